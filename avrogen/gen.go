@@ -0,0 +1,731 @@
+// Package avrogen generates Go types for avro schemas: a struct with avro tags for each record,
+// a string type with constants for each enum and an array type for each fixed.
+//
+// The types of the fields are the Go types of the native values of goavro: int32 for an int, int64
+// for a long, a pointer for a union of null and a type, except for the types which are nil
+// already, time.Time for a date or a timestamp, time.Duration for a time of day and *big.Rat for a
+// decimal. Another union is an interface{} holding its goavro native value.
+//
+// The value of a union of null and a type is nil for null, whichever the order of the branches:
+// a nil pointer, slice or map encodes to null and any other value, a pointer to a zero value or an
+// empty slice or map too, to the other branch. Decoding sets it to nil for null and to a new value
+// otherwise, never to nil for an empty bytes, array or map.
+//
+// With Options.Codec the records get ToNative and FromNative methods converting them to and from
+// the native values of goavro, and the types of the schemas Decode and Encode methods which decode
+// and encode them with a kafkaavro.Codec. FromNative accepts the values of a codec with
+// WithLogicalTypes or WithEnumType too. It sets the optional fields which the data does not hold
+// to nil and leaves the other fields which the data does not hold as is.
+//
+// The output is deterministic: the schemas are generated in the order of their name, the named
+// types in the order of their definition.
+package avrogen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// Header is the first line of the generated code.
+const Header = "// Code generated by gokafkaavro gen. DO NOT EDIT."
+
+// Schema is an avro schema to generate the types of. Name identifies the schema, e.g. its subject
+// or file name, it orders the schemas and names the const of a schema which is not a named type.
+type Schema struct {
+	Name   string
+	Schema string
+}
+
+// Options are the options of Generate.
+type Options struct {
+	// Package is the name of the package of the generated code.
+	Package string
+	// Codec generates the ToNative, FromNative, Decode and Encode methods.
+	Codec bool
+}
+
+type root struct {
+	name   string
+	schema string
+	node   *avroschema.Node
+}
+
+type generator struct {
+	options Options
+
+	roots []root
+	// definitions are the named types of all schemas, a type is defined once
+	definitions []*avroschema.Node
+	sources     map[string]string
+	names       map[*avroschema.Node]string
+	identifiers map[string]string
+
+	imports map[string]bool
+	temps   int
+	out     *bytes.Buffer
+}
+
+// Generate returns the formatted Go source of the types of the schemas. A named type may be
+// defined by several schemas, e.g. a record shared by the schemas of several subjects, as long as
+// the definitions are the same.
+func Generate(schemas []Schema, options Options) (source []byte, err error) {
+
+	if len(schemas) == 0 {
+		return nil, errNoSchemas
+	}
+	if !isIdentifier(options.Package) {
+		return nil, fmt.Errorf("invalid package name %q", options.Package)
+	}
+
+	sorted := append([]Schema(nil), schemas...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	g := &generator{
+		options:     options,
+		sources:     make(map[string]string),
+		names:       make(map[*avroschema.Node]string),
+		identifiers: make(map[string]string),
+		imports:     make(map[string]bool),
+	}
+	// a schema which references the types of other schemas is added after those
+	for len(sorted) > 0 {
+		var deferred []Schema
+		var deferredErr error
+		for _, schema := range sorted {
+			var unknown avroschema.UnknownTypeError
+			if err = g.add(schema); errors.As(err, &unknown) {
+				deferred = append(deferred, schema)
+				if deferredErr == nil {
+					deferredErr = fmt.Errorf("schema %v: %w", schema.Name, err)
+				}
+			} else if err != nil {
+				return nil, fmt.Errorf("schema %v: %w", schema.Name, err)
+			}
+		}
+		if len(deferred) == len(sorted) {
+			return nil, deferredErr
+		}
+		sorted = deferred
+	}
+	sort.SliceStable(g.roots, func(i, j int) bool { return g.roots[i].name < g.roots[j].name })
+	if err = g.name(); err != nil {
+		return
+	}
+
+	g.generate()
+
+	if source, err = format.Source(g.out.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to format the generated code: %w", err)
+	}
+	return
+}
+
+// add parses the schema, references to named types defined by an earlier schema resolve to the
+// earlier definition.
+func (g *generator) add(schema Schema) (err error) {
+
+	p := &avroschema.Parser{Known: make(map[string]*avroschema.Node)}
+	for _, definition := range g.definitions {
+		p.Known[definition.Name] = definition
+	}
+	n, err := p.Parse(schema.Schema)
+	if err != nil {
+		return
+	}
+	defined := make(map[string]bool)
+	for _, definition := range p.Definitions {
+		if defined[definition.Name] {
+			return fmt.Errorf("%v is defined twice", definition.Name)
+		}
+		defined[definition.Name] = true
+	}
+
+	var compact bytes.Buffer
+	if err = json.Compact(&compact, []byte(schema.Schema)); err != nil {
+		return
+	}
+	g.roots = append(g.roots, root{name: schema.Name, schema: compact.String(), node: n})
+
+	for _, definition := range p.Definitions {
+		earlier := g.definition(definition.Name)
+		if earlier == nil {
+			g.definitions = append(g.definitions, definition)
+			g.sources[definition.Name] = schema.Name
+			continue
+		}
+		if signature(earlier) != signature(definition) {
+			return fmt.Errorf("%v is defined differently by schema %v", definition.Name, g.sources[definition.Name])
+		}
+	}
+	return
+}
+
+func (g *generator) definition(name string) *avroschema.Node {
+	for _, definition := range g.definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+// signature describes the Go types of a named type, the named types it references by their name.
+func signature(n *avroschema.Node) string {
+	var s strings.Builder
+	s.WriteString(n.Type + " " + n.Logical + " " + strconv.Itoa(n.Size))
+	for _, symbol := range n.Symbols {
+		s.WriteString(" " + symbol)
+	}
+	for _, f := range n.Fields {
+		s.WriteString(" " + f.Name + ":" + reference(f.Node))
+	}
+	return s.String()
+}
+
+func reference(n *avroschema.Node) string {
+	switch {
+	case n.Name != "":
+		return n.Name
+	case n.Type == "array":
+		return "array<" + reference(n.Items) + ">"
+	case n.Type == "map":
+		return "map<" + reference(n.Values) + ">"
+	case n.Type == "union":
+		branches := make([]string, len(n.Branches))
+		for i, branch := range n.Branches {
+			branches[i] = reference(branch)
+		}
+		return "[" + strings.Join(branches, ",") + "]"
+	}
+	return n.Type + "." + n.Logical
+}
+
+// name names the Go types of the named types after their name, or their full name when types of
+// different namespaces share their name.
+func (g *generator) name() (err error) {
+
+	namespaces := make(map[string]int)
+	for _, definition := range g.definitions {
+		namespaces[shortName(definition.Name)]++
+	}
+	for _, definition := range g.definitions {
+		name := camel(shortName(definition.Name))
+		if namespaces[shortName(definition.Name)] > 1 {
+			name = camel(definition.Name)
+		}
+		if err = g.declare(name, definition.Name); err != nil {
+			return
+		}
+		g.names[definition] = name
+		for _, symbol := range definition.Symbols {
+			if err = g.declare(name+camel(symbol), definition.Name+"."+symbol); err != nil {
+				return
+			}
+		}
+	}
+	for _, r := range g.roots {
+		if name := g.schemaConst(r); name != "" {
+			if err = g.declare(name, "the schema "+r.name); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (g *generator) declare(identifier string, of string) error {
+	if !isIdentifier(identifier) {
+		return fmt.Errorf("%v has no valid Go name", of)
+	}
+	if other, found := g.identifiers[identifier]; found {
+		return fmt.Errorf("%v and %v are both generated as %v", other, of, identifier)
+	}
+	g.identifiers[identifier] = of
+	return nil
+}
+
+// schemaConst returns the name of the const of the schema of the root, or "" if an earlier schema
+// of the same type declares it.
+func (g *generator) schemaConst(r root) string {
+	if r.node.Name == "" {
+		return camel(r.name) + "Schema"
+	}
+	for _, earlier := range g.roots {
+		if earlier.node.Name == r.node.Name {
+			if earlier.name != r.name {
+				return ""
+			}
+			break
+		}
+	}
+	return g.typeName(r.node) + "Schema"
+}
+
+// typeName returns the Go name of a named type, references to it in later schemas are other nodes.
+func (g *generator) typeName(n *avroschema.Node) string {
+	return g.names[g.definition(n.Name)]
+}
+
+func (g *generator) generate() {
+
+	body := &bytes.Buffer{}
+	g.out = body
+
+	g.printf("const (\n")
+	for _, r := range g.roots {
+		if name := g.schemaConst(r); name != "" {
+			g.printf("// %v is the schema %v.\n%v = %v\n", name, r.name, name, strconv.Quote(r.schema))
+		}
+	}
+	g.printf(")\n\n")
+
+	for _, definition := range g.definitions {
+		switch definition.Type {
+		case "record":
+			g.record(definition)
+		case "enum":
+			g.enum(definition)
+		case "fixed":
+			if definition.Logical != "decimal" {
+				name := g.names[definition]
+				g.comment(definition.Doc, name+" is the fixed "+definition.Name+".")
+				g.printf("type %v [%d]byte\n\n", name, definition.Size)
+			}
+		}
+	}
+
+	g.out = &bytes.Buffer{}
+	g.printf("%v\n\n", Header)
+	sources := make([]string, len(g.roots))
+	for i, r := range g.roots {
+		sources[i] = r.name
+	}
+	g.printf("// Schemas: %v\n\n", strings.Join(sources, ", "))
+	g.printf("package %v\n\n", g.options.Package)
+
+	// the standard library first, then the packages of kafkaavro
+	var standard, others []string
+	for path := range g.imports {
+		if strings.Contains(path, ".") {
+			others = append(others, path)
+		} else {
+			standard = append(standard, path)
+		}
+	}
+	sort.Strings(standard)
+	sort.Strings(others)
+	if len(g.imports) > 0 {
+		g.printf("import (\n")
+		for _, path := range standard {
+			g.printf("%q\n", path)
+		}
+		g.printf("\n")
+		for _, path := range others {
+			g.printf("%q\n", path)
+		}
+		g.printf(")\n\n")
+	}
+	g.out.Write(body.Bytes())
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.out, format, args...)
+}
+
+func (g *generator) comment(doc string, otherwise string) {
+	if doc == "" {
+		doc = otherwise
+	}
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		g.printf("// %v\n", strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+}
+
+func (g *generator) enum(n *avroschema.Node) {
+
+	name := g.names[n]
+	g.comment(n.Doc, name+" is the enum "+n.Name+".")
+	g.printf("type %v string\n\n", name)
+	if len(n.Symbols) == 0 {
+		return
+	}
+	g.printf("// The symbols of %v.\nconst (\n", name)
+	for _, symbol := range n.Symbols {
+		g.printf("%v %v = %q\n", name+camel(symbol), name, symbol)
+	}
+	g.printf(")\n\n")
+}
+
+// fieldNames returns the Go names of the fields of the record.
+func (g *generator) fieldNames(n *avroschema.Node) []string {
+
+	reserved := make(map[string]bool)
+	if g.options.Codec {
+		for _, method := range []string{"ToNative", "FromNative", "Decode", "Encode"} {
+			reserved[method] = true
+		}
+	}
+	taken := make(map[string]bool)
+	names := make([]string, len(n.Fields))
+	for i, f := range n.Fields {
+		name := camel(f.Name)
+		if reserved[name] {
+			name += "Field"
+		}
+		for base, suffix := name, 2; taken[name]; suffix++ {
+			name = base + strconv.Itoa(suffix)
+		}
+		taken[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func (g *generator) record(n *avroschema.Node) {
+
+	name := g.names[n]
+	g.comment(n.Doc, name+" is the record "+n.Name+".")
+	g.printf("type %v struct {\n", name)
+	for i, fieldName := range g.fieldNames(n) {
+		f := n.Fields[i]
+		if f.Doc != "" {
+			g.comment(f.Doc, "")
+		}
+		g.printf("%v %v `avro:%q`\n", fieldName, g.goType(f.Node), f.Name)
+	}
+	g.printf("}\n\n")
+
+	if g.options.Codec {
+		g.toNativeMethod(n)
+		g.fromNativeMethod(n)
+		for _, r := range g.roots {
+			if r.node.Name == n.Name && g.schemaConst(r) != "" {
+				g.codecMethods(n)
+			}
+		}
+	}
+}
+
+// goType returns the Go type of the native values of the schema.
+func (g *generator) goType(n *avroschema.Node) string {
+
+	switch n.Type + "." + n.Logical {
+	case "int.date", "long.timestamp-millis", "long.timestamp-micros":
+		g.imports["time"] = true
+		return "time.Time"
+	case "int.time-millis", "long.time-micros":
+		g.imports["time"] = true
+		return "time.Duration"
+	case "bytes.decimal", "fixed.decimal":
+		g.imports["math/big"] = true
+		return "*big.Rat"
+	}
+
+	switch n.Type {
+	case "boolean":
+		return "bool"
+	case "int":
+		return "int32"
+	case "long":
+		return "int64"
+	case "float":
+		return "float32"
+	case "double":
+		return "float64"
+	case "bytes":
+		return "[]byte"
+	case "string":
+		return "string"
+	case "record", "enum", "fixed":
+		return g.typeName(n)
+	case "array":
+		return "[]" + g.goType(n.Items)
+	case "map":
+		return "map[string]" + g.goType(n.Values)
+	case "union":
+		if other, ok := n.Nullable(); ok {
+			if typ := g.goType(other); nilable(typ) {
+				return typ
+			}
+			return "*" + g.goType(other)
+		}
+	}
+	return "interface{}"
+}
+
+// nilable returns whether nil is a value of the Go type, the null of a union with null.
+func nilable(typ string) bool {
+	return strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[") || strings.HasPrefix(typ, "*") || typ == "interface{}"
+}
+
+func (g *generator) temp(name string) string {
+	g.temps++
+	return name + strconv.Itoa(g.temps)
+}
+
+func receiver(typeName string) string {
+	return strings.ToLower(typeName[:1])
+}
+
+func (g *generator) toNativeMethod(n *avroschema.Node) {
+
+	name := g.names[n]
+	r := receiver(name)
+	g.temps = 0
+	g.printf("// ToNative returns the goavro native value of the %v.\n", name)
+	g.printf("func (%v %v) ToNative() map[string]interface{} {\nreturn map[string]interface{}{\n", r, name)
+	for i, fieldName := range g.fieldNames(n) {
+		g.printf("%q: %v,\n", n.Fields[i].Name, g.toNative(n.Fields[i].Node, r+"."+fieldName))
+	}
+	g.printf("}\n}\n\n")
+}
+
+// toNative returns the expression converting the Go value of the expression, which is addressable,
+// to its native value.
+func (g *generator) toNative(n *avroschema.Node, expression string) string {
+
+	switch n.Type {
+
+	case "record":
+		return expression + ".ToNative()"
+
+	case "enum":
+		return "string(" + expression + ")"
+
+	case "fixed":
+		if n.Logical != "decimal" {
+			return expression + "[:]"
+		}
+
+	case "array":
+		a, i, v := g.temp("a"), g.temp("i"), g.temp("v")
+		return fmt.Sprintf("func() []interface{} {\n%v := make([]interface{}, len(%v))\nfor %v, %v := range %v {\n%v[%v] = %v\n}\nreturn %v\n}()",
+			a, expression, i, v, expression, a, i, g.toNative(n.Items, v), a)
+
+	case "map":
+		m, k, v := g.temp("m"), g.temp("k"), g.temp("v")
+		return fmt.Sprintf("func() map[string]interface{} {\n%v := make(map[string]interface{}, len(%v))\nfor %v, %v := range %v {\n%v[%v] = %v\n}\nreturn %v\n}()",
+			m, expression, k, v, expression, m, k, g.toNative(n.Values, v), m)
+
+	case "union":
+		other, ok := n.Nullable()
+		if !ok {
+			return expression
+		}
+		value := expression
+		var bind string
+		if !nilable(g.goType(other)) {
+			value = g.temp("v")
+			bind = value + " := *" + expression + "\n"
+		}
+		return fmt.Sprintf("func() interface{} {\nif %v == nil {\nreturn nil\n}\n%vreturn map[string]interface{}{%q: %v}\n}()",
+			expression, bind, other.UnionName(), g.toNative(other, value))
+	}
+	return expression
+}
+
+func (g *generator) fromNativeMethod(n *avroschema.Node) {
+
+	name := g.names[n]
+	r := receiver(name)
+	g.temps = 0
+	g.printf("// FromNative sets the fields of the %v to those of its goavro native value.\n", name)
+	g.printf("func (%v *%v) FromNative(native interface{}) error {\n", r, name)
+	g.printf("record, err := avrogen.Record(native)\nif err != nil {\nreturn err\n}\n")
+	g.imports["github.com/timvw/kafkaavro/avrogen"] = true
+	for i, fieldName := range g.fieldNames(n) {
+		f := n.Fields[i]
+		value := g.temp("v")
+		g.printf("if %v, found := record[%q]; found {\n", value, f.Name)
+		g.assign(f.Node, value, r+"."+fieldName, f.Name)
+		if _, ok := f.Node.Nullable(); ok {
+			// a missing optional field is null, not the value of a record decoded into before
+			g.printf("} else {\n%v.%v = nil\n", r, fieldName)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+// assign prints the statements assigning the native value of the expression to the addressable
+// Go value dst, the errors name the field.
+func (g *generator) assign(n *avroschema.Node, expression string, dst string, fieldName string) {
+
+	check := func() {
+		g.imports["fmt"] = true
+		g.printf("if err != nil {\nreturn fmt.Errorf(\"field %v: %%w\", err)\n}\n", fieldName)
+	}
+	convert := func(conversion string) {
+		g.printf("%v, err = avrogen.%v(%v)\n", dst, conversion, expression)
+		check()
+	}
+
+	switch n.Type + "." + n.Logical {
+	case "int.date", "long.timestamp-millis", "long.timestamp-micros":
+		convert("Time")
+		return
+	case "int.time-millis", "long.time-micros":
+		convert("Duration")
+		return
+	case "bytes.decimal", "fixed.decimal":
+		convert("Decimal")
+		return
+	}
+
+	switch n.Type {
+
+	case "boolean", "int", "long", "float", "double", "bytes", "string":
+		convert(camel(n.Type))
+
+	case "enum":
+		symbol := g.temp("s")
+		g.printf("%v, err := avrogen.String(%v)\n", symbol, expression)
+		check()
+		g.printf("%v = %v(%v)\n", dst, g.typeName(n), symbol)
+
+	case "fixed":
+		g.printf("err = avrogen.Fixed(%v, %v[:])\n", expression, dst)
+		check()
+
+	case "record":
+		g.printf("err = %v.FromNative(%v)\n", dst, expression)
+		check()
+
+	case "array":
+		items, i, v := g.temp("a"), g.temp("i"), g.temp("v")
+		g.printf("%v, err := avrogen.Array(%v)\n", items, expression)
+		check()
+		g.printf("%v = make(%v, len(%v))\n", dst, g.goType(n), items)
+		g.printf("for %v, %v := range %v {\n", i, v, items)
+		g.assign(n.Items, v, dst+"["+i+"]", fieldName)
+		g.printf("}\n")
+
+	case "map":
+		values, k, v, e := g.temp("m"), g.temp("k"), g.temp("v"), g.temp("e")
+		g.printf("%v, err := avrogen.Map(%v)\n", values, expression)
+		check()
+		g.printf("%v = make(%v, len(%v))\n", dst, g.goType(n), values)
+		g.printf("for %v, %v := range %v {\nvar %v %v\n", k, v, values, e, g.goType(n.Values))
+		g.assign(n.Values, v, e, fieldName)
+		g.printf("%v[%v] = %v\n}\n", dst, k, e)
+
+	case "union":
+		other, ok := n.Nullable()
+		if !ok {
+			g.printf("%v = %v\n", dst, expression)
+			return
+		}
+		u := g.temp("u")
+		g.printf("if %v := avrogen.Union(%v, %q); %v == nil {\n%v = nil\n} else {\n", u, expression, other.UnionName(), u, dst)
+		if typ := g.goType(other); nilable(typ) {
+			g.assign(other, u, dst, fieldName)
+		} else {
+			e := g.temp("e")
+			g.printf("var %v %v\n", e, typ)
+			g.assign(other, u, e, fieldName)
+			g.printf("%v = &%v\n", dst, e)
+		}
+		g.printf("}\n")
+
+	default:
+		g.printf("%v = %v\n", dst, expression)
+	}
+}
+
+func (g *generator) codecMethods(n *avroschema.Node) {
+
+	name := g.typeName(n)
+	r := receiver(name)
+	g.imports["github.com/timvw/kafkaavro"] = true
+	g.printf("// Decode decodes the data of the topic with the codec to the %v.\n", name)
+	g.printf("func (%v *%v) Decode(codec *kafkaavro.Codec, topic string, isKey bool, data []byte) error {\n", r, name)
+	g.printf("native, err := codec.Decode(topic, isKey, data)\nif err != nil {\nreturn err\n}\nreturn %v.FromNative(native)\n}\n\n", r)
+	g.printf("// Encode encodes the %v for the topic with the codec.\n", name)
+	g.printf("func (%v %v) Encode(codec *kafkaavro.Codec, topic string, isKey bool) ([]byte, error) {\n", r, name)
+	g.printf("return codec.Encode(topic, isKey, %v.ToNative())\n}\n\n", r)
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// initialisms are the words which Go names spell in upper case.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "MD5": true, "QPS": true, "RAM": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true,
+	"UDP": true, "UI": true, "UID": true, "UUID": true, "URI": true, "URL": true, "UTF8": true, "VM": true,
+	"XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// camel returns the exported Go name of an avro name, e.g. CustomerID for customer_id or
+// customerId and Active for ACTIVE.
+func camel(name string) string {
+
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			words, word = appendWord(words, word), nil
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && len(word) > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// a word starts at customerId and at the Server of HTTPServer
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				words, word = appendWord(words, word), nil
+			}
+		}
+		word = append(word, r)
+	}
+	words = appendWord(words, word)
+
+	var camel strings.Builder
+	for _, w := range words {
+		upper := strings.ToUpper(w)
+		if initialisms[upper] {
+			camel.WriteString(upper)
+			continue
+		}
+		lower := []rune(strings.ToLower(w))
+		lower[0] = unicode.ToUpper(lower[0])
+		camel.WriteString(string(lower))
+	}
+	if s := camel.String(); s != "" && !unicode.IsLetter([]rune(s)[0]) {
+		return "X" + s
+	}
+	return camel.String()
+}
+
+func appendWord(words []string, word []rune) []string {
+	if len(word) == 0 {
+		return words
+	}
+	return append(words, string(word))
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// errNoSchemas is returned by Generate without schemas.
+var errNoSchemas = errors.New("no schemas")
@@ -0,0 +1,243 @@
+package avrogen
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+func testSchemas(t *testing.T) []Schema {
+	var schemas []Schema
+	for _, name := range []string{"orders", "customer"} {
+		schema, err := os.ReadFile(filepath.Join("testdata", name+".avsc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		schemas = append(schemas, Schema{Name: name, Schema: string(schema)})
+	}
+	return schemas
+}
+
+func TestGenerate(t *testing.T) {
+
+	for _, options := range []Options{{Package: "schemas"}, {Package: "schemas", Codec: true}} {
+
+		source, err := Generate(testSchemas(t), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		golden := filepath.Join("testdata", "types.go.golden")
+		if options.Codec {
+			golden = filepath.Join("testdata", "codec.go.golden")
+		}
+		if *update {
+			if err = os.WriteFile(golden, source, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, want) {
+			t.Errorf("Generate() with %+v differs from %v, run go test -update", options, golden)
+		}
+
+		schemas := testSchemas(t)
+		schemas[0], schemas[1] = schemas[1], schemas[0]
+		if reordered, err := Generate(schemas, options); err != nil || !bytes.Equal(reordered, source) {
+			t.Errorf("Generate() of the reordered schemas returned %v and other code", err)
+		}
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+
+	source, err := Generate([]Schema{
+		{Name: "a", Schema: `{"type":"record","name":"Address","namespace":"com.example.billing","fields":[
+			{"name":"user_id","type":"long"},{"name":"userId","type":"long"},
+			{"name":"shipping","type":{"type":"record","name":"com.example.shipping.Address","fields":[{"name":"url","type":"string"}]}}]}`},
+		{Name: "orders-key", Schema: `"string"`},
+		// a schema may reference the types of the other schemas
+		{Name: "0", Schema: `{"type":"array","items":"com.example.shipping.Address"}`},
+	}, Options{Package: "names"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type ComExampleBillingAddress struct",
+		"type ComExampleShippingAddress struct",
+		"UserID +int64",
+		"UserID2 +int64",
+		"URL +string",
+		"OrdersKeySchema = ",
+		"X0Schema = ",
+	} {
+		if !regexp.MustCompile(want).Match(source) {
+			t.Errorf("Generate() does not contain %q:\n%s", want, source)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		schemas []Schema
+		options Options
+		err     string
+	}{
+		{"no schemas", nil, Options{Package: "p"}, "no schemas"},
+		{"package", []Schema{{"a", `"string"`}}, Options{Package: "my-package"}, `invalid package name "my-package"`},
+		{"invalid json", []Schema{{"a", `{"type":`}}, Options{Package: "p"}, "schema a: invalid schema"},
+		{"unknown type", []Schema{{"a", `{"type":"record","name":"R","fields":[{"name":"f","type":"Missing"}]}`}}, Options{Package: "p"},
+			`schema a: field f of R: unknown type "Missing"`},
+		{"defined twice", []Schema{{"a", `{"type":"record","name":"R","fields":[{"name":"e","type":{"type":"enum","name":"E","symbols":["A"]}},
+			{"name":"f","type":{"type":"enum","name":"E","symbols":["B"]}}]}`}}, Options{Package: "p"}, "schema a: E is defined twice"},
+		{"different definitions", []Schema{
+			{"a", `{"type":"enum","name":"E","symbols":["A"]}`},
+			{"b", `{"type":"enum","name":"E","symbols":["A","B"]}`},
+		}, Options{Package: "p"}, "schema b: E is defined differently by schema a"},
+		{"conflicting names", []Schema{{"a", `{"type":"enum","name":"E","symbols":["A"]}`}, {"b", `{"type":"fixed","name":"E_a","size":1}`}},
+			Options{Package: "p"}, "E.A and E_a are both generated as EA"},
+	}
+	for _, test := range tests {
+		if _, err := Generate(test.schemas, test.options); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: Generate() returned %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCamel(t *testing.T) {
+
+	tests := map[string]string{
+		"customer_id":     "CustomerID",
+		"customerId":      "CustomerID",
+		"CustomerID":      "CustomerID",
+		"HTTPServer":      "HTTPServer",
+		"home_url":        "HomeURL",
+		"IN_PROGRESS":     "InProgress",
+		"shipped":         "Shipped",
+		"_id":             "ID",
+		"address2":        "Address2",
+		"com.example.md5": "ComExampleMD5",
+		"1st":             "X1st",
+	}
+	for name, want := range tests {
+		if got := camel(name); got != want {
+			t.Errorf("camel(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// roundTripTest encodes and decodes the generated types with a codec.
+const roundTripTest = `package generated
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", OrderSchema)
+	price, note := 9.5, "fragile"
+	order := Order{
+		ID:               "1b4e28ba-2fa1-41d2-883f-0016d3cca427",
+		CustomerID:       42,
+		CreatedAt:        time.Date(2024, 3, 1, 12, 30, 0, 5e6, time.UTC),
+		DeliveryDate:     func() *time.Time { d := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC); return &d }(),
+		Window:           90 * time.Minute,
+		Status:           StatusInProgress,
+		Total:            big.NewRat(1999, 100),
+		Checksum:         MD5{1, 2, 3},
+		PreviousChecksum: &MD5{4},
+		Lines:            []Line{{Product: "p-1", Quantity: 2, Price: &price, Weight: 1.5}, {Product: "p-2", Quantity: 1, Gift: true}},
+		Attributes:       map[string]string{"channel": "web"},
+		Customer:         Customer{Name: "ann", EmailAddresses: []string{"ann@example.com"}, Segments: map[string][]int64{"vip": {1, 2}}},
+		Note:             &note,
+		Attachment:       []byte("pdf"),
+		Related:          []Order{{ID: "c9bf9e57-1685-4c89-bafb-ff5af830be8a", CreatedAt: time.Unix(0, 0).UTC(), Status: StatusPending, Total: big.NewRat(1, 4),
+			Lines: []Line{}, Attributes: map[string]string{}, Customer: Customer{EmailAddresses: []string{}, Segments: map[string][]int64{}}}},
+		Reference:        map[string]interface{}{"long": int64(7)},
+		EncodeField:      true,
+	}
+	order.Replaces = &Order{ID: order.Related[0].ID, CreatedAt: order.CreatedAt, Status: StatusShipped, Total: big.NewRat(0, 1), Lines: []Line{},
+		Attributes: map[string]string{}, Customer: Customer{EmailAddresses: []string{}, Segments: map[string][]int64{}}}
+
+	for _, options := range [][]kafkaavro.Option{nil, {kafkaavro.WithLogicalTypes(), kafkaavro.WithEnums()}} {
+
+		codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, options...)
+		data, err := order.Encode(codec, "orders", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Order
+		if err = decoded.Decode(codec, "orders", false, data); err != nil {
+			t.Fatal(err)
+		}
+
+		// big.Rat values of the same number are not always deeply equal
+		for _, pair := range [][2]*Order{{&order, &decoded}, {&order.Related[0], &decoded.Related[0]}, {order.Replaces, decoded.Replaces}} {
+			if pair[0].Total.Cmp(pair[1].Total) != 0 {
+				t.Errorf("decoded total %v, want %v", pair[1].Total, pair[0].Total)
+			}
+			pair[1].Total = pair[0].Total
+		}
+		if !reflect.DeepEqual(decoded, order) {
+			t.Errorf("Decode() returned\n%+v\nwant\n%+v", decoded, order)
+		}
+	}
+}
+`
+
+func TestGeneratedCode(t *testing.T) {
+	testGeneratedCode(t, testSchemas(t), roundTripTest)
+}
+
+// testGeneratedCode runs go vet and the test on the code generated for the schemas.
+func testGeneratedCode(t *testing.T, schemas []Schema, test string) {
+
+	if testing.Short() {
+		t.Skip("runs go vet and go test on the generated code")
+	}
+
+	source, err := Generate(schemas, Options{Package: "generated", Codec: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated code imports the packages of the module, it is built in the module
+	dir, err := os.MkdirTemp(".", "_generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err = os.WriteFile(filepath.Join(dir, "schemas.go"), source, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "schemas_test.go"), []byte(test), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range [][]string{{"vet"}, {"test", "-count=1"}} {
+		cmd := exec.Command("go", append(command, "./"+filepath.Base(dir))...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %v of the generated code failed: %v\n%s", command[0], err, output)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package avrogen
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// The conversions of the native values of goavro which the generated FromNative methods use. They
+// accept the values goavro decodes and the ones kafkaavro converts them to, e.g. a uuid.UUID for a
+// string with WithLogicalTypes, as well as the promoted numbers of DecodeWithReader.
+
+// Boolean returns the boolean native value.
+func Boolean(native interface{}) (bool, error) {
+	if v, ok := native.(bool); ok {
+		return v, nil
+	}
+	return false, unexpected("boolean", native)
+}
+
+// Int returns the int native value.
+func Int(native interface{}) (int32, error) {
+	switch v := native.(type) {
+	case int32:
+		return v, nil
+	case int:
+		return int32(v), nil
+	}
+	return 0, unexpected("int", native)
+}
+
+// Long returns the long native value.
+func Long(native interface{}) (int64, error) {
+	switch v := native.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	}
+	return 0, unexpected("long", native)
+}
+
+// Float returns the float native value.
+func Float(native interface{}) (float32, error) {
+	switch v := native.(type) {
+	case float32:
+		return v, nil
+	case float64:
+		return float32(v), nil
+	}
+	return 0, unexpected("float", native)
+}
+
+// Double returns the double native value.
+func Double(native interface{}) (float64, error) {
+	switch v := native.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	}
+	return 0, unexpected("double", native)
+}
+
+// Bytes returns the bytes native value, never nil, so that an empty bytes in a union with null is
+// not taken for null.
+func Bytes(native interface{}) ([]byte, error) {
+	switch v := native.(type) {
+	case []byte:
+		if v == nil {
+			return []byte{}, nil
+		}
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, unexpected("bytes", native)
+}
+
+// String returns the string native value, or the string of a fmt.Stringer, e.g. a uuid.UUID.
+func String(native interface{}) (string, error) {
+	switch v := native.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	if v := reflect.ValueOf(native); v.Kind() == reflect.String {
+		// the values of a string type, e.g. of WithEnumType
+		return v.String(), nil
+	}
+	return "", unexpected("string", native)
+}
+
+// Fixed copies the fixed native value to the array of the fixed, e.g. fixed[:].
+func Fixed(native interface{}, fixed []byte) error {
+	v, ok := native.([]byte)
+	if !ok {
+		return unexpected("fixed", native)
+	}
+	if len(v) != len(fixed) {
+		return fmt.Errorf("expected a fixed of %d bytes, got %d bytes", len(fixed), len(v))
+	}
+	copy(fixed, v)
+	return nil
+}
+
+// Time returns the native value of a date or timestamp.
+func Time(native interface{}) (time.Time, error) {
+	if v, ok := native.(time.Time); ok {
+		return v, nil
+	}
+	return time.Time{}, unexpected("time.Time", native)
+}
+
+// Duration returns the native value of a time of day.
+func Duration(native interface{}) (time.Duration, error) {
+	if v, ok := native.(time.Duration); ok {
+		return v, nil
+	}
+	return 0, unexpected("time.Duration", native)
+}
+
+// Decimal returns the native value of a decimal.
+func Decimal(native interface{}) (*big.Rat, error) {
+	if v, ok := native.(*big.Rat); ok {
+		return v, nil
+	}
+	return nil, unexpected("*big.Rat", native)
+}
+
+// Record returns the native value of a record.
+func Record(native interface{}) (map[string]interface{}, error) {
+	if v, ok := native.(map[string]interface{}); ok {
+		return v, nil
+	}
+	return nil, unexpected("record", native)
+}
+
+// Array returns the native value of an array.
+func Array(native interface{}) ([]interface{}, error) {
+	if v, ok := native.([]interface{}); ok {
+		return v, nil
+	}
+	return nil, unexpected("array", native)
+}
+
+// Map returns the native value of a map.
+func Map(native interface{}) (map[string]interface{}, error) {
+	if v, ok := native.(map[string]interface{}); ok {
+		return v, nil
+	}
+	return nil, unexpected("map", native)
+}
+
+// Union returns the value of the branch of the native value of a union: goavro decodes a union to
+// nil or a map of the name of the branch to its value, WithLogicalTypes also accepts the value of
+// a union with null as is.
+func Union(native interface{}, branch string) (value interface{}) {
+	if wrapped, ok := native.(map[string]interface{}); ok && len(wrapped) == 1 {
+		if value, found := wrapped[branch]; found {
+			return value
+		}
+	}
+	return native
+}
+
+func unexpected(expected string, native interface{}) error {
+	return fmt.Errorf("expected a %v, got %T", expected, native)
+}
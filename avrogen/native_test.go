@@ -0,0 +1,59 @@
+package avrogen
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type symbol string
+
+func TestNativeConversions(t *testing.T) {
+
+	id := uuid.New()
+	for _, native := range []interface{}{id.String(), id, []byte(id.String()), symbol(id.String())} {
+		if s, err := String(native); err != nil || s != id.String() {
+			t.Errorf("String(%#v) returned %v, %v", native, s, err)
+		}
+	}
+	if _, err := String(1); err == nil {
+		t.Error("String(1) succeeded")
+	}
+
+	if v, err := Long(int32(7)); err != nil || v != 7 {
+		t.Errorf("Long() of a promoted int returned %v, %v", v, err)
+	}
+	if v, err := Double(float32(1.5)); err != nil || v != 1.5 {
+		t.Errorf("Double() of a promoted float returned %v, %v", v, err)
+	}
+	if v, err := Bytes([]byte(nil)); err != nil || v == nil {
+		t.Errorf("Bytes() of nil returned %#v, %v, want an empty bytes", v, err)
+	}
+
+	var fixed [2]byte
+	if err := Fixed([]byte{1, 2}, fixed[:]); err != nil || fixed != [2]byte{1, 2} {
+		t.Errorf("Fixed() returned %v, %v", fixed, err)
+	}
+	if err := Fixed([]byte{1}, fixed[:]); err == nil {
+		t.Error("Fixed() of a short value succeeded")
+	}
+
+	tests := []struct {
+		native interface{}
+		want   interface{}
+	}{
+		{nil, nil},
+		{map[string]interface{}{"string": "a"}, "a"},
+		{"a", "a"},
+		{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "a"}},
+	}
+	for _, test := range tests {
+		got := Union(test.native, "string")
+		if s, ok := test.want.(string); ok && got != s || test.want == nil && got != nil {
+			t.Errorf("Union(%v) returned %v, want %v", test.native, got, test.want)
+		}
+	}
+	if got, ok := Union(map[string]interface{}{"name": "a"}, "string").(map[string]interface{}); !ok || got["name"] != "a" {
+		t.Errorf("Union() of a record with one field returned %v", got)
+	}
+}
@@ -0,0 +1,193 @@
+package avrogen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestOptionalFields verifies the mapping of the unions with null to pointers, nil slices and nil
+// maps in both directions with the generated code of testdata/optional.avsc.
+func TestOptionalFields(t *testing.T) {
+
+	schema, err := os.ReadFile(filepath.Join("testdata", "optional.avsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGeneratedCode(t, []Schema{{Name: "profiles", Schema: string(schema)}}, optionalTest)
+}
+
+// optionalTest encodes and decodes the optional fields of the generated Profile.
+const optionalTest = `package generated
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// codecs returns the codecs with and without the conversions of the logical types and enums.
+func codecs() []*kafkaavro.Codec {
+	registry := mockregistry.New()
+	registry.Register("profiles-value", ProfileSchema)
+	return []*kafkaavro.Codec{
+		kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}),
+		kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithLogicalTypes(), kafkaavro.WithEnums()),
+	}
+}
+
+// roundTrip encodes the profile, decodes it into a profile with the values of into and compares it
+// with the profile.
+func roundTrip(t *testing.T, name string, profile Profile, into Profile) (native map[string]interface{}) {
+
+	t.Helper()
+	for _, codec := range codecs() {
+		data, err := profile.Encode(codec, "profiles", false)
+		if err != nil {
+			t.Fatalf("%v: Encode() returned %v", name, err)
+		}
+		decoded := into
+		if err = decoded.Decode(codec, "profiles", false, data); err != nil {
+			t.Fatalf("%v: Decode() returned %v", name, err)
+		}
+		// big.Rat values of the same number are not always deeply equal
+		if (decoded.Balance == nil) != (profile.Balance == nil) || decoded.Balance != nil && decoded.Balance.Cmp(profile.Balance) != 0 {
+			t.Errorf("%v: decoded the balance %v, want %v", name, decoded.Balance, profile.Balance)
+		}
+		decoded.Balance = profile.Balance
+		if !reflect.DeepEqual(decoded, profile) {
+			t.Errorf("%v: Decode() returned\n%#v\nwant\n%#v", name, decoded, profile)
+		}
+		if native == nil {
+			value, err := codec.Decode("profiles", false, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			native = value.(map[string]interface{})
+		}
+	}
+	return
+}
+
+// set has a value in every optional field, of which some are nil themselves.
+func set() Profile {
+	return Profile{
+		Age:         ptr(int32(42)),
+		Score:       ptr(9.5),
+		Active:      ptr(true),
+		Nickname:    ptr("ann"),
+		Avatar:      []byte("png"),
+		Level:       ptr(int64(7)),
+		Tier:        ptr(TierPro),
+		Fingerprint: &Fingerprint{1, 2, 3, 4},
+		Born:        ptr(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)),
+		Balance:     big.NewRat(1999, 100),
+		Address:     &Address{Street: "main", Unit: ptr("2b"), Geo: &Geo{Lat: 51.2, Lon: 4.4}},
+		Tags:        []string{"a", "b"},
+		Labels:      map[string]string{"k": "v"},
+		Previous:    []*Address{{Street: "old"}, nil},
+		Counters:    map[string]*int64{"visits": ptr(int64(3)), "unknown": nil},
+		Aliases:     []*string{ptr("annie"), nil},
+	}
+}
+
+func TestNilPointers(t *testing.T) {
+
+	// the nil pointers, slices and maps encode to null, the non optional ones to empty values
+	native := roundTrip(t, "nil", Profile{Previous: []*Address{}, Counters: map[string]*int64{}}, Profile{})
+	for _, field := range []string{"age", "score", "active", "nickname", "avatar", "level", "tier", "fingerprint", "born", "balance", "address", "tags", "labels", "aliases"} {
+		if native[field] != nil {
+			t.Errorf("the nil %v encoded to %v, want null", field, native[field])
+		}
+	}
+
+	// and decode to nil over the values of the profile decoded into
+	roundTrip(t, "nil into set", Profile{Previous: []*Address{}, Counters: map[string]*int64{}}, set())
+}
+
+func TestZeroPointees(t *testing.T) {
+
+	// the pointers to zero values and the empty slices and maps are not null
+	zero := Profile{
+		Age:         ptr(int32(0)),
+		Score:       ptr(0.0),
+		Active:      ptr(false),
+		Nickname:    ptr(""),
+		Avatar:      []byte{},
+		Level:       ptr(int64(0)),
+		Tier:        ptr(TierFree),
+		Fingerprint: &Fingerprint{},
+		Born:        ptr(time.Unix(0, 0).UTC()),
+		Balance:     new(big.Rat),
+		Address:     &Address{},
+		Tags:        []string{},
+		Labels:      map[string]string{},
+		Previous:    []*Address{{}},
+		Counters:    map[string]*int64{"zero": ptr(int64(0))},
+		Aliases:     []*string{ptr("")},
+	}
+	native := roundTrip(t, "zero", zero, Profile{})
+	for _, field := range []string{"age", "score", "active", "nickname", "avatar", "level", "tier", "fingerprint", "born", "balance", "address", "tags", "labels", "aliases"} {
+		if native[field] == nil {
+			t.Errorf("the zero %v encoded to null", field)
+		}
+	}
+
+	want := map[string]interface{}{
+		"age":      map[string]interface{}{"int": int32(0)},
+		"nickname": map[string]interface{}{"string": ""},
+		"level":    map[string]interface{}{"long": int64(0)},
+		"tags":     map[string]interface{}{"array": []interface{}{}},
+		"labels":   map[string]interface{}{"map": map[string]interface{}{}},
+	}
+	for field, value := range want {
+		if !reflect.DeepEqual(zero.ToNative()[field], value) {
+			t.Errorf("ToNative() of the zero %v returned %#v, want %#v", field, zero.ToNative()[field], value)
+		}
+	}
+}
+
+func TestSetPointers(t *testing.T) {
+
+	roundTrip(t, "set", set(), Profile{})
+	roundTrip(t, "set into set", set(), set())
+
+	// the nested records are decoded into new values, not into those of the profile decoded into
+	into := set()
+	address := into.Address
+	profile := set()
+	profile.Address = &Address{Street: "other"}
+	roundTrip(t, "nested", profile, into)
+	if address.Street != "main" || address.Unit == nil || address.Geo == nil {
+		t.Errorf("Decode() changed the address of the profile decoded into to %+v", address)
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+
+	// the optional fields which the native value does not hold are null, the others are kept
+	profile := set()
+	if err := profile.FromNative(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Profile{Previous: set().Previous, Counters: set().Counters}); !reflect.DeepEqual(profile, want) {
+		t.Errorf("FromNative() without the fields returned\n%#v\nwant\n%#v", profile, want)
+	}
+
+	var address Address
+	if err := address.FromNative(map[string]interface{}{"street": "main", "geo": map[string]interface{}{"com.example.profiles.Geo": map[string]interface{}{"lat": 1.0}}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Address{Street: "main", Geo: &Geo{Lat: 1}}); !reflect.DeepEqual(address, want) {
+		t.Errorf("FromNative() of a nested record without fields returned %#v, want %#v", address, want)
+	}
+}
+`
@@ -0,0 +1,531 @@
+// Package avrotest checks that the values of a schema survive a round trip through a
+// kafkaavro.Codec, for the test suites of the users of the codec:
+//
+//	registry := mockregistry.New()
+//	registry.Register("orders-value", schema)
+//	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+//	avrotest.RoundTrip(t, codec, "orders", schema, avrotest.Field("email", randomEmail))
+//
+// RoundTrip generates random values of the schema, encodes and decodes them with the Codec and
+// compares the decoded values with the generated ones. The comparison normalizes what goavro
+// changes on the way:
+//
+//   - numeric widening: generated numbers may be any Go integer or float type goavro accepts, they
+//     are compared as the int32, int64, float32 and float64 which goavro decodes
+//   - union flattening: a generator may return the bare value of a union field, it is wrapped in
+//     the first branch which accepts its Go type (goavro.Union) before it is encoded
+//   - optional fields: fields with a null (or a primitive) default are left out of some values,
+//     they are compared with the default
+//   - logical types: times are compared in UTC and truncated to the precision of the type,
+//     decimals are compared by value
+package avrotest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// DefaultSamples is the number of values RoundTrip generates, see Samples.
+const DefaultSamples = 100
+
+// maxDepth is the depth of nested values after which unions pick null and arrays and maps are
+// empty, so that the values of recursive schemas are finite.
+const maxDepth = 5
+
+// Generator customizes the values which RoundTrip generates.
+type Generator func(*generator)
+
+// Field generates the values of the field, name is the name of the field or the full name of its
+// record followed by a dot and the name of the field, e.g. "com.example.Customer.email".
+func Field(name string, generate func(r *rand.Rand) interface{}) Generator {
+	return func(g *generator) {
+		g.fields[name] = generate
+	}
+}
+
+// Type generates the values of a type: the full name of a named type, a primitive type or
+// a primitive type with a logical type like "long.timestamp-millis".
+func Type(name string, generate func(r *rand.Rand) interface{}) Generator {
+	return func(g *generator) {
+		g.types[name] = generate
+	}
+}
+
+// Samples sets the number of values to generate, the default is DefaultSamples.
+func Samples(n int) Generator {
+	return func(g *generator) {
+		g.samples = n
+	}
+}
+
+// Seed sets the seed of the random values, by default it is random and reported when a round
+// trip fails, so that the failure can be reproduced.
+func Seed(seed int64) Generator {
+	return func(g *generator) {
+		g.seed = seed
+	}
+}
+
+type generator struct {
+	rand    *rand.Rand
+	seed    int64
+	samples int
+	fields  map[string]func(r *rand.Rand) interface{}
+	types   map[string]func(r *rand.Rand) interface{}
+}
+
+// RoundTrip encodes random values of the schema for the value subject of the topic and decodes them
+// with the codec, and reports the values which do not decode to the encoded value. The schema must
+// be the latest schema of the subject in the registry of the codec.
+func RoundTrip(t testing.TB, codec *kafkaavro.Codec, topic string, schema string, generators ...Generator) {
+
+	t.Helper()
+
+	root, err := avroschema.Parse(schema)
+	if err != nil {
+		t.Fatalf("avrotest: %v", err)
+		return
+	}
+
+	g := &generator{
+		seed:    time.Now().UnixNano(),
+		samples: DefaultSamples,
+		fields:  make(map[string]func(r *rand.Rand) interface{}),
+		types:   make(map[string]func(r *rand.Rand) interface{}),
+	}
+	for _, generator := range generators {
+		generator(g)
+	}
+	g.rand = rand.New(rand.NewSource(g.seed))
+
+	for i := 0; i < g.samples; i++ {
+
+		native := g.generate(root, nil, 0)
+
+		data, err := codec.Encode(topic, false, native)
+		if err != nil {
+			t.Errorf("avrotest: value %d (seed %d) %v does not encode: %v", i, g.seed, native, err)
+			return
+		}
+
+		decoded, err := codec.Decode(topic, false, data)
+		if err != nil {
+			t.Errorf("avrotest: value %d (seed %d) %v does not decode: %v", i, g.seed, native, err)
+			return
+		}
+
+		if path, equal := compare(normalize(root, native), decoded, "$"); !equal {
+			t.Errorf("avrotest: value %d (seed %d) decodes to a different %v: %v, encoded %v", i, g.seed, path, decoded, native)
+			return
+		}
+	}
+}
+
+// generate returns a random native value of the node, or the value of the custom generator.
+func (g *generator) generate(n *avroschema.Node, custom func(r *rand.Rand) interface{}, depth int) interface{} {
+
+	if custom != nil {
+		value := custom(g.rand)
+		if n.Type == "union" {
+			return wrapUnion(n, value)
+		}
+		return value
+	}
+	if custom = g.types[n.UnionName()]; custom != nil && n.Type != "union" {
+		return custom(g.rand)
+	}
+
+	r := g.rand
+	switch n.Type + "." + n.Logical {
+	case "int.date":
+		return time.Unix(r.Int63n(100*365)*86400, 0).UTC()
+	case "int.time-millis":
+		return time.Duration(r.Int63n(86400000)) * time.Millisecond
+	case "long.time-micros":
+		return time.Duration(r.Int63n(86400000000)) * time.Microsecond
+	case "long.timestamp-millis":
+		return time.UnixMilli(r.Int63n(1 << 42)).UTC()
+	case "long.timestamp-micros":
+		return time.UnixMicro(r.Int63n(1 << 52)).UTC()
+	case "bytes.decimal", "fixed.decimal":
+		return randomDecimal(r, n)
+	case "string.uuid":
+		return fmt.Sprintf("%08x-%04x-4%03x-%04x-%012x", r.Uint32(), r.Intn(1<<16), r.Intn(1<<12), 0x8000|r.Intn(1<<14), r.Int63n(1<<48))
+	}
+
+	switch n.Type {
+	case "null":
+		return nil
+	case "boolean":
+		return r.Intn(2) == 1
+	case "int":
+		value := int32(r.Uint32())
+		return widen(r, int64(value), int(value), value, float64(value))
+	case "long":
+		value := int64(r.Uint64())
+		if r.Intn(2) == 0 {
+			value = int64(int32(value))
+			return widen(r, value, int(value), int32(value))
+		}
+		return widen(r, value, int(value))
+	case "float":
+		value := float32(r.NormFloat64() * 1000)
+		return widen(r, value, float64(value))
+	case "double":
+		value := r.NormFloat64() * math.Pow10(r.Intn(20)-10)
+		if r.Intn(4) == 0 {
+			whole := int64(r.Int31())
+			return widen(r, float64(whole), whole, int(whole), float32(whole))
+		}
+		return value
+	case "bytes":
+		return randomBytes(r, r.Intn(16))
+	case "fixed":
+		return randomBytes(r, n.Size)
+	case "string":
+		return randomString(r)
+	case "enum":
+		return n.Symbols[r.Intn(len(n.Symbols))]
+	case "array":
+		items := make([]interface{}, g.length(depth))
+		for i := range items {
+			items[i] = g.generate(n.Items, nil, depth+1)
+		}
+		return items
+	case "map":
+		values := make(map[string]interface{})
+		for i := g.length(depth); i > 0; i-- {
+			values[randomString(r)] = g.generate(n.Values, nil, depth+1)
+		}
+		return values
+	case "union":
+		branch := n.Branches[r.Intn(len(n.Branches))]
+		if depth >= maxDepth {
+			for _, b := range n.Branches {
+				if b.Type == "null" {
+					branch = b
+				}
+			}
+		}
+		if branch.Type == "null" {
+			return nil
+		}
+		return goavro.Union(branch.UnionName(), g.generate(branch, nil, depth+1))
+	case "record":
+		record := make(map[string]interface{}, len(n.Fields))
+		for _, f := range n.Fields {
+			if _, omittable := defaultValue(f); omittable && r.Intn(4) == 0 {
+				continue
+			}
+			custom := g.fields[n.Name+"."+f.Name]
+			if custom == nil {
+				custom = g.fields[f.Name]
+			}
+			record[f.Name] = g.generate(f.Node, custom, depth+1)
+		}
+		return record
+	}
+	panic(fmt.Sprintf("avrotest: unsupported type %v", n.Type))
+}
+
+func (g *generator) length(depth int) int {
+	if depth >= maxDepth {
+		return 0
+	}
+	return g.rand.Intn(4)
+}
+
+// widen returns one of the Go types of the same value.
+func widen(r *rand.Rand, values ...interface{}) interface{} {
+	return values[r.Intn(len(values))]
+}
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+var stringRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 -_.\"\\/é€😀\u0000")
+
+func randomString(r *rand.Rand) string {
+	runes := make([]rune, r.Intn(12))
+	for i := range runes {
+		runes[i] = stringRunes[r.Intn(len(stringRunes))]
+	}
+	return string(runes)
+}
+
+// randomDecimal returns a value with at most the precision digits of the decimal, which fits in the
+// size of a fixed decimal.
+func randomDecimal(r *rand.Rand, n *avroschema.Node) *big.Rat {
+
+	precision := n.Precision
+	if precision <= 0 {
+		precision = 1
+	}
+	if n.Type == "fixed" {
+		// a fixed of size bytes holds 8*size-1 bits and a sign
+		precision = min(precision, int(float64(8*n.Size-1)*math.Log10(2)))
+	}
+	precision = min(precision, 38)
+
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	unscaled := new(big.Int).Rand(r, limit)
+	if r.Intn(2) == 0 {
+		unscaled.Neg(unscaled)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n.Scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, scale)
+}
+
+// defaultValue returns the native default of the field if RoundTrip may leave the field out:
+// a default of null or of a primitive type without a logical type.
+func defaultValue(f avroschema.Field) (native interface{}, omittable bool) {
+
+	if !f.HasDefault {
+		return
+	}
+	if f.Default == nil {
+		return nil, f.Node.Type == "union" && f.Node.Branches[0].Type == "null"
+	}
+	if f.Node.GoavroLogical() {
+		return
+	}
+	switch value := f.Default.(type) {
+	case bool:
+		return value, f.Node.Type == "boolean"
+	case string:
+		return value, f.Node.Type == "string" || f.Node.Type == "enum"
+	case float64:
+		switch f.Node.Type {
+		case "int":
+			return int32(value), true
+		case "long":
+			return int64(value), true
+		case "float":
+			return float32(value), true
+		case "double":
+			return value, true
+		}
+	}
+	return
+}
+
+// wrapUnion wraps the bare value of a custom generator in the first branch accepting its Go type.
+func wrapUnion(n *avroschema.Node, value interface{}) interface{} {
+
+	if value == nil {
+		return nil
+	}
+	if m, ok := value.(map[string]interface{}); ok && len(m) == 1 {
+		for _, branch := range n.Branches {
+			if _, found := m[branch.UnionName()]; found {
+				return value
+			}
+		}
+	}
+	for _, branch := range n.Branches {
+		if accepts(branch, value) {
+			return goavro.Union(branch.UnionName(), value)
+		}
+	}
+	return value
+}
+
+func accepts(n *avroschema.Node, value interface{}) bool {
+
+	switch value.(type) {
+	case bool:
+		return n.Type == "boolean"
+	case string:
+		return n.Type == "string" || n.Type == "enum"
+	case []byte:
+		return n.Type == "bytes" || n.Type == "fixed"
+	case int, int32, int64:
+		return n.Type == "int" || n.Type == "long" || n.Logical == "timestamp-millis"
+	case float32, float64:
+		return n.Type == "float" || n.Type == "double"
+	case time.Time:
+		return n.Logical == "timestamp-millis" || n.Logical == "timestamp-micros" || n.Logical == "date"
+	case time.Duration:
+		return n.Logical == "time-millis" || n.Logical == "time-micros"
+	case *big.Rat:
+		return n.Logical == "decimal"
+	case map[string]interface{}:
+		return n.Type == "record" || n.Type == "map"
+	}
+	return reflect.ValueOf(value).Kind() == reflect.Slice && n.Type == "array"
+}
+
+// normalize converts the generated value into the value goavro decodes.
+func normalize(n *avroschema.Node, value interface{}) interface{} {
+
+	if value == nil {
+		return nil
+	}
+
+	switch n.Type + "." + n.Logical {
+	case "int.date":
+		if t, ok := value.(time.Time); ok {
+			return t.UTC().Truncate(24 * time.Hour)
+		}
+	case "long.timestamp-millis":
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC().Truncate(time.Millisecond)
+		default:
+			return time.UnixMilli(toInt64(v)).UTC()
+		}
+	case "long.timestamp-micros":
+		if t, ok := value.(time.Time); ok {
+			return t.UTC().Truncate(time.Microsecond)
+		}
+	case "int.time-millis":
+		if d, ok := value.(time.Duration); ok {
+			return d.Truncate(time.Millisecond)
+		}
+	case "long.time-micros":
+		if d, ok := value.(time.Duration); ok {
+			return d.Truncate(time.Microsecond)
+		}
+	case "bytes.decimal", "fixed.decimal":
+		return value
+	}
+
+	switch n.Type {
+	case "int":
+		return int32(toInt64(value))
+	case "long":
+		return toInt64(value)
+	case "float":
+		return float32(toFloat64(value))
+	case "double":
+		return toFloat64(value)
+	case "array":
+		items := reflect.ValueOf(value)
+		normalized := make([]interface{}, items.Len())
+		for i := range normalized {
+			normalized[i] = normalize(n.Items, items.Index(i).Interface())
+		}
+		return normalized
+	case "map":
+		values := reflect.ValueOf(value)
+		normalized := make(map[string]interface{}, values.Len())
+		for _, key := range values.MapKeys() {
+			normalized[key.String()] = normalize(n.Values, values.MapIndex(key).Interface())
+		}
+		return normalized
+	case "union":
+		union, _ := value.(map[string]interface{})
+		for name, v := range union {
+			for _, branch := range n.Branches {
+				if branch.UnionName() == name {
+					return goavro.Union(name, normalize(branch, v))
+				}
+			}
+		}
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		normalized := make(map[string]interface{}, len(n.Fields))
+		for _, f := range n.Fields {
+			if v, found := record[f.Name]; found {
+				normalized[f.Name] = normalize(f.Node, v)
+			} else if native, omittable := defaultValue(f); omittable {
+				normalized[f.Name] = native
+			}
+		}
+		return normalized
+	}
+	return value
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func toFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return float64(toInt64(value))
+}
+
+// compare returns the path of the first difference between the normalized and the decoded value.
+func compare(want interface{}, got interface{}, path string) (string, bool) {
+
+	switch w := want.(type) {
+
+	case *big.Rat:
+		g, ok := got.(*big.Rat)
+		return path, ok && g.Cmp(w) == 0
+
+	case time.Time:
+		g, ok := got.(time.Time)
+		return path, ok && g.Equal(w)
+
+	case []byte:
+		g, ok := got.([]byte)
+		return path, ok && bytes.Equal(g, w)
+
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return path, false
+		}
+		for i := range w {
+			if p, equal := compare(w[i], g[i], fmt.Sprintf("%v[%d]", path, i)); !equal {
+				return p, false
+			}
+		}
+		return path, true
+
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok || len(g) != len(w) {
+			return path, false
+		}
+		keys := make([]string, 0, len(w))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if p, equal := compare(w[key], g[key], path+"."+key); !equal {
+				return p, false
+			}
+		}
+		return path, true
+	}
+
+	return path, reflect.DeepEqual(want, got)
+}
@@ -0,0 +1,161 @@
+package avrotest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// corpus are schemas with the constructs which are easy to get wrong, the schemas of the interop
+// fixtures are added to them.
+var corpus = map[string]string{
+	"primitives": `{"type":"record","name":"primitives","fields":[
+		{"name":"n","type":"null"},{"name":"b","type":"boolean"},{"name":"i","type":"int"},{"name":"l","type":"long"},
+		{"name":"f","type":"float"},{"name":"d","type":"double"},{"name":"y","type":"bytes"},{"name":"s","type":"string"}]}`,
+	"defaults": `{"type":"record","name":"defaults","namespace":"com.example","fields":[
+		{"name":"optional","type":["null","string"],"default":null},
+		{"name":"count","type":"int","default":7},
+		{"name":"ratio","type":"float","default":0.5},
+		{"name":"label","type":"string","default":"none"},
+		{"name":"level","type":{"type":"enum","name":"Level","symbols":["LOW","HIGH"]},"default":"LOW"}]}`,
+	"unions": `{"type":"record","name":"unions","namespace":"com.example","fields":[
+		{"name":"many","type":["null","int","long","float","double","string","bytes","boolean"]},
+		{"name":"named","type":["null",{"type":"record","name":"Inner","fields":[{"name":"x","type":"int"}]},
+			{"type":"enum","name":"Kind","symbols":["A","B"]},{"type":"fixed","name":"Two","size":2}]},
+		{"name":"containers","type":["null",{"type":"array","items":"Inner"},{"type":"map","values":"Kind"}]},
+		{"name":"logical","type":["null",{"type":"long","logicalType":"timestamp-millis"},{"type":"bytes","logicalType":"decimal","precision":6,"scale":3}]}]}`,
+	"logical": `{"type":"record","name":"logical","fields":[
+		{"name":"date","type":{"type":"int","logicalType":"date"}},
+		{"name":"timeMillis","type":{"type":"int","logicalType":"time-millis"}},
+		{"name":"timeMicros","type":{"type":"long","logicalType":"time-micros"}},
+		{"name":"timestampMillis","type":{"type":"long","logicalType":"timestamp-millis"}},
+		{"name":"timestampMicros","type":{"type":"long","logicalType":"timestamp-micros"}},
+		{"name":"maxPrecision","type":{"type":"bytes","logicalType":"decimal","precision":38,"scale":10}},
+		{"name":"fixedDecimal","type":{"type":"fixed","name":"amount","size":8,"logicalType":"decimal","precision":18,"scale":2}},
+		{"name":"uuid","type":{"type":"string","logicalType":"uuid"}},
+		{"name":"localTimestamp","type":{"type":"long","logicalType":"local-timestamp-millis"}}]}`,
+	"recursive": `{"type":"record","name":"Node","fields":[
+		{"name":"value","type":"long"},
+		{"name":"next","type":["null","Node"]},
+		{"name":"children","type":{"type":"array","items":"Node"}}]}`,
+	"mutually recursive": `{"type":"record","name":"Expression","namespace":"com.example","fields":[
+		{"name":"operator","type":{"type":"enum","name":"Operator","symbols":["ADD","MULTIPLY"]}},
+		{"name":"terms","type":{"type":"array","items":{"type":"record","name":"Term","fields":[
+			{"name":"constant","type":["null","double"]},
+			{"name":"expression","type":["null","Expression"]}]}}},
+		{"name":"variables","type":{"type":"map","values":["null","Term"]}}]}`,
+	"nested containers": `{"type":"record","name":"containers","fields":[
+		{"name":"matrix","type":{"type":"array","items":{"type":"array","items":"double"}}},
+		{"name":"index","type":{"type":"map","values":{"type":"array","items":"string"}}},
+		{"name":"flags","type":{"type":"map","values":["null","boolean"]}}]}`,
+}
+
+func newCodec(topic string, schema string) *kafkaavro.Codec {
+	registry := mockregistry.New()
+	registry.Register(topic+"-value", schema)
+	return kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+}
+
+func TestRoundTripCorpus(t *testing.T) {
+
+	schemas := make(map[string]string, len(corpus))
+	for name, schema := range corpus {
+		schemas[name] = schema
+	}
+	fixtures, err := filepath.Glob(filepath.Join("..", "testdata", "interop", "*", "schema.avsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range fixtures {
+		schema, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schemas["interop "+filepath.Base(filepath.Dir(path))] = string(schema)
+	}
+
+	for name, schema := range schemas {
+		t.Run(name, func(t *testing.T) {
+			RoundTrip(t, newCodec("test", schema), "test", schema, Samples(500))
+		})
+	}
+}
+
+func TestRoundTripGenerators(t *testing.T) {
+
+	schema := corpus["unions"]
+	codec := newCodec("test", schema)
+
+	var fieldCalls, typeCalls int
+	RoundTrip(t, codec, "test", schema, Seed(42), Samples(50),
+		// a bare value of the union is wrapped in the branch accepting it
+		Field("many", func(r *rand.Rand) interface{} {
+			fieldCalls++
+			return fmt.Sprint(r.Intn(100))
+		}),
+		Type("com.example.Inner", func(r *rand.Rand) interface{} {
+			typeCalls++
+			return map[string]interface{}{"x": r.Intn(10)}
+		}),
+	)
+
+	if fieldCalls != 50 {
+		t.Errorf("the field generator was called %d times, want 50", fieldCalls)
+	}
+	if typeCalls == 0 {
+		t.Error("the type generator was not called")
+	}
+}
+
+// recorder records the errors of RoundTrip.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestRoundTripFailures(t *testing.T) {
+
+	schema := `{"type":"record","name":"r","fields":[{"name":"i","type":"int"},{"name":"s","type":"string"}]}`
+	dateSchema := `{"type":"record","name":"r","fields":[{"name":"d","type":{"type":"int","logicalType":"date"}}]}`
+
+	var tests = []struct {
+		name       string
+		schema     string
+		generators []Generator
+		want       string
+	}{
+		{"invalid schema", `{"type":`, nil, "invalid schema"},
+		{"value which does not encode", schema, []Generator{Field("i", func(*rand.Rand) interface{} { return "one" })}, "does not encode"},
+		// goavro truncates the days of dates before 1970 towards the epoch
+		{"value which changes", dateSchema, []Generator{Field("d", func(*rand.Rand) interface{} { return time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC) })}, "decodes to a different $.d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := &recorder{TB: t}
+			RoundTrip(r, newCodec("test", test.schema), "test", test.schema, append(test.generators, Seed(1))...)
+
+			if len(r.errors) != 1 || !strings.Contains(r.errors[0], test.want) {
+				t.Errorf("RoundTrip() reported %q, want one error containing %q", r.errors, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithAwaitSchema makes the decodes wait for the schema ids which the registry does not know yet,
+// e.g. the first messages of a topic which was just provisioned, of which the consumers may start
+// before the producer registered the schema: a lookup of a schema id which fails with
+// ErrSchemaNotFound is made again every pollInterval, until the schema is found or the timeout has
+// passed. The lookups are made to the registry, the not found results are not cached, and the
+// waits are on the Clock, see WithClock. The OnRegistryRetry hook is called before every lookup
+// again. DecodeContext stops waiting when its context is done. Without it, the decodes fail right
+// away.
+func WithAwaitSchema(timeout time.Duration, pollInterval time.Duration) Option {
+	return func(c *Codec) {
+		c.awaitSchemaTimeout = timeout
+		c.awaitSchemaInterval = pollInterval
+	}
+}
+
+// awaitSchema makes the lookup of the schema id again until it is found, following
+// WithAwaitSchema, if it failed with ErrSchemaNotFound.
+func (c *Codec) awaitSchema(ctx context.Context, event HookEvent, err error, lookup func() error) error {
+
+	if c.awaitSchemaTimeout <= 0 || !errors.Is(err, ErrSchemaNotFound) {
+		return err
+	}
+	interval := max(c.awaitSchemaInterval, time.Millisecond)
+	deadline := c.clock.Now().Add(c.awaitSchemaTimeout)
+	for c.clock.Now().Before(deadline) {
+		select {
+		case <-c.clock.After(min(interval, deadline.Sub(c.clock.Now()))):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+		if c.hooks.OnRegistryRetry != nil {
+			event.Err = err
+			c.callHook("OnRegistryRetry", c.hooks.OnRegistryRetry, event)
+		}
+		if err = lookup(); !errors.Is(err, ErrSchemaNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("waited %v for the schema: %w", c.awaitSchemaTimeout, err)
+}
@@ -0,0 +1,77 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestWithAwaitSchema(t *testing.T) {
+
+	registry := mockregistry.New()
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+	var polls []HookEvent
+	codec := NewCodec(registry, TopicNameStrategy{}, WithClock(clock), WithAwaitSchema(time.Second, 300*time.Millisecond),
+		WithHooks(Hooks{OnRegistryRetry: func(event HookEvent) { polls = append(polls, event) }}))
+	data := []byte{0, 0, 0, 0, 1, 2, 'x'}
+
+	// the schema is registered while the decode waits for it
+	done := make(chan error)
+	go func() {
+		native, err := codec.Decode("orders", false, data)
+		if err == nil && native != "x" {
+			err = errors.New("decoded the wrong value")
+		}
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(300 * time.Millisecond)
+	clock.BlockUntil(1)
+	registry.Register("orders-value", `"string"`)
+	clock.Advance(300 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("Decode() of a schema registered while waiting returned %v", err)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 3 || len(polls) != 2 || polls[0].SchemaID != 1 || !errors.Is(polls[0].Err, ErrSchemaNotFound) {
+		t.Errorf("Decode() looked up the schema %d times and called OnRegistryRetry with %+v", calls, polls)
+	}
+
+	// the wait ends at the timeout, the last poll is at the timeout
+	go func() {
+		_, err := codec.Decode("orders", false, []byte{0, 0, 0, 0, 9, 0})
+		done <- err
+	}()
+	for _, d := range []time.Duration{300, 300, 300, 100} {
+		clock.BlockUntil(1)
+		clock.Advance(d * time.Millisecond)
+	}
+	if err := <-done; !errors.Is(err, ErrSchemaNotFound) || !strings.Contains(err.Error(), "waited 1s for the schema") {
+		t.Errorf("Decode() of an unknown schema returned %v", err)
+	}
+
+	// the wait ends with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := codec.DecodeContext(ctx, "orders", false, []byte{0, 0, 0, 0, 9, 0})
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) || !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("DecodeContext() of a cancelled context returned %v", err)
+	}
+
+	// the other errors are not waited for, nor are the unknown schemas without the option
+	registry.Script(mockregistry.GetSchemaByID, mockregistry.Fail(mockregistry.ErrUnavailable))
+	if _, err := codec.Decode("orders", false, []byte{0, 0, 0, 0, 9, 0}); !errors.Is(err, ErrRegistryUnavailable) {
+		t.Errorf("Decode() with an unavailable registry returned %v", err)
+	}
+	if _, err := NewCodec(registry, TopicNameStrategy{}).Decode("orders", false, []byte{0, 0, 0, 0, 9, 0}); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("Decode() without WithAwaitSchema returned %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxBatchErrorsShown is the number of the errors of a BatchError which its message lists.
+const maxBatchErrorsShown = 10
+
+// IndexedError is the error of the item at Index of a batch.
+type IndexedError struct {
+	Index int
+	Err   error
+}
+
+func (e IndexedError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e IndexedError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is the error of the operations on a batch of items of which some failed, e.g.
+// WarmUp: the operations carry on with the other items and report the errors of the items which
+// failed, by their index in the batch, in the order of the indexes. errors.Is and errors.As test
+// the errors of the items. Its message lists the first errors and counts the others.
+type BatchError struct {
+	// Size is the number of items of the batch, 0 if it is unknown.
+	Size   int
+	Errors []IndexedError
+	// Omitted is the number of items which failed without their error in Errors, e.g. of a long
+	// batch which keeps the first errors only.
+	Omitted int
+}
+
+// NewBatchError returns an empty BatchError of a batch of size items, see Add and Err.
+func NewBatchError(size int) *BatchError {
+	return &BatchError{Size: size}
+}
+
+// Add adds the error of the item at the index, a nil error is skipped.
+func (e *BatchError) Add(index int, err error) {
+	if err != nil {
+		e.Errors = append(e.Errors, IndexedError{index, err})
+	}
+}
+
+// Err returns the BatchError with its errors sorted by index, or nil if no item failed, to
+// return it as the error of the batch.
+func (e *BatchError) Err() error {
+	if e == nil || len(e.Errors) == 0 && e.Omitted == 0 {
+		return nil
+	}
+	sort.SliceStable(e.Errors, func(i, j int) bool { return e.Errors[i].Index < e.Errors[j].Index })
+	return e
+}
+
+// FirstError returns the error of the item with the lowest index, nil if there is none.
+func (e *BatchError) FirstError() error {
+	var first *IndexedError
+	for i := range e.Errors {
+		if first == nil || e.Errors[i].Index < first.Index {
+			first = &e.Errors[i]
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return first.Err
+}
+
+func (e *BatchError) Error() string {
+
+	var b strings.Builder
+	failed := len(e.Errors) + e.Omitted
+	if e.Size > 0 {
+		fmt.Fprintf(&b, "%d of %d items failed", failed, e.Size)
+	} else {
+		fmt.Fprintf(&b, "%d items failed", failed)
+	}
+	shown := min(len(e.Errors), maxBatchErrorsShown)
+	for _, err := range e.Errors[:shown] {
+		b.WriteString("; ")
+		b.WriteString(err.Error())
+	}
+	if more := failed - shown; more > 0 {
+		fmt.Fprintf(&b, "; and %d more", more)
+	}
+	if e.Omitted > 0 {
+		fmt.Fprintf(&b, " (the errors of %d items are omitted)", e.Omitted)
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the items, for errors.Is and errors.As.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// syncBatchError is a BatchError to which several goroutines add errors.
+type syncBatchError struct {
+	mu sync.Mutex
+	BatchError
+}
+
+func (e *syncBatchError) Add(index int, err error) {
+	e.mu.Lock()
+	e.BatchError.Add(index, err)
+	e.mu.Unlock()
+}
@@ -0,0 +1,72 @@
+package kafkaavro
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBatchError(t *testing.T) {
+
+	batch := NewBatchError(3)
+	batch.Add(0, nil)
+	if err := batch.Err(); err != nil || batch.FirstError() != nil {
+		t.Errorf("Err() of a batch without errors returned %v", err)
+	}
+
+	// the errors are sorted by index and errors.Is and As test them
+	decodeErr := &DecodeError{Size: 1, Err: ErrPayloadTooShort}
+	batch.Add(2, fmt.Errorf("failed to warm up subject c-value: %w", ErrSchemaNotFound))
+	batch.Add(1, decodeErr)
+	err := batch.Err()
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Errors[0].Index != 1 || batchErr.Errors[1].Index != 2 {
+		t.Fatalf("Err() returned %#v", err)
+	}
+	var asDecodeErr *DecodeError
+	if !errors.Is(err, ErrSchemaNotFound) || !errors.Is(err, ErrPayloadTooShort) || !errors.As(err, &asDecodeErr) || asDecodeErr != decodeErr {
+		t.Errorf("errors.Is and As do not find the errors of the items of %v", err)
+	}
+	if errors.Is(err, ErrUnknownMagicByte) {
+		t.Errorf("errors.Is() found an error which is not in %v", err)
+	}
+	if batch.FirstError() != decodeErr {
+		t.Errorf("FirstError() returned %v", batch.FirstError())
+	}
+	if want := "2 of 3 items failed; item 1: failed to decode 1 bytes: payload too short; item 2: failed to warm up subject c-value: " + ErrSchemaNotFound.Error(); err.Error() != want {
+		t.Errorf("Error() returned %q, want %q", err, want)
+	}
+}
+
+func TestBatchErrorTruncated(t *testing.T) {
+
+	batch := &BatchError{}
+	for i := 499; i >= 0; i-- {
+		if i%2 == 0 {
+			batch.Add(i, fmt.Errorf("failure %d", i))
+		}
+	}
+	err := batch.Err()
+	want := "250 items failed; item 0: failure 0; item 2: failure 2; item 4: failure 4; item 6: failure 6; item 8: failure 8; " +
+		"item 10: failure 10; item 12: failure 12; item 14: failure 14; item 16: failure 16; item 18: failure 18; and 240 more"
+	if err.Error() != want {
+		t.Errorf("Error() returned %q, want %q", err, want)
+	}
+	if len(batch.Unwrap()) != 250 || batch.FirstError().Error() != "failure 0" {
+		t.Errorf("the BatchError has %d errors, the first %v", len(batch.Unwrap()), batch.FirstError())
+	}
+}
+
+func TestBatchErrorOmitted(t *testing.T) {
+
+	batch := NewBatchError(1000)
+	batch.Add(3, errors.New("failure 3"))
+	batch.Omitted = 149
+	want := "150 of 1000 items failed; item 3: failure 3; and 149 more (the errors of 149 items are omitted)"
+	if err := batch.Err(); err == nil || err.Error() != want {
+		t.Errorf("Error() returned %q, want %q", err, want)
+	}
+	if err := (&BatchError{Omitted: 1}).Err(); err == nil {
+		t.Error("Err() of a batch with an omitted error returned nil")
+	}
+}
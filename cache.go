@@ -0,0 +1,104 @@
+package kafkaavro
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// copyOnWriteMap is a map for caches which are read on every message and rarely updated:
+// lookups are lock free and do not allocate, every update copies the map.
+type copyOnWriteMap[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries atomic.Pointer[map[K]V]
+}
+
+func (m *copyOnWriteMap[K, V]) get(key K) (value V, found bool) {
+	if entries := m.entries.Load(); entries != nil {
+		value, found = (*entries)[key]
+	}
+	return
+}
+
+func (m *copyOnWriteMap[K, V]) len() int {
+	if entries := m.entries.Load(); entries != nil {
+		return len(*entries)
+	}
+	return 0
+}
+
+func (m *copyOnWriteMap[K, V]) each(f func(K, V)) {
+	if entries := m.entries.Load(); entries != nil {
+		for k, v := range *entries {
+			f(k, v)
+		}
+	}
+}
+
+func (m *copyOnWriteMap[K, V]) put(key K, value V) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, value)
+}
+
+// add puts the value unless the key is present already, and returns true if it did.
+func (m *copyOnWriteMap[K, V]) add(key K, value V) bool {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.get(key); found {
+		return false
+	}
+	m.store(key, value)
+	return true
+}
+
+// delete removes the key, and returns true if it was present.
+func (m *copyOnWriteMap[K, V]) delete(key K) bool {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.entries.Load()
+	if current == nil {
+		return false
+	}
+	if _, found := (*current)[key]; !found {
+		return false
+	}
+	entries := make(map[K]V, len(*current)-1)
+	for k, v := range *current {
+		if k != key {
+			entries[k] = v
+		}
+	}
+	m.entries.Store(&entries)
+	return true
+}
+
+// clear removes all the keys.
+func (m *copyOnWriteMap[K, V]) clear() {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries.Store(nil)
+}
+
+// store copies the entries with the value, the caller holds the lock.
+func (m *copyOnWriteMap[K, V]) store(key K, value V) {
+
+	var entries map[K]V
+	if current := m.entries.Load(); current != nil {
+		entries = make(map[K]V, len(*current)+1)
+		for k, v := range *current {
+			entries[k] = v
+		}
+	} else {
+		entries = make(map[K]V, 1)
+	}
+	entries[key] = value
+	m.entries.Store(&entries)
+}
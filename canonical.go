@@ -0,0 +1,226 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// canonicalAttributes are the attributes which the Parsing Canonical Form keeps, in its order.
+var canonicalAttributes = []string{"name", "type", "fields", "symbols", "items", "values", "size"}
+
+// CanonicalForm returns the Parsing Canonical Form of the schema, as defined by the avro
+// specification: the names are fully qualified, the attributes which do not matter for reading
+// the data (doc, aliases, defaults, logical types, ...) are removed, the remaining attributes
+// are ordered and the whitespace is removed. Two schemas with the same canonical form read and
+// write the same data. As the canonical form has no namespaces, a type of the null namespace which
+// is defined in a namespace reads as a type of that namespace, as in the specification.
+func CanonicalForm(schema AvroSchema) (canonical string, err error) {
+
+	decoder := json.NewDecoder(strings.NewReader(schema))
+	decoder.UseNumber()
+	var document interface{}
+	if err = decoder.Decode(&document); err != nil {
+		return "", fmt.Errorf("invalid schema: %w", err)
+	}
+
+	var b bytes.Buffer
+	c := &canonicalizer{b: &b, named: make(map[string]bool)}
+	if err = c.write(document, ""); err != nil {
+		return "", fmt.Errorf("invalid schema: %w", err)
+	}
+	return b.String(), nil
+}
+
+type canonicalizer struct {
+	b     *bytes.Buffer
+	named map[string]bool
+}
+
+func (c *canonicalizer) write(schema interface{}, namespace string) error {
+
+	switch s := schema.(type) {
+
+	case string:
+		if !avroschema.IsPrimitive(s) {
+			s = c.reference(s, namespace)
+		}
+		c.writeString(s)
+		return nil
+
+	case []interface{}:
+		c.b.WriteByte('[')
+		for i, branch := range s {
+			if i > 0 {
+				c.b.WriteByte(',')
+			}
+			if err := c.write(branch, namespace); err != nil {
+				return err
+			}
+		}
+		c.b.WriteByte(']')
+		return nil
+
+	case map[string]interface{}:
+		return c.writeComplex(s, namespace)
+	}
+	return fmt.Errorf("%v is not a schema", schema)
+}
+
+// reference returns the full name of a reference to a named type.
+func (c *canonicalizer) reference(name string, namespace string) string {
+	if full := avroschema.FullName(name, namespace); c.named[full] {
+		return full
+	}
+	return name
+}
+
+func (c *canonicalizer) writeComplex(s map[string]interface{}, namespace string) error {
+
+	typ, isString := s["type"].(string)
+	if !isString {
+		// {"type": {...}} or {"type": [...]}
+		return c.write(s["type"], namespace)
+	}
+	if avroschema.IsPrimitive(typ) {
+		// {"type":"int","logicalType":...} is "int"
+		c.writeString(typ)
+		return nil
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := s["name"].(string)
+		if name == "" {
+			return fmt.Errorf("a %v without a name", typ)
+		}
+		if ns, found := s["namespace"].(string); found && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		name = avroschema.FullName(name, namespace)
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			namespace = name[:i]
+		} else {
+			namespace = ""
+		}
+		c.named[name] = true
+		s["name"] = name
+		if typ == "error" {
+			s["type"] = "record"
+		}
+	case "array", "map":
+	default:
+		// a reference to a named type
+		c.writeString(c.reference(typ, namespace))
+		return nil
+	}
+
+	c.b.WriteByte('{')
+	first := true
+	for _, attribute := range canonicalAttributes {
+
+		value, found := s[attribute]
+		if !found {
+			continue
+		}
+		if !first {
+			c.b.WriteByte(',')
+		}
+		first = false
+		c.writeString(attribute)
+		c.b.WriteByte(':')
+
+		var err error
+		switch attribute {
+		case "name", "type":
+			c.writeString(value.(string))
+		case "fields":
+			err = c.writeFields(value, namespace)
+		case "symbols":
+			err = c.writeSymbols(value)
+		case "items", "values":
+			err = c.write(value, namespace)
+		case "size":
+			err = c.writeInteger(value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	c.b.WriteByte('}')
+	return nil
+}
+
+func (c *canonicalizer) writeFields(value interface{}, namespace string) error {
+
+	fields, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("fields %v is not an array", value)
+	}
+	c.b.WriteByte('[')
+	for i, f := range fields {
+		attributes, ok := f.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field %v is not an object", f)
+		}
+		name, _ := attributes["name"].(string)
+		if i > 0 {
+			c.b.WriteByte(',')
+		}
+		c.b.WriteString(`{"name":`)
+		c.writeString(name)
+		c.b.WriteString(`,"type":`)
+		if err := c.write(attributes["type"], namespace); err != nil {
+			return fmt.Errorf("field %v: %w", name, err)
+		}
+		c.b.WriteByte('}')
+	}
+	c.b.WriteByte(']')
+	return nil
+}
+
+func (c *canonicalizer) writeSymbols(value interface{}) error {
+
+	symbols, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("symbols %v is not an array", value)
+	}
+	c.b.WriteByte('[')
+	for i, symbol := range symbols {
+		s, ok := symbol.(string)
+		if !ok {
+			return fmt.Errorf("symbol %v is not a string", symbol)
+		}
+		if i > 0 {
+			c.b.WriteByte(',')
+		}
+		c.writeString(s)
+	}
+	c.b.WriteByte(']')
+	return nil
+}
+
+func (c *canonicalizer) writeInteger(value interface{}) error {
+	number, ok := value.(json.Number)
+	if !ok {
+		return fmt.Errorf("size %v is not a number", value)
+	}
+	size, err := number.Int64()
+	if err != nil {
+		return fmt.Errorf("size %v is not an integer", value)
+	}
+	fmt.Fprint(c.b, size)
+	return nil
+}
+
+// writeString writes the JSON string with the characters as UTF-8, only the quote, the
+// backslash and the control characters are escaped.
+func (c *canonicalizer) writeString(s string) {
+	encoder := json.NewEncoder(c.b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	c.b.Truncate(c.b.Len() - 1) // the newline of Encode
+}
@@ -0,0 +1,123 @@
+package kafkaavro
+
+import "testing"
+
+func TestCanonicalForm(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			"nested namespaces",
+			// the enum e is defined in the namespace of b.i, not of a.r (goavro names it a.e)
+			`{"type":"record","name":"r","namespace":"a","fields":[
+				{"name":"x","type":{"type":"record","name":"b.i","fields":[{"name":"y","type":{"type":"enum","name":"e","symbols":["A"]}}]}},
+				{"name":"z","type":"b.e"}]}`,
+			`{"name":"a.r","type":"record","fields":[{"name":"x","type":{"name":"b.i","type":"record","fields":[{"name":"y","type":{"name":"b.e","type":"enum","symbols":["A"]}}]}},{"name":"z","type":"b.e"}]}`,
+		},
+		{
+			"namespace attributes of nested types",
+			`{"type":"record","name":"r","namespace":"a","fields":[
+				{"name":"x","type":{"type":"record","name":"i","namespace":"c","fields":[{"name":"y","type":{"type":"fixed","name":"f","size":4}},{"name":"z","type":"f"}]}},
+				{"name":"v","type":"c.f"}]}`,
+			`{"name":"a.r","type":"record","fields":[{"name":"x","type":{"name":"c.i","type":"record","fields":[{"name":"y","type":{"name":"c.f","type":"fixed","size":4}},{"name":"z","type":"c.f"}]}},{"name":"v","type":"c.f"}]}`,
+		},
+		{
+			"references to previously defined types",
+			`{"type":"record","name":"r","namespace":"a","fields":[
+				{"name":"x","type":{"type":"enum","name":"e","symbols":["A","B"]}},
+				{"name":"y","type":"e"},
+				{"name":"z","type":{"type":"array","items":"a.e"}},
+				{"name":"self","type":["null","r"]},
+				{"name":"m","type":{"type":"map","values":{"type":"e"}}}]}`,
+			`{"name":"a.r","type":"record","fields":[{"name":"x","type":{"name":"a.e","type":"enum","symbols":["A","B"]}},{"name":"y","type":"a.e"},{"name":"z","type":{"type":"array","items":"a.e"}},{"name":"self","type":["null","a.r"]},{"name":"m","type":{"type":"map","values":"a.e"}}]}`,
+		},
+		{
+			"a reference to the null namespace",
+			`{"type":"record","name":"r","fields":[
+				{"name":"x","type":{"type":"fixed","name":"f","size":2}},
+				{"name":"y","type":{"type":"record","name":"i","namespace":"n","fields":[{"name":"z","type":"f"}]}}]}`,
+			`{"name":"r","type":"record","fields":[{"name":"x","type":{"name":"f","type":"fixed","size":2}},{"name":"y","type":{"name":"n.i","type":"record","fields":[{"name":"z","type":"f"}]}}]}`,
+		},
+		{
+			"unions of named types",
+			`{"type":"record","name":"r","namespace":"a","fields":[
+				{"name":"x","type":["null",{"type":"record","name":"A","fields":[]},{"type":"enum","name":"B","namespace":"b","symbols":["S"]},{"type":"fixed","name":"C","size":1}]},
+				{"name":"y","type":["A","b.B","C","string"]}]}`,
+			`{"name":"a.r","type":"record","fields":[{"name":"x","type":["null",{"name":"a.A","type":"record","fields":[]},{"name":"b.B","type":"enum","symbols":["S"]},{"name":"a.C","type":"fixed","size":1}]},{"name":"y","type":["a.A","b.B","a.C","string"]}]}`,
+		},
+		{
+			"stripped and ordered attributes",
+			`{"fields":[{"type":{"symbols":["A"],"aliases":["E2"],"name":"E","type":"enum","default":"A","doc":"d"},"name":"e","default":"A","order":"ignore","doc":"d","aliases":["f"]}],
+				"doc":"a record","aliases":["q"],"namespace":"n","custom":{"x":1},"type":"record","name":"r"}`,
+			`{"name":"n.r","type":"record","fields":[{"name":"e","type":{"name":"n.E","type":"enum","symbols":["A"]}}]}`,
+		},
+		{
+			"primitives and logical types",
+			`["null",{"type":"int"},{"type":{"type":"long"}},{"type":"long","logicalType":"timestamp-millis"},
+				{"type":"bytes","logicalType":"decimal","precision":9,"scale":2},{"type":"fixed","name":"d","size":8,"logicalType":"decimal","precision":18}]`,
+			`["null","int","long","long","bytes",{"name":"d","type":"fixed","size":8}]`,
+		},
+		{
+			"errors are records",
+			`{"type":"error","name":"failure","fields":[{"name":"message","type":"string"}]}`,
+			`{"name":"failure","type":"record","fields":[{"name":"message","type":"string"}]}`,
+		},
+		{
+			"strings",
+			`{"type":"enum","name":"foo","symbols":["é","a\"b","<&>"]}`,
+			`{"name":"foo","type":"enum","symbols":["é","a\"b","<&>"]}`,
+		},
+		{
+			"whitespace",
+			"{ \"type\" : \"map\",\n\t\"values\" : [ \"null\" , \"string\" ] }",
+			`{"type":"map","values":["null","string"]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CanonicalForm(test.schema)
+			if err != nil || got != test.want {
+				t.Errorf("CanonicalForm() returned\n%v, %v, want\n%v", got, err, test.want)
+			}
+			// the canonical form is its own canonical form
+			if again, err := CanonicalForm(got); err != nil || again != got {
+				t.Errorf("CanonicalForm(%v) returned %v, %v", got, again, err)
+			}
+		})
+	}
+}
+
+// TestCanonicalFormNullNamespace is the case of the specification in which the canonical form is
+// ambiguous: it has no namespaces, so a type of the null namespace defined in a namespace reads
+// as a type of that namespace.
+func TestCanonicalFormNullNamespace(t *testing.T) {
+
+	schema := `{"type":"record","name":"r","namespace":"a","fields":[{"name":"w","type":{"type":"fixed","name":"g","namespace":"","size":1}},{"name":"v","type":"g"}]}`
+	want := `{"name":"a.r","type":"record","fields":[{"name":"w","type":{"name":"g","type":"fixed","size":1}},{"name":"v","type":"g"}]}`
+
+	if got, err := CanonicalForm(schema); err != nil || got != want {
+		t.Errorf("CanonicalForm() returned %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestCanonicalFormErrors(t *testing.T) {
+
+	for _, schema := range []string{
+		`{"type":`,
+		`42`,
+		`{"type":"record","fields":[]}`,
+		`{"type":"enum","name":"e","symbols":"A"}`,
+		`{"type":"enum","name":"e","symbols":[1]}`,
+		`{"type":"fixed","name":"f","size":1.5}`,
+		`{"type":"record","name":"r","fields":{}}`,
+		`{"type":"record","name":"r","fields":[{"name":"x","type":true}]}`,
+	} {
+		if canonical, err := CanonicalForm(schema); err == nil {
+			t.Errorf("CanonicalForm(%v) returned %v, want an error", schema, canonical)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package kafkaavro
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithSchemaChurnAlert counts the distinct schema ids of the values decoded of every topic within
+// the sliding window, see CacheStats.SchemaChurn, and calls fn with the topic and those ids (sorted)
+// when a schema id enters the window of a topic which then holds more than threshold ids, e.g. a
+// deploy loop which registers a new schema on every start. fn may be nil to only count the ids,
+// it is called synchronously from the decode, and a panic of it is recovered and logged.
+//
+// The messages of a schema id which is already in the window only update its last use, without
+// locks. The keys are not counted.
+func WithSchemaChurnAlert(window time.Duration, threshold int, fn func(topic string, ids []int)) Option {
+	return func(c *Codec) {
+		c.churn = &schemaChurn{window: int64(window), threshold: threshold, alert: fn}
+	}
+}
+
+// schemaChurn tracks the schema ids of the topics within the window.
+type schemaChurn struct {
+	window    int64
+	threshold int
+	alert     func(topic string, ids []int)
+	topics    copyOnWriteMap[string, *topicChurn]
+}
+
+// topicChurn holds the last use of the schema ids of a topic, in unix nanoseconds.
+type topicChurn struct {
+	mu       sync.Mutex
+	lastSeen copyOnWriteMap[SchemaID, *atomic.Int64]
+}
+
+// seen records the use of the schema id by the topic, it returns the schema ids of the window if
+// they exceed the threshold after the schema id entered the window.
+func (s *schemaChurn) seen(topic string, schemaID SchemaID, now time.Time) (alert []int) {
+
+	t, found := s.topics.get(topic)
+	if !found {
+		s.topics.add(topic, &topicChurn{})
+		t, _ = s.topics.get(topic)
+	}
+
+	nanos := now.UnixNano()
+	last, found := t.lastSeen.get(schemaID)
+	if found && nanos-last.Swap(nanos) <= s.window {
+		return nil
+	}
+
+	// the schema id is new or returns to the window: drop the ids which left it
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !found {
+		if _, found = t.lastSeen.get(schemaID); found {
+			// another message of the new id added it first
+			return nil
+		}
+		last = &atomic.Int64{}
+		last.Store(nanos)
+		t.lastSeen.put(schemaID, last)
+	}
+	var expired []SchemaID
+	ids := t.window(nanos, s.window, &expired)
+	for _, id := range expired {
+		// unless a message of the id updated it meanwhile
+		if last, found := t.lastSeen.get(id); found && nanos-last.Load() > s.window {
+			t.lastSeen.delete(id)
+		}
+	}
+	if len(ids) > s.threshold {
+		return ids
+	}
+	return nil
+}
+
+// window returns the sorted schema ids used within the window before nanos, and appends the
+// other ones to expired if it is not nil.
+func (t *topicChurn) window(nanos int64, window int64, expired *[]SchemaID) (ids []int) {
+	t.lastSeen.each(func(id SchemaID, last *atomic.Int64) {
+		if nanos-last.Load() <= window {
+			ids = append(ids, id)
+		} else if expired != nil {
+			*expired = append(*expired, id)
+		}
+	})
+	sort.Ints(ids)
+	return
+}
+
+// counts returns the number of schema ids of every topic within the window before now.
+func (s *schemaChurn) counts(now time.Time, counts map[string]int) {
+	s.topics.each(func(topic string, t *topicChurn) {
+		if ids := t.window(now.UnixNano(), s.window, nil); len(ids) > 0 {
+			counts[topic] += len(ids)
+		}
+	})
+}
+
+// observeChurn records the schema id of a value of the topic, and calls the alert of
+// WithSchemaChurnAlert when the topic has too many.
+func (c *Codec) observeChurn(topic string, schemaID SchemaID) {
+
+	ids := c.churn.seen(topic, schemaID, c.clock.Now())
+	if ids == nil || c.churn.alert == nil {
+		return
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil && c.logger != nil {
+			c.logger.Warn("hook panicked", "hook", "WithSchemaChurnAlert", "panic", recovered)
+		}
+	}()
+
+	c.churn.alert(topic, ids)
+}
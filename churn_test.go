@@ -0,0 +1,89 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// churnMessages registers n versions of the value schema of the topic and returns a message of each.
+func churnMessages(t *testing.T, registry *mockregistry.Registry, topic string, n int) (ids []int, messages [][]byte) {
+
+	t.Helper()
+
+	codec := NewCodec(registry, TopicNameStrategy{})
+	for i := 0; i < n; i++ {
+		id := registry.Register(topic+"-value", fmt.Sprintf(`{"type":"record","name":"v%d","fields":[{"name":"f1","type":"string"}]}`, i))
+		data, err := codec.EncodeWithSchemaID(id, map[string]interface{}{"f1": "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids, messages = append(ids, id), append(messages, data)
+	}
+	return
+}
+
+func TestSchemaChurnAlert(t *testing.T) {
+
+	registry := mockregistry.New()
+	ids, messages := churnMessages(t, registry, "orders", 4)
+	_, payments := churnMessages(t, registry, "payments", 1)
+
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+	var alerts [][]int
+	codec := NewCodec(registry, TopicNameStrategy{}, WithClock(clock), WithSchemaChurnAlert(time.Hour, 2, func(topic string, ids []int) {
+		if topic != "orders" {
+			t.Errorf("alert of topic %v", topic)
+		}
+		alerts = append(alerts, ids)
+	}))
+
+	decode := func(topic string, data []byte) {
+		t.Helper()
+		if _, err := codec.Decode(topic, false, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the schemas of the window exceed the threshold when the third id enters it
+	decode("orders", messages[0])
+	decode("orders", messages[1])
+	decode("orders", messages[0])
+	decode("payments", payments[0])
+	if len(alerts) != 0 {
+		t.Errorf("alerts %v before the threshold", alerts)
+	}
+	clock.Advance(30 * time.Minute)
+	decode("orders", messages[2])
+	decode("orders", messages[2])
+	if want := [][]int{ids[:3]}; !reflect.DeepEqual(alerts, want) {
+		t.Errorf("alerts %v, want %v", alerts, want)
+	}
+	if stats := codec.CacheStats(); !reflect.DeepEqual(stats.SchemaChurn, map[string]int{"orders": 3, "payments": 1}) {
+		t.Errorf("CacheStats().SchemaChurn = %v", stats.SchemaChurn)
+	}
+
+	// the first two ids leave the window, the last two are not too many
+	clock.Advance(45 * time.Minute)
+	decode("orders", messages[3])
+	if want := [][]int{ids[:3]}; !reflect.DeepEqual(alerts, want) {
+		t.Errorf("alerts %v, want %v", alerts, want)
+	}
+	if stats := codec.CacheStats(); !reflect.DeepEqual(stats.SchemaChurn, map[string]int{"orders": 2}) {
+		t.Errorf("CacheStats().SchemaChurn = %v after the window", stats.SchemaChurn)
+	}
+
+	// an id which returns to the window counts again
+	decode("orders", messages[0])
+	if want := [][]int{ids[:3], {ids[0], ids[2], ids[3]}}; !reflect.DeepEqual(alerts, want) {
+		t.Errorf("alerts %v, want %v", alerts, want)
+	}
+
+	if stats := NewCodec(registry, TopicNameStrategy{}).CacheStats(); stats.SchemaChurn != nil {
+		t.Errorf("CacheStats().SchemaChurn = %v without WithSchemaChurnAlert", stats.SchemaChurn)
+	}
+}
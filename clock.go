@@ -0,0 +1,30 @@
+package kafkaavro
+
+import "time"
+
+// Clock is the source of time of a Codec, it measures the latency of the schema registry requests.
+// Tests can pass a clocktest.Clock to control the time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the time package, the default.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock replaces the real clock of the Codec.
+func WithClock(clock Clock) Option {
+	return func(c *Codec) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package kafkaavro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// advancingRegistry advances the clock by the latency in every request.
+type advancingRegistry struct {
+	*fakeRegistry
+	clock   *clocktest.Clock
+	latency time.Duration
+}
+
+func (r *advancingRegistry) GetSchemaByID(id int) (string, error) {
+	r.clock.Advance(r.latency)
+	return r.fakeRegistry.GetSchemaByID(id)
+}
+
+func (r *advancingRegistry) GetLatestSchema(subject string) (schemaregistry.Schema, error) {
+	r.clock.Advance(r.latency)
+	return r.fakeRegistry.GetLatestSchema(subject)
+}
+
+func TestClockMeasuresRegistryLatency(t *testing.T) {
+
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+	registry := &advancingRegistry{newFakeRegistry(), clock, 25 * time.Millisecond}
+
+	var latencies []time.Duration
+	codec := NewCodec(registry, TopicNameStrategy{}, WithClock(clock), WithHooks(Hooks{
+		OnSchemaFetched: func(e HookEvent) { latencies = append(latencies, e.Latency) },
+	}))
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.latency = time.Second
+	codec.InvalidateSubject("test-value")
+	if _, err = codec.Encode("test", false, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Decode("test", false, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []time.Duration{25 * time.Millisecond, time.Second}; len(latencies) != 2 || latencies[0] != want[0] || latencies[1] != want[1] {
+		t.Errorf("the registry requests took %v, want %v", latencies, want)
+	}
+	if got := time.Duration(codec.lastRegistryRequest.latency.Load()); got != time.Second {
+		t.Errorf("the last registry request took %v, want 1s", got)
+	}
+}
@@ -0,0 +1,97 @@
+// Package clocktest is a clock for tests of time dependent code, which only moves when the test
+// advances it:
+//
+//	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+//	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithClock(clock))
+//	...
+//	clock.Advance(time.Minute)
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a kafkaavro.Clock which is moved by Advance, it is safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+// New creates a clock at the time.
+func New(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel which receives the time once the clock is advanced by d, or right away
+// if d is not positive.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{c.now.Add(d), ch})
+	c.changed.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward and fires the channels of After which are due, in the order
+// of their deadlines.
+func (c *Clock) Advance(d time.Duration) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].deadline.Before(c.waiters[j].deadline) })
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.c <- c.now
+		}
+	}
+	c.waiters = pending
+	c.changed.Broadcast()
+}
+
+// Waiters returns the number of channels of After which did not fire yet.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until n channels of After did not fire yet, e.g. until the goroutines under
+// test wait for the clock before it is advanced.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
@@ -0,0 +1,73 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+)
+
+var _ kafkaavro.Clock = (*Clock)(nil)
+
+var start = time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+
+func fired(ch <-chan time.Time) (at time.Time, ok bool) {
+	select {
+	case at = <-ch:
+		return at, true
+	default:
+		return at, false
+	}
+}
+
+func TestAdvance(t *testing.T) {
+
+	clock := New(start)
+
+	now := clock.After(0)
+	later := clock.After(2 * time.Second)
+	soon := clock.After(time.Second)
+
+	if at, ok := fired(now); !ok || !at.Equal(start) {
+		t.Errorf("After(0) returned %v, %v, want it to fire at the start", at, ok)
+	}
+	if clock.Waiters() != 2 {
+		t.Errorf("Waiters() returned %d, want 2", clock.Waiters())
+	}
+
+	clock.Advance(time.Second)
+	if at, ok := fired(soon); !ok || !at.Equal(start.Add(time.Second)) {
+		t.Errorf("After(1s) returned %v, %v after 1s", at, ok)
+	}
+	if _, ok := fired(later); ok {
+		t.Error("After(2s) fired after 1s")
+	}
+
+	clock.Advance(time.Hour)
+	if at, ok := fired(later); !ok || !at.Equal(start.Add(time.Hour+time.Second)) {
+		t.Errorf("After(2s) returned %v, %v after 1h", at, ok)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(time.Hour + time.Second)) {
+		t.Errorf("Now() returned %v", got)
+	}
+	if clock.Waiters() != 0 {
+		t.Errorf("Waiters() returned %d, want 0", clock.Waiters())
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+
+	clock := New(start)
+
+	done := make(chan time.Time)
+	go func() {
+		done <- <-clock.After(time.Minute)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	if at := <-done; !at.Equal(start.Add(time.Minute)) {
+		t.Errorf("the goroutine woke up at %v", at)
+	}
+}
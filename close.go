@@ -0,0 +1,27 @@
+package kafkaavro
+
+import "fmt"
+
+// Close releases the resources of the Codec: the idle connections of the registry clients which
+// have a CloseIdleConnections method, like the schemaregistry.Client, are closed. The Decode and
+// Encode methods of a closed Codec fail with ErrClosed, closing it again does nothing.
+func (c *Codec) Close() error {
+
+	if c.closed.Swap(true) {
+		return nil
+	}
+	c.eachRegistry(func(codec *Codec) {
+		if client, ok := codec.client.(interface{ CloseIdleConnections() }); ok {
+			client.CloseIdleConnections()
+		}
+	})
+	return nil
+}
+
+// checkOpen returns ErrClosed once the Codec is closed.
+func (c *Codec) checkOpen() error {
+	if c.closed.Load() {
+		return fmt.Errorf("%w: the codec is closed", ErrClosed)
+	}
+	return nil
+}
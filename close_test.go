@@ -0,0 +1,62 @@
+package kafkaavro
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// pooledRegistry counts the calls of CloseIdleConnections.
+type pooledRegistry struct {
+	*mockregistry.Registry
+	closed int
+}
+
+func (r *pooledRegistry) CloseIdleConnections() {
+	r.closed++
+}
+
+func TestCodecClose(t *testing.T) {
+
+	primary := &pooledRegistry{Registry: mockregistry.New()}
+	primary.Register("orders-value", `"long"`)
+	acme := &pooledRegistry{Registry: mockregistry.New()}
+	acme.Register("acme.orders-value", `"string"`)
+
+	codec := NewCodec(primary, TopicNameStrategy{}, WithRegistryPrefixes(map[string]RegistryClient{"acme.": acme}))
+	data, err := codec.Encode("orders", false, int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Encode("acme.orders", false, "A-1"); err != nil {
+		t.Fatal(err)
+	}
+	var scratch DecodeScratch
+	if _, err = codec.DecodeReuse("orders", false, data, &scratch); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = codec.Close(); err != nil {
+			t.Errorf("Close() returned %v", err)
+		}
+	}
+	if primary.closed != 1 || acme.closed != 1 {
+		t.Errorf("the idle connections were closed %d and %d times, want once per registry", primary.closed, acme.closed)
+	}
+
+	// the cached schemas are not used either
+	if _, err = codec.Decode("orders", false, data); !errors.Is(err, ErrClosed) {
+		t.Errorf("Decode() after Close() returned %v", err)
+	}
+	if _, err = codec.DecodeReuse("orders", false, data, &scratch); !errors.Is(err, ErrClosed) {
+		t.Errorf("DecodeReuse() with the codec of the scratch after Close() returned %v", err)
+	}
+	if _, err = codec.Encode("orders", false, int64(1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Encode() after Close() returned %v", err)
+	}
+	if _, err = codec.Encode("acme.other", false, "A-2"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Encode() to a registry after Close() returned %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// offsetCommitter is the part of the kafka.Consumer used to commit offsets.
+type offsetCommitter interface {
+	StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	Commit() ([]kafka.TopicPartition, error)
+}
+
+// offsetTracker stores the offset of every handled message and commits the stored offsets
+// every interval messages and when the consumer stops.
+type offsetTracker struct {
+	consumer  offsetCommitter
+	interval  int
+	stored    int
+	committed map[string]kafka.TopicPartition
+}
+
+func newOffsetTracker(consumer offsetCommitter, interval int) *offsetTracker {
+	if interval < 1 {
+		interval = 1
+	}
+	return &offsetTracker{consumer: consumer, interval: interval, committed: make(map[string]kafka.TopicPartition)}
+}
+
+func (o *offsetTracker) handled(m *kafka.Message) (err error) {
+
+	if _, err = o.consumer.StoreMessage(m); err != nil {
+		return
+	}
+
+	o.stored++
+	if o.stored >= o.interval {
+		err = o.commit()
+	}
+	return
+}
+
+func (o *offsetTracker) commit() (err error) {
+
+	if o.stored == 0 {
+		return
+	}
+
+	committed, err := o.consumer.Commit()
+	if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrNoOffset {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %v", err)
+	}
+
+	for _, tp := range committed {
+		if tp.Error == nil && tp.Offset >= 0 {
+			o.committed[fmt.Sprintf("%v [%d]", *tp.Topic, tp.Partition)] = tp
+		}
+	}
+	o.stored = 0
+	return
+}
+
+// summary prints the committed position of every partition.
+func (o *offsetTracker) summary(w io.Writer) {
+
+	if len(o.committed) == 0 {
+		fmt.Fprintln(w, "No offsets committed")
+		return
+	}
+
+	keys := make([]string, 0, len(o.committed))
+	for key := range o.committed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "Committed positions:")
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %v: %v\n", key, o.committed[key].Offset)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+type fakeCommitter struct {
+	stored  map[int32]kafka.Offset
+	commits int
+}
+
+func (f *fakeCommitter) StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	f.stored[m.TopicPartition.Partition] = m.TopicPartition.Offset + 1
+	return nil, nil
+}
+
+func (f *fakeCommitter) Commit() (committed []kafka.TopicPartition, err error) {
+	f.commits++
+	topic := "orders"
+	for partition, offset := range f.stored {
+		committed = append(committed, kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset})
+	}
+	return
+}
+
+func testMessage(partition int32, offset kafka.Offset) *kafka.Message {
+	topic := "orders"
+	return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}}
+}
+
+func TestOffsetTrackerCommitsEveryInterval(t *testing.T) {
+
+	committer := &fakeCommitter{stored: make(map[int32]kafka.Offset)}
+	tracker := newOffsetTracker(committer, 3)
+
+	for offset := kafka.Offset(0); offset < 7; offset++ {
+		if err := tracker.handled(testMessage(int32(offset%2), offset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if committer.commits != 2 {
+		t.Errorf("committed %d times after 7 messages, want 2", committer.commits)
+	}
+
+	if err := tracker.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if committer.commits != 3 {
+		t.Errorf("committed %d times after the final commit, want 3", committer.commits)
+	}
+
+	if err := tracker.commit(); err != nil || committer.commits != 3 {
+		t.Errorf("commit without new messages committed again")
+	}
+
+	var summary bytes.Buffer
+	tracker.summary(&summary)
+	want := "Committed positions:\n  orders [0]: 7\n  orders [1]: 6\n"
+	if summary.String() != want {
+		t.Errorf("summary is %q, want %q", summary.String(), want)
+	}
+}
+
+func TestOffsetTrackerSummaryWithoutCommits(t *testing.T) {
+
+	var summary bytes.Buffer
+	newOffsetTracker(&fakeCommitter{}, 10).summary(&summary)
+	if summary.String() != "No offsets committed\n" {
+		t.Errorf("summary is %q", summary.String())
+	}
+}
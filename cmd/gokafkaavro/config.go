@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFileName    = ".gokafkaavro.yaml"
+	configFileEnvName = "GOKAFKAAVRO_CONFIG"
+	profileEnvName    = "GOKAFKAAVRO_PROFILE"
+	envPrefix         = "GOKAFKAAVRO_"
+	kafkaConfigKey    = "kafka-config"
+)
+
+// profileKeys are the flags which can also be set with a GOKAFKAAVRO_ environment variable
+// or in a profile of the config file, in the order in which they are printed.
+var profileKeys = []string{
+	"brokers",
+	"security-protocol",
+	"sasl-mechanism",
+	"sasl-username",
+	"sasl-password",
+	"ssl-ca-location",
+	kafkaConfigKey,
+	"registry",
+	"sr-basic-auth",
+	"sr-api-key",
+	"sr-api-secret",
+	"sr-ca-cert",
+	"sr-skip-tls-verify",
+}
+
+const configUsage = `Usage: gokafkaavro config <command> [flags]
+
+Commands:
+  view       print the effective connection configuration, with the secrets redacted
+
+The config file (default ~/` + configFileName + `) contains named profiles:
+
+  default-profile: local
+  profiles:
+    local:
+      brokers: localhost:9092
+      registry: http://localhost:8081
+    staging:
+      brokers: kafka-1.staging:9093,kafka-2.staging:9093
+      security-protocol: sasl_ssl
+      sasl-mechanism: SCRAM-SHA-512
+      sasl-username: gokafkaavro
+      kafka-config:
+        client.id: gokafkaavro
+      registry: https://registry.staging
+      sr-api-key: gokafkaavro
+
+A flag on the command line takes precedence over its GOKAFKAAVRO_ environment variable
+(e.g. GOKAFKAAVRO_SASL_PASSWORD), which takes precedence over the selected profile.
+`
+
+// configFlags select the config file and the profile in it.
+type configFlags struct {
+	path    string
+	profile string
+
+	// sources records where every applied value came from
+	sources map[string]string
+}
+
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "", "config file with named profiles (default ~/"+configFileName+", or set "+configFileEnvName+")")
+	fs.StringVar(&c.profile, "profile", "", "profile of the config file to use (or set "+profileEnvName+", default the default-profile of the config file)")
+}
+
+type configFile struct {
+	defaultProfile string
+	profiles       map[string]profile
+}
+
+type profile struct {
+	values      map[string]string
+	kafkaConfig []string
+}
+
+// apply sets the flags which were not passed on the command line, first from the environment
+// and then from the selected profile of the config file.
+func (c *configFlags) apply(fs *flag.FlagSet) (err error) {
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	selected, profileName, err := c.selectProfile()
+	if err != nil {
+		return
+	}
+
+	c.sources = make(map[string]string)
+	for _, key := range profileKeys {
+
+		f := fs.Lookup(key)
+		if f == nil {
+			continue
+		}
+
+		if key == kafkaConfigKey {
+			// the properties of the profile come first, so that those on the command line win
+			properties := f.Value.(*stringsFlag)
+			if len(selected.kafkaConfig) > 0 {
+				*properties = append(append(stringsFlag{}, selected.kafkaConfig...), *properties...)
+			}
+			if explicit[key] {
+				c.sources[key] = "--" + key
+			} else if len(*properties) > 0 {
+				c.sources[key] = "profile " + profileName
+			}
+			continue
+		}
+
+		if explicit[key] {
+			c.sources[key] = "--" + key
+			continue
+		}
+
+		if value := os.Getenv(envName(key)); value != "" {
+			if err = fs.Set(key, value); err != nil {
+				return fmt.Errorf("invalid %v %q: %v", envName(key), value, err)
+			}
+			c.sources[key] = envName(key)
+			continue
+		}
+
+		if value, found := selected.values[key]; found {
+			if err = fs.Set(key, value); err != nil {
+				return fmt.Errorf("invalid %v %q in profile %v: %v", key, value, profileName, err)
+			}
+			c.sources[key] = "profile " + profileName
+		}
+	}
+	return
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// selectProfile loads the config file and returns the requested profile. Without a config
+// file and without a requested profile, an empty profile is returned.
+func (c *configFlags) selectProfile() (selected profile, profileName string, err error) {
+
+	path := c.path
+	if path == "" {
+		path = os.Getenv(configFileEnvName)
+	}
+	mustExist := path != ""
+	if path == "" {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return
+		}
+		path = filepath.Join(home, configFileName)
+	}
+
+	profileName = c.profile
+	if profileName == "" {
+		profileName = os.Getenv(profileEnvName)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !mustExist {
+		if profileName != "" {
+			return selected, profileName, fmt.Errorf("profile %v requested but there is no config file %v", profileName, path)
+		}
+		return selected, profileName, nil
+	}
+	if err != nil {
+		return
+	}
+
+	config, err := parseConfigFile(data)
+	if err != nil {
+		return selected, profileName, fmt.Errorf("invalid config file %v: %v", path, err)
+	}
+
+	if profileName == "" {
+		profileName = config.defaultProfile
+	}
+	if profileName == "" {
+		return
+	}
+
+	selected, found := config.profiles[profileName]
+	if !found {
+		names := make([]string, 0, len(config.profiles))
+		for name := range config.profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return selected, profileName, fmt.Errorf("profile %v not found in config file %v, available profiles: %v", profileName, path, strings.Join(names, ", "))
+	}
+	return
+}
+
+func parseConfigFile(data []byte) (config configFile, err error) {
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(data, &document); err != nil {
+		return
+	}
+	if len(document.Content) == 0 {
+		return
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return config, fmt.Errorf("line %d: expected a mapping with default-profile and profiles", root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+
+		case "default-profile":
+			if value.Kind != yaml.ScalarNode {
+				return config, fmt.Errorf("line %d: default-profile must be a profile name", value.Line)
+			}
+			config.defaultProfile = value.Value
+
+		case "profiles":
+			if value.Kind != yaml.MappingNode {
+				return config, fmt.Errorf("line %d: profiles must be a mapping of profile names to profiles", value.Line)
+			}
+			config.profiles = make(map[string]profile)
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name := value.Content[j].Value
+				if config.profiles[name], err = parseProfile(name, value.Content[j+1]); err != nil {
+					return
+				}
+			}
+
+		default:
+			return config, fmt.Errorf("line %d: unknown key %q, expected default-profile or profiles", key.Line, key.Value)
+		}
+	}
+
+	if config.defaultProfile != "" {
+		if _, found := config.profiles[config.defaultProfile]; !found {
+			return config, fmt.Errorf("default-profile %v is not one of the profiles", config.defaultProfile)
+		}
+	}
+	return
+}
+
+func parseProfile(name string, node *yaml.Node) (p profile, err error) {
+
+	if node.Kind != yaml.MappingNode {
+		return p, fmt.Errorf("line %d: profiles.%v must be a mapping", node.Line, name)
+	}
+
+	p.values = make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := "profiles." + name + "." + key.Value
+
+		if !isProfileKey(key.Value) {
+			return p, fmt.Errorf("line %d: unknown key %v, expected one of %v", key.Line, path, strings.Join(profileKeys, ", "))
+		}
+
+		if key.Value == kafkaConfigKey {
+			if value.Kind != yaml.MappingNode {
+				return p, fmt.Errorf("line %d: %v must be a mapping of librdkafka properties", value.Line, path)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				property, propertyValue := value.Content[j], value.Content[j+1]
+				if propertyValue.Kind != yaml.ScalarNode {
+					return p, fmt.Errorf("line %d: %v.%v must be a single value", propertyValue.Line, path, property.Value)
+				}
+				p.kafkaConfig = append(p.kafkaConfig, property.Value+"="+propertyValue.Value)
+			}
+			continue
+		}
+
+		if value.Kind != yaml.ScalarNode {
+			return p, fmt.Errorf("line %d: %v must be a single value", value.Line, path)
+		}
+		p.values[key.Value] = value.Value
+	}
+	return
+}
+
+func isProfileKey(key string) bool {
+	for _, profileKey := range profileKeys {
+		if key == profileKey {
+			return true
+		}
+	}
+	return false
+}
+
+func runConfig(args []string) (err error) {
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return errors.New("missing config command")
+	}
+
+	switch args[0] {
+	case "view":
+		return runConfigView(args[1:], os.Stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, configUsage)
+		return flag.ErrHelp
+	}
+
+	fmt.Fprint(os.Stderr, configUsage)
+	return fmt.Errorf("unknown config command %q", args[0])
+}
+
+func runConfigView(args []string, w io.Writer) (err error) {
+
+	var common commonFlags
+
+	fs := flag.NewFlagSet("config view", flag.ContinueOnError)
+	common.register(fs)
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = common.config.apply(fs); err != nil {
+		return
+	}
+
+	view := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value *yaml.Node, source string) {
+		view.Content = append(view.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key, LineComment: source}, value)
+	}
+
+	for _, key := range profileKeys {
+
+		f := fs.Lookup(key)
+
+		if key == kafkaConfigKey {
+			properties := &yaml.Node{Kind: yaml.MappingNode}
+			for _, property := range common.kafka.config {
+				i := strings.Index(property, "=")
+				if i <= 0 {
+					continue
+				}
+				name, value := strings.TrimSpace(property[:i]), property[i+1:]
+				properties.Content = append(properties.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+					&yaml.Node{Kind: yaml.ScalarNode, Value: redactProperty(name, value)})
+			}
+			if len(properties.Content) > 0 {
+				add(key, properties, common.config.sources[key])
+			}
+			continue
+		}
+
+		value, source := f.Value.String(), common.config.sources[key]
+		if source == "" {
+			value, source = f.DefValue, "default"
+		}
+		if key == "brokers" && value == "" && !hasProperty(common.kafka.config, "bootstrap.servers") {
+			value = defaultBrokers
+		}
+		if value == "" || (key == "sr-skip-tls-verify" && value == "false") {
+			continue
+		}
+		add(key, &yaml.Node{Kind: yaml.ScalarNode, Value: redact(key, value)}, source)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(view); err != nil {
+		return
+	}
+	return encoder.Close()
+}
+
+func hasProperty(properties stringsFlag, name string) bool {
+	for _, property := range properties {
+		if strings.HasPrefix(property, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// redact hides the secret in the value of a flag.
+func redact(key string, value string) string {
+	switch key {
+	case "sasl-password", "sr-api-secret":
+		return "***"
+	case "sr-basic-auth":
+		if i := strings.Index(value, ":"); i >= 0 {
+			return value[:i+1] + "***"
+		}
+		return "***"
+	}
+	return value
+}
+
+// redactProperty hides the value of librdkafka properties which hold secrets.
+func redactProperty(name string, value string) string {
+	if strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.HasSuffix(name, ".key.pem") {
+		return "***"
+	}
+	return value
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfigFile = `
+default-profile: local
+profiles:
+  local:
+    brokers: localhost:9092
+  staging:
+    brokers: kafka-1.staging:9093
+    security-protocol: sasl_ssl
+    sasl-username: gokafkaavro
+    sasl-password: file-secret
+    kafka-config:
+      client.id: gokafkaavro
+      ssl.key.password: key-secret
+    registry: https://registry.staging
+    sr-basic-auth: user:pass
+    sr-skip-tls-verify: true
+`
+
+func writeTestConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseConfigFile(t *testing.T) {
+
+	config, err := parseConfigFile([]byte(testConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.defaultProfile != "local" || len(config.profiles) != 2 {
+		t.Errorf("parsed default profile %v and %d profiles", config.defaultProfile, len(config.profiles))
+	}
+
+	staging := config.profiles["staging"]
+	if staging.values["brokers"] != "kafka-1.staging:9093" || staging.values["sr-skip-tls-verify"] != "true" {
+		t.Errorf("parsed staging profile %v", staging.values)
+	}
+	if strings.Join(staging.kafkaConfig, ",") != "client.id=gokafkaavro,ssl.key.password=key-secret" {
+		t.Errorf("parsed staging kafka-config %v", staging.kafkaConfig)
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+
+	var tests = []struct {
+		content string
+		want    string
+	}{
+		{"profiles: [", "yaml"},
+		{"- local", "line 1: expected a mapping"},
+		{"profile:\n  local: {}", `unknown key "profile"`},
+		{"profiles:\n  local:\n    brokerz: localhost:9092", "line 3: unknown key profiles.local.brokerz"},
+		{"profiles:\n  local:\n    brokers: [a, b]", "line 3: profiles.local.brokers must be a single value"},
+		{"profiles:\n  local:\n    kafka-config: client.id=x", "profiles.local.kafka-config must be a mapping"},
+		{"profiles:\n  local: localhost", "profiles.local must be a mapping"},
+		{"default-profile: prod\nprofiles:\n  local: {}", "default-profile prod is not one of the profiles"},
+	}
+
+	for _, test := range tests {
+		_, err := parseConfigFile([]byte(test.content))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parseConfigFile(%q) returned %v, want an error containing %q", test.content, err, test.want)
+		}
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+
+	path := writeTestConfigFile(t, testConfigFile)
+	t.Setenv("GOKAFKAAVRO_SASL_USERNAME", "env-user")
+	t.Setenv(profileEnvName, "")
+
+	var common commonFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	common.register(fs)
+
+	args := []string{"--config", path, "--profile", "staging", "--brokers", "flag:9092", "--kafka-config", "client.id=flag"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := common.config.apply(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	if common.kafka.brokers != "flag:9092" {
+		t.Errorf("brokers is %v, want the flag", common.kafka.brokers)
+	}
+	if common.kafka.saslUsername != "env-user" {
+		t.Errorf("sasl username is %v, want the environment variable", common.kafka.saslUsername)
+	}
+	if common.kafka.securityProtocol != "sasl_ssl" || common.registry.url != "https://registry.staging" || !common.registry.skipTLSVerify {
+		t.Errorf("profile was not applied: %+v %+v", common.kafka, common.registry)
+	}
+
+	configMap, err := common.kafka.configMap(nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientID, _ := configMap.Get("client.id", nil); clientID != "flag" {
+		t.Errorf("client.id is %v, want the flag", clientID)
+	}
+}
+
+func TestConfigProfileSelection(t *testing.T) {
+
+	path := writeTestConfigFile(t, testConfigFile)
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	t.Setenv(configFileEnvName, "")
+	t.Setenv(profileEnvName, "")
+	t.Setenv("HOME", t.TempDir())
+
+	var tests = []struct {
+		config  configFlags
+		brokers string
+		wantErr string
+	}{
+		{configFlags{path: path}, "localhost:9092", ""},
+		{configFlags{path: path, profile: "staging"}, "kafka-1.staging:9093", ""},
+		{configFlags{path: path, profile: "prod"}, "", "profile prod not found in config file " + path + ", available profiles: local, staging"},
+		{configFlags{path: missing}, "", "no such file"},
+		{configFlags{}, "", ""},
+		{configFlags{profile: "staging"}, "", "profile staging requested but there is no config file"},
+	}
+
+	for _, test := range tests {
+		selected, _, err := test.config.selectProfile()
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("selectProfile(%+v) returned %v, want an error containing %q", test.config, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("selectProfile(%+v) failed: %v", test.config, err)
+			continue
+		}
+		if selected.values["brokers"] != test.brokers {
+			t.Errorf("selectProfile(%+v) returned brokers %v, want %v", test.config, selected.values["brokers"], test.brokers)
+		}
+	}
+}
+
+func TestConfigView(t *testing.T) {
+
+	path := writeTestConfigFile(t, testConfigFile)
+	t.Setenv(profileEnvName, "")
+	t.Setenv("GOKAFKAAVRO_SASL_USERNAME", "")
+
+	var out bytes.Buffer
+	if err := runConfigView([]string{"--config", path, "--profile", "staging"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `brokers: kafka-1.staging:9093 # profile staging
+security-protocol: sasl_ssl # profile staging
+sasl-username: gokafkaavro # profile staging
+sasl-password: '***' # profile staging
+kafka-config: # profile staging
+  client.id: gokafkaavro
+  ssl.key.password: '***'
+registry: https://registry.staging # profile staging
+sr-basic-auth: user:*** # profile staging
+sr-skip-tls-verify: true # profile staging
+`
+	if got := out.String(); got != want {
+		t.Errorf("config view printed\n%v\nwant\n%v", got, want)
+	}
+
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "pass\n") {
+		t.Errorf("config view printed a secret")
+	}
+}
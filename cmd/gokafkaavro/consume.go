@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+type consumeFlags struct {
+	common         commonFlags
+	topics         stringsFlag
+	topicRegex     string
+	group          string
+	outputFile     string
+	compression    string
+	onSchemaChange string
+	fields         string
+	filters        filterFlags
+	filterExpr     string
+	countOnly      bool
+	commit         bool
+	commitInterval int
+	onError        string
+	dlqTopic       string
+	stats          time.Duration
+	follow         bool
+	ranges         rangeFlags
+	snapshot       bool
+	maxKeys        int
+	jsonMode       string
+	nonFinite      string
+}
+
+// subscription returns the topics to subscribe to, a regex subscription is a
+// topic starting with ^ for librdkafka.
+func (f consumeFlags) subscription() (topics []string) {
+	topics = append(topics, f.topics...)
+	if f.topicRegex != "" {
+		if strings.HasPrefix(f.topicRegex, "^") {
+			topics = append(topics, f.topicRegex)
+		} else {
+			topics = append(topics, "^"+f.topicRegex)
+		}
+	}
+	return
+}
+
+type consumer struct {
+	flags       consumeFlags
+	codec       *kafkaavro.Codec
+	projection  *projection
+	filter      predicate
+	ocf         *ocfOutput
+	output      *json.Encoder
+	labelTopics bool
+	count       int64
+	offsets     *offsetTracker
+	errors      *errorHandler
+	stats       *statsReporter
+	ranges      *rangeTracker
+	snapshot    *snapshot
+}
+
+// labeledRecord is printed instead of the bare record when more than one topic is consumed.
+type labeledRecord struct {
+	Topic     string      `json:"topic"`
+	Partition int32       `json:"partition"`
+	Offset    int64       `json:"offset"`
+	Value     interface{} `json:"value"`
+}
+
+func runConsume(args []string) (err error) {
+
+	var f consumeFlags
+
+	fs := flag.NewFlagSet("consume", flag.ContinueOnError)
+	f.common.register(fs)
+	fs.Var(&f.topics, "topic", "topic to consume from (repeatable)")
+	fs.StringVar(&f.topicRegex, "topic-regex", "", "consume from all topics matching this regular expression, e.g. 'orders.*'")
+	fs.StringVar(&f.group, "group", "gokafkaavro", "consumer group id")
+	fs.StringVar(&f.outputFile, "output-file", "", "write the decoded records to this avro object container file instead of stdout")
+	fs.StringVar(&f.compression, "compression", "null", "compression codec of the --output-file: null, deflate or snappy")
+	fs.StringVar(&f.onSchemaChange, "on-schema-change", schemaChangeFail, "what to do when the writer schema changes while writing the --output-file: fail or split (roll to a new file)")
+	fs.StringVar(&f.fields, "fields", "", "only print these comma separated (dot separated) field paths, e.g. 'id,customer.name'. Applied before the filters")
+	fs.Var(&f.filters, "filter", "only keep records where the field path equals the value, e.g. 'customer.id=42' (repeatable, all must match)")
+	fs.StringVar(&f.filterExpr, "filter-expr", "", `only keep records matching the expression, e.g. 'total >= 100 && (status == "OPEN" || !exists(closedAt))'`)
+	fs.BoolVar(&f.countOnly, "count-only", false, "only print the number of (matching) records on exit")
+	fs.BoolVar(&f.commit, "commit", false, "commit the offsets of the handled messages so that the next run with the same --group resumes where this one stopped. "+
+		"Without --commit no offsets are committed and every run starts from the beginning of the topic")
+	fs.StringVar(&f.onError, "on-error", onErrorSkip, "what to do with messages which can not be decoded: skip (and report them at exit), "+
+		"fail (exit immediately) or dlq (produce them unchanged to the --dlq-topic)")
+	fs.DurationVar(&f.stats, "stats", 0, "print throughput, decode error, schema cache and consumer lag statistics to stderr at this interval, e.g. 10s")
+	fs.StringVar(&f.dlqTopic, "dlq-topic", "", "with --on-error dlq, the topic to produce the messages which can not be decoded to, %s is replaced by their topic, e.g. %s.dlq")
+	fs.BoolVar(&f.follow, "follow", true, "keep consuming new messages like tail -f, this is the default unless --between, --since or --until is passed")
+	fs.Var(&f.ranges.between, "between", "export the offsets start to end (inclusive) of a partition and exit, e.g. 0:100:200 (repeatable)")
+	fs.StringVar(&f.ranges.since, "since", "", "export the messages of all partitions from this timestamp (RFC 3339, 2006-01-02 or epoch millis) and exit")
+	fs.StringVar(&f.ranges.until, "until", "", "export the messages of all partitions before this timestamp and exit (default up to the current end)")
+	fs.IntVar(&f.commitInterval, "commit-interval", 100, "with --commit, commit every this many messages (and on exit)")
+	fs.StringVar(&f.nonFinite, "non-finite-floats", string(kafkaavro.NonFiniteError), `JSON of the NaN and infinite floats, which JSON has no numbers for: error (handled like --on-error), null or string ("NaN", "Infinity", "-Infinity")`)
+	fs.BoolVar(&f.snapshot, "snapshot", false, "print the latest value of every key of a (compacted) topic up to its current end and exit, deleted keys are left out")
+	fs.IntVar(&f.maxKeys, "max-keys", 1000000, "with --snapshot, fail when the topic has more than this many keys, which are all kept in memory")
+	fs.StringVar(&f.jsonMode, "json-mode", string(kafkaavro.JSONModeAvro), "JSON of the printed records: avro (unions as {\"type\": value}) or standard (unions as the bare value)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = f.common.config.apply(fs); err != nil {
+		return
+	}
+
+	topics := f.subscription()
+	if len(topics) == 0 {
+		return errors.New("--topic or --topic-regex is required")
+	}
+
+	followSet := false
+	fs.Visit(func(set *flag.Flag) {
+		followSet = followSet || set.Name == "follow"
+	})
+	if f.snapshot {
+		if err = f.validateSnapshot(followSet); err != nil {
+			return
+		}
+	} else if f.ranges.isSet() {
+		switch {
+		case followSet && f.follow:
+			return errors.New("--follow can not be combined with --between, --since or --until")
+		case len(f.topics) != 1 || f.topicRegex != "":
+			return errors.New("--between, --since and --until require a single --topic")
+		case f.commit:
+			return errors.New("--commit can not be combined with --between, --since or --until")
+		}
+	}
+
+	c := &consumer{
+		flags:       f,
+		projection:  newProjection(f.fields, os.Stderr),
+		output:      json.NewEncoder(os.Stdout),
+		labelTopics: len(topics) > 1 || f.topicRegex != "",
+	}
+
+	if c.projection != nil && f.outputFile != "" {
+		return errors.New("--fields can not be combined with --output-file")
+	}
+
+	jsonMode, err := kafkaavro.ParseJSONMode(f.jsonMode)
+	if err != nil {
+		return fmt.Errorf("--json-mode: %w", err)
+	}
+	if jsonMode != kafkaavro.JSONModeAvro && f.outputFile != "" {
+		return errors.New("--json-mode standard can not be combined with --output-file")
+	}
+	nonFinite, err := kafkaavro.ParseNonFinitePolicy(f.nonFinite)
+	if err != nil {
+		return fmt.Errorf("--non-finite-floats: %w", err)
+	}
+
+	if c.filter, err = newFilter(f.filters, f.filterExpr); err != nil {
+		return
+	}
+
+	if c.errors, err = newErrorHandler(f.onError, f.dlqTopic); err != nil {
+		return
+	}
+	defer c.errors.summary(os.Stderr)
+
+	if f.countOnly {
+		defer func() {
+			fmt.Fprintln(os.Stdout, c.count)
+		}()
+	}
+
+	if f.outputFile != "" {
+		if c.ocf, err = newOCFOutput(f.outputFile, f.compression, f.onSchemaChange); err != nil {
+			return
+		}
+		defer func() {
+			if closeErr := c.ocf.Close(); err == nil {
+				err = closeErr
+			}
+			if len(c.ocf.files) > 0 {
+				fmt.Fprintf(os.Stderr, "Wrote %v\n", strings.Join(c.ocf.files, ", "))
+			}
+		}()
+	}
+
+	client, err := f.common.registry.newClient()
+	if err != nil {
+		return
+	}
+	c.codec = kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithJSONMode(jsonMode), kafkaavro.WithNonFiniteFloats(nonFinite))
+	defer c.codec.Close()
+
+	consumerDefaults := kafka.ConfigMap{
+		"group.id":                 f.group,
+		"auto.offset.reset":        "earliest",
+		"enable.auto.commit":       false,
+		"enable.auto.offset.store": false,
+	}
+
+	if f.ranges.isSet() || f.snapshot {
+		consumerDefaults["enable.partition.eof"] = true
+	}
+
+	if f.stats > 0 {
+		consumerDefaults["statistics.interval.ms"] = int(f.stats / time.Millisecond)
+		c.stats = newStatsReporter(f.stats, time.Now(), c.codec.CacheStats)
+		defer func() {
+			c.stats.summary(time.Now(), os.Stderr)
+		}()
+	}
+
+	kafkaConfig, err := f.common.kafka.configMap(consumerDefaults, os.Stderr)
+	if err != nil {
+		return
+	}
+
+	kafkaConsumer, err := kafka.NewConsumer(kafkaConfig)
+	if err != nil {
+		return
+	}
+	defer kafkaConsumer.Close()
+
+	if f.ranges.isSet() || f.snapshot {
+		ranges, rangesErr := f.ranges.resolve(kafkaConsumer, f.topics[0])
+		if rangesErr != nil {
+			return rangesErr
+		}
+		c.ranges = newRangeTracker(f.topics[0], ranges, time.Now())
+		defer c.ranges.summary(os.Stderr)
+		if f.snapshot {
+			c.snapshot = newSnapshot(f.topics[0], f.maxKeys)
+			defer func() {
+				if err == nil {
+					err = c.writeSnapshot(os.Stderr)
+				}
+			}()
+		}
+		if c.ranges.done() {
+			return
+		}
+		if err = kafkaConsumer.Assign(c.ranges.assignment()); err != nil {
+			return
+		}
+	} else if err = kafkaConsumer.SubscribeTopics(topics, nil); err != nil {
+		return
+	}
+
+	if f.commit {
+		c.offsets = newOffsetTracker(kafkaConsumer, f.commitInterval)
+		defer func() {
+			if commitErr := c.offsets.commit(); err == nil {
+				err = commitErr
+			}
+			c.offsets.summary(os.Stderr)
+		}()
+	}
+
+	// the dead letter queue is flushed before the offsets are committed
+	if f.onError == onErrorDLQ {
+		producerConfig, configErr := f.common.kafka.configMap(kafka.ConfigMap{}, io.Discard)
+		if configErr != nil {
+			return configErr
+		}
+		producer, producerErr := kafka.NewProducer(producerConfig)
+		if producerErr != nil {
+			return producerErr
+		}
+		defer producer.Close()
+		c.errors.useProducer(producer, os.Stderr)
+		defer func() {
+			if closeErr := c.errors.close(); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	for {
+		select {
+
+		case sig := <-sigchan:
+			fmt.Fprintf(os.Stderr, "Caught signal %v: terminating\n", sig)
+			return
+
+		default:
+
+			if c.stats != nil {
+				c.stats.tick(time.Now(), os.Stderr)
+			}
+			if c.ranges != nil {
+				c.ranges.progress(time.Now(), time.Second, os.Stderr)
+			}
+
+			ev := kafkaConsumer.Poll(100)
+			if ev == nil {
+				continue
+			}
+
+			switch e := ev.(type) {
+
+			case *kafka.Message:
+				if c.ranges != nil && !c.ranges.contains(e) {
+					c.ranges.reached(e.TopicPartition.Partition, int64(e.TopicPartition.Offset), os.Stderr)
+					if c.ranges.done() {
+						return
+					}
+					continue
+				}
+				if err = c.handleMessage(e); err != nil {
+					return
+				}
+				if c.offsets != nil {
+					if err = c.offsets.handled(e); err != nil {
+						return
+					}
+				}
+				if c.ranges != nil {
+					c.ranges.handled(e, os.Stderr)
+					if c.ranges.done() {
+						return
+					}
+				}
+
+			case kafka.PartitionEOF:
+				if c.ranges != nil {
+					c.ranges.reached(e.Partition, int64(e.Offset), os.Stderr)
+					if c.ranges.done() {
+						return
+					}
+				}
+
+			case *kafka.Stats:
+				if c.stats != nil {
+					if statsErr := c.stats.librdkafkaStats(e.String()); statsErr != nil {
+						fmt.Fprintln(os.Stderr, statsErr)
+					}
+				}
+
+			case kafka.Error:
+				// Errors should generally be considered informational, the client
+				// will try to automatically recover. But when all brokers are
+				// down there is nothing left to consume from.
+				fmt.Fprintf(os.Stderr, "%% Error: %v: %v\n", e.Code(), e)
+				if e.Code() == kafka.ErrAllBrokersDown {
+					return e
+				}
+			}
+		}
+	}
+}
+
+func (c *consumer) handleMessage(m *kafka.Message) (err error) {
+
+	if c.snapshot != nil {
+		return c.handleSnapshotMessage(m)
+	}
+
+	if len(m.Value) == 0 {
+		fmt.Fprintf(os.Stderr, "Message on %v was null. On a log-compacted topic this means a delete\n", m.TopicPartition)
+		return
+	}
+
+	if c.stats != nil {
+		c.stats.message(m)
+	}
+
+	native, schema, decodeErr := c.decode(m)
+	if decodeErr != nil {
+		if c.stats != nil {
+			c.stats.decodeError()
+		}
+		return c.errors.handle(m, decodeErr, os.Stderr)
+	}
+
+	if c.projection != nil {
+		native = c.projection.project(native)
+	}
+
+	if c.filter != nil && !c.filter.match(native) {
+		return
+	}
+
+	if c.flags.countOnly {
+		c.count++
+		return
+	}
+
+	if c.ocf != nil {
+		return c.ocf.Write(schema.ID, schema.Schema, native)
+	}
+
+	if c.labelTopics {
+		return c.output.Encode(labeledRecord{
+			Topic:     *m.TopicPartition.Topic,
+			Partition: m.TopicPartition.Partition,
+			Offset:    int64(m.TopicPartition.Offset),
+			Value:     native,
+		})
+	}
+
+	return c.output.Encode(native)
+}
+
+// decode decodes the value of the message to the record of its JSON, in the encoding of --json-mode
+// and with the non finite floats of --non-finite-floats, or to its native value for the --output-file.
+func (c *consumer) decode(m *kafka.Message) (native interface{}, schema kafkaavro.SchemaInfo, err error) {
+	return c.decodeData(*m.TopicPartition.Topic, false, m.Value)
+}
+
+// decodeData decodes the key or the value data of a message of the topic like decode.
+func (c *consumer) decodeData(topic string, isKey bool, data []byte) (native interface{}, schema kafkaavro.SchemaInfo, err error) {
+
+	if c.flags.outputFile != "" {
+		return c.codec.DecodeWithSchemaInfo(topic, isKey, data)
+	}
+	textual, err := c.codec.DecodeToJSON(topic, isKey, data)
+	if err != nil {
+		return
+	}
+	native, err = kafkaavro.ParseJSON(textual)
+	return
+}
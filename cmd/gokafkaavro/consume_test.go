@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestConsumeSubscription(t *testing.T) {
+
+	var tests = []struct {
+		flags consumeFlags
+		want  []string
+	}{
+		{consumeFlags{topics: stringsFlag{"orders"}}, []string{"orders"}},
+		{consumeFlags{topics: stringsFlag{"orders", "payments"}}, []string{"orders", "payments"}},
+		{consumeFlags{topicRegex: "orders.*"}, []string{"^orders.*"}},
+		{consumeFlags{topicRegex: "^orders.*"}, []string{"^orders.*"}},
+		{consumeFlags{topics: stringsFlag{"payments"}, topicRegex: "orders.*"}, []string{"payments", "^orders.*"}},
+	}
+
+	for _, test := range tests {
+		if got := test.flags.subscription(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("subscription(%+v) returned %v, want %v", test.flags, got, test.want)
+		}
+	}
+}
+
+func TestConsumeDecodeJSONMode(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]}]}`)
+
+	var tests = []struct {
+		mode       kafkaavro.JSONMode
+		outputFile string
+		want       interface{}
+	}{
+		// the ids beyond 2^53 are exact in both modes
+		{kafkaavro.JSONModeAvro, "", map[string]interface{}{"id": json.Number("9007199254740993"), "note": map[string]interface{}{"string": "x"}}},
+		{kafkaavro.JSONModeStandard, "", map[string]interface{}{"id": json.Number("9007199254740993"), "note": "x"}},
+		// the native value with its schema for the output file
+		{kafkaavro.JSONModeAvro, "out.avro", map[string]interface{}{"id": int64(1<<53 + 1), "note": map[string]interface{}{"string": "x"}}},
+	}
+
+	for _, test := range tests {
+		codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithJSONMode(test.mode))
+		c := &consumer{flags: consumeFlags{jsonMode: string(test.mode), outputFile: test.outputFile}, codec: codec}
+		message := testMessage(0, 1)
+		var err error
+		if message.Value, err = codec.Encode("orders", false, map[string]interface{}{"id": int64(1<<53 + 1), "note": map[string]interface{}{"string": "x"}}); err != nil {
+			t.Fatal(err)
+		}
+		if native, schema, err := c.decode(message); err != nil || !reflect.DeepEqual(native, test.want) || (test.outputFile != "" && schema.ID == 0) {
+			t.Errorf("decode() in %v mode to %q returned %#v, %v, want %#v", test.mode, test.outputFile, native, err, test.want)
+		}
+	}
+}
+
+func TestConsumeDecodeNonFinite(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("sensors-value", `{"type":"record","name":"reading","fields":[{"name":"value","type":"double"},
+		{"name":"history","type":{"type":"array","items":"float"}},{"name":"extra","type":["null","double"]}]}`)
+	value := map[string]interface{}{"value": math.NaN(), "history": []interface{}{float32(1.5), float32(math.Inf(1))},
+		"extra": map[string]interface{}{"double": math.Inf(-1)}}
+
+	decode := func(policy kafkaavro.NonFinitePolicy) (interface{}, error) {
+		codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithNonFiniteFloats(policy))
+		c := &consumer{flags: consumeFlags{jsonMode: string(kafkaavro.JSONModeAvro)}, codec: codec}
+		topic := "sensors"
+		message := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}}
+		var err error
+		if message.Value, err = codec.Encode(topic, false, value); err != nil {
+			t.Fatal(err)
+		}
+		native, _, err := c.decode(message)
+		return native, err
+	}
+
+	var tests = []struct {
+		policy kafkaavro.NonFinitePolicy
+		want   interface{}
+	}{
+		{kafkaavro.NonFiniteNull, map[string]interface{}{"value": nil, "history": []interface{}{json.Number("1.5"), nil}, "extra": map[string]interface{}{"double": nil}}},
+		{kafkaavro.NonFiniteString, map[string]interface{}{"value": "NaN", "history": []interface{}{json.Number("1.5"), "Infinity"}, "extra": map[string]interface{}{"double": "-Infinity"}}},
+	}
+	for _, test := range tests {
+		if native, err := decode(test.policy); err != nil || !reflect.DeepEqual(native, test.want) {
+			t.Errorf("decode() with %v returned %#v, %v, want %#v", test.policy, native, err, test.want)
+		}
+	}
+
+	var fieldErr *kafkaavro.FieldError
+	if _, err := decode(kafkaavro.NonFiniteError); !errors.Is(err, kafkaavro.ErrNonFiniteFloat) || !errors.As(err, &fieldErr) || fieldErr.Path != "value" {
+		t.Errorf("decode() with %v returned %v", kafkaavro.NonFiniteError, err)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A predicate decides if a decoded record is kept.
+type predicate interface {
+	match(native interface{}) bool
+}
+
+// filterFlags collects the repeatable --filter 'field.path=value' flags.
+type filterFlags []string
+
+func (f *filterFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *filterFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("filter %q is not of the form field.path=value", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// newFilter combines the --filter flags and --filter-expr into a single predicate.
+// It returns nil when there is nothing to filter on.
+func newFilter(filters filterFlags, filterExpr string) (p predicate, err error) {
+
+	var predicates andPredicate
+
+	for _, filter := range filters {
+		i := strings.Index(filter, "=")
+		predicates = append(predicates, comparison{
+			path:     strings.TrimSpace(filter[:i]),
+			operator: "==",
+			literal:  parseLiteral(filter[i+1:]),
+		})
+	}
+
+	if strings.TrimSpace(filterExpr) != "" {
+		expr, parseErr := parseFilterExpr(filterExpr)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		predicates = append(predicates, expr)
+	}
+
+	if len(predicates) == 0 {
+		return nil, nil
+	}
+	return predicates, nil
+}
+
+// parseLiteral interprets the value of a --filter flag: numbers, booleans and null are
+// compared as such, everything else is compared as a string.
+func parseLiteral(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	return s
+}
+
+type andPredicate []predicate
+
+func (a andPredicate) match(native interface{}) bool {
+	for _, p := range a {
+		if !p.match(native) {
+			return false
+		}
+	}
+	return true
+}
+
+type orPredicate []predicate
+
+func (o orPredicate) match(native interface{}) bool {
+	for _, p := range o {
+		if p.match(native) {
+			return true
+		}
+	}
+	return false
+}
+
+type notPredicate struct {
+	p predicate
+}
+
+func (n notPredicate) match(native interface{}) bool {
+	return !n.p.match(native)
+}
+
+type existsPredicate struct {
+	path string
+}
+
+func (e existsPredicate) match(native interface{}) bool {
+	_, found := lookup(native, e.path)
+	return found
+}
+
+type comparison struct {
+	path     string
+	operator string
+	literal  interface{}
+}
+
+func (c comparison) match(native interface{}) bool {
+
+	value, found := lookup(native, c.path)
+	if !found {
+		return false
+	}
+	value = unwrapUnion(value)
+
+	if c.literal == nil || value == nil {
+		isEqual := c.literal == nil && value == nil
+		switch c.operator {
+		case "==":
+			return isEqual
+		case "!=":
+			return !isEqual
+		}
+		return false
+	}
+
+	var order int
+	switch literal := c.literal.(type) {
+	case float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return c.operator == "!="
+		}
+		order = compareFloats(f, literal)
+	case string:
+		s, ok := value.(string)
+		if !ok {
+			return c.operator == "!="
+		}
+		order = strings.Compare(s, literal)
+	case bool:
+		b, ok := value.(bool)
+		if !ok {
+			return c.operator == "!="
+		}
+		if b == literal {
+			order = 0
+		} else {
+			order = 1
+		}
+		if c.operator != "==" && c.operator != "!=" {
+			return false
+		}
+	}
+
+	switch c.operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// lookup resolves a dot separated field path in a decoded record. Goavro represents
+// a non-null union value as a map with the type name as single key, those wrappers are
+// traversed transparently.
+func lookup(native interface{}, path string) (value interface{}, found bool) {
+
+	value = native
+	for _, field := range strings.Split(path, ".") {
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = record[field]; ok {
+			continue
+		}
+		if value, ok = unwrapUnion(record).(map[string]interface{}); !ok {
+			return nil, false
+		}
+		if value, ok = value.(map[string]interface{})[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// unwrapUnion returns the value inside a goavro union wrapper, other values are returned as is.
+func unwrapUnion(value interface{}) interface{} {
+	wrapper, ok := value.(map[string]interface{})
+	if !ok || len(wrapper) != 1 {
+		return value
+	}
+	for _, v := range wrapper {
+		return v
+	}
+	return value
+}
+
+// parseFilterExpr parses expressions like
+//
+//	total >= 100 && (status == "OPEN" || !exists(closedAt))
+func parseFilterExpr(expr string) (p predicate, err error) {
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return
+	}
+
+	parser := &exprParser{tokens: tokens}
+	if p, err = parser.parseOr(); err != nil {
+		return
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", parser.tokens[parser.pos].text)
+	}
+	return
+}
+
+type tokenKind int
+
+const (
+	tokenIdentifier tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expr string) (tokens []token, err error) {
+
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in filter expression")
+			}
+			s, unquoteErr := strconv.Unquote(string(runes[i : j+1]))
+			if unquoteErr != nil {
+				return nil, fmt.Errorf("invalid string %v in filter expression", string(runes[i:j+1]))
+			}
+			tokens = append(tokens, token{tokenString, s})
+			i = j + 1
+
+		case r == '-' || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune(".eE+-", runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j])})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdentifier, string(runes[i:j])})
+			i = j
+
+		default:
+			operator := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected %q in filter expression", string(r))
+			}
+			tokens = append(tokens, token{tokenOperator, operator})
+			i += len(operator)
+		}
+	}
+	return
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() (t token, ok bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return
+}
+
+func (p *exprParser) acceptOperator(operator string) bool {
+	if t, ok := p.peek(); ok && t.kind == tokenOperator && t.text == operator {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expectOperator(operator string) error {
+	if !p.acceptOperator(operator) {
+		return fmt.Errorf("expected %q in filter expression", operator)
+	}
+	return nil
+}
+
+func isComparisonOperator(t token) bool {
+	if t.kind != tokenOperator {
+		return false
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (predicate, error) {
+	var or orPredicate
+	for {
+		and, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, and)
+		if !p.acceptOperator("||") {
+			break
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *exprParser) parseAnd() (predicate, error) {
+	var and andPredicate
+	for {
+		unary, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, unary)
+		if !p.acceptOperator("&&") {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *exprParser) parseUnary() (predicate, error) {
+
+	if p.acceptOperator("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notPredicate{inner}, nil
+	}
+
+	if p.acceptOperator("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expectOperator(")")
+	}
+
+	t, ok := p.peek()
+	if !ok || t.kind != tokenIdentifier {
+		return nil, fmt.Errorf("expected a field path in filter expression")
+	}
+	p.pos++
+
+	if t.text == "exists" && p.acceptOperator("(") {
+		path, ok := p.peek()
+		if !ok || path.kind != tokenIdentifier {
+			return nil, fmt.Errorf("expected a field path in exists()")
+		}
+		p.pos++
+		return existsPredicate{path.text}, p.expectOperator(")")
+	}
+
+	operator, ok := p.peek()
+	if !ok || !isComparisonOperator(operator) {
+		return nil, fmt.Errorf("expected a comparison operator after %v in filter expression", t.text)
+	}
+	p.pos++
+
+	literal, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a value after %v %v in filter expression", t.text, operator.text)
+	}
+	p.pos++
+
+	var value interface{}
+	switch literal.kind {
+	case tokenString:
+		value = literal.text
+	case tokenNumber:
+		f, err := strconv.ParseFloat(literal.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v in filter expression", literal.text)
+		}
+		value = f
+	case tokenIdentifier:
+		switch literal.text {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			return nil, fmt.Errorf("unexpected %v in filter expression, quote strings", literal.text)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q in filter expression", literal.text)
+	}
+
+	return comparison{path: t.text, operator: operator.text, literal: value}, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import "testing"
+
+var filterTestRecord = map[string]interface{}{
+	"id":     int64(42),
+	"status": "OPEN",
+	"total":  float64(99.5),
+	"paid":   false,
+	"note":   nil,
+	"customer": map[string]interface{}{
+		"name": "alice",
+		"address": map[string]interface{}{
+			"com.acme.Address": map[string]interface{}{"city": "Brussels"},
+		},
+		"email": map[string]interface{}{"string": "alice@example.com"},
+	},
+}
+
+func TestFilterFlags(t *testing.T) {
+
+	var tests = []struct {
+		filters filterFlags
+		want    bool
+	}{
+		{filterFlags{"id=42"}, true},
+		{filterFlags{"id=43"}, false},
+		{filterFlags{"status=OPEN", "customer.name=alice"}, true},
+		{filterFlags{"status=OPEN", "customer.name=bob"}, false},
+		{filterFlags{"customer.email=alice@example.com"}, true},
+		{filterFlags{"customer.address.city=Brussels"}, true},
+		{filterFlags{"paid=false"}, true},
+		{filterFlags{"note=null"}, true},
+		{filterFlags{"missing=1"}, false},
+	}
+
+	for _, test := range tests {
+		filter, err := newFilter(test.filters, "")
+		if err != nil {
+			t.Fatalf("newFilter(%v) returned error %v", test.filters, err)
+		}
+		if got := filter.match(filterTestRecord); got != test.want {
+			t.Errorf("filter %v returned %v, want %v", test.filters, got, test.want)
+		}
+	}
+}
+
+func TestFilterExpr(t *testing.T) {
+
+	var tests = []struct {
+		expr string
+		want bool
+	}{
+		{`status == "OPEN"`, true},
+		{`status != "OPEN"`, false},
+		{`id > 41 && id <= 42`, true},
+		{`id >= 43 || total < 100`, true},
+		{`total > 100`, false},
+		{`exists(customer.email)`, true},
+		{`!exists(closedAt)`, true},
+		{`exists(closedAt) || (status == "OPEN" && customer.address.city == "Brussels")`, true},
+		{`paid == true`, false},
+		{`note == null`, true},
+		{`customer.name < "bob"`, true},
+		{`status > 3`, false},
+	}
+
+	for _, test := range tests {
+		filter, err := newFilter(nil, test.expr)
+		if err != nil {
+			t.Fatalf("newFilter(%v) returned error %v", test.expr, err)
+		}
+		if got := filter.match(filterTestRecord); got != test.want {
+			t.Errorf("filter %v returned %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestFilterExprSyntaxErrors(t *testing.T) {
+
+	var tests = []string{
+		`status ==`,
+		`status = "OPEN"`,
+		`(status == "OPEN"`,
+		`status == OPEN`,
+		`status == "OPEN" extra`,
+		`exists(`,
+		`"OPEN" == status`,
+	}
+
+	for _, expr := range tests {
+		if _, err := newFilter(nil, expr); err == nil {
+			t.Errorf("newFilter(%v) returned no error", expr)
+		}
+	}
+}
+
+func TestNoFilter(t *testing.T) {
+	if filter, err := newFilter(nil, " "); filter != nil || err != nil {
+		t.Errorf("newFilter without filters returned %v, %v", filter, err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/avrogen"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// latestSchemas fetches the latest schema of subjects and the schemas they reference.
+type latestSchemas interface {
+	GetLatestSchema(subject string) (schema schemaregistry.Schema, err error)
+	GetSchemaBySubject(subject string, version int) (schema schemaregistry.Schema, err error)
+}
+
+func runGen(args []string, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var topics, subjects, files stringsFlag
+	var isKey, codec bool
+	var packageName, out string
+
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.Var(&topics, "topic", "generate the types of the latest schema of the subject of this topic (topic name strategy), repeatable")
+	fs.BoolVar(&isKey, "key", false, "with --topic, use the key subject instead of the value subject")
+	fs.Var(&subjects, "subject", "generate the types of the latest schema of this subject, repeatable")
+	fs.Var(&files, "schema-file", "generate the types of the schema in this .avsc file, repeatable")
+	fs.StringVar(&packageName, "package", "schemas", "package of the generated code")
+	fs.StringVar(&out, "out", "", "file to write the generated code to instead of stdout")
+	fs.BoolVar(&codec, "codec", false, "generate Decode and Encode methods with a *kafkaavro.Codec")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+
+	for _, topic := range topics {
+		subjects = append(subjects, string(kafkaavro.TopicNameStrategy{}.GetSubjectName(topic, isKey)))
+	}
+	if len(subjects) == 0 && len(files) == 0 {
+		return errors.New("at least one --topic, --subject or --schema-file is required")
+	}
+
+	var schemas []avrogen.Schema
+	for _, file := range files {
+		schema, readErr := os.ReadFile(file)
+		if readErr != nil {
+			return readErr
+		}
+		schemas = append(schemas, avrogen.Schema{Name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), Schema: string(schema)})
+	}
+	if len(subjects) > 0 {
+		client, clientErr := registry.newClient()
+		if clientErr != nil {
+			return clientErr
+		}
+		fetched, fetchErr := fetchLatestSchemas(client, subjects)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		schemas = append(schemas, fetched...)
+	}
+
+	source, err := avrogen.Generate(schemas, avrogen.Options{Package: packageName, Codec: codec})
+	if err != nil {
+		return
+	}
+	if out != "" {
+		return os.WriteFile(out, source, 0o644)
+	}
+	_, err = w.Write(source)
+	return
+}
+
+// fetchLatestSchemas fetches the latest avro schemas of the subjects, and the versions of the
+// subjects they reference, which define the named types the schemas use.
+func fetchLatestSchemas(client latestSchemas, subjects []string) (schemas []avrogen.Schema, err error) {
+
+	fetched := make(map[string]bool)
+	var add func(name string, schema schemaregistry.Schema) error
+	add = func(name string, schema schemaregistry.Schema) error {
+		if schema.Type() != schemaregistry.SchemaTypeAvro {
+			return fmt.Errorf("subject %v has a %v schema, only avro schemas are generated", name, schema.Type())
+		}
+		fetched[name] = true
+		schemas = append(schemas, avrogen.Schema{Name: name, Schema: schema.Schema})
+		for _, reference := range schema.References {
+			name := fmt.Sprintf("%v-%d", reference.Subject, reference.Version)
+			if fetched[name] {
+				continue
+			}
+			referenced, err := client.GetSchemaBySubject(reference.Subject, reference.Version)
+			if err != nil {
+				return fmt.Errorf("failed to fetch version %d of subject %v: %v", reference.Version, reference.Subject, err)
+			}
+			if err = add(name, referenced); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, subject := range subjects {
+		if fetched[subject] {
+			continue
+		}
+		schema, clientErr := client.GetLatestSchema(subject)
+		if clientErr != nil {
+			return nil, fmt.Errorf("failed to fetch the latest schema of subject %v: %v", subject, clientErr)
+		}
+		if err = add(subject, schema); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
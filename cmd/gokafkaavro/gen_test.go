@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+func TestRunGenSchemaFile(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "orders.avsc")
+	schema := `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]}]}`
+	if err := os.WriteFile(file, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runGen([]string{"--schema-file", file, "--package", "orders", "--codec"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"package orders", "type Order struct", "Note *string", "func (o *Order) Decode("} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("runGen() does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := runGen([]string{"--package", "orders"}, &out); err == nil {
+		t.Error("runGen() without schemas succeeded")
+	}
+}
+
+func TestFetchLatestSchemas(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("customer", `{"type":"record","name":"Customer","fields":[{"name":"name","type":"string"}]}`)
+	registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	registry.RegisterSchema("orders-value", schemaregistry.Schema{
+		Schema:     `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"customer","type":"Customer"}]}`,
+		References: []schemaregistry.Reference{{Name: "Customer", Subject: "customer", Version: 1}},
+	})
+	registry.RegisterSchema("orders-proto", schemaregistry.Schema{Schema: `syntax = "proto3";`, SchemaType: schemaregistry.SchemaTypeProtobuf})
+
+	schemas, err := fetchLatestSchemas(registry, []string{"orders-value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 || schemas[0].Name != "orders-value" || !strings.Contains(schemas[0].Schema, "customer") || schemas[1].Name != "customer-1" {
+		t.Errorf("fetchLatestSchemas() returned %v", schemas)
+	}
+
+	if _, err = fetchLatestSchemas(registry, []string{"orders-proto"}); err == nil || !strings.Contains(err.Error(), "PROTOBUF") {
+		t.Errorf("fetchLatestSchemas() of a protobuf schema returned %v", err)
+	}
+	if _, err = fetchLatestSchemas(registry, []string{"missing"}); err == nil {
+		t.Error("fetchLatestSchemas() of a missing subject succeeded")
+	}
+}
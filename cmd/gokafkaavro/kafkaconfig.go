@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	defaultBrokers      = "localhost:9092"
+	saslPasswordEnvName = "GOKAFKAAVRO_SASL_PASSWORD"
+)
+
+// kafkaFlags are the flags which end up in the kafka.ConfigMap of the consumer or producer.
+type kafkaFlags struct {
+	brokers          string
+	securityProtocol string
+	saslMechanism    string
+	saslUsername     string
+	saslPassword     string
+	sslCALocation    string
+	config           stringsFlag
+}
+
+func (k *kafkaFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&k.brokers, "brokers", "", "comma separated list of kafka brokers (default "+defaultBrokers+")")
+	fs.StringVar(&k.securityProtocol, "security-protocol", "", "protocol used to communicate with brokers: plaintext, ssl, sasl_plaintext or sasl_ssl")
+	fs.StringVar(&k.saslMechanism, "sasl-mechanism", "", "SASL mechanism: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, ...")
+	fs.StringVar(&k.saslUsername, "sasl-username", "", "SASL username")
+	fs.StringVar(&k.saslPassword, "sasl-password", "", "SASL password (prefer the "+saslPasswordEnvName+" environment variable to keep it off the command line)")
+	fs.StringVar(&k.sslCALocation, "ssl-ca-location", "", "file with the CA certificate(s) to verify the brokers")
+	fs.Var(&k.config, "kafka-config", "librdkafka configuration property key=value (repeatable), see https://github.com/edenhill/librdkafka/blob/master/CONFIGURATION.md")
+}
+
+// configMap merges the defaults of a command, the --kafka-config properties and the specific flags,
+// in increasing order of precedence. Properties of --kafka-config overridden by a specific flag are
+// reported on warnOut.
+func (k kafkaFlags) configMap(defaults kafka.ConfigMap, warnOut io.Writer) (configMap *kafka.ConfigMap, err error) {
+
+	configMap = &kafka.ConfigMap{}
+	for key, value := range defaults {
+		(*configMap)[key] = value
+	}
+
+	generic := make(map[string]string)
+	for _, property := range k.config {
+		i := strings.Index(property, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("--kafka-config %q is not of the form key=value", property)
+		}
+		key, value := strings.TrimSpace(property[:i]), property[i+1:]
+		generic[key] = value
+		(*configMap)[key] = value
+	}
+
+	if _, found := generic["bootstrap.servers"]; !found && k.brokers == "" {
+		(*configMap)["bootstrap.servers"] = defaultBrokers
+	}
+
+	saslPassword := k.saslPassword
+	if saslPassword == "" {
+		saslPassword = os.Getenv(saslPasswordEnvName)
+	}
+
+	specific := []struct {
+		flag  string
+		value string
+		keys  []string
+	}{
+		{"--brokers", k.brokers, []string{"bootstrap.servers", "metadata.broker.list"}},
+		{"--security-protocol", k.securityProtocol, []string{"security.protocol"}},
+		{"--sasl-mechanism", k.saslMechanism, []string{"sasl.mechanisms", "sasl.mechanism"}},
+		{"--sasl-username", k.saslUsername, []string{"sasl.username"}},
+		{"--sasl-password", saslPassword, []string{"sasl.password"}},
+		{"--ssl-ca-location", k.sslCALocation, []string{"ssl.ca.location"}},
+	}
+
+	var warnings []string
+	for _, s := range specific {
+		if s.value == "" {
+			continue
+		}
+		for _, key := range s.keys {
+			if value, found := generic[key]; found {
+				if value != s.value {
+					if key == "sasl.password" {
+						value = "***"
+					}
+					warnings = append(warnings, fmt.Sprintf("Warning: --kafka-config %v=%v is overridden by %v", key, value, s.flag))
+				}
+				delete(*configMap, key)
+			}
+		}
+		(*configMap)[s.keys[0]] = s.value
+	}
+
+	sort.Strings(warnings)
+	for _, warning := range warnings {
+		fmt.Fprintln(warnOut, warning)
+	}
+	return
+}
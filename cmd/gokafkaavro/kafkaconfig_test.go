@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestKafkaConfigMap(t *testing.T) {
+
+	var tests = []struct {
+		flags    kafkaFlags
+		defaults kafka.ConfigMap
+		want     kafka.ConfigMap
+		warnings int
+	}{
+		{
+			kafkaFlags{},
+			kafka.ConfigMap{"group.id": "g"},
+			kafka.ConfigMap{"group.id": "g", "bootstrap.servers": defaultBrokers},
+			0,
+		},
+		{
+			kafkaFlags{config: stringsFlag{"bootstrap.servers=broker:9092", "group.id=other"}},
+			kafka.ConfigMap{"group.id": "g"},
+			kafka.ConfigMap{"group.id": "other", "bootstrap.servers": "broker:9092"},
+			0,
+		},
+		{
+			kafkaFlags{
+				brokers:          "broker:9093",
+				securityProtocol: "sasl_ssl",
+				saslMechanism:    "SCRAM-SHA-512",
+				saslUsername:     "user",
+				saslPassword:     "secret",
+				sslCALocation:    "/etc/ca.pem",
+			},
+			kafka.ConfigMap{},
+			kafka.ConfigMap{
+				"bootstrap.servers": "broker:9093",
+				"security.protocol": "sasl_ssl",
+				"sasl.mechanisms":   "SCRAM-SHA-512",
+				"sasl.username":     "user",
+				"sasl.password":     "secret",
+				"ssl.ca.location":   "/etc/ca.pem",
+			},
+			0,
+		},
+		{
+			kafkaFlags{
+				saslMechanism: "SCRAM-SHA-512",
+				saslUsername:  "user",
+				config:        stringsFlag{"sasl.mechanism=PLAIN", "sasl.username=user", "client.id=cli"},
+			},
+			kafka.ConfigMap{},
+			kafka.ConfigMap{
+				"bootstrap.servers": defaultBrokers,
+				"sasl.mechanisms":   "SCRAM-SHA-512",
+				"sasl.username":     "user",
+				"client.id":         "cli",
+			},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		var warnings bytes.Buffer
+		got, err := test.flags.configMap(test.defaults, &warnings)
+		if err != nil {
+			t.Fatalf("configMap(%+v) returned error %v", test.flags, err)
+		}
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("configMap(%+v) returned %v, want %v", test.flags, *got, test.want)
+		}
+		if got := strings.Count(warnings.String(), "\n"); got != test.warnings {
+			t.Errorf("configMap(%+v) warned %q, want %d warnings", test.flags, warnings.String(), test.warnings)
+		}
+	}
+}
+
+func TestKafkaConfigMapPasswordFromEnvironment(t *testing.T) {
+
+	t.Setenv(saslPasswordEnvName, "from-env")
+
+	got, _ := kafkaFlags{}.configMap(kafka.ConfigMap{}, &bytes.Buffer{})
+	if (*got)["sasl.password"] != "from-env" {
+		t.Errorf("sasl.password is %v, want it from the environment", (*got)["sasl.password"])
+	}
+
+	got, _ = kafkaFlags{saslPassword: "from-flag"}.configMap(kafka.ConfigMap{}, &bytes.Buffer{})
+	if (*got)["sasl.password"] != "from-flag" {
+		t.Errorf("sasl.password is %v, want it from the flag", (*got)["sasl.password"])
+	}
+}
+
+func TestKafkaConfigMapInvalidProperty(t *testing.T) {
+	if _, err := (kafkaFlags{config: stringsFlag{"no-value"}}).configMap(kafka.ConfigMap{}, &bytes.Buffer{}); err == nil {
+		t.Errorf("invalid --kafka-config did not fail")
+	}
+}
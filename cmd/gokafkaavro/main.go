@@ -0,0 +1,102 @@
+// Command gokafkaavro consumes and produces Kafka Avro data.
+//
+// Usage:
+//
+//	gokafkaavro consume --topic orders
+//	gokafkaavro produce --topic orders --schema-file orders.avsc < orders.ndjson
+//	gokafkaavro gen --topic orders --codec --out orders.go
+//	gokafkaavro migrate --source-topic orders --dest-topic orders-v2
+//	gokafkaavro serve --listen :8080
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/timvw/kafkaavro"
+)
+
+const usage = `Usage: gokafkaavro <command> [flags]
+
+Commands:
+  consume    decode messages from a topic and print them as NDJSON
+  produce    encode NDJSON read from stdin and produce it to a topic
+  schema     inspect the subjects and schemas in the schema registry
+  config     print the effective configuration of a profile of the config file
+  gen        generate Go types for the schemas of subjects or .avsc files
+  migrate    re-encode the messages of a topic with another schema version into another topic
+  serve      decode and encode messages over HTTP, for the clients without a schema registry client
+
+Run 'gokafkaavro <command> --help' for the flags of a command.
+`
+
+type commonFlags struct {
+	kafka    kafkaFlags
+	registry registryFlags
+	config   configFlags
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	c.kafka.register(fs)
+	c.registry.register(fs)
+	c.config.register(fs)
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// the command owns the process, a corrupt block count must not take it down
+	kafkaavro.SetMaxCollectionSize(kafkaavro.DefaultMaxCollectionSize)
+
+	var err error
+
+	switch os.Args[1] {
+	case "consume":
+		err = runConsume(os.Args[2:])
+	case "produce":
+		err = runProduce(os.Args[2:])
+	case "schema":
+		err = runSchema(os.Args[2:])
+	case "config":
+		err = runConfig(os.Args[2:])
+	case "gen":
+		err = runGen(os.Args[2:], os.Stdout)
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "serve":
+		err = runServe(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err == flag.ErrHelp {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+type migrateFlags struct {
+	common        commonFlags
+	sourceTopic   string
+	destTopic     string
+	targetSubject string
+	targetVersion string
+	group         string
+	dryRun        bool
+	// preserveTimestamps produces the messages with the timestamps of the source messages
+	preserveTimestamps bool
+}
+
+// The provenance headers of the migrated messages, the offset of the source message and the id of
+// the schema it was written with.
+const (
+	migratedFromOffsetHeader = "x-migrated-from-offset"
+	originalSchemaIDHeader   = "x-original-schema-id"
+)
+
+func runMigrate(args []string) (err error) {
+
+	var f migrateFlags
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	f.common.register(fs)
+	fs.StringVar(&f.sourceTopic, "source-topic", "", "topic to read the messages from, up to its current end (required)")
+	fs.StringVar(&f.destTopic, "dest-topic", "", "topic to produce the migrated messages to (required)")
+	fs.StringVar(&f.targetSubject, "target-subject", "", "subject of the target schema (default the value subject of the --dest-topic)")
+	fs.StringVar(&f.targetVersion, "target-subject-version", "latest", "version of the target subject to migrate the values to, or latest")
+	fs.StringVar(&f.group, "group", "gokafkaavro-migrate", "consumer group id, no offsets are committed")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "migrate the values without producing them, to find the messages which do not migrate")
+	fs.BoolVar(&f.preserveTimestamps, "preserve-timestamps", true, "produce the messages with the timestamps of the source messages as their CreateTime, rather than the time of the migration")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = f.common.config.apply(fs); err != nil {
+		return
+	}
+	if err = f.validate(); err != nil {
+		return
+	}
+
+	client, err := f.common.registry.newClient()
+	if err != nil {
+		return
+	}
+	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+	defer codec.Close()
+
+	target, err := f.target(client, codec)
+	if err != nil {
+		return
+	}
+	m, err := newMigrator(codec, f.sourceTopic, f.destTopic, target, f.preserveTimestamps)
+	if err != nil {
+		return
+	}
+
+	consumerConfig, err := f.common.kafka.configMap(kafka.ConfigMap{
+		"group.id":             f.group,
+		"enable.auto.commit":   false,
+		"enable.partition.eof": true,
+	}, os.Stderr)
+	if err != nil {
+		return
+	}
+	consumer, err := kafka.NewConsumer(consumerConfig)
+	if err != nil {
+		return
+	}
+	defer consumer.Close()
+
+	ranges, err := rangeFlags{}.resolve(consumer, f.sourceTopic)
+	if err != nil {
+		return
+	}
+	tracker := newRangeTracker(f.sourceTopic, ranges, time.Now())
+
+	var producer *kafka.Producer
+	var failedDeliveries int64
+	deliveryChan := make(chan kafka.Event, 1000)
+	if !f.dryRun {
+		producerConfig, configErr := f.common.kafka.configMap(kafka.ConfigMap{}, io.Discard)
+		if configErr != nil {
+			return configErr
+		}
+		if producer, err = kafka.NewProducer(producerConfig); err != nil {
+			return
+		}
+		defer producer.Close()
+		go func() {
+			for e := range deliveryChan {
+				if delivered, ok := e.(*kafka.Message); ok && delivered.TopicPartition.Error != nil {
+					atomic.AddInt64(&failedDeliveries, 1)
+					fmt.Fprintf(os.Stderr, "Delivery to %v failed: %v\n", f.destTopic, delivered.TopicPartition.Error)
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		if producer != nil {
+			if remaining := producer.Flush(flushTimeoutMs); remaining > 0 && err == nil {
+				err = fmt.Errorf("%d messages were not delivered to %v", remaining, f.destTopic)
+			}
+			if failed := atomic.LoadInt64(&failedDeliveries); failed > 0 && err == nil {
+				err = fmt.Errorf("%d messages failed to be delivered to %v", failed, f.destTopic)
+			}
+		}
+		m.summary(f.dryRun, tracker.done(), os.Stderr)
+		if migrateErr := m.err(); migrateErr != nil && err == nil {
+			err = fmt.Errorf("messages could not be migrated: %w", migrateErr)
+		}
+	}()
+
+	if tracker.done() {
+		return
+	}
+	if err = consumer.Assign(tracker.assignment()); err != nil {
+		return
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	for !tracker.done() {
+		select {
+
+		case sig := <-sigchan:
+			fmt.Fprintf(os.Stderr, "Caught signal %v: terminating\n", sig)
+			return
+
+		default:
+			tracker.progress(time.Now(), time.Second, os.Stderr)
+
+			switch e := consumer.Poll(100).(type) {
+
+			case *kafka.Message:
+				if !tracker.contains(e) {
+					tracker.reached(e.TopicPartition.Partition, int64(e.TopicPartition.Offset), os.Stderr)
+					continue
+				}
+				migrated, migrateErr := m.migrate(e, os.Stderr)
+				if migrateErr == nil && producer != nil {
+					if err = producer.Produce(migrated, deliveryChan); err != nil {
+						return fmt.Errorf("failed to produce to %v: %w", f.destTopic, err)
+					}
+				}
+				tracker.handled(e, io.Discard)
+
+			case kafka.PartitionEOF:
+				tracker.reached(e.Partition, int64(e.Offset), io.Discard)
+
+			case kafka.Error:
+				fmt.Fprintf(os.Stderr, "%% Error: %v: %v\n", e.Code(), e)
+				if e.Code() == kafka.ErrAllBrokersDown {
+					return e
+				}
+			}
+		}
+	}
+	return
+}
+
+func (f migrateFlags) validate() error {
+	switch {
+	case f.sourceTopic == "":
+		return errors.New("--source-topic is required")
+	case f.destTopic == "":
+		return errors.New("--dest-topic is required")
+	case f.sourceTopic == f.destTopic:
+		return errors.New("--source-topic and --dest-topic must be different topics")
+	}
+	if f.targetVersion != "latest" {
+		if version, err := strconv.Atoi(f.targetVersion); err != nil || version < 1 {
+			return fmt.Errorf("--target-subject-version %q is not a version or latest", f.targetVersion)
+		}
+	}
+	return nil
+}
+
+// target fetches the schema to migrate the values to.
+func (f migrateFlags) target(client *schemaregistry.Client, codec *kafkaavro.Codec) (target kafkaavro.SchemaInfo, err error) {
+
+	subject := f.targetSubject
+	if subject == "" {
+		subject = codec.Subject(f.destTopic, false)
+	}
+
+	var schema schemaregistry.Schema
+	if f.targetVersion == "latest" {
+		schema, err = client.GetLatestSchema(subject)
+	} else {
+		version, _ := strconv.Atoi(f.targetVersion)
+		schema, err = client.GetSchemaBySubject(subject, version)
+	}
+	if err != nil {
+		return target, fmt.Errorf("failed to fetch version %v of subject %v: %w", f.targetVersion, subject, err)
+	}
+	if schema.Type() != schemaregistry.SchemaTypeAvro {
+		return target, fmt.Errorf("version %v of subject %v is a %v schema, only avro schemas can be migrated", f.targetVersion, subject, schema.Type())
+	}
+	return kafkaavro.SchemaInfo{ID: schema.ID, Subject: subject, Version: schema.Version, Schema: schema.Schema, SchemaType: schema.Type()}, nil
+}
+
+// maxMigrateErrors is the number of the errors of the messages which failed which a migration
+// keeps, the other messages which fail are only counted.
+const maxMigrateErrors = 100
+
+// migrator re-encodes the values of the messages of the source topic with the target schema.
+type migrator struct {
+	codec              *kafkaavro.Codec
+	sourceTopic        string
+	destTopic          string
+	target             kafkaavro.SchemaInfo
+	preserveTimestamps bool
+	reader             *kafkaavro.ReaderSchema
+	partitions         map[int32]*migrateCounts
+	// errors are the errors of the first messages which failed, by the index of the message in the
+	// run, see maxMigrateErrors
+	errors kafkaavro.BatchError
+}
+
+// migrateCounts are the messages of a partition which were migrated, copied as they were already
+// written with the target schema (or were tombstones) and failed.
+type migrateCounts struct {
+	migrated, copied, failed int64
+}
+
+func newMigrator(codec *kafkaavro.Codec, sourceTopic string, destTopic string, target kafkaavro.SchemaInfo, preserveTimestamps bool) (m *migrator, err error) {
+
+	reader, err := kafkaavro.NewReaderSchema(target.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("the target schema %d: %w", target.ID, err)
+	}
+	return &migrator{codec: codec, sourceTopic: sourceTopic, destTopic: destTopic, target: target, preserveTimestamps: preserveTimestamps, reader: reader, partitions: make(map[int32]*migrateCounts)}, nil
+}
+
+// migrate returns the message for the destination topic of the template of the message, with the
+// value written with the target schema, see destination. A message which does not migrate is
+// reported on w.
+func (m *migrator) migrate(message *kafka.Message, w io.Writer) (migrated *kafka.Message, err error) {
+
+	partition := message.TopicPartition.Partition
+	counts, found := m.partitions[partition]
+	if !found {
+		counts = &migrateCounts{}
+		m.partitions[partition] = counts
+	}
+
+	index := m.errors.Size
+	m.errors.Size++
+	value, writerID, changed, err := m.migrateValue(message.Value)
+	if err != nil {
+		counts.failed++
+		if len(m.errors.Errors) < maxMigrateErrors {
+			m.errors.Add(index, fmt.Errorf("%v [%d] offset %v: %w", m.sourceTopic, partition, message.TopicPartition.Offset, err))
+		} else {
+			m.errors.Omitted++
+		}
+		fmt.Fprintf(w, "Failed to migrate %v [%d] offset %v: %v\n", m.sourceTopic, partition, message.TopicPartition.Offset, err)
+		return
+	}
+	if changed {
+		counts.migrated++
+	} else {
+		counts.copied++
+	}
+
+	return m.destination(message, value, writerID), nil
+}
+
+// destination returns the message for the destination topic with the value: the template of the
+// source message, with its key, headers and timestamp (unless the timestamps are not preserved),
+// and the provenance headers with its offset and the schema id of its value, 0 for a tombstone.
+// The provenance headers of a message which was migrated before are replaced.
+func (m *migrator) destination(source *kafka.Message, value []byte, writerID kafkaavro.SchemaID) *kafka.Message {
+
+	headers := make([]kafka.Header, 0, len(source.Headers)+2)
+	for _, header := range source.Headers {
+		if header.Key != migratedFromOffsetHeader && header.Key != originalSchemaIDHeader {
+			headers = append(headers, header)
+		}
+	}
+	headers = append(headers,
+		kafka.Header{Key: migratedFromOffsetHeader, Value: []byte(strconv.FormatInt(int64(source.TopicPartition.Offset), 10))},
+		kafka.Header{Key: originalSchemaIDHeader, Value: []byte(strconv.Itoa(int(writerID)))})
+
+	destination := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &m.destTopic, Partition: kafka.PartitionAny},
+		Key:            source.Key,
+		Value:          value,
+		Headers:        headers,
+	}
+	if m.preserveTimestamps && !source.Timestamp.IsZero() {
+		// librdkafka produces a message with a timestamp with it as its CreateTime
+		destination.Timestamp = source.Timestamp
+		destination.TimestampType = kafka.TimestampCreateTime
+	}
+	return destination
+}
+
+// migrateValue returns the value written with the target schema and the schema id it was written
+// with.
+func (m *migrator) migrateValue(data []byte) (value []byte, writerID kafkaavro.SchemaID, changed bool, err error) {
+
+	if data == nil {
+		return nil, 0, false, nil
+	}
+	decoded, writer, err := m.codec.DecodeWithSchemaInfo(m.sourceTopic, false, data)
+	if err != nil {
+		return
+	}
+	if writer.ID == m.target.ID {
+		return data, writer.ID, false, nil
+	}
+	body, err := m.reader.Reencode(decoded, writer)
+	if err != nil {
+		return nil, writer.ID, false, fmt.Errorf("schema %d does not migrate to schema %d: %w", writer.ID, m.target.ID, err)
+	}
+	return m.codec.EncodeFramed(m.target.ID, body), writer.ID, true, nil
+}
+
+// err returns the *kafkaavro.BatchError of the messages which failed, nil if none did.
+func (m *migrator) err() error {
+	return m.errors.Err()
+}
+
+// summary prints the counts of every partition and of the topic.
+func (m *migrator) summary(dryRun bool, complete bool, w io.Writer) {
+
+	partitions := make([]int32, 0, len(m.partitions))
+	for partition := range m.partitions {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	var total migrateCounts
+	for _, partition := range partitions {
+		counts := m.partitions[partition]
+		fmt.Fprintf(w, "%v [%d]: %d migrated, %d copied, %d failed\n", m.sourceTopic, partition, counts.migrated, counts.copied, counts.failed)
+		total.migrated += counts.migrated
+		total.copied += counts.copied
+		total.failed += counts.failed
+	}
+
+	status := "Migrated"
+	if !complete {
+		status = "Interrupted after migrating"
+	}
+	destination := "to " + m.destTopic
+	if dryRun {
+		destination = "(dry run, nothing produced)"
+	}
+	fmt.Fprintf(w, "%v %d messages of %v to schema %d %v: %d re-encoded, %d copied, %d failed\n",
+		status, total.migrated+total.copied, m.sourceTopic, m.target.ID, destination, total.migrated, total.copied, total.failed)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestMigrateFlagsValidate(t *testing.T) {
+
+	var tests = []struct {
+		flags migrateFlags
+		want  string
+	}{
+		{migrateFlags{destTopic: "orders-v2", targetVersion: "latest"}, "--source-topic is required"},
+		{migrateFlags{sourceTopic: "orders", targetVersion: "latest"}, "--dest-topic is required"},
+		{migrateFlags{sourceTopic: "orders", destTopic: "orders", targetVersion: "latest"}, "must be different topics"},
+		{migrateFlags{sourceTopic: "orders", destTopic: "orders-v2", targetVersion: "two"}, "is not a version or latest"},
+		{migrateFlags{sourceTopic: "orders", destTopic: "orders-v2", targetVersion: "2"}, ""},
+		{migrateFlags{sourceTopic: "orders", destTopic: "orders-v2", targetVersion: "latest"}, ""},
+	}
+
+	for _, test := range tests {
+		err := test.flags.validate()
+		if (err == nil) != (test.want == "") || (err != nil && !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("validate(%+v) returned %v, want %q", test.flags, err, test.want)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+
+	registry := mockregistry.New()
+	v1 := registry.Register("orders-value", `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"note","type":"string"}]}`)
+	v2Schema := `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"status","type":"string","default":"NEW"}]}`
+	v2 := registry.Register("orders-v2-value", v2Schema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	m, err := newMigrator(codec, "orders", "orders-v2", kafkaavro.SchemaInfo{ID: v2, Schema: v2Schema}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := codec.EncodeWithSchemaID(v1, map[string]interface{}{"id": int32(1), "note": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := codec.EncodeWithSchemaID(v2, map[string]interface{}{"id": int64(2), "status": "SHIPPED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	message := testMessage(0, 10)
+	message.Key, message.Value = []byte("1"), old
+	message.Headers = []kafka.Header{{Key: "trace-id", Value: []byte("abc")}}
+	migrated, err := m.migrate(message, &out)
+	if err != nil {
+		t.Fatalf("migrate() failed: %v", err)
+	}
+	headers := []kafka.Header{message.Headers[0], {Key: "x-migrated-from-offset", Value: []byte("10")}, {Key: "x-original-schema-id", Value: []byte(strconv.Itoa(v1))}}
+	if *migrated.TopicPartition.Topic != "orders-v2" || string(migrated.Key) != "1" || !reflect.DeepEqual(migrated.Headers, headers) {
+		t.Errorf("migrate() returned %v", migrated)
+	}
+	native, err := codec.Decode("orders-v2", false, migrated.Value)
+	if want := map[string]interface{}{"id": int64(1), "status": "NEW"}; err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("the migrated value is %v, %v, want %v", native, err, want)
+	}
+
+	// the values of the target schema and tombstones are copied
+	for i, value := range [][]byte{current, nil} {
+		message := testMessage(1, kafka.Offset(i))
+		message.Value = value
+		if migrated, err = m.migrate(message, &out); err != nil || !bytes.Equal(migrated.Value, value) {
+			t.Errorf("migrate() of %x returned %x, %v", value, migrated.Value, err)
+		}
+	}
+
+	invalid := testMessage(1, 5)
+	invalid.Value = []byte("{}")
+	if _, err = m.migrate(invalid, &out); err == nil {
+		t.Error("migrate() of a value which is not in the wire format did not fail")
+	}
+	if !strings.Contains(out.String(), "Failed to migrate orders [1] offset 5: ") {
+		t.Errorf("migrate() reported %q", out.String())
+	}
+
+	out.Reset()
+	m.summary(true, true, &out)
+	want := "orders [0]: 1 migrated, 0 copied, 0 failed\n" +
+		"orders [1]: 0 migrated, 2 copied, 1 failed\n" +
+		"Migrated 3 messages of orders to schema 2 (dry run, nothing produced): 1 re-encoded, 2 copied, 1 failed\n"
+	if out.String() != want {
+		t.Errorf("summary() printed %q, want %q", out.String(), want)
+	}
+	var batchErr *kafkaavro.BatchError
+	if err := m.err(); !errors.As(err, &batchErr) || batchErr.Size != 4 || len(batchErr.Errors) != 1 || batchErr.Errors[0].Index != 3 || !errors.Is(err, kafkaavro.ErrPayloadTooShort) {
+		t.Errorf("err() returned %v", err)
+	} else if want := "1 of 4 items failed; item 3: orders [1] offset 5: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("err() returned %q, want it to start with %q", err, want)
+	}
+}
+
+func TestMigratorErrorsCapped(t *testing.T) {
+
+	registry := mockregistry.New()
+	schema := `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`
+	id := registry.Register("orders-v2-value", schema)
+	m, err := newMigrator(kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}), "orders", "orders-v2", kafkaavro.SchemaInfo{ID: id, Schema: schema}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxMigrateErrors+50; i++ {
+		invalid := testMessage(0, kafka.Offset(i))
+		invalid.Value = []byte("{}")
+		if _, err = m.migrate(invalid, io.Discard); err == nil {
+			t.Fatal("migrate() of a value which is not in the wire format did not fail")
+		}
+	}
+	if failed := m.partitions[0].failed; failed != maxMigrateErrors+50 {
+		t.Errorf("counted %d failed messages", failed)
+	}
+	var batchErr *kafkaavro.BatchError
+	if err := m.err(); !errors.As(err, &batchErr) || batchErr.Size != maxMigrateErrors+50 || len(batchErr.Errors) != maxMigrateErrors || batchErr.Omitted != 50 {
+		t.Errorf("err() returned %v", err)
+	} else if want := "(the errors of 50 items are omitted)"; !strings.HasSuffix(err.Error(), want) || !strings.HasPrefix(err.Error(), "150 of 150 items failed") {
+		t.Errorf("err() returned %q, want the number of the omitted errors", err)
+	}
+}
+
+func TestMigratorDestination(t *testing.T) {
+
+	registry := mockregistry.New()
+	schema := `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`
+	id := registry.Register("orders-v2-value", schema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	value, err := codec.EncodeWithSchemaID(id, map[string]interface{}{"id": int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a message migrated before, with a timestamp of more precision than the broker keeps
+	source := testMessage(2, 42)
+	source.Key, source.Value = []byte("1"), value
+	source.Timestamp = time.Date(2024, 3, 1, 12, 30, 15, 123456789, time.UTC)
+	source.TimestampType = kafka.TimestampLogAppendTime
+	source.Headers = []kafka.Header{{Key: "x-migrated-from-offset", Value: []byte("7")}, {Key: "trace-id", Value: []byte("abc")}}
+
+	for _, preserve := range []bool{true, false} {
+		m, err := newMigrator(codec, "orders", "orders-v2", kafkaavro.SchemaInfo{ID: id, Schema: schema}, preserve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrated, err := m.migrate(source, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []kafka.Header{{Key: "trace-id", Value: []byte("abc")}, {Key: "x-migrated-from-offset", Value: []byte("42")}, {Key: "x-original-schema-id", Value: []byte(strconv.Itoa(id))}}
+		if !reflect.DeepEqual(migrated.Headers, want) || string(migrated.Key) != "1" || !bytes.Equal(migrated.Value, value) {
+			t.Errorf("migrate() returned %v with the headers %v", migrated, migrated.Headers)
+		}
+
+		// the broker keeps the timestamps in milliseconds
+		if !preserve {
+			if !migrated.Timestamp.IsZero() {
+				t.Errorf("migrate() without preserving the timestamps returned the timestamp %v", migrated.Timestamp)
+			}
+			continue
+		}
+		if !migrated.Timestamp.Truncate(time.Millisecond).Equal(source.Timestamp.Truncate(time.Millisecond)) || migrated.TimestampType != kafka.TimestampCreateTime {
+			t.Errorf("migrate() returned the timestamp %v (%v), want %v", migrated.Timestamp, migrated.TimestampType, source.Timestamp)
+		}
+	}
+
+	// a tombstone has no schema id
+	m, _ := newMigrator(codec, "orders", "orders-v2", kafkaavro.SchemaInfo{ID: id, Schema: schema}, true)
+	migrated, err := m.migrate(testMessage(0, 1), io.Discard)
+	if err != nil || string(migrated.Headers[1].Value) != "0" || migrated.Value != nil {
+		t.Errorf("migrate() of a tombstone returned %v, %v", migrated, err)
+	}
+}
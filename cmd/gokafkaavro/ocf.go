@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/timvw/kafkaavro"
+)
+
+const ocfBlockSize = 100
+
+const (
+	schemaChangeFail  = "fail"
+	schemaChangeSplit = "split"
+)
+
+// ocfOutput writes decoded records to avro object container files.
+// All records in a file share the writer schema of the first record written to it.
+type ocfOutput struct {
+	path           string
+	compression    string
+	onSchemaChange string
+
+	file     *os.File
+	writer   *kafkaavro.OCFWriter
+	schemaID int
+	files    []string
+}
+
+func newOCFOutput(path string, compression string, onSchemaChange string) (output *ocfOutput, err error) {
+
+	switch kafkaavro.CompressionCodec(compression) {
+	case kafkaavro.CompressionNull, kafkaavro.CompressionDeflate, kafkaavro.CompressionSnappy:
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %q, use null, deflate or snappy", compression)
+	}
+
+	switch onSchemaChange {
+	case schemaChangeFail, schemaChangeSplit:
+	default:
+		return nil, fmt.Errorf("unsupported --on-schema-change %q, use fail or split", onSchemaChange)
+	}
+
+	output = &ocfOutput{path: path, compression: compression, onSchemaChange: onSchemaChange}
+	return
+}
+
+func (o *ocfOutput) Write(schemaID int, avroSchema string, native interface{}) (err error) {
+
+	if o.writer != nil {
+		err = o.writer.AppendDecoded(kafkaavro.DecodedMessage{Value: native, ValueSchema: kafkaavro.SchemaInfo{ID: schemaID, Schema: avroSchema}})
+		if !errors.Is(err, kafkaavro.ErrSchemaChanged) {
+			return
+		}
+		if o.onSchemaChange != schemaChangeSplit {
+			return fmt.Errorf("schema changed from id %d to %d while writing %v, use --on-schema-change split to roll to a new file",
+				o.schemaID, schemaID, o.file.Name())
+		}
+		if err = o.closeFile(); err != nil {
+			return
+		}
+	}
+
+	if err = o.openFile(schemaID, avroSchema); err != nil {
+		return
+	}
+	return o.writer.Append(native)
+}
+
+func (o *ocfOutput) Close() error {
+	if o.writer == nil {
+		return nil
+	}
+	return o.closeFile()
+}
+
+func (o *ocfOutput) openFile(schemaID int, avroSchema string) (err error) {
+
+	path := o.path
+	if len(o.files) > 0 {
+		extension := filepath.Ext(o.path)
+		path = fmt.Sprintf("%v-%d%v", strings.TrimSuffix(o.path, extension), len(o.files), extension)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+
+	writer, err := kafkaavro.NewOCFWriter(file, avroSchema, kafkaavro.CompressionCodec(o.compression), kafkaavro.WithOCFBlockSize(ocfBlockSize))
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	o.file = file
+	o.writer = writer
+	o.schemaID = schemaID
+	o.files = append(o.files, path)
+	return
+}
+
+func (o *ocfOutput) closeFile() (err error) {
+
+	// closes the file too
+	err = o.writer.Close()
+
+	o.file = nil
+	o.writer = nil
+	return
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+const testSchemaV2 = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"},{"name":"f2","type":"string","default":""}]}`
+
+func readOCF(t *testing.T, path string) (records []interface{}) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := goavro.NewOCFReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for reader.Scan() {
+		record, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+func TestOCFOutputWritesAllRecords(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "dump.avro")
+
+	output, err := newOCFOutput(path, "deflate", schemaChangeFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < ocfBlockSize+5; i++ {
+		if err := output.Write(1, testSchema, map[string]interface{}{"f1": "value"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(readOCF(t, path)); got != ocfBlockSize+5 {
+		t.Errorf("read %d records, want %d", got, ocfBlockSize+5)
+	}
+}
+
+func TestOCFOutputSchemaChange(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "dump.avro")
+
+	output, _ := newOCFOutput(path, "null", schemaChangeFail)
+	if err := output.Write(1, testSchema, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.Write(1, testSchema, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.Write(2, testSchemaV2, map[string]interface{}{"f1": "value", "f2": ""}); err == nil {
+		t.Errorf("schema change did not fail")
+	}
+	output.Close()
+
+	output, _ = newOCFOutput(path, "null", schemaChangeSplit)
+	output.Write(1, testSchema, map[string]interface{}{"f1": "value"})
+	output.Write(2, testSchemaV2, map[string]interface{}{"f1": "value", "f2": ""})
+	output.Write(2, testSchemaV2, map[string]interface{}{"f1": "value", "f2": ""})
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{path, filepath.Join(filepath.Dir(path), "dump-1.avro")}
+	if len(output.files) != len(want) || output.files[0] != want[0] || output.files[1] != want[1] {
+		t.Fatalf("wrote %v, want %v", output.files, want)
+	}
+	if got := len(readOCF(t, want[1])); got != 2 {
+		t.Errorf("read %d records from %v, want 2", got, want[1])
+	}
+}
+
+func TestNewOCFOutputValidatesFlags(t *testing.T) {
+	if _, err := newOCFOutput("dump.avro", "zstd", schemaChangeFail); err == nil {
+		t.Errorf("unsupported compression did not fail")
+	}
+	if _, err := newOCFOutput("dump.avro", "null", "ignore"); err == nil {
+		t.Errorf("unsupported on-schema-change did not fail")
+	}
+}
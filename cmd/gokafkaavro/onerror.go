@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro/confluent"
+)
+
+const (
+	onErrorSkip = "skip"
+	onErrorFail = "fail"
+	onErrorDLQ  = "dlq"
+)
+
+const hexPreviewSize = 16
+
+// decodeFailure describes a message which could not be decoded, with enough context to find it back.
+type decodeFailure struct {
+	message *kafka.Message
+	err     error
+}
+
+func (d decodeFailure) Error() string {
+	return fmt.Sprintf("failed to decode message on %v [%d] offset %v: %v (%v)",
+		*d.message.TopicPartition.Topic, d.message.TopicPartition.Partition, d.message.TopicPartition.Offset, d.err, hexPreview(d.message.Value))
+}
+
+// hexPreview describes the first bytes of a payload, which usually tells why it could not be decoded.
+func hexPreview(data []byte) string {
+	if len(data) <= hexPreviewSize {
+		return fmt.Sprintf("%d bytes: %v", len(data), hex.EncodeToString(data))
+	}
+	return fmt.Sprintf("%d bytes: %v...", len(data), hex.EncodeToString(data[:hexPreviewSize]))
+}
+
+// dlqProducer is the part of the kafka.Producer used to produce to the dead letter queue.
+type dlqProducer interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Flush(timeoutMs int) int
+}
+
+// errorHandler applies the --on-error policy to messages which could not be decoded.
+type errorHandler struct {
+	policy       string
+	dlqTopic     string
+	producer     dlqProducer
+	dlq          *confluent.DLQProducer
+	deliveryChan chan kafka.Event
+	skipped      int64
+	deadLettered int64
+	dlqFailed    int64
+}
+
+func newErrorHandler(policy string, dlqTopic string) (h *errorHandler, err error) {
+
+	switch policy {
+	case onErrorSkip, onErrorFail:
+		if dlqTopic != "" {
+			return nil, fmt.Errorf("--dlq-topic can only be used with --on-error %v", onErrorDLQ)
+		}
+	case onErrorDLQ:
+		if dlqTopic == "" {
+			return nil, fmt.Errorf("--on-error %v requires --dlq-topic", onErrorDLQ)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported --on-error %q, use skip, fail or dlq", policy)
+	}
+
+	h = &errorHandler{policy: policy, dlqTopic: dlqTopic}
+	return
+}
+
+// useProducer sets the producer for the dead letter queue, and reports its delivery failures on errOut.
+func (h *errorHandler) useProducer(producer dlqProducer, errOut io.Writer) {
+
+	h.producer = producer
+	h.dlq = confluent.NewDLQProducer(producer, h.dlqTopic)
+	h.deliveryChan = make(chan kafka.Event, 100)
+
+	go func() {
+		for e := range h.deliveryChan {
+			if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+				atomic.AddInt64(&h.dlqFailed, 1)
+				fmt.Fprintf(errOut, "Failed to deliver message to %v: %v\n", h.dlqTopic, m.TopicPartition.Error)
+			}
+		}
+	}()
+}
+
+// handle returns an error when the consumer has to stop.
+func (h *errorHandler) handle(m *kafka.Message, decodeErr error, errOut io.Writer) error {
+
+	failure := decodeFailure{m, decodeErr}
+
+	switch h.policy {
+
+	case onErrorFail:
+		return failure
+
+	case onErrorDLQ:
+		if err := h.dlq.Send(m, decodeErr, h.deliveryChan); err != nil {
+			return err
+		}
+		h.deadLettered++
+		fmt.Fprintf(errOut, "%v, sent to %v\n", failure, h.dlq.Topic(*m.TopicPartition.Topic))
+
+	default:
+		h.skipped++
+		fmt.Fprintf(errOut, "%v, skipped\n", failure)
+	}
+
+	return nil
+}
+
+// close waits until the messages sent to the dead letter queue are delivered.
+func (h *errorHandler) close() error {
+	if h.producer == nil {
+		return nil
+	}
+	if remaining := h.producer.Flush(flushTimeoutMs); remaining > 0 {
+		return fmt.Errorf("%d messages were not delivered to dead letter queue %v", remaining, h.dlqTopic)
+	}
+	if failed := atomic.LoadInt64(&h.dlqFailed); failed > 0 {
+		return fmt.Errorf("%d messages failed to be delivered to dead letter queue %v", failed, h.dlqTopic)
+	}
+	return nil
+}
+
+func (h *errorHandler) summary(w io.Writer) {
+	switch {
+	case h.skipped > 0:
+		fmt.Fprintf(w, "Skipped %d messages which could not be decoded\n", h.skipped)
+	case h.deadLettered > 0:
+		fmt.Fprintf(w, "Sent %d messages which could not be decoded to %v\n", h.deadLettered, h.dlqTopic)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+type fakeDLQProducer struct {
+	produced []*kafka.Message
+}
+
+func (f *fakeDLQProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	f.produced = append(f.produced, msg)
+	return nil
+}
+
+func (f *fakeDLQProducer) Flush(timeoutMs int) int {
+	return 0
+}
+
+func TestHexPreview(t *testing.T) {
+
+	var tests = []struct {
+		data []byte
+		want string
+	}{
+		{[]byte{}, "0 bytes: "},
+		{[]byte("{\"a\":1}"), "7 bytes: 7b2261223a317d"},
+		{append([]byte{0, 0, 0, 0, 1}, make([]byte, 20)...), "25 bytes: 00000000010000000000000000000000..."},
+	}
+
+	for _, test := range tests {
+		if got := hexPreview(test.data); got != test.want {
+			t.Errorf("hexPreview(%v) returned %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+func TestNewErrorHandlerValidatesFlags(t *testing.T) {
+
+	var tests = []struct {
+		policy   string
+		dlqTopic string
+		valid    bool
+	}{
+		{onErrorSkip, "", true},
+		{onErrorFail, "", true},
+		{onErrorDLQ, "orders.dlq", true},
+		{onErrorDLQ, "", false},
+		{onErrorSkip, "orders.dlq", false},
+		{"ignore", "", false},
+	}
+
+	for _, test := range tests {
+		if _, err := newErrorHandler(test.policy, test.dlqTopic); (err == nil) != test.valid {
+			t.Errorf("newErrorHandler(%v, %v) returned %v, want valid %v", test.policy, test.dlqTopic, err, test.valid)
+		}
+	}
+}
+
+func TestErrorHandlerPolicies(t *testing.T) {
+
+	message := testMessage(3, 42)
+	message.Value = []byte("{}")
+	decodeErr := errors.New("Unknown magic byte")
+
+	var errOut, summary bytes.Buffer
+
+	skip, _ := newErrorHandler(onErrorSkip, "")
+	if err := skip.handle(message, decodeErr, &errOut); err != nil {
+		t.Errorf("skip returned %v", err)
+	}
+	skip.summary(&summary)
+	if summary.String() != "Skipped 1 messages which could not be decoded\n" {
+		t.Errorf("skip summary is %q", summary.String())
+	}
+
+	fail, _ := newErrorHandler(onErrorFail, "")
+	err := fail.handle(message, decodeErr, &errOut)
+	if err == nil {
+		t.Fatal("fail did not return an error")
+	}
+	for _, context := range []string{"orders", "[3]", "offset 42", "Unknown magic byte", "2 bytes: 7b7d"} {
+		if !strings.Contains(err.Error(), context) {
+			t.Errorf("fail error %q does not contain %q", err, context)
+		}
+	}
+
+	producer := &fakeDLQProducer{}
+	dlq, _ := newErrorHandler(onErrorDLQ, "orders.dlq")
+	dlq.useProducer(producer, &errOut)
+	if err := dlq.handle(message, decodeErr, &errOut); err != nil {
+		t.Errorf("dlq returned %v", err)
+	}
+	if err := dlq.close(); err != nil {
+		t.Errorf("dlq close returned %v", err)
+	}
+	if len(producer.produced) != 1 || *producer.produced[0].TopicPartition.Topic != "orders.dlq" || string(producer.produced[0].Value) != "{}" {
+		t.Errorf("dlq produced %v", producer.produced)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+const (
+	maxLineSize    = 10 * 1024 * 1024
+	flushTimeoutMs = 15 * 1000
+)
+
+type produceFlags struct {
+	common          commonFlags
+	topic           string
+	schemaFile      string
+	useLatestSchema bool
+	keyField        string
+	key             string
+	autoRegister    bool
+	strict          bool
+	jsonMode        string
+}
+
+func runProduce(args []string) (err error) {
+
+	var f produceFlags
+
+	fs := flag.NewFlagSet("produce", flag.ContinueOnError)
+	f.common.register(fs)
+	fs.StringVar(&f.topic, "topic", "", "topic to produce to (required)")
+	fs.StringVar(&f.schemaFile, "schema-file", "", "file containing the avro schema of the values")
+	fs.BoolVar(&f.useLatestSchema, "use-latest-schema", false, "encode with the latest schema registered for the value subject")
+	fs.StringVar(&f.keyField, "key-field", "", "use the value of this (dot separated) json field as message key")
+	fs.StringVar(&f.key, "key", "", "use this literal as message key")
+	fs.BoolVar(&f.autoRegister, "auto-register", false, "register the schema from --schema-file if it is not registered yet")
+	fs.BoolVar(&f.strict, "strict", false, "abort on the first invalid input line instead of skipping it")
+	fs.StringVar(&f.jsonMode, "json-mode", string(kafkaavro.JSONModeAvro), "JSON of the input lines: avro (unions as {\"type\": value}) or standard (unions as the bare value)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = f.common.config.apply(fs); err != nil {
+		return
+	}
+
+	if err = f.validate(); err != nil {
+		return
+	}
+	jsonMode, err := kafkaavro.ParseJSONMode(f.jsonMode)
+	if err != nil {
+		return fmt.Errorf("--json-mode: %w", err)
+	}
+
+	client, err := f.common.registry.newClient()
+	if err != nil {
+		return
+	}
+
+	subjectNameStrategy := kafkaavro.TopicNameStrategy{}
+	subjectName := subjectNameStrategy.GetSubjectName(f.topic, false)
+
+	var avroSchema kafkaavro.AvroSchema
+	if f.useLatestSchema {
+		schema, clientErr := client.GetLatestSchema(subjectName)
+		if clientErr != nil {
+			return fmt.Errorf("failed to fetch latest schema for subject %v: %v", subjectName, clientErr)
+		}
+		avroSchema = schema.Schema
+	} else {
+		schemaBytes, readErr := os.ReadFile(f.schemaFile)
+		if readErr != nil {
+			return readErr
+		}
+		avroSchema = string(schemaBytes)
+	}
+
+	encoder, err := kafkaavro.NewEncoder(*client, f.autoRegister, subjectName, avroSchema, kafkaavro.WithJSONMode(jsonMode))
+	if err != nil {
+		return fmt.Errorf("failed to create encoder: %v", err)
+	}
+
+	kafkaConfig, err := f.common.kafka.configMap(kafka.ConfigMap{}, os.Stderr)
+	if err != nil {
+		return
+	}
+
+	producer, err := kafka.NewProducer(kafkaConfig)
+	if err != nil {
+		return
+	}
+	defer producer.Close()
+
+	return produceLines(os.Stdin, producer, encoder, f)
+}
+
+func (f produceFlags) validate() error {
+	if f.topic == "" {
+		return errors.New("--topic is required")
+	}
+	if f.schemaFile == "" && !f.useLatestSchema {
+		return errors.New("one of --schema-file or --use-latest-schema is required")
+	}
+	if f.schemaFile != "" && f.useLatestSchema {
+		return errors.New("--schema-file and --use-latest-schema are mutually exclusive")
+	}
+	if f.useLatestSchema && f.autoRegister {
+		return errors.New("--auto-register can only be used with --schema-file")
+	}
+	if f.keyField != "" && f.key != "" {
+		return errors.New("--key-field and --key are mutually exclusive")
+	}
+	return nil
+}
+
+func produceLines(r io.Reader, producer *kafka.Producer, encoder kafkaavro.Encoder, f produceFlags) (err error) {
+
+	var pending sync.WaitGroup
+	var produced, skipped, failed int64
+
+	// the delivery channel is never closed: when the flush times out librdkafka
+	// may still report deliveries after we returned.
+	deliveryChan := make(chan kafka.Event, 1000)
+
+	go func() {
+		for e := range deliveryChan {
+			m, ok := e.(*kafka.Message)
+			if !ok {
+				continue
+			}
+			if m.TopicPartition.Error != nil {
+				atomic.AddInt64(&failed, 1)
+				fmt.Fprintf(os.Stderr, "Delivery failed: %v\n", m.TopicPartition.Error)
+			}
+			pending.Done()
+		}
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		message, lineErr := f.message(line, encoder)
+		if lineErr == nil {
+			pending.Add(1)
+			lineErr = produce(producer, message, deliveryChan)
+			if lineErr != nil {
+				pending.Done()
+			}
+		}
+
+		if lineErr != nil {
+			if f.strict {
+				err = fmt.Errorf("line %d: %v", lineNumber, lineErr)
+				break
+			}
+			skipped++
+			fmt.Fprintf(os.Stderr, "Skipping line %d: %v\n", lineNumber, lineErr)
+			continue
+		}
+
+		produced++
+	}
+
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	if remaining := producer.Flush(flushTimeoutMs); remaining > 0 {
+		fmt.Fprintf(os.Stderr, "%d messages were not delivered within %dms\n", remaining, flushTimeoutMs)
+		if err == nil {
+			err = fmt.Errorf("%d messages were not delivered", remaining)
+		}
+		return
+	}
+	pending.Wait()
+
+	fmt.Fprintf(os.Stderr, "Produced %d messages, %d failed, %d lines skipped\n", produced, failed, skipped)
+
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d messages failed to be delivered", failed)
+	}
+	return
+}
+
+func (f produceFlags) message(line []byte, encoder kafkaavro.Encoder) (message *kafka.Message, err error) {
+
+	var key []byte
+	if f.key != "" {
+		key = []byte(f.key)
+	} else if f.keyField != "" {
+		key, err = extractKey(line, f.keyField)
+		if err != nil {
+			return
+		}
+	}
+
+	value, err := encoder.EncodeTextual(line)
+	if err != nil {
+		return
+	}
+
+	message = &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &f.topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          value,
+	}
+	return
+}
+
+func produce(producer *kafka.Producer, message *kafka.Message, deliveryChan chan kafka.Event) (err error) {
+	for {
+		err = producer.Produce(message, deliveryChan)
+		if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+			// wait for some deliveries to free up room in the local queue
+			producer.Flush(100)
+			continue
+		}
+		return
+	}
+}
+
+// extractKey returns the value of the (dot separated) field path in the json line.
+// Strings are used as is, other values are used in their json representation.
+func extractKey(line []byte, fieldPath string) (key []byte, err error) {
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return
+	}
+
+	value := document
+	for _, field := range strings.Split(fieldPath, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key field %v not found", fieldPath)
+		}
+		if value, ok = object[field]; !ok {
+			return nil, fmt.Errorf("key field %v not found", fieldPath)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
+}
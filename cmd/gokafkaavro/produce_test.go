@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestExtractKey(t *testing.T) {
+
+	var tests = []struct {
+		line     string
+		keyField string
+		want     string
+	}{
+		{`{"id":"abc","f1":"x"}`, "id", "abc"},
+		{`{"id":12345678901234567890}`, "id", "12345678901234567890"},
+		{`{"customer":{"id":{"string":"c1"}}}`, "customer.id", `{"string":"c1"}`},
+		{`{"customer":{"id":"c1"}}`, "customer.id", "c1"},
+	}
+
+	for _, test := range tests {
+		got, err := extractKey([]byte(test.line), test.keyField)
+		if err != nil {
+			t.Errorf("extractKey(%v, %v) returned error %v", test.line, test.keyField, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("extractKey(%v, %v) returned %s, want %s", test.line, test.keyField, got, test.want)
+		}
+	}
+}
+
+func TestExtractKeyMissingField(t *testing.T) {
+
+	var tests = []struct {
+		line     string
+		keyField string
+	}{
+		{`{"id":"abc"}`, "other"},
+		{`{"id":"abc"}`, "id.nested"},
+		{`not json`, "id"},
+	}
+
+	for _, test := range tests {
+		if _, err := extractKey([]byte(test.line), test.keyField); err == nil {
+			t.Errorf("extractKey(%v, %v) returned no error", test.line, test.keyField)
+		}
+	}
+}
+
+func TestProduceFlagsValidate(t *testing.T) {
+
+	var tests = []struct {
+		flags produceFlags
+		valid bool
+	}{
+		{produceFlags{topic: "t", schemaFile: "s.avsc"}, true},
+		{produceFlags{topic: "t", useLatestSchema: true}, true},
+		{produceFlags{topic: "t", schemaFile: "s.avsc", autoRegister: true, keyField: "id"}, true},
+		{produceFlags{schemaFile: "s.avsc"}, false},
+		{produceFlags{topic: "t"}, false},
+		{produceFlags{topic: "t", schemaFile: "s.avsc", useLatestSchema: true}, false},
+		{produceFlags{topic: "t", useLatestSchema: true, autoRegister: true}, false},
+		{produceFlags{topic: "t", schemaFile: "s.avsc", key: "k", keyField: "id"}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.flags.validate(); (err == nil) != test.valid {
+			t.Errorf("validate(%+v) returned %v, want valid %v", test.flags, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// projection prunes decoded records to a set of (dot separated) field paths.
+type projection struct {
+	fields  fieldTree
+	warnOut io.Writer
+	warned  map[string]bool
+}
+
+// fieldTree maps a field name to the projection of its value, an empty tree keeps the value wholesale.
+type fieldTree map[string]fieldTree
+
+func newProjection(fields string, warnOut io.Writer) *projection {
+
+	if strings.TrimSpace(fields) == "" {
+		return nil
+	}
+
+	tree := fieldTree{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		node := tree
+		for _, field := range strings.Split(path, ".") {
+			child, found := node[field]
+			if !found {
+				child = fieldTree{}
+				node[field] = child
+			}
+			node = child
+		}
+	}
+
+	return &projection{fields: tree, warnOut: warnOut, warned: make(map[string]bool)}
+}
+
+func (p *projection) project(native interface{}) interface{} {
+	return p.projectValue(native, p.fields, "")
+}
+
+func (p *projection) projectValue(native interface{}, fields fieldTree, prefix string) interface{} {
+
+	if len(fields) == 0 {
+		return native
+	}
+
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		// arrays, and values which are not records, are kept wholesale
+		return native
+	}
+
+	if isUnionWrapper(record, fields) {
+		for name, value := range record {
+			return map[string]interface{}{name: p.projectValue(value, fields, prefix)}
+		}
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for field, children := range fields {
+		value, found := record[field]
+		if !found {
+			p.warnUnknown(prefix + field)
+			continue
+		}
+		projected[field] = p.projectValue(value, children, prefix+field+".")
+	}
+	return projected
+}
+
+// isUnionWrapper detects the goavro representation of a non-null union value: a map with
+// the type name as single key, which is not one of the projected fields.
+func isUnionWrapper(record map[string]interface{}, fields fieldTree) bool {
+	if len(record) != 1 {
+		return false
+	}
+	for name, value := range record {
+		if _, isField := fields[name]; isField {
+			return false
+		}
+		_, isRecord := value.(map[string]interface{})
+		return isRecord
+	}
+	return false
+}
+
+func (p *projection) warnUnknown(path string) {
+	if p.warned[path] {
+		return
+	}
+	p.warned[path] = true
+	fmt.Fprintf(p.warnOut, "Warning: field %v not found in record\n", path)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var projectionTestRecord = map[string]interface{}{
+	"id":     int64(42),
+	"status": "OPEN",
+	"customer": map[string]interface{}{
+		"name": "alice",
+		"address": map[string]interface{}{
+			"com.acme.Address": map[string]interface{}{"city": "Brussels", "street": "Grote Markt"},
+		},
+	},
+	"items": []interface{}{
+		map[string]interface{}{"sku": "a", "quantity": int32(1)},
+	},
+}
+
+func TestProjection(t *testing.T) {
+
+	var tests = []struct {
+		fields string
+		want   interface{}
+	}{
+		{"id", map[string]interface{}{"id": int64(42)}},
+		{"id, status", map[string]interface{}{"id": int64(42), "status": "OPEN"}},
+		{"customer.name", map[string]interface{}{"customer": map[string]interface{}{"name": "alice"}}},
+		{"customer.address.city", map[string]interface{}{
+			"customer": map[string]interface{}{
+				"address": map[string]interface{}{"com.acme.Address": map[string]interface{}{"city": "Brussels"}},
+			},
+		}},
+		{"customer,customer.name", map[string]interface{}{"customer": map[string]interface{}{"name": "alice"}}},
+		{"items.sku", map[string]interface{}{"items": projectionTestRecord["items"]}},
+	}
+
+	for _, test := range tests {
+		var warnings bytes.Buffer
+		got := newProjection(test.fields, &warnings).project(projectionTestRecord)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("projection %v returned %v, want %v", test.fields, got, test.want)
+		}
+		if warnings.Len() > 0 {
+			t.Errorf("projection %v warned %v", test.fields, warnings.String())
+		}
+	}
+}
+
+func TestProjectionWarnsOnceForUnknownFields(t *testing.T) {
+
+	var warnings bytes.Buffer
+	p := newProjection("id,missing,customer.missing", &warnings)
+
+	for i := 0; i < 3; i++ {
+		got := p.project(projectionTestRecord)
+		want := map[string]interface{}{"id": int64(42), "customer": map[string]interface{}{}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("projection returned %v, want %v", got, want)
+		}
+	}
+
+	if got := strings.Count(warnings.String(), "\n"); got != 2 {
+		t.Errorf("got %d warnings, want 2: %v", got, warnings.String())
+	}
+}
+
+func TestNoProjection(t *testing.T) {
+	if p := newProjection(" ", nil); p != nil {
+		t.Errorf("newProjection without fields returned %v", p)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const rangeTimeoutMs = 10000
+
+// rangeFlags select the messages to export in batch mode.
+type rangeFlags struct {
+	between stringsFlag
+	since   string
+	until   string
+}
+
+func (r rangeFlags) isSet() bool {
+	return len(r.between) > 0 || r.since != "" || r.until != ""
+}
+
+// offsetRange is the range of offsets [start, end) of a partition to export.
+type offsetRange struct {
+	partition int32
+	start     int64
+	end       int64
+}
+
+// rangeQuerier is the part of the kafka.Consumer used to resolve the ranges to export.
+type rangeQuerier interface {
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error)
+	OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) (offsets []kafka.TopicPartition, err error)
+}
+
+// parseBetween parses the --between partition:start:end flags, the end offset is inclusive.
+func parseBetween(between stringsFlag) (ranges []offsetRange, err error) {
+
+	seen := make(map[int32]bool)
+	for _, value := range between {
+
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("--between %q is not of the form partition:start:end", value)
+		}
+
+		partition, partitionErr := strconv.ParseInt(parts[0], 10, 32)
+		start, startErr := strconv.ParseInt(parts[1], 10, 64)
+		end, endErr := strconv.ParseInt(parts[2], 10, 64)
+		if partitionErr != nil || startErr != nil || endErr != nil || partition < 0 || start < 0 || end < start {
+			return nil, fmt.Errorf("--between %q is not of the form partition:start:end with 0 <= start <= end", value)
+		}
+
+		if seen[int32(partition)] {
+			return nil, fmt.Errorf("--between has more than one range for partition %d", partition)
+		}
+		seen[int32(partition)] = true
+
+		ranges = append(ranges, offsetRange{partition: int32(partition), start: start, end: end + 1})
+	}
+	return
+}
+
+// parseTimestamp parses an RFC 3339 timestamp, a date or milliseconds since the epoch.
+func parseTimestamp(flagName string, value string) (timestamp time.Time, err error) {
+
+	if millis, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil {
+		return time.UnixMilli(millis), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if timestamp, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	return timestamp, fmt.Errorf("%v %q is not an RFC 3339 timestamp, a date (2006-01-02) or milliseconds since the epoch", flagName, value)
+}
+
+// resolve turns the flags into the ranges to export and verifies that the requested offsets
+// exist in the topic.
+func (r rangeFlags) resolve(consumer rangeQuerier, topic string) (ranges []offsetRange, err error) {
+
+	if len(r.between) > 0 && (r.since != "" || r.until != "") {
+		return nil, errors.New("--between can not be combined with --since or --until")
+	}
+
+	if len(r.between) > 0 {
+		if ranges, err = parseBetween(r.between); err != nil {
+			return
+		}
+		for _, offsets := range ranges {
+			low, high, queryErr := consumer.QueryWatermarkOffsets(topic, offsets.partition, rangeTimeoutMs)
+			if queryErr != nil {
+				return nil, fmt.Errorf("failed to query the offsets of %v [%d]: %v", topic, offsets.partition, queryErr)
+			}
+			if offsets.start < low || offsets.end > high {
+				return nil, fmt.Errorf("offsets %d-%d are not available in %v [%d], it holds offsets %d-%d",
+					offsets.start, offsets.end-1, topic, offsets.partition, low, high-1)
+			}
+		}
+		return
+	}
+
+	partitions, err := topicPartitions(consumer, topic)
+	if err != nil {
+		return
+	}
+
+	for _, partition := range partitions {
+		low, high, queryErr := consumer.QueryWatermarkOffsets(topic, partition, rangeTimeoutMs)
+		if queryErr != nil {
+			return nil, fmt.Errorf("failed to query the offsets of %v [%d]: %v", topic, partition, queryErr)
+		}
+		ranges = append(ranges, offsetRange{partition: partition, start: low, end: high})
+	}
+
+	if r.since != "" {
+		if err = r.offsetsForTime(consumer, topic, "--since", r.since, ranges, func(o *offsetRange, offset int64) { o.start = offset }); err != nil {
+			return
+		}
+	}
+	if r.until != "" {
+		if err = r.offsetsForTime(consumer, topic, "--until", r.until, ranges, func(o *offsetRange, offset int64) { o.end = offset }); err != nil {
+			return
+		}
+	}
+
+	for i := range ranges {
+		if ranges[i].end < ranges[i].start {
+			ranges[i].end = ranges[i].start
+		}
+	}
+	return
+}
+
+// offsetsForTime looks up the first offset at or after the timestamp in every partition, partitions
+// without such a message get their high watermark.
+func (r rangeFlags) offsetsForTime(consumer rangeQuerier, topic string, flagName string, value string, ranges []offsetRange, set func(*offsetRange, int64)) (err error) {
+
+	timestamp, err := parseTimestamp(flagName, value)
+	if err != nil {
+		return
+	}
+
+	times := make([]kafka.TopicPartition, 0, len(ranges))
+	for _, offsets := range ranges {
+		times = append(times, kafka.TopicPartition{Topic: &topic, Partition: offsets.partition, Offset: kafka.Offset(timestamp.UnixMilli())})
+	}
+
+	found, err := consumer.OffsetsForTimes(times, rangeTimeoutMs)
+	if err != nil {
+		return fmt.Errorf("failed to look up the offsets of %v %v: %v", flagName, value, err)
+	}
+
+	for _, tp := range found {
+		if tp.Error != nil {
+			return fmt.Errorf("failed to look up the offset of %v %v in %v [%d]: %v", flagName, value, topic, tp.Partition, tp.Error)
+		}
+		for i := range ranges {
+			if ranges[i].partition != tp.Partition {
+				continue
+			}
+			if tp.Offset >= 0 {
+				set(&ranges[i], int64(tp.Offset))
+			} else {
+				set(&ranges[i], ranges[i].end)
+			}
+		}
+	}
+	return
+}
+
+func topicPartitions(consumer rangeQuerier, topic string) (partitions []int32, err error) {
+
+	metadata, err := consumer.GetMetadata(&topic, false, rangeTimeoutMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the metadata of %v: %v", topic, err)
+	}
+
+	topicMetadata, found := metadata.Topics[topic]
+	if !found {
+		return nil, fmt.Errorf("topic %v not found", topic)
+	}
+	if topicMetadata.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("failed to fetch the metadata of %v: %v", topic, topicMetadata.Error)
+	}
+
+	for _, partition := range topicMetadata.Partitions {
+		partitions = append(partitions, partition.ID)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return
+}
+
+// rangeTracker follows the progress of the export of the ranges.
+type rangeTracker struct {
+	topic        string
+	ranges       map[int32]*partitionProgress
+	partitions   []int32
+	lastProgress time.Time
+}
+
+type partitionProgress struct {
+	offsetRange
+	position int64
+	messages int64
+	done     bool
+}
+
+func newRangeTracker(topic string, ranges []offsetRange, now time.Time) *rangeTracker {
+
+	r := &rangeTracker{topic: topic, ranges: make(map[int32]*partitionProgress), lastProgress: now}
+	for _, offsets := range ranges {
+		r.ranges[offsets.partition] = &partitionProgress{offsetRange: offsets, position: offsets.start, done: offsets.start >= offsets.end}
+		r.partitions = append(r.partitions, offsets.partition)
+	}
+	sort.Slice(r.partitions, func(i, j int) bool { return r.partitions[i] < r.partitions[j] })
+	return r
+}
+
+// assignment returns the partitions to assign, positioned at the start of their range.
+func (r *rangeTracker) assignment() (partitions []kafka.TopicPartition) {
+	for _, partition := range r.partitions {
+		progress := r.ranges[partition]
+		if !progress.done {
+			partitions = append(partitions, kafka.TopicPartition{Topic: &r.topic, Partition: partition, Offset: kafka.Offset(progress.start)})
+		}
+	}
+	return
+}
+
+// contains returns true if the message is in the range of its partition.
+func (r *rangeTracker) contains(m *kafka.Message) bool {
+	progress, found := r.ranges[m.TopicPartition.Partition]
+	offset := int64(m.TopicPartition.Offset)
+	return found && !progress.done && offset >= progress.start && offset < progress.end
+}
+
+// reached records that the partition is consumed up to (excluding) the offset.
+func (r *rangeTracker) reached(partition int32, offset int64, w io.Writer) {
+
+	progress, found := r.ranges[partition]
+	if !found || progress.done || offset <= progress.position {
+		return
+	}
+
+	progress.position = offset
+	if progress.position >= progress.end {
+		progress.done = true
+		fmt.Fprintf(w, "Completed %v [%d]: %d messages (offsets %d-%d)\n", r.topic, partition, progress.messages, progress.start, progress.end-1)
+	}
+}
+
+// handled records that the message in the range was exported.
+func (r *rangeTracker) handled(m *kafka.Message, w io.Writer) {
+	if progress, found := r.ranges[m.TopicPartition.Partition]; found {
+		progress.messages++
+	}
+	r.reached(m.TopicPartition.Partition, int64(m.TopicPartition.Offset)+1, w)
+}
+
+func (r *rangeTracker) done() bool {
+	for _, progress := range r.ranges {
+		if !progress.done {
+			return false
+		}
+	}
+	return true
+}
+
+// progress prints the completion of every partition, at most once per interval.
+func (r *rangeTracker) progress(now time.Time, interval time.Duration, w io.Writer) {
+
+	if now.Sub(r.lastProgress) < interval {
+		return
+	}
+	r.lastProgress = now
+
+	parts := make([]string, 0, len(r.partitions))
+	for _, partition := range r.partitions {
+		progress := r.ranges[partition]
+		if progress.done {
+			parts = append(parts, fmt.Sprintf("[%d] done", partition))
+			continue
+		}
+		total := progress.end - progress.start
+		consumed := progress.position - progress.start
+		parts = append(parts, fmt.Sprintf("[%d] %d/%d (%d%%)", partition, consumed, total, consumed*100/total))
+	}
+	fmt.Fprintf(w, "Progress %v: %v\n", r.topic, strings.Join(parts, ", "))
+}
+
+func (r *rangeTracker) summary(w io.Writer) {
+
+	var messages int64
+	for _, progress := range r.ranges {
+		messages += progress.messages
+	}
+
+	status := "Exported"
+	if !r.done() {
+		status = "Interrupted after exporting"
+	}
+	fmt.Fprintf(w, "%v %d messages from %d partitions of %v\n", status, messages, len(r.ranges), r.topic)
+}
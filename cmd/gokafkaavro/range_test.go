@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// fakeRangeQuerier is a topic with 2 partitions holding the offsets 10-19 and 0-4, every
+// message has the timestamp of its offset in seconds since the epoch.
+type fakeRangeQuerier struct{}
+
+func (f fakeRangeQuerier) GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error) {
+	return &kafka.Metadata{Topics: map[string]kafka.TopicMetadata{
+		"orders": {Topic: "orders", Partitions: []kafka.PartitionMetadata{{ID: 1}, {ID: 0}}},
+	}}, nil
+}
+
+func (f fakeRangeQuerier) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error) {
+	if partition == 0 {
+		return 10, 20, nil
+	}
+	return 0, 5, nil
+}
+
+func (f fakeRangeQuerier) OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) (offsets []kafka.TopicPartition, err error) {
+	for _, tp := range times {
+		low, high, _ := f.QueryWatermarkOffsets(*tp.Topic, tp.Partition, timeoutMs)
+		offset := int64(tp.Offset) / 1000
+		switch {
+		case offset < low:
+			offset = low
+		case offset >= high:
+			offset = int64(kafka.OffsetEnd)
+		}
+		tp.Offset = kafka.Offset(offset)
+		offsets = append(offsets, tp)
+	}
+	return
+}
+
+func TestParseBetween(t *testing.T) {
+
+	var tests = []struct {
+		between stringsFlag
+		want    []offsetRange
+		valid   bool
+	}{
+		{stringsFlag{"0:10:19"}, []offsetRange{{0, 10, 20}}, true},
+		{stringsFlag{"0:10:10", "1:0:4"}, []offsetRange{{0, 10, 11}, {1, 0, 5}}, true},
+		{stringsFlag{"0:10"}, nil, false},
+		{stringsFlag{"0:20:10"}, nil, false},
+		{stringsFlag{"x:0:10"}, nil, false},
+		{stringsFlag{"0:0:1", "0:2:3"}, nil, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseBetween(test.between)
+		if (err == nil) != test.valid {
+			t.Errorf("parseBetween(%v) returned error %v, want valid %v", test.between, err, test.valid)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseBetween(%v) returned %v, want %v", test.between, got, test.want)
+		}
+	}
+}
+
+func TestRangeResolve(t *testing.T) {
+
+	var tests = []struct {
+		flags   rangeFlags
+		want    []offsetRange
+		wantErr string
+	}{
+		{rangeFlags{between: stringsFlag{"0:12:15"}}, []offsetRange{{0, 12, 16}}, ""},
+		{rangeFlags{between: stringsFlag{"0:5:15"}}, nil, "offsets 5-15 are not available in orders [0], it holds offsets 10-19"},
+		{rangeFlags{between: stringsFlag{"1:0:5"}}, nil, "offsets 0-5 are not available in orders [1], it holds offsets 0-4"},
+		{rangeFlags{since: "15000"}, []offsetRange{{0, 15, 20}, {1, 5, 5}}, ""},
+		{rangeFlags{until: "1970-01-01T00:00:03Z"}, []offsetRange{{0, 10, 10}, {1, 0, 3}}, ""},
+		{rangeFlags{since: "2000", until: "12000"}, []offsetRange{{0, 10, 12}, {1, 2, 5}}, ""},
+		{rangeFlags{since: "yesterday"}, nil, `--since "yesterday" is not an RFC 3339 timestamp`},
+		{rangeFlags{between: stringsFlag{"0:12:15"}, since: "0"}, nil, "--between can not be combined"},
+	}
+
+	for _, test := range tests {
+		got, err := test.flags.resolve(fakeRangeQuerier{}, "orders")
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("resolve(%+v) returned %v, want an error containing %q", test.flags, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolve(%+v) failed: %v", test.flags, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("resolve(%+v) returned %v, want %v", test.flags, got, test.want)
+		}
+	}
+}
+
+func TestRangeTracker(t *testing.T) {
+
+	start := time.Now()
+	tracker := newRangeTracker("orders", []offsetRange{{0, 10, 13}, {1, 0, 2}, {2, 5, 5}}, start)
+
+	if assignment := tracker.assignment(); len(assignment) != 2 || assignment[0].Offset != 10 || assignment[1].Offset != 0 {
+		t.Errorf("assignment() returned %v", assignment)
+	}
+
+	var out bytes.Buffer
+
+	for _, offset := range []kafka.Offset{10, 11} {
+		m := testMessage(0, offset)
+		if !tracker.contains(m) {
+			t.Errorf("message at offset %d is not in the range", offset)
+		}
+		tracker.handled(m, &out)
+	}
+	tracker.handled(testMessage(1, 0), &out)
+
+	tracker.progress(start.Add(2*time.Second), time.Second, &out)
+	if want := "Progress orders: [0] 2/3 (66%), [1] 1/2 (50%), [2] done\n"; out.String() != want {
+		t.Errorf("progress printed %q, want %q", out.String(), want)
+	}
+	out.Reset()
+
+	// a gap at the end of the range, e.g. a transaction marker, completes the partition on EOF
+	tracker.reached(0, 13, &out)
+	if tracker.contains(testMessage(0, 13)) {
+		t.Errorf("message at offset 13 is in the range")
+	}
+	if tracker.done() {
+		t.Errorf("tracker is done before partition 1 completed")
+	}
+
+	tracker.handled(testMessage(1, 1), &out)
+	if !tracker.done() {
+		t.Errorf("tracker is not done after all partitions completed")
+	}
+
+	want := "Completed orders [0]: 2 messages (offsets 10-12)\nCompleted orders [1]: 2 messages (offsets 0-1)\n"
+	if out.String() != want {
+		t.Errorf("tracker printed %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	tracker.summary(&out)
+	if want := "Exported 4 messages from 3 partitions of orders\n"; out.String() != want {
+		t.Errorf("summary printed %q, want %q", out.String(), want)
+	}
+}
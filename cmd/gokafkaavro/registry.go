@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const (
+	srBasicAuthEnvName = "GOKAFKAAVRO_SR_BASIC_AUTH"
+	srAPISecretEnvName = "GOKAFKAAVRO_SR_API_SECRET"
+)
+
+// registryFlags are the flags to connect to the schema registry.
+type registryFlags struct {
+	url           string
+	basicAuth     string
+	apiKey        string
+	apiSecret     string
+	caCert        string
+	skipTLSVerify bool
+}
+
+func (r *registryFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&r.url, "registry", "http://localhost:8081", "url of the schema registry")
+	fs.StringVar(&r.basicAuth, "sr-basic-auth", "", "user:password to authenticate against the schema registry (or set "+srBasicAuthEnvName+")")
+	fs.StringVar(&r.apiKey, "sr-api-key", "", "api key to authenticate against the schema registry")
+	fs.StringVar(&r.apiSecret, "sr-api-secret", "", "api secret to authenticate against the schema registry (or set "+srAPISecretEnvName+")")
+	fs.StringVar(&r.caCert, "sr-ca-cert", "", "file with the CA certificate(s) to verify the schema registry")
+	fs.BoolVar(&r.skipTLSVerify, "sr-skip-tls-verify", false, "do not verify the certificate of the schema registry")
+}
+
+// credentials returns the basic auth credentials, if any.
+func (r registryFlags) credentials() (username string, password string, err error) {
+
+	basicAuth := r.basicAuth
+	if basicAuth == "" {
+		basicAuth = os.Getenv(srBasicAuthEnvName)
+	}
+	apiSecret := r.apiSecret
+	if apiSecret == "" {
+		apiSecret = os.Getenv(srAPISecretEnvName)
+	}
+
+	if basicAuth != "" && r.apiKey != "" {
+		return "", "", errors.New("--sr-basic-auth and --sr-api-key are mutually exclusive")
+	}
+
+	if basicAuth != "" {
+		i := strings.Index(basicAuth, ":")
+		if i < 0 {
+			return "", "", errors.New("--sr-basic-auth is not of the form user:password")
+		}
+		return basicAuth[:i], basicAuth[i+1:], nil
+	}
+
+	if r.apiKey != "" {
+		if apiSecret == "" {
+			return "", "", errors.New("--sr-api-key requires --sr-api-secret")
+		}
+		return r.apiKey, apiSecret, nil
+	}
+
+	return "", "", nil
+}
+
+func (r registryFlags) httpClient() (httpClient *http.Client, err error) {
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: r.skipTLSVerify}
+	if r.caCert != "" {
+		pem, readErr := os.ReadFile(r.caCert)
+		if readErr != nil {
+			return nil, readErr
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in --sr-ca-cert %v", r.caCert)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	username, password, err := r.credentials()
+	if err != nil {
+		return
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if username != "" {
+		roundTripper = basicAuthTransport{username, password, transport}
+	}
+
+	httpClient = &http.Client{Transport: roundTripper, Timeout: 30 * time.Second}
+	return
+}
+
+// newClient creates the schema registry client and verifies that the registry accepts our
+// credentials, so that we fail fast instead of failing on every message.
+func (r registryFlags) newClient() (client *schemaregistry.Client, err error) {
+
+	httpClient, err := r.httpClient()
+	if err != nil {
+		return
+	}
+
+	client, err = schemaregistry.NewClient(r.url, schemaregistry.UsingClient(httpClient))
+	if err != nil {
+		return
+	}
+
+	if _, err = client.Subjects(); err != nil {
+		return nil, fmt.Errorf("schema registry %v did not accept the connection (check the --sr-* flags): %v", r.url, err)
+	}
+	return
+}
+
+type basicAuthTransport struct {
+	username string
+	password string
+	next     http.RoundTripper
+}
+
+func (b basicAuthTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.SetBasicAuth(b.username, b.password)
+	return b.next.RoundTrip(request)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistryCredentials(t *testing.T) {
+
+	var tests = []struct {
+		flags    registryFlags
+		username string
+		password string
+		valid    bool
+	}{
+		{registryFlags{}, "", "", true},
+		{registryFlags{basicAuth: "user:pa:ss"}, "user", "pa:ss", true},
+		{registryFlags{apiKey: "key", apiSecret: "secret"}, "key", "secret", true},
+		{registryFlags{basicAuth: "user"}, "", "", false},
+		{registryFlags{apiKey: "key"}, "", "", false},
+		{registryFlags{basicAuth: "user:pass", apiKey: "key", apiSecret: "secret"}, "", "", false},
+	}
+
+	for _, test := range tests {
+		username, password, err := test.flags.credentials()
+		if (err == nil) != test.valid {
+			t.Errorf("credentials(%+v) returned error %v, want valid %v", test.flags, err, test.valid)
+			continue
+		}
+		if username != test.username || password != test.password {
+			t.Errorf("credentials(%+v) returned %v:%v, want %v:%v", test.flags, username, password, test.username, test.password)
+		}
+	}
+}
+
+func TestRegistryCredentialsFromEnvironment(t *testing.T) {
+
+	t.Setenv(srAPISecretEnvName, "secret")
+
+	username, password, err := registryFlags{apiKey: "key"}.credentials()
+	if err != nil || username != "key" || password != "secret" {
+		t.Errorf("credentials returned %v:%v, %v, want key:secret", username, password, err)
+	}
+}
+
+func TestRegistryHTTPClient(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	var tests = []struct {
+		flags      registryFlags
+		wantStatus int
+		wantErr    bool
+	}{
+		{registryFlags{basicAuth: "user:pass"}, 0, true},
+		{registryFlags{basicAuth: "user:pass", skipTLSVerify: true}, http.StatusOK, false},
+		{registryFlags{basicAuth: "user:wrong", skipTLSVerify: true}, http.StatusUnauthorized, false},
+	}
+
+	for _, test := range tests {
+		httpClient, err := test.flags.httpClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := httpClient.Get(server.URL + "/subjects")
+		if (err != nil) != test.wantErr {
+			t.Errorf("GET with %+v returned error %v, want error %v", test.flags, err, test.wantErr)
+			continue
+		}
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode != test.wantStatus {
+				t.Errorf("GET with %+v returned status %v, want %v", test.flags, response.StatusCode, test.wantStatus)
+			}
+		}
+	}
+}
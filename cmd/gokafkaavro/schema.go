@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const schemaUsage = `Usage: gokafkaavro schema <command> [flags]
+
+Commands:
+  get        print a schema, by topic, subject (and version) or id
+  versions   list the registered versions of a subject
+  diff       show the fields added, removed, renamed or changed between two versions of a subject and their compatibility
+  validate   check that a JSON document (in the avro JSON encoding) is a value of the schema of a subject
+  check      check that a schema would register under a subject, without registering it
+`
+
+func runSchema(args []string) (err error) {
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, schemaUsage)
+		return errors.New("missing schema command")
+	}
+
+	switch args[0] {
+	case "get":
+		return runSchemaGet(args[1:], os.Stdout)
+	case "versions":
+		return runSchemaVersions(args[1:], os.Stdout)
+	case "diff":
+		return runSchemaDiff(args[1:], os.Stdout)
+	case "validate":
+		return runSchemaValidate(args[1:], os.Stdin, os.Stdout)
+	case "check":
+		return runSchemaCheck(args[1:], os.Stdin, os.Stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, schemaUsage)
+		return flag.ErrHelp
+	}
+
+	fmt.Fprint(os.Stderr, schemaUsage)
+	return fmt.Errorf("unknown schema command %q", args[0])
+}
+
+func runSchemaGet(args []string, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var topic, subject string
+	var isKey bool
+	var id, version int
+
+	fs := flag.NewFlagSet("schema get", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.StringVar(&topic, "topic", "", "print the schema of the subject of this topic (topic name strategy)")
+	fs.BoolVar(&isKey, "key", false, "with --topic, use the key subject instead of the value subject")
+	fs.StringVar(&subject, "subject", "", "print the schema of this subject")
+	fs.IntVar(&version, "version", -1, "with --topic or --subject, print this version instead of the latest")
+	fs.IntVar(&id, "id", -1, "print the schema with this id")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+
+	if topic != "" && subject != "" {
+		return errors.New("--topic and --subject are mutually exclusive")
+	}
+	if topic != "" {
+		subject = kafkaavro.TopicNameStrategy{}.GetSubjectName(topic, isKey)
+	}
+	if (subject == "") == (id < 0) {
+		return errors.New("exactly one of --topic, --subject or --id is required")
+	}
+
+	client, err := registry.newClient()
+	if err != nil {
+		return
+	}
+
+	if id >= 0 {
+		codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+		defer codec.Close()
+		return printSchemaByID(codec, id, w)
+	}
+
+	var avroSchema string
+	if version < 0 {
+		schema, clientErr := client.GetLatestSchema(subject)
+		if clientErr != nil {
+			return fmt.Errorf("failed to fetch the latest schema of subject %v: %v", subject, clientErr)
+		}
+		avroSchema = schema.Schema
+	} else {
+		schema, clientErr := client.GetSchemaBySubject(subject, version)
+		if clientErr != nil {
+			return fmt.Errorf("failed to fetch version %d of subject %v: %v", version, subject, clientErr)
+		}
+		avroSchema = schema.Schema
+	}
+
+	return printSchema(w, avroSchema)
+}
+
+// printSchemaByID prints the schema of the id, with the cache and registry of the decodes.
+func printSchemaByID(codec *kafkaavro.Codec, id int, w io.Writer) error {
+	info, err := codec.SchemaByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return printSchema(w, info.Schema)
+}
+
+func printSchema(w io.Writer, avroSchema string) error {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(avroSchema), "", "  "); err != nil {
+		// primitive schemas like "string" are not always valid json documents, print them as is
+		_, err = fmt.Fprintln(w, avroSchema)
+		return err
+	}
+	_, err := fmt.Fprintln(w, pretty.String())
+	return err
+}
+
+func runSchemaVersions(args []string, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var subject string
+
+	fs := flag.NewFlagSet("schema versions", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.StringVar(&subject, "subject", "", "subject to list the versions of (required)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+	if subject == "" {
+		return errors.New("--subject is required")
+	}
+
+	client, err := registry.newClient()
+	if err != nil {
+		return
+	}
+
+	versions, err := client.Versions(subject)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the versions of subject %v: %v", subject, err)
+	}
+
+	for _, version := range versions {
+		fmt.Fprintln(w, version)
+	}
+	return
+}
+
+func runSchemaDiff(args []string, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var subject string
+	var from, to int
+
+	fs := flag.NewFlagSet("schema diff", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.StringVar(&subject, "subject", "", "subject to compare the versions of (required)")
+	fs.IntVar(&from, "from", 0, "version to compare from (required)")
+	fs.IntVar(&to, "to", 0, "version to compare to (required)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+	if subject == "" || from <= 0 || to <= 0 {
+		return errors.New("--subject, --from and --to are required")
+	}
+
+	client, err := registry.newClient()
+	if err != nil {
+		return
+	}
+
+	fromSchema, err := client.GetSchemaBySubject(subject, from)
+	if err != nil {
+		return fmt.Errorf("failed to fetch version %d of subject %v: %v", from, subject, err)
+	}
+	toSchema, err := client.GetSchemaBySubject(subject, to)
+	if err != nil {
+		return fmt.Errorf("failed to fetch version %d of subject %v: %v", to, subject, err)
+	}
+
+	return printDiff(fromSchema.Schema, toSchema.Schema, subject, from, to, w)
+}
+
+// printDiff prints a line per added (+), removed (-), renamed (>) or changed (~) field and the
+// compatibility of the versions.
+func printDiff(fromSchema string, toSchema string, subject string, from int, to int, w io.Writer) (err error) {
+
+	diff, err := kafkaavro.DiffSchemas(fromSchema, toSchema)
+	if err != nil {
+		return
+	}
+
+	if len(diff.Changes) == 0 {
+		fmt.Fprintf(w, "No field changes between version %d and %d of %v\n", from, to, subject)
+	}
+	for _, change := range diff.Changes {
+		fmt.Fprintln(w, change)
+	}
+	_, err = fmt.Fprintf(w, "Compatibility: %v\n", diff.Compatibility)
+	return
+}
+
+func runSchemaValidate(args []string, stdin io.Reader, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var subject, file string
+	var version int
+
+	fs := flag.NewFlagSet("schema validate", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.StringVar(&subject, "subject", "", "subject of the schema to validate against (required)")
+	fs.IntVar(&version, "version", -1, "validate against this version instead of the latest")
+	fs.StringVar(&file, "file", "-", "file with the JSON document, - for stdin")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+	if subject == "" {
+		return errors.New("--subject is required")
+	}
+
+	var doc []byte
+	if file == "-" {
+		doc, err = io.ReadAll(stdin)
+	} else {
+		doc, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return
+	}
+
+	client, err := registry.newClient()
+	if err != nil {
+		return
+	}
+	return validateDocument(kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}), subject, version, file, doc, w)
+}
+
+func validateDocument(codec *kafkaavro.Codec, subject string, version int, file string, doc []byte, w io.Writer) (err error) {
+
+	against := fmt.Sprintf("the latest version of %v", subject)
+	if version >= 0 {
+		against = fmt.Sprintf("version %d of %v", version, subject)
+	}
+	if file == "-" {
+		file = "stdin"
+	}
+	if err = codec.ValidateJSON(subject, version, doc); err != nil {
+		return fmt.Errorf("%v is not valid against %v: %w", file, against, err)
+	}
+	_, err = fmt.Fprintf(w, "%v is valid against %v\n", file, against)
+	return
+}
+
+func runSchemaCheck(args []string, stdin io.Reader, w io.Writer) (err error) {
+
+	var registry registryFlags
+	var config configFlags
+	var subject, file string
+
+	fs := flag.NewFlagSet("schema check", flag.ContinueOnError)
+	registry.register(fs)
+	config.register(fs)
+	fs.StringVar(&subject, "subject", "", "subject to check the registration of the schema under (required)")
+	fs.StringVar(&file, "file", "-", "file with the avro schema, - for stdin")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = config.apply(fs); err != nil {
+		return
+	}
+	if subject == "" {
+		return errors.New("--subject is required")
+	}
+
+	var schema []byte
+	if file == "-" {
+		schema, err = io.ReadAll(stdin)
+	} else {
+		schema, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return
+	}
+
+	client, err := registry.newClient()
+	if err != nil {
+		return
+	}
+	return checkSchema(*client, subject, string(schema), w)
+}
+
+// checkSchema prints what registering the schema under the subject would do, it fails if the
+// registry would reject the schema.
+func checkSchema(client schemaregistry.Client, subject string, schema string, w io.Writer) (err error) {
+
+	encoder, err := kafkaavro.NewEncoder(client, true, subject, schema, kafkaavro.WithDryRunRegistration(true))
+	if err != nil {
+		return
+	}
+	plan, _ := encoder.RegistrationPlan()
+	if !plan.Compatible {
+		return errors.New(plan.String())
+	}
+	_, err = fmt.Fprintln(w, plan)
+	return
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const diffFromSchema = `{
+	"type": "record", "name": "Order",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "status", "type": "string"},
+		{"name": "note", "type": "string"},
+		{"name": "customer", "type": {"type": "record", "name": "Customer", "fields": [
+			{"name": "name", "type": "string"}
+		]}}
+	]
+}`
+
+const diffToSchema = `{
+	"type": "record", "name": "Order",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "CLOSED"]}},
+		{"name": "total", "type": ["null", "double"], "default": null},
+		{"name": "customer", "type": ["null", {"type": "record", "name": "Customer", "fields": [
+			{"name": "name", "type": "string"},
+			{"name": "email", "type": "string"}
+		]}]}
+	]
+}`
+
+func TestPrintDiff(t *testing.T) {
+
+	var out bytes.Buffer
+	if err := printDiff(diffFromSchema, diffToSchema, "orders-value", 1, 2, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"~ status: string -> Status (changed)",
+		"+ total: [null, double], with a default",
+		"~ customer: Customer -> [null, Customer] (widened)",
+		"+ customer.email: string, without a default",
+		"- note: string, without a default",
+		"Compatibility: NONE",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("printDiff printed\n%v\nwant\n%v", out.String(), want)
+	}
+}
+
+func TestPrintDiffRecursiveSchema(t *testing.T) {
+
+	schema := `{"type": "record", "name": "Node", "fields": [
+		{"name": "value", "type": "int"},
+		{"name": "children", "type": {"type": "array", "items": "Node"}}
+	]}`
+
+	var out bytes.Buffer
+	err := printDiff(schema, schema, "nodes-value", 1, 2, &out)
+	if want := "No field changes between version 1 and 2 of nodes-value\nCompatibility: FULL\n"; err != nil || out.String() != want {
+		t.Errorf("printDiff of identical schemas printed %q, %v", out.String(), err)
+	}
+}
+
+func TestPrintSchema(t *testing.T) {
+
+	var tests = []struct {
+		schema string
+		want   string
+	}{
+		{`{"type":"record","name":"r","fields":[]}`, "{\n  \"type\": \"record\",\n  \"name\": \"r\",\n  \"fields\": []\n}\n"},
+		{`"string"`, "\"string\"\n"},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := printSchema(&out, test.schema); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("printSchema(%v) printed %q, want %q", test.schema, out.String(), test.want)
+		}
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	var out bytes.Buffer
+	if err := validateDocument(codec, "orders-value", -1, "sample.json", []byte(`{"id":1}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "sample.json is valid against the latest version of orders-value\n"; got != want {
+		t.Errorf("validateDocument() printed %q, want %q", got, want)
+	}
+
+	err := validateDocument(codec, "orders-value", 1, "-", []byte(`{"id":"1"}`), &out)
+	if want := `stdin is not valid against version 1 of orders-value: field id: `; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("validateDocument() returned %v, want %v...", err, want)
+	}
+}
+
+func TestPrintSchemaByID(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	var out bytes.Buffer
+	if err := printSchemaByID(codec, id, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "type": "record",
+  "name": "Order",
+  "fields": [
+    {
+      "name": "id",
+      "type": "long"
+    }
+  ]
+}
+`
+	if out.String() != want {
+		t.Errorf("printSchemaByID() printed %q, want %q", out.String(), want)
+	}
+	if err := printSchemaByID(codec, 42, &out); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("printSchemaByID(42) returned %v", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+
+	const registered = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`
+	const incompatible = `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Schema string `json:"schema"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /subjects/orders-value":
+			if request.Schema == registered {
+				json.NewEncoder(w).Encode(schemaregistry.Schema{Subject: "orders-value", Version: 2, ID: 5, Schema: registered})
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		case "POST /compatibility/subjects/orders-value/versions/latest":
+			json.NewEncoder(w).Encode(map[string]bool{"is_compatible": request.Schema != incompatible})
+		default:
+			t.Errorf("schema check requested %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client, err := schemaregistry.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err = checkSchema(*client, "orders-value", registered, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "the schema is registered under subject orders-value as version 2 with schema id 5\n"; got != want {
+		t.Errorf("checkSchema() printed %q, want %q", got, want)
+	}
+
+	out.Reset()
+	added := `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string","default":""}]}`
+	if err = checkSchema(*client, "orders-value", added, &out); err != nil || !strings.Contains(out.String(), "a new version") {
+		t.Errorf("checkSchema() of a new version printed %q and returned %v", out.String(), err)
+	}
+
+	if err = checkSchema(*client, "orders-value", incompatible, &out); err == nil || !strings.Contains(err.Error(), "not compatible") {
+		t.Errorf("checkSchema() of an incompatible schema returned %v", err)
+	}
+	if err = checkSchema(*client, "orders-value", `{"type":`, &out); err == nil {
+		t.Error("checkSchema() of an invalid schema succeeded")
+	}
+}
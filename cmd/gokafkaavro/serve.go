@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/kafkaavrohttp"
+)
+
+// serveTokenEnv is the environment variable of the default --token, which keeps the token out of
+// the process list.
+const serveTokenEnv = "GOKAFKAAVRO_SERVE_TOKEN"
+
+type serveFlags struct {
+	registry    registryFlags
+	config      configFlags
+	listen      string
+	token       string
+	maxBodySize int64
+	jsonMode    string
+	nonFinite   string
+}
+
+func runServe(args []string) (err error) {
+
+	var f serveFlags
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	f.registry.register(fs)
+	f.config.register(fs)
+	fs.StringVar(&f.listen, "listen", ":8080", "address to serve POST /decode?topic=<topic>[&key=true] and POST /encode?subject=<subject> on")
+	fs.StringVar(&f.token, "token", os.Getenv(serveTokenEnv), "only serve the requests with the header 'Authorization: Bearer <token>' (default $"+serveTokenEnv+")")
+	fs.Int64Var(&f.maxBodySize, "max-body-size", kafkaavrohttp.DefaultMaxBodySize, "refuse the request bodies of more than this many bytes")
+	fs.StringVar(&f.jsonMode, "json-mode", string(kafkaavro.JSONModeAvro), "JSON of the decoded and encoded records: avro (unions as {\"type\": value}) or standard (unions as the bare value)")
+	fs.StringVar(&f.nonFinite, "non-finite-floats", string(kafkaavro.NonFiniteError), `JSON of the NaN and infinite floats: error, null or string ("NaN", "Infinity", "-Infinity")`)
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if err = f.config.apply(fs); err != nil {
+		return
+	}
+
+	client, err := f.registry.newClient()
+	if err != nil {
+		return
+	}
+	handler, codec, err := f.handler(client)
+	if err != nil {
+		return
+	}
+	defer codec.Close()
+
+	server := &http.Server{Addr: f.listen, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigchan
+		fmt.Fprintf(os.Stderr, "Caught signal %v: terminating\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	if f.token == "" {
+		fmt.Fprintln(os.Stderr, "Serving without authentication, use --token to require a bearer token")
+	}
+	fmt.Fprintf(os.Stderr, "Serving on %v\n", f.listen)
+	if err = server.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return
+}
+
+// handler returns the handler of one Codec of the registry, shared by all the requests.
+func (f serveFlags) handler(client kafkaavro.RegistryClient) (handler http.Handler, codec *kafkaavro.Codec, err error) {
+
+	if f.maxBodySize < 1 {
+		return nil, nil, errors.New("--max-body-size must be at least 1")
+	}
+	jsonMode, err := kafkaavro.ParseJSONMode(f.jsonMode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--json-mode: %w", err)
+	}
+	nonFinite, err := kafkaavro.ParseNonFinitePolicy(f.nonFinite)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--non-finite-floats: %w", err)
+	}
+
+	codec = kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithJSONMode(jsonMode), kafkaavro.WithNonFiniteFloats(nonFinite))
+	handler = kafkaavrohttp.NewHandler(codec, kafkaavrohttp.WithBearerToken(f.token), kafkaavrohttp.WithMaxBodySize(f.maxBodySize))
+	return
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestServeHandler(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `{"type":"record","name":"order","fields":[{"name":"note","type":["null","string"]}]}`)
+
+	var tests = []struct {
+		flags serveFlags
+		want  string
+	}{
+		{serveFlags{maxBodySize: 0, jsonMode: "avro", nonFinite: "error"}, "--max-body-size"},
+		{serveFlags{maxBodySize: 10, jsonMode: "plain", nonFinite: "error"}, "--json-mode"},
+		{serveFlags{maxBodySize: 10, jsonMode: "avro", nonFinite: "zero"}, "--non-finite-floats"},
+	}
+	for _, test := range tests {
+		if _, _, err := test.flags.handler(registry); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("handler(%+v) returned %v, want %q", test.flags, err, test.want)
+		}
+	}
+
+	handler, codec, err := serveFlags{token: "secret", maxBodySize: 1024, jsonMode: "standard", nonFinite: "error"}.handler(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer codec.Close()
+
+	// the JSON of the requests is in the --json-mode
+	request := httptest.NewRequest(http.MethodPost, "/encode?subject=orders-value", strings.NewReader(`{"note":"x"}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	encoded := httptest.NewRecorder()
+	handler.ServeHTTP(encoded, request)
+	if encoded.Code != http.StatusOK {
+		t.Fatalf("/encode returned %d %s", encoded.Code, encoded.Body)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/decode?topic=orders", bytes.NewReader(encoded.Body.Bytes()))
+	decoded := httptest.NewRecorder()
+	handler.ServeHTTP(decoded, request)
+	if decoded.Code != http.StatusUnauthorized {
+		t.Errorf("/decode without the token returned %d", decoded.Code)
+	}
+	request.Header.Set("Authorization", "Bearer secret")
+	decoded = httptest.NewRecorder()
+	handler.ServeHTTP(decoded, request)
+	if decoded.Code != http.StatusOK || decoded.Body.String() != `{"note":"x"}` {
+		t.Errorf("/decode returned %d %s", decoded.Code, decoded.Body)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+// snapshot keeps the latest value of every key of a topic for --snapshot.
+type snapshot struct {
+	topic   string
+	maxKeys int
+	entries map[string]*snapshotEntry // by the key data
+	deletes int64
+}
+
+// snapshotEntry is the latest value of a key, printed as a line of the snapshot.
+type snapshotEntry struct {
+	Key       interface{} `json:"key"`
+	Value     interface{} `json:"value"`
+	partition int32
+	offset    int64
+}
+
+func newSnapshot(topic string, maxKeys int) *snapshot {
+	return &snapshot{topic: topic, maxKeys: maxKeys, entries: make(map[string]*snapshotEntry)}
+}
+
+// validateSnapshot verifies the flags combined with --snapshot.
+func (f consumeFlags) validateSnapshot(followSet bool) error {
+	switch {
+	case len(f.topics) != 1 || f.topicRegex != "":
+		return errors.New("--snapshot requires a single --topic")
+	case len(f.ranges.between) > 0 || f.ranges.since != "":
+		return errors.New("--snapshot can not be combined with --between or --since, the latest values are only known from the beginning of the topic")
+	case followSet && f.follow:
+		return errors.New("--follow can not be combined with --snapshot")
+	case f.commit:
+		return errors.New("--commit can not be combined with --snapshot")
+	case f.outputFile != "":
+		return errors.New("--output-file can not be combined with --snapshot")
+	case f.maxKeys < 1:
+		return errors.New("--max-keys must be at least 1")
+	}
+	return nil
+}
+
+// put sets the value of the key of the message, it fails when the key is new and the snapshot
+// already holds --max-keys keys.
+func (s *snapshot) put(m *kafka.Message, key interface{}, value interface{}) error {
+
+	entry, found := s.entries[string(m.Key)]
+	if !found {
+		if len(s.entries) >= s.maxKeys {
+			return fmt.Errorf("%v has more than --max-keys %d keys (at %v), raise --max-keys to take the snapshot", s.topic, s.maxKeys, m.TopicPartition)
+		}
+		entry = &snapshotEntry{}
+		s.entries[string(m.Key)] = entry
+	}
+	entry.Key, entry.Value = key, value
+	entry.partition, entry.offset = m.TopicPartition.Partition, int64(m.TopicPartition.Offset)
+	return nil
+}
+
+// remove deletes the key of the tombstone message.
+func (s *snapshot) remove(m *kafka.Message) {
+	delete(s.entries, string(m.Key))
+	s.deletes++
+}
+
+// sorted returns the entries in the order of their partition and offset, the order of the latest
+// messages in the topic.
+func (s *snapshot) sorted() (entries []*snapshotEntry) {
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].partition != entries[j].partition {
+			return entries[i].partition < entries[j].partition
+		}
+		return entries[i].offset < entries[j].offset
+	})
+	return
+}
+
+func (c *consumer) handleSnapshotMessage(m *kafka.Message) (err error) {
+
+	if c.stats != nil {
+		c.stats.message(m)
+	}
+
+	if len(m.Value) == 0 {
+		c.snapshot.remove(m)
+		return
+	}
+
+	key, decodeErr := c.decodeKey(m)
+	var value interface{}
+	if decodeErr == nil {
+		value, _, decodeErr = c.decode(m)
+	}
+	if decodeErr != nil {
+		if c.stats != nil {
+			c.stats.decodeError()
+		}
+		return c.errors.handle(m, decodeErr, os.Stderr)
+	}
+
+	return c.snapshot.put(m, key, value)
+}
+
+// decodeKey decodes a key in the wire format, other keys are printed as strings.
+func (c *consumer) decodeKey(m *kafka.Message) (key interface{}, err error) {
+
+	if m.Key == nil {
+		return nil, nil
+	}
+	if _, _, err = kafkaavro.ParseWireFormat(m.Key); err != nil {
+		return string(m.Key), nil
+	}
+	key, _, err = c.decodeData(*m.TopicPartition.Topic, true, m.Key)
+	return
+}
+
+// writeSnapshot prints the entries of the snapshot as NDJSON once all the partitions reached
+// their end, the projection and filters apply to the values.
+func (c *consumer) writeSnapshot(w io.Writer) (err error) {
+
+	if !c.ranges.done() {
+		fmt.Fprintf(w, "Stopped before the end of %v, the snapshot is incomplete and not printed\n", c.snapshot.topic)
+		return
+	}
+
+	entries := c.snapshot.sorted()
+	for _, entry := range entries {
+		if c.projection != nil {
+			entry.Value = c.projection.project(entry.Value)
+		}
+		if c.filter != nil && !c.filter.match(entry.Value) {
+			continue
+		}
+		if c.flags.countOnly {
+			c.count++
+			continue
+		}
+		if err = c.output.Encode(entry); err != nil {
+			return
+		}
+	}
+	fmt.Fprintf(w, "Snapshot of %v: %d keys, %d deletes\n", c.snapshot.topic, len(entries), c.snapshot.deletes)
+	return
+}
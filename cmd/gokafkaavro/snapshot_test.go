@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestValidateSnapshot(t *testing.T) {
+
+	orders := stringsFlag{"orders"}
+	var tests = []struct {
+		flags     consumeFlags
+		followSet bool
+		want      string
+	}{
+		{consumeFlags{topics: orders, maxKeys: 10, follow: true}, false, ""},
+		{consumeFlags{topics: orders, maxKeys: 10, ranges: rangeFlags{until: "2024-01-01"}}, false, ""},
+		{consumeFlags{topics: stringsFlag{"orders", "payments"}, maxKeys: 10}, false, "requires a single --topic"},
+		{consumeFlags{topicRegex: "orders.*", maxKeys: 10}, false, "requires a single --topic"},
+		{consumeFlags{topics: orders, maxKeys: 10, ranges: rangeFlags{since: "2024-01-01"}}, false, "--between or --since"},
+		{consumeFlags{topics: orders, maxKeys: 10, follow: true}, true, "--follow"},
+		{consumeFlags{topics: orders, maxKeys: 10, commit: true}, false, "--commit"},
+		{consumeFlags{topics: orders, maxKeys: 10, outputFile: "orders.avro"}, false, "--output-file"},
+		{consumeFlags{topics: orders}, false, "--max-keys must be at least 1"},
+	}
+
+	for _, test := range tests {
+		err := test.flags.validateSnapshot(test.followSet)
+		if (err == nil) != (test.want == "") || (err != nil && !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("validateSnapshot(%+v, %v) returned %v, want %q", test.flags, test.followSet, err, test.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-key", `{"type":"record","name":"orderKey","fields":[{"name":"id","type":"long"}]}`)
+	registry.Register("orders-value", `{"type":"record","name":"order","fields":[{"name":"status","type":"string"}]}`)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	message := func(partition int32, offset kafka.Offset, key interface{}, status string) *kafka.Message {
+		m := testMessage(partition, offset)
+		var err error
+		if plain, ok := key.(string); ok {
+			m.Key = []byte(plain)
+		} else if m.Key, err = codec.Encode("orders", true, key); err != nil {
+			t.Fatal(err)
+		}
+		if status != "" {
+			if m.Value, err = codec.Encode("orders", false, map[string]interface{}{"status": status}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return m
+	}
+	key := func(id int64) interface{} { return map[string]interface{}{"id": id} }
+
+	handler, err := newErrorHandler(onErrorSkip, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	c := &consumer{
+		flags:    consumeFlags{maxKeys: 3},
+		codec:    codec,
+		output:   json.NewEncoder(&output),
+		errors:   handler,
+		ranges:   newRangeTracker("orders", nil, time.Now()),
+		snapshot: newSnapshot("orders", 3),
+	}
+
+	for _, m := range []*kafka.Message{
+		message(0, 0, key(1), "NEW"),
+		message(1, 0, key(2), "NEW"),
+		message(0, 1, key(3), "NEW"),
+		message(0, 2, key(1), "SHIPPED"),
+		message(1, 1, key(2), ""), // a tombstone deletes the key
+		message(1, 2, "plain", "NEW"),
+	} {
+		if err = c.handleMessage(m); err != nil {
+			t.Fatalf("handleMessage(%v) failed: %v", m.TopicPartition, err)
+		}
+	}
+
+	// the deleted key made room for the plain key, a fourth key is one too many
+	if err = c.handleMessage(message(1, 3, key(4), "NEW")); err == nil || !strings.Contains(err.Error(), "--max-keys 3") {
+		t.Errorf("handleMessage() of a fourth key returned %v", err)
+	}
+
+	var summary bytes.Buffer
+	if err = c.writeSnapshot(&summary); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"key":{"id":3},"value":{"status":"NEW"}}
+{"key":{"id":1},"value":{"status":"SHIPPED"}}
+{"key":"plain","value":{"status":"NEW"}}
+`
+	if output.String() != want {
+		t.Errorf("writeSnapshot() printed %q, want %q", output.String(), want)
+	}
+	if want := "Snapshot of orders: 3 keys, 1 deletes\n"; summary.String() != want {
+		t.Errorf("writeSnapshot() reported %q, want %q", summary.String(), want)
+	}
+
+	// nothing is printed before the end of every partition
+	output.Reset()
+	c.ranges = newRangeTracker("orders", []offsetRange{{partition: 0, start: 0, end: 10}}, time.Now())
+	if err = c.writeSnapshot(&summary); err != nil || output.Len() > 0 {
+		t.Errorf("writeSnapshot() of an incomplete snapshot printed %q, %v", output.String(), err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+// statsReporter periodically reports the throughput, errors, schema cache efficiency and
+// consumer lag of the consume command.
+type statsReporter struct {
+	interval   time.Duration
+	cacheStats func() kafkaavro.CacheStats
+
+	start        time.Time
+	last         time.Time
+	messages     int64
+	bytes        int64
+	decodeErrors int64
+	lastMessages int64
+	lastBytes    int64
+	lag          map[string]int64
+}
+
+func newStatsReporter(interval time.Duration, now time.Time, cacheStats func() kafkaavro.CacheStats) *statsReporter {
+	return &statsReporter{
+		interval:   interval,
+		cacheStats: cacheStats,
+		start:      now,
+		last:       now,
+		lag:        make(map[string]int64),
+	}
+}
+
+func (s *statsReporter) message(m *kafka.Message) {
+	s.messages++
+	s.bytes += int64(len(m.Key) + len(m.Value))
+}
+
+func (s *statsReporter) decodeError() {
+	s.decodeErrors++
+}
+
+// librdkafkaStats takes the consumer lag of every partition out of the librdkafka statistics.
+func (s *statsReporter) librdkafkaStats(statsJSON string) error {
+
+	var stats struct {
+		Topics map[string]struct {
+			Partitions map[string]struct {
+				Partition   int32 `json:"partition"`
+				ConsumerLag int64 `json:"consumer_lag"`
+			} `json:"partitions"`
+		} `json:"topics"`
+	}
+
+	if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
+		return fmt.Errorf("invalid librdkafka statistics: %v", err)
+	}
+
+	for topic, topicStats := range stats.Topics {
+		for _, partitionStats := range topicStats.Partitions {
+			// partition -1 is the internal unassigned partition, a lag of -1 means it is unknown
+			if partitionStats.Partition < 0 || partitionStats.ConsumerLag < 0 {
+				continue
+			}
+			s.lag[fmt.Sprintf("%v [%d]", topic, partitionStats.Partition)] = partitionStats.ConsumerLag
+		}
+	}
+	return nil
+}
+
+// tick reports the statistics of the last interval, when it has passed.
+func (s *statsReporter) tick(now time.Time, w io.Writer) {
+
+	elapsed := now.Sub(s.last)
+	if elapsed < s.interval {
+		return
+	}
+
+	fmt.Fprintf(w, "Stats: %v\n", s.format(s.messages-s.lastMessages, s.bytes-s.lastBytes, elapsed))
+
+	s.last = now
+	s.lastMessages = s.messages
+	s.lastBytes = s.bytes
+}
+
+func (s *statsReporter) summary(now time.Time, w io.Writer) {
+	fmt.Fprintf(w, "Stats summary: %v\n", s.format(s.messages, s.bytes, now.Sub(s.start)))
+}
+
+func (s *statsReporter) format(messages int64, bytes int64, elapsed time.Duration) string {
+
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	cacheStats := s.cacheStats()
+
+	parts := []string{
+		fmt.Sprintf("%d messages (%.1f msg/s)", messages, float64(messages)/seconds),
+		fmt.Sprintf("%d bytes (%.1f B/s)", bytes, float64(bytes)/seconds),
+		fmt.Sprintf("%d decode errors", s.decodeErrors),
+		fmt.Sprintf("schema cache %d hits/%d misses", cacheStats.Hits, cacheStats.Misses),
+	}
+
+	if len(s.lag) > 0 {
+		partitions := make([]string, 0, len(s.lag))
+		for partition := range s.lag {
+			partitions = append(partitions, partition)
+		}
+		sort.Strings(partitions)
+
+		lags := make([]string, 0, len(partitions))
+		for _, partition := range partitions {
+			lags = append(lags, fmt.Sprintf("%v=%d", partition, s.lag[partition]))
+		}
+		parts = append(parts, "lag "+strings.Join(lags, " "))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+)
+
+const testLibrdkafkaStats = `{
+	"name": "rdkafka#consumer-1",
+	"topics": {
+		"orders": {
+			"topic": "orders",
+			"partitions": {
+				"0": {"partition": 0, "consumer_lag": 12},
+				"1": {"partition": 1, "consumer_lag": 0},
+				"2": {"partition": 2, "consumer_lag": -1},
+				"-1": {"partition": -1, "consumer_lag": -1}
+			}
+		}
+	}
+}`
+
+func TestStatsReporter(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cacheStats := func() kafkaavro.CacheStats { return kafkaavro.CacheStats{Hits: 9, Misses: 1} }
+	s := newStatsReporter(10*time.Second, start, cacheStats)
+
+	message := testMessage(0, 1)
+	message.Value = make([]byte, 100)
+	for i := 0; i < 10; i++ {
+		s.message(message)
+	}
+	s.decodeError()
+
+	if err := s.librdkafkaStats(testLibrdkafkaStats); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	s.tick(start.Add(5*time.Second), &out)
+	if out.Len() != 0 {
+		t.Errorf("reported before the interval passed: %v", out.String())
+	}
+
+	s.tick(start.Add(10*time.Second), &out)
+	want := "Stats: 10 messages (1.0 msg/s), 1000 bytes (100.0 B/s), 1 decode errors, schema cache 9 hits/1 misses, lag orders [0]=12 orders [1]=0\n"
+	if out.String() != want {
+		t.Errorf("tick reported %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	s.message(message)
+	s.summary(start.Add(20*time.Second), &out)
+	want = "Stats summary: 11 messages (0.6 msg/s), 1100 bytes (55.0 B/s), 1 decode errors, schema cache 9 hits/1 misses, lag orders [0]=12 orders [1]=0\n"
+	if out.String() != want {
+		t.Errorf("summary reported %q, want %q", out.String(), want)
+	}
+}
+
+func TestStatsReporterInvalidStatistics(t *testing.T) {
+	s := newStatsReporter(time.Second, time.Now(), nil)
+	if err := s.librdkafkaStats("not json"); err == nil {
+		t.Errorf("invalid statistics did not fail")
+	}
+}
@@ -1,25 +1,35 @@
 package kafkaavro
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	schemaregistry "github.com/lensesio/schema-registry"
-	"github.com/linkedin/goavro"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/schemaregistry"
 )
 
+// headerSize is the size of the magic byte and the schema id preceding the avro data.
+const headerSize = 5
+
 type SubjectName = string
 type AvroSchema = string
 type SubjectVersion = int
+type SchemaID = int
 
 type SubjectNameStrategy interface {
-	GetSubjectName(topic string, isKey bool)(subjectName SubjectName)
+	GetSubjectName(topic string, isKey bool) (subjectName SubjectName)
 }
 
 type TopicNameStrategy struct {
 }
 
-func (ts TopicNameStrategy) GetSubjectName(topic string, isKey bool)(subjectName SubjectName) {
+func (ts TopicNameStrategy) GetSubjectName(topic string, isKey bool) (subjectName SubjectName) {
 	if isKey {
 		subjectName = fmt.Sprintf("%v-key", topic)
 	} else {
@@ -28,96 +38,967 @@ func (ts TopicNameStrategy) GetSubjectName(topic string, isKey bool)(subjectName
 	return
 }
 
+// Decoder decodes the data of a single subject, it is safe for concurrent use.
 type Decoder struct {
-	client schemaregistry.Client
+	client      schemaregistry.Client
 	subjectName SubjectName
-	codecByVersion map[SubjectVersion]goavro.Codec
+	codecByID   *copyOnWriteMap[SchemaID, *goavro.Codec]
+	cacheStats  *CacheStats
 }
 
-func NewDecoder(client schemaregistry.Client, subjectName SubjectName)(decoder Decoder, err error) {
-	codecByVersion := make(map[SubjectVersion]goavro.Codec)
-	decoder = Decoder{client,subjectName, codecByVersion}
+// CacheStats counts the codec lookups which were served from the cache (hits) and the ones
+// which required a schema registry request (misses).
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// SchemaChurn is the number of distinct schema ids of the values of every topic within the
+	// window of WithSchemaChurnAlert, nil without it.
+	SchemaChurn map[string]int
+}
+
+func NewDecoder(client schemaregistry.Client, subjectName SubjectName) (decoder Decoder, err error) {
+	decoder = Decoder{client, subjectName, &copyOnWriteMap[SchemaID, *goavro.Codec]{}, &CacheStats{}}
 	return
 }
 
+func (d Decoder) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&d.cacheStats.Hits),
+		Misses: atomic.LoadUint64(&d.cacheStats.Misses),
+	}
+}
+
 func (d Decoder) Decode(data []byte) (native interface{}, err error) {
 
-	magicByte := data[0]
+	_, codec, err := d.codecFor(data)
+	if err != nil {
+		return
+	}
 
-	if magicByte != 0 {
-		err = errors.New("Unknown magic byte")
+	return decodeBody(codec, data[headerSize:])
+}
+
+// WriterSchema returns the schema id and avro schema with which the data was written.
+func (d Decoder) WriterSchema(data []byte) (schemaID SchemaID, avroSchema AvroSchema, err error) {
+
+	schemaID, codec, err := d.codecFor(data)
+	if err != nil {
 		return
 	}
 
-	subjectVersion := int(binary.BigEndian.Uint32((data[1:5])))
+	avroSchema = codec.Schema()
+	return
+}
+
+func (d Decoder) codecFor(data []byte) (schemaID SchemaID, codec *goavro.Codec, err error) {
 
-	codec, found := d.codecByVersion[subjectVersion]
-	if !found {
+	schemaID, err = parseHeader(data)
+	if err != nil {
+		return
+	}
 
-		schema, clientErr := d.client.GetSchemaBySubject(d.subjectName, subjectVersion)
+	codec, found := d.codecByID.get(schemaID)
+	if found {
+		atomic.AddUint64(&d.cacheStats.Hits, 1)
+	} else {
+		atomic.AddUint64(&d.cacheStats.Misses, 1)
+
+		avroSchema, clientErr := d.client.GetSchemaByID(schemaID)
 		if clientErr != nil {
-			err = clientErr
+			err = fmt.Errorf("failed to fetch schema %d: %w", schemaID, clientErr)
 			return
 		}
 
-		codecPtr, avroErr := goavro.NewCodec(schema.Schema)
-		if avroErr != nil {
-			err = avroErr
+		var avroErr error
+		if codec, avroErr = goavro.NewCodec(avroSchema); avroErr != nil {
+			err = newCodecBuildError(schemaregistry.Schema{Schema: avroSchema, ID: schemaID}, "", avroErr)
 			return
 		}
 
-		codec = *codecPtr
-		d.codecByVersion[subjectVersion] = codec
+		d.codecByID.put(schemaID, codec)
+	}
+
+	return
+}
+
+// parseHeader returns the schema id of the confluent wire format header: the magic byte 0
+// followed by the schema id as a 4 byte big endian integer.
+func parseHeader(data []byte) (schemaID SchemaID, err error) {
+
+	if len(data) < headerSize {
+		err = fmt.Errorf("%w: data of %d bytes is too short for the %d bytes header", ErrPayloadTooShort, len(data), headerSize)
+		return
 	}
 
-	native, _, err = codec.NativeFromBinary(data[5:])
+	magicByte := data[0]
+
+	if magicByte != 0 {
+		err = fmt.Errorf("%w %d, expected 0", ErrUnknownMagicByte, magicByte)
+		return
+	}
+
+	schemaID = int(binary.BigEndian.Uint32(data[1:headerSize]))
+	return
+}
+
+// ParseWireFormat splits data in the confluent wire format into the schema id and the avro
+// binary encoded body, without decoding the body. The body shares the memory of data.
+func ParseWireFormat(data []byte) (schemaID SchemaID, body []byte, err error) {
+	if schemaID, err = parseHeader(data); err != nil {
+		return
+	}
+	body = data[headerSize:]
 	return
 }
 
 type Encoder struct {
 	headerBytes []byte
-	codec goavro.Codec
+	codec       goavro.Codec
+	sizeHint    *atomic.Int64
+	// textual decodes the standard JSON of EncodeTextual, see WithJSONMode
+	textual *goavro.Codec
+	// floats accepts the strings of the non finite floats in EncodeTextual, nil without floats
+	floats *logicalSchema
+	// verify samples the encodes to decode again, nil without WithVerifyRoundTrip
+	verify *roundTrip
+	// plan is the registration of WithDryRunRegistration, nil without
+	plan *RegistrationPlan
 }
 
-func NewEncoder(client schemaregistry.Client, autoRegister bool, subjectName SubjectName, avroSchema AvroSchema)(encoder Encoder, err error) {
+// NewEncoder creates an Encoder of the schema, which is registered under the subject if
+// autoRegister is true and must be registered otherwise. Of the options, only
+// WithGoavroCodecBuilder, WithJSONMode, WithCompatibilityOnRegister, WithDryRunRegistration,
+// WithVerifyRoundTrip and WithVerifySampling apply to an Encoder.
+func NewEncoder(client schemaregistry.Client, autoRegister bool, subjectName SubjectName, avroSchema AvroSchema, options ...Option) (encoder Encoder, err error) {
 
-	var subjectVersion SubjectVersion
+	var schemaID SchemaID
 
-	if(autoRegister) {
-		subjectVersion, err = client.RegisterNewSchema(subjectName, avroSchema)
+	var config Codec
+	for _, option := range options {
+		option(&config)
+	}
+
+	var plan *RegistrationPlan
+	if autoRegister && config.dryRunRegistration {
+		var planned RegistrationPlan
+		if planned, err = PlanRegistration(client, subjectName, avroSchema); err != nil {
+			return
+		}
+		plan, schemaID = &planned, planned.SchemaID
+	} else if autoRegister {
+		level := config.compatibilityOnRegister
+		if level != "" {
+			if err = level.Validate(); err != nil {
+				return encoder, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+			}
+		}
+		schemaID, err = client.RegisterNewSchema(subjectName, avroSchema)
 		if err != nil {
+			err = fmt.Errorf("failed to register the schema under subject %v: %w", subjectName, err)
 			return
 		}
+		if level != "" {
+			if err = provisionCompatibility(client, subjectName, level); err != nil {
+				return
+			}
+		}
 	} else {
 		isRegistered, schema, clientErr := client.IsRegistered(subjectName, avroSchema)
 		if clientErr != nil {
-			err = clientErr
+			err = fmt.Errorf("failed to look up the registration of the schema under subject %v: %w", subjectName, clientErr)
 			return
 		}
 		if !isRegistered {
-			err = errors.New(fmt.Sprintf("There is no registration on subject %v for schema %v", subjectName, avroSchema))
+			err = fmt.Errorf("%w: there is no registration on subject %v for schema %v", ErrSchemaNotRegistered, subjectName, avroSchema)
 			return
 		}
 
-		subjectVersion = schema.Version
+		schemaID = schema.ID
 	}
 
-	headerBytes := make([]byte, 5) // 5 bytes, first byte is the magic byte with value 0
-	binary.BigEndian.PutUint32(headerBytes[1:], uint32(subjectVersion)) // the next 4 bytes are the subject version
+	headerBytes := make([]byte, headerSize)                       // 5 bytes, first byte is the magic byte with value 0
+	binary.BigEndian.PutUint32(headerBytes[1:], uint32(schemaID)) // the next 4 bytes are the schema id
 
-	codec, codecErr := goavro.NewCodec(avroSchema)
+	var codec *goavro.Codec
+	var codecErr error
+	config.profiled(context.Background(), OperationBuildCodec, "", subjectName, schemaID, func(context.Context) {
+		codec, codecErr = config.newGoavroCodec(avroSchema)
+	})
 	if codecErr != nil {
-		err = codecErr
+		err = newCodecBuildError(schemaregistry.Schema{Schema: avroSchema, ID: schemaID}, subjectName, codecErr)
 		return
 	}
 
-	encoder = Encoder{ headerBytes, *codec}
+	var textual *goavro.Codec
+	if config.jsonMode == JSONModeStandard {
+		if textual, codecErr = goavro.NewCodecForStandardJSONFull(avroSchema); codecErr != nil {
+			err = fmt.Errorf("%w for standard JSON of subject %v: %w", ErrCodecBuild, subjectName, codecErr)
+			return
+		}
+	}
+
+	floats, floatsErr := newFloatSchema(avroSchema)
+	if floatsErr != nil {
+		err = fmt.Errorf("%w of subject %v: %w", ErrCodecBuild, subjectName, floatsErr)
+		return
+	}
+
+	encoder = Encoder{headerBytes, *codec, &atomic.Int64{}, textual, floats, config.newRoundTrip(), plan}
+	return
+}
+
+// provisionCompatibility sets the compatibility level of the subject unless it has a level of its
+// own, which an operator may have changed since the subject was provisioned.
+func provisionCompatibility(client schemaregistry.Client, subjectName SubjectName, level schemaregistry.CompatibilityLevel) (err error) {
+
+	ctx := context.Background()
+	_, found, err := client.GetSubjectCompatibility(ctx, subjectName)
+	if err != nil {
+		return fmt.Errorf("failed to get the compatibility level of subject %v: %w", subjectName, err)
+	}
+	if found {
+		return nil
+	}
+	if err = client.SetCompatibility(ctx, subjectName, level); err != nil {
+		return fmt.Errorf("failed to set the compatibility level of subject %v to %v: %w", subjectName, level, err)
+	}
+	return nil
+}
+
+func (e Encoder) Encode(native interface{}) (avroBytes []byte, err error) {
+	if e.plan != nil && !e.plan.Registered {
+		return nil, fmt.Errorf("%w: the dry run did not register the schema under subject %v", ErrSchemaNotRegistered, e.plan.Subject)
+	}
+	if avroBytes, err = encodeFramed(e.headerBytes, &e.codec, e.sizeHint, native); err != nil {
+		return
+	}
+	if err = e.verify.verify(&e.codec, avroBytes, native); err != nil {
+		return nil, err
+	}
 	return
 }
 
-func (e Encoder) Encode(native interface{})(avroBytes []byte, err error) {
-	dataBytes, err := e.codec.BinaryFromNative(nil, native)
-	avroBytes = append(e.headerBytes, dataBytes...)
+// encodeFramed encodes the native value after the header in a single allocation: the size of
+// the largest message encoded so far is remembered in the sizeHint.
+func encodeFramed(header []byte, codec *goavro.Codec, sizeHint *atomic.Int64, native interface{}) (data []byte, err error) {
+
+	data = make([]byte, headerSize, headerSize+int(sizeHint.Load()))
+	copy(data, header)
+
+	if data, err = codec.BinaryFromNative(data, native); err != nil {
+		return nil, err
+	}
+
+	if size := int64(len(data) - headerSize); size > sizeHint.Load() {
+		sizeHint.Store(size)
+	}
 	return
 }
 
+// EncodeTextual encodes the JSON of a value, in the encoding of WithJSONMode. The floats and
+// doubles may be the strings "NaN", "Infinity" and "-Infinity", see WithNonFiniteFloats.
+func (e Encoder) EncodeTextual(textual []byte) (avroBytes []byte, err error) {
+	codec := e.textual
+	if codec == nil {
+		codec = &e.codec
+	}
+	var native interface{}
+	if e.floats != nil {
+		native, err = e.floats.nativeFromTextual(codec, textual, e.textual != nil)
+	} else {
+		native, _, err = codec.NativeFromTextual(textual)
+	}
+	if err != nil {
+		return
+	}
+	return e.Encode(native)
+}
+
+// RegistryClient is the part of the schema registry client used by the Codec. It is implemented
+// by *schemaregistry.Client, by *mockregistry.Registry for tests and by SchemaByIDFunc for the
+// Codecs which only decode.
+type RegistryClient interface {
+	GetSchemaByID(id int) (avroSchema string, err error)
+	GetLatestSchema(subject string) (schema schemaregistry.Schema, err error)
+}
+
+type subjectKey struct {
+	topic string
+	isKey bool
+}
+
+// encoderSchema is the schema with which the messages of a subject are encoded,
+// along with the header of those messages.
+type encoderSchema struct {
+	schemaID SchemaID
+	header   []byte
+	codec    *goavro.Codec
+	sizeHint *atomic.Int64
+	// encoder encodes the schemas of another schema type than avro, see EncodingSchemaType.
+	encoder BodyEncoder
+}
+
+// Codec decodes and encodes the keys and values of any topic. Decoding looks up the writer
+// schema by the schema id in the data, encoding uses the latest schema of the subject of the topic.
+// The subjects, schemas and goavro codecs are cached.
+//
+// A Codec is safe for concurrent use, all methods can be called from any goroutine and one Codec
+// can be shared by the whole process. The caches are read without locks, concurrent first uses of
+// a schema id or subject may each fetch the schema from the registry but only one is cached and
+// OnNewSchemaObserved is reported once. A DecodeScratch must not be shared between goroutines.
+type Codec struct {
+	client              RegistryClient
+	subjectNameStrategy SubjectNameStrategy
+	// the registry subjects of the subjects of the strategy, see WithSubjectAliases
+	subjectAliases map[SubjectName]SubjectName
+
+	subjects       copyOnWriteMap[subjectKey, SubjectName]
+	codecByID      copyOnWriteMap[SchemaID, *goavro.Codec]
+	encoderSchemas copyOnWriteMap[SubjectName, encoderSchema]
+	observed       copyOnWriteMap[observedKey, SchemaInfo]
+	logicalSchemas copyOnWriteMap[*goavro.Codec, *logicalSchema]
+	decoderByID    copyOnWriteMap[SchemaID, schemaDecoder]
+	// the schema ids which did not build, see WithCodecBuildFailureTTL
+	codecBuildFailures   copyOnWriteMap[SchemaID, codecBuildFailure]
+	codecBuildFailureTTL time.Duration
+	topicStats           copyOnWriteMap[string, *topicCounters]
+	pprofLabels          bool
+	dryRunRegistration   bool
+	// the standard JSON codecs of the codecs, see WithJSONMode
+	standardJSONCodecs copyOnWriteMap[*goavro.Codec, *goavro.Codec]
+	// the float and double nodes of the schemas of the codecs, see WithNonFiniteFloats
+	floatSchemas copyOnWriteMap[*goavro.Codec, *logicalSchema]
+
+	warmUpConcurrency int
+	clock             Clock
+	logger            *slog.Logger
+	metrics           MetricsHook
+	tracer            Tracer
+	hooks             Hooks
+	noPayloadPreview  bool
+	maxPayloadSize    int
+	maxSchemaSize     int
+	maxCollectionSize int64
+	validateSubjects  bool
+	retry             RetryPolicy
+	strictConfig      bool
+	clientOptions     []schemaregistry.Option
+	startupPing       bool
+	metadataHeaders   *MetadataHeaders
+	logicalTypes      bool
+	enums             enumTypes
+	schemaTypes       map[string]SchemaType
+	schemaContext     string
+	offline           bool
+	codecBuilder      func(schema string) (*goavro.Codec, error)
+	jsonMode          JSONMode
+	nonFinite         NonFinitePolicy
+	// the wait for the unknown schema ids of WithAwaitSchema
+	awaitSchemaTimeout, awaitSchemaInterval time.Duration
+	// the level NewEncoder sets, see WithCompatibilityOnRegister
+	compatibilityOnRegister schemaregistry.CompatibilityLevel
+	// the encodes decoded again, see WithVerifyRoundTrip
+	verifyRoundTrip bool
+	verifySampling  int
+	roundTrip       *roundTrip
+	// the schema id of DecodeWithHeaders when the header and the framing disagree
+	schemaIDPrecedence SchemaIDPrecedence
+	// the schema ids of the topics within the window of WithSchemaChurnAlert
+	churn   *schemaChurn
+	options []Option
+
+	// the codecs of the registries of WithRegistryRouter, by topic and by registry
+	router       func(topic string, isKey bool) RegistryClient
+	routes       copyOnWriteMap[subjectKey, *Codec]
+	registries   copyOnWriteMap[interface{}, *Codec]
+	registryName string
+
+	hits   uint64
+	misses uint64
+	closed *atomic.Bool
+
+	lastRegistryRequest registryRequest
+}
+
+// NewCodec creates a Codec which fetches the schemas from the registry client, e.g. a
+// *schemaregistry.Client, and resolves the subjects of topics with the subjectNameStrategy.
+func NewCodec(client RegistryClient, subjectNameStrategy SubjectNameStrategy, options ...Option) *Codec {
+
+	codec := &Codec{
+		client:               client,
+		subjectNameStrategy:  subjectNameStrategy,
+		warmUpConcurrency:    defaultWarmUpConcurrency,
+		maxPayloadSize:       DefaultMaxPayloadSize,
+		maxSchemaSize:        DefaultMaxSchemaSize,
+		maxCollectionSize:    DefaultMaxCollectionSize,
+		codecBuildFailureTTL: DefaultCodecBuildFailureTTL,
+		clock:                realClock{},
+		options:              options,
+		closed:               &atomic.Bool{},
+	}
+	for _, option := range options {
+		option(codec)
+	}
+	codec.roundTrip = codec.newRoundTrip()
+	return codec
+}
+
+// CacheStats returns how many schema lookups were served from the cache.
+func (c *Codec) CacheStats() (stats CacheStats) {
+	c.eachRegistry(func(codec *Codec) {
+		stats.Hits += atomic.LoadUint64(&codec.hits)
+		stats.Misses += atomic.LoadUint64(&codec.misses)
+		if codec.churn != nil {
+			if stats.SchemaChurn == nil {
+				stats.SchemaChurn = make(map[string]int)
+			}
+			codec.churn.counts(codec.clock.Now(), stats.SchemaChurn)
+		}
+	})
+	return
+}
+
+// Subject returns the subject of the key or value of the topic, or its alias, see
+// WithSubjectAliases. The subject is computed only once per topic.
+func (c *Codec) Subject(topic string, isKey bool) SubjectName {
+
+	key := subjectKey{topic, isKey}
+
+	if subjectName, found := c.subjects.get(key); found {
+		return subjectName
+	}
+
+	subjectName, _ := c.alias(c.subjectNameStrategy.GetSubjectName(topic, isKey))
+	c.subjects.put(key, subjectName)
+	return subjectName
+}
+
+// Decode decodes the key or value of a message of the topic. As the writer schema is identified
+// by the schema id in the data, no subject is resolved while decoding.
+func (c *Codec) Decode(topic string, isKey bool, data []byte) (native interface{}, err error) {
+	return c.DecodeContext(context.Background(), topic, isKey, data)
+}
+
+// DecodeContext decodes like Decode, the spans of the decode (see WithTracer) are children of the
+// span in the context.
+func (c *Codec) DecodeContext(ctx context.Context, topic string, isKey bool, data []byte) (native interface{}, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		native, err = r.DecodeContext(ctx, topic, isKey, data)
+		return native, r.registryError(err)
+	}
+	return c.decodeContext(ctx, topic, isKey, data, nil)
+}
+
+// decodeContext decodes the data, or the data read from the reader, see DecodeReader.
+func (c *Codec) decodeContext(ctx context.Context, topic string, isKey bool, data []byte, reader io.Reader) (native interface{}, err error) {
+
+	var span Span
+	if c.tracer != nil {
+		ctx, span = c.tracer.Start(ctx, SpanDecode)
+	}
+
+	var schemaID SchemaID
+	var codec *goavro.Codec
+	var cached bool
+	if reader != nil {
+		data, err = readHeader(reader)
+	}
+	if err == nil {
+		err = c.checkPayloadSize(data)
+	}
+	if err == nil {
+		schemaID, codec, cached, err = c.codecFor(ctx, topic, data)
+	}
+	if reader != nil && (err == nil || errors.Is(err, ErrUnsupportedSchemaType)) {
+		// the body is read once the writer schema is known
+		var readErr error
+		if data, readErr = c.readBody(data, reader); readErr != nil {
+			err = readErr
+		}
+	}
+	if err == nil {
+		if native, err = c.decodeBody(codec, data[headerSize:]); err == nil {
+			c.observe(topic, isKey, schemaID, codec.Schema(), schemaregistry.SchemaTypeAvro)
+		}
+	} else if errors.Is(err, ErrUnsupportedSchemaType) {
+		native, err = c.decodeOther(topic, isKey, schemaID, data, err)
+	}
+	if err != nil {
+		err = c.decodeFailed(topic, schemaID, data, err)
+	}
+
+	if span != nil {
+		span.SetAttribute(AttributeTopic, topic)
+		span.SetAttribute(AttributeSubject, c.Subject(topic, isKey))
+		span.SetAttribute(AttributePayloadSize, len(data))
+		if schemaID > 0 {
+			span.SetAttribute(AttributeSchemaID, schemaID)
+			span.SetAttribute(AttributeCacheHit, cached)
+		}
+		span.End(err)
+	}
+	c.topicDecoded(topic, isKey, schemaID, data, err)
+	if c.metrics != nil {
+		c.metrics.Decoded(err)
+	}
+	return
+}
+
+// DecodeScratch holds the state which DecodeReuse carries from one message to the next.
+// A DecodeScratch must not be shared between goroutines.
+type DecodeScratch struct {
+	topic    string
+	schemaID SchemaID
+	codec    *goavro.Codec
+}
+
+// DecodeReuse decodes like Decode, but remembers the codec of the last schema id in the scratch so
+// that a stream of messages with the same writer schema skips the cache lookup. goavro allocates a
+// new native value for every message, so the native values are not reused. A nil scratch decodes
+// like Decode.
+func (c *Codec) DecodeReuse(topic string, isKey bool, data []byte, scratch *DecodeScratch) (native interface{}, err error) {
+
+	if scratch == nil {
+		return c.Decode(topic, isKey, data)
+	}
+	if r := c.route(topic, isKey); r != c {
+		native, err = r.DecodeReuse(topic, isKey, data, scratch)
+		return native, r.registryError(err)
+	}
+
+	var schemaID SchemaID
+	defer func() {
+		if err != nil {
+			err = c.decodeFailed(topic, schemaID, data, err)
+		}
+		c.topicDecoded(topic, isKey, schemaID, data, err)
+		if c.metrics != nil {
+			c.metrics.Decoded(err)
+		}
+	}()
+
+	if err = c.checkPayloadSize(data); err != nil {
+		return
+	}
+
+	schemaID, err = parseHeader(data)
+	if err != nil {
+		return
+	}
+
+	if err = c.checkOpen(); err != nil {
+		return
+	}
+	if scratch.codec != nil && scratch.schemaID == schemaID && scratch.topic == topic {
+		c.cacheHit()
+		native, err = c.decodeBody(scratch.codec, data[headerSize:])
+		return
+	}
+
+	if _, scratch.codec, _, err = c.codecFor(context.Background(), topic, data); err != nil {
+		scratch.codec = nil
+		if errors.Is(err, ErrUnsupportedSchemaType) {
+			native, err = c.decodeOther(topic, isKey, schemaID, data, err)
+		}
+		return
+	}
+	if native, err = c.decodeBody(scratch.codec, data[headerSize:]); err != nil {
+		scratch.codec = nil
+		return
+	}
+
+	// the schema of the topic is only observed when it changes
+	scratch.topic, scratch.schemaID = topic, schemaID
+	c.observe(topic, isKey, schemaID, scratch.codec.Schema(), schemaregistry.SchemaTypeAvro)
+	return
+}
+
+// decodeBody decodes the avro data after the header and converts the logical types and enums.
+func (c *Codec) decodeBody(codec *goavro.Codec, body []byte) (native interface{}, err error) {
+	if native, err = c.decodeLimited(codec, body); err == nil && c.converting() {
+		native, err = c.fromAvro(codec, native)
+	}
+	return
+}
+
+// decodeFailed wraps the error of a decode in a DecodeError and calls the OnDecodeError hook.
+func (c *Codec) decodeFailed(topic string, schemaID SchemaID, data []byte, err error) error {
+
+	decodeErr := &DecodeError{Size: len(data), Err: err}
+	if !c.noPayloadPreview {
+		decodeErr.Head = append([]byte(nil), data[:min(len(data), payloadPreviewSize)]...)
+	}
+
+	if c.hooks.OnDecodeError != nil {
+		c.callHook("OnDecodeError", c.hooks.OnDecodeError, HookEvent{Topic: topic, SchemaID: schemaID, Err: decodeErr})
+	}
+	return decodeErr
+}
+
+// WriterSchema returns the schema id and avro schema with which the data was written, or the
+// schema of another schema type, see WithSchemaType.
+func (c *Codec) WriterSchema(data []byte) (schemaID SchemaID, avroSchema AvroSchema, err error) {
+
+	if r := c.registryCodec(nil); r != c {
+		schemaID, avroSchema, err = r.WriterSchema(data)
+		return schemaID, avroSchema, r.registryError(err)
+	}
+
+	schemaID, codec, _, err := c.codecFor(context.Background(), "", data)
+	if decoder, found := c.decoderByID.get(schemaID); found && decoder.decoder != nil && errors.Is(err, ErrUnsupportedSchemaType) {
+		return schemaID, decoder.schema.Schema, nil
+	}
+	if err != nil {
+		return
+	}
+
+	avroSchema = codec.Schema()
+	return
+}
+
+// codecFor returns the codec of the schema id in the data, cached is false if it was fetched.
+// The topic is only used for the hooks. For a schema of another schema type than avro, it fails
+// with ErrUnsupportedSchemaType, the decoder of the schema is cached if there is one.
+func (c *Codec) codecFor(ctx context.Context, topic string, data []byte) (schemaID SchemaID, codec *goavro.Codec, cached bool, err error) {
+
+	if err = c.checkOpen(); err != nil {
+		return
+	}
+	if schemaID, err = parseHeader(data); err != nil {
+		return
+	}
+
+	codec, cached = c.codecByID.get(schemaID)
+	if cached {
+		c.cacheHit()
+		return
+	}
+	if decoder, found := c.decoderByID.get(schemaID); found {
+		c.cacheHit()
+		return schemaID, nil, true, decoder.notAvro(schemaID)
+	}
+	if err = c.codecBuildFailed(schemaID); err != nil {
+		return
+	}
+	c.cacheMiss()
+
+	debug := c.debugEnabled()
+	if debug {
+		c.logger.Debug("schema cache miss", "schema_id", schemaID)
+	}
+	if c.hooks.OnCacheMiss != nil {
+		c.callHook("OnCacheMiss", c.hooks.OnCacheMiss, HookEvent{Topic: topic, SchemaID: schemaID})
+	}
+	if c.offline {
+		err = notCached(fmt.Sprintf("schema %d", schemaID), topic)
+		return
+	}
+
+	var span Span
+	var schema schemaregistry.Schema
+	start := c.clock.Now()
+	c.profiled(ctx, OperationGetSchemaByID, topic, "", schemaID, func(ctx context.Context) {
+		if c.tracer != nil {
+			_, span = c.tracer.Start(ctx, SpanGetSchemaByID)
+			span.SetAttribute(AttributeSchemaID, schemaID)
+		}
+		event := HookEvent{Topic: topic, SchemaID: schemaID}
+		fetch := func() error {
+			return c.withRetry(ctx, event, func() (err error) {
+				schema, err = c.fetchSchema(schemaID)
+				return
+			})
+		}
+		err = c.awaitSchema(ctx, event, fetch(), fetch)
+	})
+	latency := c.clock.Now().Sub(start)
+	c.lastRegistryRequest.record(latency, err)
+	if span != nil {
+		span.End(err)
+	}
+	if c.metrics != nil {
+		c.metrics.RegistryRequest(OperationGetSchemaByID, latency, err)
+	}
+	if c.hooks.OnSchemaFetched != nil {
+		c.callHook("OnSchemaFetched", c.hooks.OnSchemaFetched, HookEvent{Topic: topic, SchemaID: schemaID, Latency: latency, Err: err})
+	}
+	if err != nil {
+		if debug {
+			c.logger.Debug("schema fetch failed", "schema_id", schemaID, "latency", latency, "error", err)
+		}
+		err = fmt.Errorf("failed to fetch schema %d: %w", schemaID, err)
+		return
+	}
+	if debug {
+		c.logger.Debug("schema fetched", "schema_id", schemaID, "latency", latency)
+	}
+	if err = c.checkSchemaSize(schema.Schema, fmt.Sprintf("schema %d", schemaID)); err != nil {
+		return
+	}
+
+	if schema.Type() != schemaregistry.SchemaTypeAvro {
+		err = c.newSchemaDecoder(schemaID, schema)
+		return
+	}
+	c.profiled(ctx, OperationBuildCodec, topic, SubjectName(schema.Subject), schemaID, func(context.Context) {
+		codec, err = c.newGoavroCodec(schema.Schema)
+	})
+	if err != nil {
+		schema.ID = schemaID
+		err = newCodecBuildError(schema, "", err)
+		c.rememberCodecBuildFailure(schemaID, err)
+		return
+	}
+
+	c.codecByID.put(schemaID, codec)
+	if c.metrics != nil {
+		c.metrics.SchemaCacheSize(c.codecByID.len())
+	}
+	return
+}
+
+// Encode encodes the key or value of a message of the topic with the latest schema of its subject.
+func (c *Codec) Encode(topic string, isKey bool, native interface{}) (data []byte, err error) {
+	return c.EncodeContext(context.Background(), topic, isKey, native)
+}
+
+// EncodeContext encodes like Encode, the spans of the encode (see WithTracer) are children of the
+// span in the context.
+func (c *Codec) EncodeContext(ctx context.Context, topic string, isKey bool, native interface{}) (data []byte, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		data, err = r.EncodeContext(ctx, topic, isKey, native)
+		return data, r.registryError(err)
+	}
+
+	var span Span
+	if c.tracer != nil {
+		ctx, span = c.tracer.Start(ctx, SpanEncode)
+	}
+
+	subjectName := c.Subject(topic, isKey)
+	schema, cached, err := c.encoderSchemaFor(ctx, topic, subjectName)
+	switch {
+	case err != nil:
+	case schema.encoder != nil:
+		data, err = schema.encoder.AppendBody(append(make([]byte, 0, headerSize+int(schema.sizeHint.Load())), schema.header...), native)
+		if size := int64(len(data) - headerSize); err == nil && size > schema.sizeHint.Load() {
+			schema.sizeHint.Store(size)
+		}
+	default:
+		if c.converting() {
+			native, err = c.toAvro(schema.codec, native)
+		}
+		if err == nil {
+			data, err = encodeFramed(schema.header, schema.codec, schema.sizeHint, native)
+		}
+		if err == nil {
+			if err = c.roundTrip.verify(schema.codec, data, native); err != nil {
+				data = nil
+			}
+		}
+	}
+	err = c.aliasError(topic, isKey, err)
+	if err != nil && c.hooks.OnEncodeError != nil {
+		c.callHook("OnEncodeError", c.hooks.OnEncodeError, HookEvent{Topic: topic, Subject: subjectName, SchemaID: schema.schemaID, Err: err})
+	}
+
+	if span != nil {
+		span.SetAttribute(AttributeTopic, topic)
+		span.SetAttribute(AttributeSubject, subjectName)
+		span.SetAttribute(AttributePayloadSize, len(data))
+		span.SetAttribute(AttributeCacheHit, cached)
+		if schema.header != nil {
+			span.SetAttribute(AttributeSchemaID, schema.schemaID)
+		}
+		span.End(err)
+	}
+	c.topicEncoded(topic, isKey, schema.schemaID, data, err)
+	if c.metrics != nil {
+		c.metrics.Encoded(err)
+	}
+	return
+}
+
+// EncodeFramed frames the avro body with the magic byte and the schema id, without looking up the
+// schema: the body must be written with the schema of the id, e.g. the body of a message of which
+// the schema id is kept when it is published to another topic.
+func (c *Codec) EncodeFramed(schemaID SchemaID, avroBody []byte) []byte {
+
+	data := make([]byte, headerSize, headerSize+len(avroBody))
+	binary.BigEndian.PutUint32(data[1:], uint32(schemaID))
+	return append(data, avroBody...)
+}
+
+// EncodeWithSchemaID encodes the value with the schema of the id rather than with the latest
+// schema of a subject. The schema is fetched by id and cached, like the writer schemas of Decode.
+func (c *Codec) EncodeWithSchemaID(schemaID SchemaID, native interface{}) (data []byte, err error) {
+
+	if r := c.registryCodec(nil); r != c {
+		data, err = r.EncodeWithSchemaID(schemaID, native)
+		return data, r.registryError(err)
+	}
+
+	header := c.EncodeFramed(schemaID, nil)
+	_, codec, _, err := c.codecFor(context.Background(), "", header)
+	if err == nil && c.converting() {
+		native, err = c.toAvro(codec, native)
+	}
+	if err == nil {
+		data, err = codec.BinaryFromNative(header, native)
+	}
+	if err == nil {
+		if err = c.roundTrip.verify(codec, data, native); err != nil {
+			data = nil
+		}
+	}
+	if c.metrics != nil {
+		c.metrics.Encoded(err)
+	}
+	return
+}
+
+// encoderSchemaFor returns the latest schema of the subject, cached is false if it was fetched.
+// The topic is only used for the hooks.
+func (c *Codec) encoderSchemaFor(ctx context.Context, topic string, subjectName SubjectName) (schema encoderSchema, cached bool, err error) {
+
+	if err = c.checkOpen(); err != nil {
+		return
+	}
+	schema, cached = c.encoderSchemas.get(subjectName)
+	if cached {
+		c.cacheHit()
+		return
+	}
+	if err = c.checkSubject(subjectName, topic); err != nil {
+		return
+	}
+	c.cacheMiss()
+
+	debug := c.debugEnabled()
+	if debug {
+		c.logger.Debug("schema cache miss", "subject", subjectName)
+	}
+	if c.hooks.OnCacheMiss != nil {
+		c.callHook("OnCacheMiss", c.hooks.OnCacheMiss, HookEvent{Topic: topic, Subject: subjectName})
+	}
+	if c.offline {
+		err = notCached("the latest schema of subject "+subjectName, topic)
+		return
+	}
+
+	var span Span
+	var latest schemaregistry.Schema
+	start := c.clock.Now()
+	c.profiled(ctx, OperationGetLatestSchema, topic, subjectName, 0, func(ctx context.Context) {
+		if c.tracer != nil {
+			_, span = c.tracer.Start(ctx, SpanGetLatestSchema)
+			span.SetAttribute(AttributeSubject, subjectName)
+		}
+		err = c.withRetry(ctx, HookEvent{Topic: topic, Subject: subjectName}, func() (err error) {
+			latest, err = c.client.GetLatestSchema(subjectName)
+			return
+		})
+	})
+	latency := c.clock.Now().Sub(start)
+	c.lastRegistryRequest.record(latency, err)
+	if span != nil {
+		if err == nil {
+			span.SetAttribute(AttributeSchemaID, latest.ID)
+		}
+		span.End(err)
+	}
+	if c.metrics != nil {
+		c.metrics.RegistryRequest(OperationGetLatestSchema, latency, err)
+	}
+	if c.hooks.OnSchemaFetched != nil {
+		c.callHook("OnSchemaFetched", c.hooks.OnSchemaFetched, HookEvent{Topic: topic, Subject: subjectName, SchemaID: latest.ID, Latency: latency, Err: err})
+	}
+	if err != nil {
+		if debug {
+			c.logger.Debug("schema fetch failed", "subject", subjectName, "latency", latency, "error", err)
+		}
+		err = fmt.Errorf("failed to fetch the latest schema of subject %v: %w", subjectName, err)
+		return
+	}
+	if debug {
+		c.logger.Debug("schema fetched", "subject", subjectName, "schema_id", latest.ID, "version", latest.Version, "latency", latency)
+	}
+	if err = c.checkSchemaSize(latest.Schema, fmt.Sprintf("schema %d of subject %v", latest.ID, subjectName)); err != nil {
+		return
+	}
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[1:], uint32(latest.ID))
+
+	if latest.Type() != schemaregistry.SchemaTypeAvro {
+		encoder, encoderErr := c.newSchemaEncoder(subjectName, latest)
+		if encoderErr != nil {
+			return schema, false, encoderErr
+		}
+		schema = encoderSchema{latest.ID, header, nil, &atomic.Int64{}, encoder}
+		c.encoderSchemas.put(subjectName, schema)
+		return
+	}
+
+	var codec *goavro.Codec
+	c.profiled(ctx, OperationBuildCodec, topic, subjectName, latest.ID, func(context.Context) {
+		codec, err = c.newGoavroCodec(latest.Schema)
+	})
+	if err != nil {
+		err = newCodecBuildError(latest, subjectName, err)
+		return
+	}
+
+	schema = encoderSchema{latest.ID, header, codec, &atomic.Int64{}, nil}
+
+	c.encoderSchemas.put(subjectName, schema)
+	c.codecByID.put(latest.ID, codec)
+	if c.metrics != nil {
+		c.metrics.SchemaCacheSize(c.codecByID.len())
+	}
+	return
+}
+
+// InvalidateSubject drops the cached latest schema of the subject, so that the next Encode to it
+// fetches the latest schema again, e.g. after a new version was registered. The codecs of the
+// schema ids stay cached as a schema id never changes. An Encode which is fetching the schema
+// while the subject is invalidated may still cache the schema it fetched.
+func (c *Codec) InvalidateSubject(subjectName SubjectName) {
+	if c.router != nil {
+		c.eachRegistry(func(codec *Codec) { codec.InvalidateSubject(subjectName) })
+		return
+	}
+	subjectName = ContextSubject(c.schemaContext, subjectName)
+	if c.encoderSchemas.delete(subjectName) && c.debugEnabled() {
+		c.logger.Debug("subject invalidated", "subject", subjectName)
+	}
+}
+
+// newGoavroCodec builds the goavro codec of the schema, see WithGoavroCodecBuilder.
+func (c *Codec) newGoavroCodec(schema AvroSchema) (*goavro.Codec, error) {
+	if c.codecBuilder != nil {
+		return c.codecBuilder(schema)
+	}
+	return goavro.NewCodec(schema)
+}
+
+func (c *Codec) cacheHit() {
+	atomic.AddUint64(&c.hits, 1)
+	if c.metrics != nil {
+		c.metrics.SchemaCacheLookup(true)
+	}
+}
+
+func (c *Codec) cacheMiss() {
+	atomic.AddUint64(&c.misses, 1)
+	if c.metrics != nil {
+		c.metrics.SchemaCacheLookup(false)
+	}
+}
+
+// debugEnabled returns true if there is a logger and it logs debug events.
+func (c *Codec) debugEnabled() bool {
+	return c.logger != nil && c.logger.Enabled(context.Background(), slog.LevelDebug)
+}
@@ -1,8 +1,25 @@
 package kafkaavro
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
 )
 
 func getSchemaID(data []byte) int {
@@ -51,4 +68,623 @@ func TestBytesForSchemaID(t *testing.T) {
 			t.Errorf("readUint32(%v) returned %d, want %d", test.input, got, test.want)
 		}
 	}
-}
\ No newline at end of file
+}
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+
+// fakeRegistry serves the schemas by id, the latest schema of every subject is the one with the highest id.
+type fakeRegistry struct {
+	schemas  map[int]string
+	subjects map[string][]int
+	calls    int
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		schemas:  map[int]string{7: testSchema},
+		subjects: map[string][]int{"test-value": {7}},
+	}
+}
+
+func (f *fakeRegistry) GetSchemaByID(id int) (avroSchema string, err error) {
+	f.calls++
+	avroSchema, found := f.schemas[id]
+	if !found {
+		err = fmt.Errorf("schema %d not found", id)
+	}
+	return
+}
+
+func (f *fakeRegistry) GetLatestSchema(subject string) (schema schemaregistry.Schema, err error) {
+	f.calls++
+	ids, found := f.subjects[subject]
+	if !found {
+		return schema, fmt.Errorf("subject %v not found", subject)
+	}
+	id := ids[len(ids)-1]
+	return schemaregistry.Schema{Subject: subject, Version: len(ids), ID: id, Schema: f.schemas[id]}, nil
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+
+	registry := newFakeRegistry()
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	native := map[string]interface{}{"f1": "value"}
+
+	data, err := codec.Encode("test", false, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0, 0, 0, 7}; !bytes.Equal(data[:5], want) {
+		t.Errorf("Encode() wrote header %v, want %v", data[:5], want)
+	}
+
+	for i := 0; i < 3; i++ {
+		decoded, err := codec.Decode("test", false, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, native) {
+			t.Errorf("Decode() returned %v, want %v", decoded, native)
+		}
+	}
+
+	if registry.calls != 1 {
+		t.Errorf("the registry was called %d times, want once", registry.calls)
+	}
+	if stats := codec.CacheStats(); stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("CacheStats() returned %+v, want 3 hits and 1 miss", stats)
+	}
+
+	schemaID, avroSchema, err := codec.WriterSchema(data)
+	if err != nil || schemaID != 7 || !strings.Contains(avroSchema, `"name":"myrecord"`) {
+		t.Errorf("WriterSchema() returned %v, %v, %v", schemaID, avroSchema, err)
+	}
+}
+
+func TestCodecDecodeErrors(t *testing.T) {
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{})
+
+	var tests = []struct {
+		data []byte
+		want string
+	}{
+		{nil, "too short"},
+		{[]byte{0, 0, 0}, "too short"},
+		{[]byte{1, 0, 0, 0, 7, 0}, "unknown magic byte"},
+		{[]byte{0, 0, 0, 0, 8, 0}, "schema 8 not found"},
+	}
+
+	for _, test := range tests {
+		if _, err := codec.Decode("test", false, test.data); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Decode(%v) returned %v, want an error containing %q", test.data, err, test.want)
+		}
+	}
+}
+
+func TestCodecLogger(t *testing.T) {
+
+	var tests = []struct {
+		level slog.Level
+		want  []string
+	}{
+		{slog.LevelDebug, []string{
+			`level=DEBUG msg="schema cache miss" subject=test-value`,
+			`level=DEBUG msg="schema fetched" subject=test-value schema_id=7 version=1`,
+			`level=DEBUG msg="schema cache miss" schema_id=8`,
+			`level=DEBUG msg="schema fetch failed" schema_id=8 error="schema 8 not found"`,
+		}},
+		{slog.LevelInfo, nil},
+	}
+
+	for _, test := range tests {
+
+		var out bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+			Level: test.level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey || a.Key == "latency" {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+		codec := NewCodec(newFakeRegistry(), TopicNameStrategy{}, WithLogger(logger))
+
+		data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = codec.Decode("test", false, data); err != nil {
+			t.Fatal(err)
+		}
+		codec.Decode("test", false, []byte{0, 0, 0, 0, 8, 0})
+
+		var lines []string
+		if out.Len() > 0 {
+			lines = strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		}
+		if !reflect.DeepEqual(lines, test.want) {
+			t.Errorf("at level %v the codec logged %q, want %q", test.level, lines, test.want)
+		}
+	}
+}
+
+func TestCodecSubjectIsCached(t *testing.T) {
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{})
+
+	if subject := codec.Subject("orders", true); subject != "orders-key" {
+		t.Errorf("Subject() returned %v, want orders-key", subject)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		codec.Subject("orders", true)
+	})
+	if allocs != 0 {
+		t.Errorf("Subject() of a cached topic allocated %v times, want 0", allocs)
+	}
+}
+
+// TestDecodeAllocations verifies that decoding a message with a cached schema allocates no more
+// than goavro requires to decode the avro data.
+func TestDecodeAllocations(t *testing.T) {
+
+	codec, data := newBenchmarkCodec(t)
+
+	goavroCodec, err := goavro.NewCodec(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goavroAllocs := testing.AllocsPerRun(100, func() {
+		goavroCodec.NativeFromBinary(data[headerSize:])
+	})
+	decodeAllocs := testing.AllocsPerRun(100, func() {
+		codec.Decode("test", false, data)
+	})
+
+	var scratch DecodeScratch
+	reuseAllocs := testing.AllocsPerRun(100, func() {
+		codec.DecodeReuse("test", false, data, &scratch)
+	})
+
+	if decodeAllocs > goavroAllocs || reuseAllocs > goavroAllocs {
+		t.Errorf("Decode() allocated %v times and DecodeReuse() %v times, goavro only requires %v", decodeAllocs, reuseAllocs, goavroAllocs)
+	}
+}
+
+// TestEncodeAllocations verifies that encoding allocates the output once, on top of what goavro
+// requires to encode the native value into a large enough buffer.
+func TestEncodeAllocations(t *testing.T) {
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{})
+	native := map[string]interface{}{"f1": strings.Repeat("value", 100)}
+
+	goavroCodec, err := goavro.NewCodec(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := Encoder{[]byte{0, 0, 0, 0, 7}, *goavroCodec, &atomic.Int64{}, nil, nil, nil, nil}
+
+	buffer := make([]byte, 0, 1024)
+	goavroAllocs := testing.AllocsPerRun(100, func() {
+		goavroCodec.BinaryFromNative(buffer[:0], native)
+	})
+	codecAllocs := testing.AllocsPerRun(100, func() {
+		codec.Encode("test", false, native)
+	})
+	encoderAllocs := testing.AllocsPerRun(100, func() {
+		encoder.Encode(native)
+	})
+
+	if codecAllocs > goavroAllocs+1 || encoderAllocs > goavroAllocs+1 {
+		t.Errorf("Codec.Encode() allocated %v times and Encoder.Encode() %v times, goavro requires %v", codecAllocs, encoderAllocs, goavroAllocs)
+	}
+
+	data, err := encoder.Encode(native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := codec.Encode("test", false, native)
+	if err != nil || !bytes.Equal(data, encoded) {
+		t.Errorf("Encoder.Encode() and Codec.Encode() returned different data")
+	}
+}
+
+func TestCodecDecodeReuse(t *testing.T) {
+
+	registry := newFakeRegistry()
+	registry.schemas[8] = `"string"`
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	var tests = []struct {
+		data []byte
+		want interface{}
+	}{
+		{[]byte{0, 0, 0, 0, 7, 2, 'a'}, map[string]interface{}{"f1": "a"}},
+		{[]byte{0, 0, 0, 0, 7, 2, 'b'}, map[string]interface{}{"f1": "b"}},
+		{[]byte{0, 0, 0, 0, 8, 2, 'c'}, "c"},
+		{[]byte{0, 0, 0, 0, 7, 2, 'd'}, map[string]interface{}{"f1": "d"}},
+	}
+
+	var scratch DecodeScratch
+	for _, test := range tests {
+		native, err := codec.DecodeReuse("test", false, test.data, &scratch)
+		if err != nil {
+			t.Errorf("DecodeReuse(%v) failed: %v", test.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(native, test.want) {
+			t.Errorf("DecodeReuse(%v) returned %v, want %v", test.data, native, test.want)
+		}
+	}
+
+	if registry.calls != 2 {
+		t.Errorf("the registry was called %d times, want twice", registry.calls)
+	}
+
+	if _, err := codec.DecodeReuse("test", false, []byte{0, 0, 0, 0, 9, 0}, &scratch); err == nil || scratch.codec != nil {
+		t.Errorf("DecodeReuse() of an unknown schema returned %v and kept codec %v", err, scratch.codec)
+	}
+
+	if native, err := codec.DecodeReuse("test", false, tests[0].data, nil); err != nil || !reflect.DeepEqual(native, tests[0].want) {
+		t.Errorf("DecodeReuse() without scratch returned %v, %v", native, err)
+	}
+}
+
+func newBenchmarkCodec(tb testing.TB) (codec *Codec, data []byte) {
+
+	codec = NewCodec(newFakeRegistry(), TopicNameStrategy{})
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return
+}
+
+func BenchmarkDecode(b *testing.B) {
+
+	codec, data := newBenchmarkCodec(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Decode("test", false, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newLargeBenchmarkCodec returns a codec and the data of a record of about 1KB.
+func newLargeBenchmarkCodec(tb testing.TB) (codec *Codec, data []byte) {
+
+	codec = NewCodec(newFakeRegistry(), TopicNameStrategy{})
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": strings.Repeat("x", 1024)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return
+}
+
+func BenchmarkDecode1KB(b *testing.B) {
+
+	codec, data := newLargeBenchmarkCodec(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Decode("test", false, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeReuse1KB(b *testing.B) {
+
+	codec, data := newLargeBenchmarkCodec(b)
+	var scratch DecodeScratch
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.DecodeReuse("test", false, data, &scratch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeParallel(b *testing.B) {
+
+	codec, data := newBenchmarkCodec(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := codec.Decode("test", false, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEncode(b *testing.B) {
+
+	codec, _ := newBenchmarkCodec(b)
+	native := map[string]interface{}{"f1": "value"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Encode("test", false, native); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestCodecReferenceEncodings decodes and encodes avro data of which the encoding is given in
+// the avro specification, as the java implementation writes it.
+func TestCodecReferenceEncodings(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		schema string
+		body   []byte
+		native interface{}
+	}{
+		{
+			"record",
+			`{"type":"record","name":"test","fields":[{"name":"a","type":"long"},{"name":"b","type":"string"}]}`,
+			[]byte{0x36, 0x06, 0x66, 0x6f, 0x6f},
+			map[string]interface{}{"a": int64(27), "b": "foo"},
+		},
+		{
+			"union",
+			`["null","string"]`,
+			[]byte{0x02, 0x02, 0x61},
+			map[string]interface{}{"string": "a"},
+		},
+		{
+			"null union",
+			`["null","string"]`,
+			[]byte{0x00},
+			nil,
+		},
+		{
+			"array",
+			`{"type":"array","items":"long"}`,
+			[]byte{0x04, 0x06, 0x36, 0x00},
+			[]interface{}{int64(3), int64(27)},
+		},
+		{
+			"map",
+			`{"type":"map","values":"int"}`,
+			[]byte{0x02, 0x06, 0x66, 0x6f, 0x6f, 0x36, 0x00},
+			map[string]interface{}{"foo": int32(27)},
+		},
+		{
+			"enum",
+			`{"type":"enum","name":"suit","symbols":["SPADES","HEARTS"]}`,
+			[]byte{0x02},
+			"HEARTS",
+		},
+		{
+			"bytes",
+			`"bytes"`,
+			[]byte{0x04, 0x01, 0xff},
+			[]byte{0x01, 0xff},
+		},
+		{
+			"fixed",
+			`{"type":"fixed","name":"md5","size":2}`,
+			[]byte{0x01, 0xff},
+			[]byte{0x01, 0xff},
+		},
+		{
+			"timestamp-millis",
+			`{"type":"long","logicalType":"timestamp-millis"}`,
+			[]byte{0xd0, 0x0f},
+			time.UnixMilli(1000).UTC(),
+		},
+	}
+
+	registry := &fakeRegistry{schemas: map[int]string{}, subjects: map[string][]int{}}
+	for i, test := range tests {
+		registry.schemas[i+1] = test.schema
+		registry.subjects[fmt.Sprintf("topic%d-value", i+1)] = []int{i + 1}
+	}
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	for i, test := range tests {
+
+		topic := fmt.Sprintf("topic%d", i+1)
+		data := append([]byte{0, 0, 0, 0, byte(i + 1)}, test.body...)
+
+		native, err := codec.Decode(topic, false, data)
+		if err != nil {
+			t.Errorf("%v: Decode() failed: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(native, test.native) {
+			t.Errorf("%v: Decode() returned %#v, want %#v", test.name, native, test.native)
+		}
+
+		encoded, err := codec.Encode(topic, false, test.native)
+		if err != nil {
+			t.Errorf("%v: Encode() failed: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Errorf("%v: Encode() returned % x, want % x", test.name, encoded, data)
+		}
+	}
+}
+
+func TestCodecEncodeWithSchemaID(t *testing.T) {
+
+	registry := mockregistry.New()
+	first := registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string","default":""}]}`)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	// the value is encoded with the first schema rather than the latest schema of the subject
+	data, err := codec.EncodeWithSchemaID(first, map[string]interface{}{"id": int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, err := codec.WriterSchema(data); err != nil || id != first {
+		t.Errorf("EncodeWithSchemaID() encoded with schema %v, %v, want %v", id, err, first)
+	}
+
+	// the framing of the body of the first schema is the same data
+	if framed := codec.EncodeFramed(first, data[headerSize:]); !bytes.Equal(framed, data) {
+		t.Errorf("EncodeFramed() returned %v, want %v", framed, data)
+	}
+	if framed := codec.EncodeFramed(0x01020304, []byte{7}); !bytes.Equal(framed, []byte{0, 1, 2, 3, 4, 7}) {
+		t.Errorf("EncodeFramed() returned %v", framed)
+	}
+
+	if _, err = codec.EncodeWithSchemaID(99, map[string]interface{}{"id": int64(1)}); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("EncodeWithSchemaID() of an unknown schema returned %v", err)
+	}
+	if _, err = codec.EncodeWithSchemaID(first, map[string]interface{}{"id": "1"}); err == nil {
+		t.Error("EncodeWithSchemaID() of an invalid value succeeded")
+	}
+}
+
+func TestWithGoavroCodecBuilder(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", ocfSchema)
+
+	var built []*goavro.Codec
+	builder := func(schema string) (*goavro.Codec, error) {
+		codec, err := goavro.NewCodecForStandardJSON(schema)
+		built = append(built, codec)
+		return codec, err
+	}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithGoavroCodecBuilder(builder))
+
+	data, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native, err := codec.Decode("orders", false, data); err != nil || !reflect.DeepEqual(native, order(1)) {
+		t.Errorf("Decode() returned %v, %v", native, err)
+	}
+	// the cache holds the codec of the builder
+	if len(built) != 1 {
+		t.Fatalf("the builder built %d codecs, want 1", len(built))
+	}
+	if cached, _ := codec.codecByID.get(1); cached != built[0] {
+		t.Error("the cached codec is not the codec of the builder")
+	}
+	// the standard JSON codec does not wrap the union values
+	if err = codec.ValidateJSON("orders-value", -1, []byte(`{"id":1,"note":"first"}`)); err != nil {
+		t.Errorf("ValidateJSON() of standard JSON returned %v", err)
+	}
+
+	failing := NewCodec(registry, TopicNameStrategy{}, WithGoavroCodecBuilder(func(string) (*goavro.Codec, error) {
+		return nil, errors.New("not built")
+	}))
+	if _, err = failing.Decode("orders", false, data); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("Decode() with a failing builder returned %v", err)
+	}
+
+	client, close := newErrorRegistry(t)
+	defer close()
+	built = nil
+	if _, err = NewEncoder(*client, true, "ok-value", testSchema, WithGoavroCodecBuilder(builder)); err != nil || len(built) != 1 {
+		t.Errorf("NewEncoder() returned %v and built %d codecs with the builder", err, len(built))
+	}
+}
+
+func TestNewEncoderCompatibilityOnRegister(t *testing.T) {
+
+	var requests []string
+	var failConfig atomic.Bool
+	// subjectLevel is the compatibility level of the subject itself, none if empty
+	var subjectLevel atomic.Value
+	subjectLevel.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body))))
+		switch {
+		case r.URL.Path == "/config/orders-value" && r.Method == http.MethodGet && subjectLevel.Load() == "":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40408,"message":"Subject 'orders-value' does not have subject-level compatibility configured"}`))
+		case r.URL.Path == "/config/orders-value" && r.Method == http.MethodGet:
+			fmt.Fprintf(w, `{"compatibilityLevel":%q}`, subjectLevel.Load())
+		case r.URL.Path == "/config/orders-value" && failConfig.Load():
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error_code":50001,"message":"Store error"}`))
+		case r.URL.Path == "/config/orders-value":
+			var request struct{ Compatibility string }
+			json.Unmarshal(body, &request)
+			subjectLevel.Store(request.Compatibility)
+			w.Write(body)
+		default:
+			w.Write([]byte(`{"id":1}`))
+		}
+	}))
+	defer server.Close()
+	client, err := schemaregistry.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := "POST /subjects/orders-value/versions " + fmt.Sprintf(`{"schema":%q}`, testSchema)
+	getLevel := "GET /config/orders-value"
+	setLevel := `PUT /config/orders-value {"compatibility":"FULL_TRANSITIVE"}`
+
+	var tests = []struct {
+		name         string
+		autoRegister bool
+		level        schemaregistry.CompatibilityLevel
+		subjectLevel string
+		failConfig   bool
+		wantErr      error
+		want         []string
+	}{
+		{"set after registering", true, schemaregistry.CompatibilityFullTransitive, "", false, nil, []string{register, getLevel, setLevel}},
+		{"not set without a level", true, "", "", false, nil, []string{register}},
+		{"not set without registering", false, schemaregistry.CompatibilityFullTransitive, "", false, nil, []string{"POST /subjects/orders-value " + fmt.Sprintf(`{"schema":%q}`, testSchema)}},
+		{"invalid level", true, "STRICT", "", false, ErrInvalidConfig, nil},
+		{"failing to set", true, schemaregistry.CompatibilityFullTransitive, "", true, schemaregistry.ErrRegistryUnavailable, []string{register, getLevel, setLevel}},
+		// a retry registers the same schema and sets the level the failure did not set
+		{"retry", true, schemaregistry.CompatibilityFullTransitive, "", false, nil, []string{register, getLevel, setLevel}},
+		{"not set over the level of the subject", true, schemaregistry.CompatibilityFullTransitive, "NONE", false, nil, []string{register, getLevel}},
+	}
+
+	for _, test := range tests {
+		requests = nil
+		failConfig.Store(test.failConfig)
+		subjectLevel.Store(test.subjectLevel)
+		_, err := NewEncoder(*client, test.autoRegister, "orders-value", testSchema, WithCompatibilityOnRegister(test.level))
+		if (test.wantErr == nil) != (err == nil) || test.wantErr != nil && !errors.Is(err, test.wantErr) {
+			t.Errorf("%v: NewEncoder() returned %v, want %v", test.name, err, test.wantErr)
+		}
+		if !reflect.DeepEqual(requests, test.want) {
+			t.Errorf("%v: NewEncoder() requested %q, want %q", test.name, requests, test.want)
+		}
+	}
+
+	// an operator changes the level the first Encoder provisioned, the next Encoder keeps it
+	subjectLevel.Store("")
+	if _, err = NewEncoder(*client, true, "orders-value", testSchema, WithCompatibilityOnRegister(schemaregistry.CompatibilityFullTransitive)); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.SetCompatibility(context.Background(), "orders-value", schemaregistry.CompatibilityBackward); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewEncoder(*client, true, "orders-value", testSchema, WithCompatibilityOnRegister(schemaregistry.CompatibilityFullTransitive)); err != nil {
+		t.Fatal(err)
+	}
+	if level := subjectLevel.Load(); level != "BACKWARD" {
+		t.Errorf("the level of the subject is %v after a second NewEncoder, want the BACKWARD the operator set", level)
+	}
+}
@@ -0,0 +1,63 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// DefaultCodecBuildFailureTTL is how long a Codec remembers that it failed to build the codec of a
+// schema id, unless WithCodecBuildFailureTTL is used.
+const DefaultCodecBuildFailureTTL = 30 * time.Second
+
+// WithCodecBuildFailureTTL makes the Codec return the *CodecBuildError of a schema id which did not
+// build to the messages of the schema id for the ttl, rather than fetching and parsing the schema
+// again for every message. 0 fetches the schema again for every message. The default is
+// DefaultCodecBuildFailureTTL.
+func WithCodecBuildFailureTTL(ttl time.Duration) Option {
+	return func(c *Codec) {
+		c.codecBuildFailureTTL = ttl
+	}
+}
+
+// codecBuildFailure is the cached error of a schema id which did not build.
+type codecBuildFailure struct {
+	err   error
+	until time.Time
+}
+
+// codecBuildFailed returns the cached error of the schema id if it failed to build within the ttl.
+func (c *Codec) codecBuildFailed(schemaID SchemaID) error {
+	failure, found := c.codecBuildFailures.get(schemaID)
+	if !found {
+		return nil
+	}
+	if c.clock.Now().Before(failure.until) {
+		return failure.err
+	}
+	c.codecBuildFailures.delete(schemaID)
+	return nil
+}
+
+// rememberCodecBuildFailure caches the error of the schema id for the ttl.
+func (c *Codec) rememberCodecBuildFailure(schemaID SchemaID, err error) {
+	if c.codecBuildFailureTTL > 0 {
+		c.codecBuildFailures.put(schemaID, codecBuildFailure{err, c.clock.Now().Add(c.codecBuildFailureTTL)})
+	}
+}
+
+// schemaTypeOf guesses the schema type of a schema which is not avro, from the keywords of JSON
+// schemas and protobuf, "" if it does not look like either.
+func schemaTypeOf(schema string) string {
+	var document map[string]interface{}
+	if json.Unmarshal([]byte(schema), &document) == nil {
+		if _, found := document["$schema"]; found || document["type"] == "object" {
+			return "JSON"
+		}
+		return ""
+	}
+	if trimmed := strings.TrimSpace(schema); strings.HasPrefix(trimmed, "syntax") || strings.HasPrefix(trimmed, "message ") {
+		return "PROTOBUF"
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package kafkaavro
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const jsonSchema = `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"id":{"type":"integer"}}}`
+
+func TestCodecBuildError(t *testing.T) {
+
+	// a JSON schema registered without its schemaType, as on a subject of mixed schema types
+	registry := mockregistry.New()
+	schemaID := registry.Register("orders-value", jsonSchema)
+	data := []byte{0, 0, 0, 0, byte(schemaID), 2}
+
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+	codec := NewCodec(registry, TopicNameStrategy{}, WithClock(clock))
+
+	_, err := codec.Decode("orders", false, data)
+	var buildErr *CodecBuildError
+	if !errors.Is(err, ErrCodecBuild) || !errors.As(err, &buildErr) || ErrorType(err) != "ErrCodecBuild" {
+		t.Fatalf("Decode() returned %v, want a *CodecBuildError", err)
+	}
+	if buildErr.SchemaID != schemaID || buildErr.SchemaType != "" || buildErr.Err == nil {
+		t.Errorf("Decode() returned %+v", buildErr)
+	}
+	if want := "of schema 1 (the registry returned no schemaType, the schema looks like a JSON schema): "; !strings.Contains(err.Error(), want) {
+		t.Errorf("Decode() returned %q, want it to contain %q", err, want)
+	}
+
+	// the failure is cached for the ttl
+	if _, err = codec.Decode("orders", false, data); !errors.As(err, &buildErr) || registry.CallCount(mockregistry.GetSchemaByID) != 1 {
+		t.Errorf("Decode() again returned %v and fetched the schema %d times", err, registry.CallCount(mockregistry.GetSchemaByID))
+	}
+	clock.Advance(DefaultCodecBuildFailureTTL)
+	if _, err = codec.Decode("orders", false, data); !errors.As(err, &buildErr) || registry.CallCount(mockregistry.GetSchemaByID) != 2 {
+		t.Errorf("Decode() after the ttl returned %v and fetched the schema %d times", err, registry.CallCount(mockregistry.GetSchemaByID))
+	}
+
+	registry.Reset()
+	schemaID = registry.Register("orders-value", jsonSchema)
+	uncached := NewCodec(registry, TopicNameStrategy{}, WithCodecBuildFailureTTL(0))
+	for i := 0; i < 2; i++ {
+		uncached.Decode("orders", false, data)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 2 {
+		t.Errorf("Decode() without a ttl fetched the schema %d times, want twice", calls)
+	}
+
+	// the encodes report the subject and the schema type of the registry
+	registry.RegisterSchema("payments-value", schemaregistry.Schema{Schema: `{"type":"unknown"}`, SchemaType: schemaregistry.SchemaTypeAvro})
+	if _, err = codec.Encode("payments", false, nil); !errors.As(err, &buildErr) || buildErr.Subject != "payments-value" || buildErr.SchemaType != schemaregistry.SchemaTypeAvro {
+		t.Errorf("Encode() returned %v", err)
+	} else if want := "of subject payments-value (schemaType AVRO): "; !strings.Contains(err.Error(), want) {
+		t.Errorf("Encode() returned %q, want it to contain %q", err, want)
+	}
+}
+
+func TestSchemaTypeOf(t *testing.T) {
+
+	var tests = []struct {
+		schema string
+		want   string
+	}{
+		{jsonSchema, "JSON"},
+		{`{"type":"object","properties":{}}`, "JSON"},
+		{"syntax = \"proto3\";\nmessage Order { int64 id = 1; }", "PROTOBUF"},
+		{"message Order { int64 id = 1; }", "PROTOBUF"},
+		{`{"type":"record","name":"Order","fields":[{"name":"properties","type":"string"}]}`, ""},
+		{`"string"`, ""},
+	}
+	for _, test := range tests {
+		if got := schemaTypeOf(test.schema); got != test.want {
+			t.Errorf("schemaTypeOf(%q) returned %q, want %q", test.schema, got, test.want)
+		}
+	}
+}
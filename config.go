@@ -0,0 +1,185 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// The properties of the Confluent serializers which NewCodecFromConfig supports.
+const (
+	ConfigRegistryURL            = "schema.registry.url"
+	ConfigBasicAuthSource        = "basic.auth.credentials.source"
+	ConfigBasicAuthUserInfo      = "basic.auth.user.info"
+	ConfigKeySubjectNameStrategy = "key.subject.name.strategy"
+	ConfigSubjectNameStrategy    = "value.subject.name.strategy"
+	ConfigAutoRegisterSchemas    = "auto.register.schemas"
+	ConfigUseLatestVersion       = "use.latest.version"
+	ConfigNormalizeSchemas       = "normalize.schemas"
+)
+
+// topicNameStrategies are the names of the TopicNameStrategy in the properties.
+var topicNameStrategies = map[string]bool{
+	"io.confluent.kafka.serializers.subject.TopicNameStrategy": true,
+	"TopicNameStrategy": true,
+}
+
+// WithStrictConfig makes NewCodecFromConfig fail with ErrInvalidConfig for the properties which it
+// does not support, rather than logging a warning for them.
+func WithStrictConfig(strict bool) Option {
+	return func(c *Codec) {
+		c.strictConfig = strict
+	}
+}
+
+// NewCodecFromConfig creates a Codec from the properties of the Confluent serializers, e.g. to
+// share the configuration of the Java clients of the same topics, followed by the options. The
+// supported properties are:
+//
+//   - schema.registry.url: the url of the registry, one url only
+//   - basic.auth.credentials.source: USER_INFO (with basic.auth.user.info user:password) or URL
+//     (with the user info in the url)
+//   - key.subject.name.strategy and value.subject.name.strategy: only the TopicNameStrategy
+//   - auto.register.schemas: only false, the Codec does not register schemas
+//   - use.latest.version: true or false, the Codec always encodes with the latest version
+//   - normalize.schemas: true or false, see schemaregistry.UsingNormalize
+//
+// The other properties are logged as a warning (with the logger of WithLogger, or the default
+// slog logger), or fail with ErrInvalidConfig with WithStrictConfig. Invalid values of the
+// supported properties fail with ErrInvalidConfig. WithRegistryClientOptions and WithStartupPing
+// apply, like for NewCodecFromURL.
+func NewCodecFromConfig(props map[string]string, options ...Option) (codec *Codec, err error) {
+
+	var unknown []string
+	for key := range props {
+		switch key {
+		case ConfigRegistryURL, ConfigBasicAuthSource, ConfigBasicAuthUserInfo, ConfigKeySubjectNameStrategy,
+			ConfigSubjectNameStrategy, ConfigAutoRegisterSchemas, ConfigUseLatestVersion, ConfigNormalizeSchemas:
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	// the unknown properties fail before the client is created, which may ping the registry
+	var config Codec
+	for _, option := range options {
+		option(&config)
+	}
+	if len(unknown) > 0 && config.strictConfig {
+		return nil, fmt.Errorf("%w: the properties %v are not supported", ErrInvalidConfig, strings.Join(unknown, ", "))
+	}
+
+	url := strings.TrimSpace(props[ConfigRegistryURL])
+	switch {
+	case url == "":
+		return nil, fmt.Errorf("%w: %v is required", ErrInvalidConfig, ConfigRegistryURL)
+	case strings.Contains(url, ","):
+		return nil, fmt.Errorf("%w: %v %q holds several urls, only one is supported", ErrInvalidConfig, ConfigRegistryURL, url)
+	}
+
+	var clientOptions []schemaregistry.Option
+	switch source := props[ConfigBasicAuthSource]; source {
+	case "", "USER_INFO":
+		if userInfo, found := props[ConfigBasicAuthUserInfo]; found {
+			username, password, ok := strings.Cut(userInfo, ":")
+			if !ok {
+				return nil, fmt.Errorf("%w: %v is not of the form user:password", ErrInvalidConfig, ConfigBasicAuthUserInfo)
+			}
+			clientOptions = append(clientOptions, schemaregistry.UsingBasicAuth(username, password))
+		}
+	case "URL":
+		// the http client sends the user info of the url
+	default:
+		return nil, fmt.Errorf("%w: %v %q is not supported, use USER_INFO or URL", ErrInvalidConfig, ConfigBasicAuthSource, source)
+	}
+
+	for _, key := range []string{ConfigKeySubjectNameStrategy, ConfigSubjectNameStrategy} {
+		if strategy, found := props[key]; found && !topicNameStrategies[strategy] {
+			return nil, fmt.Errorf("%w: %v %q is not supported, only the TopicNameStrategy is", ErrInvalidConfig, key, strategy)
+		}
+	}
+	for _, key := range []string{ConfigAutoRegisterSchemas, ConfigUseLatestVersion, ConfigNormalizeSchemas} {
+		value, found := props[key]
+		if !found {
+			continue
+		}
+		enabled, parseErr := strconv.ParseBool(value)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: %v %q is not a boolean", ErrInvalidConfig, key, value)
+		}
+		if key == ConfigAutoRegisterSchemas && enabled {
+			return nil, fmt.Errorf("%w: %v is not supported, the Codec encodes with the registered schemas", ErrInvalidConfig, key)
+		}
+		if key == ConfigNormalizeSchemas {
+			clientOptions = append(clientOptions, schemaregistry.UsingNormalize(enabled))
+		}
+	}
+
+	if codec, err = newURLCodec(url, clientOptions, options); err != nil {
+		return
+	}
+
+	if len(unknown) > 0 {
+		logger := codec.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Warn("the properties are not supported and ignored", "properties", unknown)
+	}
+	return
+}
+
+// WithRegistryClientOptions passes the options to the schema registry client which
+// NewCodecFromURL (or NewCodecFromConfig) creates, e.g. schemaregistry.UsingBasicAuth, or
+// schemaregistry.UsingClient for TLS.
+func WithRegistryClientOptions(options ...schemaregistry.Option) Option {
+	return func(c *Codec) {
+		c.clientOptions = append(c.clientOptions, options...)
+	}
+}
+
+// WithStartupPing makes NewCodecFromURL (and NewCodecFromConfig) request the subjects of the
+// registry when ping is true, so that an unreachable registry or invalid credentials fail at
+// startup rather than on the first message. The capabilities of the registry are detected as well,
+// see schemaregistry.Client.DetectCapabilities, e.g. for Karapace or Redpanda.
+func WithStartupPing(ping bool) Option {
+	return func(c *Codec) {
+		c.startupPing = ping
+	}
+}
+
+// NewCodecFromURL creates a Codec with the TopicNameStrategy which fetches the schemas from the
+// schema registry at the url, see WithRegistryClientOptions and WithStartupPing. Use NewCodec to
+// configure the registry client entirely.
+func NewCodecFromURL(url string, options ...Option) (codec *Codec, err error) {
+	return newURLCodec(url, nil, options)
+}
+
+// newURLCodec creates the schema registry client of the url with the client options, followed by
+// the ones of WithRegistryClientOptions, and the Codec of the client.
+func newURLCodec(url string, clientOptions []schemaregistry.Option, options []Option) (codec *Codec, err error) {
+
+	var config Codec
+	for _, option := range options {
+		option(&config)
+	}
+
+	client, err := schemaregistry.NewClient(url, append(clientOptions, config.clientOptions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if config.startupPing {
+		if _, err = client.Subjects(); err != nil {
+			return nil, fmt.Errorf("schema registry %v did not answer the startup ping: %w", client, err)
+		}
+		if _, err = client.DetectCapabilities(); err != nil {
+			return nil, fmt.Errorf("failed to detect the capabilities of schema registry %v: %w", client, err)
+		}
+	}
+	return NewCodec(client, TopicNameStrategy{}, options...), nil
+}
@@ -0,0 +1,128 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+func TestNewCodecFromConfig(t *testing.T) {
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if username, password, _ := r.BasicAuth(); username != "key" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 401, "message": "Unauthorized"})
+			return
+		}
+		json.NewEncoder(w).Encode(schemaregistry.Schema{Subject: "orders-value", Version: 1, ID: 1, Schema: `"long"`})
+	}))
+	defer server.Close()
+	withUserInfo := strings.Replace(server.URL, "http://", "http://key:secret@", 1)
+
+	valid := []map[string]string{
+		{"schema.registry.url": server.URL, "basic.auth.credentials.source": "USER_INFO", "basic.auth.user.info": "key:secret"},
+		{"schema.registry.url": server.URL, "basic.auth.user.info": "key:secret",
+			"value.subject.name.strategy": "io.confluent.kafka.serializers.subject.TopicNameStrategy",
+			"auto.register.schemas":       "false", "use.latest.version": "true", "normalize.schemas": "true"},
+		{"schema.registry.url": withUserInfo, "basic.auth.credentials.source": "URL"},
+	}
+	for _, props := range valid {
+		codec, err := NewCodecFromConfig(props)
+		if err != nil {
+			t.Errorf("NewCodecFromConfig(%v) returned %v", props, err)
+			continue
+		}
+		if _, err = codec.Encode("orders", false, int64(1)); err != nil {
+			t.Errorf("Encode() with the config %v returned %v", props, err)
+		}
+	}
+
+	invalid := map[string]map[string]string{
+		"schema.registry.url is required": {"basic.auth.user.info": "key:secret"},
+		"only one is supported":           {"schema.registry.url": server.URL + "," + server.URL},
+		"not of the form user:password":   {"schema.registry.url": server.URL, "basic.auth.user.info": "key"},
+		"basic.auth.credentials.source":   {"schema.registry.url": server.URL, "basic.auth.credentials.source": "SASL_INHERIT"},
+		"RecordNameStrategy":              {"schema.registry.url": server.URL, "key.subject.name.strategy": "io.confluent.kafka.serializers.subject.RecordNameStrategy"},
+		"auto.register.schemas is not":    {"schema.registry.url": server.URL, "auto.register.schemas": "true"},
+		`use.latest.version "yes"`:        {"schema.registry.url": server.URL, "use.latest.version": "yes"},
+		`normalize.schemas "on"`:          {"schema.registry.url": server.URL, "normalize.schemas": "on"},
+		"expected http(s)://host:port":    {"schema.registry.url": "registry:8081"},
+	}
+	for want, props := range invalid {
+		if _, err := NewCodecFromConfig(props); !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), want) {
+			t.Errorf("NewCodecFromConfig(%v) returned %v, want ErrInvalidConfig with %v", props, err, want)
+		}
+	}
+
+	// the unknown properties are logged, or fail in strict mode
+	props := map[string]string{"schema.registry.url": server.URL, "specific.avro.reader": "true", "max.schemas.per.subject": "1000"}
+	var logs bytes.Buffer
+	if _, err := NewCodecFromConfig(props, WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))); err != nil {
+		t.Errorf("NewCodecFromConfig() with unknown properties returned %v", err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "[max.schemas.per.subject specific.avro.reader]") {
+		t.Errorf("NewCodecFromConfig() logged %q", logs.String())
+	}
+	before := requests.Load()
+	_, err := NewCodecFromConfig(props, WithStrictConfig(true), WithStartupPing(true))
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "max.schemas.per.subject, specific.avro.reader") {
+		t.Errorf("NewCodecFromConfig() in strict mode returned %v", err)
+	}
+	if requests.Load() != before {
+		t.Error("NewCodecFromConfig() in strict mode pinged the registry")
+	}
+}
+
+func TestNewCodecFromURL(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "key" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 401, "message": "Unauthorized"})
+			return
+		}
+		switch r.URL.Path {
+		case "/subjects":
+			json.NewEncoder(w).Encode([]string{"orders-value"})
+			return
+		case "/schemas/types":
+			json.NewEncoder(w).Encode([]string{schemaregistry.SchemaTypeAvro})
+			return
+		}
+		json.NewEncoder(w).Encode(schemaregistry.Schema{Subject: "orders-value", Version: 1, ID: 1, Schema: `"long"`})
+	}))
+	defer server.Close()
+	auth := WithRegistryClientOptions(schemaregistry.UsingBasicAuth("key", "secret"))
+
+	codec, err := NewCodecFromURL(server.URL, auth, WithStartupPing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Encode("orders", false, int64(1)); err != nil {
+		t.Errorf("Encode() returned %v", err)
+	}
+
+	// the credentials are only checked by the ping
+	if _, err = NewCodecFromURL(server.URL); err != nil {
+		t.Errorf("NewCodecFromURL() without credentials returned %v", err)
+	}
+	if _, err = NewCodecFromURL(server.URL, WithStartupPing(true)); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("NewCodecFromURL() with a ping without credentials returned %v", err)
+	}
+	if _, err = NewCodecFromURL("http://127.0.0.1:1", WithStartupPing(true)); !errors.Is(err, ErrRegistryUnavailable) {
+		t.Errorf("NewCodecFromURL() with a ping of an unreachable registry returned %v", err)
+	}
+	if _, err = NewCodecFromURL("registry:8081"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewCodecFromURL() of an invalid url returned %v", err)
+	}
+}
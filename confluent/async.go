@@ -0,0 +1,319 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+// The errors of the messages of an AsyncProducer which were not produced.
+var (
+	// ErrQueueFull is the error of a message which did not fit in the queue, or of the oldest
+	// message which was dropped for a new one, see QueueFullPolicy.
+	ErrQueueFull = errors.New("queue full")
+	// ErrProducerClosed is the error of a message enqueued after Close.
+	ErrProducerClosed = errors.New("producer closed")
+)
+
+// QueueFullPolicy is what EnqueueEncode does when the queue of an AsyncProducer is full.
+type QueueFullPolicy int
+
+const (
+	// BlockWhenFull waits until the queue has room, or the AsyncProducer is closed.
+	BlockWhenFull QueueFullPolicy = iota
+	// DropOldest fails the oldest message of the queue with ErrQueueFull to make room.
+	DropOldest
+	// FailWhenFull fails the new message with ErrQueueFull.
+	FailWhenFull
+)
+
+// DefaultQueueSize is the size of the queue of an AsyncProducer, unless WithQueueSize is used.
+const DefaultQueueSize = 1000
+
+// AsyncOption is an option of NewAsyncProducer.
+type AsyncOption func(*AsyncProducer)
+
+// WithQueueSize sets the number of messages which wait to be encoded and produced.
+func WithQueueSize(size int) AsyncOption {
+	return func(p *AsyncProducer) {
+		p.queueSize = size
+	}
+}
+
+// WithWorkers sets the number of goroutines which encode and produce the messages, 1 by default.
+// The messages of several workers are produced out of order.
+func WithWorkers(workers int) AsyncOption {
+	return func(p *AsyncProducer) {
+		p.workers = workers
+	}
+}
+
+// WithQueueFullPolicy sets what EnqueueEncode does when the queue is full, BlockWhenFull by
+// default.
+func WithQueueFullPolicy(policy QueueFullPolicy) AsyncOption {
+	return func(p *AsyncProducer) {
+		p.policy = policy
+	}
+}
+
+// WithQueueDepthHook calls hook with the number of queued messages when a message is enqueued or
+// taken from the queue, e.g. to export it as a gauge. It must not block.
+func WithQueueDepthHook(hook func(depth int)) AsyncOption {
+	return func(p *AsyncProducer) {
+		p.depthHook = hook
+	}
+}
+
+// WithMessageOptions sets the options of the messages, see NewMessage.
+func WithMessageOptions(options ...MessageOption) AsyncOption {
+	return func(p *AsyncProducer) {
+		p.messageOptions = append(p.messageOptions, options...)
+	}
+}
+
+// AsyncStats are the counts of the messages of an AsyncProducer.
+type AsyncStats struct {
+	Enqueued uint64
+	// Delivered counts the messages of the successful delivery reports, Failed the other messages
+	// which completed: which did not encode or produce, failed to deliver or were dropped.
+	Delivered uint64
+	Failed    uint64
+	// Dropped counts the messages which failed with ErrQueueFull.
+	Dropped uint64
+	// Depth is the number of queued messages.
+	Depth int
+}
+
+// Delivery is the future of a message of EnqueueEncode.
+type Delivery struct {
+	done    chan struct{}
+	message *kafka.Message
+	err     error
+}
+
+// Done is closed when the message is delivered or failed.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait waits until the message is delivered and returns it with its partition and offset, or
+// returns the error of the message: the encode error, the produce error or the error of its
+// delivery report, ErrQueueFull or ErrProducerClosed.
+func (d *Delivery) Wait(ctx context.Context) (m *kafka.Message, err error) {
+	select {
+	case <-d.done:
+		return d.message, d.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type asyncMessage struct {
+	topic    string
+	key      []byte
+	value    interface{}
+	delivery *Delivery
+}
+
+// AsyncProducer encodes and produces messages in the background from a bounded queue, to absorb
+// bursts without blocking the callers, see EnqueueEncode. The delivery reports of the producer
+// complete the Delivery of each message. Close drains the queue.
+type AsyncProducer struct {
+	producer       Producer
+	codec          *kafkaavro.Codec
+	queueSize      int
+	workers        int
+	policy         QueueFullPolicy
+	depthHook      func(depth int)
+	messageOptions []MessageOption
+
+	queue      chan *asyncMessage
+	deliveries chan kafka.Event
+	closing    chan struct{}
+	workersWG  sync.WaitGroup
+
+	// mu orders the enqueues before Close, enqueuing counts the callers of EnqueueEncode which
+	// may still send to the queue and pending the messages which are not completed
+	mu        sync.RWMutex
+	closed    bool
+	enqueuing sync.WaitGroup
+	pending   sync.WaitGroup
+	inFlight  atomic.Int64
+
+	enqueued, delivered, failed, dropped atomic.Uint64
+}
+
+// NewAsyncProducer creates an AsyncProducer which produces the messages with the producer, e.g. a
+// *kafka.Producer, and encodes the values with the latest schema of the value subject of their
+// topic with the codec, see NewMessage. Close it to stop its goroutines.
+func NewAsyncProducer(producer Producer, codec *kafkaavro.Codec, options ...AsyncOption) *AsyncProducer {
+
+	p := &AsyncProducer{producer: producer, codec: codec, queueSize: DefaultQueueSize, workers: 1}
+	for _, option := range options {
+		option(p)
+	}
+	p.queue = make(chan *asyncMessage, max(p.queueSize, 1))
+	p.deliveries = make(chan kafka.Event, max(p.queueSize, 1))
+	p.closing = make(chan struct{})
+
+	for i := 0; i < max(p.workers, 1); i++ {
+		p.workersWG.Add(1)
+		go p.work()
+	}
+	go p.report()
+	return p
+}
+
+// EnqueueEncode queues the value to encode for the topic and produce with the key, and returns the
+// Delivery of the message. A full queue blocks, drops the oldest message or fails with
+// ErrQueueFull, following the QueueFullPolicy. A closed AsyncProducer fails with ErrProducerClosed.
+func (p *AsyncProducer) EnqueueEncode(topic string, key []byte, value interface{}) (delivery *Delivery, err error) {
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return nil, ErrProducerClosed
+	}
+	p.enqueuing.Add(1)
+	p.mu.RUnlock()
+	defer p.enqueuing.Done()
+
+	m := &asyncMessage{topic: topic, key: key, value: value, delivery: &Delivery{done: make(chan struct{})}}
+	p.pending.Add(1)
+	p.inFlight.Add(1)
+
+	for {
+		select {
+		case p.queue <- m:
+			p.enqueued.Add(1)
+			p.queueDepth()
+			return m.delivery, nil
+		default:
+		}
+
+		switch p.policy {
+		case FailWhenFull:
+			p.dropped.Add(1)
+			p.complete(m, nil, ErrQueueFull)
+			return nil, ErrQueueFull
+		case DropOldest:
+			select {
+			case oldest := <-p.queue:
+				p.dropped.Add(1)
+				p.complete(oldest, nil, fmt.Errorf("%w: the message was dropped for a newer one", ErrQueueFull))
+			default:
+			}
+		default:
+			select {
+			case p.queue <- m:
+				p.enqueued.Add(1)
+				p.queueDepth()
+				return m.delivery, nil
+			case <-p.closing:
+				p.complete(m, nil, ErrProducerClosed)
+				return nil, ErrProducerClosed
+			}
+		}
+	}
+}
+
+// work encodes and produces the queued messages until the queue is closed and drained.
+func (p *AsyncProducer) work() {
+
+	defer p.workersWG.Done()
+	for m := range p.queue {
+		p.queueDepth()
+		message, err := NewMessage(p.codec, m.topic, m.key, m.value, p.messageOptions...)
+		if err != nil {
+			p.complete(m, nil, err)
+			continue
+		}
+		message.Opaque = m
+		if err = p.producer.Produce(message, p.deliveries); err != nil {
+			p.complete(m, message, err)
+		}
+	}
+}
+
+// report completes the messages of the delivery reports.
+func (p *AsyncProducer) report() {
+	for e := range p.deliveries {
+		message, ok := e.(*kafka.Message)
+		if !ok {
+			continue
+		}
+		if m, ok := message.Opaque.(*asyncMessage); ok {
+			message.Opaque = nil
+			p.complete(m, message, message.TopicPartition.Error)
+		}
+	}
+}
+
+func (p *AsyncProducer) complete(m *asyncMessage, message *kafka.Message, err error) {
+	if err != nil {
+		p.failed.Add(1)
+	} else {
+		p.delivered.Add(1)
+	}
+	m.delivery.message, m.delivery.err = message, err
+	close(m.delivery.done)
+	p.inFlight.Add(-1)
+	p.pending.Done()
+}
+
+func (p *AsyncProducer) queueDepth() {
+	if p.depthHook != nil {
+		p.depthHook(len(p.queue))
+	}
+}
+
+// Stats returns the counts of the messages.
+func (p *AsyncProducer) Stats() AsyncStats {
+	return AsyncStats{
+		Enqueued:  p.enqueued.Load(),
+		Delivered: p.delivered.Load(),
+		Failed:    p.failed.Load(),
+		Dropped:   p.dropped.Load(),
+		Depth:     len(p.queue),
+	}
+}
+
+// Close stops accepting messages, the callers blocked on a full queue fail with
+// ErrProducerClosed, and waits for the queued messages to be produced and their delivery reports,
+// for at most the timeout. The messages which are still pending then complete when their delivery
+// reports come in, Close returns an error with their count. Closing again returns nil.
+func (p *AsyncProducer) Close(timeout time.Duration) error {
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	close(p.closing)
+	p.enqueuing.Wait()
+	close(p.queue)
+
+	drained := make(chan struct{})
+	go func() {
+		p.workersWG.Wait()
+		p.pending.Wait()
+		close(p.deliveries)
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %v waiting for %d messages, %d were dropped", timeout, p.inFlight.Load(), p.dropped.Load())
+	}
+}
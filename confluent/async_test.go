@@ -0,0 +1,241 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// asyncProducer reports the delivery of the messages once the gate is open, the messages of the
+// topic failed fail to deliver.
+type asyncProducer struct {
+	gate     chan struct{}
+	mu       sync.Mutex
+	produced []*kafka.Message
+	offset   kafka.Offset
+}
+
+func newAsyncProducer() *asyncProducer {
+	return &asyncProducer{gate: make(chan struct{})}
+}
+
+func (f *asyncProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	if *msg.TopicPartition.Topic == "rejected" {
+		return errors.New("rejected")
+	}
+	f.mu.Lock()
+	f.produced = append(f.produced, msg)
+	msg.TopicPartition.Offset = f.offset
+	f.offset++
+	f.mu.Unlock()
+	go func() {
+		<-f.gate
+		if *msg.TopicPartition.Topic == "failed" {
+			msg.TopicPartition.Error = errors.New("delivery failed")
+		}
+		deliveryChan <- msg
+	}()
+	return nil
+}
+
+func newAsyncCodec() *kafkaavro.Codec {
+	registry := mockregistry.New()
+	for _, subject := range []string{"orders-value", "failed-value", "rejected-value"} {
+		registry.Register(subject, testSchema)
+	}
+	return kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+}
+
+func TestAsyncProducer(t *testing.T) {
+
+	producer := newAsyncProducer()
+	var depths []int
+	var depthMu sync.Mutex
+	async := NewAsyncProducer(producer, newAsyncCodec(), WithQueueSize(10), WithWorkers(2), WithQueueDepthHook(func(depth int) {
+		depthMu.Lock()
+		depths = append(depths, depth)
+		depthMu.Unlock()
+	}), WithMessageOptions(WithMetadataHeaders()))
+
+	var deliveries []*Delivery
+	for _, topic := range []string{"orders", "orders", "failed", "rejected", "unknown"} {
+		delivery, err := async.EnqueueEncode(topic, []byte("key"), map[string]interface{}{"f1": "value"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	close(producer.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, delivery := range deliveries[:2] {
+		m, err := delivery.Wait(ctx)
+		if err != nil || *m.TopicPartition.Topic != "orders" || string(m.Key) != "key" || len(m.Headers) == 0 || m.Opaque != nil {
+			t.Errorf("Wait() of message %d returned %v, %v", i, m, err)
+		}
+	}
+	if _, err := deliveries[2].Wait(ctx); err == nil || err.Error() != "delivery failed" {
+		t.Errorf("Wait() of the failed delivery returned %v", err)
+	}
+	if _, err := deliveries[3].Wait(ctx); err == nil || err.Error() != "rejected" {
+		t.Errorf("Wait() of the rejected message returned %v", err)
+	}
+	if _, err := deliveries[4].Wait(ctx); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("Wait() of the message which did not encode returned %v", err)
+	}
+
+	if err := async.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if stats := async.Stats(); stats != (AsyncStats{Enqueued: 5, Delivered: 2, Failed: 3}) {
+		t.Errorf("Stats() returned %+v", stats)
+	}
+	depthMu.Lock()
+	if len(depths) != 10 {
+		t.Errorf("the queue depth hook was called %d times, want twice per message", len(depths))
+	}
+	depthMu.Unlock()
+
+	if _, err := async.EnqueueEncode("orders", nil, nil); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("EnqueueEncode() after Close returned %v", err)
+	}
+	if err := async.Close(time.Second); err != nil {
+		t.Errorf("Close() again returned %v", err)
+	}
+}
+
+// blockingProducer blocks the worker in Produce until the gate is open.
+type blockingProducer struct {
+	asyncProducer
+	producing chan struct{}
+}
+
+func (f *blockingProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	f.producing <- struct{}{}
+	<-f.gate
+	return f.asyncProducer.Produce(msg, deliveryChan)
+}
+
+func TestAsyncProducerQueueFull(t *testing.T) {
+
+	value := map[string]interface{}{"f1": "value"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, policy := range []QueueFullPolicy{FailWhenFull, DropOldest, BlockWhenFull} {
+
+		producer := &blockingProducer{asyncProducer: *newAsyncProducer(), producing: make(chan struct{}, 1)}
+		async := NewAsyncProducer(producer, newAsyncCodec(), WithQueueSize(2), WithQueueFullPolicy(policy))
+
+		// the worker blocks on the first message, the next two fill the queue
+		var deliveries []*Delivery
+		for i := 0; i < 3; i++ {
+			delivery, err := async.EnqueueEncode("orders", nil, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deliveries = append(deliveries, delivery)
+			if i == 0 {
+				<-producer.producing
+			}
+		}
+
+		switch policy {
+		case FailWhenFull:
+			if _, err := async.EnqueueEncode("orders", nil, value); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("EnqueueEncode() of a full queue returned %v", err)
+			}
+		case DropOldest:
+			if _, err := async.EnqueueEncode("orders", nil, value); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := deliveries[1].Wait(ctx); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("Wait() of the oldest message returned %v", err)
+			}
+		case BlockWhenFull:
+			enqueued := make(chan error)
+			go func() {
+				_, err := async.EnqueueEncode("orders", nil, value)
+				enqueued <- err
+			}()
+			select {
+			case err := <-enqueued:
+				t.Fatalf("EnqueueEncode() of a full queue returned %v without blocking", err)
+			case <-time.After(10 * time.Millisecond):
+			}
+			go func() {
+				for range producer.producing {
+				}
+			}()
+			close(producer.gate)
+			if err := <-enqueued; err != nil {
+				t.Errorf("EnqueueEncode() after the queue had room returned %v", err)
+			}
+		}
+
+		if policy != BlockWhenFull {
+			go func() {
+				for range producer.producing {
+				}
+			}()
+			close(producer.gate)
+		}
+		if err := async.Close(time.Second); err != nil {
+			t.Errorf("%v: Close() returned %v", policy, err)
+		}
+		close(producer.producing)
+
+		stats := async.Stats()
+		wantDropped := map[QueueFullPolicy]uint64{FailWhenFull: 1, DropOldest: 1}[policy]
+		if stats.Dropped != wantDropped || stats.Delivered != 4-wantDropped || stats.Depth != 0 {
+			t.Errorf("%v: Stats() returned %+v", policy, stats)
+		}
+	}
+}
+
+func TestAsyncProducerClose(t *testing.T) {
+
+	// the callers blocked on a full queue fail when the producer is closed
+	producer := &blockingProducer{asyncProducer: *newAsyncProducer(), producing: make(chan struct{}, 1)}
+	async := NewAsyncProducer(producer, newAsyncCodec(), WithQueueSize(1))
+	value := map[string]interface{}{"f1": "value"}
+
+	first, _ := async.EnqueueEncode("orders", nil, value)
+	<-producer.producing
+	async.EnqueueEncode("orders", nil, value)
+	blocked := make(chan error)
+	go func() {
+		_, err := async.EnqueueEncode("orders", nil, value)
+		blocked <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// the close times out while the worker is blocked, and reports the pending messages
+	err := async.Close(20 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "waiting for 2 messages") {
+		t.Errorf("Close() returned %v", err)
+	}
+	if err := <-blocked; !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("the blocked EnqueueEncode() returned %v", err)
+	}
+
+	// the pending messages complete after the close
+	go func() {
+		for range producer.producing {
+		}
+	}()
+	close(producer.gate)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := first.Wait(ctx); err != nil {
+		t.Errorf("Wait() after Close() returned %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ClaimCheckHeader is the header of a message of which the value was offloaded by an
+// OversizeHandler, see WithOversizeHandler. Its value is the reference returned by Offload. The
+// value of the message is then only the envelope of the offloaded data: its magic byte and schema
+// id, so that the consumers which do not retrieve it fail to decode it rather than take it for a
+// value, or for a tombstone. DecodeValue and DecodeMessage fail on it with ErrClaimCheck.
+const ClaimCheckHeader = "x-claim-check"
+
+var (
+	// ErrClaimNotFound is the error of MemoryOversizeHandler.Retrieve for an unknown reference.
+	ErrClaimNotFound = errors.New("claim not found")
+	// ErrClaimCheck is the error of DecodeValue and DecodeMessage for a message of which the value
+	// was offloaded: it has the ClaimCheckHeader and its value is only the envelope. Claim the value
+	// first, see Claim and WithClaimCheck.
+	ErrClaimCheck = errors.New("the value is offloaded to a claim check")
+)
+
+// OversizeHandler stores the values which are too large to produce, e.g. in an object store, and
+// retrieves them by their reference, see WithOversizeHandler and Claim.
+type OversizeHandler interface {
+	// Offload stores the payload and returns its reference, e.g. the key of the object.
+	Offload(ctx context.Context, payload []byte) (reference []byte, err error)
+	// Retrieve returns the payload of the reference.
+	Retrieve(ctx context.Context, reference []byte) (payload []byte, err error)
+}
+
+// WithOversizeHandler offloads the encoded values of more than threshold bytes, e.g. the max
+// message size of the broker minus some room for the key and headers, with the handler. The message
+// then has the ClaimCheckHeader with the reference and the envelope of the value, see Claim. The
+// metadata headers of WithMetadataHeaders describe the offloaded value.
+func WithOversizeHandler(handler OversizeHandler, threshold int) MessageOption {
+	return func(o *messageOptions) {
+		o.oversize = handler
+		o.threshold = threshold
+	}
+}
+
+// envelopeSize is the size of the magic byte and the schema id of the wire format.
+const envelopeSize = 5
+
+// offload offloads the data with the handler and returns its envelope and the headers with the
+// ClaimCheckHeader.
+func offload(ctx context.Context, handler OversizeHandler, data []byte, headers []kafka.Header) (envelope []byte, _ []kafka.Header, err error) {
+
+	reference, err := handler.Offload(ctx, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("offload of the value of %d bytes: %w", len(data), err)
+	}
+	envelope = data[:min(len(data), envelopeSize):min(len(data), envelopeSize)]
+	return envelope, append(headers, kafka.Header{Key: ClaimCheckHeader, Value: reference}), nil
+}
+
+// Claim returns the message with the value retrieved with the handler if it has the
+// ClaimCheckHeader, without the header, and the message itself otherwise, to decode it, e.g. with
+// DecodeMessage. The retrieved value must start with the envelope of the message.
+func Claim(ctx context.Context, handler OversizeHandler, m *kafka.Message) (claimed *kafka.Message, err error) {
+
+	i := claimCheckIndex(m)
+	if i < 0 {
+		return m, nil
+	}
+	reference := m.Headers[i].Value
+	headers := append(m.Headers[:i:i], m.Headers[i+1:]...)
+
+	value, err := handler.Retrieve(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve of the value of the %v %q: %w", ClaimCheckHeader, reference, err)
+	}
+	if !bytes.HasPrefix(value, m.Value) {
+		return nil, fmt.Errorf("the value of the %v %q does not start with the envelope % x of the message", ClaimCheckHeader, reference, m.Value)
+	}
+
+	copied := *m
+	copied.Value = value
+	copied.Headers = headers
+	return &copied, nil
+}
+
+// WithClaimCheck makes DecodeMessage retrieve the offloaded values with the handler, see Claim,
+// rather than failing with ErrClaimCheck.
+func WithClaimCheck(handler OversizeHandler) DecodeOption {
+	return func(o *decodeOptions) {
+		o.claimCheck = handler
+	}
+}
+
+// claimCheckIndex returns the index of the first ClaimCheckHeader of the message, -1 if it has none.
+func claimCheckIndex(m *kafka.Message) int {
+	for i, header := range m.Headers {
+		if header.Key == ClaimCheckHeader {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkClaimed returns ErrClaimCheck for a message of which the value is the envelope of an
+// offloaded value.
+func checkClaimed(m *kafka.Message) error {
+	if i := claimCheckIndex(m); i >= 0 && len(m.Value) <= envelopeSize {
+		return fmt.Errorf("%w %q, claim it first", ErrClaimCheck, m.Headers[i].Value)
+	}
+	return nil
+}
+
+// MemoryOversizeHandler is an OversizeHandler which keeps the payloads in memory, e.g. for tests.
+type MemoryOversizeHandler struct {
+	mu       sync.Mutex
+	payloads map[string][]byte
+	next     int
+}
+
+// NewMemoryOversizeHandler creates an empty MemoryOversizeHandler.
+func NewMemoryOversizeHandler() *MemoryOversizeHandler {
+	return &MemoryOversizeHandler{payloads: make(map[string][]byte)}
+}
+
+// Offload keeps a copy of the payload and returns its reference, memory-1, memory-2...
+func (h *MemoryOversizeHandler) Offload(ctx context.Context, payload []byte) (reference []byte, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next++
+	key := "memory-" + strconv.Itoa(h.next)
+	h.payloads[key] = bytes.Clone(payload)
+	return []byte(key), nil
+}
+
+// Retrieve returns the payload of the reference, or ErrClaimNotFound.
+func (h *MemoryOversizeHandler) Retrieve(ctx context.Context, reference []byte) (payload []byte, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	payload, found := h.payloads[string(reference)]
+	if !found {
+		return nil, ErrClaimNotFound
+	}
+	return bytes.Clone(payload), nil
+}
+
+// Len returns the number of payloads.
+func (h *MemoryOversizeHandler) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.payloads)
+}
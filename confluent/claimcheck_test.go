@@ -0,0 +1,120 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestClaimCheck(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	handler := NewMemoryOversizeHandler()
+	ctx := context.Background()
+	source := kafka.Header{Key: "source", Value: []byte("test")}
+
+	// the small values are not offloaded
+	small := map[string]interface{}{"f1": "small"}
+	m, err := NewMessageContext(ctx, codec, "orders", nil, small, WithOversizeHandler(handler, 100), WithHeaders(source))
+	if err != nil || len(m.Headers) != 1 || handler.Len() != 0 {
+		t.Fatalf("NewMessageContext() of a small value returned %v, %v", m, err)
+	}
+	if claimed, err := Claim(ctx, handler, m); err != nil || claimed != m {
+		t.Errorf("Claim() of a message without the %v returned %v, %v", ClaimCheckHeader, claimed, err)
+	}
+
+	// the large ones are, and the message has the reference and the envelope
+	large := map[string]interface{}{"f1": strings.Repeat("x", 200)}
+	data, err := codec.Encode("orders", false, large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = NewMessage(codec, "orders", []byte("order-1"), large, WithOversizeHandler(handler, 100), WithHeaders(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []kafka.Header{source, {Key: ClaimCheckHeader, Value: []byte("memory-1")}}
+	if !bytes.Equal(m.Value, data[:5]) || !reflect.DeepEqual(m.Headers, want) || handler.Len() != 1 {
+		t.Fatalf("NewMessage() of a large value returned the value % x with the headers %v", m.Value, m.Headers)
+	}
+	if _, err = DecodeValue(codec, m); !errors.Is(err, ErrClaimCheck) {
+		t.Errorf("DecodeValue() of the envelope returned %v, want ErrClaimCheck", err)
+	}
+	if _, err = DecodeMessage(codec, m); !errors.Is(err, ErrClaimCheck) || !strings.Contains(err.Error(), "memory-1") {
+		t.Errorf("DecodeMessage() of the envelope returned %v, want ErrClaimCheck with the reference", err)
+	}
+
+	claimed, err := Claim(ctx, handler, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(claimed.Value, data) || !reflect.DeepEqual(claimed.Headers, []kafka.Header{source}) || string(claimed.Key) != "order-1" {
+		t.Errorf("Claim() returned %v", claimed)
+	}
+	if m.Headers[1].Key != ClaimCheckHeader {
+		t.Error("Claim() changed the message")
+	}
+	message, err := DecodeMessage(codec, claimed)
+	if err != nil || !reflect.DeepEqual(message.Value, large) {
+		t.Errorf("DecodeMessage() of the claimed message returned %v, %v", message.Value, err)
+	}
+
+	// DecodeMessage claims the value itself with the handler
+	message, err = DecodeMessage(codec, m, WithClaimCheck(handler))
+	if err != nil || !reflect.DeepEqual(message.Value, large) || !reflect.DeepEqual(message.Headers, []kafkaavro.Header{{Key: "source", Value: []byte("test")}}) {
+		t.Errorf("DecodeMessage() with WithClaimCheck returned %v with the headers %v, %v", message.Value, message.Headers, err)
+	}
+	if _, err = DecodeMessage(codec, m, WithClaimCheck(NewMemoryOversizeHandler())); !errors.Is(err, ErrClaimNotFound) {
+		t.Errorf("DecodeMessage() with a handler without the claim returned %v, want ErrClaimNotFound", err)
+	}
+}
+
+type failingOversizeHandler struct{}
+
+func (failingOversizeHandler) Offload(ctx context.Context, payload []byte) (reference []byte, err error) {
+	return nil, errors.New("bucket unavailable")
+}
+
+func (failingOversizeHandler) Retrieve(ctx context.Context, reference []byte) (payload []byte, err error) {
+	return nil, errors.New("bucket unavailable")
+}
+
+func TestClaimCheckErrors(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	ctx := context.Background()
+	value := map[string]interface{}{"f1": "value"}
+
+	if _, err := NewMessage(codec, "orders", nil, value, WithOversizeHandler(failingOversizeHandler{}, 0)); err == nil || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Errorf("NewMessage() with a failing handler returned %v", err)
+	}
+
+	handler := NewMemoryOversizeHandler()
+	m, err := NewMessage(codec, "orders", nil, value, WithOversizeHandler(handler, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Claim(ctx, failingOversizeHandler{}, m); err == nil || !strings.Contains(err.Error(), "bucket unavailable") {
+		t.Errorf("Claim() with a failing handler returned %v", err)
+	}
+	if _, err = Claim(ctx, NewMemoryOversizeHandler(), m); !errors.Is(err, ErrClaimNotFound) {
+		t.Errorf("Claim() of an unknown reference returned %v", err)
+	}
+
+	// the retrieved value must have the schema id of the envelope
+	m.Value = []byte{0, 0, 0, 0, 99}
+	if _, err = Claim(ctx, handler, m); err == nil || !strings.Contains(err.Error(), "envelope") {
+		t.Errorf("Claim() of a value of another envelope returned %v", err)
+	}
+}
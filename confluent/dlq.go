@@ -0,0 +1,160 @@
+package confluent
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+// The headers which DLQProducer adds to the messages of the dead letter queue.
+const (
+	ErrorMessageHeader      = "x-error-message"
+	ErrorTypeHeader         = "x-error-type"
+	FailedAtHeader          = "x-failed-at"
+	OriginalTopicHeader     = "x-original-topic"
+	OriginalPartitionHeader = "x-original-partition"
+	OriginalOffsetHeader    = "x-original-offset"
+)
+
+// Producer is the part of the kafka.Producer used by DLQProducer.
+type Producer interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+}
+
+// DLQProducer produces the messages which failed, e.g. to decode, to a dead letter queue, with
+// headers which tell why and where they failed. Recover reconstructs the original messages.
+type DLQProducer struct {
+	producer     Producer
+	topicPattern string
+	now          func() time.Time
+}
+
+// NewDLQProducer creates a DLQProducer which produces to the topic of the pattern, in which %s is
+// the topic of the failed message, e.g. %s.dlq, or to the pattern itself if it has no %s.
+func NewDLQProducer(producer Producer, topicPattern string) *DLQProducer {
+	return &DLQProducer{producer: producer, topicPattern: topicPattern, now: time.Now}
+}
+
+// Topic returns the dead letter queue of the topic.
+func (p *DLQProducer) Topic(topic string) string {
+	if !strings.Contains(p.topicPattern, "%s") {
+		return p.topicPattern
+	}
+	return fmt.Sprintf(p.topicPattern, topic)
+}
+
+// Send produces the key and value of the failed message unchanged to its dead letter queue, with
+// its headers and timestamp. The headers get the error message, its type (see
+// kafkaavro.ErrorType), the time of the failure in RFC 3339, the topic, partition and offset of
+// the message, and the SchemaIDHeader if the value is in the wire format and the message has none.
+// The deliveryChan is passed to the producer, see kafka.Producer.Produce.
+func (p *DLQProducer) Send(m *kafka.Message, failure error, deliveryChan chan kafka.Event) (err error) {
+
+	if m.TopicPartition.Topic == nil {
+		return errors.New("message has no topic")
+	}
+	topic := *m.TopicPartition.Topic
+	dlqTopic := p.Topic(topic)
+
+	var headers []kafka.Header
+	var hasSchemaID bool
+	for _, header := range m.Headers {
+		switch header.Key {
+		case ErrorMessageHeader, ErrorTypeHeader, FailedAtHeader, OriginalTopicHeader, OriginalPartitionHeader, OriginalOffsetHeader:
+			// the headers of a message which failed before are replaced
+			continue
+		case SchemaIDHeader:
+			hasSchemaID = true
+		}
+		headers = append(headers, header)
+	}
+
+	var message string
+	if failure != nil {
+		message = failure.Error()
+	}
+	headers = append(headers,
+		kafka.Header{Key: ErrorMessageHeader, Value: []byte(message)},
+		kafka.Header{Key: ErrorTypeHeader, Value: []byte(kafkaavro.ErrorType(failure))},
+		kafka.Header{Key: FailedAtHeader, Value: []byte(p.now().UTC().Format(time.RFC3339))},
+		kafka.Header{Key: OriginalTopicHeader, Value: []byte(topic)},
+		kafka.Header{Key: OriginalPartitionHeader, Value: []byte(strconv.FormatInt(int64(m.TopicPartition.Partition), 10))},
+		kafka.Header{Key: OriginalOffsetHeader, Value: []byte(strconv.FormatInt(int64(m.TopicPartition.Offset), 10))},
+	)
+	if schemaID, _, parseErr := kafkaavro.ParseWireFormat(m.Value); parseErr == nil && !hasSchemaID {
+		headers = append(headers, kafka.Header{Key: SchemaIDHeader, Value: []byte(strconv.Itoa(schemaID))})
+	}
+
+	err = p.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &dlqTopic, Partition: kafka.PartitionAny},
+		Key:            m.Key,
+		Value:          m.Value,
+		Headers:        headers,
+		Timestamp:      m.Timestamp,
+	}, deliveryChan)
+	if err != nil {
+		return fmt.Errorf("failed to produce to dead letter queue %v: %w", dlqTopic, err)
+	}
+	return
+}
+
+// DeadLetter is a message of a dead letter queue, see Recover.
+type DeadLetter struct {
+	// Original is the message which failed, to replay it. Its topic partition is the one of the
+	// failed message, the producer ignores the offset.
+	Original     *kafka.Message
+	ErrorMessage string
+	ErrorType    string
+	FailedAt     time.Time
+}
+
+// Recover reconstructs the original message of a message which DLQProducer produced: the headers
+// of DLQProducer are removed, except the SchemaIDHeader which is the one of the value.
+func Recover(m *kafka.Message) (letter DeadLetter, err error) {
+
+	original := &kafka.Message{Key: m.Key, Value: m.Value, Timestamp: m.Timestamp}
+	var topic, partition, offset, failedAt string
+	var found bool
+	for _, header := range m.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case ErrorMessageHeader:
+			letter.ErrorMessage = value
+		case ErrorTypeHeader:
+			letter.ErrorType = value
+		case FailedAtHeader:
+			failedAt = value
+		case OriginalTopicHeader:
+			topic, found = value, true
+		case OriginalPartitionHeader:
+			partition = value
+		case OriginalOffsetHeader:
+			offset = value
+		default:
+			original.Headers = append(original.Headers, header)
+		}
+	}
+	if !found {
+		return letter, fmt.Errorf("the message has no %v header, it is not a message of a dead letter queue", OriginalTopicHeader)
+	}
+
+	p, err := strconv.ParseInt(partition, 10, 32)
+	if err != nil {
+		return letter, fmt.Errorf("invalid %v header %q: %w", OriginalPartitionHeader, partition, err)
+	}
+	o, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil {
+		return letter, fmt.Errorf("invalid %v header %q: %w", OriginalOffsetHeader, offset, err)
+	}
+	if letter.FailedAt, err = time.Parse(time.RFC3339, failedAt); err != nil {
+		return letter, fmt.Errorf("invalid %v header %q: %w", FailedAtHeader, failedAt, err)
+	}
+	original.TopicPartition = kafka.TopicPartition{Topic: &topic, Partition: int32(p), Offset: kafka.Offset(o)}
+	letter.Original = original
+	return
+}
@@ -0,0 +1,107 @@
+package confluent
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+type fakeProducer struct {
+	produced []*kafka.Message
+	err      error
+}
+
+func (f *fakeProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.produced = append(f.produced, msg)
+	return nil
+}
+
+func TestDLQProducer(t *testing.T) {
+
+	producer := &fakeProducer{}
+	dlq := NewDLQProducer(producer, "%s.dlq")
+	failedAt := time.Date(2020, 11, 1, 12, 30, 0, 0, time.UTC)
+	dlq.now = func() time.Time { return failedAt }
+
+	topic := "orders"
+	m := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 42},
+		Key:            []byte("order-1"),
+		Value:          []byte{0, 0, 0, 0, 7, 2},
+		Headers:        []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+		Timestamp:      failedAt.Add(-time.Hour),
+	}
+	failure := &kafkaavro.DecodeError{Size: 6, Err: fmt.Errorf("failed to fetch schema 7: %w", kafkaavro.ErrSchemaNotFound)}
+	if err := dlq.Send(m, failure, nil); err != nil {
+		t.Fatalf("Send() returned %v", err)
+	}
+
+	if len(producer.produced) != 1 {
+		t.Fatalf("produced %d messages", len(producer.produced))
+	}
+	produced := producer.produced[0]
+	if *produced.TopicPartition.Topic != "orders.dlq" || string(produced.Key) != "order-1" || string(produced.Value) != string(m.Value) || !produced.Timestamp.Equal(m.Timestamp) {
+		t.Errorf("produced %v", produced)
+	}
+	want := []kafka.Header{
+		{Key: "trace-id", Value: []byte("abc")},
+		{Key: ErrorMessageHeader, Value: []byte(failure.Error())},
+		{Key: ErrorTypeHeader, Value: []byte("ErrSchemaNotFound")},
+		{Key: FailedAtHeader, Value: []byte("2020-11-01T12:30:00Z")},
+		{Key: OriginalTopicHeader, Value: []byte("orders")},
+		{Key: OriginalPartitionHeader, Value: []byte("3")},
+		{Key: OriginalOffsetHeader, Value: []byte("42")},
+		{Key: SchemaIDHeader, Value: []byte("7")},
+	}
+	if !reflect.DeepEqual(produced.Headers, want) {
+		t.Errorf("the headers are %v, want %v", produced.Headers, want)
+	}
+
+	letter, err := Recover(produced)
+	if err != nil {
+		t.Fatalf("Recover() returned %v", err)
+	}
+	if letter.ErrorMessage != failure.Error() || letter.ErrorType != "ErrSchemaNotFound" || !letter.FailedAt.Equal(failedAt) {
+		t.Errorf("Recover() returned %+v", letter)
+	}
+	original := letter.Original
+	if *original.TopicPartition.Topic != topic || original.TopicPartition.Partition != 3 || original.TopicPartition.Offset != 42 ||
+		string(original.Key) != "order-1" || string(original.Value) != string(m.Value) {
+		t.Errorf("Recover() returned the original %v", original)
+	}
+	wantHeaders := []kafka.Header{{Key: "trace-id", Value: []byte("abc")}, {Key: SchemaIDHeader, Value: []byte("7")}}
+	if !reflect.DeepEqual(original.Headers, wantHeaders) {
+		t.Errorf("the headers of the original are %v, want %v", original.Headers, wantHeaders)
+	}
+
+	// a recovered message which fails again gets new headers, a value which is not in the wire
+	// format no schema id
+	second := "orders.dlq"
+	again := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &second, Partition: 0, Offset: 7}, Value: []byte("{}"), Headers: produced.Headers[:7]}
+	if err = NewDLQProducer(producer, "errors").Send(again, kafkaavro.ErrUnknownMagicByte, nil); err != nil {
+		t.Fatal(err)
+	}
+	resent := producer.produced[1]
+	if *resent.TopicPartition.Topic != "errors" || len(resent.Headers) != 7 {
+		t.Errorf("produced %v with the headers %v", resent, resent.Headers)
+	}
+	if letter, err = Recover(resent); err != nil || *letter.Original.TopicPartition.Topic != second || letter.ErrorType != "ErrUnknownMagicByte" {
+		t.Errorf("Recover() returned %+v, %v", letter, err)
+	}
+
+	producer.err = errors.New("queue full")
+	if err = dlq.Send(m, failure, nil); err == nil || err.Error() != "failed to produce to dead letter queue orders.dlq: queue full" {
+		t.Errorf("Send() with a failing producer returned %v", err)
+	}
+	if _, err = Recover(m); err == nil {
+		t.Error("Recover() of a message which is not a dead letter did not fail")
+	}
+}
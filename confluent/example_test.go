@@ -0,0 +1,70 @@
+package confluent_test
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/confluent"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// poller is the part of the *kafka.Consumer used by the consumer loop.
+type poller interface {
+	Poll(timeoutMs int) kafka.Event
+}
+
+// messages replays the messages, like a consumer of a topic which holds them.
+type messages []*kafka.Message
+
+func (m *messages) Poll(int) kafka.Event {
+	if len(*m) == 0 {
+		return nil
+	}
+	next := (*m)[0]
+	*m = (*m)[1:]
+	return next
+}
+
+func Example_consumerLoop() {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`)
+
+	// one codec for all messages, it fetches every schema once
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	topic := "orders"
+	var consumer poller = &messages{
+		{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 0}, Value: []byte{0, 0, 0, 0, 1, 2}},
+		{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 1}, Value: nil},
+		{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 2}, Value: []byte("not avro")},
+		{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 3}, Value: []byte{0, 0, 0, 0, 1, 4}},
+	}
+
+	// with a *kafka.Consumer this loop runs until the consumer is closed
+	for ev := consumer.Poll(100); ev != nil; ev = consumer.Poll(100) {
+
+		m, ok := ev.(*kafka.Message)
+		if !ok {
+			continue
+		}
+
+		if len(m.Value) == 0 {
+			fmt.Printf("offset %d: null, a delete on a log-compacted topic\n", m.TopicPartition.Offset)
+			continue
+		}
+
+		native, err := confluent.DecodeValue(codec, m)
+		if err != nil {
+			fmt.Printf("offset %d: skipped: %v\n", m.TopicPartition.Offset, err)
+			continue
+		}
+		fmt.Printf("offset %d: %v\n", m.TopicPartition.Offset, native)
+	}
+	// Output:
+	// offset 0: map[id:1]
+	// offset 1: null, a delete on a log-compacted topic
+	// offset 2: skipped: failed to decode 8 bytes 6e6f74206176726f: unknown magic byte 110, expected 0
+	// offset 3: map[id:2]
+}
@@ -0,0 +1,248 @@
+// Package confluent decodes and encodes the messages of the confluent-kafka-go client with a
+// kafkaavro.Codec. It is a separate package so that the kafkaavro package itself does not
+// depend on the kafka client (and librdkafka).
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+)
+
+// DecodeKey decodes the key of the message with the key subject of its topic.
+func DecodeKey(codec *kafkaavro.Codec, m *kafka.Message) (native interface{}, err error) {
+	return decode(codec, m, true, m.Key)
+}
+
+// DecodeValue decodes the value of the message with the value subject of its topic. A value
+// offloaded with WithOversizeHandler fails with ErrClaimCheck, see Claim.
+func DecodeValue(codec *kafkaavro.Codec, m *kafka.Message) (native interface{}, err error) {
+	if err = checkClaimed(m); err != nil {
+		return
+	}
+	return decode(codec, m, false, m.Value)
+}
+
+func decode(codec *kafkaavro.Codec, m *kafka.Message, isKey bool, data []byte) (native interface{}, err error) {
+	if m.TopicPartition.Topic == nil {
+		return nil, errors.New("message has no topic")
+	}
+	return codec.Decode(*m.TopicPartition.Topic, isKey, data)
+}
+
+// DecodeOption is an option of DecodeMessage.
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	claimCheck OversizeHandler
+}
+
+// DecodeMessage decodes the key and value of the message, see kafkaavro.Codec.DecodeMessage, along
+// with its partition, offset, timestamp and headers. A value of which the schema id header does not
+// match its framing is decoded following kafkaavro.WithSchemaIDPrecedence, see
+// kafkaavro.Codec.DecodeWithHeaders. A value offloaded with WithOversizeHandler fails with
+// ErrClaimCheck, unless WithClaimCheck retrieves it.
+func DecodeMessage(codec *kafkaavro.Codec, m *kafka.Message, options ...DecodeOption) (message kafkaavro.DecodedMessage, err error) {
+	return DecodeMessageContext(context.Background(), codec, m, options...)
+}
+
+// DecodeMessageContext is DecodeMessage with a context, which is passed to the OversizeHandler of
+// WithClaimCheck.
+func DecodeMessageContext(ctx context.Context, codec *kafkaavro.Codec, m *kafka.Message, options ...DecodeOption) (message kafkaavro.DecodedMessage, err error) {
+
+	var o decodeOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	if m.TopicPartition.Topic == nil {
+		return message, errors.New("message has no topic")
+	}
+	if o.claimCheck != nil {
+		if m, err = Claim(ctx, o.claimCheck, m); err != nil {
+			return
+		}
+	} else if err = checkClaimed(m); err != nil {
+		return
+	}
+	headers := headersOf(m)
+	value := m.Value
+	if value != nil {
+		if value, err = codec.ResolveHeaders(*m.TopicPartition.Topic, value, headers); err != nil {
+			return
+		}
+	}
+	if message, err = codec.DecodeMessage(*m.TopicPartition.Topic, m.Key, value); err != nil {
+		return
+	}
+	message.Partition = m.TopicPartition.Partition
+	message.Offset = int64(m.TopicPartition.Offset)
+	message.Timestamp = m.Timestamp
+	message.Headers = headers
+	return
+}
+
+// headersOf returns the headers of the message.
+func headersOf(m *kafka.Message) (headers []kafkaavro.Header) {
+	for _, header := range m.Headers {
+		headers = append(headers, kafkaavro.Header{Key: header.Key, Value: header.Value})
+	}
+	return
+}
+
+// DispatchValue decodes the value of the message with the deserializer of the dispatcher of its
+// header, see kafkaavro.HeaderDispatcher.
+func DispatchValue(dispatcher *kafkaavro.HeaderDispatcher, m *kafka.Message) (value interface{}, discriminator string, err error) {
+	if m.TopicPartition.Topic == nil {
+		return nil, "", errors.New("message has no topic")
+	}
+	return dispatcher.Decode(*m.TopicPartition.Topic, false, m.Value, headersOf(m))
+}
+
+// SchemaIDHeader is the header with the schema id of the value of a message, see WithSchemaIDHeader.
+const SchemaIDHeader = "x-schema-id"
+
+// Body is the avro body of a value, without the magic byte and the schema id, see
+// WithSchemaIDHeader.
+type Body []byte
+
+// MessageOption is an option of NewMessage.
+type MessageOption func(*messageOptions)
+
+type messageOptions struct {
+	headers         []kafka.Header
+	schemaIDHeader  bool
+	checkSchemaID   bool
+	metadataHeaders bool
+	oversize        OversizeHandler
+	threshold       int
+}
+
+// WithHeaders sets the headers of the message.
+func WithHeaders(headers ...kafka.Header) MessageOption {
+	return func(o *messageOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// WithSchemaIDHeader encodes the value with the schema of the id in the SchemaIDHeader of the
+// headers (see WithHeaders), when there is one, rather than with the latest schema of the subject
+// of the topic: e.g. a service which publishes messages to other topics keeps their schema id. A
+// Body is framed with the schema id without looking it up, unless checkSchemaID is true, the other
+// values are encoded with the schema of the id. The header is the id in decimal, e.g. 42.
+func WithSchemaIDHeader(checkSchemaID bool) MessageOption {
+	return func(o *messageOptions) {
+		o.schemaIDHeader = true
+		o.checkSchemaID = checkSchemaID
+	}
+}
+
+// WithMetadataHeaders adds the headers of kafkaavro.Codec.EncodeWithHeaders which describe the
+// value, the content type, schema id and subject, to the headers of the message. The headers which
+// the message has already, see WithHeaders, are kept.
+func WithMetadataHeaders() MessageOption {
+	return func(o *messageOptions) {
+		o.metadataHeaders = true
+	}
+}
+
+// NewMessage creates a message for any partition of the topic, with the value encoded with the
+// latest schema of the value subject of the topic. The key is used as is.
+func NewMessage(codec *kafkaavro.Codec, topic string, key []byte, value interface{}, options ...MessageOption) (m *kafka.Message, err error) {
+	return NewMessageContext(context.Background(), codec, topic, key, value, options...)
+}
+
+// NewMessageContext is NewMessage with a context, which is passed to the OversizeHandler of
+// WithOversizeHandler.
+func NewMessageContext(ctx context.Context, codec *kafkaavro.Codec, topic string, key []byte, value interface{}, options ...MessageOption) (m *kafka.Message, err error) {
+
+	var o messageOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	var data []byte
+	schemaID, found, err := headerSchemaID(o)
+	switch {
+	case err != nil:
+	case !found:
+		if _, ok := value.(Body); ok {
+			return nil, fmt.Errorf("the value is a Body but the message has no %v header", SchemaIDHeader)
+		}
+		data, err = codec.Encode(topic, false, value)
+	default:
+		body, ok := value.(Body)
+		if !ok {
+			data, err = codec.EncodeWithSchemaID(schemaID, value)
+			break
+		}
+		data = codec.EncodeFramed(schemaID, body)
+		if o.checkSchemaID {
+			_, _, err = codec.WriterSchema(data)
+		}
+	}
+	if err != nil {
+		return
+	}
+	if o.metadataHeaders {
+		if o.headers, err = addMetadataHeaders(codec, topic, data, o.headers); err != nil {
+			return
+		}
+	}
+
+	if o.oversize != nil && len(data) > o.threshold {
+		if data, o.headers, err = offload(ctx, o.oversize, data, o.headers); err != nil {
+			return
+		}
+	}
+
+	m = &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          data,
+		Headers:        o.headers,
+	}
+	return
+}
+
+// addMetadataHeaders adds the metadata headers of the value which are not in the headers.
+func addMetadataHeaders(codec *kafkaavro.Codec, topic string, data []byte, headers []kafka.Header) ([]kafka.Header, error) {
+
+	metadata, err := codec.MetadataHeaders(topic, false, data)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		present[header.Key] = true
+	}
+	for _, header := range metadata {
+		if !present[header.Key] {
+			headers = append(headers, kafka.Header{Key: header.Key, Value: header.Value})
+		}
+	}
+	return headers, nil
+}
+
+// headerSchemaID returns the schema id of the SchemaIDHeader, if WithSchemaIDHeader is used.
+func headerSchemaID(o messageOptions) (schemaID kafkaavro.SchemaID, found bool, err error) {
+
+	if !o.schemaIDHeader {
+		return
+	}
+	for _, header := range o.headers {
+		if header.Key != SchemaIDHeader {
+			continue
+		}
+		id, parseErr := strconv.ParseUint(string(header.Value), 10, 32)
+		if parseErr != nil {
+			return 0, false, fmt.Errorf("invalid %v header %q: %w", SchemaIDHeader, header.Value, parseErr)
+		}
+		return kafkaavro.SchemaID(id), true, nil
+	}
+	return
+}
@@ -0,0 +1,205 @@
+package confluent
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+
+func TestMessageRoundTrip(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-key", `"string"`)
+	registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	key, err := codec.Encode("orders", true, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := map[string]interface{}{"f1": "value"}
+
+	m, err := NewMessage(codec, "orders", key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *m.TopicPartition.Topic != "orders" || m.TopicPartition.Partition != kafka.PartitionAny {
+		t.Errorf("NewMessage() created a message for %v", m.TopicPartition)
+	}
+
+	decodedKey, err := DecodeKey(codec, m)
+	if err != nil || decodedKey != "order-1" {
+		t.Errorf("DecodeKey() returned %v, %v", decodedKey, err)
+	}
+
+	decodedValue, err := DecodeValue(codec, m)
+	if err != nil || !reflect.DeepEqual(decodedValue, value) {
+		t.Errorf("DecodeValue() returned %v, %v", decodedValue, err)
+	}
+
+	if _, err = DecodeValue(codec, &kafka.Message{Value: m.Value}); err == nil {
+		t.Errorf("DecodeValue() of a message without topic did not fail")
+	}
+}
+
+func TestNewMessageSchemaIDHeader(t *testing.T) {
+
+	registry := mockregistry.New()
+	first := registry.Register("orders-value", testSchema)
+	registry.Register("orders-value", `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"},{"name":"f2","type":"int","default":0}]}`)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	value := map[string]interface{}{"f1": "value"}
+
+	// the message of another topic keeps the schema id of its header
+	in, err := codec.EncodeWithSchemaID(first, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := []kafka.Header{{Key: "trace", Value: []byte("1")}, {Key: SchemaIDHeader, Value: []byte(strconv.Itoa(first))}}
+
+	for _, v := range []interface{}{value, Body(in[5:])} {
+		for _, check := range []bool{false, true} {
+			m, err := NewMessage(codec, "archive", nil, v, WithHeaders(headers...), WithSchemaIDHeader(check))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(m.Value, in) || !reflect.DeepEqual(m.Headers, headers) {
+				t.Errorf("NewMessage() of a %T returned %v with headers %v, want %v", v, m.Value, m.Headers, in)
+			}
+		}
+	}
+
+	// without the header, or the option, the latest schema of the subject is used
+	for _, options := range [][]MessageOption{{WithSchemaIDHeader(true)}, {WithHeaders(headers...)}} {
+		m, err := NewMessage(codec, "orders", nil, value, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, _, _ := codec.WriterSchema(m.Value); id == first {
+			t.Errorf("NewMessage() without the schema id header encoded with schema %v", id)
+		}
+	}
+
+	// a Body is only checked against the registry with checkSchemaID
+	unknown := []kafka.Header{{Key: SchemaIDHeader, Value: []byte("99")}}
+	if _, err = NewMessage(codec, "archive", nil, Body(in[5:]), WithHeaders(unknown...), WithSchemaIDHeader(false)); err != nil {
+		t.Errorf("NewMessage() of a Body with an unchecked schema id returned %v", err)
+	}
+	if _, err = NewMessage(codec, "archive", nil, Body(in[5:]), WithHeaders(unknown...), WithSchemaIDHeader(true)); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("NewMessage() of a Body with an unknown schema id returned %v", err)
+	}
+	invalid := []kafka.Header{{Key: SchemaIDHeader, Value: []byte("x")}}
+	if _, err = NewMessage(codec, "archive", nil, value, WithHeaders(invalid...), WithSchemaIDHeader(false)); err == nil {
+		t.Error("NewMessage() with an invalid schema id header succeeded")
+	}
+	if _, err = NewMessage(codec, "archive", nil, Body(in[5:])); err == nil {
+		t.Error("NewMessage() of a Body without a schema id succeeded")
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	value := map[string]interface{}{"f1": "value"}
+	m, err := NewMessage(codec, "orders", []byte("order-1"), value, WithHeaders(kafka.Header{Key: "source", Value: []byte("test")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.TopicPartition.Partition = 3
+	m.TopicPartition.Offset = 42
+	m.Timestamp = time.Unix(1700000000, 0)
+
+	message, err := DecodeMessage(codec, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := kafkaavro.DecodedMessage{
+		Topic:       "orders",
+		Partition:   3,
+		Offset:      42,
+		Timestamp:   m.Timestamp,
+		Headers:     []kafkaavro.Header{{Key: "source", Value: []byte("test")}},
+		Key:         []byte("order-1"),
+		Value:       value,
+		ValueSchema: kafkaavro.SchemaInfo{ID: id, Subject: "orders-value", Schema: testSchema, RecordName: "myrecord", SchemaType: "AVRO"},
+	}
+	if !reflect.DeepEqual(message, want) {
+		t.Errorf("DecodeMessage() returned %+v, want %+v", message, want)
+	}
+
+	if _, err = DecodeMessage(codec, &kafka.Message{Value: m.Value}); err == nil {
+		t.Errorf("DecodeMessage() of a message without topic did not fail")
+	}
+}
+
+func TestDispatchValue(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	dispatcher := kafkaavro.NewHeaderDispatcher("event-type").Register("OrderCreated", codec.Deserializer())
+
+	value := map[string]interface{}{"f1": "value"}
+	m, err := NewMessage(codec, "orders", nil, value, WithHeaders(kafka.Header{Key: "event-type", Value: []byte("OrderCreated")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native, discriminator, err := DispatchValue(dispatcher, m); err != nil || discriminator != "OrderCreated" || !reflect.DeepEqual(native, value) {
+		t.Errorf("DispatchValue() returned %v, %q, %v", native, discriminator, err)
+	}
+
+	m.Headers = nil
+	if _, _, err = DispatchValue(dispatcher, m); !errors.Is(err, kafkaavro.ErrUnknownDiscriminator) {
+		t.Errorf("DispatchValue() without the header returned %v", err)
+	}
+	if _, _, err = DispatchValue(dispatcher, &kafka.Message{Value: m.Value}); err == nil {
+		t.Errorf("DispatchValue() of a message without topic did not fail")
+	}
+}
+
+func TestMetadataHeaders(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", testSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	value := map[string]interface{}{"f1": "value"}
+
+	// the headers of the message are kept
+	m, err := NewMessage(codec, "orders", nil, value, WithHeaders(kafka.Header{Key: "content-type", Value: []byte("avro/binary")}), WithMetadataHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []kafka.Header{
+		{Key: "content-type", Value: []byte("avro/binary")},
+		{Key: "x-schema-id", Value: []byte(strconv.Itoa(id))},
+		{Key: "x-schema-subject", Value: []byte("orders-value")},
+	}
+	if !reflect.DeepEqual(m.Headers, want) {
+		t.Errorf("NewMessage() returned the headers %v, want %v", m.Headers, want)
+	}
+	if _, err = DecodeMessage(codec, m); err != nil {
+		t.Errorf("DecodeMessage() returned %v", err)
+	}
+
+	// the schema id header which is not the schema id of the framing follows the precedence
+	m.Headers[1].Value = []byte("7")
+	if message, err := DecodeMessage(codec, m); err != nil || !reflect.DeepEqual(message.Value, value) {
+		t.Errorf("DecodeMessage() with another schema id header returned %v, %v", message.Value, err)
+	}
+	strict := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaIDPrecedence(kafkaavro.ErrorOnMismatch))
+	if _, err = DecodeMessage(strict, m); !errors.Is(err, kafkaavro.ErrSchemaIDMismatch) {
+		t.Errorf("DecodeMessage() with another schema id header returned %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is a decimal logical type: a bytes or fixed holding the unscaled value as a big endian
+// two's complement integer, the value is the unscaled value divided by 10^Scale. goavro decodes
+// decimals as *big.Rat, Decimal checks the values against the precision and scale of the schema
+// and converts between the values and their bytes.
+type Decimal struct {
+	Precision int
+	Scale     int
+	// Size is the size of a fixed decimal, 0 for a bytes decimal.
+	Size int
+}
+
+// ParseDecimal returns the decimal of a decimal type, e.g.
+// {"type":"bytes","logicalType":"decimal","precision":9,"scale":2}.
+func ParseDecimal(schema AvroSchema) (decimal Decimal, err error) {
+
+	var node interface{}
+	if err = json.Unmarshal([]byte(schema), &node); err != nil {
+		return decimal, fmt.Errorf("%w: %w", ErrInvalidDecimal, err)
+	}
+	return decimalOf(node)
+}
+
+// DecimalField returns the decimal of a field of a record schema. The path holds the names of
+// the nested record fields separated by dots, e.g. "order.total", unions with null are looked
+// through.
+func DecimalField(schema AvroSchema, path string) (decimal Decimal, err error) {
+
+	var node interface{}
+	if err = json.Unmarshal([]byte(schema), &node); err != nil {
+		return decimal, fmt.Errorf("%w: %w", ErrInvalidDecimal, err)
+	}
+
+	named := make(map[string]interface{})
+	for _, name := range strings.Split(path, ".") {
+		record, found := resolveNamed(nonNull(node), named).(map[string]interface{})
+		if !found || record["type"] != "record" {
+			return decimal, fmt.Errorf("%w: %v is not a field of a record", ErrInvalidDecimal, path)
+		}
+		collectNamed(record, named)
+		fields, _ := record["fields"].([]interface{})
+		node = nil
+		for _, f := range fields {
+			attributes, _ := f.(map[string]interface{})
+			collectNamed(attributes["type"], named)
+			if attributes["name"] == name {
+				node = attributes["type"]
+			}
+		}
+		if node == nil {
+			return decimal, fmt.Errorf("%w: field %v of %v not found", ErrInvalidDecimal, name, path)
+		}
+	}
+	return decimalOf(resolveNamed(nonNull(node), named))
+}
+
+// nonNull returns the other branch of a union with null.
+func nonNull(node interface{}) interface{} {
+	if union, ok := node.([]interface{}); ok && len(union) == 2 {
+		if union[0] == "null" {
+			return union[1]
+		}
+		if union[1] == "null" {
+			return union[0]
+		}
+	}
+	return node
+}
+
+// collectNamed records the named type definition, so that later references resolve.
+func collectNamed(node interface{}, named map[string]interface{}) {
+	for _, n := range append([]interface{}{node}, unionBranches(node)...) {
+		if definition, ok := n.(map[string]interface{}); ok {
+			if name, ok := definition["name"].(string); ok {
+				named[name] = definition
+			}
+		}
+	}
+}
+
+func unionBranches(node interface{}) []interface{} {
+	branches, _ := node.([]interface{})
+	return branches
+}
+
+func resolveNamed(node interface{}, named map[string]interface{}) interface{} {
+	if name, ok := node.(string); ok {
+		if definition, found := named[name]; found {
+			return definition
+		}
+	}
+	return node
+}
+
+func decimalOf(node interface{}) (decimal Decimal, err error) {
+
+	attributes, _ := node.(map[string]interface{})
+	if attributes["logicalType"] != "decimal" || (attributes["type"] != "bytes" && attributes["type"] != "fixed") {
+		return decimal, fmt.Errorf("%w: %v is not a bytes or fixed with logical type decimal", ErrInvalidDecimal, node)
+	}
+
+	precision, _ := attributes["precision"].(float64)
+	scale, _ := attributes["scale"].(float64)
+	size, _ := attributes["size"].(float64)
+	decimal = Decimal{Precision: int(precision), Scale: int(scale)}
+	if attributes["type"] == "fixed" {
+		decimal.Size = int(size)
+	}
+
+	if decimal.Precision <= 0 || decimal.Scale < 0 || decimal.Scale > decimal.Precision {
+		return decimal, fmt.Errorf("%w: precision %d and scale %d, want 0 <= scale <= precision", ErrInvalidDecimal, decimal.Precision, decimal.Scale)
+	}
+	return
+}
+
+// Validate returns an error if the value has more decimals than the scale or more digits than
+// the precision, or does not fit in the size of a fixed.
+func (d Decimal) Validate(value *big.Rat) error {
+	_, err := d.Bytes(value)
+	return err
+}
+
+// Bytes returns the unscaled value as the shortest big endian two's complement integer, as the
+// Java BigDecimal does, or sign extended to the Size of a fixed.
+func (d Decimal) Bytes(value *big.Rat) (data []byte, err error) {
+
+	unscaled, err := d.unscaled(value)
+	if err != nil {
+		return
+	}
+
+	if unscaled.Sign() >= 0 {
+		data = unscaled.Bytes()
+		if len(data) == 0 || data[0]&0x80 != 0 {
+			data = append([]byte{0}, data...)
+		}
+	} else {
+		// -n-1 has all bits of n inverted, a byte more leaves room for the sign bit
+		size := new(big.Int).Not(unscaled).BitLen()/8 + 1
+		twos := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(8*size)), unscaled)
+		data = twos.FillBytes(make([]byte, size))
+	}
+
+	if d.Size > 0 {
+		if len(data) > d.Size {
+			return nil, fmt.Errorf("%w: %v does not fit in a fixed of %d bytes", ErrInvalidDecimal, value.FloatString(d.Scale), d.Size)
+		}
+		padded := make([]byte, d.Size)
+		if unscaled.Sign() < 0 {
+			for i := range padded {
+				padded[i] = 0xff
+			}
+		}
+		copy(padded[d.Size-len(data):], data)
+		data = padded
+	}
+	return
+}
+
+// Rat returns the value of the bytes of a decimal, checked against the precision of the decimal.
+func (d Decimal) Rat(data []byte) (value *big.Rat, err error) {
+
+	if d.Size > 0 && len(data) != d.Size {
+		return nil, fmt.Errorf("%w: %d bytes for a fixed of %d bytes", ErrInvalidDecimal, len(data), d.Size)
+	}
+
+	unscaled := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+
+	value = new(big.Rat).SetFrac(unscaled, pow10(d.Scale))
+	if digits := decimalDigits(unscaled); digits > d.Precision {
+		return nil, fmt.Errorf("%w: %v has %d digits, more than the precision %d", ErrInvalidDecimal, value.FloatString(d.Scale), digits, d.Precision)
+	}
+	return
+}
+
+// unscaled returns the value times 10^scale, which must be an integer of at most precision digits.
+func (d Decimal) unscaled(value *big.Rat) (unscaled *big.Int, err error) {
+
+	if value == nil {
+		return nil, fmt.Errorf("%w: nil value", ErrInvalidDecimal)
+	}
+
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(pow10(d.Scale)))
+	if !scaled.IsInt() {
+		return nil, fmt.Errorf("%w: %v has more than %d decimals", ErrInvalidDecimal, value.RatString(), d.Scale)
+	}
+
+	unscaled = scaled.Num()
+	if digits := decimalDigits(unscaled); digits > d.Precision {
+		return nil, fmt.Errorf("%w: %v has %d digits, more than the precision %d", ErrInvalidDecimal, value.FloatString(d.Scale), digits, d.Precision)
+	}
+	return
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// decimalDigits returns the number of digits of the integer, 0 has 1 digit.
+func decimalDigits(n *big.Int) int {
+	return len(new(big.Int).Abs(n).String())
+}
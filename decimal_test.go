@@ -0,0 +1,170 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+func rat(t *testing.T, value string) *big.Rat {
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		t.Fatalf("invalid rat %v", value)
+	}
+	return r
+}
+
+func TestDecimalBytes(t *testing.T) {
+
+	maxPrecision := strings.Repeat("9", 36) + ".99"
+
+	var tests = []struct {
+		decimal Decimal
+		value   string
+		want    string
+	}{
+		{Decimal{Precision: 9, Scale: 2}, "0", "00"},
+		{Decimal{Precision: 9, Scale: 2}, "0.01", "01"},
+		{Decimal{Precision: 9, Scale: 2}, "-0.01", "ff"},
+		{Decimal{Precision: 9, Scale: 2}, "1.27", "7f"},
+		{Decimal{Precision: 9, Scale: 2}, "1.28", "0080"},
+		{Decimal{Precision: 9, Scale: 2}, "-1.28", "80"},
+		{Decimal{Precision: 9, Scale: 2}, "-1.29", "ff7f"},
+		{Decimal{Precision: 9, Scale: 2}, "123.45", "3039"},
+		{Decimal{Precision: 9, Scale: 2}, "-123.45", "cfc7"},
+		{Decimal{Precision: 9, Scale: 2}, "1234567.8", "075bcd0c"},
+		{Decimal{Precision: 9, Scale: 0}, "-999999999", "c4653601"},
+		{Decimal{Precision: 38, Scale: 2}, maxPrecision, "4b3b4ca85a86c47a098a223fffffffff"},
+		{Decimal{Precision: 38, Scale: 2}, "-" + maxPrecision, "b4c4b357a5793b85f675ddc000000001"},
+		{Decimal{Precision: 9, Scale: 2, Size: 4}, "0", "00000000"},
+		{Decimal{Precision: 9, Scale: 2, Size: 4}, "-1", "ffffff9c"},
+		{Decimal{Precision: 9, Scale: 2, Size: 4}, "1.28", "00000080"},
+	}
+
+	for _, test := range tests {
+
+		data, err := test.decimal.Bytes(rat(t, test.value))
+		if err != nil {
+			t.Errorf("%+v.Bytes(%v) failed: %v", test.decimal, test.value, err)
+			continue
+		}
+		if got := hex.EncodeToString(data); got != test.want {
+			t.Errorf("%+v.Bytes(%v) returned %v, want %v", test.decimal, test.value, got, test.want)
+		}
+
+		value, err := test.decimal.Rat(data)
+		if err != nil || value.Cmp(rat(t, test.value)) != 0 {
+			t.Errorf("%+v.Rat(%v) returned %v, %v, want %v", test.decimal, test.want, value, err, test.value)
+		}
+	}
+}
+
+func TestDecimalErrors(t *testing.T) {
+
+	decimal := Decimal{Precision: 5, Scale: 2}
+
+	for _, value := range []string{"1.001", "1/3", "1000", "-1000.5"} {
+		if _, err := decimal.Bytes(rat(t, value)); !errors.Is(err, ErrInvalidDecimal) {
+			t.Errorf("Bytes(%v) returned %v, want ErrInvalidDecimal", value, err)
+		}
+	}
+	if err := decimal.Validate(rat(t, "999.99")); err != nil {
+		t.Errorf("Validate(999.99) failed: %v", err)
+	}
+	if _, err := (Decimal{Precision: 5, Scale: 0, Size: 2}).Bytes(rat(t, "40000")); !errors.Is(err, ErrInvalidDecimal) {
+		t.Errorf("Bytes(40000) of a fixed of 2 bytes returned %v, want ErrInvalidDecimal", err)
+	}
+	if _, err := decimal.Rat([]byte{0x01, 0x86, 0xa0}); !errors.Is(err, ErrInvalidDecimal) {
+		t.Errorf("Rat(100000) returned %v, want ErrInvalidDecimal for the precision", err)
+	}
+	if _, err := (Decimal{Precision: 5, Scale: 2, Size: 4}).Rat([]byte{1}); !errors.Is(err, ErrInvalidDecimal) {
+		t.Errorf("Rat() of a short fixed returned %v, want ErrInvalidDecimal", err)
+	}
+}
+
+// TestDecimalMatchesGoavro checks the bytes against those goavro writes and reads.
+func TestDecimalMatchesGoavro(t *testing.T) {
+
+	for _, schema := range []string{
+		`{"type":"bytes","logicalType":"decimal","precision":20,"scale":4}`,
+		`{"type":"fixed","name":"amount","size":9,"logicalType":"decimal","precision":20,"scale":4}`,
+	} {
+		decimal, err := ParseDecimal(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codec, err := goavro.NewCodec(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, value := range []string{"0", "1", "-1", "0.0128", "-0.0128", "3.1415", "-9999999999999999.9999", "9999999999999999.9999"} {
+
+			data, err := decimal.Bytes(rat(t, value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decimal.Size == 0 {
+				encoded, err := codec.BinaryFromNative(nil, rat(t, value))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the length, all values are shorter than 64 bytes
+				if !bytes.Equal(data, encoded[1:]) {
+					t.Errorf("%v: Bytes(%v) returned %x, goavro wrote %x", schema, value, data, encoded[1:])
+				}
+				continue
+			}
+
+			// goavro does not pad the fixed it writes, it reads the padded fixed
+			native, _, err := codec.NativeFromBinary(data)
+			if err != nil {
+				t.Fatalf("%v: goavro failed to read %x: %v", schema, data, err)
+			}
+			if got, ok := native.(*big.Rat); !ok || got.Cmp(rat(t, value)) != 0 {
+				t.Errorf("%v: goavro read %x as %v, want %v", schema, data, native, value)
+			}
+		}
+	}
+}
+
+func TestDecimalField(t *testing.T) {
+
+	schema := `{"type":"record","name":"order","fields":[
+		{"name":"id","type":"long"},
+		{"name":"total","type":{"type":"bytes","logicalType":"decimal","precision":12,"scale":2}},
+		{"name":"payment","type":["null",{"type":"record","name":"payment","fields":[
+			{"name":"amount","type":{"type":"fixed","name":"amount","size":8,"logicalType":"decimal","precision":18,"scale":4}},
+			{"name":"fee","type":"amount"}]}]}]}`
+
+	var tests = []struct {
+		path string
+		want Decimal
+		err  bool
+	}{
+		{"total", Decimal{Precision: 12, Scale: 2}, false},
+		{"payment.amount", Decimal{Precision: 18, Scale: 4, Size: 8}, false},
+		{"payment.fee", Decimal{Precision: 18, Scale: 4, Size: 8}, false},
+		{"id", Decimal{}, true},
+		{"missing", Decimal{}, true},
+		{"total.cents", Decimal{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := DecimalField(schema, test.path)
+		if test.err {
+			if !errors.Is(err, ErrInvalidDecimal) {
+				t.Errorf("DecimalField(%v) returned %v, want ErrInvalidDecimal", test.path, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("DecimalField(%v) returned %+v, %v, want %+v", test.path, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package kafkaavro
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestDependencies verifies that the package builds without cgo and does not depend on the kafka
+// client, Prometheus, OpenTelemetry, protobuf or a JSON
+// schema validator.
+func TestDependencies(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	build := exec.Command(goCommand, "build", ".")
+	build.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("CGO_ENABLED=0 go build failed: %v\n%s", err, out)
+	}
+
+	list := exec.Command(goCommand, "list", "-deps", ".")
+	list.Env = append(os.Environ(), "CGO_ENABLED=0")
+	out, err := list.Output()
+	if err != nil {
+		t.Fatalf("go list -deps failed: %v", err)
+	}
+
+	for _, dependency := range strings.Fields(string(out)) {
+		if strings.HasPrefix(dependency, "github.com/confluentinc/") || strings.HasPrefix(dependency, "github.com/prometheus/") ||
+			strings.HasPrefix(dependency, "go.opentelemetry.io/") || strings.HasPrefix(dependency, "google.golang.org/protobuf/") ||
+			strings.HasPrefix(dependency, "github.com/bufbuild/") || strings.HasPrefix(dependency, "github.com/santhosh-tekuri/") {
+			t.Errorf("the package depends on %v", dependency)
+		}
+	}
+}
+
+// TestCrossCompile verifies that the decode path, the package with its tests and the registry and
+// REST Proxy clients, builds without cgo for js/wasm and linux/arm64, e.g. for a browser tool
+// which decodes the messages of the REST Proxy.
+func TestCrossCompile(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	for _, target := range []struct{ goos, goarch string }{{"js", "wasm"}, {"linux", "arm64"}} {
+		vet := exec.Command(goCommand, "vet", ".", "./schemaregistry", "./mockregistry", "./restproxy")
+		vet.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+target.goos, "GOARCH="+target.goarch)
+		if out, err := vet.CombinedOutput(); err != nil {
+			t.Errorf("CGO_ENABLED=0 GOOS=%v GOARCH=%v go vet failed: %v\n%s", target.goos, target.goarch, err, out)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// Compatibility is the compatibility of two versions of a schema, named like the compatibility
+// levels of the schema registry.
+type Compatibility string
+
+// The compatibility verdicts of DiffSchemas.
+const (
+	// CompatibilityNone: the schemas do not read the data of each other.
+	CompatibilityNone Compatibility = "NONE"
+	// CompatibilityBackward: the new schema reads the data of the old schema.
+	CompatibilityBackward Compatibility = "BACKWARD"
+	// CompatibilityForward: the old schema reads the data of the new schema.
+	CompatibilityForward Compatibility = "FORWARD"
+	// CompatibilityFull: backward and forward.
+	CompatibilityFull Compatibility = "FULL"
+)
+
+// ChangeKind is the kind of a SchemaChange.
+type ChangeKind string
+
+// The kinds of the changes of DiffSchemas.
+const (
+	FieldAdded   ChangeKind = "added"
+	FieldRemoved ChangeKind = "removed"
+	// FieldRenamed is a field of the new schema with an alias of a field of the old schema.
+	FieldRenamed ChangeKind = "renamed"
+	// TypeWidened is a type of which the new type reads the values of the old type but not the
+	// other way around, e.g. int to long or a branch added to a union.
+	TypeWidened ChangeKind = "widened"
+	// TypeNarrowed is a type of which the old type reads the values of the new type but not the
+	// other way around, e.g. long to int.
+	TypeNarrowed ChangeKind = "narrowed"
+	// TypeChanged is another change of a type, e.g. string to an enum.
+	TypeChanged ChangeKind = "changed"
+	// SymbolsChanged is an enum of which symbols are added or removed.
+	SymbolsChanged ChangeKind = "symbols changed"
+)
+
+// SchemaChange is a change of a field of a schema, see DiffSchemas.
+type SchemaChange struct {
+	Kind ChangeKind
+	// Path is the path of the field, e.g. customer.email, the fields of the records of arrays,
+	// maps and unions have the path of the field of the array, map or union.
+	Path string
+	// OldPath is the path of the field in the old schema of a FieldRenamed.
+	OldPath string
+	// OldType and NewType are the types of the field, e.g. [null, string], or the symbols of
+	// the enum of a SymbolsChanged.
+	OldType string
+	NewType string
+	// HasDefault is true for a field which is added or removed with a default.
+	HasDefault bool
+}
+
+// String returns the change as a line: + for an added field, - for a removed field, > for a
+// renamed field and ~ for a changed type.
+func (c SchemaChange) String() string {
+
+	defaults := ", without a default"
+	if c.HasDefault {
+		defaults = ", with a default"
+	}
+	switch c.Kind {
+	case FieldAdded:
+		return fmt.Sprintf("+ %v: %v%v", c.Path, c.NewType, defaults)
+	case FieldRemoved:
+		return fmt.Sprintf("- %v: %v%v", c.Path, c.OldType, defaults)
+	case FieldRenamed:
+		return fmt.Sprintf("> %v -> %v", c.OldPath, c.Path)
+	case SymbolsChanged:
+		return fmt.Sprintf("~ %v: symbols %v -> %v", c.Path, c.OldType, c.NewType)
+	}
+	return fmt.Sprintf("~ %v: %v -> %v (%v)", c.Path, c.OldType, c.NewType, c.Kind)
+}
+
+// SchemaDiff are the changes between two versions of a schema.
+type SchemaDiff struct {
+	// Changes are the changes of the fields, in the order of the fields of the new schema and
+	// then of the removed fields.
+	Changes []SchemaChange
+	// Compatibility is the compatibility of the new schema with the old schema, by the rules
+	// with which the schema registry checks the compatibility of a version with the previous one.
+	Compatibility Compatibility
+}
+
+// DiffSchemas compares the old and the new version of a schema: the fields which are added,
+// removed or renamed (the new field has the old name as alias), the types which change and the
+// compatibility of the versions. The schemas are compared like the schema registry does:
+//
+//   - the new schema is backward compatible if it reads the data of the old one, a field which
+//     is added has a default and a type changes to a type which reads its values, e.g. int to
+//     long, a symbol is added to an enum or a branch to a union
+//   - it is forward compatible if the old schema reads its data, a field which is removed has a
+//     default and a symbol is removed from an enum with a default
+//   - records, enums and fixed match by name or alias, logical types are ignored
+func DiffSchemas(oldSchema, newSchema AvroSchema) (diff SchemaDiff, err error) {
+
+	var from, to *avroschema.Node
+	for _, s := range []struct {
+		schema AvroSchema
+		node   **avroschema.Node
+	}{{oldSchema, &from}, {newSchema, &to}} {
+		if _, err = goavro.NewCodec(s.schema); err != nil {
+			return diff, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+		}
+		if *s.node, err = avroschema.Parse(s.schema); err != nil {
+			return diff, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+		}
+	}
+
+	d := &differ{visited: make(map[[2]*avroschema.Node]bool)}
+	d.diffType("", from, to)
+	diff.Changes = d.changes
+
+	backward := compatible(from, to, make(map[[2]*avroschema.Node]bool))
+	forward := compatible(to, from, make(map[[2]*avroschema.Node]bool))
+	switch {
+	case backward && forward:
+		diff.Compatibility = CompatibilityFull
+	case backward:
+		diff.Compatibility = CompatibilityBackward
+	case forward:
+		diff.Compatibility = CompatibilityForward
+	default:
+		diff.Compatibility = CompatibilityNone
+	}
+	return
+}
+
+type differ struct {
+	changes []SchemaChange
+	// the pairs of records which are compared, the records of recursive schemas are compared once
+	visited map[[2]*avroschema.Node]bool
+}
+
+// diffType reports the change of the old type to the new type, and compares the fields of the
+// records which the types have in common.
+func (d *differ) diffType(path string, from, to *avroschema.Node) {
+
+	if path != "" && !sameType(from, to) {
+		kind := TypeChanged
+		// the changes of the fields of the records are changes of their own
+		switch backward, forward := compatible(from, to, nil), compatible(to, from, nil); {
+		case backward && !forward:
+			kind = TypeWidened
+		case forward && !backward:
+			kind = TypeNarrowed
+		}
+		d.changes = append(d.changes, SchemaChange{Kind: kind, Path: path, OldType: describeType(from), NewType: describeType(to)})
+	}
+
+	for _, o := range namedTypes(from) {
+		for _, n := range namedTypes(to) {
+			if o.Type == n.Type && sameName(o, n) {
+				switch o.Type {
+				case "record":
+					d.diffRecord(path, o, n)
+				case "enum":
+					if added, removed := symbolChanges(o, n); added || removed {
+						d.changes = append(d.changes, SchemaChange{Kind: SymbolsChanged, Path: path,
+							OldType: "[" + strings.Join(o.Symbols, ", ") + "]", NewType: "[" + strings.Join(n.Symbols, ", ") + "]"})
+					}
+				}
+			}
+		}
+	}
+}
+
+func (d *differ) diffRecord(path string, from, to *avroschema.Node) {
+
+	if d.visited[[2]*avroschema.Node{from, to}] {
+		return
+	}
+	d.visited[[2]*avroschema.Node{from, to}] = true
+
+	matched := make(map[string]bool, len(from.Fields))
+	for _, f := range to.Fields {
+		o, found := writerField(from, f)
+		if !found {
+			d.changes = append(d.changes, SchemaChange{Kind: FieldAdded, Path: fieldPath(path, f.Name), NewType: describeType(f.Node), HasDefault: f.HasDefault})
+			continue
+		}
+		matched[o.Name] = true
+		if o.Name != f.Name {
+			d.changes = append(d.changes, SchemaChange{Kind: FieldRenamed, Path: fieldPath(path, f.Name), OldPath: fieldPath(path, o.Name)})
+		}
+		d.diffType(fieldPath(path, f.Name), o.Node, f.Node)
+	}
+	for _, o := range from.Fields {
+		if !matched[o.Name] {
+			d.changes = append(d.changes, SchemaChange{Kind: FieldRemoved, Path: fieldPath(path, o.Name), OldType: describeType(o.Node), HasDefault: o.HasDefault})
+		}
+	}
+}
+
+// namedTypes returns the records and enums of a type and of the items, values and branches of it.
+func namedTypes(n *avroschema.Node) (named []*avroschema.Node) {
+	switch n.Type {
+	case "record", "enum":
+		return []*avroschema.Node{n}
+	case "array":
+		return namedTypes(n.Items)
+	case "map":
+		return namedTypes(n.Values)
+	case "union":
+		for _, b := range n.Branches {
+			named = append(named, namedTypes(b)...)
+		}
+	}
+	return
+}
+
+func symbolChanges(from, to *avroschema.Node) (added bool, removed bool) {
+	for _, s := range to.Symbols {
+		added = added || !hasSymbol(from, s)
+	}
+	for _, s := range from.Symbols {
+		removed = removed || !hasSymbol(to, s)
+	}
+	return
+}
+
+// sameType returns true if the types are the same types, the named types matching by name or
+// alias, not comparing the fields of records and the symbols of enums.
+func sameType(from, to *avroschema.Node) bool {
+
+	if from.Type != to.Type || from.Logical != to.Logical {
+		return false
+	}
+	switch from.Type {
+	case "record", "enum", "fixed":
+		return from.Size == to.Size && (sameName(from, to) || sameName(to, from))
+	case "array":
+		return sameType(from.Items, to.Items)
+	case "map":
+		return sameType(from.Values, to.Values)
+	case "union":
+		if len(from.Branches) != len(to.Branches) {
+			return false
+		}
+		for i := range from.Branches {
+			if !sameType(from.Branches[i], to.Branches[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// describeType returns the type as, e.g., [null, com.example.Customer] or array<long.timestamp-millis>.
+func describeType(n *avroschema.Node) string {
+	switch n.Type {
+	case "record", "enum", "fixed":
+		return n.Name
+	case "array":
+		return "array<" + describeType(n.Items) + ">"
+	case "map":
+		return "map<" + describeType(n.Values) + ">"
+	case "union":
+		names := make([]string, len(n.Branches))
+		for i, b := range n.Branches {
+			names[i] = describeType(b)
+		}
+		return "[" + strings.Join(names, ", ") + "]"
+	}
+	if n.Logical != "" {
+		return n.Type + "." + n.Logical
+	}
+	return n.Type
+}
+
+// compatible returns true if the reader type r reads the data of the writer type w, by the rules
+// of the avro specification which the schema registry checks (SchemaCompatibility of avro). Without
+// visited the records are compatible by name, not comparing their fields.
+func compatible(w, r *avroschema.Node, visited map[[2]*avroschema.Node]bool) bool {
+
+	if w.Type == "union" {
+		for _, b := range w.Branches {
+			if !compatible(b, r, visited) {
+				return false
+			}
+		}
+		return true
+	}
+	if r.Type == "union" {
+		for _, b := range r.Branches {
+			if compatible(w, b, visited) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch w.Type + ">" + r.Type {
+	case "int>long", "int>float", "int>double", "long>float", "long>double", "float>double", "string>bytes", "bytes>string":
+		return true
+	}
+	if w.Type != r.Type {
+		return false
+	}
+
+	switch r.Type {
+	case "fixed":
+		return w.Size == r.Size && sameName(w, r)
+	case "enum":
+		if !sameName(w, r) {
+			return false
+		}
+		if r.DefaultSymbol != "" && hasSymbol(r, r.DefaultSymbol) {
+			return true
+		}
+		for _, s := range w.Symbols {
+			if !hasSymbol(r, s) {
+				return false
+			}
+		}
+		return true
+	case "array":
+		return compatible(w.Items, r.Items, visited)
+	case "map":
+		return compatible(w.Values, r.Values, visited)
+	case "record":
+		if !sameName(w, r) {
+			return false
+		}
+		// a recursive record is compatible if its fields are
+		if visited == nil || visited[[2]*avroschema.Node{w, r}] {
+			return true
+		}
+		visited[[2]*avroschema.Node{w, r}] = true
+		for _, f := range r.Fields {
+			wf, found := writerField(w, f)
+			if !found {
+				if !f.HasDefault {
+					return false
+				}
+				continue
+			}
+			if !compatible(wf.Node, f.Node, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
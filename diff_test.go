@@ -0,0 +1,146 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// compatibilityCases are pairs of versions of a schema and their compatibility, the integration
+// tests check the same verdicts against the schema registry.
+var compatibilityCases = []struct {
+	name     string
+	old, new string
+	want     Compatibility
+}{
+	{"identical",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		CompatibilityFull},
+	{"field added with a default",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"],"default":null}]}`,
+		CompatibilityFull},
+	{"field added without a default",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string"}]}`,
+		CompatibilityForward},
+	{"field removed without a default",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		CompatibilityBackward},
+	{"int widened to long",
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"int"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"long"}]}`,
+		CompatibilityBackward},
+	{"long narrowed to int",
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"long"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"int"}]}`,
+		CompatibilityForward},
+	{"string to int",
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"string"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"int"}]}`,
+		CompatibilityNone},
+	{"field renamed with an alias",
+		`{"type":"record","name":"Order","fields":[{"name":"customer_name","type":"string"}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"customer","type":"string","aliases":["customer_name"]}]}`,
+		CompatibilityBackward},
+	{"symbol added to an enum",
+		`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN"]}}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]}}]}`,
+		CompatibilityBackward},
+	{"symbol added to an enum with a default",
+		`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN"],"default":"OPEN"}}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"],"default":"OPEN"}}]}`,
+		CompatibilityFull},
+	{"branch added to a union",
+		`{"type":"record","name":"Order","fields":[{"name":"note","type":["null","string"],"default":null}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"note","type":["null","string","long"],"default":null}]}`,
+		CompatibilityBackward},
+	{"nested field added without a default",
+		`{"type":"record","name":"Order","fields":[{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[{"name":"product","type":"string"}]}}}]}`,
+		`{"type":"record","name":"Order","fields":[{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[{"name":"product","type":"string"},{"name":"quantity","type":"int"}]}}}]}`,
+		CompatibilityForward},
+	{"record renamed",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		`{"type":"record","name":"Purchase","fields":[{"name":"id","type":"long"}]}`,
+		CompatibilityNone},
+	{"record renamed with an alias",
+		`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+		`{"type":"record","name":"Purchase","aliases":["Order"],"fields":[{"name":"id","type":"long"}]}`,
+		CompatibilityBackward},
+	{"recursive record",
+		`{"type":"record","name":"Node","fields":[{"name":"children","type":{"type":"array","items":"Node"}}]}`,
+		`{"type":"record","name":"Node","fields":[{"name":"children","type":{"type":"array","items":"Node"}},{"name":"value","type":"int","default":0}]}`,
+		CompatibilityFull},
+}
+
+func TestDiffSchemasCompatibility(t *testing.T) {
+
+	for _, test := range compatibilityCases {
+		diff, err := DiffSchemas(test.old, test.new)
+		if err != nil || diff.Compatibility != test.want {
+			t.Errorf("%v: DiffSchemas() returned %v, %v, want %v", test.name, diff.Compatibility, err, test.want)
+		}
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+
+	old := `{"type":"record","name":"Order","namespace":"com.example","fields":[
+		{"name":"id","type":"int"},
+		{"name":"customer_name","type":"string"},
+		{"name":"quantity","type":"long"},
+		{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]}},
+		{"name":"note","type":"string","default":""},
+		{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[{"name":"product","type":"string"}]}}}]}`
+	new := `{"type":"record","name":"Order","namespace":"com.example","fields":[
+		{"name":"id","type":"long"},
+		{"name":"customer","type":"string","aliases":["customer_name"]},
+		{"name":"quantity","type":"int"},
+		{"name":"status","type":["null",{"type":"enum","name":"Status","symbols":["OPEN","CLOSED","LOST"]}]},
+		{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[
+			{"name":"product","type":"string"},{"name":"discount","type":["null","double"],"default":null}]}}},
+		{"name":"placed","type":{"type":"long","logicalType":"timestamp-millis"}}]}`
+
+	diff, err := DiffSchemas(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SchemaChange{
+		{Kind: TypeWidened, Path: "id", OldType: "int", NewType: "long"},
+		{Kind: FieldRenamed, Path: "customer", OldPath: "customer_name"},
+		{Kind: TypeNarrowed, Path: "quantity", OldType: "long", NewType: "int"},
+		{Kind: TypeWidened, Path: "status", OldType: "com.example.Status", NewType: "[null, com.example.Status]"},
+		{Kind: SymbolsChanged, Path: "status", OldType: "[OPEN, CLOSED]", NewType: "[OPEN, CLOSED, LOST]"},
+		{Kind: FieldAdded, Path: "lines.discount", NewType: "[null, double]", HasDefault: true},
+		{Kind: FieldAdded, Path: "placed", NewType: "long.timestamp-millis"},
+		{Kind: FieldRemoved, Path: "note", OldType: "string", HasDefault: true},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("DiffSchemas() returned\n%v\nwant\n%v", diff.Changes, want)
+	}
+	if diff.Compatibility != CompatibilityNone {
+		t.Errorf("DiffSchemas() returned the compatibility %v", diff.Compatibility)
+	}
+
+	lines := []string{
+		"~ id: int -> long (widened)",
+		"> customer_name -> customer",
+		"~ quantity: long -> int (narrowed)",
+		"~ status: com.example.Status -> [null, com.example.Status] (widened)",
+		"~ status: symbols [OPEN, CLOSED] -> [OPEN, CLOSED, LOST]",
+		"+ lines.discount: [null, double], with a default",
+		"+ placed: long.timestamp-millis, without a default",
+		"- note: string, with a default",
+	}
+	for i, change := range diff.Changes {
+		if change.String() != lines[i] {
+			t.Errorf("%d: String() returned %q, want %q", i, change.String(), lines[i])
+		}
+	}
+
+	if _, err = DiffSchemas(old, `{"type":"record"}`); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("DiffSchemas() of an invalid schema returned %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DeserializerFunc is a Deserializer of a function, e.g. one decoding to the Go type of the
+// messages with an event type, see HeaderDispatcher.
+type DeserializerFunc func(topic string, isKey bool, data []byte) (interface{}, error)
+
+// Deserialize calls f.
+func (f DeserializerFunc) Deserialize(topic string, isKey bool, data []byte) (interface{}, error) {
+	return f(topic, isKey, data)
+}
+
+// HeaderDispatcher decodes the messages of a topic of several types, e.g. events of which an
+// event-type header tells the type, with the Deserializer registered for the value of the header:
+//
+//	dispatcher := kafkaavro.NewHeaderDispatcher("event-type").
+//		Register("OrderCreated", orderCreated).
+//		Register("OrderCancelled", orderCancelled)
+//
+// Register the deserializers before decoding, the decodes are safe for concurrent use.
+type HeaderDispatcher struct {
+	header        string
+	deserializers map[string]Deserializer
+}
+
+// NewHeaderDispatcher creates a HeaderDispatcher of the header.
+func NewHeaderDispatcher(headerName string) *HeaderDispatcher {
+	return &HeaderDispatcher{header: headerName, deserializers: make(map[string]Deserializer)}
+}
+
+// Register registers the deserializer of the messages of which the header is the discriminator,
+// replacing the one registered before, and returns the dispatcher.
+func (d *HeaderDispatcher) Register(discriminator string, deserializer Deserializer) *HeaderDispatcher {
+	d.deserializers[discriminator] = deserializer
+	return d
+}
+
+// Decode decodes the data with the deserializer of the value of the first header of the headers
+// named like the header of the dispatcher, and returns the value with the discriminator. Data
+// without the header, or of which no deserializer is registered for the discriminator, fails with
+// an *UnknownDiscriminatorError. The errors of the deserializer are returned as is.
+func (d *HeaderDispatcher) Decode(topic string, isKey bool, data []byte, headers []Header) (value interface{}, discriminator string, err error) {
+
+	header, found := headerValue(headers, d.header)
+	discriminator = string(header)
+	deserializer, registered := d.deserializers[discriminator]
+	if !found || !registered {
+		return nil, discriminator, &UnknownDiscriminatorError{Header: d.header, Discriminator: discriminator, Missing: !found, Registered: d.Discriminators()}
+	}
+	native, err := deserializer.Deserialize(topic, isKey, data)
+	return native, discriminator, err
+}
+
+// Discriminators returns the registered discriminators in order.
+func (d *HeaderDispatcher) Discriminators() []string {
+	discriminators := make([]string, 0, len(d.deserializers))
+	for discriminator := range d.deserializers {
+		discriminators = append(discriminators, discriminator)
+	}
+	sort.Strings(discriminators)
+	return discriminators
+}
+
+// UnknownDiscriminatorError is the error of HeaderDispatcher for a message without the header or
+// of which no deserializer is registered for the value of the header. It wraps
+// ErrUnknownDiscriminator.
+type UnknownDiscriminatorError struct {
+	Header        string
+	Discriminator string
+	// Missing is true if the message has no header.
+	Missing    bool
+	Registered []string
+}
+
+func (e *UnknownDiscriminatorError) Error() string {
+	registered := "none are registered"
+	if len(e.Registered) > 0 {
+		registered = "registered are " + strings.Join(e.Registered, ", ")
+	}
+	if e.Missing {
+		return fmt.Sprintf("%v: the message has no %v header, %v", ErrUnknownDiscriminator, e.Header, registered)
+	}
+	return fmt.Sprintf("%v %q of the %v header, %v", ErrUnknownDiscriminator, e.Discriminator, e.Header, registered)
+}
+
+func (e *UnknownDiscriminatorError) Unwrap() error {
+	return ErrUnknownDiscriminator
+}
+
+// headerValue returns the value of the first header of the name.
+func headerValue(headers []Header, name string) (value []byte, found bool) {
+	for _, header := range headers {
+		if header.Key == name {
+			return header.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,87 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+type orderCreated struct {
+	ID int64
+}
+
+func TestHeaderDispatcher(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", ocfSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+	data, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := DeserializerFunc(func(topic string, isKey bool, data []byte) (interface{}, error) {
+		native, err := codec.Decode(topic, isKey, data)
+		if err != nil {
+			return nil, err
+		}
+		return orderCreated{ID: native.(map[string]interface{})["id"].(int64)}, nil
+	})
+	failed := errors.New("failed")
+	cancelled := DeserializerFunc(func(string, bool, []byte) (interface{}, error) { return nil, failed })
+	dispatcher := NewHeaderDispatcher("event-type").Register("OrderCreated", created).Register("OrderCancelled", cancelled)
+
+	headers := []Header{{Key: "source", Value: []byte("test")}, {Key: "event-type", Value: []byte("OrderCreated")}, {Key: "event-type", Value: []byte("OrderCancelled")}}
+	if value, discriminator, err := dispatcher.Decode("orders", false, data, headers); err != nil || discriminator != "OrderCreated" || value != (orderCreated{ID: 1}) {
+		t.Errorf("Decode() returned %v, %q, %v", value, discriminator, err)
+	}
+	if _, discriminator, err := dispatcher.Decode("orders", false, data, headers[2:]); err != failed || discriminator != "OrderCancelled" {
+		t.Errorf("Decode() with a failing deserializer returned %q, %v", discriminator, err)
+	}
+	if got := dispatcher.Discriminators(); !reflect.DeepEqual(got, []string{"OrderCancelled", "OrderCreated"}) {
+		t.Errorf("Discriminators() returned %q", got)
+	}
+
+	var tests = []struct {
+		name    string
+		headers []Header
+		want    UnknownDiscriminatorError
+		message string
+	}{
+		{"unknown", []Header{{Key: "event-type", Value: []byte("OrderShipped")}},
+			UnknownDiscriminatorError{Header: "event-type", Discriminator: "OrderShipped", Registered: []string{"OrderCancelled", "OrderCreated"}},
+			`unknown discriminator "OrderShipped" of the event-type header, registered are OrderCancelled, OrderCreated`},
+		{"missing", headers[:1],
+			UnknownDiscriminatorError{Header: "event-type", Missing: true, Registered: []string{"OrderCancelled", "OrderCreated"}},
+			"unknown discriminator: the message has no event-type header, registered are OrderCancelled, OrderCreated"},
+	}
+	for _, test := range tests {
+		value, _, err := dispatcher.Decode("orders", false, data, test.headers)
+		var unknown *UnknownDiscriminatorError
+		if value != nil || !errors.As(err, &unknown) || !errors.Is(err, ErrUnknownDiscriminator) || ErrorType(err) != "ErrUnknownDiscriminator" {
+			t.Fatalf("%v: Decode() returned %v, %v", test.name, value, err)
+		}
+		if !reflect.DeepEqual(*unknown, test.want) || err.Error() != test.message {
+			t.Errorf("%v: Decode() returned %+v: %q, want %+v: %q", test.name, *unknown, err, test.want, test.message)
+		}
+	}
+	if _, _, err := NewHeaderDispatcher("event-type").Decode("orders", false, data, headers); err == nil || err.Error() != `unknown discriminator "OrderCreated" of the event-type header, none are registered` {
+		t.Errorf("Decode() without deserializers returned %v", err)
+	}
+}
+
+func TestDecodedMessageHeader(t *testing.T) {
+
+	message := DecodedMessage{Headers: []Header{{Key: "a", Value: []byte("1")}, {Key: "a", Value: []byte("2")}, {Key: "empty"}}}
+	if value, found := message.Header("a"); !found || string(value) != "1" {
+		t.Errorf("Header() returned %q, %v, want the first header", value, found)
+	}
+	if _, found := message.Header("empty"); !found {
+		t.Error("Header() did not find the header without a value")
+	}
+	if _, found := message.Header("b"); found {
+		t.Error("Header() found a missing header")
+	}
+}
@@ -0,0 +1,111 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// WithEnums validates the enum values on encode: a value which is not one of the symbols fails
+// with a *FieldError wrapping ErrInvalidEnum, which lists the symbols. Values of string types,
+// e.g. type Status string, encode as the symbol they hold.
+func WithEnums() Option {
+	return func(c *Codec) {
+		if c.enums == nil {
+			c.enums = make(enumTypes)
+		}
+	}
+}
+
+// WithEnumType decodes the symbols of the enum with the full name to the values of a Go type, e.g.
+// a string type or iota constants, and encodes those values as their symbol. It implies WithEnums.
+//
+// The values play the role of the reader schema of the enum: a symbol without a value, e.g. one
+// added to a newer version of the enum, decodes to the value of the default of the enum if the
+// writer schema declares one which has a value, and fails with ErrInvalidEnum otherwise.
+func WithEnumType[T comparable](name string, values map[string]T) Option {
+	return func(c *Codec) {
+		WithEnums()(c)
+		t := &enumType{typ: reflect.TypeOf((*T)(nil)).Elem(), values: make(map[string]interface{}), symbols: make(map[interface{}]string)}
+		for symbol, value := range values {
+			t.values[symbol] = value
+			t.symbols[value] = symbol
+		}
+		c.enums[name] = t
+	}
+}
+
+// enumTypes are the Go types of the enums by their full name.
+type enumTypes map[string]*enumType
+
+type enumType struct {
+	typ     reflect.Type
+	values  map[string]interface{}
+	symbols map[interface{}]string
+}
+
+// conversion returns the conversion of the values of the enum.
+func (e enumTypes) conversion(n *avroschema.Node) logicalConversion {
+
+	t := e[n.Name]
+
+	conversion := logicalConversion{
+		encode: func(value interface{}) (interface{}, error) {
+
+			var symbol string
+			if s, found := t.symbolOf(value); found {
+				symbol = s
+			} else if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+				symbol = v.String()
+			} else {
+				return nil, fmt.Errorf("%w: %v of type %T is not one of the symbols %v of %v", ErrInvalidEnum, value, value, n.Symbols, n.Name)
+			}
+
+			if !hasSymbol(n, symbol) {
+				return nil, fmt.Errorf("%w: %q is not one of the symbols %v of %v", ErrInvalidEnum, symbol, n.Symbols, n.Name)
+			}
+			return symbol, nil
+		},
+		accepts: func(value interface{}) bool {
+			if t != nil {
+				return reflect.TypeOf(value) == t.typ
+			}
+			// a string is a value of the string branch
+			v := reflect.ValueOf(value)
+			return v.Kind() == reflect.String && v.Type() != reflect.TypeOf("")
+		},
+	}
+
+	if t != nil {
+		conversion.decode = func(avro interface{}) (interface{}, error) {
+			symbol, _ := avro.(string)
+			if value, found := t.values[symbol]; found {
+				return value, nil
+			}
+			if value, found := t.values[n.DefaultSymbol]; found && n.DefaultSymbol != "" {
+				return value, nil
+			}
+			return nil, fmt.Errorf("%w: symbol %q of %v has no value of type %v", ErrInvalidEnum, symbol, n.Name, t.typ)
+		}
+	}
+	return conversion
+}
+
+// symbolOf returns the symbol of a value of the Go type of the enum.
+func (t *enumType) symbolOf(value interface{}) (symbol string, found bool) {
+	if t == nil || reflect.TypeOf(value) != t.typ {
+		return
+	}
+	symbol, found = t.symbols[value]
+	return
+}
+
+func hasSymbol(n *avroschema.Node, symbol string) bool {
+	for _, s := range n.Symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const enumSchema = `{"type":"record","name":"account","namespace":"com.example","fields":[
+	{"name":"status","type":{"type":"enum","name":"Status","symbols":["ACTIVE","CLOSED"]}},
+	{"name":"level","type":["null",{"type":"enum","name":"Level","symbols":["LOW","HIGH"]}]},
+	{"name":"history","type":{"type":"array","items":"Status"}}]}`
+
+type status string
+
+type level int
+
+const (
+	low level = iota
+	high
+	critical
+)
+
+func TestEnumValidation(t *testing.T) {
+
+	var tests = []struct {
+		value map[string]interface{}
+		path  string
+		want  string
+	}{
+		{map[string]interface{}{"status": "OPEN", "level": nil, "history": []interface{}{}}, "status", `"OPEN" is not one of the symbols [ACTIVE CLOSED] of com.example.Status`},
+		{map[string]interface{}{"status": status("CLOSED"), "level": goavro.Union("com.example.Level", "MEDIUM"), "history": []interface{}{}}, "level", `"MEDIUM" is not one of the symbols [LOW HIGH]`},
+		{map[string]interface{}{"status": "ACTIVE", "level": nil, "history": []interface{}{"ACTIVE", 1}}, "history[1]", "1 of type int is not one of the symbols"},
+	}
+
+	codec := newLogicalCodec(t, enumSchema, WithEnums())
+
+	for _, test := range tests {
+		_, err := codec.Encode("orders", false, test.value)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != test.path || !errors.Is(err, ErrInvalidEnum) || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Encode(%v) returned %v, want an ErrInvalidEnum of field %v containing %q", test.value, err, test.path, test.want)
+		}
+	}
+
+	// a string type encodes as its symbol and decodes to a string
+	data, err := codec.Encode("orders", false, map[string]interface{}{"status": status("CLOSED"), "level": nil, "history": []interface{}{status("ACTIVE")}})
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	native, err := codec.Decode("orders", false, data)
+	want := map[string]interface{}{"status": "CLOSED", "level": nil, "history": []interface{}{"ACTIVE"}}
+	if err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("Decode() returned %v, %v, want %v", native, err, want)
+	}
+}
+
+func TestEnumType(t *testing.T) {
+
+	codec := newLogicalCodec(t, enumSchema,
+		WithEnumType("com.example.Status", map[string]status{"ACTIVE": "ACTIVE", "CLOSED": "CLOSED"}),
+		WithEnumType("com.example.Level", map[string]level{"LOW": low, "HIGH": high}))
+
+	var tests = []struct {
+		value map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			map[string]interface{}{"status": status("ACTIVE"), "level": high, "history": []interface{}{status("CLOSED"), "ACTIVE"}},
+			map[string]interface{}{"status": status("ACTIVE"), "level": map[string]interface{}{"com.example.Level": high}, "history": []interface{}{status("CLOSED"), status("ACTIVE")}},
+		},
+		{
+			map[string]interface{}{"status": "CLOSED", "level": goavro.Union("com.example.Level", low), "history": []interface{}{}},
+			map[string]interface{}{"status": status("CLOSED"), "level": map[string]interface{}{"com.example.Level": low}, "history": []interface{}{}},
+		},
+		{
+			map[string]interface{}{"status": "CLOSED", "level": goavro.Union("com.example.Level", "HIGH"), "history": []interface{}{}},
+			map[string]interface{}{"status": status("CLOSED"), "level": map[string]interface{}{"com.example.Level": high}, "history": []interface{}{}},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := codec.Encode("orders", false, test.value)
+		if err != nil {
+			t.Errorf("Encode(%v) failed: %v", test.value, err)
+			continue
+		}
+		native, err := codec.Decode("orders", false, data)
+		if err != nil || !reflect.DeepEqual(native, test.want) {
+			t.Errorf("Decode() returned %#v, %v, want %#v", native, err, test.want)
+		}
+	}
+
+	// a constant without a symbol
+	_, err := codec.Encode("orders", false, map[string]interface{}{"status": "CLOSED", "level": critical, "history": []interface{}{}})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "level" || !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("Encode(critical) returned %v, want an ErrInvalidEnum of field level", err)
+	}
+}
+
+// TestEnumTypeEvolution decodes the messages of a newer version of the enum, with a symbol which
+// has no Go value.
+func TestEnumTypeEvolution(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		schema string
+		want   interface{}
+	}{
+		{"default", `{"type":"enum","name":"Level","symbols":["LOW","HIGH","CRITICAL"],"default":"HIGH"}`, high},
+		{"default without a value", `{"type":"enum","name":"Level","symbols":["LOW","HIGH","CRITICAL","UNKNOWN"],"default":"UNKNOWN"}`, nil},
+		{"no default", `{"type":"enum","name":"Level","symbols":["LOW","HIGH","CRITICAL"]}`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			schema := `{"type":"record","name":"r","fields":[{"name":"level","type":` + test.schema + `}]}`
+			data, err := newLogicalCodec(t, schema).Encode("orders", false, map[string]interface{}{"level": "CRITICAL"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			codec := newLogicalCodec(t, schema, WithEnumType("Level", map[string]level{"LOW": low, "HIGH": high}))
+			native, err := codec.Decode("orders", false, data)
+
+			if test.want == nil {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Path != "level" || !errors.Is(err, ErrInvalidEnum) {
+					t.Errorf("Decode() returned %v, %v, want an ErrInvalidEnum of field level", native, err)
+				}
+				return
+			}
+			if err != nil || native.(map[string]interface{})["level"] != test.want {
+				t.Errorf("Decode() returned %v, %v, want level %v", native, err, test.want)
+			}
+		})
+	}
+}
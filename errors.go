@@ -0,0 +1,227 @@
+package kafkaavro
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// The errors returned by the Codec, Decoder and Encoder wrap one of these errors, test for them
+// with errors.Is. The registry errors are the ones of the schemaregistry package.
+var (
+	// ErrPayloadTooShort is returned for data shorter than the 5 bytes header.
+	ErrPayloadTooShort = errors.New("payload too short")
+	// ErrUnknownMagicByte is returned for data which does not start with the magic byte 0.
+	ErrUnknownMagicByte = errors.New("unknown magic byte")
+	// ErrSchemaNotRegistered is returned by NewEncoder when the schema is not registered under the subject.
+	ErrSchemaNotRegistered = errors.New("schema not registered")
+	// ErrCodecBuild is returned when goavro can not build a codec for a schema, the schemas of the
+	// registry fail with a *CodecBuildError.
+	ErrCodecBuild = errors.New("failed to build the avro codec")
+	// ErrPayloadTooLarge is returned for data larger than the maximum payload size, or with a
+	// collection larger than the maximum collection size.
+	ErrPayloadTooLarge = errors.New("payload too large")
+	// ErrSchemaTooLarge is returned for a schema of the registry larger than the maximum schema
+	// size, see WithMaxSchemaSize.
+	ErrSchemaTooLarge = errors.New("schema too large")
+	// ErrRoundTripMismatch is returned by the encodes of WithVerifyRoundTrip when the encoded data
+	// does not decode to the encoded value.
+	ErrRoundTripMismatch = errors.New("round trip mismatch")
+	// ErrUnknownDiscriminator is returned by HeaderDispatcher for a message of which no
+	// Deserializer is registered for the header, see UnknownDiscriminatorError.
+	ErrUnknownDiscriminator = errors.New("unknown discriminator")
+	// ErrMalformedPayload is returned when the avro data does not match the writer schema.
+	ErrMalformedPayload = errors.New("malformed payload")
+	// ErrInvalidDecimal is returned by the Decimal helpers for a value which does not fit the
+	// precision or scale of the decimal, or a schema which is not a decimal.
+	ErrInvalidDecimal = errors.New("invalid decimal")
+	// ErrInvalidUUID is returned for a string with logical type uuid which is not a UUID, see
+	// WithLogicalTypes.
+	ErrInvalidUUID = errors.New("invalid uuid")
+	// ErrInvalidTime is returned for a time out of the range of its logical type, e.g. a time of
+	// day of more than 24 hours, see WithLogicalTypes.
+	ErrInvalidTime = errors.New("invalid time")
+	// ErrInvalidEnum is returned for a value which is not a symbol of its enum, see WithEnums.
+	ErrInvalidEnum = errors.New("invalid enum")
+	// ErrSchemaResolution is returned by DecodeWithReader for a value of the writer schema which does
+	// not resolve to the reader schema, e.g. a new field without a default.
+	ErrSchemaResolution = errors.New("the writer schema does not resolve to the reader schema")
+
+	// ErrUnsupportedSchemaType is returned for data written with a schema of another schema type
+	// than avro, e.g. protobuf, without a SchemaType for it (see WithSchemaType), and by the avro
+	// only methods, e.g. DecodeWithReader, for those schemas.
+	ErrUnsupportedSchemaType = errors.New("unsupported schema type")
+	// ErrSchemaValidation is returned for a JSON document which does not validate against its JSON
+	// schema, see JSONSchemaType, or against an avro schema, see ValidateJSON.
+	ErrSchemaValidation = errors.New("the document does not validate against the schema")
+
+	// ErrSchemaChanged is returned by OCFWriter.AppendDecoded for a message of another schema than
+	// the schema of the file, a new file has to be started for the new schema.
+	ErrSchemaChanged = errors.New("the schema changed, roll a new file")
+	// ErrSchemaNotCached is returned in offline mode for a schema which would have to be fetched
+	// from the registry, see WithOfflineMode.
+	ErrSchemaNotCached = errors.New("schema not cached")
+	// ErrAmbiguousUnion is returned by WrapForSchema for a value which is a value of several
+	// branches of its union, wrap it with Union.
+	ErrAmbiguousUnion = errors.New("ambiguous union value")
+	// ErrInvalidSubject is returned for a subject with characters which the registry may not
+	// handle, see WithSubjectValidation.
+	ErrInvalidSubject = errors.New("invalid subject")
+	// ErrInvalidConfig is returned by NewCodecFromConfig for a property which is missing, invalid
+	// or not supported.
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrSchemaIDMismatch is returned for data of which the schema id header is not the schema id
+	// of its framing, see CheckHeaders and WithSchemaIDPrecedence.
+	ErrSchemaIDMismatch = errors.New("schema id header mismatch")
+	// ErrClosed is returned by the methods of a Codec which is closed, see Codec.Close.
+	ErrClosed = errors.New("closed")
+	// ErrNonFiniteFloat is returned by DecodeToJSON for a NaN or infinite float or double, which
+	// JSON has no number for, see WithNonFiniteFloats.
+	ErrNonFiniteFloat = errors.New("non finite float")
+
+	// ErrSchemaNotFound is returned when the registry does not know the subject, version or schema id.
+	ErrSchemaNotFound = schemaregistry.ErrSchemaNotFound
+	// ErrIncompatibleSchema is returned when the registry refuses to register an incompatible schema.
+	ErrIncompatibleSchema = schemaregistry.ErrIncompatibleSchema
+	// ErrRegistryUnavailable is returned when the registry can not be reached or fails with a server error.
+	ErrRegistryUnavailable = schemaregistry.ErrRegistryUnavailable
+)
+
+// errorTypes are the names of the errors of ErrorType, the errors which wrap others first.
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{ErrSchemaIDMismatch, "ErrSchemaIDMismatch"},
+	{ErrClosed, "ErrClosed"},
+	{ErrInvalidConfig, "ErrInvalidConfig"},
+	{ErrInvalidSubject, "ErrInvalidSubject"},
+	{ErrSchemaNotCached, "ErrSchemaNotCached"},
+	{ErrPayloadTooShort, "ErrPayloadTooShort"},
+	{ErrUnknownMagicByte, "ErrUnknownMagicByte"},
+	{ErrPayloadTooLarge, "ErrPayloadTooLarge"},
+	{ErrSchemaTooLarge, "ErrSchemaTooLarge"},
+	{ErrSchemaNotRegistered, "ErrSchemaNotRegistered"},
+	{ErrSchemaNotFound, "ErrSchemaNotFound"},
+	{ErrIncompatibleSchema, "ErrIncompatibleSchema"},
+	{ErrRegistryUnavailable, "ErrRegistryUnavailable"},
+	{ErrCodecBuild, "ErrCodecBuild"},
+	{ErrUnsupportedSchemaType, "ErrUnsupportedSchemaType"},
+	{ErrSchemaValidation, "ErrSchemaValidation"},
+	{ErrSchemaResolution, "ErrSchemaResolution"},
+	{ErrInvalidDecimal, "ErrInvalidDecimal"},
+	{ErrInvalidUUID, "ErrInvalidUUID"},
+	{ErrInvalidTime, "ErrInvalidTime"},
+	{ErrInvalidEnum, "ErrInvalidEnum"},
+	{ErrAmbiguousUnion, "ErrAmbiguousUnion"},
+	{ErrSchemaChanged, "ErrSchemaChanged"},
+	{ErrNonFiniteFloat, "ErrNonFiniteFloat"},
+	{ErrRoundTripMismatch, "ErrRoundTripMismatch"},
+	{ErrUnknownDiscriminator, "ErrUnknownDiscriminator"},
+	{ErrMalformedPayload, "ErrMalformedPayload"},
+}
+
+// ErrorType returns the name of the error of this package which the error wraps, e.g.
+// ErrUnknownMagicByte, to tell the failures apart in logs or in the headers of a dead letter
+// queue. It returns "" for the other errors.
+func ErrorType(err error) string {
+	for _, t := range errorTypes {
+		if errors.Is(err, t.err) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// payloadPreviewSize is the number of bytes of the data in a DecodeError.
+const payloadPreviewSize = 16
+
+// DecodeError is the error returned by the Decode methods of the Codec. It holds the size and
+// the first 16 bytes of the data (unless WithoutPayloadPreview is used) to help finding out what
+// was produced, e.g. JSON instead of avro: {"id":... is 7b226964...
+type DecodeError struct {
+	Size int
+	Head []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Head == nil {
+		return fmt.Sprintf("failed to decode %d bytes: %v", e.Size, e.Err)
+	}
+	ellipsis := ""
+	if e.Size > len(e.Head) {
+		ellipsis = "..."
+	}
+	return fmt.Sprintf("failed to decode %d bytes %x%v: %v", e.Size, e.Head, ellipsis, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// CodecBuildError is the error of a schema which goavro does not parse, e.g. a schema of another
+// schema type registered without its schemaType. It wraps ErrCodecBuild and the error of goavro.
+// SchemaType is the schemaType of the registry response, "" when it returned none, which means
+// avro; Subject is the subject of the schema if it is known.
+type CodecBuildError struct {
+	SchemaID   SchemaID
+	Subject    SubjectName
+	SchemaType string
+	Err        error
+
+	// looksLike is the schema type which the schema seems to be of, see schemaTypeOf
+	looksLike string
+}
+
+func newCodecBuildError(schema schemaregistry.Schema, subject SubjectName, err error) *CodecBuildError {
+	if subject == "" {
+		subject = SubjectName(schema.Subject)
+	}
+	return &CodecBuildError{SchemaID: schema.ID, Subject: subject, SchemaType: schema.SchemaType, Err: err, looksLike: schemaTypeOf(schema.Schema)}
+}
+
+func (e *CodecBuildError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrCodecBuild.Error())
+	if e.SchemaID > 0 {
+		fmt.Fprintf(&b, " of schema %d", e.SchemaID)
+	}
+	if e.Subject != "" {
+		fmt.Fprintf(&b, " of subject %v", e.Subject)
+	}
+	if e.SchemaType == "" {
+		b.WriteString(" (the registry returned no schemaType")
+	} else {
+		fmt.Fprintf(&b, " (schemaType %v", e.SchemaType)
+	}
+	if e.looksLike != "" {
+		fmt.Fprintf(&b, ", the schema looks like a %v schema", e.looksLike)
+	}
+	fmt.Fprintf(&b, "): %v", e.Err)
+	return b.String()
+}
+
+func (e *CodecBuildError) Unwrap() []error {
+	return []error{ErrCodecBuild, e.Err}
+}
+
+// FieldError is the error of a value of a field which does not convert to or from its logical
+// type or enum, Path is the path of the field, e.g. order.lines[2].id.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("field %v: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,239 @@
+package kafkaavro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// newErrorRegistry serves schema 1 for the subject ok-value, an invalid schema 2 for the subject
+// invalid-value, fails with a server error for schema 3 and the subject down-value, and does not
+// know any other schema or subject. Registering under incompatible-value fails as incompatible.
+func newErrorRegistry(t *testing.T) (client *schemaregistry.Client, close func()) {
+
+	writeJSON := func(w http.ResponseWriter, status int, v interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(w http.ResponseWriter, status int, errorCode int) {
+		writeJSON(w, status, map[string]interface{}{"error_code": errorCode, "message": http.StatusText(status)})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /schemas/ids/1":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"schema": testSchema})
+		case "GET /schemas/ids/2":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"schema": `{"type":"unknown"}`})
+		case "GET /schemas/ids/3", "GET /subjects/down-value/versions/latest":
+			fail(w, http.StatusInternalServerError, 50001)
+		case "GET /subjects/ok-value/versions/latest":
+			writeJSON(w, http.StatusOK, schemaregistry.Schema{Subject: "ok-value", Version: 1, ID: 1, Schema: testSchema})
+		case "GET /subjects/invalid-value/versions/latest":
+			writeJSON(w, http.StatusOK, schemaregistry.Schema{Subject: "invalid-value", Version: 1, ID: 2, Schema: `{"type":"unknown"}`})
+		case "POST /subjects/ok-value/versions":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"id": 1})
+		case "POST /subjects/incompatible-value/versions":
+			fail(w, http.StatusConflict, 409)
+		case "POST /subjects/unregistered-value":
+			fail(w, http.StatusNotFound, 40403)
+		default:
+			if strings.HasPrefix(r.URL.Path, "/schemas/ids/") {
+				fail(w, http.StatusNotFound, 40403)
+			} else {
+				fail(w, http.StatusNotFound, 40401)
+			}
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	client, err := schemaregistry.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
+
+func TestErrors(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+	codec := NewCodec(client, TopicNameStrategy{})
+
+	unreachable, closeUnreachable := newErrorRegistry(t)
+	closeUnreachable()
+
+	decoder, err := NewDecoder(*client, "ok-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decode := func(data []byte) func() error {
+		return func() error {
+			_, err := codec.Decode("ok", false, data)
+			return err
+		}
+	}
+	encode := func(topic string) func() error {
+		return func() error {
+			_, err := codec.Encode(topic, false, map[string]interface{}{"f1": "value"})
+			return err
+		}
+	}
+
+	var tests = []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"Decode empty", decode(nil), ErrPayloadTooShort},
+		{"Decode short", decode([]byte{0, 0, 0}), ErrPayloadTooShort},
+		{"Decode magic byte", decode([]byte{1, 0, 0, 0, 1, 0}), ErrUnknownMagicByte},
+		{"Decode unknown schema", decode([]byte{0, 0, 0, 0, 9, 0}), ErrSchemaNotFound},
+		{"Decode invalid schema", decode([]byte{0, 0, 0, 0, 2, 0}), ErrCodecBuild},
+		{"Decode server error", decode([]byte{0, 0, 0, 0, 3, 0}), ErrRegistryUnavailable},
+		{"DecodeReuse magic byte", func() error {
+			_, err := codec.DecodeReuse("ok", false, []byte{1, 0, 0, 0, 1, 0}, &DecodeScratch{})
+			return err
+		}, ErrUnknownMagicByte},
+		{"WriterSchema unknown schema", func() error {
+			_, _, err := codec.WriterSchema([]byte{0, 0, 0, 0, 9, 0})
+			return err
+		}, ErrSchemaNotFound},
+		{"Encode unknown subject", encode("missing"), ErrSchemaNotFound},
+		{"Encode invalid schema", encode("invalid"), ErrCodecBuild},
+		{"Encode server error", encode("down"), ErrRegistryUnavailable},
+		{"WarmUp unknown subject", func() error {
+			return codec.WarmUp(context.Background(), []SubjectName{"ok-value", "missing-value"})
+		}, ErrSchemaNotFound},
+		{"Encode unreachable registry", func() error {
+			_, err := NewCodec(unreachable, TopicNameStrategy{}).Encode("ok", false, nil)
+			return err
+		}, ErrRegistryUnavailable},
+		{"Decoder magic byte", func() error {
+			_, err := decoder.Decode([]byte{1, 0, 0, 0, 1, 0})
+			return err
+		}, ErrUnknownMagicByte},
+		{"Decoder unknown schema", func() error {
+			_, err := decoder.Decode([]byte{0, 0, 0, 0, 9, 0})
+			return err
+		}, ErrSchemaNotFound},
+		{"Decoder invalid schema", func() error {
+			_, err := decoder.Decode([]byte{0, 0, 0, 0, 2, 0})
+			return err
+		}, ErrCodecBuild},
+		{"NewEncoder not registered", func() error {
+			_, err := NewEncoder(*client, false, "unregistered-value", testSchema)
+			return err
+		}, ErrSchemaNotRegistered},
+		{"NewEncoder incompatible", func() error {
+			_, err := NewEncoder(*client, true, "incompatible-value", testSchema)
+			return err
+		}, ErrIncompatibleSchema},
+		{"NewEncoder invalid schema", func() error {
+			_, err := NewEncoder(*client, true, "ok-value", `{"type":"unknown"}`)
+			return err
+		}, ErrCodecBuild},
+		{"NewEncoder unreachable registry", func() error {
+			_, err := NewEncoder(*unreachable, true, "ok-value", testSchema)
+			return err
+		}, ErrRegistryUnavailable},
+	}
+
+	sentinels := []error{ErrPayloadTooShort, ErrUnknownMagicByte, ErrSchemaNotFound, ErrSchemaNotRegistered,
+		ErrIncompatibleSchema, ErrCodecBuild, ErrRegistryUnavailable}
+
+	for _, test := range tests {
+		err := test.call()
+		if !errors.Is(err, test.want) {
+			t.Errorf("%v returned %v, want an error wrapping %v", test.name, err, test.want)
+			continue
+		}
+		for _, sentinel := range sentinels {
+			if sentinel != test.want && errors.Is(err, sentinel) {
+				t.Errorf("%v returned %v, which also wraps %v", test.name, err, sentinel)
+			}
+		}
+	}
+}
+
+func TestErrorType(t *testing.T) {
+
+	var tests = []struct {
+		err  error
+		want string
+	}{
+		{&DecodeError{Size: 3, Err: ErrPayloadTooShort}, "ErrPayloadTooShort"},
+		{fmt.Errorf("failed to fetch schema 9: %w", schemaregistry.ResourceError{ErrorCode: 40403}), "ErrSchemaNotFound"},
+		{&FieldError{Path: "order.id", Err: fmt.Errorf("%w: %w", ErrSchemaIDMismatch, ErrMalformedPayload)}, "ErrSchemaIDMismatch"},
+		{errors.New("other"), ""},
+		{nil, ""},
+	}
+
+	for _, test := range tests {
+		if got := ErrorType(test.err); got != test.want {
+			t.Errorf("ErrorType(%v) returned %q, want %q", test.err, got, test.want)
+		}
+	}
+}
+
+func TestErrorsAs(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+
+	_, err := NewCodec(client, TopicNameStrategy{}).Decode("ok", false, []byte{0, 0, 0, 0, 9, 0})
+
+	var resourceErr schemaregistry.ResourceError
+	if !errors.As(err, &resourceErr) || resourceErr.ErrorCode != 40403 {
+		t.Errorf("Decode() returned %v, want an error wrapping the ResourceError of the registry", err)
+	}
+	if !schemaregistry.IsSchemaNotFound(err) {
+		t.Errorf("IsSchemaNotFound(%v) returned false", err)
+	}
+}
+
+func TestDecodeErrorPreview(t *testing.T) {
+
+	payload := []byte(`{"id":42,"customer":"someone"}`)
+
+	var tests = []struct {
+		name    string
+		options []Option
+		data    []byte
+		want    string
+	}{
+		{"short", nil, []byte{0, 0, 0, 0, 9, 0}, "failed to decode 6 bytes 000000000900: failed to fetch schema 9"},
+		{"empty", nil, nil, "failed to decode 0 bytes: payload too short"},
+		{"truncated", nil, payload, "failed to decode 30 bytes 7b226964223a34322c22637573746f6d...: unknown magic byte 123"},
+		{"without preview", []Option{WithoutPayloadPreview()}, payload, "failed to decode 30 bytes: unknown magic byte 123"},
+	}
+
+	for _, test := range tests {
+
+		codec := NewCodec(newFakeRegistry(), TopicNameStrategy{}, test.options...)
+
+		for method, decode := range map[string]func() (interface{}, error){
+			"Decode":      func() (interface{}, error) { return codec.Decode("test", false, test.data) },
+			"DecodeReuse": func() (interface{}, error) { return codec.DecodeReuse("test", false, test.data, &DecodeScratch{}) },
+		} {
+			_, err := decode()
+			if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+				t.Errorf("%v %v returned %v, want an error starting with %q", test.name, method, err, test.want)
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || decodeErr.Size != len(test.data) || len(decodeErr.Head) > 16 {
+				t.Errorf("%v %v returned %#v, want a DecodeError with at most 16 bytes", test.name, method, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package kafkaavro_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const orderSchema = `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"customer","type":"string"}]}`
+
+func ExampleNewCodec() {
+
+	client, err := schemaregistry.NewClient("http://localhost:8081")
+	if err != nil {
+		panic(err)
+	}
+
+	// create one codec and share it, it caches the schemas of all topics
+	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+
+	fmt.Println(codec.Subject("orders", false))
+	// Output: orders-value
+}
+
+func ExampleCodec_Decode() {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", orderSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	// the value of a message: the magic byte, schema id 1 and the avro encoded order
+	data := []byte{0, 0, 0, 0, 1, 0x54, 0x0a, 'a', 'l', 'i', 'c', 'e'}
+
+	native, err := codec.Decode("orders", false, data)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(native)
+
+	// data which is not in the wire format fails instead of panicking
+	_, err = codec.Decode("orders", false, []byte("{}"))
+	fmt.Println(err)
+	// Output:
+	// map[customer:alice id:42]
+	// failed to decode 2 bytes 7b7d: payload too short: data of 2 bytes is too short for the 5 bytes header
+}
+
+func ExampleCodec_Encode() {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", orderSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+
+	// the value is encoded with the latest schema of the orders-value subject
+	data, err := codec.Encode("orders", false, map[string]interface{}{"id": 42, "customer": "alice"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("% x\n", data)
+	// Output: 00 00 00 00 01 54 0a 61 6c 69 63 65
+}
+
+func ExampleNewEncoder() {
+
+	// a registry which registers every schema with id 7
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":7}`)
+	}))
+	defer server.Close()
+
+	client, err := schemaregistry.NewClient(server.URL)
+	if err != nil {
+		panic(err)
+	}
+
+	// the encoder of a single subject registers the schema if autoRegister is true
+	encoder, err := kafkaavro.NewEncoder(*client, true, "orders-value", orderSchema)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := encoder.EncodeTextual([]byte(`{"id":42,"customer":"alice"}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("% x\n", data)
+	// Output: 00 00 00 00 07 54 0a 61 6c 69 63 65
+}
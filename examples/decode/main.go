@@ -6,7 +6,7 @@ import (
 	"os/signal"
 	"syscall"
 
-	schemaregistry "github.com/lensesio/schema-registry"
+	"github.com/timvw/kafkaavro/schemaregistry"
 	"github.com/timvw/kafkaavro"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
-	schemaregistry "github.com/lensesio/schema-registry"
+	"github.com/timvw/kafkaavro/schemaregistry"
 	"github.com/timvw/kafkaavro"
 )
 
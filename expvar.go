@@ -0,0 +1,95 @@
+package kafkaavro
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// registryRequest holds the outcome of the last schema registry request.
+type registryRequest struct {
+	latency atomic.Int64
+	err     atomic.Pointer[string]
+}
+
+func (r *registryRequest) record(latency time.Duration, err error) {
+	r.latency.Store(int64(latency))
+	if err != nil {
+		message := err.Error()
+		r.err.Store(&message)
+	} else {
+		r.err.Store(nil)
+	}
+}
+
+var (
+	publishedMu sync.Mutex
+	published   = make(map[string]*atomic.Pointer[Codec])
+)
+
+// PublishExpvar publishes the state of the Codec as the expvar variable with the name prefix, e.g.
+// to inspect it on /debug/vars: the cache size, hits and misses, the schema id of every subject
+// used to encode, the schema ids of every decoded topic and the latency and error of the last
+// registry request. Publishing again with the same prefix (e.g. another Codec) replaces the
+// published Codec, as expvar variables can not be removed.
+func (c *Codec) PublishExpvar(prefix string) {
+
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+
+	if codec, found := published[prefix]; found {
+		codec.Store(c)
+		return
+	}
+
+	codec := &atomic.Pointer[Codec]{}
+	codec.Store(c)
+	published[prefix] = codec
+	expvar.Publish(prefix, expvar.Func(func() interface{} {
+		return codec.Load().expvarState()
+	}))
+}
+
+type expvarState struct {
+	CacheSize                  int              `json:"cache_size"`
+	Hits                       uint64           `json:"hits"`
+	Misses                     uint64           `json:"misses"`
+	Subjects                   map[string]int   `json:"subjects"`
+	Topics                     map[string][]int `json:"topics"`
+	LastRegistryLatencySeconds float64          `json:"last_registry_latency_seconds"`
+	LastRegistryError          string           `json:"last_registry_error,omitempty"`
+}
+
+func (c *Codec) expvarState() (state expvarState) {
+
+	stats := c.CacheStats()
+	state = expvarState{
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		Subjects: make(map[string]int),
+		Topics:   make(map[string][]int),
+	}
+
+	// the caches of all registries of WithRegistryRouter, the last request of any of them
+	var last *Codec
+	c.eachRegistry(func(codec *Codec) {
+		state.CacheSize += codec.codecByID.len()
+		codec.encoderSchemas.each(func(subjectName SubjectName, schema encoderSchema) {
+			state.Subjects[subjectName] = schema.schemaID
+		})
+		if last == nil || codec.lastRegistryRequest.err.Load() != nil {
+			last = codec
+		}
+	})
+	state.LastRegistryLatencySeconds = time.Duration(last.lastRegistryRequest.latency.Load()).Seconds()
+	for topic, infos := range c.ObservedSchemas() {
+		for _, info := range infos {
+			state.Topics[topic] = append(state.Topics[topic], info.ID)
+		}
+	}
+	if message := last.lastRegistryRequest.err.Load(); message != nil {
+		state.LastRegistryError = *message
+	}
+	return
+}
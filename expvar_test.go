@@ -0,0 +1,49 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{})
+	codec.PublishExpvar("kafkaavro_test")
+	codec.PublishExpvar("kafkaavro_test")
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec.Decode("test", false, data)
+	codec.Decode("test", false, []byte{0, 0, 0, 0, 8, 0})
+
+	var state map[string]interface{}
+	if err = json.Unmarshal([]byte(expvar.Get("kafkaavro_test").String()), &state); err != nil {
+		t.Fatal(err)
+	}
+	delete(state, "last_registry_latency_seconds")
+
+	want := map[string]interface{}{
+		"cache_size":          1.0,
+		"hits":                1.0,
+		"misses":              2.0,
+		"subjects":            map[string]interface{}{"test-value": 7.0},
+		"topics":              map[string]interface{}{"test": []interface{}{7.0}},
+		"last_registry_error": "schema 8 not found",
+	}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("published %v, want %v", state, want)
+	}
+
+	NewCodec(newFakeRegistry(), TopicNameStrategy{}).PublishExpvar("kafkaavro_test")
+	state = nil
+	if err = json.Unmarshal([]byte(expvar.Get("kafkaavro_test").String()), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state["hits"] != 0.0 || state["last_registry_error"] != nil {
+		t.Errorf("publishing another codec with the same prefix did not replace the codec: %v", state)
+	}
+}
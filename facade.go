@@ -0,0 +1,48 @@
+package kafkaavro
+
+// Serializer encodes the keys and values of the messages of topics, e.g. for a producer which
+// takes a Serializer rather than a Codec, so that its tests can pass a fake.
+type Serializer interface {
+	Serialize(topic string, isKey bool, v interface{}) ([]byte, error)
+}
+
+// Deserializer decodes the keys and values of the messages of topics, see Serializer.
+type Deserializer interface {
+	Deserialize(topic string, isKey bool, data []byte) (interface{}, error)
+}
+
+// NewSerializer creates a Serializer which encodes like the Codec of NewCodec.
+func NewSerializer(client RegistryClient, subjectNameStrategy SubjectNameStrategy, options ...Option) Serializer {
+	return NewCodec(client, subjectNameStrategy, options...).Serializer()
+}
+
+// NewDeserializer creates a Deserializer which decodes like the Codec of NewCodec.
+func NewDeserializer(client RegistryClient, subjectNameStrategy SubjectNameStrategy, options ...Option) Deserializer {
+	return NewCodec(client, subjectNameStrategy, options...).Deserializer()
+}
+
+// Serializer returns the Serializer of the Codec, which shares its caches.
+func (c *Codec) Serializer() Serializer {
+	return serializer{c}
+}
+
+// Deserializer returns the Deserializer of the Codec, which shares its caches.
+func (c *Codec) Deserializer() Deserializer {
+	return deserializer{c}
+}
+
+type serializer struct {
+	codec *Codec
+}
+
+func (s serializer) Serialize(topic string, isKey bool, v interface{}) ([]byte, error) {
+	return s.codec.Encode(topic, isKey, v)
+}
+
+type deserializer struct {
+	codec *Codec
+}
+
+func (d deserializer) Deserialize(topic string, isKey bool, data []byte) (interface{}, error) {
+	return d.codec.Decode(topic, isKey, data)
+}
@@ -0,0 +1,45 @@
+package kafkaavro
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestSerializerDeserializer(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", ocfSchema)
+
+	// the facades of a codec share its caches
+	codec := NewCodec(registry, TopicNameStrategy{})
+	serializer, deserializer := codec.Serializer(), codec.Deserializer()
+	data, err := serializer.Serialize("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native, err := deserializer.Deserialize("orders", false, data); err != nil || !reflect.DeepEqual(native, order(1)) {
+		t.Errorf("Deserialize() returned %v, %v", native, err)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 0 {
+		t.Errorf("the schema was fetched %d times, the caches are not shared", calls)
+	}
+
+	// the facades which are created independently have their own caches
+	native, err := NewDeserializer(registry, TopicNameStrategy{}).Deserialize("orders", false, data)
+	if err != nil || !reflect.DeepEqual(native, order(1)) {
+		t.Errorf("Deserialize() returned %v, %v", native, err)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 1 {
+		t.Errorf("the schema was fetched %d times, want once", calls)
+	}
+	if encoded, err := NewSerializer(registry, TopicNameStrategy{}).Serialize("orders", false, order(1)); err != nil || !reflect.DeepEqual(encoded, data) {
+		t.Errorf("Serialize() returned %v, %v, want %v", encoded, err, data)
+	}
+
+	// a Serializer does not decode
+	if _, ok := serializer.(Deserializer); ok {
+		t.Error("the Serializer is a Deserializer")
+	}
+}
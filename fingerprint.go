@@ -0,0 +1,42 @@
+package kafkaavro
+
+import "crypto/sha256"
+
+// rabinEmpty is the fingerprint of the empty string of the CRC-64-AVRO fingerprint.
+const rabinEmpty = 0xc15d213aa4d7a795
+
+var rabinTable = func() (table [256]uint64) {
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (rabinEmpty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return
+}()
+
+// Fingerprint64 returns the CRC-64-AVRO (Rabin) fingerprint of the Parsing Canonical Form of the
+// schema, the fingerprint of the avro single object encoding.
+func Fingerprint64(schema AvroSchema) (fingerprint uint64, err error) {
+
+	canonical, err := CanonicalForm(schema)
+	if err != nil {
+		return
+	}
+	fingerprint = rabinEmpty
+	for i := 0; i < len(canonical); i++ {
+		fingerprint = (fingerprint >> 8) ^ rabinTable[byte(fingerprint)^canonical[i]]
+	}
+	return
+}
+
+// FingerprintSHA256 returns the SHA-256 fingerprint of the Parsing Canonical Form of the schema.
+func FingerprintSHA256(schema AvroSchema) (fingerprint [sha256.Size]byte, err error) {
+
+	canonical, err := CanonicalForm(schema)
+	if err != nil {
+		return
+	}
+	return sha256.Sum256([]byte(canonical)), nil
+}
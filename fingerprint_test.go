@@ -0,0 +1,109 @@
+package kafkaavro
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// specFingerprints are test vectors of the avro specification (share/test/data/schema-tests.txt):
+// the schema, its Parsing Canonical Form and its CRC-64-AVRO fingerprint as a signed long.
+var specFingerprints = []struct {
+	schema      string
+	canonical   string
+	fingerprint int64
+}{
+	{`"null"`, `"null"`, 7195948357588979594},
+	{`{"type":"null"}`, `"null"`, 7195948357588979594},
+	{`"boolean"`, `"boolean"`, -6970731678124411036},
+	{`"int"`, `"int"`, 8247732601305521295},
+	{`"long"`, `"long"`, -3434872931120570953},
+	{`"float"`, `"float"`, 5583340709985441680},
+	{`"double"`, `"double"`, -8181574048448539266},
+	{`"bytes"`, `"bytes"`, 5746618253357095269},
+	{`"string"`, `"string"`, -8142146995180207161},
+	{`[ "int"  ]`, `["int"]`, -5232228896498058493},
+	{`[ "int" , {"type":"boolean"} ]`, `["int","boolean"]`, 5392556393470105090},
+	{`{"fields":[], "type":"record", "name":"foo"}`, `{"name":"foo","type":"record","fields":[]}`, -4824392279771201922},
+	{`{"fields":[], "type":"record", "name":"foo", "namespace":"x.y"}`, `{"name":"x.y.foo","type":"record","fields":[]}`, 5916914534497305771},
+	{`{"fields":[], "type":"record", "name":"a.b", "namespace":"x.y"}`, `{"name":"a.b","type":"record","fields":[]}`, 4453111798040224814},
+	{`{"fields":[], "type":"record", "name":"foo", "doc":"Useful info"}`, `{"name":"foo","type":"record","fields":[]}`, -4824392279771201922},
+	{`{"type":"enum", "name":"foo", "symbols":["A1"]}`, `{"name":"foo","type":"enum","symbols":["A1"]}`, -6342190197741309591},
+	{`{"namespace":"x.y.z", "type":"enum", "name":"foo", "doc":"foo bar", "symbols":["A1", "A2"]}`, `{"name":"x.y.z.foo","type":"enum","symbols":["A1","A2"]}`, -4448647247586288245},
+	{`{"name":"foo","type":"fixed","size":15}`, `{"name":"foo","type":"fixed","size":15}`, 1756455273707447556},
+	{`{"namespace":"x.y.z", "type":"fixed", "name":"foo", "doc":"foo bar", "size":32}`, `{"name":"x.y.z.foo","type":"fixed","size":32}`, -3064184465700546786},
+	{`{ "items":{"type":"null"}, "type":"array"}`, `{"type":"array","items":"null"}`, -589620603366471059},
+	{`{ "values":"string", "type":"map"}`, `{"type":"map","values":"string"}`, -8732877298790414990},
+	{`{"name":"PigValue","type":"record","fields":[{"name":"value", "type":["null", "int", "long", "PigValue"]}]}`,
+		`{"name":"PigValue","type":"record","fields":[{"name":"value","type":["null","int","long","PigValue"]}]}`, -1759257747318642341},
+}
+
+func TestFingerprint64(t *testing.T) {
+
+	for _, test := range specFingerprints {
+
+		canonical, err := CanonicalForm(test.schema)
+		if err != nil || canonical != test.canonical {
+			t.Errorf("CanonicalForm(%v) returned %v, %v, want %v", test.schema, canonical, err, test.canonical)
+		}
+
+		fingerprint, err := Fingerprint64(test.schema)
+		if err != nil || int64(fingerprint) != test.fingerprint {
+			t.Errorf("Fingerprint64(%v) returned %d, %v, want %d", test.schema, int64(fingerprint), err, test.fingerprint)
+		}
+	}
+
+	if _, err := Fingerprint64(`{"type":`); err == nil {
+		t.Error("Fingerprint64() of an invalid schema did not fail")
+	}
+}
+
+// TestFingerprint64MatchesGoavro compares the fingerprints of the interop schemas with the ones of
+// goavro. The canonical form of goavro keeps {"type":"long"} for logical types (and names the
+// decimals bytes.decimal), the Parsing Canonical Form and the java library reduce them to "long".
+func TestFingerprint64MatchesGoavro(t *testing.T) {
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "interop", "*", "schema.avsc"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no interop schemas: %v", err)
+	}
+	for _, path := range paths {
+		schema, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(schema), "logicalType") {
+			continue
+		}
+		codec, err := goavro.NewCodec(string(schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fingerprint, err := Fingerprint64(string(schema)); err != nil || fingerprint != codec.Rabin {
+			t.Errorf("%v: Fingerprint64() returned %d, %v, goavro %d", path, fingerprint, err, codec.Rabin)
+		}
+	}
+}
+
+func TestFingerprintSHA256(t *testing.T) {
+
+	var tests = []struct {
+		schema string
+		want   string
+	}{
+		{`"int"`, "3f2b87a9fe7cc9b13835598c3981cd45e3e355309e5090aa0933d7becb6fba45"},
+		{`{"type":"int","logicalType":"date"}`, "3f2b87a9fe7cc9b13835598c3981cd45e3e355309e5090aa0933d7becb6fba45"},
+		{specFingerprints[len(specFingerprints)-1].schema, "7844d3a484ec5fc907e35c8a9fd69ae0af4ff70b241835efbd0acdaf62433ec7"},
+	}
+
+	for _, test := range tests {
+		fingerprint, err := FingerprintSHA256(test.schema)
+		if got := hex.EncodeToString(fingerprint[:]); err != nil || got != test.want {
+			t.Errorf("FingerprintSHA256(%v) returned %v, %v, want %v", test.schema, got, err, test.want)
+		}
+	}
+}
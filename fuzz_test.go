@@ -0,0 +1,141 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// fuzzSchema has a field of every kind of avro type, so that the fuzzer reaches every goavro decoder.
+const fuzzSchema = `{"type":"record","name":"fuzzed","fields":[
+	{"name":"i","type":"int"},
+	{"name":"l","type":"long"},
+	{"name":"d","type":"double"},
+	{"name":"s","type":"string"},
+	{"name":"b","type":"bytes"},
+	{"name":"u","type":["null","string","long"]},
+	{"name":"e","type":{"type":"enum","name":"color","symbols":["RED","GREEN"]}},
+	{"name":"f","type":{"type":"fixed","name":"four","size":4}},
+	{"name":"a","type":{"type":"array","items":"string"}},
+	{"name":"m","type":{"type":"map","values":"long"}},
+	{"name":"n","type":{"type":"array","items":"null"}},
+	{"name":"t","type":{"type":"long","logicalType":"timestamp-millis"}}
+]}`
+
+// FuzzDecode decodes arbitrary data, which must never panic and either decode or fail with one of
+// the errors of the package. The crafted payloads of testdata/fuzz/FuzzDecode are part of the corpus.
+func FuzzDecode(f *testing.F) {
+
+	registry := &fakeRegistry{schemas: map[int]string{1: fuzzSchema}, subjects: map[string][]int{}}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithMaxPayloadSize(1<<20))
+
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0, 1})
+	f.Add([]byte(`{"i":1}`))
+	f.Add(validFuzzPayload(f))
+
+	expected := []error{ErrPayloadTooShort, ErrUnknownMagicByte, ErrPayloadTooLarge, ErrMalformedPayload}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		_, err := codec.Decode("fuzz", false, data)
+		if err == nil {
+			return
+		}
+
+		if len(data) >= headerSize && data[0] == 0 && getSchemaID(data[1:]) != 1 {
+			return // an unknown schema id
+		}
+		for _, target := range expected {
+			if errors.Is(err, target) {
+				return
+			}
+		}
+		t.Errorf("Decode(%x) returned %v, which is not one of the errors of the package", data, err)
+	})
+}
+
+// validFuzzPayload returns a message of the fuzzSchema with schema id 1, so that the fuzzer starts
+// from data which decodes.
+func validFuzzPayload(tb testing.TB) []byte {
+
+	codec, err := goavro.NewCodec(fuzzSchema)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	body, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"i": 1, "l": int64(-2), "d": 3.5, "s": "four", "b": []byte{5},
+		"u": goavro.Union("string", "six"), "e": "GREEN", "f": []byte("8888"),
+		"a": []interface{}{"nine"}, "m": map[string]interface{}{"ten": int64(10)}, "n": []interface{}{nil},
+		"t": time.UnixMilli(1604232000123),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return append([]byte{0, 0, 0, 0, 1}, body...)
+}
+
+// FuzzParseWireFormat splits arbitrary data, which must never panic and either return the
+// schema id and body of a header or fail with one of the header errors.
+func FuzzParseWireFormat(f *testing.F) {
+
+	f.Add([]byte{})
+	f.Add([]byte{0})
+	f.Add([]byte{0, 0, 0, 0, 1})
+	f.Add([]byte{0, 0xff, 0xff, 0xff, 0xff, 2, 'a'})
+	f.Add([]byte{1, 0, 0, 0, 1})
+	f.Add(validFuzzPayload(f))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		schemaID, body, err := ParseWireFormat(data)
+
+		switch {
+		case len(data) < headerSize:
+			if !errors.Is(err, ErrPayloadTooShort) {
+				t.Errorf("ParseWireFormat(%x) returned %v, want ErrPayloadTooShort", data, err)
+			}
+		case data[0] != 0:
+			if !errors.Is(err, ErrUnknownMagicByte) {
+				t.Errorf("ParseWireFormat(%x) returned %v, want ErrUnknownMagicByte", data, err)
+			}
+		case err != nil:
+			t.Errorf("ParseWireFormat(%x) failed: %v", data, err)
+		case schemaID != getSchemaID(data[1:]) || schemaID < 0:
+			t.Errorf("ParseWireFormat(%x) returned schema id %d, want %d", data, schemaID, getSchemaID(data[1:]))
+		case !bytes.Equal(body, data[headerSize:]):
+			t.Errorf("ParseWireFormat(%x) returned body %x, want %x", data, body, data[headerSize:])
+		}
+	})
+}
+
+// FuzzSubject resolves the subjects of arbitrary topics, which must be the topic followed by
+// -key or -value, also when they are served from the subject cache.
+func FuzzSubject(f *testing.F) {
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{})
+
+	f.Add("orders", false)
+	f.Add("", true)
+	f.Add("orders-value", true)
+	f.Add("%v\x00-key", false)
+
+	f.Fuzz(func(t *testing.T, topic string, isKey bool) {
+
+		suffix := "-value"
+		if isKey {
+			suffix = "-key"
+		}
+
+		for i := 0; i < 2; i++ {
+			subject := codec.Subject(topic, isKey)
+			if !strings.HasSuffix(subject, suffix) || strings.TrimSuffix(subject, suffix) != topic {
+				t.Fatalf("Subject(%q, %v) returned %q, want %q", topic, isKey, subject, topic+suffix)
+			}
+		}
+	})
+}
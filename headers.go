@@ -0,0 +1,188 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ContentTypeAvro is the content type header of the data of EncodeWithHeaders.
+const ContentTypeAvro = "application/avro"
+
+// MetadataHeaders are the names of the headers of EncodeWithHeaders, the headers with an empty name
+// are left out.
+type MetadataHeaders struct {
+	// ContentType is the header with ContentTypeAvro.
+	ContentType string
+	// SchemaID is the header with the schema id of the data in decimal, e.g. 42.
+	SchemaID string
+	// Subject is the header with the subject of the key or value of the topic.
+	Subject string
+}
+
+// DefaultMetadataHeaders are the names of the headers unless WithMetadataHeaders is used.
+var DefaultMetadataHeaders = MetadataHeaders{ContentType: "content-type", SchemaID: "x-schema-id", Subject: "x-schema-subject"}
+
+// WithMetadataHeaders replaces the names of the headers of EncodeWithHeaders and DecodeWithHeaders.
+func WithMetadataHeaders(names MetadataHeaders) Option {
+	return func(c *Codec) {
+		c.metadataHeaders = &names
+	}
+}
+
+func (c *Codec) metadataHeaderNames() MetadataHeaders {
+	if c.metadataHeaders != nil {
+		return *c.metadataHeaders
+	}
+	return DefaultMetadataHeaders
+}
+
+// EncodeWithHeaders encodes like Encode and returns the headers which describe the data, for the
+// generic tools which read the headers rather than the framing: the content type, the schema id
+// and the subject, see MetadataHeaders.
+func (c *Codec) EncodeWithHeaders(topic string, isKey bool, native interface{}) (data []byte, headers []Header, err error) {
+
+	if data, err = c.Encode(topic, isKey, native); err != nil {
+		return
+	}
+	headers, err = c.MetadataHeaders(topic, isKey, data)
+	return
+}
+
+// MetadataHeaders returns the headers of EncodeWithHeaders of the encoded data.
+func (c *Codec) MetadataHeaders(topic string, isKey bool, data []byte) (headers []Header, err error) {
+
+	schemaID, err := parseHeader(data)
+	if err != nil {
+		return
+	}
+	names := c.metadataHeaderNames()
+	for _, header := range []Header{
+		{names.ContentType, []byte(ContentTypeAvro)},
+		{names.SchemaID, []byte(strconv.Itoa(schemaID))},
+		{names.Subject, []byte(c.Subject(topic, isKey))},
+	} {
+		if header.Key != "" {
+			headers = append(headers, header)
+		}
+	}
+	return
+}
+
+// SchemaIDPrecedence is the schema id with which DecodeWithHeaders decodes the data of which the
+// schema id header (see MetadataHeaders) is not the schema id of its framing, see
+// WithSchemaIDPrecedence.
+type SchemaIDPrecedence int
+
+const (
+	// PreferPayload decodes the data with the schema id of its framing, the default.
+	PreferPayload SchemaIDPrecedence = iota
+	// PreferHeader decodes the body of the data with the schema id of the header, e.g. for the
+	// mirroring tools which rewrite the data without its schema id but set the header.
+	PreferHeader
+	// ErrorOnMismatch fails with ErrSchemaIDMismatch.
+	ErrorOnMismatch
+)
+
+func (p SchemaIDPrecedence) String() string {
+	switch p {
+	case PreferPayload:
+		return "PreferPayload"
+	case PreferHeader:
+		return "PreferHeader"
+	case ErrorOnMismatch:
+		return "ErrorOnMismatch"
+	}
+	return fmt.Sprintf("SchemaIDPrecedence(%d)", int(p))
+}
+
+// The SchemaIDSource of the HookEvent of OnSchemaIDMismatch.
+const (
+	SchemaIDFromPayload = "payload"
+	SchemaIDFromHeader  = "header"
+)
+
+// WithSchemaIDPrecedence sets the schema id of DecodeWithHeaders (and confluent.DecodeMessage)
+// when the schema id header and the framing of the data disagree, PreferPayload by default.
+// Every mismatch is reported to the OnSchemaIDMismatch hook.
+func WithSchemaIDPrecedence(precedence SchemaIDPrecedence) Option {
+	return func(c *Codec) {
+		c.schemaIDPrecedence = precedence
+	}
+}
+
+// DecodeWithHeaders decodes like Decode, with the schema id of the schema id header of the headers
+// (see MetadataHeaders) or of the framing of the data when they disagree, see ResolveHeaders.
+func (c *Codec) DecodeWithHeaders(topic string, isKey bool, data []byte, headers []Header) (native interface{}, err error) {
+
+	if data, err = c.ResolveHeaders(topic, data, headers); err != nil {
+		return
+	}
+	return c.Decode(topic, isKey, data)
+}
+
+// ResolveHeaders returns the data of the topic to decode following WithSchemaIDPrecedence when
+// its schema id header is not the schema id of its framing: the data, the body of the data framed
+// with the schema id of the header, or ErrSchemaIDMismatch with both. Data without the header is
+// returned as is.
+func (c *Codec) ResolveHeaders(topic string, data []byte, headers []Header) (resolved []byte, err error) {
+
+	headerID, value, schemaID, found, err := c.schemaIDHeader(data, headers)
+	if err != nil || !found || headerID == schemaID {
+		return data, err
+	}
+
+	resolved = data
+	event := HookEvent{Topic: topic, SchemaID: schemaID, SchemaIDSource: SchemaIDFromPayload}
+	switch {
+	case c.schemaIDPrecedence == ErrorOnMismatch, c.schemaIDPrecedence == PreferHeader && headerID < 0:
+		resolved, event.SchemaIDSource, event.Err = nil, "", c.schemaIDMismatch(topic, value, schemaID)
+	case c.schemaIDPrecedence == PreferHeader:
+		resolved, event.SchemaID, event.SchemaIDSource = c.EncodeFramed(headerID, data[headerSize:]), headerID, SchemaIDFromHeader
+	}
+	if c.hooks.OnSchemaIDMismatch != nil {
+		c.callHook("OnSchemaIDMismatch", c.hooks.OnSchemaIDMismatch, event)
+	}
+	return resolved, event.Err
+}
+
+// CheckHeaders returns ErrSchemaIDMismatch if the schema id header of the headers is not the schema
+// id of the framing of the data, regardless of WithSchemaIDPrecedence.
+func (c *Codec) CheckHeaders(data []byte, headers []Header) error {
+
+	headerID, value, schemaID, found, err := c.schemaIDHeader(data, headers)
+	if err != nil || !found || headerID == schemaID {
+		return err
+	}
+	return c.schemaIDMismatch("", value, schemaID)
+}
+
+// schemaIDHeader returns the schema id and the value of the schema id header of the headers, if
+// found, and the schema id of the framing of the data. The id is -1 for a value which is not a
+// schema id.
+func (c *Codec) schemaIDHeader(data []byte, headers []Header) (headerID SchemaID, value []byte, schemaID SchemaID, found bool, err error) {
+
+	name := c.metadataHeaderNames().SchemaID
+	if name == "" {
+		return
+	}
+	for _, header := range headers {
+		if header.Key != name {
+			continue
+		}
+		if schemaID, err = parseHeader(data); err != nil {
+			return
+		}
+		if headerID, err = strconv.Atoi(string(header.Value)); err != nil || headerID < 0 {
+			headerID, err = -1, nil
+		}
+		return headerID, header.Value, schemaID, true, nil
+	}
+	return
+}
+
+func (c *Codec) schemaIDMismatch(topic string, value []byte, schemaID SchemaID) error {
+	if topic != "" {
+		topic = "topic " + topic + ": "
+	}
+	return fmt.Errorf("%w: %vthe %v header is %q, the data is framed with schema %d", ErrSchemaIDMismatch, topic, c.metadataHeaderNames().SchemaID, value, schemaID)
+}
@@ -0,0 +1,114 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestEncodeWithHeaders(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `"string"`)
+	id := registry.Register("orders-value", ocfSchema)
+
+	data, headers, err := NewCodec(registry, TopicNameStrategy{}).EncodeWithHeaders("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Header{{"content-type", []byte("application/avro")}, {"x-schema-id", []byte("2")}, {"x-schema-subject", []byte("orders-value")}}
+	if schemaID, _, _ := ParseWireFormat(data); schemaID != id || !reflect.DeepEqual(headers, want) {
+		t.Errorf("EncodeWithHeaders() returned schema %v and %q, want %v and %q", schemaID, headers, id, want)
+	}
+
+	// the headers are renamed or left out
+	codec := NewCodec(registry, TopicNameStrategy{}, WithMetadataHeaders(MetadataHeaders{SchemaID: "schema-id"}))
+	if _, headers, err = codec.EncodeWithHeaders("orders", false, order(1)); err != nil || !reflect.DeepEqual(headers, []Header{{"schema-id", []byte("2")}}) {
+		t.Errorf("EncodeWithHeaders() with other names returned %q, %v", headers, err)
+	}
+
+	// the mismatches fail with ErrorOnMismatch, see TestSchemaIDPrecedence for the others
+	codec = NewCodec(registry, TopicNameStrategy{}, WithMetadataHeaders(MetadataHeaders{SchemaID: "schema-id"}), WithSchemaIDPrecedence(ErrorOnMismatch))
+	tests := []struct {
+		name    string
+		headers []Header
+		want    error
+	}{
+		{"matching header", []Header{{"schema-id", []byte("2")}}, nil},
+		{"no header", []Header{{"x-schema-id", []byte("1")}}, nil},
+		{"other schema", []Header{{"schema-id", []byte("1")}}, ErrSchemaIDMismatch},
+		{"not a schema id", []Header{{"schema-id", []byte("two")}}, ErrSchemaIDMismatch},
+	}
+	for _, test := range tests {
+		native, err := codec.DecodeWithHeaders("orders", false, data, test.headers)
+		if !errors.Is(err, test.want) || (test.want == nil && !reflect.DeepEqual(native, order(1))) {
+			t.Errorf("%v: DecodeWithHeaders() returned %v, %v, want %v", test.name, native, err, test.want)
+		}
+	}
+}
+
+func TestSchemaIDPrecedence(t *testing.T) {
+
+	registry := mockregistry.New()
+	stringID := registry.Register("orders-value", `"string"`)
+	orderID := registry.Register("orders-value", ocfSchema)
+	encoded, err := NewCodec(registry, TopicNameStrategy{}).Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a mirror rewrote the data with the schema id of another schema, the header is right
+	codec := NewCodec(registry, TopicNameStrategy{})
+	data := codec.EncodeFramed(stringID, encoded[headerSize:])
+	headers := []Header{{"x-schema-id", []byte(strconv.Itoa(orderID))}}
+
+	var tests = []struct {
+		precedence SchemaIDPrecedence
+		headers    []Header
+		want       interface{}
+		wantErr    string
+		wantEvent  HookEvent
+	}{
+		{PreferPayload, headers, "\x00", "", HookEvent{Topic: "orders", SchemaID: stringID, SchemaIDSource: SchemaIDFromPayload}},
+		{PreferHeader, headers, order(1), "", HookEvent{Topic: "orders", SchemaID: orderID, SchemaIDSource: SchemaIDFromHeader}},
+		{ErrorOnMismatch, headers, nil, `topic orders: the x-schema-id header is "2", the data is framed with schema 1`, HookEvent{Topic: "orders", SchemaID: stringID}},
+		// a header which is not a schema id is only used to fail
+		{PreferHeader, []Header{{"x-schema-id", []byte("two")}}, nil, `the x-schema-id header is "two"`, HookEvent{Topic: "orders", SchemaID: stringID}},
+		{PreferPayload, []Header{{"x-schema-id", []byte("two")}}, "\x00", "", HookEvent{Topic: "orders", SchemaID: stringID, SchemaIDSource: SchemaIDFromPayload}},
+	}
+
+	for _, test := range tests {
+		var events []HookEvent
+		codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaIDPrecedence(test.precedence), WithHooks(Hooks{
+			OnSchemaIDMismatch: func(event HookEvent) { events = append(events, event) },
+		}))
+		native, err := codec.DecodeWithHeaders("orders", false, data, test.headers)
+		if test.wantErr != "" {
+			if !errors.Is(err, ErrSchemaIDMismatch) || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("DecodeWithHeaders() with %v returned %v, want %q", test.precedence, err, test.wantErr)
+			}
+		} else if err != nil || !reflect.DeepEqual(native, test.want) {
+			t.Errorf("DecodeWithHeaders() with %v returned %#v, %v, want %#v", test.precedence, native, err, test.want)
+		}
+		if len(events) != 1 {
+			t.Errorf("OnSchemaIDMismatch was called %d times with %v", len(events), test.precedence)
+			continue
+		}
+		events[0].Err = nil
+		if events[0] != test.wantEvent {
+			t.Errorf("OnSchemaIDMismatch with %v got %+v, want %+v", test.precedence, events[0], test.wantEvent)
+		}
+	}
+
+	// the data of which the header matches is not reported
+	var mismatches int
+	matching := NewCodec(registry, TopicNameStrategy{}, WithSchemaIDPrecedence(ErrorOnMismatch), WithHooks(Hooks{
+		OnSchemaIDMismatch: func(HookEvent) { mismatches++ },
+	}))
+	if _, err := matching.DecodeWithHeaders("orders", false, encoded, headers); err != nil || mismatches != 0 {
+		t.Errorf("DecodeWithHeaders() of a matching header returned %v with %d mismatches", err, mismatches)
+	}
+}
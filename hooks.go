@@ -0,0 +1,57 @@
+package kafkaavro
+
+import "time"
+
+// HookEvent describes the event passed to a hook. The fields which do not apply to the event are
+// empty: decodes identify the schema by id and have no Subject, and warming up has no Topic.
+type HookEvent struct {
+	Topic    string
+	Subject  SubjectName
+	SchemaID SchemaID
+	Latency  time.Duration
+	Err      error
+	// SchemaIDSource is the source of the schema id with which the data of OnSchemaIDMismatch is
+	// decoded, SchemaIDFromPayload or SchemaIDFromHeader, "" when it fails.
+	SchemaIDSource string
+}
+
+// Hooks are called synchronously on the goroutine of the operation. A hook which panics does not
+// break the operation: the panic is recovered (and logged, see WithLogger). Nil hooks are skipped.
+type Hooks struct {
+	// OnCacheMiss is called when a schema is not cached, before it is fetched.
+	OnCacheMiss func(HookEvent)
+	// OnSchemaFetched is called after every schema registry request, with its latency and error.
+	OnSchemaFetched func(HookEvent)
+	// OnDecodeError is called when a message can not be decoded.
+	OnDecodeError func(HookEvent)
+	// OnEncodeError is called when a message can not be encoded.
+	OnEncodeError func(HookEvent)
+	// OnRegistryRetry is called before a failed schema registry request is retried.
+	OnRegistryRetry func(HookEvent)
+	// OnNewSchemaObserved is called once per topic and schema id, the first time data of the
+	// topic written with the schema is decoded, to notice producers writing with a new schema.
+	OnNewSchemaObserved func(topic string, info SchemaInfo)
+	// OnSchemaIDMismatch is called by DecodeWithHeaders for data of which the schema id header is
+	// not the schema id of its framing, with the schema id and its source following
+	// WithSchemaIDPrecedence, or the ErrSchemaIDMismatch error.
+	OnSchemaIDMismatch func(HookEvent)
+}
+
+// WithHooks calls the hooks on the events of the Codec.
+func WithHooks(hooks Hooks) Option {
+	return func(c *Codec) {
+		c.hooks = hooks
+	}
+}
+
+// callHook calls the hook and recovers when it panics.
+func (c *Codec) callHook(name string, hook func(HookEvent), event HookEvent) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil && c.logger != nil {
+			c.logger.Warn("hook panicked", "hook", name, "panic", recovered)
+		}
+	}()
+
+	hook(event)
+}
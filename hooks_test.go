@@ -0,0 +1,93 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+
+	type call struct {
+		hook  string
+		event HookEvent
+	}
+
+	var calls []call
+	record := func(hook string) func(HookEvent) {
+		return func(e HookEvent) {
+			e.Latency = 0
+			calls = append(calls, call{hook, e})
+		}
+	}
+
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{}, WithHooks(Hooks{
+		OnCacheMiss:     record("miss"),
+		OnSchemaFetched: record("fetched"),
+		OnDecodeError:   record("decode-error"),
+		OnEncodeError:   record("encode-error"),
+	}))
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec.Encode("test", false, map[string]interface{}{"f2": "value"})
+	codec.Encode("other", false, map[string]interface{}{"f1": "value"})
+	if _, err = codec.Decode("test", false, data); err != nil {
+		t.Fatal(err)
+	}
+	codec.Decode("test", false, []byte{0, 0, 0, 0, 8, 0})
+	codec.DecodeReuse("test", false, []byte{1, 0, 0, 0, 7, 0}, &DecodeScratch{})
+
+	want := []struct {
+		hook  string
+		event HookEvent
+		err   string
+	}{
+		{"miss", HookEvent{Topic: "test", Subject: "test-value"}, ""},
+		{"fetched", HookEvent{Topic: "test", Subject: "test-value", SchemaID: 7}, ""},
+		{"encode-error", HookEvent{Topic: "test", Subject: "test-value", SchemaID: 7}, `field "f1"`},
+		{"miss", HookEvent{Topic: "other", Subject: "other-value"}, ""},
+		{"fetched", HookEvent{Topic: "other", Subject: "other-value"}, "subject other-value not found"},
+		{"encode-error", HookEvent{Topic: "other", Subject: "other-value"}, "subject other-value not found"},
+		{"miss", HookEvent{Topic: "test", SchemaID: 8}, ""},
+		{"fetched", HookEvent{Topic: "test", SchemaID: 8}, "schema 8 not found"},
+		{"decode-error", HookEvent{Topic: "test", SchemaID: 8}, "schema 8 not found"},
+		{"decode-error", HookEvent{Topic: "test"}, "unknown magic byte"},
+	}
+
+	if len(calls) != len(want) {
+		t.Fatalf("hooks were called %d times, want %d: %+v", len(calls), len(want), calls)
+	}
+	for i, w := range want {
+		got := calls[i]
+		gotErr := got.event.Err
+		got.event.Err = nil
+		if got.hook != w.hook || got.event != w.event ||
+			(w.err == "") != (gotErr == nil) || (gotErr != nil && !strings.Contains(gotErr.Error(), w.err)) {
+			t.Errorf("call %d was %v %+v (error %v), want %v %+v (error containing %q)", i, got.hook, got.event, gotErr, w.hook, w.event, w.err)
+		}
+	}
+}
+
+func TestHookPanicIsRecovered(t *testing.T) {
+
+	var out bytes.Buffer
+	codec := NewCodec(newFakeRegistry(), TopicNameStrategy{},
+		WithLogger(slog.New(slog.NewTextHandler(&out, nil))),
+		WithHooks(Hooks{OnCacheMiss: func(HookEvent) { panic("broken hook") }}))
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Decode("test", false, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), `msg="hook panicked" hook=OnCacheMiss panic="broken hook"`) {
+		t.Errorf("the panic was not logged: %v", out.String())
+	}
+}
@@ -0,0 +1,84 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// TestDiffSchemasCompatibility checks the compatibility verdicts of DiffSchemas against the
+// compatibility checks of the schema registry.
+func TestDiffSchemasCompatibility(t *testing.T) {
+
+	env := StartKafkaWithRegistry(t)
+	client := env.RegistryClient(t)
+
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"field added with a default",
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"],"default":null}]}`},
+		{"field added without a default",
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string"}]}`},
+		{"field removed without a default",
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":"string"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`},
+		{"int widened to long",
+			`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"int"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"long"}]}`},
+		{"string to int",
+			`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"string"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"quantity","type":"int"}]}`},
+		{"field renamed with an alias",
+			`{"type":"record","name":"Order","fields":[{"name":"customer_name","type":"string"}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"customer","type":"string","aliases":["customer_name"]}]}`},
+		{"symbol added to an enum",
+			`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN"]}}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]}}]}`},
+		{"symbol added to an enum with a default",
+			`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN"],"default":"OPEN"}}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"],"default":"OPEN"}}]}`},
+		{"branch added to a union",
+			`{"type":"record","name":"Order","fields":[{"name":"note","type":["null","string"],"default":null}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"note","type":["null","string","long"],"default":null}]}`},
+		{"record renamed with an alias",
+			`{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`,
+			`{"type":"record","name":"Purchase","aliases":["Order"],"fields":[{"name":"id","type":"long"}]}`},
+	}
+
+	for i, test := range tests {
+
+		diff, err := kafkaavro.DiffSchemas(test.old, test.new)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		subject := fmt.Sprintf("compatibility-%d-%d-value", i, time.Now().UnixNano())
+		if _, err = client.RegisterNewSchema(subject, test.old); err != nil {
+			t.Fatalf("%v: failed to register the old schema: %v", test.name, err)
+		}
+
+		for _, level := range []kafkaavro.Compatibility{kafkaavro.CompatibilityBackward, kafkaavro.CompatibilityForward, kafkaavro.CompatibilityFull} {
+			if err = client.SetCompatibility(context.Background(), subject, schemaregistry.CompatibilityLevel(level)); err != nil {
+				t.Fatalf("%v: failed to set the compatibility: %v", test.name, err)
+			}
+			isCompatible, err := client.IsCompatible(subject, test.new)
+			if err != nil {
+				t.Fatalf("%v: failed to check the compatibility: %v", test.name, err)
+			}
+			want := diff.Compatibility == level || diff.Compatibility == kafkaavro.CompatibilityFull
+			if isCompatible != want {
+				t.Errorf("%v: the registry finds the schemas %v compatible: %v, DiffSchemas returned %v", test.name, level, isCompatible, diff.Compatibility)
+			}
+		}
+	}
+}
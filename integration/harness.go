@@ -0,0 +1,247 @@
+//go:build integration
+
+// Package integration starts Kafka and the Confluent Schema Registry (or Karapace) in docker
+// containers with testcontainers-go, for tests which need a real broker and registry. It is only
+// built with the integration build tag:
+//
+//	go test -tags integration ./integration
+//
+// Downstream projects can use StartKafkaWithRegistry in their own integration tests.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const (
+	// KafkaImage is the image of the Kafka broker (in KRaft mode).
+	KafkaImage = "confluentinc/confluent-local:7.5.0"
+	// RegistryImage is the image of the schema registry.
+	RegistryImage = "confluentinc/cp-schema-registry:7.5.0"
+	// KarapaceImage is the image of the Karapace schema registry.
+	KarapaceImage = "ghcr.io/aiven-open/karapace:3.15.0"
+
+	kafkaAlias    = "kafka"
+	kafkaPort     = nat.Port("9093/tcp")
+	registryPort  = nat.Port("8081/tcp")
+	startTimeout  = 3 * time.Minute
+	starterScript = "/usr/sbin/testcontainers_start.sh"
+
+	// the PLAINTEXT listener is advertised on the mapped port for the test, the BROKER listener
+	// on the network alias for the schema registry
+	starterScriptContent = `#!/bin/bash
+source /etc/confluent/docker/bash-config
+export KAFKA_ADVERTISED_LISTENERS=PLAINTEXT://%s:%d,BROKER://%s:9092
+sed -i '/KAFKA_ZOOKEEPER_CONNECT/d' /etc/confluent/docker/configure
+echo 'kafka-storage format --ignore-formatted -t "$(kafka-storage random-uuid)" -c /etc/kafka/kafka.properties' >> /etc/confluent/docker/configure
+echo '' > /etc/confluent/docker/ensure
+/etc/confluent/docker/configure
+/etc/confluent/docker/launch`
+)
+
+// Environment is a running Kafka broker and schema registry.
+type Environment struct {
+	// Brokers is the bootstrap.servers of the broker, e.g. localhost:32768
+	Brokers string
+	// RegistryURL is the url of the schema registry, e.g. http://localhost:32769
+	RegistryURL string
+}
+
+// RegistryClient returns a client of the schema registry.
+func (e Environment) RegistryClient(t testing.TB) *schemaregistry.Client {
+	t.Helper()
+	client, err := schemaregistry.NewClient(e.RegistryURL)
+	if err != nil {
+		t.Fatalf("failed to create the schema registry client: %v", err)
+	}
+	return client
+}
+
+// StartKafkaWithRegistry starts a Kafka broker and a schema registry on a new docker network and
+// returns their addresses. The containers and the network are removed when the test completes.
+// The test fails if docker is not available.
+func StartKafkaWithRegistry(t testing.TB) (env Environment) {
+	t.Helper()
+	return startEnvironment(t, startRegistry)
+}
+
+// StartKafkaWithKarapace starts a Kafka broker and the Karapace schema registry, like
+// StartKafkaWithRegistry.
+func StartKafkaWithKarapace(t testing.TB) (env Environment) {
+	t.Helper()
+	return startEnvironment(t, startKarapace)
+}
+
+func startEnvironment(t testing.TB, start func(ctx context.Context, networkName string) (testcontainers.Container, error)) (env Environment) {
+
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	networkName := fmt.Sprintf("kafkaavro-%d", time.Now().UnixNano())
+	network, err := testcontainers.GenericNetwork(ctx, testcontainers.GenericNetworkRequest{
+		NetworkRequest: testcontainers.NetworkRequest{Name: networkName, CheckDuplicate: true, Attachable: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the docker network: %v", err)
+	}
+	t.Cleanup(func() {
+		if removeErr := network.Remove(context.Background()); removeErr != nil {
+			t.Logf("failed to remove the docker network %v: %v", networkName, removeErr)
+		}
+	})
+
+	kafka, err := startKafka(ctx, networkName)
+	if err != nil {
+		t.Fatalf("failed to start kafka: %v", err)
+	}
+	terminateOnCleanup(t, kafka)
+
+	registry, err := start(ctx, networkName)
+	if err != nil {
+		t.Fatalf("failed to start the schema registry: %v", err)
+	}
+	terminateOnCleanup(t, registry)
+
+	if env.Brokers, err = endpoint(ctx, kafka, kafkaPort, ""); err != nil {
+		t.Fatalf("failed to get the address of kafka: %v", err)
+	}
+	if env.RegistryURL, err = endpoint(ctx, registry, registryPort, "http://"); err != nil {
+		t.Fatalf("failed to get the address of the schema registry: %v", err)
+	}
+	return
+}
+
+func startKafka(ctx context.Context, networkName string) (container testcontainers.Container, err error) {
+
+	return testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		Started: true,
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:          KafkaImage,
+			ExposedPorts:   []string{string(kafkaPort)},
+			Networks:       []string{networkName},
+			NetworkAliases: map[string][]string{networkName: {kafkaAlias}},
+			Env: map[string]string{
+				"KAFKA_LISTENERS":                                "PLAINTEXT://0.0.0.0:9093,BROKER://0.0.0.0:9092,CONTROLLER://0.0.0.0:9094",
+				"KAFKA_REST_BOOTSTRAP_SERVERS":                   "PLAINTEXT://0.0.0.0:9093,BROKER://0.0.0.0:9092,CONTROLLER://0.0.0.0:9094",
+				"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":           "BROKER:PLAINTEXT,PLAINTEXT:PLAINTEXT,CONTROLLER:PLAINTEXT",
+				"KAFKA_INTER_BROKER_LISTENER_NAME":               "BROKER",
+				"KAFKA_BROKER_ID":                                "1",
+				"KAFKA_NODE_ID":                                  "1",
+				"KAFKA_PROCESS_ROLES":                            "broker,controller",
+				"KAFKA_CONTROLLER_LISTENER_NAMES":                "CONTROLLER",
+				"KAFKA_CONTROLLER_QUORUM_VOTERS":                 "1@" + kafkaAlias + ":9094",
+				"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR":         "1",
+				"KAFKA_OFFSETS_TOPIC_NUM_PARTITIONS":             "1",
+				"KAFKA_TRANSACTION_STATE_LOG_REPLICATION_FACTOR": "1",
+				"KAFKA_TRANSACTION_STATE_LOG_MIN_ISR":            "1",
+				"KAFKA_LOG_FLUSH_INTERVAL_MESSAGES":              fmt.Sprintf("%d", math.MaxInt64),
+				"KAFKA_GROUP_INITIAL_REBALANCE_DELAY_MS":         "0",
+				"KAFKA_AUTO_CREATE_TOPICS_ENABLE":                "true",
+			},
+			// the advertised listeners contain the mapped port, which is only known once the
+			// container is started, so the container waits for the starter script
+			Entrypoint: []string{"sh"},
+			Cmd:        []string{"-c", "while [ ! -f " + starterScript + " ]; do sleep 0.1; done; bash " + starterScript},
+			LifecycleHooks: []testcontainers.ContainerLifecycleHooks{{
+				PostStarts: []testcontainers.ContainerHook{
+					func(ctx context.Context, c testcontainers.Container) (err error) {
+						host, err := c.Host(ctx)
+						if err != nil {
+							return
+						}
+						port, err := c.MappedPort(ctx, kafkaPort)
+						if err != nil {
+							return
+						}
+						script := fmt.Sprintf(starterScriptContent, host, port.Int(), kafkaAlias)
+						return c.CopyToContainer(ctx, []byte(script), starterScript, 0o755)
+					},
+					func(ctx context.Context, c testcontainers.Container) error {
+						return wait.ForLog(".*Transitioning from RECOVERY to RUNNING.*").AsRegexp().
+							WithStartupTimeout(startTimeout).WaitUntilReady(ctx, c)
+					},
+				},
+			}},
+		},
+	})
+}
+
+func startRegistry(ctx context.Context, networkName string) (container testcontainers.Container, err error) {
+
+	return testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		Started: true,
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        RegistryImage,
+			ExposedPorts: []string{string(registryPort)},
+			Networks:     []string{networkName},
+			Env: map[string]string{
+				"SCHEMA_REGISTRY_HOST_NAME":                    "schema-registry",
+				"SCHEMA_REGISTRY_LISTENERS":                    "http://0.0.0.0:8081",
+				"SCHEMA_REGISTRY_KAFKASTORE_BOOTSTRAP_SERVERS": "PLAINTEXT://" + kafkaAlias + ":9092",
+			},
+			WaitingFor: wait.ForHTTP("/subjects").WithPort(registryPort).WithStartupTimeout(startTimeout),
+		},
+	})
+}
+
+func startKarapace(ctx context.Context, networkName string) (container testcontainers.Container, err error) {
+
+	return testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		Started: true,
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        KarapaceImage,
+			ExposedPorts: []string{string(registryPort)},
+			Networks:     []string{networkName},
+			Entrypoint:   []string{"/bin/bash", "/opt/karapace/start.sh"},
+			Cmd:          []string{"registry"},
+			Env: map[string]string{
+				"KARAPACE_ADVERTISED_HOSTNAME": "karapace",
+				"KARAPACE_BOOTSTRAP_URI":       kafkaAlias + ":9092",
+				"KARAPACE_HOST":                "0.0.0.0",
+				"KARAPACE_PORT":                "8081",
+				"KARAPACE_CLIENT_ID":           "karapace",
+				"KARAPACE_GROUP_ID":            "karapace-registry",
+				"KARAPACE_MASTER_ELIGIBILITY":  "true",
+				"KARAPACE_TOPIC_NAME":          "_schemas",
+				"KARAPACE_COMPATIBILITY":       "BACKWARD",
+			},
+			WaitingFor: wait.ForHTTP("/subjects").WithPort(registryPort).WithStartupTimeout(startTimeout),
+		},
+	})
+}
+
+func terminateOnCleanup(t testing.TB, container testcontainers.Container) {
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("failed to terminate container %v: %v", container.GetContainerID(), err)
+		}
+	})
+}
+
+func endpoint(ctx context.Context, container testcontainers.Container, port nat.Port, scheme string) (address string, err error) {
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return
+	}
+	mapped, err := container.MappedPort(ctx, port)
+	if err != nil {
+		return
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s%s:%d", scheme, host, mapped.Int()), nil
+}
@@ -0,0 +1,70 @@
+//go:build integration
+
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// TestKarapace checks that the client and the Codec work with Karapace, which implements the API
+// of the Confluent registry with deviations.
+func TestKarapace(t *testing.T) {
+
+	env := StartKafkaWithKarapace(t)
+	client, err := schemaregistry.NewClient(env.RegistryURL, schemaregistry.UsingNormalize(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities, err := client.DetectCapabilities()
+	if err != nil {
+		t.Fatalf("DetectCapabilities() failed: %v", err)
+	}
+	t.Logf("the capabilities of Karapace are %+v", capabilities)
+
+	topic := fmt.Sprintf("orders-%d", time.Now().UnixNano())
+	codec, err := kafkaavro.NewCodecFromURL(env.RegistryURL, kafkaavro.WithStartupPing(true),
+		kafkaavro.WithRegistryClientOptions(schemaregistry.UsingNormalize(true)))
+	if err != nil {
+		t.Fatalf("NewCodecFromURL() failed: %v", err)
+	}
+	subject := codec.Subject(topic, false)
+
+	if isRegistered, _, err := client.IsRegistered(subject, roundTripSchema); err != nil || isRegistered {
+		t.Fatalf("IsRegistered() of the unknown subject returned %v, %v", isRegistered, err)
+	}
+	id, err := client.RegisterNewSchema(subject, roundTripSchema)
+	if err != nil {
+		t.Fatalf("RegisterNewSchema() failed: %v", err)
+	}
+	if _, err = client.RegisterNewSchema(subject, `{"type":"record","name":"order"`); !errors.Is(err, schemaregistry.ErrInvalidSchema) {
+		t.Errorf("RegisterNewSchema() of an invalid schema returned %v, want ErrInvalidSchema", err)
+	}
+	if _, err = client.GetLatestSchema(topic + "-unknown"); !errors.Is(err, schemaregistry.ErrSchemaNotFound) {
+		t.Errorf("GetLatestSchema() of an unknown subject returned %v, want ErrSchemaNotFound", err)
+	}
+
+	// Karapace omits the schema type of avro schemas
+	schema, err := client.GetSchema(id)
+	if err != nil {
+		t.Fatalf("GetSchema() failed: %v", err)
+	}
+	if schema.Type() != schemaregistry.SchemaTypeAvro {
+		t.Errorf("the schema type is %v", schema.Type())
+	}
+
+	sent := map[string]interface{}{"id": int64(1), "customer": "alice", "total": 12.5}
+	data, err := codec.Encode(topic, false, sent)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	if _, err = codec.Decode(topic, false, data); err != nil {
+		t.Errorf("Decode() failed: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/confluent"
+)
+
+// TestMigratePreservesTimestamps migrates a message with gokafkaavro migrate and reads back the
+// CreateTime of the migrated message, which must be the timestamp of the source message.
+func TestMigratePreservesTimestamps(t *testing.T) {
+
+	env := StartKafkaWithRegistry(t)
+	client := env.RegistryClient(t)
+
+	suffix := time.Now().UnixNano()
+	source, destination := fmt.Sprintf("orders-%d", suffix), fmt.Sprintf("orders-v2-%d", suffix)
+	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+	if _, err := client.RegisterNewSchema(codec.Subject(source, false), `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`); err != nil {
+		t.Fatalf("failed to register the source schema: %v", err)
+	}
+	if _, err := client.RegisterNewSchema(codec.Subject(destination, false),
+		`{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"status","type":"string","default":"NEW"}]}`); err != nil {
+		t.Fatalf("failed to register the target schema: %v", err)
+	}
+
+	// a message of a day ago, with the milliseconds of the timestamps of Kafka
+	createTime := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+	m, err := confluent.NewMessage(codec, source, []byte("1"), map[string]interface{}{"id": int64(1)})
+	if err != nil {
+		t.Fatalf("failed to encode the source message: %v", err)
+	}
+	m.Timestamp = createTime
+	produce(t, env.Brokers, []*kafka.Message{m})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	migrate := exec.CommandContext(ctx, "go", "run", "../cmd/gokafkaavro", "migrate", "--brokers", env.Brokers, "--registry", env.RegistryURL,
+		"--source-topic", source, "--dest-topic", destination, "--group", fmt.Sprintf("migrate-%d", suffix))
+	if out, err := migrate.CombinedOutput(); err != nil {
+		t.Fatalf("gokafkaavro migrate failed: %v\n%s", err, out)
+	}
+
+	migrated := consume(t, env.Brokers, destination, 1)[0]
+	if migrated.TimestampType != kafka.TimestampCreateTime || !migrated.Timestamp.Equal(createTime) {
+		t.Errorf("the migrated message has the %v timestamp %v, want the CreateTime %v of the source message", migrated.TimestampType, migrated.Timestamp, createTime)
+	}
+	native, err := confluent.DecodeValue(codec, migrated)
+	if want := map[string]interface{}{"id": int64(1), "status": "NEW"}; err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("the migrated value is %v, %v, want %v", native, err, want)
+	}
+}
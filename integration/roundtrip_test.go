@@ -0,0 +1,132 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/confluent"
+)
+
+const roundTripSchema = `{
+	"type": "record",
+	"name": "order",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "customer", "type": "string"},
+		{"name": "total", "type": "double"}
+	]
+}`
+
+func TestRoundTrip(t *testing.T) {
+
+	env := StartKafkaWithRegistry(t)
+	client := env.RegistryClient(t)
+
+	topic := fmt.Sprintf("orders-%d", time.Now().UnixNano())
+	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+	subject := codec.Subject(topic, false)
+
+	// the encoder registers the schema, the codec encodes with the latest schema of the subject
+	encoder, err := kafkaavro.NewEncoder(*client, true, subject, roundTripSchema)
+	if err != nil {
+		t.Fatalf("failed to create the encoder: %v", err)
+	}
+
+	sent := []map[string]interface{}{
+		{"id": int64(1), "customer": "alice", "total": 12.5},
+		{"id": int64(2), "customer": "bob", "total": 100.0},
+	}
+
+	encoded, err := encoder.Encode(sent[0])
+	if err != nil {
+		t.Fatalf("failed to encode with the encoder: %v", err)
+	}
+	messages := []*kafka.Message{{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          encoded,
+	}}
+
+	m, err := confluent.NewMessage(codec, topic, []byte("2"), sent[1])
+	if err != nil {
+		t.Fatalf("failed to encode with the codec: %v", err)
+	}
+	messages = append(messages, m)
+
+	produce(t, env.Brokers, messages)
+
+	received := consume(t, env.Brokers, topic, len(sent))
+	for i, m := range received {
+		native, err := confluent.DecodeValue(codec, m)
+		if err != nil {
+			t.Fatalf("failed to decode message %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(native, sent[i]) {
+			t.Errorf("message %d: got %v, expected %v", i, native, sent[i])
+		}
+	}
+}
+
+func produce(t *testing.T, brokers string, messages []*kafka.Message) {
+
+	t.Helper()
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": brokers})
+	if err != nil {
+		t.Fatalf("failed to create the producer: %v", err)
+	}
+	defer producer.Close()
+
+	deliveries := make(chan kafka.Event, len(messages))
+	for _, m := range messages {
+		if err = producer.Produce(m, deliveries); err != nil {
+			t.Fatalf("failed to produce: %v", err)
+		}
+	}
+	for range messages {
+		delivered := (<-deliveries).(*kafka.Message)
+		if delivered.TopicPartition.Error != nil {
+			t.Fatalf("failed to deliver %v: %v", delivered.TopicPartition, delivered.TopicPartition.Error)
+		}
+	}
+}
+
+func consume(t *testing.T, brokers string, topic string, count int) (messages []*kafka.Message) {
+
+	t.Helper()
+
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": brokers,
+		"group.id":          "kafkaavro-integration",
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		t.Fatalf("failed to create the consumer: %v", err)
+	}
+	defer consumer.Close()
+
+	if err = consumer.Assign([]kafka.TopicPartition{{Topic: &topic, Partition: 0, Offset: kafka.OffsetBeginning}}); err != nil {
+		t.Fatalf("failed to assign %v: %v", topic, err)
+	}
+
+	deadline := time.Now().Add(time.Minute)
+	for len(messages) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("consumed %d of %d messages before the deadline", len(messages), count)
+		}
+		m, readErr := consumer.ReadMessage(time.Second)
+		if readErr != nil {
+			if kafkaErr, ok := readErr.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+			t.Fatalf("failed to consume: %v", readErr)
+		}
+		messages = append(messages, m)
+	}
+	return
+}
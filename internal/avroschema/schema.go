@@ -0,0 +1,257 @@
+// Package avroschema parses avro schemas into a tree of nodes, for the packages which walk the
+// schema next to the data goavro decodes.
+package avroschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Node is a parsed avro schema. Named types are parsed once, references to them share the node,
+// so the nodes of a recursive schema form a cycle.
+type Node struct {
+	Type    string // a primitive type, record, enum, array, map, fixed or union
+	Name    string // the full name of a record, enum or fixed
+	Logical string
+	Doc     string
+
+	Fields   []Field
+	Symbols  []string
+	Items    *Node
+	Values   *Node
+	Branches []*Node
+
+	// DefaultSymbol is the default of an enum, which replaces the symbols the reader does not know.
+	DefaultSymbol string
+	Aliases       []string
+	Size          int // of a fixed
+	Precision     int // of a decimal
+	Scale         int
+}
+
+// Field is a field of a record.
+type Field struct {
+	Name       string
+	Doc        string
+	Node       *Node
+	Aliases    []string
+	HasDefault bool
+	Default    interface{}
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+// goavroLogicalTypes are the logical types which goavro converts, the native values of the other
+// logical types are those of the underlying type.
+var goavroLogicalTypes = map[string]bool{
+	"int.date": true, "int.time-millis": true, "long.time-micros": true,
+	"long.timestamp-millis": true, "long.timestamp-micros": true, "bytes.decimal": true, "fixed.decimal": true,
+}
+
+// IsPrimitive returns whether the type is one of the primitive avro types.
+func IsPrimitive(typ string) bool {
+	return primitives[typ]
+}
+
+// UnknownTypeError is the error of a reference to a type which is not defined (yet).
+type UnknownTypeError string
+
+func (e UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown type %q", string(e))
+}
+
+// Parser parses schemas which may reference the named types of other schemas.
+type Parser struct {
+	// Known are the named types of other schemas which the schema may reference.
+	Known map[string]*Node
+	// Definitions are the named types of the parsed schemas in the order they are defined.
+	Definitions []*Node
+
+	named map[string]*Node
+}
+
+// Parse parses the avro schema, the schema must be valid for goavro.
+func Parse(schema string) (n *Node, err error) {
+	return new(Parser).Parse(schema)
+}
+
+// Parse parses the avro schema, references to undefined types resolve to the Known types.
+func (p *Parser) Parse(schema string) (n *Node, err error) {
+
+	var document interface{}
+	if err = json.Unmarshal([]byte(schema), &document); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	p.named = make(map[string]*Node)
+	return p.parse(document, "")
+}
+
+func (p *Parser) parse(schema interface{}, namespace string) (n *Node, err error) {
+
+	switch s := schema.(type) {
+
+	case string:
+		if primitives[s] {
+			return &Node{Type: s}, nil
+		}
+		if n = p.named[FullName(s, namespace)]; n == nil {
+			n = p.named[s]
+		}
+		if n == nil {
+			if n = p.Known[FullName(s, namespace)]; n == nil {
+				n = p.Known[s]
+			}
+		}
+		if n == nil {
+			return nil, UnknownTypeError(s)
+		}
+		return
+
+	case []interface{}:
+		n = &Node{Type: "union"}
+		for _, branch := range s {
+			b, branchErr := p.parse(branch, namespace)
+			if branchErr != nil {
+				return nil, branchErr
+			}
+			n.Branches = append(n.Branches, b)
+		}
+		return
+
+	case map[string]interface{}:
+		return p.parseComplex(s, namespace)
+	}
+	return nil, fmt.Errorf("invalid schema %v", schema)
+}
+
+func (p *Parser) parseComplex(s map[string]interface{}, namespace string) (n *Node, err error) {
+
+	typ, _ := s["type"].(string)
+	n = &Node{Type: typ, Size: intAttribute(s, "size"), Precision: intAttribute(s, "precision"), Scale: intAttribute(s, "scale")}
+	n.Logical, _ = s["logicalType"].(string)
+	n.Doc, _ = s["doc"].(string)
+
+	switch typ {
+
+	case "record", "error", "enum", "fixed":
+		name, _ := s["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("a %v without a name", typ)
+		}
+		if ns, found := s["namespace"].(string); found {
+			namespace = ns
+		}
+		n.Name = FullName(name, namespace)
+		if i := strings.LastIndex(n.Name, "."); i >= 0 {
+			namespace = n.Name[:i]
+		}
+		p.named[n.Name] = n
+		p.Definitions = append(p.Definitions, n)
+		for _, alias := range stringsAttribute(s, "aliases") {
+			n.Aliases = append(n.Aliases, FullName(alias, namespace))
+		}
+
+	case "array":
+		n.Items, err = p.parse(s["items"], namespace)
+		return
+
+	case "map":
+		n.Values, err = p.parse(s["values"], namespace)
+		return
+
+	default:
+		if !primitives[typ] {
+			// {"type": {...}} or a reference to a named type
+			return p.parse(s["type"], namespace)
+		}
+		return
+	}
+
+	switch typ {
+	case "enum":
+		n.Symbols = stringsAttribute(s, "symbols")
+		n.DefaultSymbol, _ = s["default"].(string)
+	case "record", "error":
+		n.Type = "record"
+		fields, _ := s["fields"].([]interface{})
+		for _, f := range fields {
+			attributes, _ := f.(map[string]interface{})
+			name, _ := attributes["name"].(string)
+			fieldNode, fieldErr := p.parse(attributes["type"], namespace)
+			if fieldErr != nil {
+				return nil, fmt.Errorf("field %v of %v: %w", name, n.Name, fieldErr)
+			}
+			doc, _ := attributes["doc"].(string)
+			defaultValue, hasDefault := attributes["default"]
+			n.Fields = append(n.Fields, Field{Name: name, Doc: doc, Node: fieldNode, Aliases: stringsAttribute(attributes, "aliases"),
+				HasDefault: hasDefault, Default: defaultValue})
+		}
+	}
+	return
+}
+
+func intAttribute(s map[string]interface{}, key string) int {
+	value, _ := s[key].(float64)
+	return int(value)
+}
+
+func stringsAttribute(s map[string]interface{}, key string) (values []string) {
+	list, _ := s[key].([]interface{})
+	for _, v := range list {
+		if v, ok := v.(string); ok {
+			values = append(values, v)
+		}
+	}
+	return
+}
+
+// FullName returns the name qualified with the namespace, unless it is qualified already.
+func FullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// GoavroLogical returns whether goavro converts the logical type of the node.
+func (n *Node) GoavroLogical() bool {
+	return goavroLogicalTypes[n.Type+"."+n.Logical]
+}
+
+// UnionName is the name with which goavro identifies the branch of a union.
+func (n *Node) UnionName() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	if n.GoavroLogical() {
+		return n.Type + "." + n.Logical
+	}
+	return n.Type
+}
+
+// Branch returns the branch of the union with the name goavro gives it.
+func (n *Node) Branch(name string) *Node {
+	for _, b := range n.Branches {
+		if b.UnionName() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Nullable returns the other branch of a union of null and one other type.
+func (n *Node) Nullable() (other *Node, ok bool) {
+	if n.Type != "union" || len(n.Branches) != 2 {
+		return nil, false
+	}
+	if n.Branches[0].Type == "null" && n.Branches[1].Type != "null" {
+		return n.Branches[1], true
+	}
+	if n.Branches[1].Type == "null" && n.Branches[0].Type != "null" {
+		return n.Branches[0], true
+	}
+	return nil, false
+}
@@ -0,0 +1,66 @@
+package avroschema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	n, err := Parse(`{"type":"record","name":"Node","namespace":"com.example","aliases":["Tree"],"fields":[
+		{"name":"value","type":{"type":"bytes","logicalType":"decimal","precision":9,"scale":2},"doc":"the value"},
+		{"name":"next","type":["null","Node"],"default":null},
+		{"name":"children","type":{"type":"array","items":"com.example.Node"},"aliases":["kids"]},
+		{"name":"color","type":{"type":"enum","name":"Color","symbols":["RED","GREEN"],"default":"RED"}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Type != "record" || n.Name != "com.example.Node" || !reflect.DeepEqual(n.Aliases, []string{"com.example.Tree"}) {
+		t.Fatalf("Parse() returned a %v %v with aliases %v", n.Type, n.Name, n.Aliases)
+	}
+	value, next, children, color := n.Fields[0], n.Fields[1], n.Fields[2], n.Fields[3]
+	if value.Doc != "the value" || value.Node.Precision != 9 || value.Node.Scale != 2 || value.Node.UnionName() != "bytes.decimal" {
+		t.Errorf("the decimal field is %+v", value.Node)
+	}
+	if !next.HasDefault || next.Default != nil || next.Node.Branch("com.example.Node") != n {
+		t.Errorf("the recursive branch is not the record")
+	}
+	if other, ok := next.Node.Nullable(); !ok || other != n {
+		t.Errorf("Nullable() returned %v, %v", other, ok)
+	}
+	if children.Node.Items != n || !reflect.DeepEqual(children.Aliases, []string{"kids"}) {
+		t.Errorf("the items of the array are not the record")
+	}
+	if !reflect.DeepEqual(color.Node.Symbols, []string{"RED", "GREEN"}) || color.Node.DefaultSymbol != "RED" {
+		t.Errorf("the enum is %+v", color.Node)
+	}
+}
+
+func TestParserKnown(t *testing.T) {
+
+	p := new(Parser)
+	address, err := p.Parse(`{"type":"record","name":"Address","namespace":"com.example","fields":[{"name":"city","type":"string"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p = &Parser{Known: map[string]*Node{address.Name: address}}
+	customer, err := p.Parse(`{"type":"record","name":"com.example.Customer","fields":[{"name":"address","type":"Address"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.Fields[0].Node != address {
+		t.Errorf("the reference did not resolve to the known type")
+	}
+	if len(p.Definitions) != 1 || p.Definitions[0] != customer {
+		t.Errorf("Definitions are %v, want the customer", p.Definitions)
+	}
+
+	_, err = Parse(`{"type":"record","name":"Customer","fields":[{"name":"address","type":"Address"}]}`)
+	var unknown UnknownTypeError
+	if !errors.As(err, &unknown) || unknown != "Address" {
+		t.Errorf("Parse() of an unknown reference returned %v", err)
+	}
+}
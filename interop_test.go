@@ -0,0 +1,341 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// interopFixtures are synthetic messages in the framing and binary encoding of the Java
+// KafkaAvroSerializer, see testdata/interop/README.md. A fixture is deterministic when our encoding
+// of the expected value must match it byte for byte, goavro writes the entries of a map in a random
+// order.
+var interopFixtures = []struct {
+	name          string
+	deterministic bool
+}{
+	{"nested_record", true},
+	{"unions", true},
+	{"logical_types", true},
+	{"enums", true},
+	{"arrays_maps", false},
+}
+
+type interopFixture struct {
+	schema   string
+	message  []byte
+	expected []byte
+}
+
+func readInteropFixture(t *testing.T, name string) (fixture interopFixture) {
+
+	t.Helper()
+
+	dir := filepath.Join("testdata", "interop", name)
+	schema, err := os.ReadFile(filepath.Join(dir, "schema.avsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture.message, err = os.ReadFile(filepath.Join(dir, "message.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if fixture.expected, err = os.ReadFile(filepath.Join(dir, "expected.json")); err != nil {
+		t.Fatal(err)
+	}
+	fixture.schema = string(schema)
+	return
+}
+
+// newInteropCodec returns a codec which knows the schema of the fixture under its id, as the
+// latest schema of the value subject of the topic.
+func newInteropCodec(t *testing.T, topic string, fixture interopFixture) (codec *Codec, avroCodec *goavro.Codec) {
+
+	t.Helper()
+
+	schemaID, err := parseHeader(fixture.message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := &fakeRegistry{
+		schemas:  map[int]string{int(schemaID): fixture.schema},
+		subjects: map[string][]int{topic + "-value": {int(schemaID)}},
+	}
+	if avroCodec, err = goavro.NewCodec(fixture.schema); err != nil {
+		t.Fatal(err)
+	}
+	return NewCodec(registry, TopicNameStrategy{}), avroCodec
+}
+
+func jsonEqual(t *testing.T, got []byte, want []byte) bool {
+
+	t.Helper()
+
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid json %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("invalid json %s: %v", want, err)
+	}
+	return reflect.DeepEqual(gotValue, wantValue)
+}
+
+func TestInteropDecode(t *testing.T) {
+
+	for _, test := range interopFixtures {
+		t.Run(test.name, func(t *testing.T) {
+
+			fixture := readInteropFixture(t, test.name)
+			codec, avroCodec := newInteropCodec(t, test.name, fixture)
+
+			native, err := codec.Decode(test.name, false, fixture.message)
+			if err != nil {
+				t.Fatalf("Decode() failed: %v", err)
+			}
+
+			textual, err := avroCodec.TextualFromNative(nil, native)
+			if err != nil {
+				t.Fatalf("TextualFromNative() failed: %v", err)
+			}
+			if !jsonEqual(t, textual, fixture.expected) {
+				t.Errorf("Decode() returned %s, want %s", textual, fixture.expected)
+			}
+		})
+	}
+}
+
+func TestInteropEncode(t *testing.T) {
+
+	for _, test := range interopFixtures {
+		t.Run(test.name, func(t *testing.T) {
+
+			fixture := readInteropFixture(t, test.name)
+			codec, avroCodec := newInteropCodec(t, test.name, fixture)
+
+			native, _, err := avroCodec.NativeFromTextual(fixture.expected)
+			if err != nil {
+				t.Fatalf("NativeFromTextual() failed: %v", err)
+			}
+
+			data, err := codec.Encode(test.name, false, native)
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+
+			if len(data) < headerSize || !bytes.Equal(data[:headerSize], fixture.message[:headerSize]) {
+				t.Fatalf("Encode() wrote header % x, want % x", data[:min(len(data), headerSize)], fixture.message[:headerSize])
+			}
+
+			got, _, err := avroCodec.NativeFromBinary(data[headerSize:])
+			if err != nil {
+				t.Fatalf("the encoded body does not decode: %v", err)
+			}
+			want, _, err := avroCodec.NativeFromBinary(fixture.message[headerSize:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Encode() wrote a body which decodes to %v, want %v", got, want)
+			}
+
+			if test.deterministic && !bytes.Equal(data, fixture.message) {
+				t.Errorf("Encode() wrote % x, want % x", data, fixture.message)
+			}
+		})
+	}
+}
+
+// interopSchema is the schema of the compatibility matrix, in the form of the Schema.toString()
+// the Java serializer sends: this package sends the schema as it is given, the requests only
+// match byte for byte for a schema in that form. The registry parses the schemas before
+// comparing them, so the lookups match either way.
+const interopSchema = `{"type":"record","name":"Order","namespace":"com.example","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"],"default":null}]}`
+
+// interopMatrix is the compatibility matrix with the Java KafkaAvroSerializer 7.x: for every
+// subject name strategy, auto.register.schemas (or use.latest.version) and key or value,
+// testdata/interop/expected_requests.json holds the registry requests this package must make under
+// the name of the combination, with the responses of the registry, and the value must be framed
+// with the same schema id. The requests are written by hand from those of the Java RestService,
+// they are not captured from the serializer. A combination which this package does not support
+// documents why and how to get the same interaction, and is checked to fail loudly.
+var interopMatrix = []struct {
+	name         string
+	strategy     string
+	autoRegister bool
+	isKey        bool
+	subject      SubjectName
+	// codec encodes with the Codec, as with use.latest.version, instead of an Encoder
+	codec bool
+	// unsupported is the divergence of a combination which this package does not support
+	unsupported string
+}{
+	{name: "topic-auto-register-value", strategy: "TopicNameStrategy", autoRegister: true, subject: "orders-value"},
+	{name: "topic-auto-register-key", strategy: "TopicNameStrategy", autoRegister: true, isKey: true, subject: "orders-key"},
+	{name: "topic-lookup-value", strategy: "TopicNameStrategy", subject: "orders-value"},
+	{name: "topic-lookup-key", strategy: "TopicNameStrategy", isKey: true, subject: "orders-key"},
+	{name: "topic-use-latest-value", strategy: "TopicNameStrategy", subject: "orders-value", codec: true},
+	{name: "topic-use-latest-key", strategy: "TopicNameStrategy", isKey: true, subject: "orders-key", codec: true},
+	// the subject of the RecordNameStrategy is the full name of the record, for keys and values:
+	// the SubjectNameStrategy of the Codec only sees the topic, pass the subject to NewEncoder
+	{name: "record-auto-register-value", strategy: "RecordNameStrategy", autoRegister: true, subject: "com.example.Order"},
+	{name: "record-auto-register-key", strategy: "RecordNameStrategy", autoRegister: true, isKey: true, subject: "com.example.Order"},
+	{name: "record-lookup-value", strategy: "RecordNameStrategy", subject: "com.example.Order"},
+	{name: "record-lookup-key", strategy: "RecordNameStrategy", isKey: true, subject: "com.example.Order"},
+	{name: "record-use-latest-value", strategy: "RecordNameStrategy", codec: true,
+		unsupported: "the Codec encodes with the latest schema of the subject of the topic, it can not know the record: NewCodecFromConfig rejects the RecordNameStrategy"},
+	{name: "topic-codec-auto-register-value", strategy: "TopicNameStrategy", autoRegister: true, codec: true,
+		unsupported: "the Codec encodes with the registered schemas, it does not register: NewCodecFromConfig rejects auto.register.schemas=true, use the Encoder"},
+}
+
+// javaOnlyParameters are the query parameters the Java client sends and this package does not,
+// with why that does not matter.
+var javaOnlyParameters = map[string]string{
+	"normalize": "false is the default of the registry, this package only sends normalize=true (see UsingNormalize)",
+	"deleted":   "false is the default of the registry, a lookup does not match soft deleted schemas either way",
+}
+
+// expectedInteraction is a registry request the Java client makes and the response of the registry.
+type expectedInteraction struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query"`
+	ContentType string          `json:"contentType"`
+	Body        json.RawMessage `json:"body"`
+	Status      int             `json:"status"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// newReplayRegistry returns a registry which compares the requests to the interactions, in order,
+// and responds with their responses.
+func newReplayRegistry(t *testing.T, interactions []expectedInteraction) (server *httptest.Server, replayed func() int) {
+
+	var next atomic.Int64
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		i := int(next.Add(1)) - 1
+		if i >= len(interactions) {
+			t.Errorf("request %d %v %v is not expected", i, r.Method, r.URL)
+			http.Error(w, `{"error_code":50001,"message":"not expected"}`, http.StatusInternalServerError)
+			return
+		}
+		expected := interactions[i]
+
+		if r.Method != expected.Method || r.URL.Path != expected.Path {
+			t.Errorf("request %d is %v %v, want %v %v", i, r.Method, r.URL.Path, expected.Method, expected.Path)
+		}
+		javaQuery, _ := url.ParseQuery(expected.Query)
+		for key, values := range r.URL.Query() {
+			if !reflect.DeepEqual(values, javaQuery[key]) {
+				t.Errorf("request %d has the query parameter %v=%v, want %v", i, key, values, javaQuery[key])
+			}
+		}
+		for key := range javaQuery {
+			if _, sent := r.URL.Query()[key]; !sent && javaOnlyParameters[key] == "" {
+				t.Errorf("request %d does not have the expected query parameter %v", i, key)
+			}
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if len(expected.Body) > 0 && string(expected.Body) != "null" {
+			// the Java client sets the content type of requests without a body as well
+			if contentType := r.Header.Get("Content-Type"); contentType != expected.ContentType {
+				t.Errorf("request %d has the content type %v, want %v", i, contentType, expected.ContentType)
+			}
+			if !jsonEqual(t, body, expected.Body) {
+				t.Errorf("request %d has the body %s, want %s", i, body, expected.Body)
+			}
+		} else if len(body) > 0 {
+			t.Errorf("request %d has the body %s, want none", i, body)
+		}
+
+		w.Header().Set("Content-Type", expected.ContentType)
+		w.WriteHeader(expected.Status)
+		w.Write(expected.Response)
+	}))
+	return server, func() int { return int(next.Load()) }
+}
+
+func TestInteropMatrix(t *testing.T) {
+
+	requests, err := os.ReadFile(filepath.Join("testdata", "interop", "expected_requests.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var interactions map[string][]expectedInteraction
+	if err = json.Unmarshal(requests, &interactions); err != nil {
+		t.Fatal(err)
+	}
+
+	value := map[string]interface{}{"id": int64(42), "note": Union("string", "x")}
+	// what the Java serializer writes for the value with schema id 201
+	javaMessage := []byte{0, 0, 0, 0, 201, 0x54, 2, 2, 'x'}
+
+	for _, test := range interopMatrix {
+		t.Run(test.name, func(t *testing.T) {
+
+			if test.unsupported != "" {
+				props := map[string]string{ConfigRegistryURL: "http://localhost:1"}
+				if test.strategy == "RecordNameStrategy" {
+					props[ConfigSubjectNameStrategy] = "io.confluent.kafka.serializers.subject.RecordNameStrategy"
+				}
+				if test.autoRegister {
+					props[ConfigAutoRegisterSchemas] = "true"
+				}
+				if _, err := NewCodecFromConfig(props); !errors.Is(err, ErrInvalidConfig) {
+					t.Errorf("NewCodecFromConfig() returned %v, want ErrInvalidConfig as %v", err, test.unsupported)
+				}
+				return
+			}
+
+			expected, found := interactions[test.name]
+			if !found {
+				t.Fatalf("no requests are expected for %v", test.name)
+			}
+			server, replayed := newReplayRegistry(t, expected)
+			defer server.Close()
+			client, err := schemaregistry.NewClient(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var data []byte
+			if test.codec {
+				codec := NewCodec(client, TopicNameStrategy{})
+				if subject := codec.Subject("orders", test.isKey); subject != test.subject {
+					t.Errorf("the subject is %v, the Java serializer uses %v", subject, test.subject)
+				}
+				data, err = codec.Encode("orders", test.isKey, value)
+			} else {
+				if test.strategy == "TopicNameStrategy" && (TopicNameStrategy{}).GetSubjectName("orders", test.isKey) != test.subject {
+					t.Errorf("the TopicNameStrategy does not return the subject %v of the Java serializer", test.subject)
+				}
+				encoder, encoderErr := NewEncoder(*client, test.autoRegister, test.subject, interopSchema)
+				if encoderErr != nil {
+					t.Fatalf("NewEncoder() failed: %v", encoderErr)
+				}
+				data, err = encoder.Encode(value)
+			}
+			if err != nil {
+				t.Fatalf("encoding failed: %v", err)
+			}
+			if !bytes.Equal(data, javaMessage) {
+				t.Errorf("encoded % x, the Java serializer writes % x", data, javaMessage)
+			}
+			if replayed() != len(expected) {
+				t.Errorf("made %d registry requests, the Java client makes %d", replayed(), len(expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package kafkaavro
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// JSONMode is the JSON encoding of the avro values of DecodeToJSON and Encoder.EncodeTextual,
+// see WithJSONMode.
+type JSONMode string
+
+const (
+	// JSONModeAvro is the JSON encoding of the avro specification, the default: a union value
+	// other than null is an object of the name of its branch to the value, e.g. {"string":"x"},
+	// and bytes are strings of which the characters are the bytes. It is lossless, the JSON
+	// encodes back to the same avro data.
+	JSONModeAvro JSONMode = "avro"
+	// JSONModeStandard is plain JSON, a union value is the bare value, e.g. "x", as most consumers
+	// of JSON expect. It is lossy for the unions of which the JSON of a value is the JSON of a
+	// value of several branches: the value is encoded as the first of those branches, e.g. 1 is
+	// the int of ["int","long"] and of ["int","string"], "1" the string of ["int","string"].
+	JSONModeStandard JSONMode = "standard"
+)
+
+// ParseJSONMode parses avro or standard, e.g. the value of a flag.
+func ParseJSONMode(mode string) (JSONMode, error) {
+	switch JSONMode(mode) {
+	case JSONModeAvro, JSONModeStandard:
+		return JSONMode(mode), nil
+	}
+	return "", fmt.Errorf("unsupported JSON mode %q, use %v or %v", mode, JSONModeAvro, JSONModeStandard)
+}
+
+// WithJSONMode sets the JSON encoding of DecodeToJSON and of Encoder.EncodeTextual (see
+// NewEncoder), JSONModeAvro by default. The standard JSON is decoded and encoded with the
+// standard JSON codecs of goavro.
+func WithJSONMode(mode JSONMode) Option {
+	return func(c *Codec) {
+		c.jsonMode = mode
+	}
+}
+
+// DecodeToJSON decodes the data like Decode and returns the value as JSON, in the encoding of
+// WithJSONMode. The logical types and enums of WithLogicalTypes and WithEnums do not apply, the
+// JSON encodings have their own representation of them. Only avro data decodes to JSON. The NaN
+// and infinite floats fail unless WithNonFiniteFloats substitutes them. The longs are exact, parse
+// the JSON with ParseJSON to keep them so, see there for the numbers of every avro primitive.
+func (c *Codec) DecodeToJSON(topic string, isKey bool, data []byte) (textual []byte, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		textual, err = r.DecodeToJSON(topic, isKey, data)
+		return textual, r.registryError(err)
+	}
+
+	var schemaID SchemaID
+	defer func() {
+		if err != nil {
+			err = c.decodeFailed(topic, schemaID, data, err)
+		}
+		c.topicDecoded(topic, isKey, schemaID, data, err)
+		if c.metrics != nil {
+			c.metrics.Decoded(err)
+		}
+	}()
+
+	if err = c.checkPayloadSize(data); err != nil {
+		return
+	}
+	schemaID, codec, _, err := c.codecFor(context.Background(), topic, data)
+	if err != nil {
+		return
+	}
+	native, err := c.decodeLimited(codec, data[headerSize:])
+	if err != nil {
+		return
+	}
+	jsonCodec, err := c.jsonCodec(codec)
+	if err != nil {
+		return
+	}
+	floats, err := floatSchemaOf(codec, &c.floatSchemas)
+	if err != nil {
+		return
+	}
+	if floats != nil {
+		textual, err = floats.textualFromNative(jsonCodec, native, c.nonFinite, c.jsonMode == JSONModeStandard)
+	} else {
+		textual, err = jsonCodec.TextualFromNative(nil, native)
+	}
+	if err != nil {
+		if _, ok := err.(*FieldError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+	}
+	c.observe(topic, isKey, schemaID, codec.Schema(), schemaregistry.SchemaTypeAvro)
+	return
+}
+
+// jsonCodec returns the codec of the JSON mode for the codec of a schema.
+func (c *Codec) jsonCodec(codec *goavro.Codec) (jsonCodec *goavro.Codec, err error) {
+
+	if c.jsonMode != JSONModeStandard {
+		return codec, nil
+	}
+	if jsonCodec, found := c.standardJSONCodecs.get(codec); found {
+		return jsonCodec, nil
+	}
+	if jsonCodec, err = goavro.NewCodecForStandardJSONFull(codec.Schema()); err != nil {
+		return nil, fmt.Errorf("%w for standard JSON: %w", ErrCodecBuild, err)
+	}
+	c.standardJSONCodecs.put(codec, jsonCodec)
+	return
+}
+
+// EncodeJSON encodes the JSON, in the encoding of WithJSONMode, with the latest schema of the
+// subject, the inverse of DecodeToJSON. The strings of the NaN and infinite floats of
+// WithNonFiniteFloats are accepted. Only subjects of which the latest schema is an avro schema
+// are encoded.
+func (c *Codec) EncodeJSON(subjectName SubjectName, textual []byte) (data []byte, schemaID SchemaID, err error) {
+
+	if r := c.registryCodec(nil); r != c {
+		data, schemaID, err = r.EncodeJSON(subjectName, textual)
+		return data, schemaID, r.registryError(err)
+	}
+
+	defer func() {
+		if c.metrics != nil {
+			c.metrics.Encoded(err)
+		}
+	}()
+
+	schema, _, err := c.encoderSchemaFor(context.Background(), "", subjectName)
+	if err != nil {
+		return
+	}
+	if schema.codec == nil {
+		return nil, schema.schemaID, fmt.Errorf("%w: the latest schema %d of subject %v is not an avro schema", ErrUnsupportedSchemaType, schema.schemaID, subjectName)
+	}
+	jsonCodec, err := c.jsonCodec(schema.codec)
+	if err != nil {
+		return
+	}
+	floats, err := floatSchemaOf(schema.codec, &c.floatSchemas)
+	if err != nil {
+		return
+	}
+	var native interface{}
+	if floats != nil {
+		native, err = floats.nativeFromTextual(jsonCodec, textual, c.jsonMode == JSONModeStandard)
+	} else {
+		native, _, err = jsonCodec.NativeFromTextual(textual)
+	}
+	if err != nil {
+		return nil, schema.schemaID, err
+	}
+	data, err = encodeFramed(schema.header, schema.codec, schema.sizeHint, native)
+	return data, schema.schemaID, err
+}
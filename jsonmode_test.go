@@ -0,0 +1,150 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const unionsSchema = `{"type":"record","name":"event","fields":[{"name":"note","type":["null","string"]},{"name":"value","type":["int","string"]}]}`
+
+var unionsTests = []struct {
+	name     string
+	native   map[string]interface{}
+	avro     string
+	standard string
+}{
+	{"null and int",
+		map[string]interface{}{"note": nil, "value": map[string]interface{}{"int": int32(1)}},
+		`{"note":null,"value":{"int":1}}`,
+		`{"note":null,"value":1}`},
+	{"string and string of a number",
+		map[string]interface{}{"note": map[string]interface{}{"string": "x"}, "value": map[string]interface{}{"string": "1"}},
+		`{"note":{"string":"x"},"value":{"string":"1"}}`,
+		`{"note":"x","value":"1"}`},
+}
+
+func TestDecodeToJSON(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("events-value", unionsSchema)
+
+	for _, mode := range []JSONMode{JSONModeAvro, JSONModeStandard} {
+		codec := NewCodec(registry, TopicNameStrategy{}, WithJSONMode(mode))
+		for _, test := range unionsTests {
+			data, err := codec.Encode("events", false, test.native)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := test.avro
+			if mode == JSONModeStandard {
+				want = test.standard
+			}
+			if textual, err := codec.DecodeToJSON("events", false, data); err != nil || !equalJSON(t, textual, want) {
+				t.Errorf("%v: DecodeToJSON() in %v mode returned %s, %v, want %s", test.name, mode, textual, err, want)
+			}
+		}
+	}
+
+	codec := NewCodec(registry, TopicNameStrategy{})
+	if _, err := codec.DecodeToJSON("events", false, []byte{1, 0}); !errors.Is(err, ErrPayloadTooShort) {
+		t.Errorf("DecodeToJSON() of a short payload returned %v", err)
+	}
+}
+
+// equalJSON returns whether the JSON is the JSON of want, the fields of records are in any order.
+func equalJSON(t *testing.T, textual []byte, want string) bool {
+	var got, wanted interface{}
+	if err := json.Unmarshal(textual, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", textual, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wanted); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(got, wanted)
+}
+
+func TestEncodeTextualJSONMode(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+	registry := mockregistry.New()
+	registry.Register("ok-value", unionsSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	encoders := make(map[JSONMode]Encoder)
+	for _, mode := range []JSONMode{JSONModeAvro, JSONModeStandard} {
+		encoder, err := NewEncoder(*client, true, "ok-value", unionsSchema, WithJSONMode(mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoders[mode] = encoder
+	}
+
+	for _, test := range unionsTests {
+		for mode, textual := range map[JSONMode]string{JSONModeAvro: test.avro, JSONModeStandard: test.standard} {
+			data, err := encoders[mode].EncodeTextual([]byte(textual))
+			if err != nil {
+				t.Errorf("%v: EncodeTextual(%s) in %v mode failed: %v", test.name, textual, mode, err)
+				continue
+			}
+			if native, err := codec.Decode("ok", false, data); err != nil || !reflect.DeepEqual(native, test.native) {
+				t.Errorf("%v: EncodeTextual(%s) in %v mode encoded %v, %v, want %v", test.name, textual, mode, native, err, test.native)
+			}
+		}
+	}
+
+	// the avro mode requires the branches of the unions, in the standard mode they are inferred
+	if _, err := encoders[JSONModeAvro].EncodeTextual([]byte(`{"note":"x","value":1}`)); err == nil {
+		t.Error("EncodeTextual() of standard JSON in avro mode did not fail")
+	}
+	if _, err := encoders[JSONModeStandard].EncodeTextual([]byte(`{"note":"x","value":true}`)); err == nil {
+		t.Error("EncodeTextual() of a value of no branch in standard mode did not fail")
+	}
+}
+
+func TestParseJSONMode(t *testing.T) {
+	for _, mode := range []string{"avro", "standard"} {
+		if parsed, err := ParseJSONMode(mode); err != nil || string(parsed) != mode {
+			t.Errorf("ParseJSONMode(%v) returned %v, %v", mode, parsed, err)
+		}
+	}
+	if _, err := ParseJSONMode("plain"); err == nil {
+		t.Error("ParseJSONMode(plain) did not fail")
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("events-value", unionsSchema)
+
+	for _, mode := range []JSONMode{JSONModeAvro, JSONModeStandard} {
+		codec := NewCodec(registry, TopicNameStrategy{}, WithJSONMode(mode))
+		for _, test := range unionsTests {
+			textual := test.avro
+			if mode == JSONModeStandard {
+				textual = test.standard
+			}
+			data, schemaID, err := codec.EncodeJSON("events-value", []byte(textual))
+			if err != nil || schemaID != id {
+				t.Errorf("%v: EncodeJSON(%s) in %v mode returned %v, %v", test.name, textual, mode, schemaID, err)
+				continue
+			}
+			if native, err := codec.Decode("events", false, data); err != nil || !reflect.DeepEqual(native, test.native) {
+				t.Errorf("%v: EncodeJSON(%s) in %v mode encoded %v, %v, want %v", test.name, textual, mode, native, err, test.native)
+			}
+		}
+	}
+
+	codec := NewCodec(registry, TopicNameStrategy{})
+	if _, _, err := codec.EncodeJSON("payments-value", []byte(`{}`)); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("EncodeJSON() of an unknown subject returned %v", err)
+	}
+	if _, _, err := codec.EncodeJSON("events-value", []byte(`{"note":null}`)); err == nil {
+		t.Error("EncodeJSON() of a record without a field did not fail")
+	}
+}
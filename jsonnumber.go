@@ -0,0 +1,35 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ParseJSON parses the JSON of DecodeToJSON to the values of json.Unmarshal, except for the
+// numbers, which are json.Number, as json.Unmarshal turns them into float64s, which do not hold
+// the longs beyond 2^53. The numbers of the avro primitives are, in both JSON modes:
+//
+//   - int: a JSON integer, Int64 returns the value, as does Decode as an int32
+//   - long: a JSON integer, Int64 returns every value exactly, as does Decode as an int64
+//   - float: a JSON number, the shortest decimal of the float32 (0.1 for float32(0.1)), Float64
+//     returns a float64 which converts back to the same float32
+//   - double: a JSON number, the shortest decimal of the float64, Float64 returns the value
+//   - the NaN and infinite floats and doubles fail DecodeToJSON, see WithNonFiniteFloats
+//
+// The numbers encode back unchanged, e.g. with json.Marshal or EncodeJSON. The other primitives
+// are the JSON null, booleans and strings, bytes and fixed are strings of which the characters are
+// the bytes. The logical types are the values of their underlying primitive.
+func ParseJSON(textual []byte) (document interface{}, err error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(textual))
+	decoder.UseNumber()
+	if err = decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid JSON: data after the value")
+	}
+	return document, nil
+}
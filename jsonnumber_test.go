@@ -0,0 +1,83 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const numbersSchema = `{"type":"record","name":"numbers","fields":[{"name":"i","type":"int"},{"name":"l","type":"long"},{"name":"f","type":"float"},{"name":"d","type":"double"},{"name":"id","type":["null","long"]}]}`
+
+func TestParseJSON(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("numbers-value", numbersSchema)
+	native := map[string]interface{}{
+		"i":  int32(math.MinInt32),
+		"l":  int64(math.MaxInt64),
+		"f":  float32(0.1),
+		"d":  0.1,
+		"id": map[string]interface{}{"long": int64(1<<53 + 1)},
+	}
+
+	for _, mode := range []JSONMode{JSONModeAvro, JSONModeStandard} {
+		codec := NewCodec(registry, TopicNameStrategy{}, WithJSONMode(mode))
+		data, err := codec.Encode("numbers", false, native)
+		if err != nil {
+			t.Fatal(err)
+		}
+		textual, err := codec.DecodeToJSON("numbers", false, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// decode, JSON, parse
+		parsed, err := ParseJSON(textual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		document := parsed.(map[string]interface{})
+		id := document["id"]
+		if mode == JSONModeAvro {
+			id = id.(map[string]interface{})["long"]
+		}
+		want := map[string]interface{}{"i": json.Number("-2147483648"), "l": json.Number("9223372036854775807"), "f": json.Number("0.1"), "d": json.Number("0.1")}
+		for field, value := range want {
+			if document[field] != value {
+				t.Errorf("%v: ParseJSON() returned %v of %v, want %v", mode, document[field], field, value)
+			}
+		}
+		if n, err := id.(json.Number).Int64(); err != nil || n != 1<<53+1 {
+			t.Errorf("%v: ParseJSON() returned the id %v, %v, want %d", mode, id, err, int64(1<<53+1))
+		}
+		if f, _ := document["f"].(json.Number).Float64(); float32(f) != float32(0.1) {
+			t.Errorf("%v: ParseJSON() returned the float %v", mode, f)
+		}
+
+		// and back to the same avro data
+		remarshaled, err := json.Marshal(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _, err := codec.EncodeJSON("numbers-value", remarshaled)
+		if err != nil || !reflect.DeepEqual(encoded, data) {
+			t.Errorf("%v: EncodeJSON() of the parsed JSON returned %x, %v, want %x", mode, encoded, err, data)
+		}
+
+		// json.Unmarshal does not keep the id
+		var lossy map[string]interface{}
+		json.Unmarshal(textual, &lossy)
+		if mode == JSONModeStandard && lossy["id"] != float64(1<<53) {
+			t.Errorf("json.Unmarshal() returned the id %v", lossy["id"])
+		}
+	}
+
+	for _, invalid := range []string{"", "{", `{"a":1} {}`} {
+		if _, err := ParseJSON([]byte(invalid)); err == nil {
+			t.Errorf("ParseJSON(%q) succeeded", invalid)
+		}
+	}
+}
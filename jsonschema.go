@@ -0,0 +1,132 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// JSONSchemaType is the SchemaType of the JSON schemas, the body of their data is a JSON document:
+//
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(kafkaavro.JSONSchemaType{}))
+//
+// The documents decode to the values of json.Unmarshal, e.g. a map[string]interface{} for an
+// object, and encode from a json.RawMessage or any value json.Marshal encodes. Without a
+// NewValidator, the documents are not validated against the schema.
+type JSONSchemaType struct {
+	// RawMessage decodes the documents to a json.RawMessage instead.
+	RawMessage bool
+	// UseNumber decodes the numbers of the documents to json.Number instead of float64, which
+	// does not hold the integers beyond 2^53, like ParseJSON. The validators get them too.
+	UseNumber bool
+	// NewValidator returns the validator of the documents of a schema, e.g.
+	// kafkaavrojsonschema.NewValidator. The documents are validated on decode and before encoding,
+	// the documents which fail validation fail with ErrSchemaValidation.
+	NewValidator func(schema schemaregistry.Schema, references ReferenceLookup) (validator JSONValidator, err error)
+}
+
+// JSONValidator validates a JSON document, as decoded by json.Unmarshal, against a JSON schema.
+type JSONValidator interface {
+	Validate(document interface{}) error
+}
+
+// Name returns schemaregistry.SchemaTypeJSON.
+func (JSONSchemaType) Name() string {
+	return schemaregistry.SchemaTypeJSON
+}
+
+// NewBodyDecoder returns the decoder of the documents of the schema.
+func (t JSONSchemaType) NewBodyDecoder(schema schemaregistry.Schema, references ReferenceLookup) (decoder BodyDecoder, err error) {
+	return t.newCodec(schema, references)
+}
+
+// NewBodyEncoder returns the encoder of the documents of the schema.
+func (t JSONSchemaType) NewBodyEncoder(schema schemaregistry.Schema, references ReferenceLookup) (encoder BodyEncoder, err error) {
+	return t.newCodec(schema, references)
+}
+
+func (t JSONSchemaType) newCodec(schema schemaregistry.Schema, references ReferenceLookup) (codec jsonCodec, err error) {
+	codec.rawMessage = t.RawMessage
+	codec.useNumber = t.UseNumber
+	if t.NewValidator != nil {
+		codec.validator, err = t.NewValidator(schema, references)
+	}
+	return
+}
+
+type jsonCodec struct {
+	rawMessage bool
+	useNumber  bool
+	validator  JSONValidator
+}
+
+func (c jsonCodec) DecodeBody(body []byte) (native interface{}, err error) {
+
+	if c.rawMessage && c.validator == nil {
+		if !json.Valid(body) {
+			return nil, errors.New("invalid JSON document")
+		}
+		return json.RawMessage(append([]byte(nil), body...)), nil
+	}
+
+	document, err := c.unmarshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.validate(document); err != nil {
+		return
+	}
+	if c.rawMessage {
+		return json.RawMessage(append([]byte(nil), body...)), nil
+	}
+	return document, nil
+}
+
+func (c jsonCodec) AppendBody(data []byte, native interface{}) ([]byte, error) {
+
+	body, isRaw := native.(json.RawMessage)
+	if !isRaw {
+		var err error
+		if body, err = json.Marshal(native); err != nil {
+			return nil, fmt.Errorf("failed to encode the JSON document: %w", err)
+		}
+	}
+
+	if c.validator != nil {
+		document, err := c.unmarshal(body)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.validate(document); err != nil {
+			return nil, err
+		}
+	} else if isRaw && !json.Valid(body) {
+		return nil, errors.New("invalid JSON document")
+	}
+	return append(data, body...), nil
+}
+
+// unmarshal decodes the document, with json.Number numbers if useNumber is set.
+func (c jsonCodec) unmarshal(body []byte) (document interface{}, err error) {
+	if c.useNumber {
+		document, err = ParseJSON(body)
+	} else {
+		err = json.Unmarshal(body, &document)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON document: %w", err)
+	}
+	return
+}
+
+func (c jsonCodec) validate(document interface{}) error {
+	if c.validator == nil {
+		return nil
+	}
+	if err := c.validator.Validate(document); err != nil {
+		return fmt.Errorf("%w: %w", ErrSchemaValidation, err)
+	}
+	return nil
+}
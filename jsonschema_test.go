@@ -0,0 +1,94 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// requiredValidator fails for the objects without the property.
+type requiredValidator string
+
+func (property requiredValidator) Validate(document interface{}) error {
+	if object, ok := document.(map[string]interface{}); !ok || object[string(property)] == nil {
+		return errors.New("missing " + string(property))
+	}
+	return nil
+}
+
+func TestJSONSchemaType(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.RegisterSchema("events-value", schemaregistry.Schema{Schema: `{"type":"object"}`, SchemaType: schemaregistry.SchemaTypeJSON})
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(JSONSchemaType{}))
+	event := map[string]interface{}{"id": "e-1", "count": 2.0}
+	data, err := codec.Encode("events", false, event)
+	if err != nil || string(data) != "\x00\x00\x00\x00\x01"+`{"count":2,"id":"e-1"}` {
+		t.Fatalf("Encode() returned %q, %v", data, err)
+	}
+	if decoded, err := codec.Decode("events", false, data); err != nil || !reflect.DeepEqual(decoded, event) {
+		t.Errorf("Decode() returned %v, %v", decoded, err)
+	}
+	if _, err = codec.Encode("events", false, json.RawMessage(`{"id":`)); err == nil {
+		t.Error("Encode() of an invalid json.RawMessage succeeded")
+	}
+	if _, err = codec.Encode("events", false, func() {}); err == nil {
+		t.Error("Encode() of a func succeeded")
+	}
+
+	raw := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(JSONSchemaType{RawMessage: true}))
+	if decoded, err := raw.Decode("events", false, data); err != nil || !reflect.DeepEqual(decoded, json.RawMessage(data[5:])) {
+		t.Errorf("Decode() to a json.RawMessage returned %v, %v", decoded, err)
+	}
+	if _, err = raw.Decode("events", false, append(data[:5:5], '{')); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("Decode() of invalid JSON to a json.RawMessage returned %v, want ErrMalformedPayload", err)
+	}
+
+	// the integers beyond 2^53 are only exact with UseNumber
+	big := append(data[:5:5], `{"id":9007199254740993}`...)
+	if decoded, err := codec.Decode("events", false, big); err != nil || decoded.(map[string]interface{})["id"] != float64(1<<53) {
+		t.Errorf("Decode() of a large integer returned %v, %v", decoded, err)
+	}
+	numbers := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(JSONSchemaType{UseNumber: true, NewValidator: func(schema schemaregistry.Schema, references ReferenceLookup) (JSONValidator, error) {
+		return requiredValidator("id"), nil
+	}}))
+	decoded, err := numbers.Decode("events", false, big)
+	if want := map[string]interface{}{"id": json.Number("9007199254740993")}; err != nil || !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Decode() with UseNumber returned %#v, %v, want %#v", decoded, err, want)
+	}
+	if encoded, err := numbers.Encode("events", false, decoded); err != nil || string(encoded) != string(big) {
+		t.Errorf("Encode() of the decoded document returned %q, %v", encoded, err)
+	}
+
+	validated := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(JSONSchemaType{
+		NewValidator: func(schema schemaregistry.Schema, references ReferenceLookup) (JSONValidator, error) {
+			return requiredValidator("id"), nil
+		},
+	}))
+	if _, err = validated.Encode("events", false, map[string]interface{}{"count": 1}); !errors.Is(err, ErrSchemaValidation) {
+		t.Errorf("Encode() of an invalid document returned %v, want ErrSchemaValidation", err)
+	}
+	if _, err = validated.Decode("events", false, append(data[:5:5], `{"count":1}`...)); !errors.Is(err, ErrSchemaValidation) {
+		t.Errorf("Decode() of an invalid document returned %v, want ErrSchemaValidation", err)
+	}
+	if decoded, err := validated.Decode("events", false, data); err != nil || !reflect.DeepEqual(decoded, event) {
+		t.Errorf("Decode() of a valid document returned %v, %v", decoded, err)
+	}
+
+	failing := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(JSONSchemaType{
+		NewValidator: func(schema schemaregistry.Schema, references ReferenceLookup) (JSONValidator, error) {
+			return nil, errors.New("invalid schema")
+		},
+	}))
+	if _, err = failing.Encode("events", false, event); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("Encode() with an invalid schema returned %v, want ErrCodecBuild", err)
+	}
+	if _, err = failing.Decode("events", false, data); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("Decode() with an invalid schema returned %v, want ErrCodecBuild", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package kafkaavrohttp serves the decoding and encoding of a kafkaavro.Codec over HTTP, for the
+// systems and scripts which have to decode or encode a message now and then without a schema
+// registry client of their own:
+//
+//	POST /decode?topic=orders[&key=true]   the data of a message in the body, returns its JSON
+//	POST /encode?subject=orders-value      JSON in the body, returns the data of a message
+//
+// The JSON is in the encoding of the kafkaavro.WithJSONMode of the Codec. The responses have the
+// X-Schema-Id header, the decode responses the X-Schema-Subject of the topic as well. Failures
+// are JSON objects with the error and its kafkaavro.ErrorType.
+//
+// Usage:
+//
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{})
+//	http.ListenAndServe(":8080", kafkaavrohttp.NewHandler(codec, kafkaavrohttp.WithBearerToken(token)))
+package kafkaavrohttp
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/timvw/kafkaavro"
+)
+
+// DefaultMaxBodySize is the maximum size of a request body unless WithMaxBodySize is used, the
+// default maximum size of a Kafka message.
+const DefaultMaxBodySize = 1 << 20
+
+// The headers of the responses.
+const (
+	HeaderSchemaID      = "X-Schema-Id"
+	HeaderSchemaSubject = "X-Schema-Subject"
+)
+
+// Option configures the Handler.
+type Option func(h *Handler)
+
+// WithBearerToken makes the Handler refuse the requests without the header
+// "Authorization: Bearer <token>" with 401 Unauthorized.
+func WithBearerToken(token string) Option {
+	return func(h *Handler) {
+		h.token = token
+	}
+}
+
+// WithMaxBodySize makes the Handler refuse request bodies of more than size bytes with 413
+// Request Entity Too Large. The default is DefaultMaxBodySize.
+func WithMaxBodySize(size int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = size
+	}
+}
+
+// Handler serves /decode and /encode with a Codec. It is safe for concurrent use as the Codec is,
+// all the requests share the caches of the Codec.
+type Handler struct {
+	codec       *kafkaavro.Codec
+	token       string
+	maxBodySize int64
+	mux         *http.ServeMux
+}
+
+// NewHandler returns the Handler of the codec.
+func NewHandler(codec *kafkaavro.Codec, opts ...Option) *Handler {
+
+	h := &Handler{codec: codec, maxBodySize: DefaultMaxBodySize, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux.HandleFunc("/decode", h.decode)
+	h.mux.HandleFunc("/encode", h.encode)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if h.token != "" && !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kafkaavro"`)
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request) {
+
+	topic := r.URL.Query().Get("topic")
+	isKey, err := parseBool(r.URL.Query().Get("key"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("key: %w", err))
+		return
+	}
+	data, ok := h.readBody(w, r, topic != "", "topic")
+	if !ok {
+		return
+	}
+
+	textual, err := h.codec.DecodeToJSON(topic, isKey, data)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	schemaID, _, _ := kafkaavro.ParseWireFormat(data)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(HeaderSchemaID, strconv.Itoa(schemaID))
+	w.Header().Set(HeaderSchemaSubject, h.codec.Subject(topic, isKey))
+	w.Write(textual)
+}
+
+func (h *Handler) encode(w http.ResponseWriter, r *http.Request) {
+
+	subject := r.URL.Query().Get("subject")
+	textual, ok := h.readBody(w, r, subject != "", "subject")
+	if !ok {
+		return
+	}
+
+	data, schemaID, err := h.codec.EncodeJSON(subject, textual)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(HeaderSchemaID, strconv.Itoa(schemaID))
+	w.Write(data)
+}
+
+// readBody verifies the method and the required query parameter and reads the body, limited to
+// the maximum body size. It writes the error response and returns false on failure.
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request, hasParameter bool, parameter string) (body []byte, ok bool) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%v is not allowed, use POST", r.Method))
+		return nil, false
+	}
+	if !hasParameter {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("the %v query parameter is required", parameter))
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("the body exceeds the maximum of %d bytes", maxBytesErr.Limit))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read the body: %w", err))
+		return nil, false
+	}
+	return body, true
+}
+
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// statusOf returns the status of the response of an error of the Codec: the errors of the
+// registry are the errors of a gateway, the others are errors of the request.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, kafkaavro.ErrSchemaNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, kafkaavro.ErrRegistryUnavailable):
+		return http.StatusBadGateway
+	case errors.Is(err, kafkaavro.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, kafkaavro.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// errorResponse is the body of a failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+	Type  string `json:"type,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: err.Error(), Type: kafkaavro.ErrorType(err)})
+}
@@ -0,0 +1,154 @@
+package kafkaavrohttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const testSchema = `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]}]}`
+
+func newTestHandler(t *testing.T, opts ...Option) (h *Handler, codec *kafkaavro.Codec, schemaID int) {
+	registry := mockregistry.New()
+	schemaID = registry.Register("orders-value", testSchema)
+	codec = kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	return NewHandler(codec, opts...), codec, schemaID
+}
+
+func serve(h http.Handler, method string, target string, body []byte, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+
+	h, codec, schemaID := newTestHandler(t)
+
+	data, err := codec.Encode("orders", false, map[string]interface{}{"id": int64(1), "note": map[string]interface{}{"string": "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodPost, "/decode?topic=orders", data, "")
+	var decoded interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("/decode returned %d %s: %v", w.Code, w.Body, err)
+	}
+	if want := map[string]interface{}{"id": 1.0, "note": map[string]interface{}{"string": "x"}}; w.Code != http.StatusOK || !reflect.DeepEqual(decoded, want) {
+		t.Errorf("/decode returned %d %s, want %v", w.Code, w.Body, want)
+	}
+	if w.Header().Get(HeaderSchemaID) != strconv.Itoa(schemaID) || w.Header().Get(HeaderSchemaSubject) != "orders-value" {
+		t.Errorf("/decode returned the headers %v", w.Header())
+	}
+
+	w = serve(h, http.MethodPost, "/encode?subject=orders-value", []byte(`{"id":1,"note":{"string":"x"}}`), "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) || w.Header().Get(HeaderSchemaID) != strconv.Itoa(schemaID) {
+		t.Errorf("/encode returned %d %x, want %x", w.Code, w.Body, data)
+	}
+
+	var tests = []struct {
+		name     string
+		method   string
+		target   string
+		body     []byte
+		status   int
+		wantType string
+	}{
+		{"get", http.MethodGet, "/decode?topic=orders", nil, http.StatusMethodNotAllowed, ""},
+		{"no topic", http.MethodPost, "/decode", data, http.StatusBadRequest, ""},
+		{"invalid key", http.MethodPost, "/decode?topic=orders&key=maybe", data, http.StatusBadRequest, ""},
+		{"no subject", http.MethodPost, "/encode", []byte(`{}`), http.StatusBadRequest, ""},
+		{"not in the wire format", http.MethodPost, "/decode?topic=orders", []byte(`{"id":1}`), http.StatusBadRequest, "ErrUnknownMagicByte"},
+		{"unknown schema", http.MethodPost, "/decode?topic=orders", []byte{0, 0, 0, 0, 42, 2}, http.StatusNotFound, "ErrSchemaNotFound"},
+		{"unknown subject", http.MethodPost, "/encode?subject=payments-value", []byte(`{}`), http.StatusNotFound, "ErrSchemaNotFound"},
+		{"invalid JSON", http.MethodPost, "/encode?subject=orders-value", []byte(`{"id":"1"}`), http.StatusBadRequest, ""},
+		{"unknown path", http.MethodPost, "/schemas", nil, http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := serve(h, test.method, test.target, test.body, "")
+		if w.Code != test.status {
+			t.Errorf("%v: %v %v returned %d %s, want %d", test.name, test.method, test.target, w.Code, w.Body, test.status)
+			continue
+		}
+		if test.wantType == "" {
+			continue
+		}
+		var response errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Type != test.wantType || response.Error == "" {
+			t.Errorf("%v: %v %v returned %s, want the error type %v", test.name, test.method, test.target, w.Body, test.wantType)
+		}
+	}
+}
+
+func TestHandlerLimitsAndAuth(t *testing.T) {
+
+	h, codec, _ := newTestHandler(t, WithBearerToken("secret"), WithMaxBodySize(16))
+	data, err := codec.Encode("orders", false, map[string]interface{}{"id": int64(1), "note": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := serve(h, http.MethodPost, "/decode?topic=orders", data, token); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("/decode with the token %q returned %d", token, w.Code)
+		}
+	}
+	if w := serve(h, http.MethodPost, "/decode?topic=orders", data, "secret"); w.Code != http.StatusOK {
+		t.Errorf("/decode with the token returned %d %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodPost, "/encode?subject=orders-value", []byte(`{"id":1,"note":{"string":"more than sixteen bytes"}}`), "secret"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("/encode of a large body returned %d %s", w.Code, w.Body)
+	}
+}
+
+func TestHandlerConcurrentRequests(t *testing.T) {
+
+	h, codec, _ := newTestHandler(t)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			textual := []byte(`{"id":` + strconv.FormatInt(id, 10) + `,"note":null}`)
+			response, err := http.Post(server.URL+"/encode?subject=orders-value", "application/json", bytes.NewReader(textual))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer response.Body.Close()
+			var data bytes.Buffer
+			data.ReadFrom(response.Body)
+			native, err := codec.Decode("orders", false, data.Bytes())
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got := native.(map[string]interface{})["id"]; got != id {
+				errs <- fmt.Errorf("/encode of id %d encoded the id %v", id, got)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
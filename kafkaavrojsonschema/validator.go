@@ -0,0 +1,144 @@
+// Package kafkaavrojsonschema validates the JSON documents of the JSON schemas of a
+// kafkaavro.Codec against their schema, with github.com/santhosh-tekuri/jsonschema:
+//
+//	jsonSchemas := kafkaavro.JSONSchemaType{NewValidator: kafkaavrojsonschema.NewValidator}
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(jsonSchemas))
+//
+// The schemas referenced by a schema are fetched from the registry and resolved by their name,
+// relative to the $id of the schema if it has one. The drafts 4, 6, 7, 2019-09 and 2020-12 are
+// supported, the schemas without $schema are 2020-12.
+package kafkaavrojsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// schemaURL is the url of the schema when it is compiled, for the references relative to it.
+const schemaURL = "mem://kafkaavro/schema.json"
+
+// Validator validates the documents of a JSON schema, it is safe for concurrent use.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// NewValidator compiles the JSON schema and the schemas it references, which are fetched with the
+// lookup. It is the NewValidator of a kafkaavro.JSONSchemaType.
+func NewValidator(schema schemaregistry.Schema, references kafkaavro.ReferenceLookup) (validator kafkaavro.JSONValidator, err error) {
+
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource(schemaURL, strings.NewReader(schema.Schema)); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	bases := []string{schemaURL}
+	var identified struct {
+		ID string `json:"$id"`
+	}
+	if json.Unmarshal([]byte(schema.Schema), &identified) == nil && identified.ID != "" {
+		bases = append(bases, identified.ID)
+	}
+	if err = addReferences(compiler, bases, schema.References, references, make(map[string]bool)); err != nil {
+		return
+	}
+
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return Validator{compiled}, nil
+}
+
+// addReferences adds the references, and the references of those, as resources of the compiler.
+func addReferences(compiler *jsonschema.Compiler, bases []string, references []schemaregistry.Reference, lookup kafkaavro.ReferenceLookup, added map[string]bool) error {
+
+	for _, reference := range references {
+		if added[reference.Name] {
+			continue
+		}
+		added[reference.Name] = true
+		if lookup == nil {
+			return fmt.Errorf("no lookup for the reference %v", reference.Name)
+		}
+		schema, err := lookup(reference)
+		if err != nil {
+			return err
+		}
+
+		for _, base := range bases {
+			baseURL, err := url.Parse(base)
+			if err != nil {
+				return fmt.Errorf("invalid $id %q: %w", base, err)
+			}
+			referenceURL, err := baseURL.Parse(reference.Name)
+			if err != nil {
+				return fmt.Errorf("invalid reference %q: %w", reference.Name, err)
+			}
+			referenceURL.Fragment = ""
+			if err = compiler.AddResource(referenceURL.String(), strings.NewReader(schema.Schema)); err != nil {
+				return fmt.Errorf("invalid JSON schema of the reference %v: %w", reference.Name, err)
+			}
+		}
+		if err = addReferences(compiler, bases, schema.References, lookup, added); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate validates the document, as decoded by json.Unmarshal. A document which does not
+// validate fails with a *ValidationError.
+func (v Validator) Validate(document interface{}) error {
+
+	err := v.schema.Validate(document)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	invalid := &ValidationError{}
+	invalid.add(validationErr)
+	return invalid
+}
+
+// ValidationError lists the problems of a document which does not validate.
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Problem is a value of the document which does not validate, Path is its JSON pointer, e.g.
+// /lines/2/quantity, and "" for the document.
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		path := problem.Path
+		if path == "" {
+			path = "/"
+		}
+		problems[i] = path + ": " + problem.Message
+	}
+	return strings.Join(problems, "; ")
+}
+
+// add adds the leaves of the validation errors, the errors with causes only tell which keyword of
+// the schema failed.
+func (e *ValidationError) add(err *jsonschema.ValidationError) {
+	if len(err.Causes) == 0 {
+		e.Problems = append(e.Problems, Problem{Path: err.InstanceLocation, Message: err.Message})
+		return
+	}
+	for _, cause := range err.Causes {
+		e.add(cause)
+	}
+}
@@ -0,0 +1,157 @@
+package kafkaavrojsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const customerSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {"name": {"type": "string", "minLength": 1}},
+  "required": ["name"]
+}`
+
+const orderSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "id": {"type": "string"},
+    "customer": {"$ref": "customer.json"},
+    "lines": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {"product": {"type": "string"}, "quantity": {"type": "integer", "minimum": 1}},
+        "required": ["product", "quantity"]
+      }
+    }
+  },
+  "required": ["id"]
+}`
+
+func newRegistry() *mockregistry.Registry {
+	registry := mockregistry.New()
+	registry.RegisterSchema("customer", schemaregistry.Schema{Schema: customerSchema, SchemaType: schemaregistry.SchemaTypeJSON})
+	registry.RegisterSchema("orders-value", schemaregistry.Schema{Schema: orderSchema, SchemaType: schemaregistry.SchemaTypeJSON,
+		References: []schemaregistry.Reference{{Name: "customer.json", Subject: "customer", Version: 1}}})
+	return registry
+}
+
+func TestCodec(t *testing.T) {
+
+	registry := newRegistry()
+	jsonSchemas := kafkaavro.JSONSchemaType{NewValidator: NewValidator}
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(jsonSchemas))
+
+	order := map[string]interface{}{
+		"id":       "o-1",
+		"customer": map[string]interface{}{"name": "ann"},
+		"lines":    []interface{}{map[string]interface{}{"product": "p-1", "quantity": 2.0}},
+	}
+	data, err := codec.Encode("orders", false, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0 || data[4] != 2 || !json.Valid(data[5:]) {
+		t.Errorf("Encode() returned %q", data)
+	}
+
+	decoded, err := codec.Decode("orders", false, data)
+	if err != nil || !reflect.DeepEqual(decoded, order) {
+		t.Errorf("Decode() returned %v, %v, want %v", decoded, err, order)
+	}
+
+	jsonSchemas.RawMessage = true
+	raw := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(jsonSchemas))
+	decoded, err = raw.Decode("orders", false, data)
+	if message, ok := decoded.(json.RawMessage); err != nil || !ok || string(message) != string(data[5:]) {
+		t.Errorf("Decode() to a json.RawMessage returned %v, %v", decoded, err)
+	}
+	if encoded, err := raw.Encode("orders", false, json.RawMessage(`{"id":"o-2"}`)); err != nil || string(encoded[5:]) != `{"id":"o-2"}` {
+		t.Errorf("Encode() of a json.RawMessage returned %q, %v", encoded, err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+
+	registry := newRegistry()
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(kafkaavro.JSONSchemaType{NewValidator: NewValidator}))
+
+	tests := []struct {
+		document string
+		paths    []string
+	}{
+		{`{}`, []string{""}},
+		{`{"id":1}`, []string{"/id"}},
+		{`{"id":"o-1","customer":{"name":""}}`, []string{"/customer/name"}},
+		{`{"id":"o-1","lines":[{"product":"p-1","quantity":1},{"product":"p-2","quantity":0},{"quantity":1}]}`, []string{"/lines/1/quantity", "/lines/2"}},
+	}
+	for _, test := range tests {
+
+		_, err := codec.Encode("orders", false, json.RawMessage(test.document))
+		if !errors.Is(err, kafkaavro.ErrSchemaValidation) {
+			t.Errorf("Encode() of %v returned %v, want ErrSchemaValidation", test.document, err)
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("Encode() of %v returned a %T", test.document, err)
+		}
+		var paths []string
+		for _, problem := range validationErr.Problems {
+			paths = append(paths, problem.Path)
+		}
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("Encode() of %v failed at %v, want %v: %v", test.document, paths, test.paths, err)
+		}
+
+		// the documents of other producers are validated on decode
+		data := append([]byte{0, 0, 0, 0, 2}, test.document...)
+		if _, err = codec.Decode("orders", false, data); !errors.Is(err, kafkaavro.ErrSchemaValidation) {
+			t.Errorf("Decode() of %v returned %v, want ErrSchemaValidation", test.document, err)
+		}
+	}
+
+	if _, err := codec.Decode("orders", false, append([]byte{0, 0, 0, 0, 2}, `{"id":`...)); !errors.Is(err, kafkaavro.ErrMalformedPayload) {
+		t.Errorf("Decode() of invalid JSON returned %v, want ErrMalformedPayload", err)
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+
+	identified := schemaregistry.Schema{Schema: `{"$id":"https://example.com/schemas/order.json","$ref":"customer.json"}`,
+		References: []schemaregistry.Reference{{Name: "customer.json", Subject: "customer", Version: 1}}}
+	registry := newRegistry()
+	validator, err := NewValidator(identified, func(reference schemaregistry.Reference) (schemaregistry.Schema, error) {
+		return registry.GetSchemaBySubject(reference.Subject, reference.Version)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validator.Validate(map[string]interface{}{"name": "ann"}); err != nil {
+		t.Errorf("Validate() with a reference relative to the $id returned %v", err)
+	}
+	if err = validator.Validate(map[string]interface{}{}); err == nil {
+		t.Error("Validate() of a customer without name succeeded")
+	}
+
+	for _, invalid := range []schemaregistry.Schema{
+		{Schema: `{"type":`},
+		{Schema: `{"type":"unknown"}`},
+		{Schema: `{"$ref":"missing.json"}`},
+		{Schema: `{}`, References: []schemaregistry.Reference{{Name: "customer.json"}}},
+	} {
+		if _, err = NewValidator(invalid, nil); err == nil {
+			t.Errorf("NewValidator(%v) succeeded", invalid.Schema)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package kafkaavrootel traces the operations of a kafkaavro.Codec with OpenTelemetry:
+//
+//	tracer := kafkaavrootel.NewTracer(otel.GetTracerProvider())
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithTracer(tracer))
+//	native, err := codec.DecodeContext(ctx, topic, false, data)
+//
+// The decodes and encodes get a span with the topic, subject, schema id, payload size and whether
+// the schema was cached, and the schema registry requests get a child span.
+package kafkaavrootel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timvw/kafkaavro"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/timvw/kafkaavro"
+
+// Tracer is a kafkaavro.Tracer which starts OpenTelemetry spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ kafkaavro.Tracer = (*Tracer)(nil)
+
+// NewTracer creates a Tracer which starts the spans with the provider. Without a provider, the
+// spans are started with the provider of the span in the context, so that only operations of
+// traced requests are traced.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	t := &Tracer{}
+	if provider != nil {
+		t.tracer = provider.Tracer(instrumentationName)
+	}
+	return t
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, kafkaavro.Span) {
+
+	tracer := t.tracer
+	if tracer == nil {
+		tracer = trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+	}
+
+	ctx, s := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, span{s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
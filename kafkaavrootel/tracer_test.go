@@ -0,0 +1,120 @@
+package kafkaavrootel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+
+func newTestCodec(tracer *Tracer) *kafkaavro.Codec {
+	registry := mockregistry.New()
+	registry.Register("test-value", testSchema)
+	return kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithTracer(tracer))
+}
+
+func TestTracer(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	codec := newTestCodec(NewTracer(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "handle")
+	data, err := codec.EncodeContext(ctx, "test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.DecodeContext(ctx, "test", false, data); err != nil {
+		t.Fatal(err)
+	}
+	codec.DecodeContext(ctx, "test", false, []byte{0, 0, 0, 0, 8, 0})
+	parent.End()
+
+	type recordedSpan struct {
+		name       string
+		parent     string
+		attributes map[attribute.Key]attribute.Value
+		failed     bool
+	}
+
+	names := make(map[string]string)
+	var spans []recordedSpan
+	for _, s := range recorder.Ended() {
+		names[s.SpanContext().SpanID().String()] = s.Name()
+	}
+	for _, s := range recorder.Ended() {
+		attributes := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			attributes[kv.Key] = kv.Value
+		}
+		spans = append(spans, recordedSpan{s.Name(), names[s.Parent().SpanID().String()], attributes, s.Status().Code == codes.Error})
+	}
+
+	want := []recordedSpan{
+		{kafkaavro.SpanGetLatestSchema, kafkaavro.SpanEncode, map[attribute.Key]attribute.Value{
+			kafkaavro.AttributeSubject:  attribute.StringValue("test-value"),
+			kafkaavro.AttributeSchemaID: attribute.IntValue(1),
+		}, false},
+		{kafkaavro.SpanEncode, "handle", map[attribute.Key]attribute.Value{
+			kafkaavro.AttributeTopic:       attribute.StringValue("test"),
+			kafkaavro.AttributeSubject:     attribute.StringValue("test-value"),
+			kafkaavro.AttributePayloadSize: attribute.IntValue(len(data)),
+			kafkaavro.AttributeCacheHit:    attribute.BoolValue(false),
+			kafkaavro.AttributeSchemaID:    attribute.IntValue(1),
+		}, false},
+		{kafkaavro.SpanDecode, "handle", map[attribute.Key]attribute.Value{
+			kafkaavro.AttributeTopic:       attribute.StringValue("test"),
+			kafkaavro.AttributeSubject:     attribute.StringValue("test-value"),
+			kafkaavro.AttributePayloadSize: attribute.IntValue(len(data)),
+			kafkaavro.AttributeCacheHit:    attribute.BoolValue(true),
+			kafkaavro.AttributeSchemaID:    attribute.IntValue(1),
+		}, false},
+		{kafkaavro.SpanGetSchemaByID, kafkaavro.SpanDecode, map[attribute.Key]attribute.Value{
+			kafkaavro.AttributeSchemaID: attribute.IntValue(8),
+		}, true},
+		{kafkaavro.SpanDecode, "handle", map[attribute.Key]attribute.Value{
+			kafkaavro.AttributeTopic:       attribute.StringValue("test"),
+			kafkaavro.AttributeSubject:     attribute.StringValue("test-value"),
+			kafkaavro.AttributePayloadSize: attribute.IntValue(6),
+			kafkaavro.AttributeCacheHit:    attribute.BoolValue(false),
+			kafkaavro.AttributeSchemaID:    attribute.IntValue(8),
+		}, true},
+		{"handle", "", map[attribute.Key]attribute.Value{}, false},
+	}
+
+	if !reflect.DeepEqual(spans, want) {
+		t.Errorf("recorded spans\n%+v\nwant\n%+v", spans, want)
+	}
+}
+
+func TestTracerWithoutProvider(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	codec := newTestCodec(NewTracer(nil))
+
+	if _, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if spans := len(recorder.Ended()); spans != 0 {
+		t.Errorf("recorded %d spans without a span in the context, want none", spans)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "handle")
+	if _, err := codec.EncodeContext(ctx, "test", false, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	if spans := len(recorder.Ended()); spans != 2 {
+		t.Errorf("recorded %d spans with a span in the context, want the encode and its parent", spans)
+	}
+}
@@ -0,0 +1,137 @@
+// Package kafkaavroprom exports the operations of a kafkaavro.Codec as Prometheus metrics:
+//
+//	kafkaavro_decodes_total{result="success|error"}                    messages decoded
+//	kafkaavro_encodes_total{result="success|error"}                    messages encoded
+//	kafkaavro_schema_cache_lookups_total{result="hit|miss"}            schema cache lookups
+//	kafkaavro_registry_requests_total{operation, status}               schema registry requests
+//	kafkaavro_registry_request_duration_seconds{operation}             schema registry request latency
+//	kafkaavro_schema_cache_size                                        number of cached schemas
+//
+// The operation is get_schema_by_id or get_latest_schema and the status is success, not_found
+// or error. The metric names and labels are stable.
+//
+// Usage:
+//
+//	metrics, err := kafkaavroprom.New(prometheus.DefaultRegisterer)
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithMetrics(metrics))
+package kafkaavroprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const namespace = "kafkaavro"
+
+// Metrics is a kafkaavro.MetricsHook which updates Prometheus metrics. One Metrics can be
+// shared by several codecs, the schema cache size is then the size of the last updated cache.
+type Metrics struct {
+	decodes          *prometheus.CounterVec
+	encodes          *prometheus.CounterVec
+	cacheLookups     *prometheus.CounterVec
+	registryRequests *prometheus.CounterVec
+	registryDuration *prometheus.HistogramVec
+	cacheSize        prometheus.Gauge
+
+	// the counters of the results are looked up once instead of for every message
+	decodeSuccess, decodeError prometheus.Counter
+	encodeSuccess, encodeError prometheus.Counter
+	cacheHit, cacheMiss        prometheus.Counter
+}
+
+var _ kafkaavro.MetricsHook = (*Metrics)(nil)
+
+// New creates the metrics and registers them with the registerer.
+func New(registerer prometheus.Registerer) (m *Metrics, err error) {
+
+	m = &Metrics{
+		decodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decodes_total",
+			Help:      "Number of messages decoded, by result (success or error).",
+		}, []string{"result"}),
+		encodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "encodes_total",
+			Help:      "Number of messages encoded, by result (success or error).",
+		}, []string{"result"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "schema_cache_lookups_total",
+			Help:      "Number of schema cache lookups, by result (hit or miss).",
+		}, []string{"result"}),
+		registryRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registry_requests_total",
+			Help:      "Number of schema registry requests, by operation and status (success, not_found or error).",
+		}, []string{"operation", "status"}),
+		registryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "registry_request_duration_seconds",
+			Help:      "Latency of the schema registry requests, by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		cacheSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "schema_cache_size",
+			Help:      "Number of schemas in the cache.",
+		}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.decodes, m.encodes, m.cacheLookups, m.registryRequests, m.registryDuration, m.cacheSize} {
+		if err = registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	m.decodeSuccess, m.decodeError = m.decodes.WithLabelValues("success"), m.decodes.WithLabelValues("error")
+	m.encodeSuccess, m.encodeError = m.encodes.WithLabelValues("success"), m.encodes.WithLabelValues("error")
+	m.cacheHit, m.cacheMiss = m.cacheLookups.WithLabelValues("hit"), m.cacheLookups.WithLabelValues("miss")
+	return
+}
+
+func (m *Metrics) Decoded(err error) {
+	if err != nil {
+		m.decodeError.Inc()
+	} else {
+		m.decodeSuccess.Inc()
+	}
+}
+
+func (m *Metrics) Encoded(err error) {
+	if err != nil {
+		m.encodeError.Inc()
+	} else {
+		m.encodeSuccess.Inc()
+	}
+}
+
+func (m *Metrics) SchemaCacheLookup(hit bool) {
+	if hit {
+		m.cacheHit.Inc()
+	} else {
+		m.cacheMiss.Inc()
+	}
+}
+
+func (m *Metrics) RegistryRequest(operation string, duration time.Duration, err error) {
+	m.registryRequests.WithLabelValues(operation, status(err)).Inc()
+	m.registryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (m *Metrics) SchemaCacheSize(size int) {
+	m.cacheSize.Set(float64(size))
+}
+
+func status(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case schemaregistry.IsSchemaNotFound(err) || schemaregistry.IsSubjectNotFound(err):
+		return "not_found"
+	}
+	return "error"
+}
@@ -0,0 +1,81 @@
+package kafkaavroprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+
+func TestMetrics(t *testing.T) {
+
+	registry := prometheus.NewPedanticRegistry()
+	metrics, err := New(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := mockregistry.New()
+	mock.Register("test-value", testSchema)
+	mock.Script(mockregistry.GetLatestSchema, mockregistry.Fail(mockregistry.ErrUnavailable))
+	codec := kafkaavro.NewCodec(mock, kafkaavro.TopicNameStrategy{}, kafkaavro.WithMetrics(metrics))
+
+	if _, err = codec.Encode("test", false, map[string]interface{}{"f1": "value"}); err == nil {
+		t.Fatal("Encode() did not fail with the scripted failure")
+	}
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"f1": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec.Encode("other", false, map[string]interface{}{"f1": "value"})
+	for i := 0; i < 3; i++ {
+		if _, err = codec.Decode("test", false, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	codec.Decode("test", false, []byte{0, 0, 0, 0, 8, 0})
+	codec.DecodeReuse("test", false, []byte{1}, &kafkaavro.DecodeScratch{})
+
+	want := `
+# HELP kafkaavro_decodes_total Number of messages decoded, by result (success or error).
+# TYPE kafkaavro_decodes_total counter
+kafkaavro_decodes_total{result="error"} 2
+kafkaavro_decodes_total{result="success"} 3
+# HELP kafkaavro_encodes_total Number of messages encoded, by result (success or error).
+# TYPE kafkaavro_encodes_total counter
+kafkaavro_encodes_total{result="error"} 2
+kafkaavro_encodes_total{result="success"} 1
+# HELP kafkaavro_registry_requests_total Number of schema registry requests, by operation and status (success, not_found or error).
+# TYPE kafkaavro_registry_requests_total counter
+kafkaavro_registry_requests_total{operation="get_latest_schema",status="error"} 1
+kafkaavro_registry_requests_total{operation="get_latest_schema",status="not_found"} 1
+kafkaavro_registry_requests_total{operation="get_latest_schema",status="success"} 1
+kafkaavro_registry_requests_total{operation="get_schema_by_id",status="not_found"} 1
+# HELP kafkaavro_schema_cache_lookups_total Number of schema cache lookups, by result (hit or miss).
+# TYPE kafkaavro_schema_cache_lookups_total counter
+kafkaavro_schema_cache_lookups_total{result="hit"} 3
+kafkaavro_schema_cache_lookups_total{result="miss"} 4
+# HELP kafkaavro_schema_cache_size Number of schemas in the cache.
+# TYPE kafkaavro_schema_cache_size gauge
+kafkaavro_schema_cache_size 1
+`
+	if err = testutil.GatherAndCompare(registry, strings.NewReader(want),
+		"kafkaavro_decodes_total", "kafkaavro_encodes_total", "kafkaavro_registry_requests_total",
+		"kafkaavro_schema_cache_lookups_total", "kafkaavro_schema_cache_size"); err != nil {
+		t.Error(err)
+	}
+
+	if count := testutil.CollectAndCount(metrics.registryDuration); count != 2 {
+		t.Errorf("registry_request_duration_seconds has %d series, want 2", count)
+	}
+
+	if _, err = New(registry); err == nil {
+		t.Errorf("New() registered the metrics twice")
+	}
+}
@@ -0,0 +1,174 @@
+// Package kafkaavroproto decodes the protobuf data of the Confluent wire format with a
+// kafkaavro.Codec, so that topics with protobuf schemas can be decoded along with the avro ones:
+//
+//	codec := kafkaavro.NewCodec(client, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(kafkaavroproto.SchemaType{}))
+//	native, err := codec.Decode(topic, false, data)
+//	message := native.(proto.Message)
+//
+// The .proto of the writer schema and the schemas it references are fetched from the registry and
+// compiled, the standard imports (google/protobuf/*.proto) are built in. The data is decoded to a
+// *dynamicpb.Message of the message type identified by the message indexes which follow the
+// header, protojson.Marshal turns it into JSON. Encoding protobuf is not supported.
+package kafkaavroproto
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/schemaregistry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// schemaFile is the name of the .proto of the writer schema when it is compiled.
+const schemaFile = "kafkaavroproto/schema.proto"
+
+// SchemaType is the kafkaavro.SchemaType of the protobuf schemas.
+type SchemaType struct {
+}
+
+// Name returns schemaregistry.SchemaTypeProtobuf.
+func (SchemaType) Name() string {
+	return schemaregistry.SchemaTypeProtobuf
+}
+
+// NewBodyDecoder compiles the .proto of the schema and the schemas it references.
+func (SchemaType) NewBodyDecoder(schema schemaregistry.Schema, references kafkaavro.ReferenceLookup) (decoder kafkaavro.BodyDecoder, err error) {
+
+	file, err := Compile(schema, references)
+	if err != nil {
+		return
+	}
+	return Decoder{file}, nil
+}
+
+// Compile compiles the .proto of the schema, the references are fetched with the lookup.
+func Compile(schema schemaregistry.Schema, references kafkaavro.ReferenceLookup) (file protoreflect.FileDescriptor, err error) {
+
+	sources := map[string]string{schemaFile: schema.Schema}
+	if err = fetchReferences(schema.References, references, sources); err != nil {
+		return
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf schema: %w", err)
+	}
+	return files[0], nil
+}
+
+// fetchReferences adds the sources of the references, and of the references of those, by name.
+func fetchReferences(references []schemaregistry.Reference, lookup kafkaavro.ReferenceLookup, sources map[string]string) error {
+
+	for _, reference := range references {
+		if _, found := sources[reference.Name]; found {
+			continue
+		}
+		if lookup == nil {
+			return fmt.Errorf("no lookup for the reference %v", reference.Name)
+		}
+		schema, err := lookup(reference)
+		if err != nil {
+			return err
+		}
+		sources[reference.Name] = schema.Schema
+		if err = fetchReferences(schema.References, lookup, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decoder decodes the protobuf data which follows the header to a *dynamicpb.Message, it is safe
+// for concurrent use.
+type Decoder struct {
+	file protoreflect.FileDescriptor
+}
+
+// DecodeBody decodes the message indexes and the message.
+func (d Decoder) DecodeBody(body []byte) (native interface{}, err error) {
+
+	indexes, size, err := ReadMessageIndexes(body)
+	if err != nil {
+		return
+	}
+	descriptor, err := d.MessageDescriptor(indexes)
+	if err != nil {
+		return
+	}
+
+	message := dynamicpb.NewMessage(descriptor)
+	if err = proto.Unmarshal(body[size:], message); err != nil {
+		return nil, fmt.Errorf("invalid %v message: %w", descriptor.FullName(), err)
+	}
+	return proto.Message(message), nil
+}
+
+// MessageDescriptor returns the message type of the message indexes: the first index is the one
+// of the message in the file, the next ones are those of the nested messages.
+func (d Decoder) MessageDescriptor(indexes []int) (descriptor protoreflect.MessageDescriptor, err error) {
+
+	messages := d.file.Messages()
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, fmt.Errorf("message index %d of %v is out of range, the schema has %d messages there", index, indexes, messages.Len())
+		}
+		descriptor = messages.Get(index)
+		messages = descriptor.Messages()
+	}
+	if descriptor == nil {
+		return nil, errors.New("no message indexes")
+	}
+	return
+}
+
+// ReadMessageIndexes reads the message indexes, the zigzag varint count followed by the zigzag
+// varint indexes, and returns the number of bytes read. A count of 0 is the first message, [0].
+func ReadMessageIndexes(body []byte) (indexes []int, size int, err error) {
+
+	count, n := binary.Varint(body)
+	if n <= 0 {
+		return nil, 0, errors.New("invalid message indexes count")
+	}
+	size = n
+	if count == 0 {
+		return []int{0}, size, nil
+	}
+	if count < 0 || count > int64(len(body)-size) {
+		return nil, 0, fmt.Errorf("invalid message indexes count %d", count)
+	}
+
+	indexes = make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(body[size:])
+		if n <= 0 || index < 0 {
+			return nil, 0, fmt.Errorf("invalid message index %d", i)
+		}
+		indexes[i], size = int(index), size+n
+	}
+	return
+}
+
+// AppendMessageIndexes appends the message indexes as the Confluent serializers write them, the
+// first message ([0]) as a single 0.
+func AppendMessageIndexes(b []byte, indexes []int) []byte {
+
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(b, 0)
+	}
+	b = binary.AppendVarint(b, int64(len(indexes)))
+	for _, index := range indexes {
+		b = binary.AppendVarint(b, int64(index))
+	}
+	return b
+}
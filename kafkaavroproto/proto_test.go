@@ -0,0 +1,206 @@
+package kafkaavroproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const commonProto = `syntax = "proto3";
+package common;
+
+message Money {
+  string currency = 1;
+  int64 cents = 2;
+}`
+
+const orderProto = `syntax = "proto3";
+package orders;
+
+import "common.proto";
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  string id = 1;
+  common.Money total = 2;
+  google.protobuf.Timestamp created = 3;
+
+  message Line {
+    string product = 1;
+    int32 quantity = 2;
+  }
+}
+
+message Refund {
+  string order_id = 1;
+}`
+
+func TestMessageIndexes(t *testing.T) {
+
+	tests := []struct {
+		indexes []int
+		encoded []byte
+	}{
+		{[]int{0}, []byte{0}},
+		{[]int{1}, []byte{2, 2}},
+		{[]int{0, 0}, []byte{4, 0, 0}},
+		{[]int{2, 65}, []byte{4, 4, 0x82, 0x01}},
+	}
+	for _, test := range tests {
+		if encoded := AppendMessageIndexes(nil, test.indexes); !reflect.DeepEqual(encoded, test.encoded) {
+			t.Errorf("AppendMessageIndexes(%v) returned %v, want %v", test.indexes, encoded, test.encoded)
+		}
+		indexes, size, err := ReadMessageIndexes(append(test.encoded, 0xff))
+		if err != nil || !reflect.DeepEqual(indexes, test.indexes) || size != len(test.encoded) {
+			t.Errorf("ReadMessageIndexes(%v) returned %v, %d, %v", test.encoded, indexes, size, err)
+		}
+	}
+
+	for _, invalid := range [][]byte{nil, {0x80}, {1}, {6, 0}, {2, 1}} {
+		if indexes, _, err := ReadMessageIndexes(invalid); err == nil {
+			t.Errorf("ReadMessageIndexes(%v) returned %v", invalid, indexes)
+		}
+	}
+}
+
+func newRegistry(t *testing.T) (registry *mockregistry.Registry, id int) {
+	registry = mockregistry.New()
+	registry.RegisterSchema("common.proto", schemaregistry.Schema{Schema: commonProto, SchemaType: schemaregistry.SchemaTypeProtobuf})
+	id = registry.RegisterSchema("orders-value", schemaregistry.Schema{Schema: orderProto, SchemaType: schemaregistry.SchemaTypeProtobuf,
+		References: []schemaregistry.Reference{{Name: "common.proto", Subject: "common.proto", Version: 1}}})
+	return
+}
+
+// encode encodes the message in the Confluent wire format, the message is set from its JSON.
+func encode(t *testing.T, registry *mockregistry.Registry, id int, indexes []int, jsonMessage string) []byte {
+
+	schema, err := registry.GetSchema(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Compile(schema, func(reference schemaregistry.Reference) (schemaregistry.Schema, error) {
+		return registry.GetSchemaBySubject(reference.Subject, reference.Version)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor, err := Decoder{file}.MessageDescriptor(indexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	if err = protojson.Unmarshal([]byte(jsonMessage), message); err != nil {
+		t.Fatal(err)
+	}
+	body, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := binary.BigEndian.AppendUint32([]byte{0}, uint32(id))
+	return append(AppendMessageIndexes(data, indexes), body...)
+}
+
+func TestDecode(t *testing.T) {
+
+	registry, id := newRegistry(t)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(SchemaType{}))
+
+	tests := []struct {
+		indexes []int
+		json    string
+		name    string
+	}{
+		{[]int{0}, `{"id":"o-1","total":{"currency":"EUR","cents":"1250"},"created":"2024-03-01T12:00:00Z"}`, "orders.Order"},
+		{[]int{1}, `{"orderId":"o-1"}`, "orders.Refund"},
+		{[]int{0, 0}, `{"product":"p-7","quantity":3}`, "orders.Order.Line"},
+	}
+	data := make([][]byte, len(tests))
+	for i, test := range tests {
+		data[i] = encode(t, registry, id, test.indexes, test.json)
+	}
+	registry.Reset()
+
+	for i, test := range tests {
+
+		native, err := codec.Decode("orders", false, data[i])
+		if err != nil {
+			t.Fatalf("Decode() of %v returned %v", test.name, err)
+		}
+		message, ok := native.(proto.Message)
+		if !ok {
+			t.Fatalf("Decode() of %v returned a %T", test.name, native)
+		}
+		if name := message.ProtoReflect().Descriptor().FullName(); string(name) != test.name {
+			t.Errorf("Decode() returned a %v, want %v", name, test.name)
+		}
+		decoded, err := protojson.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := compact(decoded), compact([]byte(test.json)); got != want {
+			t.Errorf("Decode() of %v returned %v, want %v", test.name, got, want)
+		}
+	}
+
+	// the schema and the reference are fetched once
+	if count := registry.CallCount(mockregistry.GetSchemaByID); count != 1 {
+		t.Errorf("the schema was fetched %d times, want 1", count)
+	}
+	if count := registry.CallCount(mockregistry.GetSchemaBySubject); count != 1 {
+		t.Errorf("the reference was fetched %d times, want 1", count)
+	}
+
+	if _, schema, err := codec.WriterSchema(data[0]); err != nil || schema != orderProto {
+		t.Errorf("WriterSchema() returned %q, %v", schema, err)
+	}
+	if observed := codec.ObservedSchemas()["orders"]; len(observed) != 1 || observed[0].ID != id {
+		t.Errorf("ObservedSchemas() returned %+v", observed)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+
+	registry, id := newRegistry(t)
+	data := encode(t, registry, id, []int{0}, `{"id":"o-1"}`)
+
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	if _, err := codec.Decode("orders", false, data); !errors.Is(err, kafkaavro.ErrUnsupportedSchemaType) {
+		t.Errorf("Decode() without the SchemaType returned %v, want ErrUnsupportedSchemaType", err)
+	}
+
+	codec = kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}, kafkaavro.WithSchemaType(SchemaType{}))
+	if _, err := codec.Decode("orders", false, append(data[:5:5], 2, 4)); !errors.Is(err, kafkaavro.ErrMalformedPayload) || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Decode() with an unknown message index returned %v", err)
+	}
+	if _, err := codec.Decode("orders", false, append(data[:5:5], 0, 0xff)); !errors.Is(err, kafkaavro.ErrMalformedPayload) {
+		t.Errorf("Decode() of an invalid message returned %v", err)
+	}
+	if _, err := codec.Encode("orders", false, map[string]interface{}{"id": "o-1"}); !errors.Is(err, kafkaavro.ErrUnsupportedSchemaType) {
+		t.Errorf("Encode() with a protobuf schema returned %v, want ErrUnsupportedSchemaType", err)
+	}
+
+	broken := registry.RegisterSchema("broken-value", schemaregistry.Schema{Schema: `syntax = "proto3"; message {`, SchemaType: schemaregistry.SchemaTypeProtobuf})
+	if _, err := codec.Decode("broken", false, []byte{0, 0, 0, 0, byte(broken), 0}); !errors.Is(err, kafkaavro.ErrCodecBuild) {
+		t.Errorf("Decode() with an invalid .proto returned %v, want ErrCodecBuild", err)
+	}
+
+	missing := registry.RegisterSchema("missing-value", schemaregistry.Schema{Schema: `syntax = "proto3"; import "missing.proto";`, SchemaType: schemaregistry.SchemaTypeProtobuf,
+		References: []schemaregistry.Reference{{Name: "missing.proto", Subject: "missing", Version: 1}}})
+	if _, err := codec.Decode("missing", false, []byte{0, 0, 0, 0, byte(missing), 0}); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("Decode() with a missing reference returned %v, want ErrSchemaNotFound", err)
+	}
+}
+
+func compact(jsonMessage []byte) string {
+	return strings.Join(strings.Fields(string(jsonMessage)), "")
+}
@@ -0,0 +1,112 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// DefaultMaxCollectionSize is the limit of a Codec unless WithMaxCollectionSize is used: a Kafka
+// message of the default maximum size of 1MB can not hold more items in its arrays and maps, except
+// for arrays of nulls (or empty records).
+const DefaultMaxCollectionSize = 1 << 20
+
+// WithMaxCollectionSize makes the Codec refuse data of which the arrays and maps hold more than
+// items items together with ErrPayloadTooLarge, without decoding it. goavro allows blocks of
+// math.MaxInt32 items and allocates the items of a block before decoding them: a message of a few
+// bytes with a corrupt block count of an array of nulls would take the process down with an out of
+// memory error. The Codec counts the items of all the blocks of the data before goavro decodes it.
+// The default is DefaultMaxCollectionSize, 0 removes the limit.
+func WithMaxCollectionSize(items int64) Option {
+	return func(c *Codec) {
+		c.maxCollectionSize = items
+	}
+}
+
+// SetMaxCollectionSize limits the number of items in a block of an array or map for all goavro
+// codecs (and object container files) of the process, not only for those of this package, on top
+// of the limit of WithMaxCollectionSize: data with larger blocks fails with ErrPayloadTooLarge. The
+// limit is goavro.MaxBlockCount, which is not synchronized, so set it once before decoding starts.
+// This package does not change it unless SetMaxCollectionSize is called.
+func SetMaxCollectionSize(items int64) {
+	goavro.MaxBlockCount = items
+}
+
+// The limits of a Codec unless WithMaxSchemaSize or WithMaxPayloadSize is used.
+const (
+	DefaultMaxSchemaSize  = 5 << 20
+	DefaultMaxPayloadSize = 50 << 20
+)
+
+// WithMaxPayloadSize makes the Codec refuse data of more than size bytes (including the header)
+// with ErrPayloadTooLarge, without decoding it. The default is DefaultMaxPayloadSize, 0 removes
+// the limit.
+func WithMaxPayloadSize(size int) Option {
+	return func(c *Codec) {
+		c.maxPayloadSize = size
+	}
+}
+
+// WithMaxSchemaSize makes the Codec refuse the schemas of more than size bytes which it fetches
+// from the registry with ErrSchemaTooLarge, without building a codec for them. The default is
+// DefaultMaxSchemaSize, 0 removes the limit.
+func WithMaxSchemaSize(size int) Option {
+	return func(c *Codec) {
+		c.maxSchemaSize = size
+	}
+}
+
+// checkSchemaSize returns ErrSchemaTooLarge for a fetched schema larger than the maximum schema size.
+func (c *Codec) checkSchemaSize(schema string, what string) error {
+	if c.maxSchemaSize > 0 && len(schema) > c.maxSchemaSize {
+		return fmt.Errorf("%w: %v of %d bytes exceeds the maximum of %d bytes", ErrSchemaTooLarge, what, len(schema), c.maxSchemaSize)
+	}
+	return nil
+}
+
+// checkCollectionSize returns ErrPayloadTooLarge for data of the codec of which the arrays and maps
+// hold more items than the maximum collection size, or with a block larger than goavro.MaxBlockCount.
+func (c *Codec) checkCollectionSize(codec *goavro.Codec, body []byte) error {
+
+	if c.maxCollectionSize <= 0 && goavro.MaxBlockCount >= math.MaxInt32 {
+		return nil
+	}
+	s, err := c.logicalSchemaOf(codec)
+	if err != nil || !s.collections {
+		return err
+	}
+	w := &visitWalk{schema: s, data: body, maxItems: c.maxCollectionSize}
+	return w.skip(s.root)
+}
+
+// decodeLimited decodes the avro data after the header once it is within the maximum collection size.
+func (c *Codec) decodeLimited(codec *goavro.Codec, body []byte) (native interface{}, err error) {
+	if err = c.checkCollectionSize(codec, body); err != nil {
+		return
+	}
+	return decodeBody(codec, body)
+}
+
+func (c *Codec) checkPayloadSize(data []byte) error {
+	if c.maxPayloadSize > 0 && len(data) > c.maxPayloadSize {
+		return fmt.Errorf("%w: %d bytes exceed the maximum of %d bytes", ErrPayloadTooLarge, len(data), c.maxPayloadSize)
+	}
+	return nil
+}
+
+// decodeBody decodes the avro data after the header, a panic of goavro is returned as an
+// ErrMalformedPayload.
+func decodeBody(codec *goavro.Codec, body []byte) (native interface{}, err error) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			native, err = nil, fmt.Errorf("%w: the avro decoder panicked: %v", ErrMalformedPayload, recovered)
+		}
+	}()
+
+	if native, _, err = codec.NativeFromBinary(body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+	}
+	return
+}
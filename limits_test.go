@@ -0,0 +1,161 @@
+package kafkaavro
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const arraySchema = `{"type":"record","name":"withArray","fields":[{"name":"items","type":{"type":"array","items":"long"}}]}`
+
+func newArrayCodec(t testing.TB, options ...Option) *Codec {
+	registry := &fakeRegistry{schemas: map[int]string{1: arraySchema}, subjects: map[string][]int{"test-value": {1}}}
+	return NewCodec(registry, TopicNameStrategy{}, options...)
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+
+	codec := newArrayCodec(t, WithMaxPayloadSize(9))
+
+	data, err := codec.Encode("test", false, map[string]interface{}{"items": []interface{}{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Decode("test", false, data); err != nil {
+		t.Errorf("Decode() of %d bytes returned %v", len(data), err)
+	}
+
+	data, err = codec.Encode("test", false, map[string]interface{}{"items": []interface{}{1, 2, 3, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Decode("test", false, data); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of %d bytes returned %v, want ErrPayloadTooLarge", len(data), err)
+	}
+	if _, err = codec.DecodeReuse("test", false, data, &DecodeScratch{}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("DecodeReuse() of %d bytes returned %v, want ErrPayloadTooLarge", len(data), err)
+	}
+}
+
+func TestMaxSchemaSize(t *testing.T) {
+
+	data := []byte{0, 0, 0, 0, 1, 2, 2, 0}
+	value := map[string]interface{}{"items": []interface{}{int64(1)}}
+	tests := []struct {
+		name string
+		size int
+		want error
+	}{
+		{"default", DefaultMaxSchemaSize, nil},
+		{"schema size", len(arraySchema), nil},
+		{"schema size - 1", len(arraySchema) - 1, ErrSchemaTooLarge},
+		{"no limit", 0, nil},
+	}
+	for _, test := range tests {
+		codec := newArrayCodec(t, WithMaxSchemaSize(test.size))
+		if _, err := codec.Decode("test", false, data); !errors.Is(err, test.want) || (err != nil) != (test.want != nil) {
+			t.Errorf("%v: Decode() returned %v, want %v", test.name, err, test.want)
+		}
+		if _, err := codec.Encode("test", false, value); !errors.Is(err, test.want) || (err != nil) != (test.want != nil) {
+			t.Errorf("%v: Encode() returned %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestDefaultMaxPayloadSize(t *testing.T) {
+
+	codec := newArrayCodec(t)
+	data := make([]byte, DefaultMaxPayloadSize+1)
+	if _, err := codec.Decode("test", false, data); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of %d bytes returned %v, want ErrPayloadTooLarge", len(data), err)
+	}
+	if _, err := newArrayCodec(t, WithMaxPayloadSize(0)).Decode("test", false, data); errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() without a limit returned %v", err)
+	}
+}
+
+func TestMaxCollectionSize(t *testing.T) {
+
+	codec := newArrayCodec(t, WithMaxCollectionSize(1000))
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		// 1000 zigzag encoded is d0 0f, 1001 is d2 0f
+		{"a block of 1001 items", []byte{0, 0, 0, 0, 1, 0xd2, 0x0f, 0}, ErrPayloadTooLarge},
+		{"a block of -1001 items with its size", []byte{0, 0, 0, 0, 1, 0xd1, 0x0f, 0x02, 0}, ErrPayloadTooLarge},
+		{"a block of 2 items without the items", []byte{0, 0, 0, 0, 1, 0x04}, ErrMalformedPayload},
+		{"a block of 2 items", []byte{0, 0, 0, 0, 1, 0x04, 0x02, 0x04, 0}, nil},
+	}
+	for _, test := range tests {
+		if _, err := codec.Decode("test", false, test.data); !errors.Is(err, test.want) || (err != nil) != (test.want != nil) {
+			t.Errorf("Decode() of %v returned %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// the items of the blocks count together: 2 blocks of 500 and 501 nulls
+	registry := &fakeRegistry{schemas: map[int]string{1: `{"type":"array","items":"null"}`}, subjects: map[string][]int{}}
+	nulls := NewCodec(registry, TopicNameStrategy{}, WithMaxCollectionSize(1000))
+	if _, err := nulls.Decode("test", false, []byte{0, 0, 0, 0, 1, 0xe8, 0x07, 0xea, 0x07, 0}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of 2 blocks of 1001 nulls returned %v, want ErrPayloadTooLarge", err)
+	}
+
+	// the items of nested arrays count together: 2 arrays of 500 nulls are 1002 items
+	registry = &fakeRegistry{schemas: map[int]string{1: `{"type":"array","items":{"type":"array","items":"null"}}`}, subjects: map[string][]int{}}
+	nested := NewCodec(registry, TopicNameStrategy{}, WithMaxCollectionSize(1000))
+	data := []byte{0, 0, 0, 0, 1, 0x04, 0xe8, 0x07, 0, 0xe8, 0x07, 0, 0}
+	if _, err := nested.Decode("test", false, data); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of nested arrays of 1002 items returned %v, want ErrPayloadTooLarge", err)
+	}
+	if err := nested.DecodeVisit("test", false, data, &recordingVisitor{}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("DecodeVisit() of nested arrays of 1002 items returned %v, want ErrPayloadTooLarge", err)
+	}
+	if _, err := NewCodec(registry, TopicNameStrategy{}).Decode("test", false, data); err != nil {
+		t.Errorf("Decode() of nested arrays of 1002 items with the default limit returned %v", err)
+	}
+}
+
+func TestSetMaxCollectionSize(t *testing.T) {
+
+	defer SetMaxCollectionSize(goavro.MaxBlockCount)
+	SetMaxCollectionSize(1000)
+
+	// the limit of the process applies to the blocks without the limit of the Codec as well
+	codec := newArrayCodec(t, WithMaxCollectionSize(0))
+	if _, err := codec.Decode("test", false, []byte{0, 0, 0, 0, 1, 0xd2, 0x0f, 0}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of an array of 1001 items returned %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestDecodeBodyRecovers(t *testing.T) {
+	// a nil codec makes goavro panic
+	if _, err := decodeBody(nil, []byte{0}); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("decodeBody() returned %v, want ErrMalformedPayload", err)
+	}
+}
+
+func TestDefaultMaxCollectionSize(t *testing.T) {
+
+	// importing the package does not change the limit of the process
+	if goavro.MaxBlockCount != math.MaxInt32 {
+		t.Fatalf("goavro.MaxBlockCount is %d, want the default of goavro", goavro.MaxBlockCount)
+	}
+
+	registry := &fakeRegistry{schemas: map[int]string{1: `{"type":"array","items":"null"}`}, subjects: map[string][]int{}}
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	// an array of 2^31-1 nulls in 10 bytes
+	data := []byte{0, 0, 0, 0, 1, 0xfe, 0xff, 0xff, 0xff, 0x0f}
+	if _, err := codec.Decode("test", false, data); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of an array of 2^31-1 nulls returned %v, want ErrPayloadTooLarge", err)
+	}
+
+	// testdata/fuzz/FuzzDecode/null_array_huge, of the fuzz schema
+	data = []byte("\x00\x00\x00\x00\x01\x02\x04\x00\x00\x00\x00\x00\x00\x00\x00\x06str\x04by\x00\x00abcd\x00\x00\xfe\xff\xff\xff\x0f")
+	if _, err := newFuzzCodec().Decode("fuzz", false, data); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Decode() of null_array_huge returned %v, want ErrPayloadTooLarge", err)
+	}
+}
@@ -0,0 +1,438 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// WithLogicalTypes converts the values of the logical types to and from Go types, including those
+// which goavro leaves as the values of the underlying type: a string with logical type uuid
+// decodes to a uuid.UUID.
+// Encoding accepts the Go types as well as the underlying values, which are validated, and a Go
+// value in a union with null does not need to be wrapped with goavro.Union. A value which does
+// not convert fails with a *FieldError naming the field.
+//
+// The time logical types are those of goavro, with the encoding fixed for non UTC times and times
+// before 1970: a date is a time.Time of which the date in its location is encoded (and decodes to
+// midnight UTC), a time-millis or time-micros a time.Duration since midnight and a timestamp-millis
+// or timestamp-micros a time.Time, decoded in UTC. Finer precision than that of the type is truncated.
+func WithLogicalTypes() Option {
+	return func(c *Codec) {
+		c.logicalTypes = true
+	}
+}
+
+// logicalConversion converts between the avro value of a logical type and its Go value.
+type logicalConversion struct {
+	// decode returns the Go value of an avro value, nil for the logical types goavro decodes.
+	decode func(avro interface{}) (value interface{}, err error)
+	// encode returns the avro value of a Go value, or validates an avro value.
+	encode func(value interface{}) (avro interface{}, err error)
+	// accepts returns true for the Go values, which are put in the branch of a union.
+	accepts func(value interface{}) bool
+}
+
+// logicalConversions are the conversions by the type and logical type, e.g. string.uuid.
+var logicalConversions = map[string]logicalConversion{
+	"string.uuid": {
+		decode: func(avro interface{}) (interface{}, error) {
+			s, _ := avro.(string)
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidUUID, s)
+			}
+			return id, nil
+		},
+		encode: func(value interface{}) (interface{}, error) {
+			switch v := value.(type) {
+			case uuid.UUID:
+				return v.String(), nil
+			case string:
+				if _, err := uuid.Parse(v); err != nil {
+					return nil, fmt.Errorf("%w: %q", ErrInvalidUUID, v)
+				}
+			}
+			return value, nil
+		},
+		accepts: func(value interface{}) bool {
+			_, ok := value.(uuid.UUID)
+			return ok
+		},
+	},
+	"int.date": {
+		// goavro decodes the date to midnight UTC
+		encode: func(value interface{}) (interface{}, error) {
+			t, ok := value.(time.Time)
+			if !ok {
+				return value, nil
+			}
+			// the date in the location of the time, e.g. 2024-01-01T00:30:00+02:00 is 2024-01-01
+			year, month, day := t.Date()
+			days := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+			if days < math.MinInt32 || days > math.MaxInt32 {
+				return nil, fmt.Errorf("%w: %v is out of the range of a date", ErrInvalidTime, t)
+			}
+			return int32(days), nil
+		},
+		accepts: isTime,
+	},
+	"int.time-millis": {
+		encode: func(value interface{}) (interface{}, error) {
+			d, ok := value.(time.Duration)
+			if !ok {
+				return value, nil
+			}
+			if d < 0 || d >= 24*time.Hour {
+				return nil, fmt.Errorf("%w: %v is not a time of day", ErrInvalidTime, d)
+			}
+			return int32(d / time.Millisecond), nil
+		},
+		accepts: isDuration,
+	},
+	"long.time-micros": {
+		encode: func(value interface{}) (interface{}, error) {
+			d, ok := value.(time.Duration)
+			if !ok {
+				return value, nil
+			}
+			if d < 0 || d >= 24*time.Hour {
+				return nil, fmt.Errorf("%w: %v is not a time of day", ErrInvalidTime, d)
+			}
+			return int64(d / time.Microsecond), nil
+		},
+		accepts: isDuration,
+	},
+	"long.timestamp-millis": {
+		decode: utc,
+		encode: func(value interface{}) (interface{}, error) {
+			t, ok := value.(time.Time)
+			if !ok {
+				return value, nil
+			}
+			if t.Unix() < math.MinInt64/1000 || t.Unix() > math.MaxInt64/1000-1 {
+				return nil, fmt.Errorf("%w: %v is out of the range of a timestamp-millis", ErrInvalidTime, t)
+			}
+			return t.Unix()*1e3 + int64(t.Nanosecond()/1e6), nil
+		},
+		accepts: isTime,
+	},
+	"long.timestamp-micros": {
+		// goavro decodes the microseconds, they are not truncated to milliseconds
+		decode: utc,
+		encode: func(value interface{}) (interface{}, error) {
+			t, ok := value.(time.Time)
+			if !ok {
+				return value, nil
+			}
+			if t.Unix() < math.MinInt64/1000000 || t.Unix() > math.MaxInt64/1000000-1 {
+				return nil, fmt.Errorf("%w: %v is out of the range of a timestamp-micros", ErrInvalidTime, t)
+			}
+			return t.Unix()*1e6 + int64(t.Nanosecond()/1e3), nil
+		},
+		accepts: isTime,
+	},
+}
+
+const secondsPerDay = 24 * 60 * 60
+
+func isTime(value interface{}) bool {
+	_, ok := value.(time.Time)
+	return ok
+}
+
+func isDuration(value interface{}) bool {
+	_, ok := value.(time.Duration)
+	return ok
+}
+
+func utc(avro interface{}) (interface{}, error) {
+	if t, ok := avro.(time.Time); ok {
+		return t.UTC(), nil
+	}
+	return avro, nil
+}
+
+// logicalSchema converts the values of a schema, the nodes without conversions are skipped.
+type logicalSchema struct {
+	root        *avroschema.Node
+	conversions map[*avroschema.Node]logicalConversion
+	converts    map[*avroschema.Node]bool
+	// collections is true if the schema has an array or map
+	collections bool
+	// codecs decode the values of the types of DecodeVisit
+	codecs copyOnWriteMap[*avroschema.Node, *goavro.Codec]
+}
+
+func newLogicalSchema(schema AvroSchema, conversionOf func(*avroschema.Node) (logicalConversion, bool)) (s *logicalSchema, err error) {
+
+	root, err := avroschema.Parse(schema)
+	if err != nil {
+		return
+	}
+	s = &logicalSchema{root: root, conversions: make(map[*avroschema.Node]logicalConversion), converts: make(map[*avroschema.Node]bool)}
+	s.mark(root, conversionOf)
+	return
+}
+
+// mark records the conversions and the nodes which hold one, a node converts if it reaches a node
+// with a conversion. The named types of a recursive schema form a cycle, so the nodes are collected
+// once and marked until no node changes.
+func (s *logicalSchema) mark(root *avroschema.Node, conversionOf func(*avroschema.Node) (logicalConversion, bool)) {
+
+	var nodes []*avroschema.Node
+	seen := make(map[*avroschema.Node]bool)
+	var collect func(n *avroschema.Node)
+	collect = func(n *avroschema.Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		nodes = append(nodes, n)
+		s.collections = s.collections || n.Type == "array" || n.Type == "map"
+		if conversion, converts := conversionOf(n); converts {
+			s.conversions[n] = conversion
+			s.converts[n] = true
+		}
+		for _, child := range children(n) {
+			collect(child)
+		}
+	}
+	collect(root)
+
+	for changed := true; changed; {
+		changed = false
+		// the children are collected after their parents
+		for i := len(nodes) - 1; i >= 0; i-- {
+			n := nodes[i]
+			if s.converts[n] {
+				continue
+			}
+			for _, child := range children(n) {
+				if s.converts[child] {
+					s.converts[n], changed = true, true
+					break
+				}
+			}
+		}
+	}
+}
+
+// children returns the types of the fields, branches, items or values of the type.
+func children(n *avroschema.Node) []*avroschema.Node {
+	children := append([]*avroschema.Node(nil), n.Branches...)
+	for _, f := range n.Fields {
+		children = append(children, f.Node)
+	}
+	if n.Items != nil {
+		children = append(children, n.Items)
+	}
+	if n.Values != nil {
+		children = append(children, n.Values)
+	}
+	return children
+}
+
+// converting returns true if the Codec converts the native values, see WithLogicalTypes and WithEnums.
+func (c *Codec) converting() bool {
+	return c.logicalTypes || c.enums != nil
+}
+
+// conversionOf returns the conversion of the values of the type.
+func (c *Codec) conversionOf(n *avroschema.Node) (conversion logicalConversion, found bool) {
+	if n.Type == "enum" && c.enums != nil {
+		return c.enums.conversion(n), true
+	}
+	if c.logicalTypes && n.Logical != "" {
+		conversion, found = logicalConversions[n.Type+"."+n.Logical]
+	}
+	return
+}
+
+// logicalSchemaOf returns the conversions of the schema of the codec.
+func (c *Codec) logicalSchemaOf(codec *goavro.Codec) (s *logicalSchema, err error) {
+
+	if s, found := c.logicalSchemas.get(codec); found {
+		return s, nil
+	}
+	if s, err = newLogicalSchema(codec.Schema(), c.conversionOf); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	c.logicalSchemas.put(codec, s)
+	return
+}
+
+// fromAvro converts the logical types and enums of a native value decoded by goavro, in place.
+func (c *Codec) fromAvro(codec *goavro.Codec, native interface{}) (interface{}, error) {
+	s, err := c.logicalSchemaOf(codec)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(s.root, native, "")
+}
+
+// toAvro returns the native value for goavro, the value itself is not modified.
+func (c *Codec) toAvro(codec *goavro.Codec, native interface{}) (interface{}, error) {
+	s, err := c.logicalSchemaOf(codec)
+	if err != nil {
+		return nil, err
+	}
+	return s.encode(s.root, native, "")
+}
+
+func (s *logicalSchema) decode(n *avroschema.Node, native interface{}, path string) (value interface{}, err error) {
+
+	if !s.converts[n] {
+		return native, nil
+	}
+
+	if conversion, found := s.conversions[n]; found {
+		if conversion.decode == nil {
+			return native, nil
+		}
+		if value, err = conversion.decode(native); err != nil {
+			return nil, &FieldError{Path: path, Err: err}
+		}
+		return
+	}
+
+	switch n.Type {
+	case "record":
+		record, _ := native.(map[string]interface{})
+		for _, f := range n.Fields {
+			if v, found := record[f.Name]; found {
+				if record[f.Name], err = s.decode(f.Node, v, fieldPath(path, f.Name)); err != nil {
+					return
+				}
+			}
+		}
+	case "array":
+		items, _ := native.([]interface{})
+		for i, v := range items {
+			if items[i], err = s.decode(n.Items, v, indexPath(path, strconv.Itoa(i))); err != nil {
+				return
+			}
+		}
+	case "map":
+		values, _ := native.(map[string]interface{})
+		for k, v := range values {
+			if values[k], err = s.decode(n.Values, v, indexPath(path, k)); err != nil {
+				return
+			}
+		}
+	case "union":
+		// goavro decodes a union to nil or a map of the name of the branch to the value
+		wrapped, _ := native.(map[string]interface{})
+		for name, v := range wrapped {
+			if b := n.Branch(name); b != nil {
+				if wrapped[name], err = s.decode(b, v, path); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return native, nil
+}
+
+func (s *logicalSchema) encode(n *avroschema.Node, value interface{}, path string) (native interface{}, err error) {
+
+	if !s.converts[n] {
+		return value, nil
+	}
+
+	if conversion, found := s.conversions[n]; found {
+		if native, err = conversion.encode(value); err != nil {
+			return nil, &FieldError{Path: path, Err: err}
+		}
+		return
+	}
+
+	switch n.Type {
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		converted := make(map[string]interface{}, len(record))
+		for k, v := range record {
+			converted[k] = v
+		}
+		for _, f := range n.Fields {
+			if v, found := record[f.Name]; found {
+				if converted[f.Name], err = s.encode(f.Node, v, fieldPath(path, f.Name)); err != nil {
+					return
+				}
+			}
+		}
+		return converted, nil
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		converted := make([]interface{}, len(items))
+		for i, v := range items {
+			if converted[i], err = s.encode(n.Items, v, indexPath(path, strconv.Itoa(i))); err != nil {
+				return
+			}
+		}
+		return converted, nil
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		converted := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			if converted[k], err = s.encode(n.Values, v, indexPath(path, k)); err != nil {
+				return
+			}
+		}
+		return converted, nil
+	case "union":
+		return s.encodeUnion(n, value, path)
+	}
+	return value, nil
+}
+
+// encodeUnion converts the value in a goavro.Union, or wraps a Go value of a logical type in the
+// branch which accepts it.
+func (s *logicalSchema) encodeUnion(n *avroschema.Node, value interface{}, path string) (native interface{}, err error) {
+
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, v := range wrapped {
+			if b := n.Branch(name); b != nil {
+				if v, err = s.encode(b, v, path); err != nil {
+					return
+				}
+				return map[string]interface{}{name: v}, nil
+			}
+		}
+	}
+
+	for _, b := range n.Branches {
+		if conversion, found := s.conversions[b]; found && conversion.accepts(value) {
+			if value, err = s.encode(b, value, path); err != nil {
+				return
+			}
+			return goavro.Union(b.UnionName(), value), nil
+		}
+	}
+	return value, nil
+}
+
+func fieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(path string, index string) string {
+	return path + "[" + index + "]"
+}
@@ -0,0 +1,238 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const uuidSchema = `{"type":"record","name":"order","namespace":"com.example","fields":[
+	{"name":"id","type":{"type":"string","logicalType":"uuid"}},
+	{"name":"customer","type":["null",{"type":"string","logicalType":"uuid"}]},
+	{"name":"lines","type":{"type":"array","items":{"type":"record","name":"line","fields":[
+		{"name":"product","type":{"type":"string","logicalType":"uuid"}},
+		{"name":"quantity","type":"int"}]}}},
+	{"name":"next","type":["null","order"],"default":null}]}`
+
+func newLogicalCodec(t *testing.T, schema string, options ...Option) *Codec {
+	registry := mockregistry.New()
+	registry.Register("orders-value", schema)
+	return NewCodec(registry, TopicNameStrategy{}, options...)
+}
+
+func TestLogicalTypesUUID(t *testing.T) {
+
+	id, customer, product := uuid.New(), uuid.New(), uuid.New()
+
+	var tests = []struct {
+		name  string
+		value map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			"uuids",
+			map[string]interface{}{"id": id, "customer": customer, "lines": []interface{}{map[string]interface{}{"product": product, "quantity": 1}}, "next": nil},
+			map[string]interface{}{"id": id, "customer": map[string]interface{}{"string": customer}, "lines": []interface{}{map[string]interface{}{"product": product, "quantity": int32(1)}}, "next": nil},
+		},
+		{
+			"strings and a null",
+			map[string]interface{}{"id": id.String(), "customer": nil, "lines": []interface{}{}, "next": nil},
+			map[string]interface{}{"id": id, "customer": nil, "lines": []interface{}{}, "next": nil},
+		},
+		{
+			"unions",
+			map[string]interface{}{"id": id, "customer": goavro.Union("string", customer.String()), "lines": []interface{}{},
+				"next": goavro.Union("com.example.order", map[string]interface{}{"id": product, "customer": customer, "lines": []interface{}{}, "next": nil})},
+			map[string]interface{}{"id": id, "customer": map[string]interface{}{"string": customer}, "lines": []interface{}{},
+				"next": map[string]interface{}{"com.example.order": map[string]interface{}{"id": product, "customer": map[string]interface{}{"string": customer}, "lines": []interface{}{}, "next": nil}}},
+		},
+	}
+
+	codec := newLogicalCodec(t, uuidSchema, WithLogicalTypes())
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			data, err := codec.Encode("orders", false, test.value)
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+			native, err := codec.Decode("orders", false, data)
+			if err != nil {
+				t.Fatalf("Decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(native, test.want) {
+				t.Errorf("Decode() returned %#v, want %#v", native, test.want)
+			}
+		})
+	}
+}
+
+func TestLogicalTypesUUIDDoesNotModifyTheValue(t *testing.T) {
+
+	id := uuid.New()
+	value := map[string]interface{}{"id": id, "customer": id, "lines": []interface{}{map[string]interface{}{"product": id, "quantity": 1}}, "next": nil}
+
+	if _, err := newLogicalCodec(t, uuidSchema, WithLogicalTypes()).Encode("orders", false, value); err != nil {
+		t.Fatal(err)
+	}
+	if value["id"] != id || value["customer"] != id || value["lines"].([]interface{})[0].(map[string]interface{})["product"] != id {
+		t.Errorf("Encode() modified the value to %v", value)
+	}
+}
+
+func TestLogicalTypesInvalidUUID(t *testing.T) {
+
+	id := uuid.New()
+
+	var tests = []struct {
+		value map[string]interface{}
+		path  string
+	}{
+		{map[string]interface{}{"id": "42", "customer": nil, "lines": []interface{}{}, "next": nil}, "id"},
+		{map[string]interface{}{"id": id, "customer": goavro.Union("string", "not a uuid"), "lines": []interface{}{}, "next": nil}, "customer"},
+		{map[string]interface{}{"id": id, "customer": nil, "next": nil, "lines": []interface{}{
+			map[string]interface{}{"product": id, "quantity": 1}, map[string]interface{}{"product": "", "quantity": 1}}}, "lines[1].product"},
+	}
+
+	codec := newLogicalCodec(t, uuidSchema, WithLogicalTypes())
+
+	for _, test := range tests {
+		_, err := codec.Encode("orders", false, test.value)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != test.path || !errors.Is(err, ErrInvalidUUID) {
+			t.Errorf("Encode(%v) returned %v, want an ErrInvalidUUID of field %v", test.value, err, test.path)
+		}
+	}
+
+	// a producer which does not validate the uuids
+	data, err := newLogicalCodec(t, uuidSchema).Encode("orders", false, tests[0].value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = codec.Decode("orders", false, data)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "id" || !errors.Is(err, ErrInvalidUUID) {
+		t.Errorf("Decode() returned %v, want an ErrInvalidUUID of field id", err)
+	}
+}
+
+func TestWithoutLogicalTypes(t *testing.T) {
+
+	id := uuid.NewString()
+	value := map[string]interface{}{"id": id, "customer": goavro.Union("string", "not a uuid"), "lines": []interface{}{}, "next": nil}
+
+	codec := newLogicalCodec(t, uuidSchema)
+	data, err := codec.Encode("orders", false, value)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	native, err := codec.Decode("orders", false, data)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if got := native.(map[string]interface{})["id"]; got != id {
+		t.Errorf("Decode() returned id %#v, want the string %v", got, id)
+	}
+}
+
+func TestLogicalTypesTime(t *testing.T) {
+
+	brussels := time.FixedZone("CET", 2*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+
+	var tests = []struct {
+		typ   string
+		value interface{}
+		avro  interface{} // the value of the underlying type
+		want  interface{}
+	}{
+		{`{"type":"int","logicalType":"date"}`, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), int32(0), time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), int32(-1), time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC), int32(-1), time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(1900, 3, 1, 23, 59, 0, 0, time.UTC), int32(-25508), time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(2024, 1, 1, 0, 30, 0, 0, brussels), int32(19723), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(2023, 12, 31, 23, 30, 0, 0, newYork), int32(19722), time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, 19723, int32(19723), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		{`{"type":"int","logicalType":"time-millis"}`, time.Duration(0), int32(0), time.Duration(0)},
+		{`{"type":"int","logicalType":"time-millis"}`, 24*time.Hour - time.Millisecond, int32(86399999), 24*time.Hour - time.Millisecond},
+		{`{"type":"int","logicalType":"time-millis"}`, 1500 * time.Microsecond, int32(1), time.Millisecond},
+		{`{"type":"long","logicalType":"time-micros"}`, 24*time.Hour - time.Microsecond, int64(86399999999), 24*time.Hour - time.Microsecond},
+		{`{"type":"long","logicalType":"time-micros"}`, 1234567 * time.Microsecond, int64(1234567), 1234567 * time.Microsecond},
+
+		{`{"type":"long","logicalType":"timestamp-micros"}`, time.Date(1970, 1, 1, 0, 0, 0, 1000, time.UTC), int64(1), time.Date(1970, 1, 1, 0, 0, 0, 1000, time.UTC)},
+		{`{"type":"long","logicalType":"timestamp-micros"}`, time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC), int64(-1), time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC)},
+		{`{"type":"long","logicalType":"timestamp-micros"}`, time.Date(2024, 1, 1, 2, 0, 0, 123456789, brussels), int64(1704067200123456), time.Date(2024, 1, 1, 0, 0, 0, 123456000, time.UTC)},
+		{`{"type":"long","logicalType":"timestamp-millis"}`, time.Date(1969, 12, 31, 23, 59, 59, 999999000, time.UTC), int64(-1), time.Date(1969, 12, 31, 23, 59, 59, 999000000, time.UTC)},
+		{`{"type":"long","logicalType":"timestamp-millis"}`, time.Date(2023, 12, 31, 19, 0, 0, 0, newYork), int64(1704067200000), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{`["null",{"type":"long","logicalType":"timestamp-micros"}]`, time.Date(1970, 1, 1, 0, 0, 0, 1000, time.UTC), map[string]interface{}{"long": int64(1)},
+			map[string]interface{}{"long.timestamp-micros": time.Date(1970, 1, 1, 0, 0, 0, 1000, time.UTC)}},
+		{`["null",{"type":"int","logicalType":"time-millis"}]`, time.Second, map[string]interface{}{"int": int32(1000)}, map[string]interface{}{"int.time-millis": time.Second}},
+	}
+
+	for _, test := range tests {
+
+		schema := `{"type":"record","name":"r","fields":[{"name":"v","type":` + test.typ + `}]}`
+		codec := newLogicalCodec(t, schema, WithLogicalTypes())
+
+		data, err := codec.Encode("orders", false, map[string]interface{}{"v": test.value})
+		if err != nil {
+			t.Errorf("%v: Encode(%v) failed: %v", test.typ, test.value, err)
+			continue
+		}
+
+		// the schema without the logical types decodes the value of the underlying type
+		underlying := strings.NewReplacer(`,"logicalType":"date"`, "", `,"logicalType":"time-millis"`, "", `,"logicalType":"time-micros"`, "",
+			`,"logicalType":"timestamp-millis"`, "", `,"logicalType":"timestamp-micros"`, "").Replace(schema)
+		avro, err := goavro.NewCodec(underlying)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _, err := avro.NativeFromBinary(data[headerSize:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := raw.(map[string]interface{})["v"]; !reflect.DeepEqual(got, test.avro) {
+			t.Errorf("%v: Encode(%v) wrote %#v, want %#v", test.typ, test.value, got, test.avro)
+		}
+
+		native, err := codec.Decode("orders", false, data)
+		if err != nil {
+			t.Errorf("%v: Decode() failed: %v", test.typ, err)
+			continue
+		}
+		if got := native.(map[string]interface{})["v"]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: Decode() returned %v, want %v", test.typ, got, test.want)
+		}
+	}
+}
+
+func TestLogicalTypesInvalidTime(t *testing.T) {
+
+	var tests = []struct {
+		typ   string
+		value interface{}
+	}{
+		{`{"type":"int","logicalType":"time-millis"}`, 24 * time.Hour},
+		{`{"type":"int","logicalType":"time-millis"}`, -time.Millisecond},
+		{`{"type":"long","logicalType":"time-micros"}`, 25 * time.Hour},
+		{`{"type":"long","logicalType":"timestamp-micros"}`, time.Date(300000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{`{"type":"int","logicalType":"date"}`, time.Date(-6000000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schema := `{"type":"record","name":"r","fields":[{"name":"v","type":` + test.typ + `}]}`
+		_, err := newLogicalCodec(t, schema, WithLogicalTypes()).Encode("orders", false, map[string]interface{}{"v": test.value})
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != "v" || !errors.Is(err, ErrInvalidTime) {
+			t.Errorf("%v: Encode(%v) returned %v, want an ErrInvalidTime of field v", test.typ, test.value, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"time"
+)
+
+// Header is a header of a Kafka message.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// DecodedMessage is a Kafka message with its key and value decoded with their writer schemas, see
+// DecodeMessage and confluent.DecodeMessage. A key which is not in the wire format, e.g. a string
+// key, is the data as is and a null key or value is nil, their SchemaInfo is then the zero
+// SchemaInfo. The partition, offset, timestamp and headers are the ones of the Kafka message, they
+// are only set by the wrappers of the Kafka clients.
+type DecodedMessage struct {
+	Topic       string
+	Partition   int32
+	Offset      int64
+	Timestamp   time.Time
+	Headers     []Header
+	Key         interface{}
+	Value       interface{}
+	KeySchema   SchemaInfo
+	ValueSchema SchemaInfo
+}
+
+// Header returns the value of the first header of the message with the name.
+func (m DecodedMessage) Header(name string) (value []byte, found bool) {
+	return headerValue(m.Headers, name)
+}
+
+// DecodeWithSchemaInfo decodes like Decode and returns the value along with its writer schema.
+func (c *Codec) DecodeWithSchemaInfo(topic string, isKey bool, data []byte) (native interface{}, info SchemaInfo, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		native, info, err = r.DecodeWithSchemaInfo(topic, isKey, data)
+		return native, info, r.registryError(err)
+	}
+
+	if native, err = c.Decode(topic, isKey, data); err != nil {
+		return
+	}
+	// Decode observed the schema
+	schemaID, _ := parseHeader(data)
+	info, _ = c.observed.get(observedKey{topic, schemaID})
+	info.Subject = c.Subject(topic, isKey)
+	return
+}
+
+// DecodeMessage decodes the key and value of a message of the topic, e.g. to archive it with an
+// OCFWriter.
+func (c *Codec) DecodeMessage(topic string, key []byte, value []byte) (message DecodedMessage, err error) {
+
+	message.Topic = topic
+	if inWireFormat(key) {
+		if message.Key, message.KeySchema, err = c.DecodeWithSchemaInfo(topic, true, key); err != nil {
+			return message, fmt.Errorf("failed to decode the key: %w", err)
+		}
+	} else if key != nil {
+		message.Key = key
+	}
+	if value != nil {
+		if message.Value, message.ValueSchema, err = c.DecodeWithSchemaInfo(topic, false, value); err != nil {
+			return message, fmt.Errorf("failed to decode the value: %w", err)
+		}
+	}
+	return
+}
+
+// inWireFormat returns true if the data starts with the header of the wire format.
+func inWireFormat(data []byte) bool {
+	return len(data) >= headerSize && data[0] == 0
+}
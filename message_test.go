@@ -0,0 +1,53 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestDecodeMessage(t *testing.T) {
+
+	registry := mockregistry.New()
+	keyID := registry.Register("orders-key", `"string"`)
+	valueID := registry.Register("orders-value", ocfSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	key, err := codec.Encode("orders", true, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySchema := SchemaInfo{ID: keyID, Subject: "orders-key", Schema: `"string"`, SchemaType: "AVRO"}
+	valueSchema := SchemaInfo{ID: valueID, Subject: "orders-value", Schema: ocfSchema, RecordName: "Order", SchemaType: "AVRO"}
+
+	tests := []struct {
+		name       string
+		key, value []byte
+		want       DecodedMessage
+	}{
+		{"avro key", key, value, DecodedMessage{Topic: "orders", Key: "order-1", Value: order(1), KeySchema: keySchema, ValueSchema: valueSchema}},
+		{"string key", []byte("order-1"), value, DecodedMessage{Topic: "orders", Key: []byte("order-1"), Value: order(1), ValueSchema: valueSchema}},
+		{"null key", nil, value, DecodedMessage{Topic: "orders", Value: order(1), ValueSchema: valueSchema}},
+		{"tombstone", key, nil, DecodedMessage{Topic: "orders", Key: "order-1", KeySchema: keySchema}},
+	}
+	for _, test := range tests {
+		message, err := codec.DecodeMessage("orders", test.key, test.value)
+		if err != nil || !reflect.DeepEqual(message, test.want) {
+			t.Errorf("%v: DecodeMessage() returned %+v, %v, want %+v", test.name, message, err, test.want)
+		}
+	}
+
+	if _, err = codec.DecodeMessage("orders", key, []byte("not avro")); !errors.Is(err, ErrUnknownMagicByte) || !strings.Contains(err.Error(), "value") {
+		t.Errorf("DecodeMessage() of a value which is not avro returned %v", err)
+	}
+	if _, err = codec.DecodeMessage("orders", []byte{0, 0, 0, 0, 9, 0}, value); !errors.Is(err, ErrSchemaNotFound) || !strings.Contains(err.Error(), "key") {
+		t.Errorf("DecodeMessage() of a key with an unknown schema returned %v", err)
+	}
+}
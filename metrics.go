@@ -0,0 +1,32 @@
+package kafkaavro
+
+import "time"
+
+// The registry operations reported to the MetricsHook.
+const (
+	OperationGetSchemaByID   = "get_schema_by_id"
+	OperationGetLatestSchema = "get_latest_schema"
+)
+
+// MetricsHook receives the outcome of the operations of a Codec, e.g. to export them as metrics
+// (see the kafkaavroprom package for Prometheus). The methods are called on the goroutine of the
+// operation, so they must be cheap and safe for concurrent use.
+type MetricsHook interface {
+	// Decoded is called after every Decode or DecodeReuse with its error (nil on success).
+	Decoded(err error)
+	// Encoded is called after every Encode with its error (nil on success).
+	Encoded(err error)
+	// SchemaCacheLookup is called for every lookup of a schema in the cache.
+	SchemaCacheLookup(hit bool)
+	// RegistryRequest is called after every request to the schema registry.
+	RegistryRequest(operation string, duration time.Duration, err error)
+	// SchemaCacheSize is called with the number of cached schemas whenever a schema is added.
+	SchemaCacheSize(size int)
+}
+
+// WithMetrics reports the operations of the Codec to the hook.
+func WithMetrics(hook MetricsHook) Option {
+	return func(c *Codec) {
+		c.metrics = hook
+	}
+}
@@ -0,0 +1,234 @@
+// Package mockregistry is an in memory schema registry for tests of code using a kafkaavro.Codec.
+// Every call can be scripted to fail or return another schema, and the calls are recorded:
+//
+//	registry := mockregistry.New()
+//	id := registry.Register("orders-value", schema)
+//	registry.Script(mockregistry.GetSchemaByID, mockregistry.Fail(mockregistry.ErrUnavailable), mockregistry.Fail(mockregistry.ErrUnavailable))
+//	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+package mockregistry
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// The methods of the registry which can be scripted.
+const (
+	GetSchemaByID      = "GetSchemaByID"
+	GetLatestSchema    = "GetLatestSchema"
+	GetSchemaBySubject = "GetSchemaBySubject"
+)
+
+// The errors of the schema registry, they match the errors of the schemaregistry package with errors.Is.
+var (
+	ErrUnavailable     = schemaregistry.ResourceError{ErrorCode: 503, Message: "Service Unavailable"}
+	ErrSubjectNotFound = schemaregistry.ResourceError{ErrorCode: 40401, Message: "Subject not found"}
+	ErrSchemaNotFound  = schemaregistry.ResourceError{ErrorCode: 40403, Message: "Schema not found"}
+	ErrVersionNotFound = schemaregistry.ResourceError{ErrorCode: 40402, Message: "Version not found"}
+)
+
+// Response is a scripted response of a call.
+type Response struct {
+	Schema schemaregistry.Schema
+	Err    error
+}
+
+// Fail is a scripted failure.
+func Fail(err error) Response {
+	return Response{Err: err}
+}
+
+// Return is a scripted schema, which is returned instead of the registered one.
+func Return(schema schemaregistry.Schema) Response {
+	return Response{Schema: schema}
+}
+
+// Call is a recorded call to the registry.
+type Call struct {
+	Method  string
+	ID      int
+	Subject string
+	Err     error
+}
+
+// Registry is a mock schema registry, it is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	schemas  map[int]schemaregistry.Schema
+	subjects map[string][]schemaregistry.Schema
+	scripts  map[string][]Response
+	calls    []Call
+	name     string
+}
+
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{
+		schemas:  make(map[int]schemaregistry.Schema),
+		subjects: make(map[string][]schemaregistry.Schema),
+		scripts:  make(map[string][]Response),
+	}
+}
+
+// NewNamed creates an empty registry of which String returns the name, e.g. to tell the registries
+// of kafkaavro.WithRegistryRouter apart.
+func NewNamed(name string) *Registry {
+	registry := New()
+	registry.name = name
+	return registry
+}
+
+// String returns the name of the registry, mockregistry for the registries of New.
+func (r *Registry) String() string {
+	if r.name == "" {
+		return "mockregistry"
+	}
+	return r.name
+}
+
+// Register registers the schema as the latest version of the subject and returns its id. A schema
+// which is registered already (under any subject) keeps its id.
+func (r *Registry) Register(subject string, avroSchema string) (id int) {
+	return r.RegisterSchema(subject, schemaregistry.Schema{Schema: avroSchema})
+}
+
+// RegisterSchema registers the schema with its schema type and references, e.g. a .proto file,
+// like Register. The subject, version and id of the schema are ignored.
+func (r *Registry) RegisterSchema(subject string, schema schemaregistry.Schema) (id int) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for existingID, existing := range r.schemas {
+		if existing.Schema == schema.Schema && existing.Type() == schema.Type() {
+			id = existingID
+		}
+	}
+	if id == 0 {
+		id = len(r.schemas) + 1
+		r.schemas[id] = schemaregistry.Schema{Schema: schema.Schema, ID: id, SchemaType: schema.SchemaType, References: schema.References}
+	}
+
+	registered := r.schemas[id]
+	versions := r.subjects[subject]
+	registered.Subject, registered.Version = subject, len(versions)+1
+	r.subjects[subject] = append(versions, registered)
+	return
+}
+
+// Script queues responses for the next calls of the method, after the responses which were
+// scripted before. Once the responses are used up, the calls are served from the registered schemas.
+func (r *Registry) Script(method string, responses ...Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scripts[method] = append(r.scripts[method], responses...)
+}
+
+// Calls returns the calls made so far.
+func (r *Registry) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallCount returns the number of calls of the method made so far.
+func (r *Registry) CallCount(method string) (count int) {
+	for _, call := range r.Calls() {
+		if call.Method == method {
+			count++
+		}
+	}
+	return
+}
+
+// Reset forgets the recorded calls and the scripted responses.
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+	r.scripts = make(map[string][]Response)
+}
+
+// scripted returns the next scripted response of the method, the caller holds the lock.
+func (r *Registry) scripted(method string) (response Response, found bool) {
+	if responses := r.scripts[method]; len(responses) > 0 {
+		response, found = responses[0], true
+		r.scripts[method] = responses[1:]
+	}
+	return
+}
+
+// GetSchemaByID returns the schema with the id.
+func (r *Registry) GetSchemaByID(id int) (avroSchema string, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schema, err := r.getSchema(id)
+	return schema.Schema, err
+}
+
+// GetSchema returns the schema with the id along with its schema type and references. It is the
+// same request as GetSchemaByID, it is scripted and recorded as a GetSchemaByID call.
+func (r *Registry) GetSchema(id int) (schema schemaregistry.Schema, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.getSchema(id)
+}
+
+// getSchema returns the schema with the id, the caller holds the lock.
+func (r *Registry) getSchema(id int) (schema schemaregistry.Schema, err error) {
+
+	if response, found := r.scripted(GetSchemaByID); found {
+		schema, err = response.Schema, response.Err
+	} else if registered, found := r.schemas[id]; found {
+		schema = schemaregistry.Schema{Schema: registered.Schema, ID: id, SchemaType: registered.SchemaType, References: registered.References}
+	} else {
+		err = schemaregistry.ResourceError{ErrorCode: ErrSchemaNotFound.ErrorCode, Message: fmt.Sprintf("Schema %d not found", id)}
+	}
+
+	r.calls = append(r.calls, Call{Method: GetSchemaByID, ID: id, Err: err})
+	return
+}
+
+// GetSchemaBySubject returns the version of the subject.
+func (r *Registry) GetSchemaBySubject(subject string, version int) (schema schemaregistry.Schema, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if response, found := r.scripted(GetSchemaBySubject); found {
+		schema, err = response.Schema, response.Err
+	} else if versions := r.subjects[subject]; version >= 1 && version <= len(versions) {
+		schema = versions[version-1]
+	} else if len(versions) == 0 {
+		err = schemaregistry.ResourceError{ErrorCode: ErrSubjectNotFound.ErrorCode, Message: fmt.Sprintf("Subject %v not found", subject)}
+	} else {
+		err = schemaregistry.ResourceError{ErrorCode: ErrVersionNotFound.ErrorCode, Message: fmt.Sprintf("Version %d of subject %v not found", version, subject)}
+	}
+
+	r.calls = append(r.calls, Call{Method: GetSchemaBySubject, Subject: subject, Err: err})
+	return
+}
+
+// GetLatestSchema returns the latest version of the subject.
+func (r *Registry) GetLatestSchema(subject string) (schema schemaregistry.Schema, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if response, found := r.scripted(GetLatestSchema); found {
+		schema, err = response.Schema, response.Err
+	} else if versions := r.subjects[subject]; len(versions) > 0 {
+		schema = versions[len(versions)-1]
+	} else {
+		err = schemaregistry.ResourceError{ErrorCode: ErrSubjectNotFound.ErrorCode, Message: fmt.Sprintf("Subject %v not found", subject)}
+	}
+
+	r.calls = append(r.calls, Call{Method: GetLatestSchema, Subject: subject, Err: err})
+	return
+}
@@ -0,0 +1,127 @@
+package mockregistry_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const testSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"}]}`
+
+func TestRegistry(t *testing.T) {
+
+	registry := mockregistry.New()
+	if id := registry.Register("test-value", testSchema); id != 1 {
+		t.Errorf("Register() returned id %d, want 1", id)
+	}
+	if id := registry.Register("copy-value", testSchema); id != 1 {
+		t.Errorf("Register() of a registered schema returned id %d, want 1", id)
+	}
+	if id := registry.Register("test-value", `"string"`); id != 2 {
+		t.Errorf("Register() of a new version returned id %d, want 2", id)
+	}
+
+	latest, err := registry.GetLatestSchema("test-value")
+	if want := (schemaregistry.Schema{Subject: "test-value", Version: 2, ID: 2, Schema: `"string"`}); err != nil || !reflect.DeepEqual(latest, want) {
+		t.Errorf("GetLatestSchema() returned %+v, %v, want %+v", latest, err, want)
+	}
+	if _, err = registry.GetLatestSchema("missing-value"); !schemaregistry.IsSubjectNotFound(err) {
+		t.Errorf("GetLatestSchema() of an unknown subject returned %v", err)
+	}
+	if _, err = registry.GetSchemaByID(3); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("GetSchemaByID() of an unknown id returned %v", err)
+	}
+}
+
+func TestRegistryScript(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("test-value", testSchema)
+	registry.Script(mockregistry.GetLatestSchema,
+		mockregistry.Fail(mockregistry.ErrUnavailable),
+		mockregistry.Fail(mockregistry.ErrUnavailable))
+
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	native := map[string]interface{}{"f1": "value"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := codec.Encode("test", false, native); !errors.Is(err, kafkaavro.ErrRegistryUnavailable) {
+			t.Errorf("Encode() %d returned %v, want ErrRegistryUnavailable", i, err)
+		}
+	}
+	data, err := codec.Encode("test", false, native)
+	if err != nil {
+		t.Fatalf("Encode() after the scripted failures returned %v", err)
+	}
+
+	registry.Script(mockregistry.GetSchemaByID, mockregistry.Return(schemaregistry.Schema{Schema: `"string"`}))
+	// the record with a single string field is encoded like a string
+	if decoded, err := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{}).Decode("test", false, data); err != nil || decoded != "value" {
+		t.Errorf("Decode() with a scripted string schema returned %v, %v", decoded, err)
+	}
+
+	want := []mockregistry.Call{
+		{Method: mockregistry.GetLatestSchema, Subject: "test-value", Err: mockregistry.ErrUnavailable},
+		{Method: mockregistry.GetLatestSchema, Subject: "test-value", Err: mockregistry.ErrUnavailable},
+		{Method: mockregistry.GetLatestSchema, Subject: "test-value"},
+		{Method: mockregistry.GetSchemaByID, ID: 1},
+	}
+	if calls := registry.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("Calls() returned %+v, want %+v", calls, want)
+	}
+	if count := registry.CallCount(mockregistry.GetLatestSchema); count != 3 {
+		t.Errorf("CallCount() returned %d, want 3", count)
+	}
+
+	registry.Reset()
+	if calls := registry.Calls(); len(calls) != 0 {
+		t.Errorf("Calls() after Reset() returned %+v", calls)
+	}
+}
+
+func TestRegistrySchemaTypes(t *testing.T) {
+
+	registry := mockregistry.New()
+	common := schemaregistry.Schema{Schema: `syntax = "proto3"; message Common {}`, SchemaType: schemaregistry.SchemaTypeProtobuf}
+	registry.RegisterSchema("common", common)
+	references := []schemaregistry.Reference{{Name: "common.proto", Subject: "common", Version: 1}}
+	id := registry.RegisterSchema("test-value", schemaregistry.Schema{Schema: `syntax = "proto3";`, SchemaType: schemaregistry.SchemaTypeProtobuf, References: references})
+
+	schema, err := registry.GetSchema(id)
+	want := schemaregistry.Schema{Schema: `syntax = "proto3";`, ID: id, SchemaType: schemaregistry.SchemaTypeProtobuf, References: references}
+	if err != nil || !reflect.DeepEqual(schema, want) {
+		t.Errorf("GetSchema() returned %+v, %v, want %+v", schema, err, want)
+	}
+	if count := registry.CallCount(mockregistry.GetSchemaByID); count != 1 {
+		t.Errorf("CallCount(GetSchemaByID) after GetSchema() returned %d, want 1", count)
+	}
+
+	schema, err = registry.GetSchemaBySubject("common", 1)
+	if err != nil || schema.Schema != common.Schema || schema.Subject != "common" || schema.Version != 1 || schema.Type() != schemaregistry.SchemaTypeProtobuf {
+		t.Errorf("GetSchemaBySubject() returned %+v, %v", schema, err)
+	}
+	if _, err = registry.GetSchemaBySubject("common", 2); !schemaregistry.IsSchemaNotFound(err) {
+		t.Errorf("GetSchemaBySubject() of an unknown version returned %v", err)
+	}
+	if _, err = registry.GetSchemaBySubject("missing", 1); !schemaregistry.IsSubjectNotFound(err) {
+		t.Errorf("GetSchemaBySubject() of an unknown subject returned %v", err)
+	}
+
+	// the same text with another schema type is another schema
+	if avroID := registry.Register("avro-value", `syntax = "proto3";`); avroID == id {
+		t.Errorf("Register() of an avro schema returned the id %d of the protobuf schema", avroID)
+	}
+}
+
+func TestRegistryString(t *testing.T) {
+	if name := mockregistry.New().String(); name != "mockregistry" {
+		t.Errorf("String() returned %v", name)
+	}
+	if name := mockregistry.NewNamed("acme").String(); name != "acme" {
+		t.Errorf("String() of a named registry returned %v", name)
+	}
+}
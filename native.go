@@ -0,0 +1,247 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// Union returns the goavro native value of a value of the branch of a union, e.g.
+// Union("com.acme.Address", address), or nil for the branch null. The branch of a named type is
+// its full name, that of a logical type goavro converts the type and logical type, e.g.
+// long.timestamp-millis, and that of another type the type, e.g. string.
+func Union(branch string, v interface{}) interface{} {
+	if branch == "null" {
+		return nil
+	}
+	return map[string]interface{}{branch: v}
+}
+
+// NullableString returns the native value of a union of null and string: nil for nil, the string
+// otherwise.
+func NullableString(s *string) interface{} {
+	return Nullable("string", s)
+}
+
+// Nullable returns the native value of a union of null and the branch: nil for nil, the value v
+// points to otherwise, e.g. Nullable("long", &id).
+func Nullable[T any](branch string, v *T) interface{} {
+	if v == nil {
+		return nil
+	}
+	return Union(branch, *v)
+}
+
+// FieldValue is the value of a field of a record, see Record.
+type FieldValue struct {
+	Name  string
+	Value interface{}
+}
+
+// Field returns the value of the field of a record.
+func Field(name string, value interface{}) FieldValue {
+	return FieldValue{Name: name, Value: value}
+}
+
+// Record returns the native value of a record with the values of the fields, the fields which
+// are not given take their default when encoded.
+func Record(fields ...FieldValue) map[string]interface{} {
+	record := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		record[f.Name] = f.Value
+	}
+	return record
+}
+
+// WrapForSchema returns the goavro native value of a plain value of the schema: the values of
+// unions are wrapped in their branch, the slices are converted to []interface{} and the maps to
+// map[string]interface{}. The branch of a value is the branch of the Go type of its native value,
+// e.g. int64 for a long and time.Time for a timestamp, and a record of which the value has the
+// fields without a default and no other fields. Values which are wrapped already are kept.
+//
+// A value which is not a value of any branch of its union fails with a *FieldError, and a value
+// which may be the value of several branches, e.g. of two records with the same fields, with a
+// *FieldError wrapping ErrAmbiguousUnion: wrap those with Union.
+func WrapForSchema(schema AvroSchema, plainValue map[string]interface{}) (native interface{}, err error) {
+
+	n, err := avroschema.Parse(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	return wrap(n, plainValue, "")
+}
+
+func wrap(n *avroschema.Node, value interface{}, path string) (native interface{}, err error) {
+
+	switch n.Type {
+
+	case "union":
+		return wrapUnion(n, value, path)
+
+	case "record":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		record := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			record[k] = v
+		}
+		for _, f := range n.Fields {
+			if v, found := fields[f.Name]; found {
+				if record[f.Name], err = wrap(f.Node, v, fieldPath(path, f.Name)); err != nil {
+					return
+				}
+			}
+		}
+		return record, nil
+
+	case "array":
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice {
+			return value, nil
+		}
+		array := make([]interface{}, items.Len())
+		for i := range array {
+			if array[i], err = wrap(n.Items, items.Index(i).Interface(), indexPath(path, fmt.Sprint(i))); err != nil {
+				return
+			}
+		}
+		return array, nil
+
+	case "map":
+		values := reflect.ValueOf(value)
+		if values.Kind() != reflect.Map || values.Type().Key().Kind() != reflect.String {
+			return value, nil
+		}
+		m := make(map[string]interface{}, values.Len())
+		for it := values.MapRange(); it.Next(); {
+			k := it.Key().String()
+			if m[k], err = wrap(n.Values, it.Value().Interface(), indexPath(path, k)); err != nil {
+				return
+			}
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+func wrapUnion(n *avroschema.Node, value interface{}, path string) (native interface{}, err error) {
+
+	if value == nil {
+		for _, b := range n.Branches {
+			if b.Type == "null" {
+				return nil, nil
+			}
+		}
+		return nil, &FieldError{Path: path, Err: fmt.Errorf("null is not a value of the union %v", branchNames(n.Branches))}
+	}
+
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, v := range wrapped {
+			if b := n.Branch(name); b != nil {
+				if v, err = wrap(b, v, path); err != nil {
+					return
+				}
+				return map[string]interface{}{name: v}, nil
+			}
+		}
+	}
+
+	// the branches of the Go type of the value, else the branches it converts to
+	var matches []*avroschema.Node
+	for _, exact := range []bool{true, false} {
+		for _, b := range n.Branches {
+			if b.Type != "null" && matchesBranch(b, value, exact) {
+				matches = append(matches, b)
+			}
+		}
+		if len(matches) > 0 {
+			break
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, &FieldError{Path: path, Err: fmt.Errorf("a %T is not a value of the union %v", value, branchNames(n.Branches))}
+	case 1:
+		if native, err = wrap(matches[0], value, path); err != nil {
+			return
+		}
+		return Union(matches[0].UnionName(), native), nil
+	}
+	return nil, &FieldError{Path: path, Err: fmt.Errorf("%w: the %T is a value of the branches %v, wrap it with Union", ErrAmbiguousUnion, value, branchNames(matches))}
+}
+
+func branchNames(branches []*avroschema.Node) string {
+	names := make([]string, len(branches))
+	for i, b := range branches {
+		names[i] = b.UnionName()
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// matchesBranch returns whether the value is a value of the branch: exact for the Go type of
+// the native values of the branch, otherwise for the Go types goavro converts, e.g. an int or a
+// float32 for a double.
+func matchesBranch(b *avroschema.Node, value interface{}, exact bool) bool {
+
+	switch b.Type + "." + b.Logical {
+	case "int.date", "long.timestamp-millis", "long.timestamp-micros":
+		_, ok := value.(time.Time)
+		return ok
+	case "int.time-millis", "long.time-micros":
+		_, ok := value.(time.Duration)
+		return ok
+	case "bytes.decimal", "fixed.decimal":
+		_, ok := value.(*big.Rat)
+		return ok
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return b.Type == "boolean"
+	case int32:
+		return b.Type == "int" || !exact && b.Type == "long"
+	case int64:
+		return b.Type == "long"
+	case int:
+		return !exact && (b.Type == "int" || b.Type == "long")
+	case float32:
+		return b.Type == "float" || !exact && b.Type == "double"
+	case float64:
+		return b.Type == "double" || !exact && b.Type == "float"
+	case string:
+		return b.Type == "string" || b.Type == "enum" && hasSymbol(b, v)
+	case []byte:
+		return b.Type == "bytes" || b.Type == "fixed" && len(v) == b.Size
+	case map[string]interface{}:
+		return b.Type == "map" || b.Type == "record" && isRecordOf(b, v)
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Array:
+		return b.Type == "array"
+	case reflect.Map:
+		return b.Type == "map" && reflect.TypeOf(value).Key().Kind() == reflect.String
+	}
+	return false
+}
+
+// isRecordOf returns whether the fields are the fields of the record, at least those without a
+// default.
+func isRecordOf(record *avroschema.Node, fields map[string]interface{}) bool {
+	known := 0
+	for _, f := range record.Fields {
+		if _, found := fields[f.Name]; found {
+			known++
+		} else if !f.HasDefault {
+			return false
+		}
+	}
+	return known == len(fields)
+}
@@ -0,0 +1,135 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const wrapSchema = `{"type":"record","name":"Order","namespace":"com.example","fields":[
+	{"name":"id","type":"long"},
+	{"name":"note","type":["null","string"],"default":null},
+	{"name":"status","type":["null",{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]}],"default":null},
+	{"name":"placed","type":["null",{"type":"long","logicalType":"timestamp-millis"}],"default":null},
+	{"name":"quantity","type":["null","int","string"],"default":null},
+	{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[
+		{"name":"product","type":"string"},
+		{"name":"discount","type":["null","double"],"default":null}]}},"default":[]},
+	{"name":"tags","type":{"type":"map","values":["null","string"]},"default":{}},
+	{"name":"payment","type":["null",
+		{"type":"record","name":"Card","fields":[{"name":"number","type":"string"}]},
+		{"type":"record","name":"Transfer","fields":[{"name":"iban","type":"string"}]}],"default":null},
+	{"name":"address","type":["null",
+		{"type":"record","name":"Home","fields":[{"name":"street","type":"string"}]},
+		{"type":"record","name":"Office","fields":[{"name":"street","type":"string"}]}],"default":null}]}`
+
+func TestBuilders(t *testing.T) {
+
+	note := "fragile"
+	tests := []struct {
+		got  interface{}
+		want interface{}
+	}{
+		{Union("string", "a"), map[string]interface{}{"string": "a"}},
+		{Union("null", "a"), nil},
+		{NullableString(&note), map[string]interface{}{"string": "fragile"}},
+		{NullableString(nil), nil},
+		{Nullable[int64]("long", nil), nil},
+		{Record(Field("id", int64(1)), Field("note", nil)), map[string]interface{}{"id": int64(1), "note": nil}},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%d: got %v, want %v", i, test.got, test.want)
+		}
+	}
+}
+
+func TestWrapForSchema(t *testing.T) {
+
+	placed := time.UnixMilli(1700000000000).UTC()
+	plain := map[string]interface{}{
+		"id":       int64(1),
+		"note":     "fragile",
+		"status":   "OPEN",
+		"placed":   placed,
+		"quantity": 2,
+		"lines": []map[string]interface{}{
+			{"product": "p"},
+			{"product": "q", "discount": 0.1},
+		},
+		"tags":    map[string]string{"gift": "yes"},
+		"payment": map[string]interface{}{"iban": "BE00"},
+		"address": Union("com.example.Office", map[string]interface{}{"street": "Main"}),
+	}
+	want := map[string]interface{}{
+		"id":       int64(1),
+		"note":     map[string]interface{}{"string": "fragile"},
+		"status":   map[string]interface{}{"com.example.Status": "OPEN"},
+		"placed":   map[string]interface{}{"long.timestamp-millis": placed},
+		"quantity": map[string]interface{}{"int": 2},
+		"lines": []interface{}{
+			map[string]interface{}{"product": "p"},
+			map[string]interface{}{"product": "q", "discount": map[string]interface{}{"double": 0.1}},
+		},
+		"tags":    map[string]interface{}{"gift": map[string]interface{}{"string": "yes"}},
+		"payment": map[string]interface{}{"com.example.Transfer": map[string]interface{}{"iban": "BE00"}},
+		"address": map[string]interface{}{"com.example.Office": map[string]interface{}{"street": "Main"}},
+	}
+
+	native, err := WrapForSchema(wrapSchema, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(native, want) {
+		t.Errorf("WrapForSchema() returned %v, want %v", native, want)
+	}
+
+	// the wrapped value encodes and decodes to the wrapped value, with the defaults
+	codec := newLogicalCodec(t, wrapSchema)
+	data, err := codec.Encode("orders", false, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := codec.Decode("orders", false, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.(map[string]interface{})["payment"]; !reflect.DeepEqual(got, want["payment"]) {
+		t.Errorf("the payment decodes to %v", got)
+	}
+	if note := decoded.(map[string]interface{})["note"]; !reflect.DeepEqual(note, want["note"]) {
+		t.Errorf("the note decodes to %v", note)
+	}
+}
+
+func TestWrapForSchemaErrors(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		plain     map[string]interface{}
+		path      string
+		ambiguous bool
+	}{
+		{"two record branches", map[string]interface{}{"id": int64(1), "address": map[string]interface{}{"street": "Main"}}, "address", true},
+		{"in an array", map[string]interface{}{"id": int64(1), "lines": []interface{}{map[string]interface{}{"product": "p", "discount": "10%"}}}, "lines[0].discount", false},
+		{"in a map", map[string]interface{}{"id": int64(1), "tags": map[string]interface{}{"gift": 1}}, "tags[gift]", false},
+		{"no branch", map[string]interface{}{"id": int64(1), "note": 1}, "note", false},
+		{"not a symbol", map[string]interface{}{"id": int64(1), "status": "LOST"}, "status", false},
+		{"no record branch", map[string]interface{}{"id": int64(1), "payment": map[string]interface{}{"number": "1", "iban": "BE00"}}, "payment", false},
+		{"in a wrapped value", map[string]interface{}{"id": int64(1), "payment": map[string]interface{}{"com.example.Card": map[string]interface{}{"number": "1"}},
+			"lines": []interface{}{map[string]interface{}{"product": "p", "discount": true}}}, "lines[0].discount", false},
+	}
+
+	for _, test := range tests {
+		_, err := WrapForSchema(wrapSchema, test.plain)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != test.path || errors.Is(err, ErrAmbiguousUnion) != test.ambiguous {
+			t.Errorf("%v: WrapForSchema() returned %v, want an error of %v", test.name, err, test.path)
+		}
+	}
+
+	if _, err := WrapForSchema(`{"type":"record"`, nil); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("WrapForSchema() of an invalid schema returned %v", err)
+	}
+}
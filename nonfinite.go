@@ -0,0 +1,472 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// NonFinitePolicy is the JSON of the NaN and infinite values of float and double fields, which
+// avro carries but JSON has no numbers for, see WithNonFiniteFloats.
+type NonFinitePolicy string
+
+const (
+	// NonFiniteError fails DecodeToJSON with a *FieldError wrapping ErrNonFiniteFloat, the Path
+	// of which is the field, the default.
+	NonFiniteError NonFinitePolicy = "error"
+	// NonFiniteNull substitutes null, which is lossy: it encodes back to NaN, or to the null of a
+	// union with null.
+	NonFiniteNull NonFinitePolicy = "null"
+	// NonFiniteString substitutes the strings "NaN", "Infinity" and "-Infinity", like Jackson
+	// with WRITE_NAN_AS_STRINGS.
+	NonFiniteString NonFinitePolicy = "string"
+)
+
+// ParseNonFinitePolicy parses error, null or string, e.g. the value of a flag.
+func ParseNonFinitePolicy(policy string) (NonFinitePolicy, error) {
+	switch NonFinitePolicy(policy) {
+	case NonFiniteError, NonFiniteNull, NonFiniteString:
+		return NonFinitePolicy(policy), nil
+	}
+	return "", fmt.Errorf("unsupported non finite float policy %q, use %v, %v or %v", policy, NonFiniteError, NonFiniteNull, NonFiniteString)
+}
+
+// WithNonFiniteFloats sets the JSON of DecodeToJSON for the NaN and infinite values of float and
+// double fields, NonFiniteError by default. Encoder.EncodeTextual accepts the strings "NaN",
+// "Infinity" and "-Infinity" for those fields whatever the policy.
+func WithNonFiniteFloats(policy NonFinitePolicy) Option {
+	return func(c *Codec) {
+		c.nonFinite = policy
+	}
+}
+
+// the strings of NonFiniteString
+const (
+	nanString           = "NaN"
+	infinityString      = "Infinity"
+	minusInfinityString = "-Infinity"
+)
+
+// floatSchemaOf returns the schema of the codec of which the float and double nodes are marked,
+// see logicalSchema, nil if it has none.
+func floatSchemaOf(codec *goavro.Codec, cache *copyOnWriteMap[*goavro.Codec, *logicalSchema]) (s *logicalSchema, err error) {
+
+	if s, found := cache.get(codec); found {
+		return s, nil
+	}
+	if s, err = newFloatSchema(codec.Schema()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	cache.put(codec, s)
+	return
+}
+
+func newFloatSchema(schema AvroSchema) (s *logicalSchema, err error) {
+	s, err = newLogicalSchema(schema, func(n *avroschema.Node) (logicalConversion, bool) {
+		return logicalConversion{}, n.Type == "float" || n.Type == "double"
+	})
+	if err != nil || !s.converts[s.root] {
+		return nil, err
+	}
+	return
+}
+
+// nonFiniteText returns the JSON of the policy for a non finite float.
+func (policy NonFinitePolicy) nonFiniteText(f float64) string {
+	if policy != NonFiniteString {
+		return "null"
+	}
+	switch {
+	case math.IsNaN(f):
+		return strconv.Quote(nanString)
+	case f > 0:
+		return strconv.Quote(infinityString)
+	}
+	return strconv.Quote(minusInfinityString)
+}
+
+// textualFromNative returns the JSON of the codec, of which the non finite floats are encoded
+// following the policy. goavro encodes a NaN as null and the infinities as 1e999: the non finite
+// floats are replaced by 0, in place, and those zeros in the JSON by their JSON, found by their
+// JSON pointer. The JSON of a union is the bare value if standard is true.
+func (s *logicalSchema) textualFromNative(codec *goavro.Codec, native interface{}, policy NonFinitePolicy, standard bool) (textual []byte, err error) {
+
+	w := nonFiniteWalk{policy: policy, standard: standard, replacements: make(map[string]string)}
+	if native, err = w.replace(s, s.root, native, "", ""); err != nil {
+		return
+	}
+	if textual, err = codec.TextualFromNative(nil, native); err != nil || len(w.replacements) == 0 {
+		return
+	}
+	return replaceJSON(textual, w.replacements)
+}
+
+type nonFiniteWalk struct {
+	policy       NonFinitePolicy
+	standard     bool
+	replacements map[string]string // the JSON by JSON pointer
+}
+
+// replace returns the native value of which the non finite floats are replaced by 0, path is the
+// path of the value for a *FieldError and pointer its JSON pointer.
+func (w *nonFiniteWalk) replace(s *logicalSchema, n *avroschema.Node, native interface{}, path string, pointer string) (value interface{}, err error) {
+
+	if !s.converts[n] {
+		return native, nil
+	}
+
+	switch n.Type {
+	case "float", "double":
+		var f float64
+		switch v := native.(type) {
+		case float32:
+			f, value = float64(v), float32(0)
+		case float64:
+			f, value = v, float64(0)
+		default:
+			return native, nil
+		}
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return native, nil
+		}
+		if w.policy == NonFiniteError || w.policy == "" {
+			return nil, &FieldError{Path: path, Err: fmt.Errorf("%w: %v", ErrNonFiniteFloat, f)}
+		}
+		w.replacements[pointer] = w.policy.nonFiniteText(f)
+		return
+	case "record":
+		record, _ := native.(map[string]interface{})
+		for _, f := range n.Fields {
+			if v, found := record[f.Name]; found {
+				if record[f.Name], err = w.replace(s, f.Node, v, fieldPath(path, f.Name), jsonPointer(pointer, f.Name)); err != nil {
+					return
+				}
+			}
+		}
+	case "array":
+		items, _ := native.([]interface{})
+		for i, v := range items {
+			index := strconv.Itoa(i)
+			if items[i], err = w.replace(s, n.Items, v, indexPath(path, index), jsonPointer(pointer, index)); err != nil {
+				return
+			}
+		}
+	case "map":
+		values, _ := native.(map[string]interface{})
+		for k, v := range values {
+			if values[k], err = w.replace(s, n.Values, v, indexPath(path, k), jsonPointer(pointer, k)); err != nil {
+				return
+			}
+		}
+	case "union":
+		wrapped, _ := native.(map[string]interface{})
+		for name, v := range wrapped {
+			if b := n.Branch(name); b != nil {
+				branchPointer := pointer
+				if !w.standard {
+					branchPointer = jsonPointer(pointer, name)
+				}
+				if wrapped[name], err = w.replace(s, b, v, path, branchPointer); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return native, nil
+}
+
+// jsonPointer returns the JSON pointer (RFC 6901) of the member or item of the value at pointer.
+func jsonPointer(pointer string, token string) string {
+	return pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// jsonFrame is an object or array of replaceJSON.
+type jsonFrame struct {
+	object bool
+	key    string // of the value of an object
+	atKey  bool   // the next token of an object is a key
+	index  int    // of the next item of an array
+}
+
+// replaceJSON replaces the numbers at the JSON pointers of the JSON with their replacements.
+func replaceJSON(textual []byte, replacements map[string]string) (replaced []byte, err error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(textual))
+	decoder.UseNumber()
+
+	var stack []jsonFrame
+	pointer := func() string {
+		var p string
+		for _, frame := range stack {
+			if frame.object {
+				p = jsonPointer(p, frame.key)
+			} else {
+				p = jsonPointer(p, strconv.Itoa(frame.index))
+			}
+		}
+		return p
+	}
+	// next moves past a value of the innermost object or array
+	next := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := &stack[len(stack)-1]; top.object {
+			top.atKey = true
+		} else {
+			top.index++
+		}
+	}
+
+	replaced = make([]byte, 0, len(textual)+len(replacements)*8)
+	copied := 0
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			break
+		}
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+
+		if len(stack) > 0 && stack[len(stack)-1].atKey {
+			if key, ok := token.(string); ok {
+				stack[len(stack)-1].key, stack[len(stack)-1].atKey = key, false
+				continue
+			}
+		}
+
+		switch t := token.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, jsonFrame{object: true, atKey: true})
+			case '[':
+				stack = append(stack, jsonFrame{})
+			default:
+				stack = stack[:len(stack)-1]
+				next()
+			}
+		case json.Number:
+			if replacement, found := replacements[pointer()]; found {
+				end := int(decoder.InputOffset())
+				replaced = append(replaced, textual[copied:end-len(t)]...)
+				replaced = append(replaced, replacement...)
+				copied = end
+			}
+			next()
+		default:
+			next()
+		}
+	}
+	return append(replaced, textual[copied:]...), nil
+}
+
+// nonFiniteFloat is a float of the JSON of EncodeTextual given as a string, see nativeFromTextual.
+type nonFiniteFloat float64
+
+// MarshalJSON returns a number of the float and double types, which is replaced by the float.
+func (f nonFiniteFloat) MarshalJSON() ([]byte, error) {
+	return []byte("0"), nil
+}
+
+// parseNonFinite returns the float of "NaN", "Infinity" or "-Infinity".
+func parseNonFinite(value interface{}) (f nonFiniteFloat, ok bool) {
+	switch value {
+	case nanString:
+		return nonFiniteFloat(math.NaN()), true
+	case infinityString:
+		return nonFiniteFloat(math.Inf(1)), true
+	case minusInfinityString:
+		return nonFiniteFloat(math.Inf(-1)), true
+	}
+	return 0, false
+}
+
+// nativeFromTextual decodes the JSON with the codec, accepting the strings "NaN", "Infinity" and
+// "-Infinity" for the floats and doubles: those are replaced by 0 to be decoded by goavro and
+// the zeros of the native value by the floats. The JSON of a union is the bare value if standard
+// is true, a string of a union with a string branch or an enum of the symbol is that string.
+func (s *logicalSchema) nativeFromTextual(codec *goavro.Codec, textual []byte, standard bool) (native interface{}, err error) {
+
+	if !bytes.Contains(textual, []byte(nanString)) && !bytes.Contains(textual, []byte(infinityString)) {
+		native, _, err = codec.NativeFromTextual(textual)
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(textual))
+	decoder.UseNumber()
+	var document interface{}
+	if err = decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	found := false
+	if document = s.parseNonFinite(s.root, document, standard, &found); !found {
+		native, _, err = codec.NativeFromTextual(textual)
+		return
+	}
+	if textual, err = json.Marshal(document); err != nil {
+		return
+	}
+	if native, _, err = codec.NativeFromTextual(textual); err != nil {
+		return
+	}
+	return s.restoreNonFinite(s.root, document, native, standard), nil
+}
+
+// parseNonFinite replaces the strings of the non finite floats of the JSON document by their
+// nonFiniteFloat, in place.
+func (s *logicalSchema) parseNonFinite(n *avroschema.Node, document interface{}, standard bool, found *bool) interface{} {
+
+	if !s.converts[n] {
+		return document
+	}
+
+	switch n.Type {
+	case "float", "double":
+		if f, ok := parseNonFinite(document); ok {
+			*found = true
+			return f
+		}
+	case "record":
+		record, _ := document.(map[string]interface{})
+		for _, f := range n.Fields {
+			if v, ok := record[f.Name]; ok {
+				record[f.Name] = s.parseNonFinite(f.Node, v, standard, found)
+			}
+		}
+	case "array":
+		items, _ := document.([]interface{})
+		for i, v := range items {
+			items[i] = s.parseNonFinite(n.Items, v, standard, found)
+		}
+	case "map":
+		values, _ := document.(map[string]interface{})
+		for k, v := range values {
+			values[k] = s.parseNonFinite(n.Values, v, standard, found)
+		}
+	case "union":
+		if !standard {
+			wrapped, _ := document.(map[string]interface{})
+			for name, v := range wrapped {
+				if b := n.Branch(name); b != nil {
+					wrapped[name] = s.parseNonFinite(b, v, standard, found)
+				}
+			}
+			return document
+		}
+		if b := standardBranch(n, document); b != nil {
+			return s.parseNonFinite(b, document, standard, found)
+		}
+	}
+	return document
+}
+
+// standardBranch returns the branch of the union of the value of a standard JSON document with
+// floats, nil if it has none, following the branches in order as goavro does.
+func standardBranch(n *avroschema.Node, document interface{}) *avroschema.Node {
+
+	if _, ok := parseNonFinite(document); ok {
+		for _, b := range n.Branches {
+			if b.Type == "string" || b.Type == "enum" && hasSymbol(b, document.(string)) {
+				return nil
+			}
+		}
+	}
+	if _, ok := document.(string); ok {
+		return floatBranch(n)
+	}
+	for _, b := range n.Branches {
+		switch document.(type) {
+		case map[string]interface{}:
+			if b.Type == "record" || b.Type == "map" {
+				return b
+			}
+		case []interface{}:
+			if b.Type == "array" {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// floatBranch returns the first float or double branch of the union.
+func floatBranch(n *avroschema.Node) *avroschema.Node {
+	for _, b := range n.Branches {
+		if b.Type == "float" || b.Type == "double" {
+			return b
+		}
+	}
+	return nil
+}
+
+// restoreNonFinite returns the native value of which the values of the nonFiniteFloats of the
+// document are the floats.
+func (s *logicalSchema) restoreNonFinite(n *avroschema.Node, document interface{}, native interface{}, standard bool) interface{} {
+
+	if !s.converts[n] {
+		return native
+	}
+
+	switch n.Type {
+	case "float":
+		if f, ok := document.(nonFiniteFloat); ok {
+			return float32(f)
+		}
+	case "double":
+		if f, ok := document.(nonFiniteFloat); ok {
+			return float64(f)
+		}
+	case "record":
+		record, _ := native.(map[string]interface{})
+		fields, _ := document.(map[string]interface{})
+		for _, f := range n.Fields {
+			if v, ok := fields[f.Name]; ok {
+				record[f.Name] = s.restoreNonFinite(f.Node, v, record[f.Name], standard)
+			}
+		}
+	case "array":
+		items, _ := native.([]interface{})
+		documentItems, _ := document.([]interface{})
+		for i := range items {
+			if i < len(documentItems) {
+				items[i] = s.restoreNonFinite(n.Items, documentItems[i], items[i], standard)
+			}
+		}
+	case "map":
+		values, _ := native.(map[string]interface{})
+		documentValues, _ := document.(map[string]interface{})
+		for k, v := range values {
+			values[k] = s.restoreNonFinite(n.Values, documentValues[k], v, standard)
+		}
+	case "union":
+		if _, ok := document.(nonFiniteFloat); ok && standard {
+			// the 0 may have decoded to another numeric branch
+			b := floatBranch(n)
+			return goavro.Union(b.UnionName(), s.restoreNonFinite(b, document, nil, standard))
+		}
+		wrapped, _ := native.(map[string]interface{})
+		for name, v := range wrapped {
+			b := n.Branch(name)
+			if b == nil {
+				continue
+			}
+			branchDocument := document
+			if !standard {
+				documentWrapped, _ := document.(map[string]interface{})
+				branchDocument = documentWrapped[name]
+			}
+			wrapped[name] = s.restoreNonFinite(b, branchDocument, v, standard)
+		}
+	}
+	return native
+}
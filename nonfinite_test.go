@@ -0,0 +1,150 @@
+package kafkaavro
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const readingSchema = `{"type":"record","name":"reading","fields":[
+	{"name":"sensor","type":"string"},
+	{"name":"value","type":"double"},
+	{"name":"ratio","type":"float"},
+	{"name":"history","type":{"type":"array","items":"double"}},
+	{"name":"extra","type":["null","double"]},
+	{"name":"label","type":["double","string"]},
+	{"name":"byName","type":{"type":"map","values":"double"}}]}`
+
+func newReading() map[string]interface{} {
+	return map[string]interface{}{
+		"sensor":  "NaN",
+		"value":   math.NaN(),
+		"ratio":   float32(math.Inf(1)),
+		"history": []interface{}{1.5, math.Inf(-1)},
+		"extra":   map[string]interface{}{"double": math.NaN()},
+		"label":   map[string]interface{}{"string": "NaN"},
+		"byName":  map[string]interface{}{"a/b": math.Inf(1)},
+	}
+}
+
+func TestDecodeToJSONNonFinite(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("readings-value", readingSchema)
+	data, err := NewCodec(registry, TopicNameStrategy{}).Encode("readings", false, newReading())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		policy NonFinitePolicy
+		mode   JSONMode
+		want   string
+	}{
+		{NonFiniteNull, JSONModeAvro,
+			`{"sensor":"NaN","value":null,"ratio":null,"history":[1.5,null],"extra":{"double":null},"label":{"string":"NaN"},"byName":{"a/b":null}}`},
+		{NonFiniteNull, JSONModeStandard,
+			`{"sensor":"NaN","value":null,"ratio":null,"history":[1.5,null],"extra":null,"label":"NaN","byName":{"a/b":null}}`},
+		{NonFiniteString, JSONModeAvro,
+			`{"sensor":"NaN","value":"NaN","ratio":"Infinity","history":[1.5,"-Infinity"],"extra":{"double":"NaN"},"label":{"string":"NaN"},"byName":{"a/b":"Infinity"}}`},
+		{NonFiniteString, JSONModeStandard,
+			`{"sensor":"NaN","value":"NaN","ratio":"Infinity","history":[1.5,"-Infinity"],"extra":"NaN","label":"NaN","byName":{"a/b":"Infinity"}}`},
+	}
+
+	for _, test := range tests {
+		codec := NewCodec(registry, TopicNameStrategy{}, WithNonFiniteFloats(test.policy), WithJSONMode(test.mode))
+		if textual, err := codec.DecodeToJSON("readings", false, data); err != nil || !equalJSON(t, textual, test.want) {
+			t.Errorf("DecodeToJSON() with %v in %v mode returned %s, %v, want %s", test.policy, test.mode, textual, err, test.want)
+		}
+	}
+
+	// the first non finite float fails by default
+	for _, policy := range []NonFinitePolicy{"", NonFiniteError} {
+		codec := NewCodec(registry, TopicNameStrategy{}, WithNonFiniteFloats(policy))
+		_, err := codec.DecodeToJSON("readings", false, data)
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrNonFiniteFloat) || !errors.As(err, &fieldErr) || fieldErr.Path != "value" {
+			t.Errorf("DecodeToJSON() with %q returned %v", policy, err)
+		}
+	}
+	reading := newReading()
+	reading["value"], reading["ratio"], reading["extra"], reading["byName"] = 1.0, float32(2), nil, map[string]interface{}{}
+	if data, err = NewCodec(registry, TopicNameStrategy{}).Encode("readings", false, reading); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewCodec(registry, TopicNameStrategy{}).DecodeToJSON("readings", false, data)
+	if fieldErr := (*FieldError)(nil); !errors.As(err, &fieldErr) || fieldErr.Path != "history[1]" || ErrorType(err) != "ErrNonFiniteFloat" {
+		t.Errorf("DecodeToJSON() returned %v, want the error of history[1]", err)
+	}
+}
+
+func TestEncodeTextualNonFinite(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+	registry := mockregistry.New()
+	registry.Register("ok-value", readingSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+	want := fmt.Sprint(newReading())
+
+	var tests = []struct {
+		mode     JSONMode
+		textual  string
+		wantErr  bool
+		wantNull bool
+	}{
+		{JSONModeAvro, `{"sensor":"NaN","value":"NaN","ratio":"Infinity","history":[1.5,"-Infinity"],"extra":{"double":"NaN"},"label":{"string":"NaN"},"byName":{"a/b":"Infinity"}}`, false, false},
+		{JSONModeStandard, `{"sensor":"NaN","value":"NaN","ratio":"Infinity","history":[1.5,"-Infinity"],"extra":"NaN","label":"NaN","byName":{"a/b":"Infinity"}}`, false, false},
+		// the encoding of goavro
+		{JSONModeAvro, `{"sensor":"NaN","value":null,"ratio":1e999,"history":[1.5,-1e999],"extra":{"double":null},"label":{"string":"NaN"},"byName":{"a/b":1e999}}`, false, false},
+		// null is the null of a union with null
+		{JSONModeStandard, `{"sensor":"NaN","value":null,"ratio":"Infinity","history":[1.5,"-Infinity"],"extra":null,"label":"NaN","byName":{"a/b":"Infinity"}}`, false, true},
+		// the strings are not floats of a string field
+		{JSONModeAvro, `{"sensor":"NaN","value":"nan","ratio":"Infinity","history":[],"extra":null,"label":{"string":"NaN"},"byName":{}}`, true, false},
+	}
+
+	for _, test := range tests {
+		encoder, err := NewEncoder(*client, true, "ok-value", readingSchema, WithJSONMode(test.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := encoder.EncodeTextual([]byte(test.textual))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("EncodeTextual(%s) in %v mode did not fail", test.textual, test.mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("EncodeTextual(%s) in %v mode failed: %v", test.textual, test.mode, err)
+			continue
+		}
+		native, err := codec.Decode("ok", false, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.wantNull {
+			wantNull := newReading()
+			wantNull["extra"] = nil
+			if got := fmt.Sprint(native); got != fmt.Sprint(wantNull) {
+				t.Errorf("EncodeTextual(%s) in %v mode encoded %v", test.textual, test.mode, got)
+			}
+		} else if got := fmt.Sprint(native); got != want {
+			t.Errorf("EncodeTextual(%s) in %v mode encoded %v, want %v", test.textual, test.mode, got, want)
+		}
+	}
+}
+
+func TestParseNonFinitePolicy(t *testing.T) {
+	for _, policy := range []string{"error", "null", "string"} {
+		if parsed, err := ParseNonFinitePolicy(policy); err != nil || string(parsed) != policy {
+			t.Errorf("ParseNonFinitePolicy(%v) returned %v, %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseNonFinitePolicy("zero"); err == nil {
+		t.Error("ParseNonFinitePolicy(zero) did not fail")
+	}
+}
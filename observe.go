@@ -0,0 +1,91 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// SchemaInfo describes a writer schema. The zero SchemaInfo is no schema, e.g. the key schema of
+// a message with a key which is not in the wire format, see DecodedMessage.
+type SchemaInfo struct {
+	ID SchemaID
+	// Subject is the subject of the key or value of the topic, see Codec.Subject.
+	Subject SubjectName
+	// Version is the version of the schema under the subject, 0 when it is not known: the registry
+	// does not report the versions of a schema id.
+	Version SubjectVersion
+	Schema  AvroSchema
+	// RecordName is the full name of the record (or other named type) of the schema, if any.
+	RecordName string
+	// SchemaType is the schema type of the registry, e.g. AVRO or PROTOBUF.
+	SchemaType string
+}
+
+type observedKey struct {
+	topic    string
+	schemaID SchemaID
+}
+
+// observe records that the topic holds data written with the schema, and calls the
+// OnNewSchemaObserved hook the first time it does.
+func (c *Codec) observe(topic string, isKey bool, schemaID SchemaID, schema AvroSchema, schemaType string) {
+
+	if c.churn != nil && !isKey {
+		c.observeChurn(topic, schemaID)
+	}
+
+	key := observedKey{topic, schemaID}
+	if _, found := c.observed.get(key); found {
+		return
+	}
+
+	info := SchemaInfo{ID: schemaID, Subject: c.Subject(topic, isKey), Schema: schema, SchemaType: schemaType}
+	info.RecordName = recordName(info.Schema)
+
+	if c.observed.add(key, info) && c.hooks.OnNewSchemaObserved != nil {
+		c.callObserver(topic, info)
+	}
+}
+
+func (c *Codec) callObserver(topic string, info SchemaInfo) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil && c.logger != nil {
+			c.logger.Warn("hook panicked", "hook", "OnNewSchemaObserved", "panic", recovered)
+		}
+	}()
+
+	c.hooks.OnNewSchemaObserved(topic, info)
+}
+
+// ObservedSchemas returns the writer schemas of the data decoded so far, by topic and sorted by id.
+func (c *Codec) ObservedSchemas() map[string][]SchemaInfo {
+
+	observed := make(map[string][]SchemaInfo)
+	c.eachRegistry(func(codec *Codec) {
+		codec.observed.each(func(key observedKey, info SchemaInfo) {
+			observed[key.topic] = append(observed[key.topic], info)
+		})
+	})
+
+	for _, infos := range observed {
+		sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	}
+	return observed
+}
+
+// recordName returns the full name of the named type of the (canonical) schema.
+func recordName(avroSchema AvroSchema) string {
+
+	var named struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	}
+	if json.Unmarshal([]byte(avroSchema), &named) != nil || named.Name == "" {
+		return ""
+	}
+	if named.Namespace != "" {
+		return named.Namespace + "." + named.Name
+	}
+	return named.Name
+}
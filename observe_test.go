@@ -0,0 +1,81 @@
+package kafkaavro
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const namespacedSchema = `{"type":"record","name":"order","namespace":"com.example","fields":[{"name":"f1","type":"string"}]}`
+
+func TestOnNewSchemaObserved(t *testing.T) {
+
+	registry := &fakeRegistry{
+		schemas:  map[int]string{7: testSchema, 8: namespacedSchema, 9: `"string"`},
+		subjects: map[string][]int{"test-value": {7}, "orders-value": {8}, "names-value": {9}},
+	}
+
+	var mu sync.Mutex
+	var observed []string
+	codec := NewCodec(registry, TopicNameStrategy{}, WithHooks(Hooks{
+		OnNewSchemaObserved: func(topic string, info SchemaInfo) {
+			mu.Lock()
+			observed = append(observed, topic+" "+info.RecordName)
+			mu.Unlock()
+		},
+	}))
+
+	encode := func(topic string, native interface{}) []byte {
+		data, err := codec.Encode(topic, false, native)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	test := encode("test", map[string]interface{}{"f1": "value"})
+	orders := encode("orders", map[string]interface{}{"f1": "value"})
+	names := encode("names", "name")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scratch := &DecodeScratch{}
+			for j := 0; j < 10; j++ {
+				codec.Decode("test", false, test)
+				codec.DecodeReuse("orders", false, orders, scratch)
+				// the same data on another topic is observed for that topic too
+				codec.DecodeReuse("orders-copy", false, orders, scratch)
+			}
+		}()
+	}
+	wg.Wait()
+	codec.Decode("names", false, names)
+	codec.Decode("test", false, []byte{0, 0, 0, 0, 8, 0xff}) // fails to decode, so not observed
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(observed) != 4 {
+		t.Errorf("OnNewSchemaObserved was called %d times, want once per topic and schema: %v", len(observed), observed)
+	}
+
+	want := map[string][]SchemaInfo{
+		"test":        {{ID: 7, Subject: "test-value", RecordName: "myrecord", SchemaType: "AVRO"}},
+		"orders":      {{ID: 8, Subject: "orders-value", RecordName: "com.example.order", SchemaType: "AVRO"}},
+		"orders-copy": {{ID: 8, Subject: "orders-copy-value", RecordName: "com.example.order", SchemaType: "AVRO"}},
+		"names":       {{ID: 9, Subject: "names-value", SchemaType: "AVRO"}},
+	}
+	got := codec.ObservedSchemas()
+	for _, infos := range got {
+		for i := range infos {
+			if infos[i].Schema == "" {
+				t.Errorf("ObservedSchemas() returned %+v without the schema", infos[i])
+			}
+			infos[i].Schema = ""
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ObservedSchemas() returned %+v, want %+v", got, want)
+	}
+}
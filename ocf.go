@@ -0,0 +1,198 @@
+package kafkaavro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// CompressionCodec is the codec which compresses the blocks of an avro object container file.
+type CompressionCodec string
+
+// The compression codecs of the object container files.
+const (
+	CompressionNull    CompressionCodec = goavro.CompressionNullLabel
+	CompressionDeflate CompressionCodec = goavro.CompressionDeflateLabel
+	CompressionSnappy  CompressionCodec = goavro.CompressionSnappyLabel
+)
+
+// defaultOCFBlockSize is the number of records of a block, unless WithOCFBlockSize is used.
+const defaultOCFBlockSize = 100
+
+// OCFWriter appends the values of a schema to an avro object container file. The values are
+// buffered and written in blocks, of 100 values unless WithOCFBlockSize is used, or on Flush.
+// The values are goavro native values, decoded by a Codec without conversions (see
+// WithLogicalTypes). An OCFWriter is not safe for concurrent use.
+type OCFWriter struct {
+	w         io.Writer
+	schema    AvroSchema
+	canonical string
+	writer    *goavro.OCFWriter
+	blockSize int
+	block     []interface{}
+	closed    bool
+}
+
+// OCFWriterOption is an option of NewOCFWriter.
+type OCFWriterOption func(*OCFWriter)
+
+// WithOCFBlockSize sets the number of values of the blocks, larger blocks compress better but
+// are held in memory until they are written.
+func WithOCFBlockSize(values int) OCFWriterOption {
+	return func(w *OCFWriter) {
+		if values > 0 {
+			w.blockSize = values
+		}
+	}
+}
+
+// NewOCFWriter writes the header of an object container file of the schema to w.
+func NewOCFWriter(w io.Writer, schema AvroSchema, codec CompressionCodec, options ...OCFWriterOption) (writer *OCFWriter, err error) {
+
+	switch codec {
+	case CompressionNull, CompressionDeflate, CompressionSnappy:
+	case "":
+		codec = CompressionNull
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %q, use null, deflate or snappy", codec)
+	}
+
+	canonical, err := CanonicalForm(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{W: w, Schema: schema, CompressionName: string(codec)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+
+	writer = &OCFWriter{w: w, schema: schema, canonical: canonical, writer: ocf, blockSize: defaultOCFBlockSize}
+	for _, option := range options {
+		option(writer)
+	}
+	return
+}
+
+// Schema returns the schema of the file.
+func (w *OCFWriter) Schema() AvroSchema {
+	return w.schema
+}
+
+// Append appends a value of the schema of the file, it is written with the next block.
+func (w *OCFWriter) Append(native interface{}) error {
+
+	if w.closed {
+		return errOCFWriterClosed
+	}
+	w.block = append(w.block, native)
+	if len(w.block) >= w.blockSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// AppendDecoded appends the value of a decoded message. A message of another writer schema than
+// the schema of the file fails with ErrSchemaChanged: a file has one schema, roll a new file for
+// the new schema.
+func (w *OCFWriter) AppendDecoded(message DecodedMessage) error {
+
+	if schema := message.ValueSchema; schema.Schema != w.schema {
+		canonical, err := CanonicalForm(schema.Schema)
+		if err != nil || canonical != w.canonical {
+			return fmt.Errorf("%w: schema %d of the message of topic %v is not the schema of the file", ErrSchemaChanged, schema.ID, message.Topic)
+		}
+	}
+	return w.Append(message.Value)
+}
+
+// Flush writes the buffered values as a block.
+func (w *OCFWriter) Flush() (err error) {
+
+	if len(w.block) == 0 {
+		return nil
+	}
+	err = w.writer.Append(w.block)
+	w.block = w.block[:0]
+	return
+}
+
+// Close writes the buffered values and closes w if it is an io.Closer, e.g. the writer of an
+// object which is only stored when it is closed.
+func (w *OCFWriter) Close() (err error) {
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err = w.Flush()
+	if closer, ok := w.w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+var errOCFWriterClosed = errors.New("the OCF writer is closed")
+
+// OCFReader reads the values of an avro object container file, e.g. to replay an archive.
+type OCFReader struct {
+	reader *goavro.OCFReader
+}
+
+// NewOCFReader reads the header of the object container file.
+func NewOCFReader(r io.Reader) (reader *OCFReader, err error) {
+
+	ocf, err := goavro.NewOCFReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+	}
+	return &OCFReader{ocf}, nil
+}
+
+// Schema returns the schema of the file.
+func (r *OCFReader) Schema() AvroSchema {
+	return r.reader.Codec().Schema()
+}
+
+// Next returns the next value of the file, and io.EOF after the last one.
+func (r *OCFReader) Next() (native interface{}, err error) {
+
+	if !r.reader.Scan() {
+		if err = r.reader.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+		}
+		return nil, io.EOF
+	}
+	if native, err = r.reader.Read(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+	}
+	return
+}
+
+// Replay encodes the values of the file with the latest schema of the subject of the topic and
+// passes the data to produce, until the end of the file or the first error. The values must be
+// values of the latest schema, e.g. the latest schema is the schema of the file or only adds
+// fields with defaults to it.
+func (r *OCFReader) Replay(codec *Codec, topic string, isKey bool, produce func(data []byte) error) (count int, err error) {
+
+	for {
+		native, nextErr := r.Next()
+		if nextErr == io.EOF {
+			return
+		}
+		if nextErr != nil {
+			return count, nextErr
+		}
+		data, encodeErr := codec.Encode(topic, isKey, native)
+		if encodeErr != nil {
+			return count, fmt.Errorf("value %d: %w", count, encodeErr)
+		}
+		if err = produce(data); err != nil {
+			return
+		}
+		count++
+	}
+}
@@ -0,0 +1,123 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const ocfSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"]}]}`
+
+// closingBuffer records that it is closed, like the writer of an object in object storage.
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func order(id int64) map[string]interface{} {
+	return map[string]interface{}{"id": id, "note": nil}
+}
+
+func TestOCFWriter(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", ocfSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	for _, compression := range []CompressionCodec{"", CompressionNull, CompressionDeflate, CompressionSnappy} {
+
+		var file closingBuffer
+		writer, err := NewOCFWriter(&file, ocfSchema, compression, WithOCFBlockSize(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := file.Len()
+
+		var want []interface{}
+		for i := int64(0); i < 3; i++ {
+			data, err := codec.Encode("orders", false, order(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			message, err := codec.DecodeMessage("orders", nil, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = writer.AppendDecoded(message); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, message.Value)
+		}
+		// the first block of 2 values is written, the third value is buffered
+		if file.Len() == header {
+			t.Errorf("%v: no block is written after 3 values", compression)
+		}
+		written := file.Len()
+		if err = writer.Flush(); err != nil || file.Len() == written {
+			t.Errorf("%v: Flush() returned %v and wrote nothing", compression, err)
+		}
+		if err = writer.Append(order(3)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, order(3))
+		if err = writer.Close(); err != nil || !file.closed {
+			t.Errorf("%v: Close() returned %v, closed the file: %v", compression, err, file.closed)
+		}
+		if err = writer.Append(order(4)); err == nil {
+			t.Errorf("%v: Append() after Close() succeeded", compression)
+		}
+
+		reader, err := NewOCFReader(bytes.NewReader(file.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := CanonicalForm(reader.Schema()); c != writer.canonical {
+			t.Errorf("%v: the schema of the file is %v", compression, reader.Schema())
+		}
+		var got []interface{}
+		count, err := reader.Replay(codec, "orders", false, func(data []byte) error {
+			native, err := codec.Decode("orders", false, data)
+			got = append(got, native)
+			return err
+		})
+		if err != nil || count != 4 || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: Replay() returned %v, %v, %v, want %v", compression, count, err, got, want)
+		}
+	}
+}
+
+func TestOCFWriterSchemaChange(t *testing.T) {
+
+	var file bytes.Buffer
+	writer, err := NewOCFWriter(&file, ocfSchema, CompressionNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same schema with other whitespace and a doc
+	same := `{"type":"record","name":"Order","doc":"an order","fields":[{"name":"id","type":"long"}, {"name":"note","type":["null","string"]}]}`
+	if err = writer.AppendDecoded(DecodedMessage{Value: order(1), ValueSchema: SchemaInfo{ID: 2, Schema: same}}); err != nil {
+		t.Errorf("AppendDecoded() of the same schema returned %v", err)
+	}
+
+	changed := `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`
+	err = writer.AppendDecoded(DecodedMessage{Topic: "orders", Value: map[string]interface{}{"id": int64(2)}, ValueSchema: SchemaInfo{ID: 3, Schema: changed}})
+	if !errors.Is(err, ErrSchemaChanged) {
+		t.Errorf("AppendDecoded() of another schema returned %v, want ErrSchemaChanged", err)
+	}
+
+	if _, err = NewOCFWriter(&file, ocfSchema, "zstd"); err == nil {
+		t.Error("NewOCFWriter() with an unsupported compression succeeded")
+	}
+	if _, err = NewOCFReader(bytes.NewReader([]byte("not a container file"))); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("NewOCFReader() of invalid data returned %v", err)
+	}
+}
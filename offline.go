@@ -0,0 +1,84 @@
+package kafkaavro
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// WithOfflineMode never calls the registry when offline is true: a schema which is not cached,
+// see PreloadSchemas and PreloadSubjects, fails with ErrSchemaNotCached rather than being fetched,
+// e.g. for deployments which must not make outbound calls. The error names the schema id or
+// subject and the topic, to add to the preloaded schemas.
+func WithOfflineMode(offline bool) Option {
+	return func(c *Codec) {
+		c.offline = offline
+	}
+}
+
+// PreloadSchemas caches the avro schemas by schema id, e.g. schemas which are read from files for
+// WithOfflineMode, the data written with them decode without fetching them. The schemas which do
+// not build a codec fail with ErrCodecBuild, the others are cached.
+func (c *Codec) PreloadSchemas(schemas map[int]string) error {
+
+	if r := c.registryCodec(nil); r != c {
+		return r.PreloadSchemas(schemas)
+	}
+
+	ids := make([]int, 0, len(schemas))
+	for id := range schemas {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var errs []error
+	for _, id := range ids {
+		codec, err := c.newGoavroCodec(schemas[id])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w of schema %d: %w", ErrCodecBuild, id, err))
+			continue
+		}
+		c.codecByID.put(id, codec)
+	}
+	if c.metrics != nil {
+		c.metrics.SchemaCacheSize(c.codecByID.len())
+	}
+	return errors.Join(errs...)
+}
+
+// PreloadSubjects caches the schema ids of the latest schemas of the subjects, so that Encode
+// encodes with them without fetching the latest schemas. The schemas must be cached, e.g. by
+// PreloadSchemas, the subjects of which they are not fail with ErrSchemaNotCached.
+func (c *Codec) PreloadSubjects(latest map[SubjectName]SchemaID) error {
+
+	if r := c.registryCodec(nil); r != c {
+		return r.PreloadSubjects(latest)
+	}
+
+	subjects := make([]SubjectName, 0, len(latest))
+	for subject := range latest {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+
+	var errs []error
+	for _, subject := range subjects {
+		id := latest[subject]
+		codec, found := c.codecByID.get(id)
+		if !found {
+			errs = append(errs, fmt.Errorf("%w: schema %d of subject %v, preload it with PreloadSchemas", ErrSchemaNotCached, id, subject))
+			continue
+		}
+		c.encoderSchemas.put(ContextSubject(c.schemaContext, subject), encoderSchema{id, c.EncodeFramed(id, nil), codec, &atomic.Int64{}, nil})
+	}
+	return errors.Join(errs...)
+}
+
+// notCached is the error of a cache miss in offline mode.
+func notCached(what string, topic string) error {
+	if topic == "" {
+		return fmt.Errorf("%w: %v, the registry is not called in offline mode", ErrSchemaNotCached, what)
+	}
+	return fmt.Errorf("%w: %v of topic %v, the registry is not called in offline mode", ErrSchemaNotCached, what, topic)
+}
@@ -0,0 +1,67 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestWithOfflineMode(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", ocfSchema)
+	online := NewCodec(registry, TopicNameStrategy{})
+	data, err := online.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := len(registry.Calls())
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithOfflineMode(true))
+
+	// the misses fail with the schema id or subject and the topic
+	_, err = codec.Decode("orders", false, data)
+	if !errors.Is(err, ErrSchemaNotCached) || !strings.Contains(err.Error(), "schema 1 of topic orders") {
+		t.Errorf("Decode() of a schema which is not cached returned %v", err)
+	}
+	_, err = codec.Encode("orders", false, order(1))
+	if !errors.Is(err, ErrSchemaNotCached) || !strings.Contains(err.Error(), "subject orders-value of topic orders") {
+		t.Errorf("Encode() of a subject which is not cached returned %v", err)
+	}
+	if err = codec.ValidateJSON("orders-value", -1, []byte(`{"id":1,"note":null}`)); !errors.Is(err, ErrSchemaNotCached) {
+		t.Errorf("ValidateJSON() returned %v", err)
+	}
+
+	// the preloaded schemas are used
+	if err = codec.PreloadSchemas(map[int]string{id: ocfSchema}); err != nil {
+		t.Fatal(err)
+	}
+	if native, err := codec.Decode("orders", false, data); err != nil || !reflect.DeepEqual(native, order(1)) {
+		t.Errorf("Decode() of a preloaded schema returned %v, %v", native, err)
+	}
+	if err = codec.PreloadSubjects(map[SubjectName]SchemaID{"orders-value": id}); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := codec.Encode("orders", false, order(1))
+	if err != nil || !reflect.DeepEqual(encoded, data) {
+		t.Errorf("Encode() of a preloaded subject returned %v, %v, want %v", encoded, err, data)
+	}
+
+	if len(registry.Calls()) != calls {
+		t.Errorf("the registry was called in offline mode: %v", registry.Calls()[calls:])
+	}
+
+	err = codec.PreloadSchemas(map[int]string{7: `{"type":"record"}`, 8: `"string"`})
+	if !errors.Is(err, ErrCodecBuild) || !strings.Contains(err.Error(), "schema 7") {
+		t.Errorf("PreloadSchemas() of an invalid schema returned %v", err)
+	}
+	if _, _, err = codec.WriterSchema(codec.EncodeFramed(8, nil)); err != nil {
+		t.Errorf("the valid schemas are not preloaded: %v", err)
+	}
+	if err = codec.PreloadSubjects(map[SubjectName]SchemaID{"customers-value": 9}); !errors.Is(err, ErrSchemaNotCached) {
+		t.Errorf("PreloadSubjects() of a schema which is not cached returned %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package kafkaavro
+
+import (
+	"log/slog"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// Option configures a Codec.
+type Option func(*Codec)
+
+const defaultWarmUpConcurrency = 8
+
+// WithWarmUpConcurrency sets the maximum number of schemas WarmUp fetches at the same time (default 8).
+func WithWarmUpConcurrency(concurrency int) Option {
+	return func(c *Codec) {
+		if concurrency > 0 {
+			c.warmUpConcurrency = concurrency
+		}
+	}
+}
+
+// WithLogger logs the schema cache misses and registry fetches at debug level to the logger.
+// Without a logger, nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Codec) {
+		c.logger = logger
+	}
+}
+
+// WithoutPayloadPreview leaves the first bytes of the data out of the DecodeErrors, for data
+// which may hold sensitive information.
+func WithoutPayloadPreview() Option {
+	return func(c *Codec) {
+		c.noPayloadPreview = true
+	}
+}
+
+// WithGoavroCodecBuilder builds the goavro codecs of the schemas with the builder rather than with
+// goavro.NewCodec, e.g. goavro.NewCodecForStandardJSON for another JSON encoding of the unions.
+// The caches of a Codec only hold the codecs of its builder, it is set once when it is created.
+// NewEncoder takes the option too.
+func WithGoavroCodecBuilder(builder func(schema string) (*goavro.Codec, error)) Option {
+	return func(c *Codec) {
+		c.codecBuilder = builder
+	}
+}
+
+// WithCompatibilityOnRegister makes NewEncoder set the compatibility level of the subject after
+// it registered the schema, e.g. to provision a new subject from the producer. It is only set when
+// the subject has no level of its own, a level an operator changed later is kept. NewEncoder fails
+// if the level can not be looked up or set, calling it again is a retry. Without autoRegister
+// nothing is set.
+func WithCompatibilityOnRegister(level schemaregistry.CompatibilityLevel) Option {
+	return func(c *Codec) {
+		c.compatibilityOnRegister = level
+	}
+}
@@ -0,0 +1,48 @@
+package kafkaavro
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+)
+
+// The pprof labels of WithPprofLabels, along with AttributeTopic, AttributeSubject and
+// AttributeSchemaID, and the operation of the codec builds, besides the registry operations.
+const (
+	LabelOperation      = "kafkaavro.operation"
+	OperationBuildCodec = "build_codec"
+)
+
+// WithPprofLabels labels the schema registry requests and the goavro codec builds of the Codec
+// in the CPU and goroutine profiles with their operation (OperationGetSchemaByID,
+// OperationGetLatestSchema or OperationBuildCodec), topic, subject and schema id, as far as they
+// are known, to tell which topic the registry latency or the codec compilation is spent on. The
+// spans of the registry requests (see WithTracer) start from the labeled context. The labels are
+// only set on cache misses, but allocate, so they are off by default.
+func WithPprofLabels(enabled bool) Option {
+	return func(c *Codec) {
+		c.pprofLabels = enabled
+	}
+}
+
+// profiled calls f with the pprof labels of the operation if WithPprofLabels is on, the empty
+// topic, subject and schema id are left out.
+func (c *Codec) profiled(ctx context.Context, operation string, topic string, subject SubjectName, schemaID SchemaID, f func(ctx context.Context)) {
+
+	if !c.pprofLabels {
+		f(ctx)
+		return
+	}
+
+	labels := []string{LabelOperation, operation}
+	if topic != "" {
+		labels = append(labels, AttributeTopic, topic)
+	}
+	if subject != "" {
+		labels = append(labels, AttributeSubject, subject)
+	}
+	if schemaID > 0 {
+		labels = append(labels, AttributeSchemaID, strconv.Itoa(schemaID))
+	}
+	pprof.Do(ctx, pprof.Labels(labels...), f)
+}
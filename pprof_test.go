@@ -0,0 +1,74 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// labelTracer records the pprof labels of the contexts of the spans by span name.
+type labelTracer struct {
+	labels map[string]map[string]string
+}
+
+func (t *labelTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	labels := make(map[string]string)
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	t.labels[name] = labels
+	return ctx, labelSpan{}
+}
+
+type labelSpan struct{}
+
+func (labelSpan) SetAttribute(string, interface{}) {}
+func (labelSpan) End(error)                        {}
+
+func TestWithPprofLabels(t *testing.T) {
+
+	for _, enabled := range []bool{true, false} {
+
+		registry := mockregistry.New()
+		schemaID := registry.Register("orders-value", `"string"`)
+		data := []byte{0, 0, 0, 0, byte(schemaID), 4, 'h', 'i'}
+
+		// the goroutine profile of the codec build shows the labels of the goroutine
+		var profile bytes.Buffer
+		builder := func(schema string) (*goavro.Codec, error) {
+			pprof.Lookup("goroutine").WriteTo(&profile, 1)
+			return goavro.NewCodec(schema)
+		}
+		tracer := &labelTracer{labels: make(map[string]map[string]string)}
+		codec := NewCodec(registry, TopicNameStrategy{}, WithPprofLabels(enabled), WithTracer(tracer), WithGoavroCodecBuilder(builder))
+
+		if _, err := codec.DecodeContext(context.Background(), "orders", false, data); err != nil {
+			t.Fatal(err)
+		}
+
+		labels := tracer.labels[SpanGetSchemaByID]
+		if enabled {
+			want := map[string]string{LabelOperation: OperationGetSchemaByID, AttributeTopic: "orders", AttributeSchemaID: "1"}
+			for key, value := range want {
+				if labels[key] != value {
+					t.Errorf("the schema fetch is labeled %v, want %v", labels, want)
+					break
+				}
+			}
+			if want := `"kafkaavro.operation":"build_codec"`; !strings.Contains(profile.String(), want) || !strings.Contains(profile.String(), `"kafkaavro.schema_id":"1"`) {
+				t.Errorf("the goroutine profile of the codec build does not contain the labels %s", want)
+			}
+		} else if len(labels) != 0 || strings.Contains(profile.String(), "kafkaavro.operation") {
+			t.Errorf("without WithPprofLabels the schema fetch is labeled %v", labels)
+		}
+		if len(tracer.labels[SpanDecode]) != 0 {
+			t.Errorf("the decode is labeled %v, want only the registry requests", tracer.labels[SpanDecode])
+		}
+	}
+}
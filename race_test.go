@@ -0,0 +1,269 @@
+package kafkaavro
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// The tests of this file share a Codec between goroutines, run them with go test -race.
+
+const raceGoroutines = 16
+
+// runConcurrently runs f in raceGoroutines goroutines which start at the same time and fails
+// the test with the errors they return.
+func runConcurrently(t *testing.T, f func(goroutine int) error) {
+
+	t.Helper()
+
+	start := make(chan struct{})
+	errs := make(chan error, raceGoroutines)
+
+	var wg sync.WaitGroup
+	for i := 0; i < raceGoroutines; i++ {
+		wg.Add(1)
+		go func(goroutine int) {
+			defer wg.Done()
+			<-start
+			errs <- f(goroutine)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// raceMessage registers the schema, which has a string field f1, for the topic and returns a
+// native value and its encoding. The registry forgets the calls made to encode it.
+func raceMessage(t *testing.T, registry *mockregistry.Registry, topic string, avroSchema string) (native map[string]interface{}, data []byte) {
+
+	t.Helper()
+
+	registry.Register(topic+"-value", avroSchema)
+	native = map[string]interface{}{"f1": topic}
+	data, err := NewCodec(registry, TopicNameStrategy{}).Encode(topic, false, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.Reset()
+	return
+}
+
+func TestConcurrentDecodeOfANewSchema(t *testing.T) {
+
+	registry := mockregistry.New()
+	native, data := raceMessage(t, registry, "orders", testSchema)
+
+	var observed atomic.Int64
+	codec := NewCodec(registry, TopicNameStrategy{}, WithHooks(Hooks{
+		OnNewSchemaObserved: func(topic string, info SchemaInfo) { observed.Add(1) },
+	}))
+
+	runConcurrently(t, func(int) error {
+		decoded, err := codec.Decode("orders", false, data)
+		if err == nil && !reflect.DeepEqual(decoded, native) {
+			err = fmt.Errorf("Decode() returned %v, want %v", decoded, native)
+		}
+		return err
+	})
+
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls < 1 || calls > raceGoroutines {
+		t.Errorf("the schema was fetched %d times, want between 1 and %d", calls, raceGoroutines)
+	}
+	if got := observed.Load(); got != 1 {
+		t.Errorf("OnNewSchemaObserved was called %d times, want once", got)
+	}
+	if stats := codec.CacheStats(); stats.Hits+stats.Misses != raceGoroutines {
+		t.Errorf("CacheStats() returned %+v, want %d lookups", stats, raceGoroutines)
+	}
+}
+
+func TestConcurrentEncodeAndDecode(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", testSchema)
+	registry.Register("payments-value", `{"type":"record","name":"payment","fields":[{"name":"amount","type":"long"}]}`)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	runConcurrently(t, func(goroutine int) (err error) {
+
+		topic, native := "orders", map[string]interface{}{"f1": fmt.Sprint(goroutine)}
+		if goroutine%2 == 1 {
+			topic, native = "payments", map[string]interface{}{"amount": int64(goroutine)}
+		}
+
+		for i := 0; i < 10; i++ {
+			data, err := codec.Encode(topic, false, native)
+			if err != nil {
+				return err
+			}
+			decoded, err := codec.Decode(topic, false, data)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(decoded, native) {
+				return fmt.Errorf("Decode() returned %v, want %v", decoded, native)
+			}
+			_ = codec.ObservedSchemas()
+			_ = codec.CacheStats()
+		}
+		return
+	})
+}
+
+func TestWarmUpRacingWithDecode(t *testing.T) {
+
+	registry := mockregistry.New()
+	subjects := make([]SubjectName, raceGoroutines)
+	messages := make([][]byte, raceGoroutines)
+	for i := range subjects {
+		topic := fmt.Sprintf("topic-%d", i)
+		avroSchema := fmt.Sprintf(`{"type":"record","name":"record%d","fields":[{"name":"f1","type":"string"}]}`, i)
+		_, messages[i] = raceMessage(t, registry, topic, avroSchema)
+		subjects[i] = topic + "-value"
+	}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithWarmUpConcurrency(4))
+
+	runConcurrently(t, func(goroutine int) (err error) {
+		if goroutine == 0 {
+			return codec.WarmUp(context.Background(), subjects)
+		}
+		for i, data := range messages {
+			if _, err = codec.Decode(fmt.Sprintf("topic-%d", i), false, data); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+func TestInvalidateSubjectRacingWithEncode(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", testSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	evolved := `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"},{"name":"f2","type":"string","default":""}]}`
+	native := map[string]interface{}{"f1": "value", "f2": "value"}
+
+	runConcurrently(t, func(goroutine int) (err error) {
+		for i := 0; i < 10; i++ {
+			if goroutine == 0 && i == 5 {
+				registry.Register("orders-value", evolved)
+			}
+			if goroutine%2 == 0 {
+				codec.InvalidateSubject("orders-value")
+			}
+			data, err := codec.Encode("orders", false, native)
+			if err != nil {
+				return err
+			}
+			if _, err = codec.Decode("orders", false, data); err != nil {
+				return err
+			}
+		}
+		return
+	})
+
+	codec.InvalidateSubject("orders-value")
+	data, err := codec.Encode("orders", false, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemaID, _ := parseHeader(data); schemaID != 2 {
+		t.Errorf("Encode() after InvalidateSubject used schema %d, want the latest schema 2", schemaID)
+	}
+}
+
+func TestConcurrentDecoder(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+
+	decoder, err := NewDecoder(*client, "ok-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte{0, 0, 0, 0, 1}, 10, 'r', 'a', 'c', 'e', 's')
+
+	runConcurrently(t, func(int) (err error) {
+		for i := 0; i < 10; i++ {
+			if _, err = decoder.Decode(data); err != nil {
+				return
+			}
+			if _, _, err = decoder.WriterSchema(data); err != nil {
+				return
+			}
+		}
+		return
+	})
+
+	if stats := decoder.CacheStats(); stats.Hits+stats.Misses != 2*10*raceGoroutines {
+		t.Errorf("CacheStats() returned %+v, want %d lookups", stats, 2*10*raceGoroutines)
+	}
+}
+
+func TestConcurrentSchemaChurn(t *testing.T) {
+
+	registry := mockregistry.New()
+	ids, messages := churnMessages(t, registry, "orders", raceGoroutines)
+
+	var alerts atomic.Int64
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaChurnAlert(time.Hour, raceGoroutines-1, func(topic string, ids []int) {
+		alerts.Add(1)
+	}))
+
+	runConcurrently(t, func(goroutine int) error {
+		for i := 0; i < 100; i++ {
+			if _, err := codec.Decode("orders", false, messages[(goroutine+i)%len(messages)]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if got := codec.CacheStats().SchemaChurn["orders"]; got != len(ids) {
+		t.Errorf("CacheStats().SchemaChurn[orders] = %d, want %d", got, len(ids))
+	}
+	if got := alerts.Load(); got != 1 {
+		t.Errorf("the alert was called %d times, want once", got)
+	}
+}
+
+func TestConcurrentTopicStats(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", ocfSchema)
+	codec := NewCodec(registry, TopicNameStrategy{})
+	data, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runConcurrently(t, func(goroutine int) error {
+		for i := 0; i < 100; i++ {
+			if _, err := codec.Decode("orders", false, data); err != nil {
+				return err
+			}
+			codec.Decode("orders", false, data[:1])
+		}
+		return nil
+	})
+
+	stats := codec.TopicStats("orders")
+	if stats.Decoded != raceGoroutines*100 || stats.DecodeFailures != raceGoroutines*100 || stats.Failures["ErrPayloadTooShort"] != raceGoroutines*100 {
+		t.Errorf("TopicStats() returned %+v", stats)
+	}
+}
@@ -0,0 +1,57 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DecodeReader decodes the data read from the reader like Decode, e.g. a message archived in an
+// object store, without reading it into a []byte first: it reads the header, fetches the writer
+// schema and only then reads the body, which goavro decodes as a whole, up to the maximum payload
+// size (see WithMaxPayloadSize). The data which does not start with a header fails without
+// reading the body and larger data fails with ErrPayloadTooLarge as soon as the maximum is read.
+// The errors are those of Decode, a failure of the reader is returned in a *DecodeError too.
+func (c *Codec) DecodeReader(topic string, isKey bool, reader io.Reader) (native interface{}, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		native, err = r.DecodeReader(topic, isKey, reader)
+		return native, r.registryError(err)
+	}
+	return c.decodeContext(context.Background(), topic, isKey, nil, reader)
+}
+
+// readHeader reads the header of the data, the data shorter than the header is returned as is.
+func readHeader(reader io.Reader) (header []byte, err error) {
+
+	header = make([]byte, headerSize)
+	n, err := io.ReadFull(reader, header)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return header[:n], nil
+	}
+	if err != nil {
+		return header[:n], fmt.Errorf("failed to read the data: %w", err)
+	}
+	return
+}
+
+// readBody reads the rest of the data after the header, up to one byte more than the maximum
+// payload size.
+func (c *Codec) readBody(header []byte, reader io.Reader) (data []byte, err error) {
+
+	if c.maxPayloadSize > 0 {
+		reader = io.LimitReader(reader, int64(c.maxPayloadSize-len(header)+1))
+	}
+	buffer := bytes.NewBuffer(header)
+	_, err = buffer.ReadFrom(reader)
+	data = buffer.Bytes()
+	if err != nil {
+		return data, fmt.Errorf("failed to read the data: %w", err)
+	}
+	if c.maxPayloadSize > 0 && len(data) > c.maxPayloadSize {
+		return data, fmt.Errorf("%w: the data exceeds the maximum of %d bytes", ErrPayloadTooLarge, c.maxPayloadSize)
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.read += n
+	return
+}
+
+func TestDecodeReader(t *testing.T) {
+
+	codec, data := newLargeArray(t)
+	if len(data) < 2<<20 {
+		t.Fatalf("the fixture is %d bytes, want a few MB", len(data))
+	}
+
+	// the reader returns the data in chunks
+	native, err := codec.DecodeReader("test", false, iotest.HalfReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := native.(map[string]interface{})["items"].([]interface{}); len(items) != 1<<20 || items[1<<20-1] != int64(1<<20-1) {
+		t.Errorf("DecodeReader() returned %d items", len(items))
+	}
+	if stats := codec.TopicStats("test"); stats.Decoded != 1 || stats.BytesIn != uint64(len(data)) {
+		t.Errorf("DecodeReader() counted %+v", stats)
+	}
+
+	small := []byte{0, 0, 0, 0, 1, 2, 2, 0}
+	want, err := codec.Decode("test", false, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if native, err = codec.DecodeReader("test", false, iotest.OneByteReader(bytes.NewReader(small))); err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("DecodeReader() returned %v, %v, want %v", native, err, want)
+	}
+}
+
+func TestDecodeReaderMaxPayloadSize(t *testing.T) {
+
+	_, data := newLargeArray(t)
+	codec := newArrayCodec(t, WithMaxPayloadSize(1<<20))
+
+	reader := &countingReader{reader: bytes.NewReader(data)}
+	_, err := codec.DecodeReader("test", false, reader)
+	var decodeErr *DecodeError
+	if !errors.Is(err, ErrPayloadTooLarge) || !errors.As(err, &decodeErr) {
+		t.Fatalf("DecodeReader() of %d bytes returned %v", len(data), err)
+	}
+	if reader.read > 1<<20+1 {
+		t.Errorf("DecodeReader() read %d bytes, want at most the maximum of %d bytes and one", reader.read, 1<<20)
+	}
+
+	// the data of the maximum size decodes
+	codec = newArrayCodec(t, WithMaxPayloadSize(len(data)))
+	if _, err = codec.DecodeReader("test", false, bytes.NewReader(data)); err != nil {
+		t.Errorf("DecodeReader() of the maximum size returned %v", err)
+	}
+}
+
+func TestDecodeReaderErrors(t *testing.T) {
+
+	codec := newArrayCodec(t)
+	body := bytes.Repeat([]byte{1}, 1000)
+	failed := errors.New("failed")
+
+	var tests = []struct {
+		name string
+		data []byte
+		// read is the number of bytes DecodeReader reads, the body is not read after a failure of
+		// the header
+		read int
+	}{
+		{"too short", []byte{0, 0}, 2},
+		{"not in the wire format", append([]byte(`{"items":[]}`), body...), headerSize},
+		{"unknown schema id", append([]byte{0, 0, 0, 0, 2}, body...), headerSize},
+		{"malformed", append([]byte{0, 0, 0, 0, 1}, body...), headerSize + len(body)},
+	}
+	for _, test := range tests {
+		want := ErrorType(func() error { _, err := codec.Decode("test", false, test.data); return err }())
+		reader := &countingReader{reader: bytes.NewReader(test.data)}
+		_, err := codec.DecodeReader("test", false, reader)
+		var decodeErr *DecodeError
+		if err == nil || ErrorType(err) != want || !errors.As(err, &decodeErr) {
+			t.Errorf("%v: DecodeReader() returned %v, want an error of type %v like Decode", test.name, err, want)
+		}
+		if reader.read != test.read {
+			t.Errorf("%v: DecodeReader() read %d bytes, want %d", test.name, reader.read, test.read)
+		}
+	}
+
+	for _, reader := range []io.Reader{iotest.ErrReader(failed), io.MultiReader(bytes.NewReader([]byte{0, 0, 0, 0, 1}), iotest.ErrReader(failed))} {
+		_, err := codec.DecodeReader("test", false, reader)
+		var decodeErr *DecodeError
+		if !errors.Is(err, failed) || !errors.As(err, &decodeErr) {
+			t.Errorf("DecodeReader() of a failing reader returned %v", err)
+		}
+	}
+}
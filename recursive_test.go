@@ -0,0 +1,172 @@
+package kafkaavro
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+)
+
+// treeSchema references its enclosing record by its name and by its full name.
+const treeSchema = `{"type":"record","name":"Node","namespace":"com.example","fields":[
+	{"name":"value","type":"long"},
+	{"name":"next","type":["null","Node"],"default":null},
+	{"name":"children","type":{"type":"array","items":"com.example.Node"}},
+	{"name":"index","type":{"type":"map","values":"Node"}}]}`
+
+// expressionSchema is a pair of mutually recursive records.
+const expressionSchema = `{"type":"record","name":"Expression","namespace":"com.example","fields":[
+	{"name":"id","type":{"type":"string","logicalType":"uuid"}},
+	{"name":"operator","type":{"type":"enum","name":"Operator","symbols":["ADD","MULTIPLY"]}},
+	{"name":"terms","type":{"type":"array","items":{"type":"record","name":"Term","fields":[
+		{"name":"constant","type":["null","double"]},
+		{"name":"expression","type":["null","Expression"]}]}}}]}`
+
+func tree(value int64, next interface{}, children ...interface{}) map[string]interface{} {
+	if next != nil {
+		next = goavro.Union("com.example.Node", next)
+	}
+	if children == nil {
+		children = []interface{}{}
+	}
+	return map[string]interface{}{"value": value, "next": next, "children": children, "index": map[string]interface{}{}}
+}
+
+type operator string
+
+func expression(id uuid.UUID, op operator, terms ...interface{}) map[string]interface{} {
+	if terms == nil {
+		terms = []interface{}{}
+	}
+	return map[string]interface{}{"id": id, "operator": op, "terms": terms}
+}
+
+func term(constant interface{}, e interface{}) map[string]interface{} {
+	if constant != nil {
+		constant = goavro.Union("double", constant)
+	}
+	if e != nil {
+		e = goavro.Union("com.example.Expression", e)
+	}
+	return map[string]interface{}{"constant": constant, "expression": e}
+}
+
+func TestRecursiveSchemas(t *testing.T) {
+
+	deep := tree(0, nil)
+	for i := int64(1); i <= 200; i++ {
+		deep = tree(i, deep)
+	}
+	indexed := tree(1, nil, tree(2, nil), tree(3, tree(4, nil)))
+	indexed["index"] = map[string]interface{}{"five": tree(5, nil, tree(6, nil))}
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	enums := WithEnumType("com.example.Operator", map[string]operator{"ADD": "+", "MULTIPLY": "*"})
+
+	tests := []struct {
+		name    string
+		schema  string
+		options []Option
+		value   map[string]interface{}
+	}{
+		{"leaf", treeSchema, nil, tree(1, nil)},
+		{"tree", treeSchema, nil, tree(1, tree(2, nil), tree(3, nil, tree(4, nil)), tree(5, nil))},
+		{"index", treeSchema, nil, indexed},
+		{"deep", treeSchema, nil, deep},
+		{"tree with conversions", treeSchema, []Option{WithLogicalTypes(), WithEnums()}, tree(1, tree(2, nil), tree(3, nil))},
+		{"mutually recursive", expressionSchema, []Option{WithLogicalTypes(), enums},
+			expression(ids[0], "+", term(1.5, nil), term(nil, expression(ids[1], "*", term(2.0, nil), term(nil, expression(ids[2], "+")))))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			codec := newLogicalCodec(t, test.schema, test.options...)
+			data, err := codec.Encode("orders", false, test.value)
+			if err != nil {
+				t.Fatalf("Encode() returned %v", err)
+			}
+			decoded, err := codec.Decode("orders", false, data)
+			if err != nil || !reflect.DeepEqual(decoded, test.value) {
+				t.Fatalf("Decode() returned %v, %v, want %v", decoded, err, test.value)
+			}
+
+			reader, err := NewReaderSchema(test.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved, err := codec.DecodeWithReader("orders", false, data, reader); err != nil || !reflect.DeepEqual(resolved, test.value) {
+				t.Errorf("DecodeWithReader() with the writer schema returned %v, %v, want %v", resolved, err, test.value)
+			}
+
+			if _, err = CanonicalForm(test.schema); err != nil {
+				t.Errorf("CanonicalForm() returned %v", err)
+			}
+		})
+	}
+}
+
+func TestRecursiveSchemaResolution(t *testing.T) {
+
+	// the reader adds a field with a default to every node of the tree
+	reader, err := NewReaderSchema(`{"type":"record","name":"Node","namespace":"com.example","fields":[
+		{"name":"value","type":"long"},
+		{"name":"label","type":"string","default":"none"},
+		{"name":"children","type":{"type":"array","items":"Node"}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codec := newLogicalCodec(t, treeSchema)
+	data, err := codec.Encode("orders", false, tree(1, tree(2, nil), tree(3, nil, tree(4, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := func(value int64, children ...interface{}) map[string]interface{} {
+		if children == nil {
+			children = []interface{}{}
+		}
+		return map[string]interface{}{"value": value, "label": "none", "children": children}
+	}
+	want := node(1, node(3, node(4)))
+	if resolved, err := codec.DecodeWithReader("orders", false, data, reader); err != nil || !reflect.DeepEqual(resolved, want) {
+		t.Errorf("DecodeWithReader() returned %v, %v, want %v", resolved, err, want)
+	}
+}
+
+func TestRecursiveSchemaConversionMarks(t *testing.T) {
+
+	codec := NewCodec(nil, TopicNameStrategy{}, WithLogicalTypes())
+
+	// a recursive type without conversions is not walked, even in a schema with conversions
+	s, err := newLogicalSchema(`{"type":"record","name":"Root","fields":[
+		{"name":"id","type":{"type":"string","logicalType":"uuid"}},
+		{"name":"tree","type":`+treeSchema+`}]}`, codec.conversionOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.converts[s.root] {
+		t.Error("the root with a uuid does not convert")
+	}
+	if node := s.root.Fields[1].Node; s.converts[node] || s.converts[node.Fields[1].Node] {
+		t.Error("the recursive type without conversions converts")
+	}
+
+	// every type of a cycle with a conversion converts
+	s, err = newLogicalSchema(expressionSchema, codec.conversionOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	termNode := s.root.Fields[2].Node.Items
+	for name, n := range map[string]*avroschema.Node{"Expression": s.root, "terms": s.root.Fields[2].Node, "Term": termNode, "Term.expression": termNode.Fields[1].Node} {
+		if !s.converts[n] {
+			t.Errorf("%v of the cycle with a uuid does not convert", name)
+		}
+	}
+	if s.converts[termNode.Fields[0].Node] {
+		t.Error("the constant of a Term converts")
+	}
+}
@@ -0,0 +1,85 @@
+package kafkaavro
+
+import (
+	"fmt"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// WithDryRunRegistration makes NewEncoder with autoRegister plan the registration of the schema
+// rather than registering it, e.g. to check in CI that a schema would register against a staging
+// registry: the registry is only read, see PlanRegistration, and the compatibility level of
+// WithCompatibilityOnRegister is not set. The Encoder reports the plan, see
+// Encoder.RegistrationPlan, and encodes if the schema is registered already. The encodes of a
+// schema which is not registered fail with ErrSchemaNotRegistered.
+func WithDryRunRegistration(enabled bool) Option {
+	return func(c *Codec) {
+		c.dryRunRegistration = enabled
+	}
+}
+
+// RegistrationPlan is what registering a schema under a subject would do, see PlanRegistration.
+type RegistrationPlan struct {
+	Subject SubjectName
+	// Registered is true if the schema is registered under the subject already, as the version of
+	// the subject with the schema id. Registering it again returns the schema id.
+	Registered bool
+	SchemaID   SchemaID
+	Version    SubjectVersion
+	// NewSubject is true if the subject has no versions, the schema would be its first version.
+	NewSubject bool
+	// Compatible is true if the registry accepts the schema: it is registered already, or it is
+	// compatible with the subject under its compatibility level and would be a new version.
+	Compatible bool
+}
+
+// NewVersion returns true if registering the schema would create a new version of the subject.
+func (p RegistrationPlan) NewVersion() bool {
+	return !p.Registered && p.Compatible
+}
+
+func (p RegistrationPlan) String() string {
+	switch {
+	case p.Registered:
+		return fmt.Sprintf("the schema is registered under subject %v as version %d with schema id %d", p.Subject, p.Version, p.SchemaID)
+	case p.NewSubject:
+		return fmt.Sprintf("the schema would be registered as the first version of subject %v", p.Subject)
+	case p.Compatible:
+		return fmt.Sprintf("the schema would be registered as a new version of subject %v", p.Subject)
+	}
+	return fmt.Sprintf("the schema would be rejected: it is not compatible with the latest version of subject %v", p.Subject)
+}
+
+// PlanRegistration looks up whether the schema is registered under the subject and, if it is not,
+// whether registering it would create a new version, without registering it.
+func PlanRegistration(client schemaregistry.Client, subjectName SubjectName, avroSchema AvroSchema) (plan RegistrationPlan, err error) {
+
+	plan.Subject = subjectName
+	registered, schema, err := client.IsRegistered(subjectName, avroSchema)
+	if err != nil {
+		return plan, fmt.Errorf("failed to look up the registration of the schema under subject %v: %w", subjectName, err)
+	}
+	if registered {
+		plan.Registered, plan.SchemaID, plan.Version, plan.Compatible = true, schema.ID, schema.Version, true
+		return
+	}
+
+	plan.Compatible, err = client.IsCompatible(subjectName, avroSchema)
+	if schemaregistry.IsSubjectNotFound(err) || schemaregistry.IsSchemaNotFound(err) {
+		plan.NewSubject, plan.Compatible = true, true
+		return plan, nil
+	}
+	if err != nil {
+		return plan, fmt.Errorf("failed to check the compatibility of the schema with subject %v: %w", subjectName, err)
+	}
+	return
+}
+
+// RegistrationPlan returns the plan of the registration of the schema of the Encoder of
+// WithDryRunRegistration, found is false for the other Encoders.
+func (e Encoder) RegistrationPlan() (plan RegistrationPlan, found bool) {
+	if e.plan == nil {
+		return
+	}
+	return *e.plan, true
+}
@@ -0,0 +1,117 @@
+package kafkaavro
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const incompatibleSchema = `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"int"}]}`
+
+// newPlanRegistry returns a registry with testSchema registered under orders-value, which fails
+// the test when it is changed.
+func newPlanRegistry(t *testing.T) *schemaregistry.Client {
+
+	writeJSON := func(w http.ResponseWriter, status int, v interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Schema string `json:"schema"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		registered := request.Schema == testSchema
+
+		switch path := r.Method + " " + r.URL.Path; {
+		case path == "POST /subjects/orders-value" && registered:
+			writeJSON(w, http.StatusOK, schemaregistry.Schema{Subject: "orders-value", Version: 3, ID: 7, Schema: testSchema})
+		case path == "POST /subjects/orders-value":
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"error_code": 40403, "message": "Schema not found"})
+		case path == "POST /compatibility/subjects/orders-value/versions/latest":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"is_compatible": request.Schema != incompatibleSchema})
+		case strings.HasPrefix(path, "POST /subjects/") && !strings.HasSuffix(path, "/versions"), strings.HasPrefix(path, "POST /compatibility/"):
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"error_code": 40401, "message": "Subject not found"})
+		default:
+			t.Errorf("the dry run requested %v", path)
+			writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error_code": 50001})
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := schemaregistry.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestPlanRegistration(t *testing.T) {
+
+	client := newPlanRegistry(t)
+	changed := `{"type":"record","name":"myrecord","fields":[{"name":"f1","type":"string"},{"name":"f2","type":"string","default":""}]}`
+
+	var tests = []struct {
+		name    string
+		subject string
+		schema  string
+		want    RegistrationPlan
+		message string
+	}{
+		{"registered", "orders-value", testSchema, RegistrationPlan{Subject: "orders-value", Registered: true, SchemaID: 7, Version: 3, Compatible: true},
+			"the schema is registered under subject orders-value as version 3 with schema id 7"},
+		{"new version", "orders-value", changed, RegistrationPlan{Subject: "orders-value", Compatible: true},
+			"the schema would be registered as a new version of subject orders-value"},
+		{"incompatible", "orders-value", incompatibleSchema, RegistrationPlan{Subject: "orders-value"},
+			"the schema would be rejected: it is not compatible with the latest version of subject orders-value"},
+		{"new subject", "payments-value", testSchema, RegistrationPlan{Subject: "payments-value", NewSubject: true, Compatible: true},
+			"the schema would be registered as the first version of subject payments-value"},
+	}
+	for _, test := range tests {
+		plan, err := PlanRegistration(*client, test.subject, test.schema)
+		if err != nil || plan != test.want || plan.String() != test.message {
+			t.Errorf("%v: PlanRegistration() returned %+v, %v: %q, want %+v: %q", test.name, plan, err, plan, test.want, test.message)
+		}
+		if plan.NewVersion() != (test.name == "new version" || test.name == "new subject") {
+			t.Errorf("%v: NewVersion() returned %v", test.name, plan.NewVersion())
+		}
+	}
+}
+
+func TestWithDryRunRegistration(t *testing.T) {
+
+	client := newPlanRegistry(t)
+
+	// the encoder of a registered schema encodes with its id
+	encoder, err := NewEncoder(*client, true, "orders-value", testSchema, WithDryRunRegistration(true), WithCompatibilityOnRegister(schemaregistry.CompatibilityFull))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan, found := encoder.RegistrationPlan(); !found || !plan.Registered || plan.SchemaID != 7 {
+		t.Errorf("RegistrationPlan() returned %+v, %v", plan, found)
+	}
+	if data, err := encoder.Encode(map[string]interface{}{"f1": "value"}); err != nil || getSchemaID(data[1:]) != 7 {
+		t.Errorf("Encode() returned %v, %v", data, err)
+	}
+
+	// the encoder of a schema which is not registered reports the plan but does not encode
+	encoder, err = NewEncoder(*client, true, "payments-value", testSchema, WithDryRunRegistration(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan, _ := encoder.RegistrationPlan(); !plan.NewSubject {
+		t.Errorf("RegistrationPlan() returned %+v", plan)
+	}
+	if _, err = encoder.Encode(map[string]interface{}{"f1": "value"}); !errors.Is(err, ErrSchemaNotRegistered) {
+		t.Errorf("Encode() of the schema which was not registered returned %v", err)
+	}
+
+	// the other encoders have no plan
+	if _, found := (Encoder{}).RegistrationPlan(); found {
+		t.Error("RegistrationPlan() of an Encoder without a dry run found a plan")
+	}
+}
@@ -0,0 +1,440 @@
+package kafkaavro
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// ReaderSchema is the schema the application reads the messages with, whatever the schema they
+// were written with. Create it once with NewReaderSchema, it is safe for concurrent use.
+type ReaderSchema struct {
+	node  *avroschema.Node
+	codec *goavro.Codec
+}
+
+// NewReaderSchema parses the reader schema.
+func NewReaderSchema(schema AvroSchema) (reader *ReaderSchema, err error) {
+
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w of the reader schema: %w", ErrCodecBuild, err)
+	}
+	node, err := avroschema.Parse(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w of the reader schema: %w", ErrCodecBuild, err)
+	}
+	return &ReaderSchema{node, codec}, nil
+}
+
+// Schema returns the reader schema.
+func (r *ReaderSchema) Schema() AvroSchema {
+	return r.codec.Schema()
+}
+
+// DecodeWithReader decodes like Decode and resolves the value written with the writer schema to
+// the reader schema, as the avro specification describes:
+//
+//   - a field of the reader which the writer does not have gets the default of the reader, the
+//     default of a union is one of its first branch, and a field without a default fails
+//   - the fields of the writer which the reader does not have are dropped
+//   - a symbol of an enum which the reader does not have is replaced by the default of the enum
+//   - ints, longs and floats are promoted, strings and bytes are interchangeable
+//   - records, enums and fixed match by full name, name or alias
+//
+// A value which does not resolve fails with a *FieldError wrapping ErrSchemaResolution.
+func (c *Codec) DecodeWithReader(topic string, isKey bool, data []byte, reader *ReaderSchema) (native interface{}, err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		native, err = r.DecodeWithReader(topic, isKey, data, reader)
+		return native, r.registryError(err)
+	}
+
+	var schemaID SchemaID
+	defer func() {
+		if err != nil {
+			err = c.decodeFailed(topic, schemaID, data, err)
+		}
+		c.topicDecoded(topic, isKey, schemaID, data, err)
+		if c.metrics != nil {
+			c.metrics.Decoded(err)
+		}
+	}()
+
+	if err = c.checkPayloadSize(data); err != nil {
+		return
+	}
+	schemaID, codec, _, err := c.codecFor(context.Background(), topic, data)
+	if err != nil {
+		return
+	}
+	if native, err = c.decodeLimited(codec, data[headerSize:]); err != nil {
+		return
+	}
+	writer, err := c.logicalSchemaOf(codec)
+	if err != nil {
+		return
+	}
+	if native, err = resolve(writer.root, reader.node, native, ""); err != nil {
+		return
+	}
+
+	// the reader codec converts the defaults and promoted values to its native values
+	body, err := reader.codec.BinaryFromNative(nil, native)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchemaResolution, err)
+	}
+	if native, err = decodeBody(reader.codec, body); err == nil && c.converting() {
+		native, err = c.fromAvro(reader.codec, native)
+	}
+	if err == nil {
+		c.observe(topic, isKey, schemaID, codec.Schema(), schemaregistry.SchemaTypeAvro)
+	}
+	return
+}
+
+// Reencode resolves the value decoded with the writer schema to the target schema, like
+// DecodeWithReader, and returns the avro body of the resolved value, e.g. to rewrite the messages of
+// a topic after a schema migration. Frame the body with the schema id of the target schema with
+// EncodeFramed. The value is the native value of goavro, as decoded by a Codec without
+// WithLogicalTypes or WithEnums. See ReaderSchema.Reencode to resolve many values to one schema.
+func Reencode(decoded interface{}, writerInfo SchemaInfo, targetSchema AvroSchema) (body []byte, err error) {
+
+	reader, err := NewReaderSchema(targetSchema)
+	if err != nil {
+		return
+	}
+	return reader.Reencode(decoded, writerInfo)
+}
+
+// Reencode resolves the value decoded with the writer schema to the reader schema and returns its
+// avro body, see Reencode.
+func (r *ReaderSchema) Reencode(decoded interface{}, writerInfo SchemaInfo) (body []byte, err error) {
+
+	writer, err := avroschema.Parse(writerInfo.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w of the writer schema %d: %w", ErrCodecBuild, writerInfo.ID, err)
+	}
+	native, err := resolve(writer, r.node, decoded, "")
+	if err != nil {
+		return
+	}
+	if body, err = r.codec.BinaryFromNative(nil, native); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchemaResolution, err)
+	}
+	return
+}
+
+// resolve returns the value of the writer schema w as a value of the reader schema r.
+func resolve(w *avroschema.Node, r *avroschema.Node, value interface{}, path string) (interface{}, error) {
+
+	if w.Type == "union" {
+		// goavro decodes a union to nil or a map of the name of the branch to the value
+		wrapped, _ := value.(map[string]interface{})
+		var branch *avroschema.Node
+		for name, v := range wrapped {
+			branch, value = w.Branch(name), v
+		}
+		if wrapped == nil {
+			branch = w.Branch("null")
+		}
+		if branch == nil {
+			return nil, &FieldError{Path: path, Err: fmt.Errorf("%w: %v is not a value of the writer union", ErrSchemaResolution, value)}
+		}
+		return resolve(branch, r, value, path)
+	}
+
+	if r.Type == "union" {
+		b := bestBranch(w, r)
+		if b == nil {
+			return nil, resolutionError(w, r, path)
+		}
+		v, err := resolve(w, b, value, path)
+		if err != nil || b.Type == "null" {
+			return nil, err
+		}
+		return goavro.Union(b.UnionName(), v), nil
+	}
+
+	if !resolves(w, r) {
+		return nil, resolutionError(w, r, path)
+	}
+
+	switch r.Type {
+
+	case "record":
+		record, _ := value.(map[string]interface{})
+		resolved := make(map[string]interface{}, len(r.Fields))
+		for _, f := range r.Fields {
+			fieldPath := fieldPath(path, f.Name)
+			if wf, found := writerField(w, f); found {
+				v, err := resolve(wf.Node, f.Node, record[wf.Name], fieldPath)
+				if err != nil {
+					return nil, err
+				}
+				resolved[f.Name] = v
+				continue
+			}
+			if !f.HasDefault {
+				return nil, &FieldError{Path: fieldPath, Err: fmt.Errorf("%w: the field is not in the writer schema and has no default", ErrSchemaResolution)}
+			}
+			v, err := defaultNative(f.Node, f.Default)
+			if err != nil {
+				return nil, &FieldError{Path: fieldPath, Err: fmt.Errorf("%w: invalid default: %w", ErrSchemaResolution, err)}
+			}
+			resolved[f.Name] = v
+		}
+		return resolved, nil
+
+	case "enum":
+		symbol, _ := value.(string)
+		if hasSymbol(r, symbol) {
+			return symbol, nil
+		}
+		if r.DefaultSymbol != "" {
+			return r.DefaultSymbol, nil
+		}
+		return nil, &FieldError{Path: path, Err: fmt.Errorf("%w: %q is not one of the symbols %v of the reader and the enum has no default", ErrSchemaResolution, symbol, r.Symbols)}
+
+	case "array":
+		items, _ := value.([]interface{})
+		resolved := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := resolve(w.Items, r.Items, item, indexPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = v
+		}
+		return resolved, nil
+
+	case "map":
+		values, _ := value.(map[string]interface{})
+		resolved := make(map[string]interface{}, len(values))
+		for k, item := range values {
+			v, err := resolve(w.Values, r.Values, item, indexPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = v
+		}
+		return resolved, nil
+
+	case "string":
+		if b, ok := value.([]byte); ok {
+			return string(b), nil
+		}
+	case "bytes":
+		if s, ok := value.(string); ok {
+			return []byte(s), nil
+		}
+	}
+	// goavro encodes the int32, int64 and float32 values of promoted numbers
+	return value, nil
+}
+
+// bestBranch returns the branch of the reader union r to which the writer type w resolves, nil if
+// there is none. Like the Java resolver, a branch of the type of the writer is preferred over the
+// earlier branches to which the writer type is promoted, e.g. an int is an int of ["long","int"].
+func bestBranch(w *avroschema.Node, r *avroschema.Node) *avroschema.Node {
+	for _, b := range r.Branches {
+		if b.Type == w.Type && resolves(w, b) {
+			return b
+		}
+	}
+	for _, b := range r.Branches {
+		if resolves(w, b) {
+			return b
+		}
+	}
+	return nil
+}
+
+// resolves returns true if the values of the writer type w resolve to the reader type r.
+func resolves(w *avroschema.Node, r *avroschema.Node) bool {
+
+	if w.Type == "union" || r.Type == "union" {
+		return true
+	}
+
+	switch w.Type + ">" + r.Type {
+	case "int>long", "int>float", "int>double", "long>float", "long>double", "float>double", "string>bytes", "bytes>string":
+		return true
+	}
+	if w.Type != r.Type {
+		return false
+	}
+
+	switch r.Type {
+	case "record", "enum", "fixed":
+		if r.Type == "fixed" && w.Size != r.Size {
+			return false
+		}
+		return sameName(w, r)
+	case "array":
+		return resolves(w.Items, r.Items)
+	case "map":
+		return resolves(w.Values, r.Values)
+	}
+	return true
+}
+
+// sameName returns true if the names, the names without namespace or an alias of the reader match.
+func sameName(w *avroschema.Node, r *avroschema.Node) bool {
+	if w.Name == r.Name || unqualified(w.Name) == unqualified(r.Name) {
+		return true
+	}
+	for _, alias := range r.Aliases {
+		if alias == w.Name || unqualified(alias) == unqualified(w.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func unqualified(name string) string {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
+
+// writerField returns the field of the writer record which is the field of the reader, by name or alias.
+func writerField(n *avroschema.Node, reader avroschema.Field) (field avroschema.Field, found bool) {
+	for _, f := range n.Fields {
+		if f.Name == reader.Name {
+			return f, true
+		}
+	}
+	for _, f := range n.Fields {
+		for _, alias := range reader.Aliases {
+			if f.Name == alias {
+				return f, true
+			}
+		}
+	}
+	return
+}
+
+func resolutionError(w *avroschema.Node, r *avroschema.Node, path string) error {
+	return &FieldError{Path: path, Err: fmt.Errorf("%w: a %v does not resolve to a %v", ErrSchemaResolution, typeName(w), typeName(r))}
+}
+
+func typeName(n *avroschema.Node) string {
+	if n.Name != "" {
+		return n.Type + " " + n.Name
+	}
+	if n.Type == "union" {
+		names := make([]string, len(n.Branches))
+		for i, b := range n.Branches {
+			names[i] = typeName(b)
+		}
+		return fmt.Sprintf("union %v", names)
+	}
+	return n.Type
+}
+
+// defaultNative returns the native value of the JSON default of a field for goavro. The default of
+// a union is a value of its first branch, the defaults of bytes and fixed are strings of which the
+// characters are the bytes.
+func defaultNative(n *avroschema.Node, value interface{}) (interface{}, error) {
+
+	switch n.Type {
+
+	case "union":
+		if len(n.Branches) == 0 {
+			return nil, fmt.Errorf("a union without branches")
+		}
+		first := n.Branches[0]
+		if first.Type == "null" {
+			return nil, nil
+		}
+		v, err := defaultNative(first, value)
+		if err != nil {
+			return nil, err
+		}
+		return goavro.Union(first.UnionName(), v), nil
+
+	case "bytes", "fixed":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("the default %v of a %v is not a string", value, n.Type)
+		}
+		b := make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 0xff {
+				return nil, fmt.Errorf("the default %q of a %v has a character which is not a byte", s, n.Type)
+			}
+			b = append(b, byte(r))
+		}
+		if n.Logical == "decimal" {
+			return Decimal{Precision: n.Precision, Scale: n.Scale, Size: n.Size}.Rat(b)
+		}
+		return b, nil
+
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the default %v of record %v is not an object", value, n.Name)
+		}
+		native := make(map[string]interface{}, len(n.Fields))
+		for _, f := range n.Fields {
+			v, found := record[f.Name]
+			if !found {
+				if !f.HasDefault {
+					return nil, fmt.Errorf("the default of record %v has no field %v", n.Name, f.Name)
+				}
+				v = f.Default
+			}
+			fieldValue, err := defaultNative(f.Node, v)
+			if err != nil {
+				return nil, err
+			}
+			native[f.Name] = fieldValue
+		}
+		return native, nil
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the default %v of an array is not an array", value)
+		}
+		native := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := defaultNative(n.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			native[i] = v
+		}
+		return native, nil
+
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the default %v of a map is not an object", value)
+		}
+		native := make(map[string]interface{}, len(values))
+		for k, item := range values {
+			v, err := defaultNative(n.Values, item)
+			if err != nil {
+				return nil, err
+			}
+			native[k] = v
+		}
+		return native, nil
+
+	case "enum":
+		if symbol, ok := value.(string); !ok || !hasSymbol(n, symbol) {
+			return nil, fmt.Errorf("the default %v is not one of the symbols %v of %v", value, n.Symbols, n.Name)
+		}
+	}
+	// goavro encodes the float64 of JSON numbers as int, long, float or double
+	return value, nil
+}
@@ -0,0 +1,250 @@
+package kafkaavro
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func record(fields string) string {
+	return `{"type":"record","name":"order","namespace":"com.example","fields":[` + fields + `]}`
+}
+
+const (
+	idField     = `{"name":"id","type":"long"}`
+	statusEnum  = `{"type":"enum","name":"Status","symbols":["NEW","SHIPPED","RETURNED"]}`
+	levelEnum   = `{"type":"enum","name":"Level","symbols":["LOW","HIGH"],"default":"LOW"}`
+	addressType = `{"type":"record","name":"address","fields":[{"name":"street","type":"string"},{"name":"zip","type":["null","int"],"default":null}]}`
+)
+
+// TestDecodeWithReader is the matrix of the writer schemas (v1) and reader schemas (v2) of the
+// avro schema evolution.
+func TestDecodeWithReader(t *testing.T) {
+
+	var tests = []struct {
+		name   string
+		writer string
+		value  map[string]interface{}
+		reader string
+		want   map[string]interface{}
+		path   string // of the error, if the value does not resolve
+	}{
+		{
+			name:   "primitive defaults",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"count","type":"int","default":7},{"name":"total","type":"long","default":-1},
+				{"name":"ratio","type":"float","default":0.5},{"name":"score","type":"double","default":1e100},{"name":"label","type":"string","default":"none"},
+				{"name":"flag","type":"boolean","default":true},{"name":"raw","type":"bytes","default":"ÿ\u0000"},
+				{"name":"code","type":{"type":"fixed","name":"code","size":2},"default":"ab"},{"name":"nothing","type":"null","default":null}`),
+			want: map[string]interface{}{"id": int64(1), "count": int32(7), "total": int64(-1), "ratio": float32(0.5), "score": 1e100, "label": "none",
+				"flag": true, "raw": []byte{0xff, 0}, "code": []byte("ab"), "nothing": nil},
+		},
+		{
+			name:   "union defaults",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"note","type":["null","string"],"default":null},{"name":"label","type":["string","null"],"default":"n/a"},
+				{"name":"level","type":[` + levelEnum + `,"null"],"default":"HIGH"}`),
+			want: map[string]interface{}{"id": int64(1), "note": nil, "label": map[string]interface{}{"string": "n/a"},
+				"level": map[string]interface{}{"com.example.Level": "HIGH"}},
+		},
+		{
+			name:   "record defaults",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"shipping","type":` + addressType + `,"default":{"street":"unknown"}},
+				{"name":"billing","type":["null","address"],"default":null}`),
+			want: map[string]interface{}{"id": int64(1), "shipping": map[string]interface{}{"street": "unknown", "zip": nil}, "billing": nil},
+		},
+		{
+			name:   "array and map defaults",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"tags","type":{"type":"array","items":"string"},"default":["a","b"]},
+				{"name":"counts","type":{"type":"map","values":"long"},"default":{"x":1}},
+				{"name":"empty","type":{"type":"array","items":"int"},"default":[]}`),
+			want: map[string]interface{}{"id": int64(1), "tags": []interface{}{"a", "b"}, "counts": map[string]interface{}{"x": int64(1)}, "empty": []interface{}{}},
+		},
+		{
+			name:   "defaults referencing enum symbols",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"level","type":` + levelEnum + `,"default":"HIGH"},
+				{"name":"preferences","type":{"type":"record","name":"preferences","fields":[{"name":"level","type":"Level"}]},"default":{"level":"HIGH"}},
+				{"name":"history","type":{"type":"array","items":"Level"},"default":["LOW","HIGH"]},
+				{"name":"byRegion","type":{"type":"map","values":"Level"},"default":{"eu":"HIGH"}}`),
+			want: map[string]interface{}{"id": int64(1), "level": "HIGH", "preferences": map[string]interface{}{"level": "HIGH"},
+				"history": []interface{}{"LOW", "HIGH"}, "byRegion": map[string]interface{}{"eu": "HIGH"}},
+		},
+		{
+			name:   "logical type defaults",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"day","type":{"type":"int","logicalType":"date"},"default":1},
+				{"name":"amount","type":{"type":"bytes","logicalType":"decimal","precision":4,"scale":2},"default":"ÿ8"}`),
+			want: map[string]interface{}{"id": int64(1), "day": time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC), "amount": big.NewRat(-2, 1)},
+		},
+		{
+			name:   "enum symbol unknown to the reader",
+			writer: record(`{"name":"status","type":` + statusEnum + `}`),
+			value:  map[string]interface{}{"status": "RETURNED"},
+			reader: record(`{"name":"status","type":{"type":"enum","name":"Status","symbols":["NEW","SHIPPED"],"default":"NEW"}}`),
+			want:   map[string]interface{}{"status": "NEW"},
+		},
+		{
+			name:   "enum symbol unknown to the reader without a default",
+			writer: record(`{"name":"status","type":` + statusEnum + `}`),
+			value:  map[string]interface{}{"status": "RETURNED"},
+			reader: record(`{"name":"status","type":{"type":"enum","name":"Status","symbols":["NEW","SHIPPED"]}}`),
+			path:   "status",
+		},
+		{
+			name:   "promotions",
+			writer: record(`{"name":"quantity","type":"int"},{"name":"price","type":"float"},{"name":"total","type":"long"},{"name":"name","type":"string"},{"name":"raw","type":"bytes"}`),
+			value:  map[string]interface{}{"quantity": 3, "price": 1.5, "total": 10, "name": "x", "raw": []byte("y")},
+			reader: record(`{"name":"quantity","type":"long"},{"name":"price","type":"double"},{"name":"total","type":"float"},{"name":"name","type":"bytes"},{"name":"raw","type":"string"}`),
+			want:   map[string]interface{}{"quantity": int64(3), "price": 1.5, "total": float32(10), "name": []byte("x"), "raw": "y"},
+		},
+		{
+			name:   "removed fields",
+			writer: record(idField + `,{"name":"note","type":"string"},{"name":"shipping","type":` + addressType + `}`),
+			value:  map[string]interface{}{"id": 1, "note": "x", "shipping": map[string]interface{}{"street": "main", "zip": nil}},
+			reader: record(idField),
+			want:   map[string]interface{}{"id": int64(1)},
+		},
+		{
+			name:   "aliases",
+			writer: `{"type":"record","name":"purchase","namespace":"com.example","fields":[` + idField + `]}`,
+			value:  map[string]interface{}{"id": 1},
+			reader: `{"type":"record","name":"order","namespace":"com.example","aliases":["purchase"],"fields":[{"name":"orderId","type":"long","aliases":["id"]}]}`,
+			want:   map[string]interface{}{"orderId": int64(1)},
+		},
+		{
+			name:   "unions",
+			writer: record(`{"name":"note","type":["null","string"]},{"name":"count","type":"int"},{"name":"level","type":["null",` + levelEnum + `]}`),
+			value:  map[string]interface{}{"note": map[string]interface{}{"string": "x"}, "count": 2, "level": nil},
+			reader: record(`{"name":"note","type":"string"},{"name":"count","type":["null","long"]},{"name":"level","type":["null","string",` + levelEnum + `]}`),
+			want:   map[string]interface{}{"note": "x", "count": map[string]interface{}{"long": int64(2)}, "level": nil},
+		},
+		{
+			name:   "union branch of the writer type before a promotion",
+			writer: record(`{"name":"count","type":"int"},{"name":"price","type":["null","float"]},{"name":"name","type":"string"}`),
+			value:  map[string]interface{}{"count": 2, "price": map[string]interface{}{"float": 1.5}, "name": "x"},
+			reader: record(`{"name":"count","type":["long","int"]},{"name":"price","type":["null","double","float"]},{"name":"name","type":["bytes","string"]}`),
+			want: map[string]interface{}{"count": map[string]interface{}{"int": int32(2)}, "price": map[string]interface{}{"float": float32(1.5)},
+				"name": map[string]interface{}{"string": "x"}},
+		},
+		{
+			name:   "null which does not resolve",
+			writer: record(`{"name":"note","type":["null","string"]}`),
+			value:  map[string]interface{}{"note": nil},
+			reader: record(`{"name":"note","type":"string"}`),
+			path:   "note",
+		},
+		{
+			name:   "new field without a default",
+			writer: record(idField),
+			value:  map[string]interface{}{"id": 1},
+			reader: record(idField + `,{"name":"count","type":"int"}`),
+			path:   "count",
+		},
+		{
+			name:   "nested type change",
+			writer: record(`{"name":"shipping","type":` + addressType + `}`),
+			value:  map[string]interface{}{"shipping": map[string]interface{}{"street": "main", "zip": nil}},
+			reader: record(`{"name":"shipping","type":{"type":"record","name":"address","fields":[{"name":"street","type":"int"}]}}`),
+			path:   "shipping.street",
+		},
+		{
+			name:   "writer v2 and reader v1",
+			writer: record(idField + `,{"name":"count","type":"int","default":7},{"name":"tags","type":{"type":"array","items":"string"},"default":[]}`),
+			value:  map[string]interface{}{"id": 1, "count": 3, "tags": []interface{}{"a"}},
+			reader: record(idField),
+			want:   map[string]interface{}{"id": int64(1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			codec := newLogicalCodec(t, test.writer)
+			data, err := codec.Encode("orders", false, test.value)
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+			reader, err := NewReaderSchema(test.reader)
+			if err != nil {
+				t.Fatalf("NewReaderSchema() failed: %v", err)
+			}
+
+			native, err := codec.DecodeWithReader("orders", false, data, reader)
+
+			if test.path != "" {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Path != test.path || !errors.Is(err, ErrSchemaResolution) {
+					t.Errorf("DecodeWithReader() returned %v, %v, want an ErrSchemaResolution of field %v", native, err, test.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeWithReader() failed: %v", err)
+			}
+			if !reflect.DeepEqual(native, test.want) {
+				t.Errorf("DecodeWithReader() returned %#v, want %#v", native, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWithReaderLogicalTypes(t *testing.T) {
+
+	writer := record(idField)
+	reader, err := NewReaderSchema(record(idField + `,{"name":"level","type":` + levelEnum + `,"default":"HIGH"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codec := newLogicalCodec(t, writer, WithEnumType("com.example.Level", map[string]level{"LOW": low, "HIGH": high}))
+	data, err := codec.Encode("orders", false, map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	native, err := codec.DecodeWithReader("orders", false, data, reader)
+	if want := map[string]interface{}{"id": int64(1), "level": high}; err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("DecodeWithReader() returned %v, %v, want %v", native, err, want)
+	}
+}
+
+func TestReencode(t *testing.T) {
+
+	writer := SchemaInfo{ID: 1, Schema: record(idField + `,{"name":"note","type":"string"},{"name":"quantity","type":"int"}`)}
+	target := record(idField + `,{"name":"quantity","type":"long"},{"name":"status","type":` + statusEnum + `,"default":"NEW"}`)
+	decoded := map[string]interface{}{"id": int64(1), "note": "x", "quantity": int32(3)}
+
+	body, err := Reencode(decoded, writer, target)
+	if err != nil {
+		t.Fatalf("Reencode() failed: %v", err)
+	}
+	reader, err := NewReaderSchema(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, err := decodeBody(reader.codec, body)
+	if want := map[string]interface{}{"id": int64(1), "quantity": int64(3), "status": "NEW"}; err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("Reencode() encoded %v, %v, want %v", native, err, want)
+	}
+
+	if _, err = Reencode(decoded, writer, record(idField+`,{"name":"total","type":"double"}`)); !errors.Is(err, ErrSchemaResolution) {
+		t.Errorf("Reencode() to a schema with a field without default returned %v", err)
+	}
+	if _, err = Reencode(decoded, SchemaInfo{ID: 2, Schema: `{"type":`}, target); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("Reencode() with an invalid writer schema returned %v", err)
+	}
+	if _, err = Reencode(decoded, writer, `{"type":"unknown"}`); !errors.Is(err, ErrCodecBuild) {
+		t.Errorf("Reencode() to an invalid target schema returned %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package restproxy produces and consumes the messages of a kafkaavro.Codec through the Confluent
+// REST Proxy (API v2), for the environments which can reach the proxy but not the Kafka brokers.
+// The values are sent and received in the binary embedded format: the data of the Codec, with the
+// schema id in its framing, base64 encoded in the JSON of the proxy.
+//
+// Usage:
+//
+//	client, err := restproxy.NewClient("https://rest-proxy:8082", restproxy.UsingBasicAuth(key, secret))
+//	producer := restproxy.NewProducer(client, codec)
+//	offset, err := producer.Send(ctx, "orders", []byte("order-1"), order)
+//
+//	consumer, err := restproxy.NewConsumer(ctx, client, codec, "archiver", "orders")
+//	defer consumer.Close(context.Background())
+//	messages, err := consumer.Poll(ctx)
+package restproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The content types of the REST Proxy API v2.
+const (
+	contentTypeV2     = "application/vnd.kafka.v2+json"
+	contentTypeBinary = "application/vnd.kafka.binary.v2+json"
+)
+
+// ErrProxyUnavailable is reported when the REST Proxy can not be reached or fails with a server error.
+var ErrProxyUnavailable = errors.New("rest proxy unavailable")
+
+// Error is the error returned by the REST Proxy for a failed request.
+type Error struct {
+	StatusCode int
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rest proxy failed with status %d, error code %d: %v", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// Is maps the server errors to ErrProxyUnavailable.
+func (e *Error) Is(target error) bool {
+	return target == ErrProxyUnavailable && e.StatusCode >= 500
+}
+
+// Client talks to the REST Proxy at a base url.
+type Client struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// UsingClient makes the Client send its requests with the http client, e.g. to configure TLS.
+func UsingClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.client = httpClient
+	}
+}
+
+// UsingBasicAuth makes the Client authenticate with basic authentication.
+func UsingBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// NewClient creates a client for the REST Proxy at baseURL.
+func NewClient(baseURL string, options ...Option) (client *Client, err error) {
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rest proxy url %q: %w", baseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid rest proxy url %q, expected http(s)://host:port", baseURL)
+	}
+
+	client = &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return
+}
+
+// do sends the request to the target url, the body as JSON of the content type, and decodes the JSON
+// response into the result, if any.
+func (c *Client) do(ctx context.Context, method string, target string, contentType string, accept string, body interface{}, result interface{}) (err error) {
+
+	var requestBody io.Reader
+	if body != nil {
+		payload, marshalErr := json.Marshal(body)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		requestBody = bytes.NewReader(payload)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, requestBody)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Accept", accept)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProxyUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	payload, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProxyUnavailable, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		proxyErr := &Error{StatusCode: response.StatusCode}
+		if json.Unmarshal(payload, proxyErr) != nil || proxyErr.Message == "" {
+			proxyErr.Message = strings.TrimSpace(string(payload))
+		}
+		if proxyErr.Message == "" {
+			proxyErr.Message = response.Status
+		}
+		return proxyErr
+	}
+
+	if result == nil || len(payload) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(payload, result); err != nil {
+		return fmt.Errorf("invalid response from %v %v: %w", method, target, err)
+	}
+	return
+}
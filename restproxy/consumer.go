@@ -0,0 +1,137 @@
+package restproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/timvw/kafkaavro"
+)
+
+// Consumer consumes the messages of topics through a consumer instance of the REST Proxy and
+// decodes them with a kafkaavro.Codec. The offsets are committed with Commit. Close deletes the
+// consumer instance, which the proxy otherwise keeps until it times out.
+type Consumer struct {
+	client  *Client
+	codec   *kafkaavro.Codec
+	group   string
+	baseURI string
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewConsumer creates a consumer instance of the group and subscribes it to the topics. The
+// instance starts from the earliest offsets of the partitions without a committed offset of the
+// group.
+func NewConsumer(ctx context.Context, client *Client, codec *kafkaavro.Codec, group string, topics ...string) (consumer *Consumer, err error) {
+
+	request := map[string]string{
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}
+	var instance struct {
+		InstanceID string `json:"instance_id"`
+		BaseURI    string `json:"base_uri"`
+	}
+	target := client.baseURL + "/consumers/" + url.PathEscape(group)
+	if err = client.do(ctx, http.MethodPost, target, contentTypeV2, contentTypeV2, request, &instance); err != nil {
+		return nil, fmt.Errorf("failed to create a consumer instance of group %v: %w", group, err)
+	}
+	consumer = &Consumer{client: client, codec: codec, group: group, baseURI: instance.BaseURI}
+
+	subscription := struct {
+		Topics []string `json:"topics"`
+	}{topics}
+	if err = client.do(ctx, http.MethodPost, consumer.baseURI+"/subscription", contentTypeV2, contentTypeV2, subscription, nil); err != nil {
+		err = fmt.Errorf("failed to subscribe consumer %v of group %v to %v: %w", instance.InstanceID, group, topics, err)
+		consumer.Close(ctx)
+		return nil, err
+	}
+	return
+}
+
+// Poll fetches the next records and decodes them with the codec, see kafkaavro.Codec.DecodeMessage.
+// A record which does not decode fails the poll, its partition and offset are in the error, the
+// messages before it are returned with the error. The position of the consumer instance is past
+// all the records of the poll: to consume the record again, commit the returned messages, close
+// the consumer and create a new one of the group, which starts after the committed offsets.
+func (c *Consumer) Poll(ctx context.Context) (messages []kafkaavro.DecodedMessage, err error) {
+
+	var records []struct {
+		Topic     string `json:"topic"`
+		Key       []byte `json:"key"`
+		Value     []byte `json:"value"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	if err = c.client.do(ctx, http.MethodGet, c.baseURI+"/records", "", contentTypeBinary, nil, &records); err != nil {
+		return nil, fmt.Errorf("failed to poll the records of group %v: %w", c.group, err)
+	}
+
+	for _, record := range records {
+		message, decodeErr := c.codec.DecodeMessage(record.Topic, record.Key, record.Value)
+		if decodeErr != nil {
+			return messages, fmt.Errorf("failed to decode the record of %v [%d] at offset %d: %w", record.Topic, record.Partition, record.Offset, decodeErr)
+		}
+		message.Partition = record.Partition
+		message.Offset = record.Offset
+		messages = append(messages, message)
+	}
+	return
+}
+
+// Commit commits the offsets of the messages, e.g. of the messages of a Poll once they are
+// processed: the offset of the last message of every partition, the group continues after it.
+// The records of a Poll which are not in the messages are not committed.
+func (c *Consumer) Commit(ctx context.Context, messages []kafkaavro.DecodedMessage) error {
+
+	type partitionOffset struct {
+		Topic     string `json:"topic"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	type topicPartition struct {
+		topic     string
+		partition int32
+	}
+	last := make(map[topicPartition]partitionOffset)
+	for _, message := range messages {
+		key := topicPartition{message.Topic, message.Partition}
+		if committed, found := last[key]; !found || message.Offset > committed.Offset {
+			last[key] = partitionOffset{message.Topic, message.Partition, message.Offset}
+		}
+	}
+	if len(last) == 0 {
+		return nil
+	}
+
+	var request struct {
+		Offsets []partitionOffset `json:"offsets"`
+	}
+	for _, offset := range last {
+		request.Offsets = append(request.Offsets, offset)
+	}
+	sort.Slice(request.Offsets, func(i, j int) bool {
+		a, b := request.Offsets[i], request.Offsets[j]
+		return a.Topic < b.Topic || a.Topic == b.Topic && a.Partition < b.Partition
+	})
+	if err := c.client.do(ctx, http.MethodPost, c.baseURI+"/offsets", contentTypeV2, contentTypeV2, request, nil); err != nil {
+		return fmt.Errorf("failed to commit the offsets of group %v: %w", c.group, err)
+	}
+	return nil
+}
+
+// Close deletes the consumer instance, closing it again returns the error of the first Close.
+func (c *Consumer) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		if err := c.client.do(ctx, http.MethodDelete, c.baseURI, contentTypeV2, contentTypeV2, nil, nil); err != nil {
+			c.closeErr = fmt.Errorf("failed to delete the consumer instance of group %v: %w", c.group, err)
+		}
+	})
+	return c.closeErr
+}
@@ -0,0 +1,86 @@
+package restproxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/timvw/kafkaavro"
+)
+
+// Record is a record to produce, the value is the data of a kafkaavro.Codec or Encoder, the key is
+// sent as is. A nil Partition lets the proxy choose the partition.
+type Record struct {
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value"`
+	Partition *int32 `json:"partition,omitempty"`
+}
+
+// Offset is the partition and offset of a produced record.
+type Offset struct {
+	Partition int32
+	Offset    int64
+}
+
+// Producer produces the records of a kafkaavro.Codec through the REST Proxy.
+type Producer struct {
+	client *Client
+	codec  *kafkaavro.Codec
+}
+
+// NewProducer creates a producer which encodes the values with the codec.
+func NewProducer(client *Client, codec *kafkaavro.Codec) *Producer {
+	return &Producer{client, codec}
+}
+
+// Send encodes the value with the latest schema of the value subject of the topic and produces it
+// with the key.
+func (p *Producer) Send(ctx context.Context, topic string, key []byte, value interface{}) (offset Offset, err error) {
+
+	data, err := p.codec.Encode(topic, false, value)
+	if err != nil {
+		return
+	}
+	offsets, err := p.Produce(ctx, topic, Record{Key: key, Value: data})
+	if err != nil {
+		return
+	}
+	return offsets[0], nil
+}
+
+// Produce produces the records of encoded values in one request and returns their offsets. A
+// record which the proxy fails to produce fails the whole call with the error of the record.
+func (p *Producer) Produce(ctx context.Context, topic string, records ...Record) (offsets []Offset, err error) {
+
+	request := struct {
+		Records []Record `json:"records"`
+	}{records}
+	var response struct {
+		Offsets []struct {
+			Partition int32   `json:"partition"`
+			Offset    int64   `json:"offset"`
+			ErrorCode *int    `json:"error_code"`
+			Error     *string `json:"error"`
+		} `json:"offsets"`
+	}
+	target := p.client.baseURL + "/topics/" + url.PathEscape(topic)
+	if err = p.client.do(ctx, http.MethodPost, target, contentTypeBinary, contentTypeV2, request, &response); err != nil {
+		return nil, fmt.Errorf("failed to produce to topic %v: %w", topic, err)
+	}
+	if len(response.Offsets) != len(records) {
+		return nil, fmt.Errorf("failed to produce to topic %v: the proxy returned %d offsets for %d records", topic, len(response.Offsets), len(records))
+	}
+
+	for i, produced := range response.Offsets {
+		if produced.ErrorCode != nil {
+			message := ""
+			if produced.Error != nil {
+				message = *produced.Error
+			}
+			return nil, fmt.Errorf("failed to produce record %d to topic %v: %w", i, topic, &Error{StatusCode: http.StatusOK, ErrorCode: *produced.ErrorCode, Message: message})
+		}
+		offsets = append(offsets, Offset{produced.Partition, produced.Offset})
+	}
+	return
+}
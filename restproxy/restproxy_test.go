@@ -0,0 +1,341 @@
+package restproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/timvw/kafkaavro"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+const orderSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`
+
+type fakeRecord struct {
+	Topic     string `json:"topic"`
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// fakeProxy is a REST Proxy with one partition per topic, which requires the credentials
+// key:secret. A consumer instance returns the records of its topics from its position, which
+// starts after the offsets its group committed.
+type fakeProxy struct {
+	t         *testing.T
+	server    *httptest.Server
+	mu        sync.Mutex
+	records   map[string][]fakeRecord
+	instances map[string]*fakeInstance
+	created   int
+	// the next offsets of the groups by topic, like the proxy commits the offset after the
+	// committed one
+	committed map[string]map[string]int64
+}
+
+type fakeInstance struct {
+	group     string
+	topics    []string
+	positions map[string]int
+}
+
+func newFakeProxy(t *testing.T) *fakeProxy {
+	proxy := &fakeProxy{t: t, records: make(map[string][]fakeRecord), instances: make(map[string]*fakeInstance),
+		committed: make(map[string]map[string]int64)}
+	proxy.server = httptest.NewServer(http.HandlerFunc(proxy.serve))
+	t.Cleanup(proxy.server.Close)
+	return proxy
+}
+
+func (p *fakeProxy) serve(w http.ResponseWriter, r *http.Request) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	writeJSON := func(status int, v interface{}) {
+		w.Header().Set("Content-Type", contentTypeV2)
+		w.WriteHeader(status)
+		if v != nil {
+			json.NewEncoder(w).Encode(v)
+		}
+	}
+	if username, password, _ := r.BasicAuth(); username != "key" || password != "secret" {
+		writeJSON(http.StatusUnauthorized, map[string]interface{}{"error_code": 40101, "message": "Unauthorized"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "topics":
+		if r.Header.Get("Content-Type") != contentTypeBinary {
+			writeJSON(http.StatusUnsupportedMediaType, map[string]interface{}{"error_code": 415, "message": "Unsupported Media Type"})
+			return
+		}
+		if parts[1] == "down" {
+			writeJSON(http.StatusInternalServerError, map[string]interface{}{"error_code": 50002, "message": "Kafka error"})
+			return
+		}
+		var request struct {
+			Records []fakeRecord `json:"records"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		var offsets []map[string]interface{}
+		for _, record := range request.Records {
+			record.Topic, record.Offset = parts[1], int64(len(p.records[parts[1]]))
+			p.records[parts[1]] = append(p.records[parts[1]], record)
+			offsets = append(offsets, map[string]interface{}{"partition": 0, "offset": record.Offset, "error_code": nil, "error": nil})
+		}
+		writeJSON(http.StatusOK, map[string]interface{}{"offsets": offsets})
+
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "consumers":
+		var request map[string]string
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["format"] != "binary" {
+			p.t.Errorf("the consumer instance is created with the format %q", request["format"])
+		}
+		p.created++
+		id := "instance-" + string(rune('0'+p.created))
+		p.instances[id] = &fakeInstance{group: parts[1], positions: make(map[string]int)}
+		writeJSON(http.StatusOK, map[string]string{"instance_id": id, "base_uri": p.server.URL + "/consumers/" + parts[1] + "/instances/" + id})
+
+	case len(parts) >= 4 && parts[0] == "consumers" && parts[2] == "instances":
+		instance, found := p.instances[parts[3]]
+		if !found {
+			writeJSON(http.StatusNotFound, map[string]interface{}{"error_code": 40403, "message": "Consumer instance not found."})
+			return
+		}
+		switch action := strings.Join(parts[4:], "/"); {
+		case r.Method == http.MethodDelete && action == "":
+			delete(p.instances, parts[3])
+			writeJSON(http.StatusNoContent, nil)
+		case r.Method == http.MethodPost && action == "subscription":
+			var request struct {
+				Topics []string `json:"topics"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			if len(request.Topics) == 0 {
+				writeJSON(http.StatusUnprocessableEntity, map[string]interface{}{"error_code": 42201, "message": "no topics"})
+				return
+			}
+			instance.topics = request.Topics
+			for _, topic := range request.Topics {
+				instance.positions[topic] = int(p.committed[instance.group][topic])
+			}
+			writeJSON(http.StatusNoContent, nil)
+		case r.Method == http.MethodGet && action == "records":
+			if r.Header.Get("Accept") != contentTypeBinary {
+				writeJSON(http.StatusNotAcceptable, map[string]interface{}{"error_code": 406, "message": "Not Acceptable"})
+				return
+			}
+			records := []fakeRecord{}
+			for _, topic := range instance.topics {
+				records = append(records, p.records[topic][instance.positions[topic]:]...)
+				instance.positions[topic] = len(p.records[topic])
+			}
+			writeJSON(http.StatusOK, records)
+		case r.Method == http.MethodPost && action == "offsets":
+			var request struct {
+				Offsets []struct {
+					Topic     string `json:"topic"`
+					Partition int32  `json:"partition"`
+					Offset    int64  `json:"offset"`
+				} `json:"offsets"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			if len(request.Offsets) == 0 {
+				p.t.Error("the offsets are committed without offsets, which commits the position of the instance")
+			}
+			if p.committed[instance.group] == nil {
+				p.committed[instance.group] = make(map[string]int64)
+			}
+			for _, offset := range request.Offsets {
+				p.committed[instance.group][offset.Topic] = offset.Offset + 1
+			}
+			writeJSON(http.StatusOK, nil)
+		default:
+			writeJSON(http.StatusNotFound, map[string]interface{}{"error_code": 404, "message": "Not Found"})
+		}
+
+	default:
+		writeJSON(http.StatusNotFound, map[string]interface{}{"error_code": 404, "message": "Not Found"})
+	}
+}
+
+func TestProduceConsume(t *testing.T) {
+
+	proxy := newFakeProxy(t)
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", orderSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	client, err := NewClient(proxy.server.URL+"/", UsingBasicAuth("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	producer := NewProducer(client, codec)
+	offset, err := producer.Send(ctx, "orders", []byte("order-1"), map[string]interface{}{"id": int64(1)})
+	if err != nil || offset != (Offset{0, 0}) {
+		t.Fatalf("Send() returned %v, %v", offset, err)
+	}
+	// the values are the framed data, base64 encoded by the JSON
+	if data := proxy.records["orders"][0].Value; len(data) < 5 || data[0] != 0 || int(data[4]) != id {
+		t.Errorf("the proxy received the value %x, want the data of schema %d", data, id)
+	}
+	data, err := codec.Encode("orders", false, map[string]interface{}{"id": int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, err := producer.Produce(ctx, "orders", Record{Value: data}, Record{Key: []byte("order-3"), Value: data})
+	if err != nil || !reflect.DeepEqual(offsets, []Offset{{0, 1}, {0, 2}}) {
+		t.Errorf("Produce() returned %v, %v", offsets, err)
+	}
+
+	consumer, err := NewConsumer(ctx, client, codec, "archiver", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	polled, err := consumer.Poll(ctx)
+	if err != nil || len(polled) != 3 {
+		t.Fatalf("Poll() returned %v, %v", polled, err)
+	}
+	want := kafkaavro.DecodedMessage{Topic: "orders", Offset: 2, Key: []byte("order-3"), Value: map[string]interface{}{"id": int64(2)}}
+	if message := polled[2]; message.Key == nil || !reflect.DeepEqual(message.Value, want.Value) || message.Offset != want.Offset || message.ValueSchema.ID != id {
+		t.Errorf("Poll() returned %+v, want %+v", message, want)
+	}
+	if polled[1].Key != nil {
+		t.Errorf("the null key is polled as %v", polled[1].Key)
+	}
+	if messages, err := consumer.Poll(ctx); err != nil || len(messages) != 0 {
+		t.Errorf("the second Poll() returned %v, %v", messages, err)
+	}
+	if err = consumer.Commit(ctx, polled); err != nil || proxy.committed["archiver"]["orders"] != 3 {
+		t.Errorf("Commit() returned %v and committed %v", err, proxy.committed)
+	}
+
+	// the instance is deleted once
+	for i := 0; i < 2; i++ {
+		if err = consumer.Close(ctx); err != nil {
+			t.Errorf("Close() returned %v", err)
+		}
+	}
+	if len(proxy.instances) != 0 {
+		t.Errorf("the instances %v are not deleted", proxy.instances)
+	}
+	var proxyErr *Error
+	if _, err = consumer.Poll(ctx); !errors.As(err, &proxyErr) || proxyErr.ErrorCode != 40403 {
+		t.Errorf("Poll() of a closed consumer returned %v", err)
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+
+	proxy := newFakeProxy(t)
+	registry := mockregistry.New()
+	registry.Register("down-value", orderSchema)
+	registry.Register("orders-value", orderSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	client, err := NewClient(proxy.server.URL, UsingBasicAuth("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err = NewProducer(client, codec).Send(ctx, "down", nil, map[string]interface{}{"id": int64(1)}); !errors.Is(err, ErrProxyUnavailable) {
+		t.Errorf("Send() to a failing topic returned %v", err)
+	}
+	if _, err = NewProducer(client, codec).Send(ctx, "unknown", nil, map[string]interface{}{"id": int64(1)}); !errors.Is(err, kafkaavro.ErrSchemaNotFound) {
+		t.Errorf("Send() of an unknown subject returned %v", err)
+	}
+
+	// a consumer which fails to subscribe is deleted
+	if _, err = NewConsumer(ctx, client, codec, "archiver"); err == nil || len(proxy.instances) != 0 {
+		t.Errorf("NewConsumer() without topics returned %v and left %v", err, proxy.instances)
+	}
+
+	unauthorized, _ := NewClient(proxy.server.URL)
+	var proxyErr *Error
+	if _, err = NewConsumer(ctx, unauthorized, codec, "archiver", "orders"); !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("NewConsumer() without credentials returned %v", err)
+	}
+
+	// a record which does not decode names its offset
+	proxy.records["orders"] = []fakeRecord{{Topic: "orders", Value: []byte("not avro"), Offset: 7}}
+	consumer, err := NewConsumer(ctx, client, codec, "archiver", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close(ctx)
+	if _, err = consumer.Poll(ctx); !errors.Is(err, kafkaavro.ErrUnknownMagicByte) || !strings.Contains(err.Error(), "offset 7") {
+		t.Errorf("Poll() of a record which is not avro returned %v", err)
+	}
+
+	if _, err = NewClient("rest-proxy:8082"); err == nil {
+		t.Error("NewClient() of an invalid url succeeded")
+	}
+	unreachable, err := NewClient("http://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewProducer(unreachable, codec).Send(ctx, "orders", nil, map[string]interface{}{"id": int64(1)}); !errors.Is(err, ErrProxyUnavailable) {
+		t.Errorf("Send() to an unreachable proxy returned %v", err)
+	}
+}
+
+func TestPollDecodeFailure(t *testing.T) {
+
+	proxy := newFakeProxy(t)
+	registry := mockregistry.New()
+	registry.Register("orders-value", orderSchema)
+	codec := kafkaavro.NewCodec(registry, kafkaavro.TopicNameStrategy{})
+	client, err := NewClient(proxy.server.URL, UsingBasicAuth("key", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// the record at offset 2 does not decode, the one after it does
+	producer := NewProducer(client, codec)
+	for i := 0; i < 4; i++ {
+		if i == 2 {
+			proxy.records["orders"] = append(proxy.records["orders"], fakeRecord{Topic: "orders", Value: []byte("not avro"), Offset: 2})
+			continue
+		}
+		if _, err = producer.Send(ctx, "orders", nil, map[string]interface{}{"id": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consumer, err := NewConsumer(ctx, client, codec, "archiver", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := consumer.Poll(ctx)
+	if err == nil || !strings.Contains(err.Error(), "offset 2") || len(messages) != 2 {
+		t.Fatalf("Poll() returned %v, %v, want the messages before offset 2", messages, err)
+	}
+	// the record which failed and the ones after it are not committed
+	if err = consumer.Commit(ctx, messages); err != nil || proxy.committed["archiver"]["orders"] != 2 {
+		t.Errorf("Commit() returned %v and committed %v", err, proxy.committed)
+	}
+	consumer.Close(ctx)
+
+	// a new consumer of the group continues with the record which failed
+	if consumer, err = NewConsumer(ctx, client, codec, "archiver", "orders"); err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close(ctx)
+	if messages, err = consumer.Poll(ctx); err == nil || !strings.Contains(err.Error(), "offset 2") || len(messages) != 0 {
+		t.Errorf("Poll() of the new consumer returned %v, %v, want the record at offset 2 again", messages, err)
+	}
+	if err = consumer.Commit(ctx, nil); err != nil {
+		t.Errorf("Commit() without messages returned %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy retries the schema registry requests which fail with ErrRegistryUnavailable, after a
+// backoff which grows by Multiplier from Base up to Max. A part of every backoff, the Jitter
+// fraction, is random, so that the consumers of a fleet which fail together do not retry together.
+type RetryPolicy struct {
+	// Attempts is the maximum number of requests, including the first one.
+	Attempts   int
+	Base       time.Duration
+	Multiplier float64
+	Max        time.Duration
+	// Jitter is the random fraction of a backoff: a backoff d is between d*(1-Jitter) and d.
+	Jitter float64
+	// Rand returns a random number in [0, 1), the seeded math/rand/v2.Float64 if it is nil. Tests
+	// pass a fixed sequence to assert the backoffs.
+	Rand func() float64
+}
+
+// DefaultRetryPolicy makes 4 requests, with backoffs of 100ms, 200ms and 400ms of which up to 20%
+// is random: a failing request is retried for at most 700ms, so that every client makes at most
+// 4 requests per schema in that time.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 4, Base: 100 * time.Millisecond, Multiplier: 2, Max: 2 * time.Second, Jitter: 0.2}
+
+// WithRetry retries the requests to the schema registry with the policy, e.g.
+// DefaultRetryPolicy. Without it, a failed request is not retried. The backoffs wait on the
+// Clock, see WithClock, and the OnRegistryRetry hook is called before every retry.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Codec) {
+		c.retry = policy
+	}
+}
+
+// backoff returns the backoff before the retry of the failed attempt, the first attempt is 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+
+	d := float64(p.Base)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if p.Max > 0 && d >= float64(p.Max) {
+			d = float64(p.Max)
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		random := p.Rand
+		if random == nil {
+			random = rand.Float64
+		}
+		d -= d * p.Jitter * random()
+	}
+	return time.Duration(d)
+}
+
+// withRetry makes the request until it succeeds, fails with another error than
+// ErrRegistryUnavailable or the attempts of the retry policy are made. It stops waiting when the
+// context is done.
+func (c *Codec) withRetry(ctx context.Context, event HookEvent, request func() error) (err error) {
+
+	for attempt := 1; ; attempt++ {
+		if err = request(); err == nil || attempt >= c.retry.Attempts || !errors.Is(err, ErrRegistryUnavailable) {
+			return
+		}
+		if c.hooks.OnRegistryRetry != nil {
+			event.Err = err
+			c.callHook("OnRegistryRetry", c.hooks.OnRegistryRetry, event)
+		}
+		select {
+		case <-c.clock.After(c.retry.backoff(attempt)):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
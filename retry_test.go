@@ -0,0 +1,124 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// fixedRand returns the numbers in turn.
+func fixedRand(numbers ...float64) func() float64 {
+	return func() float64 {
+		n := numbers[0]
+		numbers = append(numbers[1:], n)
+		return n
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		{"default without jitter", RetryPolicy{Base: 100 * time.Millisecond, Multiplier: 2, Max: 2 * time.Second, Rand: fixedRand(0)},
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second, 2 * time.Second}},
+		{"default", RetryPolicy{Base: 100 * time.Millisecond, Multiplier: 2, Max: 2 * time.Second, Jitter: 0.2, Rand: fixedRand(0, 0.5, 1)},
+			[]time.Duration{100 * time.Millisecond, 180 * time.Millisecond, 320 * time.Millisecond, 800 * time.Millisecond}},
+		{"constant", RetryPolicy{Base: time.Second, Multiplier: 1, Jitter: 1, Rand: fixedRand(0.25)},
+			[]time.Duration{750 * time.Millisecond, 750 * time.Millisecond, 750 * time.Millisecond}},
+		{"cap below base", RetryPolicy{Base: time.Second, Multiplier: 3, Max: 500 * time.Millisecond, Rand: fixedRand(0)},
+			[]time.Duration{time.Second, 500 * time.Millisecond}},
+	}
+	for _, test := range tests {
+		var got []time.Duration
+		for attempt := 1; attempt <= len(test.want); attempt++ {
+			got = append(got, test.policy.backoff(attempt))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: the backoffs are %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// the default source is random, within the jitter
+	for attempt := 1; attempt <= 3; attempt++ {
+		d, max := DefaultRetryPolicy.backoff(attempt), DefaultRetryPolicy.Base<<(attempt-1)
+		if d > max || d < time.Duration(float64(max)*0.8) {
+			t.Errorf("backoff %d of the default policy is %v, want between 80%% of %v and %v", attempt, d, max, max)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `"long"`)
+	unavailable := mockregistry.Fail(mockregistry.ErrUnavailable)
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+
+	var retries []HookEvent
+	policy := DefaultRetryPolicy
+	policy.Rand = fixedRand(0.5)
+	codec := NewCodec(registry, TopicNameStrategy{}, WithRetry(policy), WithClock(clock),
+		WithHooks(Hooks{OnRegistryRetry: func(event HookEvent) { retries = append(retries, event) }}))
+
+	// two failures are retried after 90ms and 180ms
+	registry.Script(mockregistry.GetLatestSchema, unavailable, unavailable)
+	done := make(chan error)
+	go func() {
+		_, err := codec.Encode("orders", false, int64(1))
+		done <- err
+	}()
+	for _, backoff := range []time.Duration{90 * time.Millisecond, 180 * time.Millisecond} {
+		clock.BlockUntil(1)
+		clock.Advance(backoff - time.Millisecond)
+		if clock.Waiters() != 1 {
+			t.Fatalf("the retry did not wait %v", backoff)
+		}
+		clock.Advance(time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Encode() returned %v", err)
+	}
+	if len(retries) != 2 || retries[0].Subject != "orders-value" || !errors.Is(retries[0].Err, ErrRegistryUnavailable) {
+		t.Errorf("OnRegistryRetry was called with %+v", retries)
+	}
+
+	// the attempts are limited and the other errors are not retried
+	retries = nil
+	registry.Script(mockregistry.GetSchemaByID, unavailable, unavailable, unavailable, unavailable)
+	go func() {
+		_, err := codec.Decode("orders", false, []byte{0, 0, 0, 0, 9, 0})
+		done <- err
+	}()
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	if err := <-done; !errors.Is(err, ErrRegistryUnavailable) || len(retries) != 3 {
+		t.Errorf("Decode() returned %v after %d retries", err, len(retries))
+	}
+	retries = nil
+	if _, err := codec.Encode("customers", false, int64(1)); !errors.Is(err, ErrSchemaNotFound) || len(retries) != 0 {
+		t.Errorf("Encode() of an unknown subject returned %v after %d retries", err, len(retries))
+	}
+
+	// the wait stops with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	registry.Script(mockregistry.GetLatestSchema, unavailable)
+	go func() {
+		_, err := codec.EncodeContext(ctx, "payments", false, int64(1))
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) || !errors.Is(err, ErrRegistryUnavailable) {
+		t.Errorf("EncodeContext() with a canceled context returned %v", err)
+	}
+}
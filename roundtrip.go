@@ -0,0 +1,207 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// WithVerifyRoundTrip makes the Codec and the Encoder (see NewEncoder) decode the data of every
+// avro encode with the same schema and compare the value with the encoded one, the encode fails
+// with ErrRoundTripMismatch when they differ, e.g. a misspelled field of a record which goavro
+// ignores and encodes the default of the field for.
+// It doubles the cost of encoding, see WithVerifySampling.
+//
+// The values are compared modulo the normalizations of avro: numbers of any Go type compare by
+// value after the conversion to the type of the schema (int32, int64, float32 or float64), the
+// bytes may be strings, arrays and maps any slices and maps, times and durations compare at the
+// precision of their logical type, decimals by value and the fields missing from the encoded
+// records, encoded with their default, are not compared.
+func WithVerifyRoundTrip(enabled bool) Option {
+	return func(c *Codec) {
+		c.verifyRoundTrip = enabled
+	}
+}
+
+// WithVerifySampling makes WithVerifyRoundTrip verify one in n encodes, the first one included,
+// instead of every encode.
+func WithVerifySampling(n int) Option {
+	return func(c *Codec) {
+		c.verifySampling = n
+	}
+}
+
+// roundTrip samples the encodes to verify of WithVerifyRoundTrip.
+type roundTrip struct {
+	every   uint64
+	encodes atomic.Uint64
+}
+
+// newRoundTrip returns the sampler of the options applied to the config, nil when the encodes are
+// not verified.
+func (c *Codec) newRoundTrip() *roundTrip {
+	if !c.verifyRoundTrip {
+		return nil
+	}
+	return &roundTrip{every: uint64(max(c.verifySampling, 1))}
+}
+
+// verify decodes the framed data with the codec and compares the value with the encoded native
+// value, if the encode is sampled.
+func (r *roundTrip) verify(codec *goavro.Codec, data []byte, native interface{}) error {
+
+	if r == nil || (r.encodes.Add(1)-1)%r.every != 0 {
+		return nil
+	}
+	decoded, err := decodeBody(codec, data[headerSize:])
+	if err != nil {
+		return fmt.Errorf("%w: the encoded data does not decode: %w", ErrRoundTripMismatch, err)
+	}
+	return compareRoundTrip("", native, decoded)
+}
+
+// compareRoundTrip compares the encoded value with the decoded value with the normalizations of
+// WithVerifyRoundTrip, it returns the *FieldError of the first difference.
+func compareRoundTrip(path string, encoded interface{}, decoded interface{}) error {
+
+	mismatch := func() error {
+		return &FieldError{Path: path, Err: fmt.Errorf("%w: encoded %#v, decoded %#v", ErrRoundTripMismatch, encoded, decoded)}
+	}
+
+	switch d := decoded.(type) {
+	case nil:
+		if encoded != nil {
+			return mismatch()
+		}
+		return nil
+	case int32, int64:
+		if i, ok := integer(encoded); !ok || i != reflect.ValueOf(d).Int() {
+			return mismatch()
+		}
+		return nil
+	case float32:
+		if f, ok := float(encoded); !ok || !sameFloat(float64(float32(f)), float64(d)) {
+			return mismatch()
+		}
+		return nil
+	case float64:
+		if f, ok := float(encoded); !ok || !sameFloat(f, d) {
+			return mismatch()
+		}
+		return nil
+	case []byte:
+		switch e := encoded.(type) {
+		case []byte:
+			if string(e) == string(d) {
+				return nil
+			}
+		case string:
+			if e == string(d) {
+				return nil
+			}
+		}
+		return mismatch()
+	case time.Time:
+		if e, ok := encoded.(time.Time); !ok || !(d.Equal(e) || d.Equal(e.Truncate(time.Microsecond)) || d.Equal(e.Truncate(time.Millisecond))) {
+			return mismatch()
+		}
+		return nil
+	case time.Duration:
+		if e, ok := encoded.(time.Duration); !ok || !(d == e || d == e.Truncate(time.Microsecond) || d == e.Truncate(time.Millisecond)) {
+			return mismatch()
+		}
+		return nil
+	case *big.Rat:
+		if e, ok := encoded.(*big.Rat); !ok || e.Cmp(d) != 0 {
+			return mismatch()
+		}
+		return nil
+	case map[string]interface{}:
+		e := reflect.ValueOf(encoded)
+		if e.Kind() != reflect.Map || e.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		keys := make([]string, 0, e.Len())
+		for _, key := range e.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, found := d[key]
+			if !found {
+				return &FieldError{Path: fieldPath(path, key), Err: fmt.Errorf("%w: encoded but not decoded", ErrRoundTripMismatch)}
+			}
+			if err := compareRoundTrip(fieldPath(path, key), e.MapIndex(reflect.ValueOf(key).Convert(e.Type().Key())).Interface(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		e := reflect.ValueOf(encoded)
+		if (e.Kind() != reflect.Slice && e.Kind() != reflect.Array) || e.Len() != len(d) {
+			return mismatch()
+		}
+		for i, value := range d {
+			if err := compareRoundTrip(indexPath(path, strconv.Itoa(i)), e.Index(i).Interface(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		// strings and enums, the symbols of enums may be of a string type
+		if e := reflect.ValueOf(encoded); e.Kind() != reflect.String || e.String() != d {
+			return mismatch()
+		}
+		return nil
+	}
+
+	if !reflect.DeepEqual(encoded, decoded) {
+		return mismatch()
+	}
+	return nil
+}
+
+// integer returns the value of an integer of any Go type, or of a float without a fraction, e.g.
+// of a value decoded from JSON.
+func integer(value interface{}) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+			return int64(f), true
+		}
+		return 0, false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// float returns the value of a float or integer of any Go type.
+func float(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+		return v.Float(), true
+	}
+	if i, ok := integer(value); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// sameFloat compares the floats, NaN is NaN.
+func sameFloat(a, b float64) bool {
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
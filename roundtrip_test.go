@@ -0,0 +1,128 @@
+package kafkaavro
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+// defaultedSchema has a field with a default, which goavro encodes for a misspelled field.
+const defaultedSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"note","type":["null","string"],"default":null}]}`
+
+func TestCompareRoundTrip(t *testing.T) {
+
+	type status string
+	at := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+
+	var tests = []struct {
+		encoded interface{}
+		decoded interface{}
+		path    string // of the mismatch, "-" without mismatch
+	}{
+		{int(1), int64(1), "-"},
+		{uint8(1), int32(1), "-"},
+		{1.0, int64(1), "-"},
+		{1.5, int64(1), ""},
+		{"1", int64(1), ""},
+		{0.1, float32(0.1), "-"},
+		{1, 1.0, "-"},
+		{math.NaN(), math.NaN(), "-"},
+		{0.1, 0.2, ""},
+		{"x", []byte("x"), "-"},
+		{[]byte("x"), []byte("y"), ""},
+		{status("OPEN"), "OPEN", "-"},
+		{nil, nil, "-"},
+		{"x", nil, ""},
+		{at, at.Truncate(time.Microsecond), "-"},
+		{at, at.Add(time.Second), ""},
+		{time.Duration(1500), time.Duration(1000), "-"},
+		{big.NewRat(1, 2), big.NewRat(2, 4), "-"},
+		{big.NewRat(1, 2), big.NewRat(1, 3), ""},
+		{[]string{"a", "b"}, []interface{}{"a", "b"}, "-"},
+		{[]string{"a", "b"}, []interface{}{"a", "c"}, "[1]"},
+		{[]string{"a"}, []interface{}{"a", "b"}, ""},
+		{map[string]int64{"a": 1}, map[string]interface{}{"a": int64(1)}, "-"},
+		// the fields encoded with their default are not compared
+		{map[string]interface{}{"id": 1}, map[string]interface{}{"id": int64(1), "note": nil}, "-"},
+		// a misspelled field is not encoded
+		{map[string]interface{}{"id": 1, "nte": "x"}, map[string]interface{}{"id": int64(1), "note": nil}, "nte"},
+		{map[string]interface{}{"note": map[string]interface{}{"string": "x"}}, map[string]interface{}{"note": map[string]interface{}{"string": "y"}}, "note.string"},
+	}
+
+	for _, test := range tests {
+		err := compareRoundTrip("", test.encoded, test.decoded)
+		if test.path == "-" {
+			if err != nil {
+				t.Errorf("compareRoundTrip(%#v, %#v) returned %v", test.encoded, test.decoded, err)
+			}
+			continue
+		}
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrRoundTripMismatch) || !errors.As(err, &fieldErr) || fieldErr.Path != test.path {
+			t.Errorf("compareRoundTrip(%#v, %#v) returned %v, want a mismatch at %q", test.encoded, test.decoded, err, test.path)
+		}
+	}
+}
+
+func TestWithVerifyRoundTrip(t *testing.T) {
+
+	registry := mockregistry.New()
+	schemaID := registry.Register("orders-value", defaultedSchema)
+	misspelled := map[string]interface{}{"id": int64(1), "nte": map[string]interface{}{"string": "x"}}
+
+	unverified := NewCodec(registry, TopicNameStrategy{})
+	if _, err := unverified.Encode("orders", false, misspelled); err != nil {
+		t.Fatalf("Encode() without the verification returned %v", err)
+	}
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithVerifyRoundTrip(true))
+	if _, err := codec.Encode("orders", false, order(1)); err != nil {
+		t.Errorf("Encode() returned %v", err)
+	}
+	if data, err := codec.Encode("orders", false, misspelled); !errors.Is(err, ErrRoundTripMismatch) || data != nil {
+		t.Errorf("Encode() of a misspelled field returned %x, %v", data, err)
+	}
+	if _, err := codec.EncodeWithSchemaID(schemaID, misspelled); !errors.Is(err, ErrRoundTripMismatch) {
+		t.Errorf("EncodeWithSchemaID() of a misspelled field returned %v", err)
+	}
+
+	client, close := newErrorRegistry(t)
+	defer close()
+	encoder, err := NewEncoder(*client, true, "ok-value", testSchema, WithVerifyRoundTrip(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = encoder.Encode(map[string]interface{}{"f1": "x"}); err != nil {
+		t.Errorf("Encoder.Encode() returned %v", err)
+	}
+	if _, err = encoder.Encode(map[string]interface{}{"f1": "x", "f2": "y"}); !errors.Is(err, ErrRoundTripMismatch) {
+		t.Errorf("Encoder.Encode() of a misspelled field returned %v", err)
+	}
+}
+
+func TestWithVerifySampling(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", defaultedSchema)
+	misspelled := map[string]interface{}{"id": int64(1), "nte": nil}
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithVerifyRoundTrip(true), WithVerifySampling(3))
+	var failed []int
+	for i := 0; i < 7; i++ {
+		if _, err := codec.Encode("orders", false, misspelled); err != nil {
+			failed = append(failed, i)
+		}
+	}
+	// the first one in three encodes
+	if len(failed) != 3 || failed[0] != 0 || failed[1] != 3 || failed[2] != 6 {
+		t.Errorf("the encodes %v failed, want 0, 3 and 6", failed)
+	}
+
+	if r := NewCodec(registry, TopicNameStrategy{}, WithVerifySampling(3)).roundTrip; r != nil {
+		t.Error("WithVerifySampling() without WithVerifyRoundTrip() verifies the encodes")
+	}
+}
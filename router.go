@@ -0,0 +1,146 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// WithRegistryRouter fetches the schemas of the keys and values of a topic from the registry the
+// router returns for it, or from the registry of NewCodec if it returns nil, e.g. when the topics
+// are split between the registries of two organisations. Every registry has caches of its own, as
+// the schema ids of two registries are unrelated, and the errors of the registries name the
+// registry: the String of a registry client which is a fmt.Stringer, e.g. the url of a
+// *schemaregistry.Client. The router is called once per topic, key or value. The codec of a
+// registry client is looked up by the client, a client which is not comparable (e.g. a
+// SchemaByIDFunc or a struct with a map) has caches per topic, key or value, unless it is one of
+// WithRegistryPrefixes.
+//
+// The methods which are not called with a topic, e.g. WriterSchema, EncodeWithSchemaID, WarmUp
+// and ValidateJSON, use the registry of NewCodec, InvalidateSubject invalidates the subject of
+// every registry.
+func WithRegistryRouter(router func(topic string, isKey bool) RegistryClient) Option {
+	return func(c *Codec) {
+		c.router = router
+	}
+}
+
+// WithRegistryPrefixes routes the topics which start with a prefix to its registry, see
+// WithRegistryRouter: the registry of the longest prefix, or the registry of NewCodec for the
+// topics without a prefix.
+func WithRegistryPrefixes(registries map[string]RegistryClient) Option {
+
+	prefixes := make([]string, 0, len(registries))
+	routes := make(map[string]RegistryClient, len(registries))
+	for prefix, client := range registries {
+		prefixes = append(prefixes, prefix)
+		routes[prefix] = client
+		if !comparableClient(client) {
+			routes[prefix] = &prefixRegistry{client}
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	return WithRegistryRouter(func(topic string, isKey bool) RegistryClient {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(topic, prefix) {
+				return routes[prefix]
+			}
+		}
+		return nil
+	})
+}
+
+// prefixRegistry is a registry client of WithRegistryPrefixes which is not comparable, the pointer
+// is the key of the codec of the registry.
+type prefixRegistry struct {
+	RegistryClient
+}
+
+// comparableClient returns whether the registry client can be the key of the codec of the registry.
+func comparableClient(client RegistryClient) bool {
+	return client == nil || reflect.TypeOf(client).Comparable()
+}
+
+// route returns the codec of the registry of the topic, the codec itself without a router.
+func (c *Codec) route(topic string, isKey bool) *Codec {
+
+	if c.router == nil {
+		return c
+	}
+	key := subjectKey{topic, isKey}
+	if codec, found := c.routes.get(key); found {
+		return codec
+	}
+	var codec *Codec
+	if client := c.router(topic, isKey); comparableClient(client) {
+		codec = c.registryCodec(client)
+	} else {
+		// the route has a registry of its own
+		codec = c.registryCodecOf(key, client)
+	}
+	c.routes.put(key, codec)
+	return codec
+}
+
+// registryCodec returns the codec with the options of the Codec and the caches of the registry,
+// the registry of NewCodec for nil.
+func (c *Codec) registryCodec(client RegistryClient) *Codec {
+
+	if c.router == nil {
+		return c
+	}
+	if client == nil {
+		client = c.client
+		if !comparableClient(client) {
+			return c.registryCodecOf(&c.client, client)
+		}
+	}
+	return c.registryCodecOf(client, client)
+}
+
+// registryCodecOf returns the codec of the registry client with the key in the registries.
+func (c *Codec) registryCodecOf(key interface{}, client RegistryClient) *Codec {
+
+	if codec, found := c.registries.get(key); found {
+		return codec
+	}
+
+	if prefix, ok := client.(*prefixRegistry); ok {
+		client = prefix.RegistryClient
+	}
+	codec := NewCodec(client, c.subjectNameStrategy, c.options...)
+	codec.router = nil
+	codec.closed = c.closed
+	codec.registryName = fmt.Sprintf("%T", client)
+	if stringer, ok := client.(fmt.Stringer); ok {
+		codec.registryName = stringer.String()
+	}
+	if !c.registries.add(key, codec) {
+		codec, _ = c.registries.get(key)
+	}
+	return codec
+}
+
+// registryError names the registry in the error, for the codecs of WithRegistryRouter.
+func (c *Codec) registryError(err error) error {
+	if err == nil || c.registryName == "" {
+		return err
+	}
+	return fmt.Errorf("registry %v: %w", c.registryName, err)
+}
+
+// eachRegistry calls f with the codec of every registry which is used, or with the Codec itself
+// without a router.
+func (c *Codec) eachRegistry(f func(codec *Codec)) {
+
+	if c.router == nil {
+		f(c)
+		return
+	}
+	c.registryCodec(nil)
+	c.registries.each(func(_ interface{}, codec *Codec) {
+		f(codec)
+	})
+}
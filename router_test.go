@@ -0,0 +1,170 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestWithRegistryPrefixes(t *testing.T) {
+
+	// the first schemas of both registries have id 1
+	legacy := mockregistry.NewNamed("legacy")
+	legacyID := legacy.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	acme := mockregistry.NewNamed("acme")
+	acmeID := acme.Register("acme.orders-value", `{"type":"record","name":"Order","fields":[{"name":"reference","type":"string"}]}`)
+	if legacyID != acmeID {
+		t.Fatalf("the registries use the ids %v and %v", legacyID, acmeID)
+	}
+
+	codec := NewCodec(legacy, TopicNameStrategy{}, WithRegistryPrefixes(map[string]RegistryClient{"acme.": acme}))
+
+	values := map[string]map[string]interface{}{
+		"orders":      {"id": int64(1)},
+		"acme.orders": {"reference": "A-1"},
+	}
+	data := make(map[string][]byte)
+	for topic, value := range values {
+		encoded, err := codec.Encode(topic, false, value)
+		if err != nil {
+			t.Fatalf("Encode() to %v returned %v", topic, err)
+		}
+		data[topic] = encoded
+	}
+
+	// the writer schemas are looked up in the registry of the topic, twice to read the caches
+	for i := 0; i < 2; i++ {
+		for topic, value := range values {
+			native, err := codec.Decode(topic, false, data[topic])
+			if err != nil || !reflect.DeepEqual(native, value) {
+				t.Errorf("Decode() of %v returned %v, %v, want %v", topic, native, err, value)
+			}
+		}
+	}
+	// schema 1 of the legacy registry is not the schema of the acme data
+	if native, _ := codec.Decode("orders", false, data["acme.orders"]); reflect.DeepEqual(native, values["acme.orders"]) {
+		t.Error("Decode() of the acme data on a topic of the legacy registry used the acme schema")
+	}
+	for _, registry := range []*mockregistry.Registry{legacy, acme} {
+		for _, call := range registry.Calls() {
+			if call.Method == mockregistry.GetSchemaByID {
+				t.Errorf("schema %v of %v is fetched, the schemas are cached on Encode", call.ID, registry)
+			}
+		}
+	}
+	if stats := codec.CacheStats(); stats.Misses != 2 {
+		t.Errorf("CacheStats() returned %+v, want a miss per registry", stats)
+	}
+	if observed := codec.ObservedSchemas(); len(observed["orders"]) != 1 || len(observed["acme.orders"]) != 1 {
+		t.Errorf("ObservedSchemas() returned %v", observed)
+	}
+
+	// the errors name the registry
+	acme.Script(mockregistry.GetLatestSchema, mockregistry.Fail(mockregistry.ErrUnavailable))
+	_, err := codec.Encode("acme.orders", true, "A-1")
+	if !errors.Is(err, ErrRegistryUnavailable) || !strings.Contains(err.Error(), "registry acme") {
+		t.Errorf("Encode() with a failing registry returned %v", err)
+	}
+	if _, err = codec.Encode("orders", true, int64(1)); !errors.Is(err, ErrSchemaNotFound) || !strings.Contains(err.Error(), "registry legacy") {
+		t.Errorf("Encode() of an unknown subject returned %v", err)
+	}
+
+	// the subjects are invalidated in every registry
+	next := acme.Register("acme.orders-value", `{"type":"record","name":"Order","fields":[{"name":"reference","type":"string"},{"name":"note","type":"string","default":""}]}`)
+	codec.InvalidateSubject("acme.orders-value")
+	encoded, err := codec.Encode("acme.orders", false, values["acme.orders"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _ := ParseWireFormat(encoded); id != next {
+		t.Errorf("Encode() after InvalidateSubject() used schema %v, want %v", id, next)
+	}
+}
+
+func TestWithRegistryRouter(t *testing.T) {
+
+	primary := mockregistry.NewNamed("primary")
+	primary.Register("orders-value", `"long"`)
+	secondary := mockregistry.NewNamed("secondary")
+	secondary.Register("orders-key", `"string"`)
+
+	// the keys are in the secondary registry, the router returns nil for the primary registry
+	var routed []string
+	codec := NewCodec(primary, TopicNameStrategy{}, WithRegistryRouter(func(topic string, isKey bool) RegistryClient {
+		routed = append(routed, topic)
+		if isKey {
+			return secondary
+		}
+		return nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		key, err := codec.Encode("orders", true, "order-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := codec.Encode("orders", false, int64(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if native, err := codec.Decode("orders", true, key); err != nil || native != "order-1" {
+			t.Errorf("Decode() of the key returned %v, %v", native, err)
+		}
+		// the methods without a topic use the registry of NewCodec
+		if _, schema, err := codec.WriterSchema(value); err != nil || schema != `"long"` {
+			t.Errorf("WriterSchema() returned %v, %v", schema, err)
+		}
+	}
+	if len(routed) != 2 {
+		t.Errorf("the router was called for %v, want once for the key and once for the value", routed)
+	}
+}
+
+func TestRegistryRouterFuncClients(t *testing.T) {
+
+	// functions are not comparable, the codecs of their registries are not looked up by the client
+	fetches := make(map[string]int)
+	fetcher := func(registry string, schema string) SchemaByIDFunc {
+		return func(id int) (string, error) {
+			fetches[registry]++
+			return schema, nil
+		}
+	}
+	data := []byte{0, 0, 0, 0, 1, 2}
+	codec := NewCodec(fetcher("default", `"long"`), TopicNameStrategy{},
+		WithRegistryPrefixes(map[string]RegistryClient{"x.": fetcher("x", `"int"`)}))
+
+	for i := 0; i < 2; i++ {
+		for _, topic := range []string{"x.orders", "x.payments", "orders"} {
+			if native, err := codec.Decode(topic, false, data); err != nil || native == nil {
+				t.Errorf("Decode() of %v returned %v, %v", topic, native, err)
+			}
+		}
+		if _, schema, err := codec.WriterSchema(data); err != nil || schema != `"long"` {
+			t.Errorf("WriterSchema() returned %v, %v", schema, err)
+		}
+	}
+	if want := map[string]int{"default": 1, "x": 1}; !reflect.DeepEqual(fetches, want) {
+		t.Errorf("the registries fetched the schema %v times, want %v", fetches, want)
+	}
+
+	// a router which returns a function has a registry per topic, key or value
+	fetches = make(map[string]int)
+	routed := fetcher("routed", `"int"`)
+	codec = NewCodec(fetcher("default", `"long"`), TopicNameStrategy{}, WithRegistryRouter(func(topic string, isKey bool) RegistryClient {
+		return routed
+	}))
+	for i := 0; i < 2; i++ {
+		for _, topic := range []string{"orders", "payments"} {
+			if native, err := codec.Decode(topic, false, data); err != nil || native != int32(1) {
+				t.Errorf("Decode() of %v returned %v, %v", topic, native, err)
+			}
+		}
+	}
+	if fetches["routed"] != 2 {
+		t.Errorf("the routed registry fetched the schema %d times, want once per topic", fetches["routed"])
+	}
+}
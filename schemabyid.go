@@ -0,0 +1,68 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// SchemaByID returns the schema of the id, e.g. of a log line, through the cache and the registry
+// client of Decode: the schema is fetched once. The schemas of other schema types than avro are
+// returned as well, also those without a SchemaType (see WithSchemaType). The Subject and Version
+// of the SchemaInfo are not known.
+func (c *Codec) SchemaByID(ctx context.Context, id SchemaID) (info SchemaInfo, err error) {
+
+	if r := c.registryCodec(nil); r != c {
+		info, err = r.SchemaByID(ctx, id)
+		return info, r.registryError(err)
+	}
+
+	_, codec, _, err := c.codecFor(ctx, "", c.EncodeFramed(id, nil))
+	switch {
+	case err == nil:
+		info = SchemaInfo{ID: id, Schema: codec.Schema(), SchemaType: schemaregistry.SchemaTypeAvro}
+	case !errors.Is(err, ErrUnsupportedSchemaType):
+		return
+	default:
+		decoder, found := c.decoderByID.get(id)
+		if !found {
+			return
+		}
+		info = SchemaInfo{ID: id, Schema: decoder.schema.Schema, SchemaType: decoder.schema.Type()}
+	}
+	info.RecordName = recordName(info.Schema)
+	return info, nil
+}
+
+// SchemaByID returns the schema of the id of the schema registry at the url, for one-off scripts:
+// it creates a Codec with NewCodecFromURL and the options for it, use Codec.SchemaByID to fetch
+// several schemas.
+func SchemaByID(ctx context.Context, url string, id SchemaID, options ...Option) (info SchemaInfo, err error) {
+
+	codec, err := NewCodecFromURL(url, options...)
+	if err != nil {
+		return
+	}
+	defer codec.Close()
+	return codec.SchemaByID(ctx, id)
+}
+
+// SchemaByIDFunc is the RegistryClient of a function which fetches the avro schema of an id, for a
+// Codec which only decodes, e.g. in a js/wasm build which fetches the schemas through a proxy with
+// the fetch API of the browser. The Codec builds with CGO_ENABLED=0 for every platform, including
+// js/wasm, where the *schemaregistry.Client works as well: net/http sends its requests with fetch.
+//
+//	codec := kafkaavro.NewCodec(kafkaavro.SchemaByIDFunc(fetchSchema), kafkaavro.TopicNameStrategy{})
+//
+// The encodes fail, it does not fetch the latest schemas of subjects.
+type SchemaByIDFunc func(id int) (avroSchema string, err error)
+
+func (f SchemaByIDFunc) GetSchemaByID(id int) (avroSchema string, err error) {
+	return f(id)
+}
+
+func (f SchemaByIDFunc) GetLatestSchema(subject string) (schema schemaregistry.Schema, err error) {
+	return schema, fmt.Errorf("the latest schema of subject %v is not fetched by a SchemaByIDFunc, it only decodes", subject)
+}
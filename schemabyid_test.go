@@ -0,0 +1,139 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+func TestSchemaByIDFunc(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("events-value", unionsSchema)
+	data, err := NewCodec(registry, TopicNameStrategy{}).Encode("events", false, unionsTests[0].native)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched []int
+	codec := NewCodec(SchemaByIDFunc(func(id int) (string, error) {
+		fetched = append(fetched, id)
+		return registry.GetSchemaByID(id)
+	}), TopicNameStrategy{})
+
+	for i := 0; i < 2; i++ {
+		if native, err := codec.Decode("events", false, data); err != nil || !reflect.DeepEqual(native, unionsTests[0].native) {
+			t.Errorf("Decode() returned %v, %v", native, err)
+		}
+	}
+	if len(fetched) != 1 {
+		t.Errorf("the schema was fetched %d times, want once", len(fetched))
+	}
+
+	if _, err := codec.Encode("events", false, unionsTests[0].native); err == nil {
+		t.Error("Encode() with a SchemaByIDFunc did not fail")
+	}
+	failing := NewCodec(SchemaByIDFunc(func(id int) (string, error) { return "", ErrSchemaNotFound }), TopicNameStrategy{})
+	if _, err := failing.Decode("events", false, data); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("Decode() returned %v, want the error of the func", err)
+	}
+}
+
+func TestSchemaByIDFuncRouted(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("events-value", unionsSchema)
+	data, err := NewCodec(registry, TopicNameStrategy{}).Encode("events", false, unionsTests[0].native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := make(map[string]int)
+	fetcher := func(name string) SchemaByIDFunc {
+		return func(id int) (string, error) {
+			fetched[name]++
+			return registry.GetSchemaByID(id)
+		}
+	}
+
+	// the func of the router is a registry per topic, the others fetch the schema once
+	tests := []struct {
+		name   string
+		option Option
+		want   map[string]int
+	}{
+		{"prefixes", WithRegistryPrefixes(map[string]RegistryClient{"routed.": fetcher("prefixes")}), map[string]int{"default": 1, "prefixes": 1}},
+		{"router", WithRegistryRouter(func(topic string, isKey bool) RegistryClient { return fetcher("router") }), map[string]int{"default": 1, "router": 2}},
+	}
+	for _, test := range tests {
+		fetched = make(map[string]int)
+		codec := NewCodec(fetcher("default"), TopicNameStrategy{}, test.option)
+		for i := 0; i < 2; i++ {
+			for _, topic := range []string{"events", "routed.events"} {
+				if native, err := codec.Decode(topic, false, data); err != nil || !reflect.DeepEqual(native, unionsTests[0].native) {
+					t.Errorf("%v: Decode() of %v returned %v, %v", test.name, topic, native, err)
+				}
+			}
+			if info, err := codec.SchemaByID(context.Background(), 1); err != nil || info.Schema != unionsSchema {
+				t.Errorf("%v: SchemaByID() returned %+v, %v", test.name, info, err)
+			}
+		}
+		if _, err := codec.Encode("routed.events", false, unionsTests[0].native); err == nil || !strings.Contains(err.Error(), "registry kafkaavro.SchemaByIDFunc") {
+			t.Errorf("%v: Encode() returned %v, want the error of the registry", test.name, err)
+		}
+		if !reflect.DeepEqual(fetched, test.want) {
+			t.Errorf("%v: the schema was fetched %v times, want %v", test.name, fetched, test.want)
+		}
+		codec.Close()
+	}
+}
+
+func TestCodecSchemaByID(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", namespacedSchema)
+	protoID := registry.RegisterSchema("payments-value", schemaregistry.Schema{Schema: `syntax = "proto3"; message Payment {}`, SchemaType: "PROTOBUF"})
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	want := SchemaInfo{ID: id, Schema: namespacedSchema, RecordName: "com.example.order", SchemaType: schemaregistry.SchemaTypeAvro}
+	for i := 0; i < 2; i++ {
+		if info, err := codec.SchemaByID(context.Background(), id); err != nil || info != want {
+			t.Errorf("SchemaByID(%d) returned %+v, %v, want %+v", id, info, err, want)
+		}
+	}
+	// the schema is cached for Decode as well
+	data, err := codec.EncodeWithSchemaID(id, map[string]interface{}{"f1": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Decode("orders", false, data); err != nil {
+		t.Fatal(err)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 1 {
+		t.Errorf("the schema was fetched %d times, want once", calls)
+	}
+
+	if info, err := codec.SchemaByID(context.Background(), protoID); err != nil || info.SchemaType != "PROTOBUF" || info.Schema == "" {
+		t.Errorf("SchemaByID(%d) of a protobuf schema returned %+v, %v", protoID, info, err)
+	}
+	if _, err := codec.SchemaByID(context.Background(), 42); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("SchemaByID(42) returned %v", err)
+	}
+}
+
+func TestSchemaByID(t *testing.T) {
+
+	client, closeRegistry := newErrorRegistry(t)
+	defer closeRegistry()
+
+	if info, err := SchemaByID(context.Background(), client.String(), 1); err != nil || info.Schema != testSchema || info.ID != 1 {
+		t.Errorf("SchemaByID(1) returned %+v, %v", info, err)
+	}
+	if _, err := SchemaByID(context.Background(), client.String(), 4); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("SchemaByID(4) returned %v", err)
+	}
+}
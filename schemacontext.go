@@ -0,0 +1,72 @@
+package kafkaavro
+
+import "strings"
+
+// ContextNameStrategy prefixes the subjects of the Strategy with a schema context of the schema
+// registry, e.g. :.lsrc-abc123:orders-value for the context .lsrc-abc123, as schema linking and
+// multi-tenant registries name the subjects. See WithSchemaContext.
+type ContextNameStrategy struct {
+	Context  string
+	Strategy SubjectNameStrategy
+}
+
+// GetSubjectName returns the subject of the Strategy in the context.
+func (s ContextNameStrategy) GetSubjectName(topic string, isKey bool) (subjectName SubjectName) {
+	return ContextSubject(s.Context, s.Strategy.GetSubjectName(topic, isKey))
+}
+
+// WithSchemaContext resolves the subjects in the schema context, e.g. lsrc-abc123 or
+// .lsrc-abc123: the subjects of the strategy of the Codec are prefixed with :.lsrc-abc123:, as
+// are the subjects which are passed to the Codec, e.g. to InvalidateSubject or ValidateJSON, unless
+// they have a context already. Decoding finds the writer schemas by id, outside of any context.
+func WithSchemaContext(ctx string) Option {
+	return func(c *Codec) {
+		if ctx = normalizeContext(ctx); ctx == "" {
+			return
+		}
+		c.schemaContext = ctx
+		if strategy, ok := c.subjectNameStrategy.(ContextNameStrategy); ok {
+			c.subjectNameStrategy = strategy.Strategy
+		}
+		c.subjectNameStrategy = ContextNameStrategy{Context: ctx, Strategy: c.subjectNameStrategy}
+	}
+}
+
+// ContextSubject returns the subject in the context, e.g. :.lsrc-abc123:orders-value for the
+// context lsrc-abc123 or .lsrc-abc123. A subject with a context and the subjects of the default
+// context (an empty context or .) are returned as is.
+func ContextSubject(ctx string, subject SubjectName) SubjectName {
+	ctx = normalizeContext(ctx)
+	if ctx == "" {
+		return subject
+	}
+	if c, _ := SplitContextSubject(subject); c != "" {
+		return subject
+	}
+	return ":" + ctx + ":" + subject
+}
+
+// SplitContextSubject returns the context and the subject of a qualified subject, e.g. .lsrc-abc123
+// and orders-value for :.lsrc-abc123:orders-value, and an empty context for a subject without one.
+func SplitContextSubject(qualified SubjectName) (ctx string, subject SubjectName) {
+	if !strings.HasPrefix(qualified, ":.") {
+		return "", qualified
+	}
+	end := strings.Index(qualified[1:], ":")
+	if end < 0 {
+		return "", qualified
+	}
+	return qualified[1 : end+1], qualified[end+2:]
+}
+
+// normalizeContext returns the context as .name, the names of the default context are empty.
+func normalizeContext(ctx string) string {
+	ctx = strings.Trim(ctx, ":")
+	if ctx == "" || ctx == "." {
+		return ""
+	}
+	if !strings.HasPrefix(ctx, ".") {
+		ctx = "." + ctx
+	}
+	return ctx
+}
@@ -0,0 +1,95 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestContextSubject(t *testing.T) {
+
+	tests := []struct {
+		ctx     string
+		subject string
+		want    string
+	}{
+		{"lsrc-abc123", "orders-value", ":.lsrc-abc123:orders-value"},
+		{".lsrc-abc123", "orders-value", ":.lsrc-abc123:orders-value"},
+		{":.lsrc-abc123:", "orders-value", ":.lsrc-abc123:orders-value"},
+		{"lsrc-abc123", ":.other:orders-value", ":.other:orders-value"},
+		{"", "orders-value", "orders-value"},
+		{".", "orders-value", "orders-value"},
+	}
+	for _, test := range tests {
+		if got := ContextSubject(test.ctx, test.subject); got != test.want {
+			t.Errorf("ContextSubject(%q, %q) returned %q, want %q", test.ctx, test.subject, got, test.want)
+		}
+	}
+
+	splits := []struct {
+		qualified, ctx, subject string
+	}{
+		{":.lsrc-abc123:orders-value", ".lsrc-abc123", "orders-value"},
+		{"orders-value", "", "orders-value"},
+		{":.unterminated", "", ":.unterminated"},
+	}
+	for _, test := range splits {
+		if ctx, subject := SplitContextSubject(test.qualified); ctx != test.ctx || subject != test.subject {
+			t.Errorf("SplitContextSubject(%q) returned %q, %q", test.qualified, ctx, subject)
+		}
+	}
+}
+
+func TestWithSchemaContext(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register(":.lsrc-abc123:orders-value", ocfSchema)
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaContext("lsrc-abc123"))
+
+	if subject := codec.Subject("orders", false); subject != ":.lsrc-abc123:orders-value" {
+		t.Errorf("Subject() returned %v", subject)
+	}
+	// the option replaces the context of a strategy with a context
+	wrapped := NewCodec(registry, ContextNameStrategy{Context: "other", Strategy: TopicNameStrategy{}}, WithSchemaContext(".lsrc-abc123"))
+	if subject := wrapped.Subject("orders", true); subject != ":.lsrc-abc123:orders-key" {
+		t.Errorf("Subject() of a strategy with a context returned %v", subject)
+	}
+
+	data, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decoding finds the schema by id, with or without the context
+	for _, decoder := range []*Codec{codec, NewCodec(registry, TopicNameStrategy{})} {
+		native, err := decoder.Decode("orders", false, data)
+		if err != nil || !reflect.DeepEqual(native, order(1)) {
+			t.Errorf("Decode() returned %v, %v", native, err)
+		}
+	}
+
+	// outside of the context the subject is not known, and the errors name the subject in the context
+	if _, err = NewCodec(registry, TopicNameStrategy{}).Encode("orders", false, order(1)); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("Encode() outside of the context returned %v", err)
+	}
+	if _, err = codec.Encode("orders", true, int64(1)); !errors.Is(err, ErrSchemaNotFound) || !strings.Contains(err.Error(), ":.lsrc-abc123:orders-key") {
+		t.Errorf("Encode() of a missing subject returned %v", err)
+	}
+
+	// the subjects passed to the codec are in the context too
+	if err = codec.ValidateJSON("orders-value", -1, []byte(`{"id":1,"note":null}`)); err != nil {
+		t.Errorf("ValidateJSON() returned %v", err)
+	}
+	next := registry.Register(":.lsrc-abc123:orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},
+		{"name":"note","type":["null","string"],"default":null},{"name":"total","type":"double","default":0}]}`)
+	codec.InvalidateSubject("orders-value")
+	if data, err = codec.Encode("orders", false, order(2)); err != nil {
+		t.Fatal(err)
+	}
+	if id, _, err := codec.WriterSchema(data); err != nil || id != next {
+		t.Errorf("the value is encoded with schema %v, %v, want %v after InvalidateSubject", id, err, next)
+	}
+}
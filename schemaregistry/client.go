@@ -0,0 +1,495 @@
+// Package schemaregistry is a small client for the REST API of the Confluent schema registry.
+//
+// It only covers the calls which are required to encode and decode Kafka Avro data.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Error codes returned by the schema registry.
+const (
+	subjectNotFoundCode = 40401
+	versionNotFoundCode = 40402
+	schemaNotFoundCode  = 40403
+	incompatibleCode    = 409
+	invalidSchemaCode   = 42201
+	// the subject has no compatibility level of its own
+	subjectCompatibilityNotFoundCode = 40408
+)
+
+var (
+	// ErrSchemaNotFound is reported for an unknown subject, version or schema.
+	ErrSchemaNotFound = errors.New("schema not found")
+	// ErrIncompatibleSchema is reported when a schema is not compatible with the registered versions of the subject.
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+	// ErrInvalidSchema is reported when the schema registry rejects a schema, e.g. one which does not parse.
+	ErrInvalidSchema = errors.New("invalid schema")
+	// ErrInvalidCompatibilityLevel is reported for a compatibility level which is not one of the
+	// levels of the registry.
+	ErrInvalidCompatibilityLevel = errors.New("invalid compatibility level")
+	// ErrRegistryUnavailable is reported when the schema registry can not be reached or fails with a server error.
+	ErrRegistryUnavailable = errors.New("schema registry unavailable")
+)
+
+// Client talks to the schema registry at a base url.
+type Client struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+
+	normalize bool
+	// noNormalize is set once the registry rejected the normalize parameter, it is shared by the
+	// copies of the client
+	noNormalize *atomic.Bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// UsingClient makes the Client send its requests with the given http client, e.g. to configure
+// TLS or authentication.
+func UsingClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.client = httpClient
+	}
+}
+
+// UsingBasicAuth makes the Client authenticate with basic authentication, e.g. with the API key and
+// secret of Confluent Cloud.
+func UsingBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// UsingNormalize makes the Client ask the registry to normalize the schemas which it registers or
+// looks up, so that schemas which only differ in formatting get the same id. A registry which does
+// not support normalization, e.g. older versions of Karapace or Redpanda, is called without it, see
+// DetectCapabilities.
+func UsingNormalize(normalize bool) Option {
+	return func(c *Client) {
+		c.normalize = normalize
+	}
+}
+
+// NewClient creates a client for the schema registry at baseURL.
+func NewClient(baseURL string, options ...Option) (client *Client, err error) {
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry url %q: %w", baseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid schema registry url %q, expected http(s)://host:port", baseURL)
+	}
+
+	client = &Client{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		client:      &http.Client{Timeout: 30 * time.Second},
+		noNormalize: &atomic.Bool{},
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return
+}
+
+// The schema types of the registry, the registry omits the schema type of avro schemas.
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// Schema is a schema registered under a subject.
+type Schema struct {
+	Schema     string      `json:"schema"`
+	Subject    string      `json:"subject"`
+	Version    int         `json:"version"`
+	ID         int         `json:"id,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// schemaRequest is the body of the requests with a schema, only the schema as the Java client
+// sends it.
+type schemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+// Reference is a schema which a schema imports, e.g. a .proto file, by the name with which it is imported.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Type returns the schema type, SchemaTypeAvro if the registry did not report one.
+func (s Schema) Type() string {
+	if s.SchemaType == "" {
+		return SchemaTypeAvro
+	}
+	return s.SchemaType
+}
+
+// ResourceError is the error returned by the schema registry for a failed request.
+type ResourceError struct {
+	ErrorCode int    `json:"error_code"`
+	Method    string `json:"method,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+func (e ResourceError) Error() string {
+	return fmt.Sprintf("client: (%v: %v) failed with error code %d: %v", e.Method, e.URI, e.ErrorCode, e.Message)
+}
+
+// Is maps the error code to ErrSchemaNotFound, ErrIncompatibleSchema, ErrInvalidSchema or
+// ErrRegistryUnavailable. Karapace and Redpanda sometimes report the http status code rather than
+// the error code of the Confluent registry, e.g. 404 for an unknown subject, which map the same.
+func (e ResourceError) Is(target error) bool {
+	switch target {
+	case ErrSchemaNotFound:
+		return e.ErrorCode == subjectNotFoundCode || e.ErrorCode == versionNotFoundCode || e.ErrorCode == schemaNotFoundCode || e.ErrorCode == http.StatusNotFound
+	case ErrIncompatibleSchema:
+		return e.ErrorCode == incompatibleCode
+	case ErrInvalidSchema:
+		return e.ErrorCode == invalidSchemaCode || e.ErrorCode == http.StatusUnprocessableEntity
+	case ErrRegistryUnavailable:
+		// the http status code or the error code of the registry, e.g. 50003 for a forwarding error
+		return (e.ErrorCode >= 500 && e.ErrorCode < 600) || (e.ErrorCode >= 50000 && e.ErrorCode < 60000)
+	}
+	return false
+}
+
+// IsSubjectNotFound returns true if the error is the schema registry reporting an unknown subject.
+func IsSubjectNotFound(err error) bool {
+	return hasErrorCode(err, subjectNotFoundCode) || hasErrorCode(err, http.StatusNotFound)
+}
+
+// IsSchemaNotFound returns true if the error is the schema registry reporting an unknown schema or version.
+func IsSchemaNotFound(err error) bool {
+	return hasErrorCode(err, schemaNotFoundCode) || hasErrorCode(err, versionNotFoundCode)
+}
+
+func hasErrorCode(err error, errorCode int) bool {
+	var resourceErr ResourceError
+	return errors.As(err, &resourceErr) && resourceErr.ErrorCode == errorCode
+}
+
+// String returns the url of the registry, without the user info.
+func (c *Client) String() string {
+	if parsed, err := url.Parse(c.baseURL); err == nil && parsed.User != nil {
+		parsed.User = nil
+		return parsed.String()
+	}
+	return c.baseURL
+}
+
+// CloseIdleConnections closes the idle connections of the http client.
+func (c *Client) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// Subjects returns all registered subjects.
+func (c *Client) Subjects() (subjects []string, err error) {
+	err = c.do(http.MethodGet, "/subjects", nil, &subjects)
+	return
+}
+
+// Versions returns the registered versions of a subject.
+func (c *Client) Versions(subject string) (versions []int, err error) {
+	err = c.do(http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions", nil, &versions)
+	return
+}
+
+// IsRegistered checks if the avro schema is registered under the subject and, if it is,
+// returns the registration.
+func (c *Client) IsRegistered(subject string, avroSchema string) (isRegistered bool, schema Schema, err error) {
+
+	err = c.doNormalized(http.MethodPost, "/subjects/"+url.PathEscape(subject), schemaRequest{avroSchema}, &schema)
+	if IsSubjectNotFound(err) || IsSchemaNotFound(err) {
+		return false, Schema{}, nil
+	}
+	if err != nil {
+		return
+	}
+	return true, schema, nil
+}
+
+// RegisterNewSchema registers the avro schema under the subject and returns its schema id.
+// Registering a schema which is already registered returns the existing id.
+func (c *Client) RegisterNewSchema(subject string, avroSchema string) (id int, err error) {
+
+	var response struct {
+		ID int `json:"id"`
+	}
+	err = c.doNormalized(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schemaRequest{avroSchema}, &response)
+	id = response.ID
+	return
+}
+
+// GetSchemaByID returns the schema with the given id.
+func (c *Client) GetSchemaByID(id int) (avroSchema string, err error) {
+
+	var response struct {
+		Schema string `json:"schema"`
+	}
+	err = c.do(http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &response)
+	avroSchema = response.Schema
+	return
+}
+
+// GetSchema returns the schema with the given id along with its schema type and references. The
+// subject and version of the schema are not known.
+func (c *Client) GetSchema(id int) (schema Schema, err error) {
+	err = c.do(http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &schema)
+	schema.ID = id
+	return
+}
+
+// GetSchemaBySubject returns the given version of the subject.
+func (c *Client) GetSchemaBySubject(subject string, version int) (schema Schema, err error) {
+	err = c.do(http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/"+strconv.Itoa(version), nil, &schema)
+	return
+}
+
+// GetLatestSchema returns the latest version of the subject.
+func (c *Client) GetLatestSchema(subject string) (schema Schema, err error) {
+	err = c.do(http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &schema)
+	return
+}
+
+// IsCompatible checks with the compatibility level of the subject if the avro schema is compatible
+// with the latest version of the subject.
+func (c *Client) IsCompatible(subject string, avroSchema string) (isCompatible bool, err error) {
+
+	var response struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	err = c.do(http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", schemaRequest{avroSchema}, &response)
+	isCompatible = response.IsCompatible
+	return
+}
+
+// CompatibilityLevel is the compatibility level of a subject, with which the registry checks the
+// schemas registered under it.
+type CompatibilityLevel string
+
+const (
+	CompatibilityBackward           CompatibilityLevel = "BACKWARD"
+	CompatibilityBackwardTransitive CompatibilityLevel = "BACKWARD_TRANSITIVE"
+	CompatibilityForward            CompatibilityLevel = "FORWARD"
+	CompatibilityForwardTransitive  CompatibilityLevel = "FORWARD_TRANSITIVE"
+	CompatibilityFull               CompatibilityLevel = "FULL"
+	CompatibilityFullTransitive     CompatibilityLevel = "FULL_TRANSITIVE"
+	CompatibilityNone               CompatibilityLevel = "NONE"
+)
+
+var compatibilityLevels = []CompatibilityLevel{
+	CompatibilityBackward, CompatibilityBackwardTransitive, CompatibilityForward, CompatibilityForwardTransitive,
+	CompatibilityFull, CompatibilityFullTransitive, CompatibilityNone,
+}
+
+// Validate returns an error wrapping ErrInvalidCompatibilityLevel if the level is not one of the
+// levels of the registry.
+func (l CompatibilityLevel) Validate() error {
+	for _, level := range compatibilityLevels {
+		if l == level {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q, use one of %v", ErrInvalidCompatibilityLevel, string(l), compatibilityLevels)
+}
+
+// ParseCompatibilityLevel parses a level, in any case, e.g. full_transitive.
+func ParseCompatibilityLevel(level string) (CompatibilityLevel, error) {
+	l := CompatibilityLevel(strings.ToUpper(strings.TrimSpace(level)))
+	if err := l.Validate(); err != nil {
+		return "", err
+	}
+	return l, nil
+}
+
+// SetCompatibility sets the compatibility level of the subject.
+func (c *Client) SetCompatibility(ctx context.Context, subject string, level CompatibilityLevel) (err error) {
+
+	if err = level.Validate(); err != nil {
+		return
+	}
+	request := struct {
+		Compatibility CompatibilityLevel `json:"compatibility"`
+	}{level}
+	var response struct {
+		Compatibility string `json:"compatibility"`
+	}
+	return c.doContext(ctx, http.MethodPut, "/config/"+url.PathEscape(subject), request, &response)
+}
+
+// GetCompatibility returns the compatibility level of the subject, the global level of the
+// registry if the subject has none.
+func (c *Client) GetCompatibility(ctx context.Context, subject string) (level CompatibilityLevel, err error) {
+
+	var response struct {
+		CompatibilityLevel CompatibilityLevel `json:"compatibilityLevel"`
+	}
+	path := "/config/" + url.PathEscape(subject)
+	err = c.doContext(ctx, http.MethodGet, path+"?defaultToGlobal=true", nil, &response)
+	if rejectsParameter(err) {
+		// registries without defaultToGlobal
+		err = c.doContext(ctx, http.MethodGet, path, nil, &response)
+	}
+	if hasErrorCode(err, subjectCompatibilityNotFoundCode) {
+		err = c.doContext(ctx, http.MethodGet, "/config", nil, &response)
+	}
+	return response.CompatibilityLevel, err
+}
+
+// GetSubjectCompatibility returns the compatibility level of the subject itself, found is false if
+// the subject has none and the global level of the registry applies.
+func (c *Client) GetSubjectCompatibility(ctx context.Context, subject string) (level CompatibilityLevel, found bool, err error) {
+
+	var response struct {
+		CompatibilityLevel CompatibilityLevel `json:"compatibilityLevel"`
+	}
+	err = c.doContext(ctx, http.MethodGet, "/config/"+url.PathEscape(subject), nil, &response)
+	if hasErrorCode(err, subjectCompatibilityNotFoundCode) || IsSubjectNotFound(err) {
+		// older registries report a subject without a level as not found
+		return "", false, nil
+	}
+	if err != nil {
+		return
+	}
+	return response.CompatibilityLevel, true, nil
+}
+
+// Capabilities are the features of the API of the Confluent registry which a registry supports,
+// see DetectCapabilities.
+type Capabilities struct {
+	// SchemaTypes are the schema types the registry supports, only SchemaTypeAvro for a registry
+	// without the /schemas/types endpoint.
+	SchemaTypes []string
+	// Normalize is true if the registry accepts the normalize parameter, see UsingNormalize.
+	Normalize bool
+}
+
+// probeSubject is the subject of which DetectCapabilities looks up a schema, it is not registered.
+const probeSubject = "kafkaavro-capabilities-probe"
+
+// DetectCapabilities probes the registry for the features in which Karapace, Redpanda and older
+// versions of the Confluent registry differ, e.g. at startup. The client does not use the features
+// which the registry does not support, rather than failing on them: e.g. it stops sending the
+// normalize parameter. The probes do not register anything.
+func (c *Client) DetectCapabilities() (capabilities Capabilities, err error) {
+
+	err = c.do(http.MethodGet, "/schemas/types", nil, &capabilities.SchemaTypes)
+	if errors.Is(err, ErrSchemaNotFound) {
+		capabilities.SchemaTypes, err = []string{SchemaTypeAvro}, nil
+	}
+	if err != nil {
+		return
+	}
+
+	// a registry which supports the parameter reports the unknown subject, the others reject it
+	var schema Schema
+	err = c.do(http.MethodPost, "/subjects/"+probeSubject+"?normalize=true", schemaRequest{`"string"`}, &schema)
+	switch {
+	case err == nil, errors.Is(err, ErrSchemaNotFound):
+		capabilities.Normalize, err = true, nil
+	case rejectsParameter(err):
+		capabilities.Normalize, err = false, nil
+	default:
+		return
+	}
+	c.noNormalize.Store(!capabilities.Normalize)
+	return
+}
+
+// rejectsParameter returns true if the registry rejected the request with a bare http status, as
+// registries do for a parameter they do not know, rather than with an error code of the registry,
+// e.g. 42201 for an invalid schema.
+func rejectsParameter(err error) bool {
+	return hasErrorCode(err, http.StatusBadRequest) || hasErrorCode(err, http.StatusUnprocessableEntity)
+}
+
+// doNormalized does the request with the normalize parameter if the client normalizes and the
+// registry supports it. A registry which rejects the parameter is called again without it.
+func (c *Client) doNormalized(method string, path string, body interface{}, result interface{}) (err error) {
+
+	if !c.normalize || c.noNormalize.Load() {
+		return c.do(method, path, body, result)
+	}
+	err = c.do(method, path+"?normalize=true", body, result)
+	if rejectsParameter(err) {
+		c.noNormalize.Store(true)
+		return c.do(method, path, body, result)
+	}
+	return
+}
+
+func (c *Client) do(method string, path string, body interface{}, result interface{}) (err error) {
+	return c.doContext(context.Background(), method, path, body, result)
+}
+
+func (c *Client) doContext(ctx context.Context, method string, path string, body interface{}, result interface{}) (err error) {
+
+	var requestBody io.Reader
+	if body != nil {
+		payload, marshalErr := json.Marshal(body)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		requestBody = bytes.NewReader(payload)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, requestBody)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Accept", contentType)
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRegistryUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		resourceErr := ResourceError{ErrorCode: response.StatusCode, Method: method, URI: request.URL.String()}
+		payload, _ := io.ReadAll(response.Body)
+		if json.Unmarshal(payload, &resourceErr) != nil || resourceErr.Message == "" {
+			resourceErr.Message = strings.TrimSpace(string(payload))
+		}
+		if resourceErr.Message == "" {
+			resourceErr.Message = response.Status
+		}
+		return resourceErr
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("invalid response from %v %v: %w", method, request.URL, err)
+	}
+	return
+}
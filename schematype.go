@@ -0,0 +1,156 @@
+package kafkaavro
+
+import (
+	"fmt"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// SchemaType decodes the data written with the schemas of a schema type of the registry other
+// than avro, e.g. kafkaavroproto.SchemaType for protobuf. The Codec dispatches the data by the
+// schema type of its writer schema, see WithSchemaType.
+type SchemaType interface {
+	// Name is the schema type reported by the registry, e.g. schemaregistry.SchemaTypeProtobuf.
+	Name() string
+	// NewBodyDecoder returns the decoder of the data written with the schema, the schemas it
+	// references are fetched with references.
+	NewBodyDecoder(schema schemaregistry.Schema, references ReferenceLookup) (decoder BodyDecoder, err error)
+}
+
+// BodyDecoder decodes the data which follows the 5 bytes header, it is safe for concurrent use.
+type BodyDecoder interface {
+	DecodeBody(body []byte) (native interface{}, err error)
+}
+
+// EncodingSchemaType is a SchemaType which also encodes, the Codec encodes the data of the subjects
+// of which the latest schema is of that type with it, e.g. kafkaavro.JSONSchemaType.
+type EncodingSchemaType interface {
+	SchemaType
+	NewBodyEncoder(schema schemaregistry.Schema, references ReferenceLookup) (encoder BodyEncoder, err error)
+}
+
+// BodyEncoder encodes the native value after the 5 bytes header, it is safe for concurrent use.
+type BodyEncoder interface {
+	AppendBody(data []byte, native interface{}) ([]byte, error)
+}
+
+// ReferenceLookup fetches the schema of a reference from the registry.
+type ReferenceLookup func(reference schemaregistry.Reference) (schema schemaregistry.Schema, err error)
+
+// WithSchemaType decodes the data of the schemas of the schema type, e.g.
+// WithSchemaType(kafkaavroproto.SchemaType{}). The schema type is only known with a registry client
+// which implements GetSchema, as *schemaregistry.Client and *mockregistry.Registry do. Without a
+// SchemaType, the data of the schemas of that type fails to decode with ErrUnsupportedSchemaType.
+//
+// Encode encodes with the schema types which are an EncodingSchemaType. DecodeWithReader and the
+// logical types and enums are avro only.
+func WithSchemaType(schemaType SchemaType) Option {
+	return func(c *Codec) {
+		if c.schemaTypes == nil {
+			c.schemaTypes = make(map[string]SchemaType)
+		}
+		c.schemaTypes[schemaType.Name()] = schemaType
+	}
+}
+
+// schemaFetcher is implemented by the registry clients which report the schema type and references.
+type schemaFetcher interface {
+	GetSchema(id int) (schema schemaregistry.Schema, err error)
+}
+
+// subjectVersionFetcher is implemented by the registry clients which fetch the references.
+type subjectVersionFetcher interface {
+	GetSchemaBySubject(subject string, version int) (schema schemaregistry.Schema, err error)
+}
+
+// newSchemaEncoder returns the encoder of the latest schema of a subject of another schema
+// type than avro.
+func (c *Codec) newSchemaEncoder(subjectName SubjectName, schema schemaregistry.Schema) (encoder BodyEncoder, err error) {
+
+	schemaType, ok := c.schemaTypes[schema.Type()].(EncodingSchemaType)
+	if !ok {
+		return nil, fmt.Errorf("%w: the latest schema %d of subject %v is a %v schema, which is not encoded", ErrUnsupportedSchemaType, schema.ID, subjectName, schema.Type())
+	}
+	if encoder, err = schemaType.NewBodyEncoder(schema, c.lookupReference); err != nil {
+		return nil, fmt.Errorf("%w: the %v encoder of schema %d: %w", ErrCodecBuild, schema.Type(), schema.ID, err)
+	}
+	return
+}
+
+// schemaDecoder is the decoder of a schema of another schema type than avro. The decoder is nil
+// if the schema type is not supported (see WithSchemaType), the schema of an id does not change so
+// it is cached like the decoders.
+type schemaDecoder struct {
+	schema  schemaregistry.Schema
+	decoder BodyDecoder
+}
+
+// fetchSchema fetches the schema with the id, with its schema type if the client reports it.
+func (c *Codec) fetchSchema(id SchemaID) (schema schemaregistry.Schema, err error) {
+	if fetcher, ok := c.client.(schemaFetcher); ok {
+		return fetcher.GetSchema(id)
+	}
+	avroSchema, err := c.client.GetSchemaByID(id)
+	return schemaregistry.Schema{Schema: avroSchema, ID: id}, err
+}
+
+// newSchemaDecoder caches the decoder of a schema of another schema type than avro. It returns
+// the ErrUnsupportedSchemaType error which codecFor returns for the schema, a decoder which fails
+// to build is remembered like a codec, see WithCodecBuildFailureTTL.
+func (c *Codec) newSchemaDecoder(id SchemaID, schema schemaregistry.Schema) error {
+
+	schemaType, found := c.schemaTypes[schema.Type()]
+	if !found {
+		d := schemaDecoder{schema: schema}
+		c.decoderByID.put(id, d)
+		return d.notAvro(id)
+	}
+	decoder, err := schemaType.NewBodyDecoder(schema, c.lookupReference)
+	if err != nil {
+		err = fmt.Errorf("%w: the %v decoder of schema %d: %w", ErrCodecBuild, schema.Type(), id, err)
+		c.rememberCodecBuildFailure(id, err)
+		return err
+	}
+	d := schemaDecoder{schema, decoder}
+	c.decoderByID.put(id, d)
+	return d.notAvro(id)
+}
+
+func (d schemaDecoder) notAvro(id SchemaID) error {
+	if d.decoder == nil {
+		return fmt.Errorf("%w: schema %d is a %v schema, see WithSchemaType", ErrUnsupportedSchemaType, id, d.schema.Type())
+	}
+	return fmt.Errorf("%w: schema %d is a %v schema", ErrUnsupportedSchemaType, id, d.schema.Type())
+}
+
+// decodeOther decodes the data with the decoder of the schema id if it is a schema of another
+// schema type than avro, and returns err, the error of codecFor, otherwise.
+func (c *Codec) decodeOther(topic string, isKey bool, schemaID SchemaID, data []byte, err error) (native interface{}, _ error) {
+
+	decoder, found := c.decoderByID.get(schemaID)
+	if !found || decoder.decoder == nil {
+		return nil, err
+	}
+	if native, err = decoder.decoder.DecodeBody(data[headerSize:]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPayload, err)
+	}
+	c.observe(topic, isKey, schemaID, decoder.schema.Schema, decoder.schema.Type())
+	return native, nil
+}
+
+func (c *Codec) lookupReference(reference schemaregistry.Reference) (schema schemaregistry.Schema, err error) {
+
+	if c.offline {
+		return schema, notCached(fmt.Sprintf("reference %v (version %d of %v)", reference.Name, reference.Version, reference.Subject), "")
+	}
+
+	fetcher, ok := c.client.(subjectVersionFetcher)
+	if !ok {
+		return schema, fmt.Errorf("the registry client does not fetch the reference %v", reference.Name)
+	}
+	if schema, err = fetcher.GetSchemaBySubject(reference.Subject, reference.Version); err != nil {
+		return schema, fmt.Errorf("failed to fetch reference %v (version %d of %v): %w", reference.Name, reference.Version, reference.Subject, err)
+	}
+	err = c.checkSchemaSize(schema.Schema, "reference "+reference.Name)
+	return
+}
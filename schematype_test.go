@@ -0,0 +1,118 @@
+package kafkaavro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// fakeSchemaType decodes the body as JSON, whatever the schema, and checks the references.
+type fakeSchemaType struct{}
+
+func (fakeSchemaType) Name() string { return schemaregistry.SchemaTypeJSON }
+
+func (fakeSchemaType) NewBodyDecoder(schema schemaregistry.Schema, references ReferenceLookup) (BodyDecoder, error) {
+	for _, reference := range schema.References {
+		if _, err := references(reference); err != nil {
+			return nil, err
+		}
+	}
+	return fakeDecoder{}, nil
+}
+
+type fakeDecoder struct{}
+
+func (fakeDecoder) DecodeBody(body []byte) (native interface{}, err error) {
+	err = json.Unmarshal(body, &native)
+	return
+}
+
+func TestSchemaTypes(t *testing.T) {
+
+	registry := mockregistry.New()
+	avroID := registry.Register("orders-value", `"string"`)
+	registry.RegisterSchema("common", schemaregistry.Schema{Schema: `{}`, SchemaType: schemaregistry.SchemaTypeJSON})
+	jsonID := registry.RegisterSchema("events-value", schemaregistry.Schema{Schema: `{"type":"object"}`, SchemaType: schemaregistry.SchemaTypeJSON,
+		References: []schemaregistry.Reference{{Name: "common.json", Subject: "common", Version: 1}}})
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(fakeSchemaType{}))
+	avroData, err := codec.Encode("orders", false, "o-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData := append([]byte{0, 0, 0, 0, byte(jsonID)}, `{"id":"e-1"}`...)
+
+	var scratch DecodeScratch
+	for i := 0; i < 2; i++ {
+		if native, err := codec.Decode("events", false, jsonData); err != nil || !reflect.DeepEqual(native, map[string]interface{}{"id": "e-1"}) {
+			t.Errorf("Decode() of JSON data returned %v, %v", native, err)
+		}
+		if native, err := codec.DecodeReuse("events", false, jsonData, &scratch); err != nil || !reflect.DeepEqual(native, map[string]interface{}{"id": "e-1"}) {
+			t.Errorf("DecodeReuse() of JSON data returned %v, %v", native, err)
+		}
+		if native, err := codec.Decode("orders", false, avroData); err != nil || native != "o-1" {
+			t.Errorf("Decode() of avro data returned %v, %v", native, err)
+		}
+	}
+	if stats := codec.CacheStats(); stats.Misses != 2 {
+		t.Errorf("CacheStats() returned %+v, want 2 misses, the encode and the JSON schema", stats)
+	}
+	if count := registry.CallCount(mockregistry.GetSchemaBySubject); count != 1 {
+		t.Errorf("the reference was fetched %d times, want 1", count)
+	}
+
+	if _, err = codec.Decode("events", false, append(jsonData[:5:5], '{')); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("Decode() of invalid JSON data returned %v, want ErrMalformedPayload", err)
+	}
+	if _, err = codec.DecodeWithReader("events", false, jsonData, &ReaderSchema{}); !errors.Is(err, ErrUnsupportedSchemaType) {
+		t.Errorf("DecodeWithReader() of JSON data returned %v, want ErrUnsupportedSchemaType", err)
+	}
+	if _, err = codec.Encode("events", false, map[string]interface{}{"id": "e-1"}); !errors.Is(err, ErrUnsupportedSchemaType) {
+		t.Errorf("Encode() with a JSON schema returned %v, want ErrUnsupportedSchemaType", err)
+	}
+
+	codec = NewCodec(registry, TopicNameStrategy{})
+	if _, err = codec.Decode("events", false, jsonData); !errors.Is(err, ErrUnsupportedSchemaType) {
+		t.Errorf("Decode() without the SchemaType returned %v, want ErrUnsupportedSchemaType", err)
+	}
+	if native, err := codec.Decode("orders", false, append([]byte{0, 0, 0, 0, byte(avroID)}, avroData[5:]...)); err != nil || native != "o-1" {
+		t.Errorf("Decode() of avro data without the SchemaType returned %v, %v", native, err)
+	}
+}
+
+func TestSchemaTypeFailuresCached(t *testing.T) {
+
+	registry := mockregistry.New()
+	protobufID := registry.RegisterSchema("orders-value", schemaregistry.Schema{Schema: `syntax = "proto3"; message Order {}`, SchemaType: schemaregistry.SchemaTypeProtobuf})
+	brokenID := registry.RegisterSchema("events-value", schemaregistry.Schema{Schema: `{"type":"object"}`, SchemaType: schemaregistry.SchemaTypeJSON,
+		References: []schemaregistry.Reference{{Name: "missing.json", Subject: "missing", Version: 1}}})
+
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSchemaType(fakeSchemaType{}))
+	for i := 0; i < 5; i++ {
+		if _, err := codec.Decode("orders", false, []byte{0, 0, 0, 0, byte(protobufID), 0}); !errors.Is(err, ErrUnsupportedSchemaType) {
+			t.Errorf("Decode() of protobuf data returned %v, want ErrUnsupportedSchemaType", err)
+		}
+		if _, err := codec.Decode("events", false, []byte{0, 0, 0, 0, byte(brokenID), '{', '}'}); !errors.Is(err, ErrCodecBuild) {
+			t.Errorf("Decode() with a decoder which does not build returned %v, want ErrCodecBuild", err)
+		}
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 2 {
+		t.Errorf("the schemas were fetched %d times, want once each", calls)
+	}
+	if stats := codec.CacheStats(); stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("CacheStats() returned %+v, want 4 hits and 2 misses", stats)
+	}
+
+	info, err := codec.SchemaByID(context.Background(), protobufID)
+	if err != nil || info.SchemaType != schemaregistry.SchemaTypeProtobuf {
+		t.Errorf("SchemaByID() of the protobuf schema returned %+v, %v", info, err)
+	}
+	if calls := registry.CallCount(mockregistry.GetSchemaByID); calls != 2 {
+		t.Errorf("SchemaByID() fetched the cached protobuf schema")
+	}
+}
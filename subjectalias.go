@@ -0,0 +1,50 @@
+package kafkaavro
+
+import "fmt"
+
+// WithSubjectAliases maps the subjects of the strategy to the subjects of the registry, e.g. the
+// subject new.orders-value of a renamed topic to the subject orders-value of which the schemas
+// were kept. The subjects are resolved in this order: the SubjectNameStrategy computes the subject
+// of the topic, WithSchemaContext qualifies it, then the alias of the qualified subject replaces
+// it, or else the alias of the subject without its context, qualified in the context. Subject and
+// everything that uses it, e.g. Encode and the headers of EncodeWithHeaders, return the alias.
+// Decoding finds the writer schemas by id and is not affected. The errors of the encodes with an
+// alias name both subjects.
+func WithSubjectAliases(aliases map[string]string) Option {
+	return func(c *Codec) {
+		if c.subjectAliases == nil {
+			c.subjectAliases = make(map[SubjectName]SubjectName, len(aliases))
+		}
+		for subject, alias := range aliases {
+			c.subjectAliases[subject] = alias
+		}
+	}
+}
+
+// alias returns the alias of the computed subject, see WithSubjectAliases.
+func (c *Codec) alias(computed SubjectName) (alias SubjectName, aliased bool) {
+
+	if alias, aliased = c.subjectAliases[computed]; aliased {
+		return
+	}
+	if ctx, subject := SplitContextSubject(computed); ctx != "" {
+		if alias, aliased = c.subjectAliases[subject]; aliased {
+			return ContextSubject(ctx, alias), true
+		}
+	}
+	return computed, false
+}
+
+// aliasError adds the computed subject of the topic and its alias to the error, if the subject is
+// aliased.
+func (c *Codec) aliasError(topic string, isKey bool, err error) error {
+
+	if err == nil || len(c.subjectAliases) == 0 {
+		return err
+	}
+	computed := c.subjectNameStrategy.GetSubjectName(topic, isKey)
+	if alias, aliased := c.alias(computed); aliased {
+		return fmt.Errorf("subject %v of topic %v, aliased to subject %v: %w", computed, topic, alias, err)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package kafkaavro
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestWithSubjectAliases(t *testing.T) {
+
+	registry := mockregistry.New()
+	id := registry.Register("orders-value", testSchema)
+	registry.Register(":.tenant:payments-value", testSchema)
+	aliases := map[string]string{"new.orders-value": "orders-value", "new.payments-value": "payments-value", "new.refunds-value": "missing-value"}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSubjectAliases(aliases))
+	value := map[string]interface{}{"f1": "value"}
+
+	var tests = []struct {
+		codec *Codec
+		topic string
+		isKey bool
+		want  SubjectName
+	}{
+		{codec, "new.orders", false, "orders-value"},
+		{codec, "new.orders", true, "new.orders-key"},
+		{codec, "orders", false, "orders-value"},
+		// the alias of the subject without its context is qualified in the context
+		{NewCodec(registry, TopicNameStrategy{}, WithSchemaContext("tenant"), WithSubjectAliases(aliases)), "new.payments", false, ":.tenant:payments-value"},
+		{NewCodec(registry, TopicNameStrategy{}, WithSchemaContext("tenant"), WithSubjectAliases(map[string]string{":.tenant:new.payments-value": "payments-value"})), "new.payments", false, "payments-value"},
+	}
+	for _, test := range tests {
+		if got := test.codec.Subject(test.topic, test.isKey); got != test.want {
+			t.Errorf("Subject(%v, %v) returned %v, want %v", test.topic, test.isKey, got, test.want)
+		}
+	}
+
+	// the encodes use the schema of the alias, the decodes are not affected
+	data, err := codec.Encode("new.orders", false, value)
+	if err != nil || SchemaID(data[4]) != id {
+		t.Fatalf("Encode() with an alias returned %v, %v", data, err)
+	}
+	if _, err = codec.Decode("new.orders", false, data); err != nil {
+		t.Errorf("Decode() returned %v", err)
+	}
+
+	// the errors name the computed subject and the alias
+	_, err = codec.Encode("new.refunds", false, value)
+	if !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("Encode() with an alias of an unknown subject returned %v", err)
+	}
+	for _, want := range []string{"subject new.refunds-value of topic new.refunds, aliased to subject missing-value", "subject missing-value"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Encode() returned %q, want it to contain %q", err, want)
+		}
+	}
+	if _, err = codec.Encode("refunds", false, value); err == nil || strings.Contains(err.Error(), "aliased") {
+		t.Errorf("Encode() without an alias returned %v", err)
+	}
+}
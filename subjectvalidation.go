@@ -0,0 +1,48 @@
+package kafkaavro
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithSubjectValidation makes the Codec check the subjects before it calls the registry with
+// them: a subject with other characters than the ASCII letters and digits, '.', '_', '-' and ':'
+// (of the schema contexts, see WithSchemaContext) fails with ErrInvalidSubject, which names the
+// characters and the topic, instead of with a confusing error of the registry.
+func WithSubjectValidation(validate bool) Option {
+	return func(c *Codec) {
+		c.validateSubjects = validate
+	}
+}
+
+// checkSubject returns ErrInvalidSubject for a subject with other characters than the ones of
+// WithSubjectValidation, when the subjects are validated.
+func (c *Codec) checkSubject(subjectName SubjectName, topic string) error {
+
+	if !c.validateSubjects {
+		return nil
+	}
+	var invalid []string
+	seen := make(map[rune]bool)
+	for _, r := range subjectName {
+		if !validSubjectRune(r) && !seen[r] {
+			seen[r] = true
+			invalid = append(invalid, fmt.Sprintf("%q", r))
+		}
+	}
+	where := ""
+	if topic != "" {
+		where = fmt.Sprintf(" of topic %q", topic)
+	}
+	switch {
+	case subjectName == "":
+		return fmt.Errorf("%w: the subject%v is empty", ErrInvalidSubject, where)
+	case invalid != nil:
+		return fmt.Errorf("%w: subject %q%v contains %v", ErrInvalidSubject, subjectName, where, strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+func validSubjectRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("._-:", r)
+}
@@ -0,0 +1,54 @@
+package kafkaavro
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestWithSubjectValidation(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders.v1-value", `"long"`)
+	registry.Register(":.staging:orders_v2-value", `"long"`)
+	codec := NewCodec(registry, TopicNameStrategy{}, WithSubjectValidation(true))
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"orders.v1", ""},
+		{"orders/v1", `subject "orders/v1-value" of topic "orders/v1" contains '/'`},
+		{"orders v1 and v2", `contains ' '`},
+		{"orders%2Fv1", `contains '%'`},
+		{"bestellungen-für-köln", `contains 'ü', 'ö'`},
+	}
+	for _, test := range tests {
+		calls := len(registry.Calls())
+		_, err := codec.Encode(test.topic, false, int64(1))
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("Encode() to %v returned %v", test.topic, err)
+		case test.want != "" && (!errors.Is(err, ErrInvalidSubject) || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("Encode() to %v returned %v, want ErrInvalidSubject with %v", test.topic, err, test.want)
+		case test.want != "" && len(registry.Calls()) != calls:
+			t.Errorf("Encode() to %v called the registry with an invalid subject", test.topic)
+		}
+	}
+
+	// the subjects of a schema context are valid
+	staging := NewCodec(registry, TopicNameStrategy{}, WithSchemaContext("staging"), WithSubjectValidation(true))
+	if _, err := staging.Encode("orders_v2", false, int64(1)); err != nil {
+		t.Errorf("Encode() in a schema context returned %v", err)
+	}
+	if err := codec.ValidateJSON("orders v1-value", -1, []byte(`1`)); !errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("ValidateJSON() of an invalid subject returned %v", err)
+	}
+
+	// without the option the subjects are not validated
+	if _, err := NewCodec(registry, TopicNameStrategy{}).Encode("orders/v1", false, int64(1)); errors.Is(err, ErrInvalidSubject) {
+		t.Errorf("Encode() without validation returned %v", err)
+	}
+}
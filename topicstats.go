@@ -0,0 +1,164 @@
+package kafkaavro
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TopicStats counts the messages which a Codec decoded and encoded for a topic, keys and values
+// together, e.g. to tell whether a topic is flowing and healthy without a metrics backend. The
+// encodes without a topic, EncodeWithSchemaID and EncodeJSON, are not counted.
+type TopicStats struct {
+	Decoded        uint64
+	DecodeFailures uint64
+	Encoded        uint64
+	EncodeFailures uint64
+	// BytesIn is the size of the data of the decodes, BytesOut of the data which was encoded.
+	BytesIn  uint64
+	BytesOut uint64
+	// Failures counts the failed decodes and encodes by their ErrorType, "" for the errors which
+	// are not errors of this package.
+	Failures map[string]uint64
+	// LastSchemaID is the schema id of the last value decoded or encoded, 0 before the first one.
+	LastSchemaID SchemaID
+	// LastActivity is the time of the last decode or encode, failed or not.
+	LastActivity time.Time
+}
+
+// topicCounters holds the TopicStats of a topic, the counters are updated without locks.
+type topicCounters struct {
+	decoded, decodeFailures, encoded, encodeFailures atomic.Uint64
+	bytesIn, bytesOut                                atomic.Uint64
+	lastSchemaID                                     atomic.Int64
+	lastActivity                                     atomic.Int64 // in unix nanoseconds
+	failures                                         copyOnWriteMap[string, *atomic.Uint64]
+}
+
+func (c *Codec) topicCountersOf(topic string) *topicCounters {
+	if t, found := c.topicStats.get(topic); found {
+		return t
+	}
+	c.topicStats.add(topic, &topicCounters{})
+	t, _ := c.topicStats.get(topic)
+	return t
+}
+
+// topicDecoded counts a decode of the data of the topic.
+func (c *Codec) topicDecoded(topic string, isKey bool, schemaID SchemaID, data []byte, err error) {
+	t := c.topicCountersOf(topic)
+	t.bytesIn.Add(uint64(len(data)))
+	if err != nil {
+		t.decodeFailures.Add(1)
+	} else {
+		t.decoded.Add(1)
+	}
+	c.topicActivity(t, isKey, schemaID, err)
+}
+
+// topicEncoded counts an encode of a value of the topic to the data.
+func (c *Codec) topicEncoded(topic string, isKey bool, schemaID SchemaID, data []byte, err error) {
+	t := c.topicCountersOf(topic)
+	if err != nil {
+		t.encodeFailures.Add(1)
+	} else {
+		t.encoded.Add(1)
+		t.bytesOut.Add(uint64(len(data)))
+	}
+	c.topicActivity(t, isKey, schemaID, err)
+}
+
+func (c *Codec) topicActivity(t *topicCounters, isKey bool, schemaID SchemaID, err error) {
+
+	t.lastActivity.Store(c.clock.Now().UnixNano())
+	if err == nil && !isKey && schemaID > 0 {
+		t.lastSchemaID.Store(int64(schemaID))
+	}
+	if err == nil {
+		return
+	}
+
+	errorType := ErrorType(err)
+	failures, found := t.failures.get(errorType)
+	if !found {
+		t.failures.add(errorType, &atomic.Uint64{})
+		failures, _ = t.failures.get(errorType)
+	}
+	failures.Add(1)
+}
+
+func (t *topicCounters) stats() (stats TopicStats) {
+
+	stats = TopicStats{
+		Decoded:        t.decoded.Load(),
+		DecodeFailures: t.decodeFailures.Load(),
+		Encoded:        t.encoded.Load(),
+		EncodeFailures: t.encodeFailures.Load(),
+		BytesIn:        t.bytesIn.Load(),
+		BytesOut:       t.bytesOut.Load(),
+		LastSchemaID:   SchemaID(t.lastSchemaID.Load()),
+	}
+	if nanos := t.lastActivity.Load(); nanos != 0 {
+		stats.LastActivity = time.Unix(0, nanos)
+	}
+	t.failures.each(func(errorType string, count *atomic.Uint64) {
+		if stats.Failures == nil {
+			stats.Failures = make(map[string]uint64)
+		}
+		stats.Failures[errorType] = count.Load()
+	})
+	return
+}
+
+// add adds the stats of a topic of another registry of WithRegistryRouter.
+func (s *TopicStats) add(other TopicStats) {
+
+	s.Decoded += other.Decoded
+	s.DecodeFailures += other.DecodeFailures
+	s.Encoded += other.Encoded
+	s.EncodeFailures += other.EncodeFailures
+	s.BytesIn += other.BytesIn
+	s.BytesOut += other.BytesOut
+	for errorType, count := range other.Failures {
+		if s.Failures == nil {
+			s.Failures = make(map[string]uint64)
+		}
+		s.Failures[errorType] += count
+	}
+	if other.LastActivity.After(s.LastActivity) {
+		s.LastActivity = other.LastActivity
+		if other.LastSchemaID > 0 {
+			s.LastSchemaID = other.LastSchemaID
+		}
+	}
+}
+
+// TopicStats returns the stats of the topic, the zero TopicStats for a topic which the Codec did
+// not decode or encode.
+func (c *Codec) TopicStats(topic string) (stats TopicStats) {
+	c.eachRegistry(func(codec *Codec) {
+		if t, found := codec.topicStats.get(topic); found {
+			stats.add(t.stats())
+		}
+	})
+	return
+}
+
+// AllTopicStats returns the stats of every topic which the Codec decoded or encoded.
+func (c *Codec) AllTopicStats() map[string]TopicStats {
+	all := make(map[string]TopicStats)
+	c.eachRegistry(func(codec *Codec) {
+		codec.topicStats.each(func(topic string, t *topicCounters) {
+			stats := all[topic]
+			stats.add(t.stats())
+			all[topic] = stats
+		})
+	})
+	return all
+}
+
+// ResetTopicStats drops the stats of all topics, e.g. between tests.
+func (c *Codec) ResetTopicStats() {
+	c.eachRegistry(func(codec *Codec) {
+		codec.topicStats.clear()
+	})
+}
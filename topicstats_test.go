@@ -0,0 +1,89 @@
+package kafkaavro
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+func TestTopicStats(t *testing.T) {
+
+	registry := mockregistry.New()
+	schemaID := registry.Register("orders-value", ocfSchema)
+	keyID := registry.Register("orders-key", `"string"`)
+
+	now := time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC)
+	clock := clocktest.New(now)
+	codec := NewCodec(registry, TopicNameStrategy{}, WithClock(clock))
+
+	if stats := codec.TopicStats("orders"); !reflect.DeepEqual(stats, TopicStats{}) {
+		t.Errorf("TopicStats() before the first message returned %+v", stats)
+	}
+
+	value, err := codec.Encode("orders", false, order(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	key, err := codec.Encode("orders", true, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = codec.Encode("orders", false, "not an order"); err == nil {
+		t.Fatal("Encode() of an invalid value succeeded")
+	}
+	for _, data := range [][]byte{value, key, {1, 2, 3, 4, 5, 6}} {
+		codec.Decode("orders", data[0] == 0 && getSchemaID(data[1:]) == keyID, data)
+	}
+	codec.Decode("payments", false, []byte{0, 0, 0, 0, 42})
+
+	want := TopicStats{
+		Encoded: 2, EncodeFailures: 1, Decoded: 2, DecodeFailures: 1,
+		BytesIn: uint64(len(value) + len(key) + 6), BytesOut: uint64(len(value) + len(key)),
+		Failures:     map[string]uint64{"": 1, "ErrUnknownMagicByte": 1},
+		LastSchemaID: schemaID, LastActivity: now.Add(time.Second),
+	}
+	stats := codec.TopicStats("orders")
+	if !stats.LastActivity.Equal(want.LastActivity) {
+		t.Errorf("TopicStats().LastActivity = %v, want %v", stats.LastActivity, want.LastActivity)
+	}
+	stats.LastActivity = want.LastActivity
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("TopicStats() returned %+v, want %+v", stats, want)
+	}
+
+	all := codec.AllTopicStats()
+	if len(all) != 2 || all["payments"].DecodeFailures != 1 || all["payments"].Failures["ErrSchemaNotFound"] != 1 || all["orders"].Decoded != 2 {
+		t.Errorf("AllTopicStats() returned %+v", all)
+	}
+
+	codec.ResetTopicStats()
+	if all = codec.AllTopicStats(); len(all) != 0 {
+		t.Errorf("AllTopicStats() after ResetTopicStats() returned %+v", all)
+	}
+}
+
+func TestTopicStatsOfRegistries(t *testing.T) {
+
+	eu, us := mockregistry.NewNamed("eu"), mockregistry.NewNamed("us")
+	eu.Register("orders-value", ocfSchema)
+	us.Register("us.orders-value", ocfSchema)
+
+	codec := NewCodec(eu, TopicNameStrategy{}, WithRegistryRouter(func(topic string, isKey bool) RegistryClient {
+		if topic == "us.orders" {
+			return us
+		}
+		return eu
+	}))
+	for _, topic := range []string{"orders", "us.orders"} {
+		if _, err := codec.Encode(topic, false, order(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := codec.AllTopicStats(); stats["orders"].Encoded != 1 || stats["us.orders"].Encoded != 1 {
+		t.Errorf("AllTopicStats() returned %+v", stats)
+	}
+}
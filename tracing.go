@@ -0,0 +1,43 @@
+package kafkaavro
+
+import "context"
+
+// The names of the spans started by a Codec.
+const (
+	SpanDecode          = "kafkaavro.decode"
+	SpanEncode          = "kafkaavro.encode"
+	SpanGetSchemaByID   = "kafkaavro.registry." + OperationGetSchemaByID
+	SpanGetLatestSchema = "kafkaavro.registry." + OperationGetLatestSchema
+)
+
+// The attributes of the spans started by a Codec.
+const (
+	AttributeTopic       = "kafkaavro.topic"
+	AttributeSubject     = "kafkaavro.subject"
+	AttributeSchemaID    = "kafkaavro.schema_id"
+	AttributePayloadSize = "kafkaavro.payload_size"
+	AttributeCacheHit    = "kafkaavro.cache_hit"
+)
+
+// Tracer starts the spans around the operations of a Codec, see the kafkaavrootel package for
+// OpenTelemetry. Only DecodeContext, EncodeContext and WarmUp pass the context of the caller, the
+// other operations start their spans from an empty context.
+type Tracer interface {
+	// Start starts a span as a child of the span in the context, if any.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute records an attribute of the span, the value is a string, an int or a bool.
+	SetAttribute(key string, value interface{})
+	// End ends the span with the error of the operation (nil on success).
+	End(err error)
+}
+
+// WithTracer traces the decodes, encodes and schema registry requests of the Codec.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Codec) {
+		c.tracer = tracer
+	}
+}
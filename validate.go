@@ -0,0 +1,277 @@
+package kafkaavro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/timvw/kafkaavro/internal/avroschema"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// ValidateJSON checks that the document, in the avro JSON encoding, is a value of the schema of
+// the version of the subject, or of its latest schema for version -1. A document which validates
+// against the latest schema encodes with Encode: it is converted with the NativeFromTextual of
+// goavro, with the conversions of the Codec (see WithLogicalTypes and WithEnums) and encoded.
+//
+// A document which does not validate fails with a *FieldError wrapping ErrSchemaValidation, the
+// Path of which is the path of the first invalid value, e.g. lines[2].quantity. Anything but
+// whitespace after the document fails as well.
+func (c *Codec) ValidateJSON(subject string, version int, doc []byte) (err error) {
+
+	if r := c.registryCodec(nil); r != c {
+		return r.registryError(r.ValidateJSON(subject, version, doc))
+	}
+
+	subject = ContextSubject(c.schemaContext, subject)
+	if err = c.checkSubject(subject, ""); err != nil {
+		return
+	}
+	if c.offline {
+		return notCached("the schema of subject "+subject, "")
+	}
+	var schema schemaregistry.Schema
+	if version < 0 {
+		if schema, err = c.client.GetLatestSchema(subject); err != nil {
+			return fmt.Errorf("failed to fetch the latest schema of subject %v: %w", subject, err)
+		}
+	} else {
+		fetcher, ok := c.client.(subjectVersionFetcher)
+		if !ok {
+			return fmt.Errorf("the registry client does not fetch version %d of subject %v", version, subject)
+		}
+		if schema, err = fetcher.GetSchemaBySubject(subject, version); err != nil {
+			return fmt.Errorf("failed to fetch version %d of subject %v: %w", version, subject, err)
+		}
+	}
+	if err = c.checkSchemaSize(schema.Schema, "the schema of subject "+subject); err != nil {
+		return
+	}
+	if schema.Type() != schemaregistry.SchemaTypeAvro {
+		return fmt.Errorf("%w: subject %v has a %v schema", ErrUnsupportedSchemaType, subject, schema.Type())
+	}
+
+	codec, err := c.newGoavroCodec(schema.Schema)
+	if err != nil {
+		return fmt.Errorf("%w of schema %d: %w", ErrCodecBuild, schema.ID, err)
+	}
+
+	native, remainder, err := codec.NativeFromTextual(doc)
+	if err != nil {
+		// goavro does not tell where, look for the first invalid value
+		return invalidJSON(schema.Schema, doc, err)
+	}
+	if trailing := bytes.TrimSpace(remainder); len(trailing) > 0 {
+		return invalid(fmt.Errorf("the document is followed by %q", trailing[:min(len(trailing), 20)]))
+	}
+	if c.converting() {
+		if native, err = c.toAvro(codec, native); err != nil {
+			return invalid(err)
+		}
+	}
+	if _, err = codec.BinaryFromNative(nil, native); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+// invalid wraps the error in ErrSchemaValidation, keeping the path of a *FieldError.
+func invalid(err error) error {
+	if fieldErr, ok := err.(*FieldError); ok {
+		return &FieldError{Path: fieldErr.Path, Err: fmt.Errorf("%w: %w", ErrSchemaValidation, fieldErr.Err)}
+	}
+	return &FieldError{Err: fmt.Errorf("%w: %w", ErrSchemaValidation, err)}
+}
+
+// invalidJSON returns the error of the first value of the document which is not a value of the
+// schema, or the error of goavro if none is found.
+func invalidJSON(schema AvroSchema, doc []byte, goavroErr error) error {
+
+	n, err := avroschema.Parse(schema)
+	if err != nil {
+		return invalid(goavroErr)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	var value interface{}
+	if err = decoder.Decode(&value); err != nil {
+		return invalid(fmt.Errorf("invalid JSON: %w", err))
+	}
+	if fieldErr := checkTextual(n, value, ""); fieldErr != nil {
+		return invalid(fieldErr)
+	}
+	return invalid(goavroErr)
+}
+
+// checkTextual returns the error of the first value which is not a value of the schema in the
+// avro JSON encoding.
+func checkTextual(n *avroschema.Node, value interface{}, path string) *FieldError {
+
+	mismatch := func(format string, args ...interface{}) *FieldError {
+		return &FieldError{Path: path, Err: fmt.Errorf(format, args...)}
+	}
+
+	switch n.Type {
+
+	case "null":
+		if value != nil {
+			return mismatch("expected null, got %v", describeJSON(value))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch("expected a boolean, got %v", describeJSON(value))
+		}
+
+	case "int", "long":
+		number, ok := value.(json.Number)
+		if !ok {
+			return mismatch("expected an %v, got %v", n.Type, describeJSON(value))
+		}
+		i, err := strconv.ParseInt(number.String(), 10, 64)
+		if err != nil || n.Type == "int" && (i < math.MinInt32 || i > math.MaxInt32) {
+			return mismatch("%v is not an %v", number, n.Type)
+		}
+
+	case "float", "double":
+		if _, ok := value.(json.Number); !ok {
+			return mismatch("expected a %v, got %v", n.Type, describeJSON(value))
+		}
+
+	case "string":
+		if _, ok := value.(string); !ok {
+			return mismatch("expected a string, got %v", describeJSON(value))
+		}
+
+	case "bytes", "fixed":
+		s, ok := value.(string)
+		if !ok {
+			return mismatch("expected a string of the code points of the bytes, got %v", describeJSON(value))
+		}
+		for _, r := range s {
+			if r > 255 {
+				return mismatch("the code point %U is not a byte", r)
+			}
+		}
+		if size := utf8.RuneCountInString(s); n.Type == "fixed" && size != n.Size {
+			return mismatch("expected %d bytes, got %d", n.Size, size)
+		}
+
+	case "enum":
+		s, ok := value.(string)
+		if !ok {
+			return mismatch("expected a symbol of %v, got %v", n.Symbols, describeJSON(value))
+		}
+		if !hasSymbol(n, s) {
+			return mismatch("%q is not one of the symbols %v", s, n.Symbols)
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch("expected an array, got %v", describeJSON(value))
+		}
+		for i, item := range items {
+			if err := checkTextual(n.Items, item, indexPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch("expected a map, got %v", describeJSON(value))
+		}
+		for _, k := range sortedKeys(values) {
+			if err := checkTextual(n.Values, values[k], indexPath(path, k)); err != nil {
+				return err
+			}
+		}
+
+	case "record":
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch("expected a %v, got %v", n.Name, describeJSON(value))
+		}
+		known := make(map[string]bool, len(n.Fields))
+		for _, f := range n.Fields {
+			known[f.Name] = true
+			v, found := record[f.Name]
+			if !found {
+				if !f.HasDefault {
+					return &FieldError{Path: fieldPath(path, f.Name), Err: fmt.Errorf("missing, the field has no default")}
+				}
+				continue
+			}
+			if err := checkTextual(f.Node, v, fieldPath(path, f.Name)); err != nil {
+				return err
+			}
+		}
+		for _, k := range sortedKeys(record) {
+			if !known[k] {
+				return &FieldError{Path: fieldPath(path, k), Err: fmt.Errorf("not a field of %v", n.Name)}
+			}
+		}
+
+	case "union":
+		return checkTextualUnion(n, value, path)
+	}
+	return nil
+}
+
+func checkTextualUnion(n *avroschema.Node, value interface{}, path string) *FieldError {
+
+	names := make([]string, 0, len(n.Branches))
+	for _, b := range n.Branches {
+		if b.Type == "null" {
+			if value == nil {
+				return nil
+			}
+			continue
+		}
+		names = append(names, b.UnionName())
+	}
+
+	wrapped, ok := value.(map[string]interface{})
+	if ok && len(wrapped) == 1 {
+		for name, v := range wrapped {
+			for _, b := range n.Branches {
+				if b.Type != "null" && name == b.UnionName() {
+					return checkTextual(b, v, path)
+				}
+			}
+			return &FieldError{Path: path, Err: fmt.Errorf("%q is not one of the branches %v of the union", name, names)}
+		}
+	}
+	return &FieldError{Path: path, Err: fmt.Errorf("expected a value of the union as {\"<branch>\": value} with a branch of %v, got %v", names, describeJSON(value))}
+}
+
+// describeJSON returns the JSON type of a decoded value.
+func describeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "the number " + v.String()
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		return "an array"
+	}
+	return "an object"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package kafkaavro
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/mockregistry"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+const validateSchema = `{"type":"record","name":"Order","namespace":"com.example","fields":[
+	{"name":"id","type":{"type":"string","logicalType":"uuid"}},
+	{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]},"default":"OPEN"},
+	{"name":"checksum","type":["null",{"type":"fixed","name":"Checksum","size":2}],"default":null},
+	{"name":"placed","type":["null",{"type":"long","logicalType":"timestamp-millis"}],"default":null},
+	{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[
+		{"name":"product","type":"string"},
+		{"name":"quantity","type":"int"},
+		{"name":"attributes","type":{"type":"map","values":"boolean"},"default":{}}]}}}]}`
+
+func TestValidateJSON(t *testing.T) {
+
+	codec := newLogicalCodec(t, validateSchema)
+	textual, err := goavro.NewCodec(validateSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		doc  string
+		path string
+	}{
+		{`{"id":"b6c2d21a-7d4e-4c2c-9c2c-0d2d3f0c3e8a","lines":[]}`, ""},
+		{`{"id":"x","status":"CLOSED","checksum":{"com.example.Checksum":"ab"},"placed":{"long.timestamp-millis":1},
+			"lines":[{"product":"p","quantity":1,"attributes":{"gift":true}}]}`, ""},
+		{`{"lines":[]}`, "id"},
+		{`{"id":"x","lines":[],"note":"a"}`, "note"},
+		{`{"id":1,"lines":[]}`, "id"},
+		{`{"id":"x","status":"LOST","lines":[]}`, "status"},
+		{`{"id":"x","checksum":"ab","lines":[]}`, "checksum"},
+		{`{"id":"x","checksum":{"Checksum":"ab"},"lines":[]}`, "checksum"},
+		{`{"id":"x","checksum":{"com.example.Checksum":"abc"},"lines":[]}`, "checksum"},
+		{`{"id":"x","placed":{"long":1},"lines":[]}`, "placed"},
+		{`{"id":"x","lines":[{"product":"p","quantity":1},{"product":"p","quantity":1.5}]}`, "lines[1].quantity"},
+		{`{"id":"x","lines":[{"product":"p","quantity":2147483648}]}`, "lines[0].quantity"},
+		{`{"id":"x","lines":[{"product":"p","quantity":1,"attributes":{"gift":"yes"}}]}`, "lines[0].attributes[gift]"},
+		{`{"id":"x","lines":{}}`, "lines"},
+	}
+
+	for _, test := range tests {
+		err := codec.ValidateJSON("orders-value", -1, []byte(test.doc))
+		if test.path == "" {
+			if err != nil {
+				t.Errorf("ValidateJSON(%v) returned %v", test.doc, err)
+				continue
+			}
+			// the documents which validate encode
+			native, _, err := textual.NativeFromTextual([]byte(test.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = codec.Encode("orders", false, native); err != nil {
+				t.Errorf("Encode() of %v returned %v", test.doc, err)
+			}
+			continue
+		}
+		var fieldErr *FieldError
+		if !errors.Is(err, ErrSchemaValidation) || !errors.As(err, &fieldErr) || fieldErr.Path != test.path {
+			t.Errorf("ValidateJSON(%v) returned %v, want an error of %v", test.doc, err, test.path)
+		}
+	}
+
+	if err := codec.ValidateJSON("orders-value", -1, []byte(`{"id":`)); !errors.Is(err, ErrSchemaValidation) {
+		t.Errorf("ValidateJSON() of invalid JSON returned %v", err)
+	}
+	// the whitespace after the document is fine, anything else is not
+	if err := codec.ValidateJSON("orders-value", -1, []byte("{\"id\":\"x\",\"lines\":[]}\n ")); err != nil {
+		t.Errorf("ValidateJSON() of a document followed by whitespace returned %v", err)
+	}
+	if err := codec.ValidateJSON("orders-value", -1, []byte(`{"id":"x","lines":[]} garbage`)); !errors.Is(err, ErrSchemaValidation) {
+		t.Errorf("ValidateJSON() of a document followed by garbage returned %v", err)
+	}
+}
+
+func TestValidateJSONConversions(t *testing.T) {
+
+	// with WithLogicalTypes the uuid is validated on encode, and so on validation
+	codec := newLogicalCodec(t, validateSchema, WithLogicalTypes())
+	err := codec.ValidateJSON("orders-value", -1, []byte(`{"id":"x","lines":[]}`))
+	var fieldErr *FieldError
+	if !errors.Is(err, ErrSchemaValidation) || !errors.Is(err, ErrInvalidUUID) || !errors.As(err, &fieldErr) || fieldErr.Path != "id" {
+		t.Errorf("ValidateJSON() of an invalid uuid returned %v", err)
+	}
+}
+
+func TestValidateJSONVersions(t *testing.T) {
+
+	registry := mockregistry.New()
+	registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"}]}`)
+	registry.Register("orders-value", `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"}]}`)
+	registry.RegisterSchema("orders-proto", schemaregistry.Schema{Schema: `syntax = "proto3";`, SchemaType: schemaregistry.SchemaTypeProtobuf})
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	doc := []byte(`{"id":1}`)
+	if err := codec.ValidateJSON("orders-value", 1, doc); err != nil {
+		t.Errorf("ValidateJSON() of version 1 returned %v", err)
+	}
+	if err := codec.ValidateJSON("orders-value", -1, doc); !errors.Is(err, ErrSchemaValidation) {
+		t.Errorf("ValidateJSON() of the latest version returned %v", err)
+	}
+	if err := codec.ValidateJSON("orders-value", 3, doc); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("ValidateJSON() of a missing version returned %v", err)
+	}
+	if err := codec.ValidateJSON("orders-proto", -1, doc); !errors.Is(err, ErrUnsupportedSchemaType) {
+		t.Errorf("ValidateJSON() of a protobuf schema returned %v", err)
+	}
+}
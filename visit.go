@@ -0,0 +1,509 @@
+package kafkaavro
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/timvw/kafkaavro/internal/avroschema"
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// VisitAction is what DecodeVisit does with a value, see FieldVisitor.Enter.
+type VisitAction int
+
+const (
+	// VisitChildren visits the fields of a record, the items of an array, the values of a map or the
+	// branch of a union, and decodes a value of another type and passes it to FieldVisitor.Value.
+	VisitChildren VisitAction = iota
+	// VisitValue decodes the value as Decode does, e.g. the whole array, and passes it to
+	// FieldVisitor.Value.
+	VisitValue
+	// VisitSkip skips the value without decoding it.
+	VisitSkip
+)
+
+// FieldVisitor receives the values of DecodeVisit, depth first in the order of the data.
+type FieldVisitor interface {
+	// Enter returns what to do with the value at the path, of the avro type typ: a primitive type,
+	// record, enum, array, map, fixed or union. The branch of a union is at the path of the union.
+	Enter(path *VisitPath, typ string) VisitAction
+	// Value receives the value at the path, an error stops the decode.
+	Value(path *VisitPath, value interface{}) error
+	// Leave is called after the children of a record, array, map or union visited with
+	// VisitChildren, an error stops the decode.
+	Leave(path *VisitPath) error
+}
+
+// VisitPath is the path of a value of DecodeVisit. DecodeVisit reuses the path of the items of an
+// array and the fields of a record after the calls of the visitor, so that it does not allocate a
+// path for every item, call String to keep it.
+type VisitPath struct {
+	parent *VisitPath
+	kind   byte // of the item, 'f' for a field, 'i' for an array item and 'k' for a map value
+	name   string
+	index  int
+}
+
+// String returns the path of the value as in a FieldError, e.g. "items[2].tags[color]", the top
+// level value is at "".
+func (p *VisitPath) String() string {
+	switch p.kind {
+	case 'f':
+		return fieldPath(p.parent.String(), p.name)
+	case 'i':
+		return indexPath(p.parent.String(), strconv.Itoa(p.index))
+	case 'k':
+		return indexPath(p.parent.String(), p.name)
+	}
+	return ""
+}
+
+// Parent returns the path of the record, array or map of the value, nil for the top level value.
+func (p *VisitPath) Parent() *VisitPath {
+	return p.parent
+}
+
+// Field returns the name of the field of a record, "" for another value.
+func (p *VisitPath) Field() string {
+	if p.kind == 'f' {
+		return p.name
+	}
+	return ""
+}
+
+// Index returns the index of an item of an array, -1 for another value.
+func (p *VisitPath) Index() int {
+	if p.kind == 'i' {
+		return p.index
+	}
+	return -1
+}
+
+// Key returns the key of a value of a map, and false for another value.
+func (p *VisitPath) Key() (key string, ok bool) {
+	return p.name, p.kind == 'k'
+}
+
+// DecodeVisit decodes the avro data in the wire format and streams its values to the visitor rather
+// than materializing them: a consumer which only counts the items of a large array skips them
+// without allocating them, or an item at a time. The values passed to the visitor are those of Decode, with the logical
+// types and enums of WithLogicalTypes and WithEnums, except that the value of the branch of a union
+// visited with VisitChildren is not wrapped in a map of the name of the branch.
+//
+// An error of the visitor stops the decode and is returned without wrapping it in a DecodeError, the
+// other errors are those of Decode, and a schema type other than avro fails with
+// ErrUnsupportedSchemaType.
+func (c *Codec) DecodeVisit(topic string, isKey bool, data []byte, visitor FieldVisitor) (err error) {
+
+	if r := c.route(topic, isKey); r != c {
+		return r.registryError(r.DecodeVisit(topic, isKey, data, visitor))
+	}
+
+	var schemaID SchemaID
+	w := &visitWalk{visitor: visitor, maxItems: c.maxCollectionSize}
+	defer func() {
+		if err != nil && w.visitorErr == nil {
+			err = c.decodeFailed(topic, schemaID, data, err)
+		}
+		c.topicDecoded(topic, isKey, schemaID, data, err)
+		if c.metrics != nil {
+			c.metrics.Decoded(err)
+		}
+	}()
+
+	if err = c.checkPayloadSize(data); err != nil {
+		return
+	}
+	schemaID, codec, _, err := c.codecFor(context.Background(), topic, data)
+	if err != nil {
+		return
+	}
+	if w.schema, err = c.logicalSchemaOf(codec); err != nil {
+		return
+	}
+	w.data = data[headerSize:]
+	if err = w.visit(w.schema.root, &VisitPath{}); err != nil {
+		return
+	}
+	c.observe(topic, isKey, schemaID, codec.Schema(), schemaregistry.SchemaTypeAvro)
+	return
+}
+
+// visitWalk reads the avro binary encoding of the schema, see the avro specification.
+type visitWalk struct {
+	schema     *logicalSchema
+	visitor    FieldVisitor
+	data       []byte
+	visitorErr error
+	// the items of the arrays and maps read so far, and their maximum, see WithMaxCollectionSize
+	items, maxItems int64
+}
+
+// visited returns the error of the visitor, and records it to return it as is.
+func (w *visitWalk) visited(err error) error {
+	if err != nil {
+		w.visitorErr = err
+	}
+	return err
+}
+
+func (w *visitWalk) visit(n *avroschema.Node, path *VisitPath) (err error) {
+
+	action := w.visitor.Enter(path, n.Type)
+	switch {
+	case action == VisitSkip:
+		return w.skip(n)
+	case action == VisitValue:
+		value, err := w.value(n, path)
+		if err != nil {
+			return err
+		}
+		return w.visited(w.visitor.Value(path, value))
+	}
+
+	switch n.Type {
+	case "record":
+		child := &VisitPath{parent: path, kind: 'f'}
+		for _, f := range n.Fields {
+			child.name = f.Name
+			if err = w.visit(f.Node, child); err != nil {
+				return
+			}
+		}
+	case "array":
+		child := &VisitPath{parent: path, kind: 'i', index: -1}
+		err = w.blocks(false, func() error {
+			child.index++
+			return w.visit(n.Items, child)
+		})
+	case "map":
+		child := &VisitPath{parent: path, kind: 'k'}
+		err = w.blocks(false, func() (err error) {
+			if child.name, err = w.readString(); err != nil {
+				return err
+			}
+			return w.visit(n.Values, child)
+		})
+	case "union":
+		var b *avroschema.Node
+		if b, err = w.readBranch(n); err == nil {
+			err = w.visit(b, path)
+		}
+	default:
+		value, err := w.value(n, path)
+		if err != nil {
+			return err
+		}
+		return w.visited(w.visitor.Value(path, value))
+	}
+	if err != nil {
+		return
+	}
+	return w.visited(w.visitor.Leave(path))
+}
+
+// value decodes the value of the type and converts it, goavro decodes the records, arrays, maps,
+// unions and logical types.
+func (w *visitWalk) value(n *avroschema.Node, path *VisitPath) (value interface{}, err error) {
+
+	if avroschema.IsPrimitive(n.Type) || n.Type == "enum" || n.Type == "fixed" {
+		if !n.GoavroLogical() {
+			if value, err = w.readPrimitive(n); err != nil {
+				return
+			}
+			return w.convert(n, value, path)
+		}
+	}
+
+	codec, err := w.schema.codecOf(n)
+	if err != nil {
+		return
+	}
+	data := w.data
+	if err = w.skip(n); err != nil {
+		return
+	}
+	if value, err = decodeBody(codec, data[:len(data)-len(w.data)]); err != nil {
+		return
+	}
+	return w.convert(n, value, path)
+}
+
+// convert converts the logical types and enums of the value, the path is only built for the
+// values which convert.
+func (w *visitWalk) convert(n *avroschema.Node, value interface{}, path *VisitPath) (interface{}, error) {
+	if !w.schema.converts[n] {
+		return value, nil
+	}
+	return w.schema.decode(n, value, path.String())
+}
+
+// readPrimitive decodes a primitive type, an enum or a fixed to the native value of goavro.
+func (w *visitWalk) readPrimitive(n *avroschema.Node) (value interface{}, err error) {
+
+	switch n.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		var b []byte
+		if b, err = w.read(1); err != nil {
+			return
+		}
+		if b[0] > 1 {
+			return nil, fmt.Errorf("%w: invalid boolean %d", ErrMalformedPayload, b[0])
+		}
+		return b[0] == 1, nil
+	case "int":
+		var i int64
+		if i, err = w.readLong(); err == nil && (i < math.MinInt32 || i > math.MaxInt32) {
+			err = fmt.Errorf("%w: int %d out of range", ErrMalformedPayload, i)
+		}
+		return int32(i), err
+	case "long":
+		return w.readLong()
+	case "float":
+		var b []byte
+		if b, err = w.read(4); err != nil {
+			return
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		var b []byte
+		if b, err = w.read(8); err != nil {
+			return
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		var b []byte
+		if b, err = w.readBytes(); err != nil {
+			return
+		}
+		return append([]byte{}, b...), nil
+	case "string":
+		return w.readString()
+	case "enum":
+		var i int64
+		if i, err = w.readLong(); err != nil {
+			return
+		}
+		if i < 0 || i >= int64(len(n.Symbols)) {
+			return nil, fmt.Errorf("%w: symbol %d of enum %v out of range", ErrMalformedPayload, i, n.Name)
+		}
+		return n.Symbols[i], nil
+	case "fixed":
+		var b []byte
+		if b, err = w.read(n.Size); err != nil {
+			return
+		}
+		return append([]byte{}, b...), nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %v", ErrCodecBuild, n.Type)
+}
+
+// skip reads past a value of the type.
+func (w *visitWalk) skip(n *avroschema.Node) (err error) {
+
+	switch n.Type {
+	case "null":
+	case "boolean":
+		_, err = w.read(1)
+	case "int", "long", "enum":
+		_, err = w.readLong()
+	case "float":
+		_, err = w.read(4)
+	case "double":
+		_, err = w.read(8)
+	case "bytes", "string":
+		_, err = w.readBytes()
+	case "fixed":
+		_, err = w.read(n.Size)
+	case "record":
+		for _, f := range n.Fields {
+			if err = w.skip(f.Node); err != nil {
+				return
+			}
+		}
+	case "array":
+		err = w.blocks(w.maxItems <= 0, func() error {
+			return w.skip(n.Items)
+		})
+	case "map":
+		err = w.blocks(w.maxItems <= 0, func() error {
+			if _, err := w.readBytes(); err != nil {
+				return err
+			}
+			return w.skip(n.Values)
+		})
+	case "union":
+		var b *avroschema.Node
+		if b, err = w.readBranch(n); err == nil {
+			err = w.skip(b)
+		}
+	default:
+		err = fmt.Errorf("%w: unknown type %v", ErrCodecBuild, n.Type)
+	}
+	return
+}
+
+// blocks reads the blocks of an array or map and calls item for every item, or skips the blocks of
+// which the writer wrote the size by their size. Without a maximum of items the skipped blocks are
+// not counted, with one they are not skipped: the items of their nested arrays and maps count.
+func (w *visitWalk) blocks(skip bool, item func() error) error {
+
+	for {
+		count, err := w.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			size, err := w.readLong()
+			if err != nil {
+				return err
+			}
+			if skip && size > 0 {
+				if _, err = w.read(int(min(size, int64(len(w.data)+1)))); err != nil {
+					return err
+				}
+				continue
+			}
+			count = -count
+		}
+		if count > goavro.MaxBlockCount || count < 0 {
+			return fmt.Errorf("%w: block of %d items exceeds MaxBlockCount %d", ErrPayloadTooLarge, count, goavro.MaxBlockCount)
+		}
+		if w.maxItems > 0 {
+			if count > w.maxItems-w.items {
+				return fmt.Errorf("%w: the arrays and maps hold more than the maximum of %d items", ErrPayloadTooLarge, w.maxItems)
+			}
+			w.items += count
+		}
+		for ; count > 0; count-- {
+			if err = item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readBranch reads the index of the branch of a union.
+func (w *visitWalk) readBranch(n *avroschema.Node) (*avroschema.Node, error) {
+	i, err := w.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= int64(len(n.Branches)) {
+		return nil, fmt.Errorf("%w: branch %d of a union of %d branches", ErrMalformedPayload, i, len(n.Branches))
+	}
+	return n.Branches[i], nil
+}
+
+// readLong reads a zigzag encoded variable length integer.
+func (w *visitWalk) readLong() (int64, error) {
+	u, size := binary.Uvarint(w.data)
+	if size <= 0 {
+		return 0, fmt.Errorf("%w: invalid or truncated long", ErrMalformedPayload)
+	}
+	w.data = w.data[size:]
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func (w *visitWalk) readBytes() ([]byte, error) {
+	size, err := w.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || size > int64(len(w.data)) {
+		return nil, fmt.Errorf("%w: %d bytes but %d bytes remain", ErrMalformedPayload, size, len(w.data))
+	}
+	return w.read(int(size))
+}
+
+func (w *visitWalk) readString() (string, error) {
+	b, err := w.readBytes()
+	return string(b), err
+}
+
+func (w *visitWalk) read(size int) (b []byte, err error) {
+	if size > len(w.data) {
+		return nil, fmt.Errorf("%w: %d bytes but %d bytes remain", ErrMalformedPayload, size, len(w.data))
+	}
+	b, w.data = w.data[:size], w.data[size:]
+	return
+}
+
+// codecOf returns the goavro codec of the values of a type of the schema, which decodes the values
+// visited with VisitValue.
+func (s *logicalSchema) codecOf(n *avroschema.Node) (codec *goavro.Codec, err error) {
+
+	if codec, found := s.codecs.get(n); found {
+		return codec, nil
+	}
+	document, err := json.Marshal(schemaDocument(n, make(map[*avroschema.Node]bool)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	if codec, err = goavro.NewCodec(string(document)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCodecBuild, err)
+	}
+	s.codecs.put(n, codec)
+	return
+}
+
+// schemaDocument returns the JSON document of the schema of the type, the named types are defined at
+// their first use.
+func schemaDocument(n *avroschema.Node, defined map[*avroschema.Node]bool) interface{} {
+
+	if n.Name != "" && defined[n] {
+		return n.Name
+	}
+	if avroschema.IsPrimitive(n.Type) && n.Logical == "" {
+		return n.Type
+	}
+
+	document := map[string]interface{}{"type": n.Type}
+	if n.Logical != "" {
+		document["logicalType"] = n.Logical
+	}
+	if n.Logical == "decimal" {
+		document["precision"], document["scale"] = n.Precision, n.Scale
+	}
+	if n.Name != "" {
+		defined[n] = true
+		document["name"] = n.Name
+		if unqualified(n.Name) == n.Name {
+			document["namespace"] = ""
+		}
+	}
+
+	switch n.Type {
+	case "record":
+		fields := make([]interface{}, 0, len(n.Fields))
+		for _, f := range n.Fields {
+			fields = append(fields, map[string]interface{}{"name": f.Name, "type": schemaDocument(f.Node, defined)})
+		}
+		document["fields"] = fields
+	case "enum":
+		document["symbols"] = n.Symbols
+	case "fixed":
+		document["size"] = n.Size
+	case "array":
+		document["items"] = schemaDocument(n.Items, defined)
+	case "map":
+		document["values"] = schemaDocument(n.Values, defined)
+	case "union":
+		branches := make([]interface{}, 0, len(n.Branches))
+		for _, b := range n.Branches {
+			branches = append(branches, schemaDocument(b, defined))
+		}
+		return branches
+	}
+	return document
+}
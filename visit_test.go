@@ -0,0 +1,310 @@
+package kafkaavro
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingVisitor records the values and the paths it leaves, with the actions of action.
+type recordingVisitor struct {
+	action func(path string, typ string) VisitAction
+	values map[string]interface{}
+	left   []string
+	err    error
+}
+
+func (v *recordingVisitor) Enter(path *VisitPath, typ string) VisitAction {
+	if v.action == nil {
+		return VisitChildren
+	}
+	return v.action(path.String(), typ)
+}
+
+func (v *recordingVisitor) Value(path *VisitPath, value interface{}) error {
+	if v.values == nil {
+		v.values = make(map[string]interface{})
+	}
+	v.values[path.String()] = value
+	return v.err
+}
+
+func (v *recordingVisitor) Leave(path *VisitPath) error {
+	v.left = append(v.left, path.String())
+	return nil
+}
+
+func newFuzzCodec(options ...Option) *Codec {
+	registry := &fakeRegistry{schemas: map[int]string{1: fuzzSchema}, subjects: map[string][]int{}}
+	return NewCodec(registry, TopicNameStrategy{}, options...)
+}
+
+func TestDecodeVisit(t *testing.T) {
+
+	data := validFuzzPayload(t)
+
+	for _, codec := range []*Codec{newFuzzCodec(), newFuzzCodec(WithLogicalTypes())} {
+
+		want, err := codec.Decode("fuzz", false, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the whole value
+		root := &recordingVisitor{action: func(string, string) VisitAction { return VisitValue }}
+		if err = codec.DecodeVisit("fuzz", false, data, root); err != nil || !reflect.DeepEqual(root.values[""], want) {
+			t.Errorf("DecodeVisit() of the value returned %v, %v, want %v", root.values[""], err, want)
+		}
+
+		// the values of the fields
+		fields := &recordingVisitor{action: func(path string, typ string) VisitAction {
+			if path == "" {
+				return VisitChildren
+			}
+			return VisitValue
+		}}
+		if err = codec.DecodeVisit("fuzz", false, data, fields); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(map[string]interface{}(fields.values), want) {
+			t.Errorf("DecodeVisit() of the fields returned %v, want %v", fields.values, want)
+		}
+	}
+
+	// every value, the branches of the unions are not wrapped
+	all := &recordingVisitor{}
+	if err := newFuzzCodec().DecodeVisit("fuzz", false, data, all); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"i": int32(1), "l": int64(-2), "d": 3.5, "s": "four", "b": []byte{5}, "u": "six", "e": "GREEN",
+		"f": []byte("8888"), "a[0]": "nine", "m[ten]": int64(10), "n[0]": nil, "t": time.UnixMilli(1604232000123).UTC(),
+	}
+	if !reflect.DeepEqual(all.values, want) {
+		t.Errorf("DecodeVisit() returned %v, want %v", all.values, want)
+	}
+	if wantLeft := []string{"u", "a", "m", "n", ""}; !reflect.DeepEqual(all.left, wantLeft) {
+		t.Errorf("DecodeVisit() left %q, want %q", all.left, wantLeft)
+	}
+
+	// the skipped values are not decoded
+	var items int
+	skipping := &recordingVisitor{action: func(path string, typ string) VisitAction {
+		if strings.HasPrefix(path, "a[") {
+			items++
+			return VisitSkip
+		}
+		if path == "" || path == "a" {
+			return VisitChildren
+		}
+		return VisitSkip
+	}}
+	if err := newFuzzCodec().DecodeVisit("fuzz", false, data, skipping); err != nil || items != 1 || len(skipping.values) != 0 {
+		t.Errorf("DecodeVisit() skipping the values returned %v, counted %d items and visited %v", err, items, skipping.values)
+	}
+}
+
+func TestDecodeVisitBlockSizes(t *testing.T) {
+
+	codec := newArrayCodec(t)
+	// a block of -2 items of 2 bytes, 1 and 2, and a block of 1 item, 3
+	data := []byte{0, 0, 0, 0, 1, 3, 4, 2, 4, 2, 6, 0}
+	want := map[string]interface{}{"items[0]": int64(1), "items[1]": int64(2), "items[2]": int64(3)}
+
+	all := &recordingVisitor{}
+	if err := codec.DecodeVisit("test", false, data, all); err != nil || !reflect.DeepEqual(all.values, want) {
+		t.Errorf("DecodeVisit() returned %v, %v, want %v", all.values, err, want)
+	}
+
+	root := &recordingVisitor{action: func(string, string) VisitAction { return VisitValue }}
+	if err := codec.DecodeVisit("test", false, data, root); err != nil || !reflect.DeepEqual(root.values[""], map[string]interface{}{"items": []interface{}{int64(1), int64(2), int64(3)}}) {
+		t.Errorf("DecodeVisit() of the value returned %v, %v", root.values[""], err)
+	}
+
+	skipped := &recordingVisitor{action: func(path string, typ string) VisitAction {
+		if path == "" {
+			return VisitChildren
+		}
+		return VisitSkip
+	}}
+	if err := codec.DecodeVisit("test", false, data, skipped); err != nil || len(skipped.values) != 0 {
+		t.Errorf("DecodeVisit() skipping the items returned %v, %v", skipped.values, err)
+	}
+
+	// the blocks are only skipped by their size without a maximum collection size, a block size
+	// beyond the data
+	unlimited := newArrayCodec(t, WithMaxCollectionSize(0))
+	if err := unlimited.DecodeVisit("test", false, data, skipped); err != nil {
+		t.Errorf("DecodeVisit() skipping the blocks by their size returned %v", err)
+	}
+	if err := unlimited.DecodeVisit("test", false, []byte{0, 0, 0, 0, 1, 3, 40, 2, 4, 0}, skipped); !errors.Is(err, ErrMalformedPayload) {
+		t.Errorf("DecodeVisit() of a block size beyond the data returned %v", err)
+	}
+}
+
+func TestDecodeVisitErrors(t *testing.T) {
+
+	codec := newFuzzCodec()
+	data := validFuzzPayload(t)
+
+	var tests = []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"truncated", data[:len(data)-3], ErrMalformedPayload},
+		{"invalid union branch", append([]byte{0, 0, 0, 0, 1, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 8), ErrMalformedPayload},
+		{"too short", []byte{0, 0}, ErrPayloadTooShort},
+		{"not in the wire format", []byte(`{"i":1}`), ErrUnknownMagicByte},
+	}
+	for _, test := range tests {
+		err := codec.DecodeVisit("fuzz", false, test.data, &recordingVisitor{})
+		var decodeErr *DecodeError
+		if !errors.Is(err, test.want) || !errors.As(err, &decodeErr) {
+			t.Errorf("%v: DecodeVisit() returned %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	stop := errors.New("stop")
+	if err := codec.DecodeVisit("fuzz", false, data, &recordingVisitor{err: stop}); err != stop {
+		t.Errorf("DecodeVisit() with a failing visitor returned %v, want the error of the visitor", err)
+	}
+}
+
+// FuzzDecodeVisit visits arbitrary data, which must never panic and either decode or fail with one
+// of the errors of the package, like Decode.
+func FuzzDecodeVisit(f *testing.F) {
+
+	codec := newFuzzCodec(WithMaxPayloadSize(1 << 20))
+
+	f.Add([]byte{0, 0, 0, 0, 1})
+	f.Add(validFuzzPayload(f))
+
+	expected := []error{ErrPayloadTooShort, ErrUnknownMagicByte, ErrPayloadTooLarge, ErrMalformedPayload}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		err := codec.DecodeVisit("fuzz", false, data, &recordingVisitor{})
+		if err == nil {
+			return
+		}
+		if len(data) >= headerSize && data[0] == 0 && getSchemaID(data[1:]) != 1 {
+			return // an unknown schema id
+		}
+		for _, target := range expected {
+			if errors.Is(err, target) {
+				return
+			}
+		}
+		t.Errorf("DecodeVisit(%x) returned %v, which is not one of the errors of the package", data, err)
+	})
+}
+
+// countingVisitor counts the items of the array at the path items without decoding them.
+type countingVisitor struct {
+	items int
+}
+
+func (v *countingVisitor) Enter(path *VisitPath, typ string) VisitAction {
+	if path.Index() < 0 {
+		return VisitChildren
+	}
+	v.items++
+	return VisitSkip
+}
+
+func (v *countingVisitor) Value(*VisitPath, interface{}) error { return nil }
+func (v *countingVisitor) Leave(*VisitPath) error              { return nil }
+
+// TestDecodeVisitAllocations verifies that skipping the items of an array does not allocate for
+// every item.
+func TestDecodeVisitAllocations(t *testing.T) {
+
+	codec := newArrayCodec(t)
+	allocs := func(n int) float64 {
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = int64(i)
+		}
+		data, err := codec.Encode("test", false, map[string]interface{}{"items": items})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return testing.AllocsPerRun(10, func() {
+			codec.DecodeVisit("test", false, data, &countingVisitor{})
+		})
+	}
+	if few, many := allocs(10), allocs(10000); many > few {
+		t.Errorf("DecodeVisit() allocated %v times for 10000 items and %v times for 10 items", many, few)
+	}
+}
+
+func TestVisitPath(t *testing.T) {
+
+	root := &VisitPath{}
+	field := &VisitPath{parent: root, kind: 'f', name: "tags"}
+	value := &VisitPath{parent: field, kind: 'k', name: "color"}
+	item := &VisitPath{parent: value, kind: 'i', index: 2}
+
+	if got := item.String(); got != "tags[color][2]" {
+		t.Errorf("String() returned %q", got)
+	}
+	if root.String() != "" || root.Parent() != nil || root.Field() != "" || root.Index() != -1 {
+		t.Errorf("the top level path is %q of %v", root, root.Parent())
+	}
+	if field.Field() != "tags" || field.Index() != -1 || item.Index() != 2 || item.Parent() != value {
+		t.Errorf("the field is %q and the index %d", field.Field(), item.Index())
+	}
+	if key, ok := value.Key(); !ok || key != "color" {
+		t.Errorf("Key() returned %q, %v", key, ok)
+	}
+	if _, ok := field.Key(); ok {
+		t.Error("Key() of a field returned a key")
+	}
+}
+
+// newLargeArray returns the codec of the arraySchema and the data of an array of a million longs,
+// of about 3MB.
+func newLargeArray(tb testing.TB) (codec *Codec, data []byte) {
+
+	codec = newArrayCodec(tb)
+	items := make([]interface{}, 1<<20)
+	for i := range items {
+		items[i] = int64(i)
+	}
+	data, err := codec.Encode("test", false, map[string]interface{}{"items": items})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return
+}
+
+func BenchmarkDecodeLargeArray(b *testing.B) {
+
+	codec, data := newLargeArray(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		native, err := codec.Decode("test", false, data)
+		if err != nil || len(native.(map[string]interface{})["items"].([]interface{})) != 1<<20 {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeVisitLargeArray(b *testing.B) {
+
+	codec, data := newLargeArray(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var visitor countingVisitor
+		if err := codec.DecodeVisit("test", false, data, &visitor); err != nil || visitor.items != 1<<20 {
+			b.Fatal(err)
+		}
+	}
+}
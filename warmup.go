@@ -0,0 +1,66 @@
+package kafkaavro
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WarmUp fetches the latest schema of every subject into the cache, so that the first messages
+// do not wait for the registry. The schemas are fetched concurrently, see WithWarmUpConcurrency.
+// The subjects which failed are reported by their index in a *BatchError, the subjects which were
+// not fetched yet when the context is done with its error.
+func (c *Codec) WarmUp(ctx context.Context, subjects []SubjectName) error {
+
+	if r := c.registryCodec(nil); r != c {
+		return r.registryError(r.WarmUp(ctx, subjects))
+	}
+
+	type indexedSubject struct {
+		index   int
+		subject SubjectName
+	}
+	seen := make(map[SubjectName]bool, len(subjects))
+	pending := make(chan indexedSubject)
+	errs := &syncBatchError{BatchError: BatchError{Size: len(subjects)}}
+
+	var workers sync.WaitGroup
+	for i := 0; i < c.warmUpConcurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for s := range pending {
+				if _, _, err := c.encoderSchemaFor(ctx, "", s.subject); err != nil {
+					errs.Add(s.index, fmt.Errorf("failed to warm up subject %v: %w", s.subject, err))
+				}
+			}
+		}()
+	}
+
+	skipped := len(subjects)
+dispatch:
+	for i, subjectName := range subjects {
+		if seen[subjectName] {
+			continue
+		}
+		seen[subjectName] = true
+
+		select {
+		case pending <- indexedSubject{i, subjectName}:
+		case <-ctx.Done():
+			skipped = i
+			break dispatch
+		}
+	}
+	close(pending)
+	workers.Wait()
+
+	for i := skipped; i < len(subjects); i++ {
+		if i > skipped && seen[subjects[i]] {
+			continue
+		}
+		seen[subjects[i]] = true
+		errs.Add(i, fmt.Errorf("warm up stopped before subject %v: %w", subjects[i], ctx.Err()))
+	}
+	return errs.Err()
+}
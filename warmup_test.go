@@ -0,0 +1,130 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/schemaregistry"
+)
+
+// slowRegistry serves testSchema for every subject after a delay and records how many
+// requests were in flight at the same time.
+type slowRegistry struct {
+	delay time.Duration
+	fail  map[string]bool
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requests    map[string]int
+}
+
+func (s *slowRegistry) GetSchemaByID(id int) (string, error) {
+	return testSchema, nil
+}
+
+func (s *slowRegistry) GetLatestSchema(subject string) (schema schemaregistry.Schema, err error) {
+
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.requests[subject]++
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+
+	if s.fail[subject] {
+		return schema, fmt.Errorf("subject %v not found", subject)
+	}
+	return schemaregistry.Schema{Subject: subject, Version: 1, ID: len(subject), Schema: testSchema}, nil
+}
+
+func TestWarmUpConcurrency(t *testing.T) {
+
+	registry := &slowRegistry{delay: 10 * time.Millisecond, requests: make(map[string]int)}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithWarmUpConcurrency(3))
+
+	var subjects []SubjectName
+	for i := 0; i < 20; i++ {
+		subjects = append(subjects, fmt.Sprintf("topic%d-value", i), fmt.Sprintf("topic%d-value", i))
+	}
+
+	if err := codec.WarmUp(context.Background(), subjects); err != nil {
+		t.Fatal(err)
+	}
+
+	if registry.maxInFlight != 3 {
+		t.Errorf("%d requests were in flight at the same time, want 3", registry.maxInFlight)
+	}
+	for subject, requests := range registry.requests {
+		if requests != 1 {
+			t.Errorf("subject %v was fetched %d times, want once", subject, requests)
+		}
+	}
+	if len(registry.requests) != 20 {
+		t.Errorf("%d subjects were fetched, want 20", len(registry.requests))
+	}
+
+	// the warmed up subjects are encoded without registry requests
+	if _, err := codec.Encode("topic3", false, map[string]interface{}{"f1": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if registry.requests["topic3-value"] != 1 {
+		t.Errorf("Encode() fetched the warmed up subject again")
+	}
+}
+
+func TestWarmUpErrors(t *testing.T) {
+
+	registry := &slowRegistry{fail: map[string]bool{"a-value": true, "c-value": true}, requests: make(map[string]int)}
+	codec := NewCodec(registry, TopicNameStrategy{})
+
+	err := codec.WarmUp(context.Background(), []SubjectName{"a-value", "b-value", "c-value"})
+	if err == nil {
+		t.Fatal("WarmUp() did not fail")
+	}
+	for _, want := range []string{"failed to warm up subject a-value", "failed to warm up subject c-value"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("WarmUp() returned %v, want it to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "b-value") {
+		t.Errorf("WarmUp() returned %v, which reports the subject which succeeded", err)
+	}
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Size != 3 || len(batchErr.Errors) != 2 || batchErr.Errors[0].Index != 0 || batchErr.Errors[1].Index != 2 {
+		t.Errorf("WarmUp() returned %#v, want a *BatchError of the subjects 0 and 2", err)
+	}
+}
+
+func TestWarmUpContext(t *testing.T) {
+
+	registry := &slowRegistry{delay: 50 * time.Millisecond, requests: make(map[string]int)}
+	codec := NewCodec(registry, TopicNameStrategy{}, WithWarmUpConcurrency(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := codec.WarmUp(ctx, []SubjectName{"a-value", "b-value", "c-value", "d-value"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WarmUp() returned %v, want the context error", err)
+	}
+	if len(registry.requests) >= 4 {
+		t.Errorf("WarmUp() fetched all subjects after the context was done")
+	}
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Errors[len(batchErr.Errors)-1].Index != 3 {
+		t.Errorf("WarmUp() returned %v, want the subjects which were not fetched by their index", err)
+	}
+}
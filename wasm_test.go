@@ -0,0 +1,20 @@
+//go:build js && wasm
+
+package kafkaavro
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDecodeWasm decodes on js/wasm, e.g. with GOOS=js GOARCH=wasm go test -exec "$(go env GOROOT)/lib/wasm/go_js_wasm_exec"
+// in node, TestCrossCompile only compiles it.
+func TestDecodeWasm(t *testing.T) {
+
+	data := []byte{0, 0, 0, 0, 1, 0, 0, 2}
+	codec := NewCodec(SchemaByIDFunc(func(id int) (string, error) { return unionsSchema, nil }), TopicNameStrategy{})
+	want := map[string]interface{}{"note": nil, "value": map[string]interface{}{"int": int32(1)}}
+	if native, err := codec.Decode("events", false, data); err != nil || !reflect.DeepEqual(native, want) {
+		t.Errorf("Decode() returned %v, %v, want %v", native, err, want)
+	}
+}
@@ -0,0 +1,148 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// SchemaWatcher polls the latest versions of subjects and reports the new ones, e.g. to notify the
+// owners of the data when a schema changes. Create it with NewSchemaWatcher and run it with Run.
+type SchemaWatcher struct {
+	codec    *Codec
+	subjects []SubjectName
+	interval time.Duration
+	onChange func(subject SubjectName, old, new SchemaInfo)
+
+	// mu guards latest and polled, which Run and Observe update
+	mu     sync.Mutex
+	latest map[SubjectName]SchemaInfo
+	// polled holds the subjects of which the latest schema was polled once
+	polled map[SubjectName]bool
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSchemaWatcher creates a watcher which polls the latest versions of the subjects every
+// interval and calls onChange with the previous and the new latest schema of a subject when it
+// changes, once per schema. The first successful poll of a subject records its latest schema
+// without reporting it, a subject which is registered later is reported with the zero SchemaInfo
+// as old schema. Of the options, WithClock, WithLogger, WithRetry and WithHooks apply.
+func NewSchemaWatcher(client RegistryClient, subjects []SubjectName, interval time.Duration, onChange func(subject SubjectName, old, new SchemaInfo), options ...Option) *SchemaWatcher {
+
+	return &SchemaWatcher{
+		codec:    NewCodec(client, TopicNameStrategy{}, options...),
+		subjects: subjects,
+		interval: interval,
+		onChange: onChange,
+		latest:   make(map[SubjectName]SchemaInfo, len(subjects)),
+		polled:   make(map[SubjectName]bool, len(subjects)),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Run polls the subjects until the context is done, returning its error, or until the watcher is
+// closed, returning nil. The registry errors of a poll are logged and the subject is polled again
+// at the next interval.
+func (w *SchemaWatcher) Run(ctx context.Context) error {
+
+	for {
+		for _, subject := range w.subjects {
+			if err := w.poll(ctx, subject); err != nil && w.codec.logger != nil {
+				w.codec.logger.Warn("failed to poll the latest schema", "subject", subject, "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.stop:
+			return nil
+		case <-w.codec.clock.After(w.interval):
+		}
+	}
+}
+
+// Close stops Run. Closing a watcher more than once has no effect.
+func (w *SchemaWatcher) Close() error {
+	w.closeOnce.Do(func() { close(w.stop) })
+	return nil
+}
+
+// Observe reports the schema of an OnNewSchemaObserved hook as the new latest schema of its
+// subject if the subject is watched and the schema id is higher than the one of the latest
+// schema, the registry assigns increasing ids. It reports a new schema of the data which is
+// consumed without waiting for the next poll:
+//
+//	codec := kafkaavro.NewCodec(client, strategy, kafkaavro.WithHooks(kafkaavro.Hooks{OnNewSchemaObserved: watcher.Observe}))
+func (w *SchemaWatcher) Observe(topic string, info SchemaInfo) {
+
+	if !w.watches(info.Subject) {
+		return
+	}
+	w.update(info.Subject, info, false)
+}
+
+func (w *SchemaWatcher) watches(subject SubjectName) bool {
+	for _, s := range w.subjects {
+		if s == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// poll fetches the latest schema of the subject, a subject which is not registered has no latest
+// schema.
+func (w *SchemaWatcher) poll(ctx context.Context, subject SubjectName) (err error) {
+
+	var latest SchemaInfo
+	err = w.codec.withRetry(ctx, HookEvent{Subject: subject}, func() (err error) {
+		schema, err := w.codec.client.GetLatestSchema(subject)
+		latest = SchemaInfo{ID: schema.ID, Subject: subject, Version: schema.Version, Schema: schema.Schema, SchemaType: schema.Type()}
+		return
+	})
+	if errors.Is(err, ErrSchemaNotFound) {
+		w.update(subject, SchemaInfo{}, true)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	latest.RecordName = recordName(latest.Schema)
+	w.update(subject, latest, true)
+	return
+}
+
+// update records the latest schema of the subject if it is newer than the recorded one, and
+// reports it if the subject was polled before. The recorded schema gets the version of a poll of
+// the same schema id, which an observed schema does not know.
+func (w *SchemaWatcher) update(subject SubjectName, latest SchemaInfo, polled bool) {
+
+	w.mu.Lock()
+	old := w.latest[subject]
+	changed := w.polled[subject] && latest.ID > old.ID
+	if latest.ID > old.ID || (latest.ID == old.ID && old.Version == 0) {
+		w.latest[subject] = latest
+	}
+	if polled {
+		w.polled[subject] = true
+	}
+	w.mu.Unlock()
+
+	if changed {
+		w.callOnChange(subject, old, latest)
+	}
+}
+
+func (w *SchemaWatcher) callOnChange(subject SubjectName, old SchemaInfo, new SchemaInfo) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil && w.codec.logger != nil {
+			w.codec.logger.Warn("schema watcher callback panicked", "subject", subject, "panic", recovered)
+		}
+	}()
+
+	w.onChange(subject, old, new)
+}
@@ -0,0 +1,92 @@
+package kafkaavro
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timvw/kafkaavro/clocktest"
+	"github.com/timvw/kafkaavro/mockregistry"
+)
+
+type schemaChange struct {
+	subject  SubjectName
+	old, new SchemaID
+	version  SubjectVersion
+}
+
+func TestSchemaWatcher(t *testing.T) {
+
+	registry := mockregistry.New()
+	v1 := registry.Register("orders-value", `"long"`)
+	clock := clocktest.New(time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC))
+
+	var mu sync.Mutex
+	var changes []schemaChange
+	onChange := func(subject SubjectName, old, new SchemaInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, schemaChange{subject, old.ID, new.ID, new.Version})
+	}
+	expectChanges := func(step string, want ...schemaChange) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("%v: the changes are %+v, want %+v", step, changes, want)
+		}
+		changes = nil
+	}
+	poll := func() {
+		clock.Advance(time.Minute)
+		clock.BlockUntil(1)
+	}
+
+	watcher := NewSchemaWatcher(registry, []SubjectName{"orders-value", "payments-value"}, time.Minute, onChange, WithClock(clock))
+	done := make(chan error)
+	go func() { done <- watcher.Run(context.Background()) }()
+	clock.BlockUntil(1)
+	expectChanges("the first poll")
+
+	// the poll of orders-value fails, payments-value is registered
+	v2 := registry.Register("orders-value", `"string"`)
+	payments := registry.Register("payments-value", `"int"`)
+	registry.Script(mockregistry.GetLatestSchema, mockregistry.Fail(mockregistry.ErrUnavailable))
+	poll()
+	expectChanges("a failed poll", schemaChange{"payments-value", 0, payments, 1})
+
+	poll()
+	expectChanges("the next poll", schemaChange{"orders-value", v1, v2, 2})
+	poll()
+	expectChanges("a poll without changes")
+
+	// an observed schema is reported before the poll, which does not report it again
+	v3 := registry.Register("orders-value", `"double"`)
+	watcher.Observe("orders", SchemaInfo{ID: v3, Subject: "orders-value"})
+	watcher.Observe("orders", SchemaInfo{ID: v1, Subject: "orders-value"})
+	watcher.Observe("refunds", SchemaInfo{ID: v3 + 1, Subject: "refunds-value"})
+	expectChanges("observed schemas", schemaChange{"orders-value", v2, v3, 0})
+	poll()
+	expectChanges("the poll of an observed schema")
+
+	watcher.Close()
+	watcher.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Run() after Close() returned %v", err)
+	}
+}
+
+func TestSchemaWatcherContext(t *testing.T) {
+
+	registry := mockregistry.New()
+	watcher := NewSchemaWatcher(registry, []SubjectName{"orders-value"}, time.Minute, func(SubjectName, SchemaInfo, SchemaInfo) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() with a cancelled context returned %v", err)
+	}
+}